@@ -32,6 +32,7 @@ impl<C: Config> MultiField32ChallengerVariable<C> {
 
     pub fn duplexing(&mut self, builder: &mut Builder<C>) {
         assert!(self.input_buffer.len() <= self.num_f_elms * SPONGE_SIZE);
+        builder.cycle_tracker("challenger");
 
         for (i, f_chunk) in self.input_buffer.chunks(self.num_f_elms).enumerate() {
             self.sponge_state[i] = reduce_32(builder, f_chunk);
@@ -47,6 +48,7 @@ impl<C: Config> MultiField32ChallengerVariable<C> {
                 self.output_buffer.push(f_val);
             }
         }
+        builder.cycle_tracker("challenger");
     }
 
     pub fn observe(&mut self, builder: &mut Builder<C>, value: Felt<C::F>) {
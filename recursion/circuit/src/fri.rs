@@ -71,7 +71,9 @@ pub fn verify_two_adic_pcs<C: Config>(
             let mut alpha_pow: [Ext<C::F, C::EF>; 32] =
                 [builder.eval(SymbolicExt::from_f(C::EF::one())); 32];
 
-            for (batch_opening, round) in izip!(query_opening.clone(), &rounds) {
+            for (i, (batch_opening, round)) in izip!(query_opening.clone(), &rounds).enumerate() {
+                let region = format!("pcs-round-{}", i);
+                builder.cycle_tracker(&region);
                 let batch_commit = round.batch_commit;
                 let mats = &round.mats;
                 let batch_heights = mats
@@ -125,6 +127,7 @@ pub fn verify_two_adic_pcs<C: Config>(
                         }
                     }
                 }
+                builder.cycle_tracker(&region);
             }
             ro
         })
@@ -152,6 +155,7 @@ pub fn verify_challenges<C: Config>(
         &proof.query_proofs,
         reduced_openings
     ) {
+        builder.cycle_tracker("fri-query");
         let folded_eval = verify_query(
             builder,
             proof.commit_phase_commits.clone(),
@@ -163,6 +167,7 @@ pub fn verify_challenges<C: Config>(
         );
 
         builder.assert_ext_eq(folded_eval, proof.final_poly);
+        builder.cycle_tracker("fri-query");
     }
 }
 
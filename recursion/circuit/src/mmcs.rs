@@ -69,3 +69,68 @@ pub fn verify_batch<C: Config, const D: usize>(
 
     builder.assert_var_eq(root[0], commit[0]);
 }
+
+#[cfg(test)]
+mod tests {
+    use itertools::Itertools;
+    use p3_baby_bear::BabyBear;
+    use p3_commit::Mmcs;
+    use p3_field::{AbstractField, PrimeField32};
+    use p3_matrix::dense::RowMajorMatrix;
+    use sp1_core::utils::{inner_perm, InnerCompress, InnerHash, InnerValMmcs};
+
+    fn format_felts(felts: &[BabyBear]) -> String {
+        format!(
+            "[{}]",
+            felts.iter().map(|f| f.as_canonical_u32()).join(", ")
+        )
+    }
+
+    /// Prints the openings of sp1-recursion-gnark/sp1/merkle/testdata/mmcs_openings.json. The
+    /// heights of the matrices differ by more than one level, so that their rows are injected
+    /// past empty levels of the tree.
+    #[test]
+    #[ignore]
+    fn test_generate_mmcs_fixture() {
+        let perm = inner_perm();
+        let mmcs = InnerValMmcs::new(InnerHash::new(perm.clone()), InnerCompress::new(perm));
+        let dims = [(3, 32), (4, 8), (5, 32), (9, 2), (2, 1)];
+        let matrices: Vec<RowMajorMatrix<BabyBear>> = dims
+            .iter()
+            .enumerate()
+            .map(|(k, &(width, height))| {
+                let values = (0..height * width)
+                    .map(|i| {
+                        let (r, c) = (i / width, i % width);
+                        let v = (k as u64 * 1000003 + r as u64 * 7919 + c as u64 * 31 + 1) * 104729;
+                        BabyBear::from_canonical_u64(v % BabyBear::ORDER_U32 as u64)
+                    })
+                    .collect();
+                RowMajorMatrix::new(values, width)
+            })
+            .collect();
+        let (commit, data) = mmcs.commit(matrices);
+        let commit: [BabyBear; 8] = commit.into();
+
+        let openings: Vec<String> = [0, 13, 31]
+            .iter()
+            .map(|&index| {
+                let (rows, proof) = mmcs.open_batch(index, &data);
+                format!(
+                    "    {{\n      \"index\": {},\n      \"rows\": [\n{}\n      ],\n      \"proof\": [\n{}\n      ]\n    }}",
+                    index,
+                    rows.iter().map(|row| format!("        {}", format_felts(row))).join(",\n"),
+                    proof.iter().map(|s| format!("        {}", format_felts(s))).join(",\n"),
+                )
+            })
+            .collect();
+        println!(
+            "{{\n  \"dims\": [\n{}\n  ],\n  \"commit\": {},\n  \"openings\": [\n{}\n  ]\n}}",
+            dims.iter()
+                .map(|(w, h)| format!("    {{\"width\": {}, \"height\": {}}}", w, h))
+                .join(",\n"),
+            format_felts(&commit),
+            openings.join(",\n"),
+        );
+    }
+}
@@ -218,6 +218,8 @@ where
                     let quotient_domain = &quotient_domains[i];
                     let qc_domains =
                         quotient_domain.split_domains_const(builder, chip.log_quotient_degree());
+                    let region = format!("chip-{}", chip.name());
+                    builder.cycle_tracker(&region);
                     Self::verify_constraints(
                         builder,
                         chip,
@@ -229,6 +231,7 @@ where
                         alpha,
                         &permutation_challenges,
                     );
+                    builder.cycle_tracker(&region);
                 }
             }
         }
@@ -358,6 +358,10 @@ impl<C: Config + Debug> ConstraintCompiler<C> {
                         vec![a[3].id()],
                     ],
                 }),
+                DslIr::CycleTracker(name) => constraints.push(Constraint {
+                    opcode: ConstraintOpcode::CycleTracker,
+                    args: vec![vec![name]],
+                }),
                 _ => panic!("unsupported {:?}", instruction),
             };
         }
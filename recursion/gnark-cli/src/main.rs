@@ -2,13 +2,20 @@
 //! native feature is disabled.
 
 use sp1_recursion_gnark_ffi::ffi::{
-    build_plonk_bn254, prove_plonk_bn254, test_plonk_bn254, verify_plonk_bn254,
+    build_plonk_bn254, check_plonk_bn254, progress_plonk_bn254, prove_plonk_bn254,
+    test_plonk_bn254, verify_plonk_bn254,
 };
 
 use clap::{Args, Parser, Subcommand};
 use std::{
     fs::File,
     io::{read_to_string, Write},
+    sync::{
+        atomic::{AtomicBool, Ordering},
+        Arc,
+    },
+    thread,
+    time::Duration,
 };
 
 #[derive(Debug, Parser)]
@@ -24,6 +31,7 @@ enum Command {
     ProvePlonk(ProveArgs),
     VerifyPlonk(VerifyArgs),
     TestPlonk(TestArgs),
+    Check(CheckArgs),
 }
 
 #[derive(Debug, Args)]
@@ -53,14 +61,50 @@ struct TestArgs {
     constraints_json: String,
 }
 
+#[derive(Debug, Args)]
+struct CheckArgs {
+    witness_json: String,
+    constraints_json: String,
+    output_path: String,
+}
+
 fn run_build(args: BuildArgs) {
     build_plonk_bn254(&args.data_dir);
 }
 
 fn run_prove(args: ProveArgs) {
-    let proof = prove_plonk_bn254(&args.data_dir, &args.witness_path);
+    let finished = Arc::new(AtomicBool::new(false));
+    let reporter = {
+        let finished = finished.clone();
+        thread::spawn(move || report_progress(&finished))
+    };
+    let result = prove_plonk_bn254(&args.data_dir, &args.witness_path);
+    finished.store(true, Ordering::Relaxed);
+    reporter.join().unwrap();
     let mut file = File::create(&args.output_path).unwrap();
-    bincode::serialize_into(&mut file, &proof).unwrap();
+    bincode::serialize_into(&mut file, &result).unwrap();
+}
+
+/// Renders the progress of the running proof as a single line on stderr until `finished` is set.
+fn report_progress(finished: &AtomicBool) {
+    let mut stderr = std::io::stderr();
+    while !finished.load(Ordering::Relaxed) {
+        if let Some((stage, done, total)) = progress_plonk_bn254() {
+            let percent = if total > 0 {
+                100 * done as i64 / total as i64
+            } else {
+                100
+            };
+            let _ = write!(
+                stderr,
+                "\r[sp1] {:<12} {:>3}% ({}/{})",
+                stage, percent, done, total
+            );
+            let _ = stderr.flush();
+        }
+        thread::sleep(Duration::from_millis(500));
+    }
+    let _ = writeln!(stderr);
 }
 
 fn run_verify(args: VerifyArgs) {
@@ -85,6 +129,16 @@ fn run_test(args: TestArgs) {
     test_plonk_bn254(&args.witness_json, &args.constraints_json);
 }
 
+fn run_check(args: CheckArgs) {
+    let result = check_plonk_bn254(&args.witness_json, &args.constraints_json);
+    let output = match result {
+        Ok(_) => "OK".to_string(),
+        Err(e) => e,
+    };
+    let mut file = File::create(&args.output_path).unwrap();
+    file.write_all(output.as_bytes()).unwrap();
+}
+
 fn main() {
     let cli = Cli::parse();
 
@@ -93,5 +147,6 @@ fn main() {
         Command::ProvePlonk(args) => run_prove(args),
         Command::VerifyPlonk(args) => run_verify(args),
         Command::TestPlonk(args) => run_test(args),
+        Command::Check(args) => run_check(args),
     }
 }
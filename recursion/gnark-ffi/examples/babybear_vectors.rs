@@ -0,0 +1,117 @@
+//! Generates the test vectors the BabyBear chip of the Go circuit is checked against, from the
+//! Plonky3 field the SP1 prover computes with. Refresh them with:
+//!
+//! ```sh
+//! cargo run --example babybear_vectors -p sp1-recursion-gnark-ffi > go/sp1/babybear/testdata/vectors.json
+//! ```
+use p3_baby_bear::BabyBear;
+use p3_field::{
+    extension::BinomialExtensionField, AbstractExtensionField, AbstractField, Field, PrimeField32,
+};
+use rand::{rngs::StdRng, Rng, SeedableRng};
+use serde_json::{json, Value};
+
+type EF = BinomialExtensionField<BabyBear, 4>;
+
+/// The number of random vectors of each field, after the edge cases.
+const NUM_RANDOM_VECTORS: usize = 16;
+
+fn felt(x: BabyBear) -> Value {
+    json!(x.as_canonical_u32())
+}
+
+fn ext(x: EF) -> Value {
+    let coordinates: Vec<u32> = x
+        .as_base_slice()
+        .iter()
+        .map(|c| c.as_canonical_u32())
+        .collect();
+    json!(coordinates)
+}
+
+fn random_felt(rng: &mut StdRng) -> BabyBear {
+    BabyBear::from_canonical_u32(rng.gen_range(1..BabyBear::ORDER_U32))
+}
+
+fn random_ext(rng: &mut StdRng) -> EF {
+    EF::from_base_fn(|_| random_felt(rng))
+}
+
+/// The results of the base field operations on a and b, and of a to the power e. The inverse is
+/// the one of a, so a and b must be non-zero.
+fn base_vector(a: BabyBear, b: BabyBear, e: u64) -> Value {
+    json!({
+        "a": felt(a),
+        "b": felt(b),
+        "e": e,
+        "add": felt(a + b),
+        "sub": felt(a - b),
+        "mul": felt(a * b),
+        "neg": felt(-a),
+        "inv": felt(a.inverse()),
+        "div": felt(a / b),
+        "exp": felt(a.exp_u64(e)),
+    })
+}
+
+/// The extension field counterpart of base_vector, with the products by the base field element c.
+fn ext_vector(a: EF, b: EF, c: BabyBear, e: u64) -> Value {
+    json!({
+        "a": ext(a),
+        "b": ext(b),
+        "c": felt(c),
+        "e": e,
+        "add": ext(a + b),
+        "sub": ext(a - b),
+        "mul": ext(a * b),
+        "neg": ext(-a),
+        "inv": ext(a.inverse()),
+        "div": ext(a / b),
+        "exp": ext(a.exp_u64(e)),
+        "mul_base": ext(a * c),
+        "div_base": ext(a / EF::from_base(c)),
+    })
+}
+
+fn main() {
+    let mut rng = StdRng::seed_from_u64(0);
+    let minus_one = BabyBear::neg_one();
+
+    let mut base = vec![
+        base_vector(BabyBear::one(), minus_one, 0),
+        base_vector(minus_one, minus_one, u64::MAX),
+        base_vector(
+            BabyBear::two(),
+            BabyBear::one(),
+            (BabyBear::ORDER_U32 - 1) as u64,
+        ),
+    ];
+    for _ in 0..NUM_RANDOM_VECTORS {
+        base.push(base_vector(
+            random_felt(&mut rng),
+            random_felt(&mut rng),
+            rng.gen(),
+        ));
+    }
+
+    let mut extension = vec![
+        ext_vector(EF::one(), EF::from_base(minus_one), minus_one, 0),
+        ext_vector(
+            EF::from_base(minus_one),
+            EF::from_base_fn(|_| minus_one),
+            minus_one,
+            u64::MAX,
+        ),
+    ];
+    for _ in 0..NUM_RANDOM_VECTORS {
+        extension.push(ext_vector(
+            random_ext(&mut rng),
+            random_ext(&mut rng),
+            random_felt(&mut rng),
+            rng.gen(),
+        ));
+    }
+
+    let vectors = json!({ "base": base, "extension": extension });
+    println!("{}", serde_json::to_string_pretty(&vectors).unwrap());
+}
@@ -0,0 +1,70 @@
+//! Generates the transcript the challenger of the Go verifier is checked against, from the
+//! `DuplexChallenger` of the SP1 prover. Refresh it with:
+//!
+//! ```sh
+//! cargo run --example challenger_transcript -p sp1-recursion-gnark-ffi > go/sp1/verifier/testdata/challenger_transcript.json
+//! ```
+use p3_baby_bear::BabyBear;
+use p3_challenger::{CanObserve, CanSample, CanSampleBits, FieldChallenger};
+use p3_field::{
+    extension::BinomialExtensionField, AbstractExtensionField, AbstractField, PrimeField32,
+};
+use p3_symmetric::Hash;
+use rand::{rngs::StdRng, Rng, SeedableRng};
+use serde_json::{json, Value};
+use sp1_core::utils::{inner_perm, InnerChallenger};
+
+type EF = BinomialExtensionField<BabyBear, 4>;
+
+fn felts(values: &[BabyBear]) -> Value {
+    json!(values
+        .iter()
+        .map(|v| v.as_canonical_u32())
+        .collect::<Vec<_>>())
+}
+
+fn random_felts(rng: &mut StdRng, n: usize) -> Vec<BabyBear> {
+    (0..n)
+        .map(|_| BabyBear::from_canonical_u32(rng.gen_range(0..BabyBear::ORDER_U32)))
+        .collect()
+}
+
+fn main() {
+    let mut rng = StdRng::seed_from_u64(0);
+    let mut challenger = InnerChallenger::new(inner_perm());
+    let mut ops = Vec::new();
+
+    // Observations of fewer than, exactly and more than the 8 elements of the rate, each followed
+    // by samples, the last runs of which exhaust the permuted state and duplex again without input.
+    for (nb_observed, nb_sampled) in [(1, 1), (4, 2), (8, 3), (13, 17), (0, 20)] {
+        let observed = random_felts(&mut rng, nb_observed);
+        for &value in &observed {
+            challenger.observe(value);
+        }
+        let sampled: Vec<BabyBear> = (0..nb_sampled).map(|_| challenger.sample()).collect();
+        ops.push(json!({ "op": "observe", "values": felts(&observed) }));
+        ops.push(json!({ "op": "sample", "values": felts(&sampled) }));
+    }
+
+    let commitment: [BabyBear; 8] = random_felts(&mut rng, 8).try_into().unwrap();
+    challenger.observe(Hash::<BabyBear, BabyBear, 8>::from(commitment));
+    ops.push(json!({ "op": "observe_commitment", "values": felts(&commitment) }));
+
+    let observed = EF::from_base_slice(&random_felts(&mut rng, 4));
+    challenger.observe_ext_element(observed);
+    ops.push(json!({ "op": "observe_ext", "values": felts(observed.as_base_slice()) }));
+    for _ in 0..3 {
+        let sampled: EF = challenger.sample_ext_element();
+        ops.push(json!({ "op": "sample_ext", "values": felts(sampled.as_base_slice()) }));
+    }
+
+    for bits in [1, 10, 16, 27, 30] {
+        let sampled = challenger.sample_bits(bits);
+        ops.push(json!({ "op": "sample_bits", "bits": bits, "values": [sampled as u64] }));
+    }
+
+    println!(
+        "{}",
+        serde_json::to_string_pretty(&json!({ "ops": ops })).unwrap()
+    );
+}
@@ -0,0 +1,25 @@
+// Command convert-witness converts a witness file between the JSON and the binary witness formats.
+//
+//	convert-witness witness.json witness.bin
+//
+// The input may be in either format and gzip compressed. The output is written as JSON if its name
+// ends with .json and in the binary witness format otherwise.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1"
+)
+
+func main() {
+	if len(os.Args) != 3 {
+		fmt.Fprintln(os.Stderr, "usage: convert-witness <input> <output>")
+		os.Exit(2)
+	}
+	if err := sp1.ConvertWitnessInput(os.Args[1], os.Args[2]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
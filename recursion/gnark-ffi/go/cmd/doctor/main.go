@@ -0,0 +1,25 @@
+// Command doctor checks that the artifacts of a built data directory belong to the same build.
+//
+//	doctor build/
+//
+// It prints the inconsistent artifact and exits with a non-zero status if the check fails.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/artifacts"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: doctor <data dir>")
+		os.Exit(2)
+	}
+	if err := artifacts.SelfTest(os.Args[1]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Println("artifacts are consistent")
+}
@@ -0,0 +1,88 @@
+// Command server proves witnesses posted over HTTP with the circuit and keys of a built data
+// directory, which it reads once at startup.
+//
+//	server --data build/ --addr :8080 --workers 1 --queue 16
+//
+// POST /prove queues the witness of the body, in the JSON or binary format of the witness files,
+// and responds with 202 Accepted and its job, or with 503 Service Unavailable when the queue is
+// full. GET /jobs/{id} returns the status of the job, queued, running, done or failed, with its
+// error or its proof, whose public inputs and encoded proof are hex strings. Finished jobs are
+// kept for the --retention duration.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/logging"
+)
+
+func main() {
+	dataDir := flag.String("data", "", "the data directory of the circuit")
+	addr := flag.String("addr", ":8080", "the address to listen on")
+	workers := flag.Int("workers", 1, "the number of proofs generated at once")
+	queueSize := flag.Int("queue", 16, "the number of jobs waiting for a worker")
+	maxWitnessSize := flag.Int64("max-witness-size", 1<<30, "the largest witness accepted, in bytes")
+	retention := flag.Duration("retention", time.Hour, "how long a finished job can be fetched")
+	parallelism := flag.Int("parallelism", 0, "the number of goroutines converting the witness and solving the circuit of a proof, GOMAXPROCS by default")
+	flag.Parse()
+	sp1.PARALLELISM = *parallelism
+	if *dataDir == "" || *workers < 1 || *queueSize < 0 {
+		fmt.Fprintln(os.Stderr, "usage: server --data <data dir> [--addr :8080] [--workers 1] [--queue 16]")
+		os.Exit(2)
+	}
+	if err := serve(*dataDir, *addr, config{
+		workers:        *workers,
+		queueSize:      *queueSize,
+		maxWitnessSize: *maxWitnessSize,
+		retention:      *retention,
+	}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// serve serves the prover of dataDir on addr until it is interrupted, which cancels the running
+// proofs.
+func serve(dataDir string, addr string, config config) error {
+	logger := logging.Default()
+	start := time.Now()
+	prover, err := sp1.NewProver(dataDir)
+	if err != nil {
+		return fmt.Errorf("load: %w", err)
+	}
+	logger.Info("loaded the circuit and keys", "data_dir", dataDir, "duration", time.Since(start))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	s := newServer(prover, logger, config)
+	done := make(chan struct{})
+	go func() {
+		s.run(ctx)
+		close(done)
+	}()
+
+	httpServer := &http.Server{Addr: addr, Handler: s.handler()}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdown)
+	}()
+	logger.Info("listening", "addr", addr, "workers", config.workers, "queue", config.queueSize)
+	if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		stop()
+		<-done
+		return err
+	}
+	<-done
+	return nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/logging"
+)
+
+// The statuses of a job, in the order a job goes through them. A job ends up DONE or FAILED.
+const (
+	QUEUED  = "queued"
+	RUNNING = "running"
+	DONE    = "done"
+	FAILED  = "failed"
+)
+
+// prover is the part of *sp1.Prover the server uses.
+type prover interface {
+	Prove(ctx context.Context, witnessInput sp1.WitnessInput, opts sp1.ProveOptions) (sp1.Proof, error)
+}
+
+// job is a witness submitted to the server. Its exported fields are the response of the jobs
+// endpoint.
+type job struct {
+	ID     string     `json:"id"`
+	Status string     `json:"status"`
+	Error  string     `json:"error,omitempty"`
+	Proof  *sp1.Proof `json:"proof,omitempty"`
+
+	witness sp1.WitnessInput
+}
+
+type config struct {
+	// The number of proofs generated at once, and of jobs waiting for a worker beyond them.
+	workers   int
+	queueSize int
+	// The largest witness accepted, in bytes.
+	maxWitnessSize int64
+	// How long a finished job can be fetched.
+	retention time.Duration
+}
+
+// server proves the witnesses posted to it with a pool of workers sharing the same prover, so
+// that the circuit and keys are only read once.
+type server struct {
+	prover prover
+	logger logging.Logger
+	config config
+	queue  chan *job
+
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+func newServer(prover prover, logger logging.Logger, config config) *server {
+	return &server{
+		prover: prover,
+		logger: logging.OrDefault(logger),
+		config: config,
+		queue:  make(chan *job, config.queueSize),
+		jobs:   make(map[string]*job),
+	}
+}
+
+// run proves the queued jobs until ctx is done, which cancels the running proofs.
+func (s *server) run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < s.config.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case j := <-s.queue:
+					s.prove(ctx, j)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *server) prove(ctx context.Context, j *job) {
+	s.setStatus(j, RUNNING, nil, nil)
+	s.logger.Info("proving", "job", j.ID)
+	proof, err := s.prover.Prove(ctx, j.witness, sp1.ProveOptions{Logger: s.logger})
+	if err != nil {
+		s.logger.Error("proof failed", "job", j.ID, "error", err)
+		s.setStatus(j, FAILED, nil, err)
+	} else {
+		s.logger.Info("proved", "job", j.ID)
+		s.setStatus(j, DONE, &proof, nil)
+	}
+
+	// The witness is no longer needed, and the result is only kept for the retention period.
+	time.AfterFunc(s.config.retention, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.jobs, j.ID)
+	})
+}
+
+func (s *server) setStatus(j *job, status string, proof *sp1.Proof, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j.Status = status
+	j.Proof = proof
+	if err != nil {
+		j.Error = err.Error()
+	}
+	if status == DONE || status == FAILED {
+		j.witness = sp1.WitnessInput{}
+	}
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /prove", s.handleProve)
+	mux.HandleFunc("GET /jobs/{id}", s.handleJob)
+	mux.HandleFunc("GET /healthz", s.handleHealth)
+	return mux
+}
+
+// handleProve queues the witness of the request body, in the JSON or binary witness format, and
+// responds with its job. It responds with 503 Service Unavailable when the queue is full.
+func (s *server) handleProve(w http.ResponseWriter, r *http.Request) {
+	witnessInput, err := sp1.DecodeWitnessInput(http.MaxBytesReader(w, r.Body, s.config.maxWitnessSize))
+	if err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeError(w, status, fmt.Errorf("invalid witness: %w", err))
+		return
+	}
+	id, err := newJobID()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	j := &job{ID: id, Status: QUEUED, witness: witnessInput}
+
+	s.mu.Lock()
+	select {
+	case s.queue <- j:
+		s.jobs[id] = j
+		s.mu.Unlock()
+	default:
+		s.mu.Unlock()
+		writeError(w, http.StatusServiceUnavailable, errors.New("the queue is full"))
+		return
+	}
+	s.logger.Info("queued", "job", id)
+	w.Header().Set("Location", "/jobs/"+id)
+	s.writeJob(w, http.StatusAccepted, j)
+}
+
+func (s *server) handleJob(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	j, ok := s.jobs[r.PathValue("id")]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("unknown job"))
+		return
+	}
+	s.writeJob(w, http.StatusOK, j)
+}
+
+func (s *server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]int{"workers": s.config.workers, "queued": len(s.queue)})
+}
+
+// writeJob writes j, read while the workers do not update it.
+func (s *server) writeJob(w http.ResponseWriter, status int, j *job) {
+	s.mu.Lock()
+	response := *j
+	s.mu.Unlock()
+	writeJSON(w, status, &response)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func newJobID() (string, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id[:]), nil
+}
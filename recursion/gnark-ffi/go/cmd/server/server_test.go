@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/logging"
+)
+
+// blockingProver proves once it is released, failing the witnesses without vars.
+type blockingProver struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p *blockingProver) Prove(ctx context.Context, witnessInput sp1.WitnessInput, opts sp1.ProveOptions) (sp1.Proof, error) {
+	p.started <- struct{}{}
+	select {
+	case <-p.release:
+	case <-ctx.Done():
+		return sp1.Proof{}, ctx.Err()
+	}
+	if len(witnessInput.Vars) == 0 {
+		return sp1.Proof{}, errors.New("no vars")
+	}
+	return sp1.Proof{PublicInputs: [2]string{witnessInput.VkeyHash, witnessInput.CommitedValuesDigest}}, nil
+}
+
+func startServer(t *testing.T, prover prover, config config) *httptest.Server {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := newServer(prover, logging.Nop(), config)
+	done := make(chan struct{})
+	go func() {
+		s.run(ctx)
+		close(done)
+	}()
+	server := httptest.NewServer(s.handler())
+	t.Cleanup(func() {
+		server.Close()
+		cancel()
+		<-done
+	})
+	return server
+}
+
+func post(t *testing.T, server *httptest.Server, body []byte) (int, job) {
+	response, err := http.Post(server.URL+"/prove", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	var j job
+	json.NewDecoder(response.Body).Decode(&j)
+	return response.StatusCode, j
+}
+
+func get(t *testing.T, server *httptest.Server, id string) (int, job) {
+	response, err := http.Get(server.URL + "/jobs/" + id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	var j job
+	json.NewDecoder(response.Body).Decode(&j)
+	return response.StatusCode, j
+}
+
+// wait polls the job id until it is finished.
+func wait(t *testing.T, server *httptest.Server, id string) job {
+	for deadline := time.Now().Add(time.Minute); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		status, j := get(t, server, id)
+		if status != http.StatusOK {
+			t.Fatalf("job %s: status %d", id, status)
+		}
+		if j.Status == DONE || j.Status == FAILED {
+			return j
+		}
+	}
+	t.Fatalf("job %s did not finish", id)
+	return job{}
+}
+
+func witness(vars ...string) []byte {
+	data, _ := json.Marshal(sp1.WitnessInput{Vars: vars, Felts: []string{}, Exts: []sp1.ExtValue{}, VkeyHash: "123", CommitedValuesDigest: "456"})
+	return data
+}
+
+func TestServerQueue(t *testing.T) {
+	prover := &blockingProver{started: make(chan struct{}), release: make(chan struct{})}
+	server := startServer(t, prover, config{workers: 1, queueSize: 1, maxWitnessSize: 1 << 20, retention: time.Minute})
+
+	// The first job is running and the second waits in the queue, which is then full.
+	status, running := post(t, server, witness("1"))
+	if status != http.StatusAccepted || running.Status != QUEUED || running.ID == "" {
+		t.Fatalf("unexpected response %d %+v", status, running)
+	}
+	<-prover.started
+	if _, j := get(t, server, running.ID); j.Status != RUNNING {
+		t.Fatalf("expected the job to be running, got %+v", j)
+	}
+	status, failing := post(t, server, witness())
+	if status != http.StatusAccepted {
+		t.Fatalf("expected the job to be queued, got %d", status)
+	}
+	if status, _ := post(t, server, witness("2")); status != http.StatusServiceUnavailable {
+		t.Fatalf("expected a full queue, got %d", status)
+	}
+
+	prover.release <- struct{}{}
+	if j := wait(t, server, running.ID); j.Status != DONE || j.Proof == nil || j.Proof.PublicInputs != [2]string{"123", "456"} {
+		t.Fatalf("unexpected job %+v", j)
+	}
+	<-prover.started
+	prover.release <- struct{}{}
+	if j := wait(t, server, failing.ID); j.Status != FAILED || j.Error != "no vars" || j.Proof != nil {
+		t.Fatalf("unexpected job %+v", j)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	prover := &blockingProver{started: make(chan struct{}), release: make(chan struct{})}
+	server := startServer(t, prover, config{workers: 1, queueSize: 1, maxWitnessSize: 64, retention: time.Minute})
+	for name, c := range map[string]struct {
+		body   []byte
+		status int
+	}{
+		"malformed": {[]byte("{"), http.StatusBadRequest},
+		"too large": {witness(make([]string, 64)...), http.StatusRequestEntityTooLarge},
+	} {
+		if status, _ := post(t, server, c.body); status != c.status {
+			t.Errorf("%s: expected status %d, got %d", name, c.status, status)
+		}
+	}
+	if status, _ := get(t, server, "0123"); status != http.StatusNotFound {
+		t.Errorf("expected an unknown job, got %d", status)
+	}
+}
+
+func TestServerRetention(t *testing.T) {
+	prover := &blockingProver{started: make(chan struct{}), release: make(chan struct{})}
+	server := startServer(t, prover, config{workers: 1, queueSize: 1, maxWitnessSize: 1 << 20, retention: 0})
+	_, j := post(t, server, witness("1"))
+	<-prover.started
+	prover.release <- struct{}{}
+	for deadline := time.Now().Add(time.Minute); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if status, _ := get(t, server, j.ID); status == http.StatusNotFound {
+			return
+		}
+	}
+	t.Fatal("expected the finished job to be dropped")
+}
+
+func TestServerProve(t *testing.T) {
+	dataDir := filepath.Join(t.TempDir(), "dev")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for src, dst := range map[string]string{
+		"../../sp1/testdata/basic_constraints.json": sp1.CONSTRAINTS_JSON_FILE,
+		"../../sp1/testdata/basic_witness.json":     sp1.WITNESS_JSON_FILE,
+	} {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dataDir, dst), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sp1.BuildWithOptions(dataDir, sp1.BuildOptions{Logger: logging.Nop()})
+	prover, err := sp1.NewProver(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := startServer(t, prover, config{workers: 2, queueSize: 2, maxWitnessSize: 1 << 20, retention: time.Minute})
+	body, err := os.ReadFile(filepath.Join(dataDir, sp1.WITNESS_JSON_FILE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for i := 0; i < 2; i++ {
+		status, j := post(t, server, body)
+		if status != http.StatusAccepted {
+			t.Fatalf("unexpected status %d", status)
+		}
+		ids = append(ids, j.ID)
+	}
+	for _, id := range ids {
+		j := wait(t, server, id)
+		if j.Status != DONE {
+			t.Fatalf("job %s: %+v", id, j)
+		}
+		if err := sp1.Verify(dataDir, j.Proof.RawProof, j.Proof.PublicInputs[0], j.Proof.PublicInputs[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
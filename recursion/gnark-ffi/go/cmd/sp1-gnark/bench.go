@@ -0,0 +1,448 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"math/rand"
+	"os"
+	"runtime"
+	"runtime/metrics"
+	"strings"
+	"time"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/frontend/cs/scs"
+	"github.com/consensys/gnark/test/unsafekzg"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/poseidon2"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/verifier"
+)
+
+// The interval at which bench samples the heap to find its peak.
+var BENCH_SAMPLING_INTERVAL = 10 * time.Millisecond
+
+// The sub-circuits measured by bench, in the order they are reported. The verifier is the circuit
+// of the data directory, and is only measured if --data is given.
+var BENCH_CIRCUITS = []string{"mul_e", "poseidon2_babybear", "poseidon2_bn254", "fri_query", "verifier"}
+
+// The shape of the FRI query measured by bench: the folding rounds from a domain of 2^20 points.
+const (
+	BENCH_FRI_LOG_BLOWUP     = 1
+	BENCH_FRI_LOG_MAX_HEIGHT = 20
+)
+
+// BenchResult is the measure of a sub-circuit, written by bench as a line of JSON. The durations
+// are zero if the circuit was not proven. The peak heap is sampled while compiling and proving, and
+// includes the garbage not collected yet.
+type BenchResult struct {
+	Circuit        string `json:"circuit"`
+	System         string `json:"system"`
+	NbConstraints  int    `json:"nb_constraints"`
+	CompileTimeMs  int64  `json:"compile_time_ms"`
+	SetupTimeMs    int64  `json:"setup_time_ms"`
+	ProveTimeMs    int64  `json:"prove_time_ms"`
+	PeakHeapBytes  uint64 `json:"peak_heap_bytes"`
+	GoMaxProcs     int    `json:"gomaxprocs"`
+	ProvingSkipped bool   `json:"proving_skipped,omitempty"`
+}
+
+// benchCircuit is a sub-circuit and an assignment satisfying it.
+type benchCircuit struct {
+	circuit, assignment frontend.Circuit
+}
+
+func bench(system string, dataDir string, names string, prove bool, outputPath string) error {
+	if err := checkSystem(system); err != nil {
+		return err
+	}
+	selected := BENCH_CIRCUITS
+	if names != "" {
+		selected = strings.Split(names, ",")
+	}
+
+	out := io.Writer(os.Stdout)
+	if outputPath != "" {
+		file, err := os.Create(outputPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	encoder := json.NewEncoder(out)
+	for _, name := range selected {
+		if name == "verifier" && dataDir == "" && names == "" {
+			continue
+		}
+		c, err := newBenchCircuit(name, dataDir)
+		if err != nil {
+			return err
+		}
+		result, err := measure(system, c, prove)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		result.Circuit = name
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newBenchCircuit(name string, dataDir string) (benchCircuit, error) {
+	rng := rand.New(rand.NewSource(0))
+	switch name {
+	case "mul_e":
+		return newMulECircuit(rng), nil
+	case "poseidon2_babybear":
+		return newPoseidon2BabyBearCircuit(rng), nil
+	case "poseidon2_bn254":
+		return newPoseidon2Bn254Circuit(rng), nil
+	case "fri_query":
+		return newFriQueryCircuit(rng), nil
+	case "verifier":
+		if dataDir == "" {
+			return benchCircuit{}, fmt.Errorf("--data is required to bench the verifier")
+		}
+		os.Setenv("CONSTRAINTS_JSON", dataDir+"/"+sp1.CONSTRAINTS_JSON_FILE)
+		witnessInput, err := sp1.ReadWitnessInput(dataDir + "/" + sp1.WITNESS_JSON_FILE)
+		if err != nil {
+			return benchCircuit{}, err
+		}
+		circuit, assignment := sp1.NewCircuit(witnessInput), sp1.NewCircuit(witnessInput)
+		return benchCircuit{&circuit, &assignment}, nil
+	}
+	return benchCircuit{}, fmt.Errorf("unknown circuit %q, expected one of %s", name, strings.Join(BENCH_CIRCUITS, ", "))
+}
+
+// measure compiles the circuit for the proving system and, if prove is set, proves the assignment
+// with keys of an unsafe setup, which only serve timing.
+func measure(system string, c benchCircuit, prove bool) (BenchResult, error) {
+	runtime.GC()
+	stopSampling := samplePeakHeap()
+	result, err := measureCircuit(system, c, prove)
+	result.PeakHeapBytes = stopSampling()
+	return result, err
+}
+
+func measureCircuit(system string, c benchCircuit, prove bool) (BenchResult, error) {
+	result := BenchResult{System: system, GoMaxProcs: runtime.GOMAXPROCS(0), ProvingSkipped: !prove}
+	builder := scs.NewBuilder
+	if system == GROTH16_SYSTEM {
+		builder = r1cs.NewBuilder
+	}
+	start := time.Now()
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), builder, c.circuit)
+	if err != nil {
+		return BenchResult{}, fmt.Errorf("compile: %w", err)
+	}
+	result.CompileTimeMs = time.Since(start).Milliseconds()
+	result.NbConstraints = ccs.GetNbConstraints()
+	if !prove {
+		return result, nil
+	}
+
+	fullWitness, err := frontend.NewWitness(c.assignment, ecc.BN254.ScalarField())
+	if err != nil {
+		return BenchResult{}, fmt.Errorf("witness: %w", err)
+	}
+	start = time.Now()
+	proveWitness, err := setup(system, ccs)
+	if err != nil {
+		return BenchResult{}, fmt.Errorf("setup: %w", err)
+	}
+	result.SetupTimeMs = time.Since(start).Milliseconds()
+	start = time.Now()
+	if err := proveWitness(fullWitness); err != nil {
+		return BenchResult{}, fmt.Errorf("prove: %w", err)
+	}
+	result.ProveTimeMs = time.Since(start).Milliseconds()
+	return result, nil
+}
+
+// setup generates the keys of ccs and returns the function proving a witness with them.
+func setup(system string, ccs constraint.ConstraintSystem) (func(witness.Witness) error, error) {
+	if system == GROTH16_SYSTEM {
+		pk, _, err := groth16.Setup(ccs)
+		if err != nil {
+			return nil, err
+		}
+		return func(w witness.Witness) error {
+			_, err := groth16.Prove(ccs, pk, w)
+			return err
+		}, nil
+	}
+	srs, srsLagrange, err := unsafekzg.NewSRS(ccs)
+	if err != nil {
+		return nil, err
+	}
+	pk, _, err := plonk.Setup(ccs, srs, srsLagrange)
+	if err != nil {
+		return nil, err
+	}
+	return func(w witness.Witness) error {
+		_, err := plonk.Prove(ccs, pk, w)
+		return err
+	}, nil
+}
+
+// samplePeakHeap samples the heap in use every BENCH_SAMPLING_INTERVAL, until the returned
+// function is called, which returns the largest sample.
+func samplePeakHeap() func() uint64 {
+	samples := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	var peak uint64
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(BENCH_SAMPLING_INTERVAL)
+		defer ticker.Stop()
+		for {
+			metrics.Read(samples)
+			peak = max(peak, samples[0].Value.Uint64())
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() uint64 {
+		close(done)
+		<-stopped
+		return peak
+	}
+}
+
+// The sub-circuits, whose assignments are computed natively.
+
+func newExt(v [4]frontend.Variable) babybear.ExtensionVariable {
+	var out babybear.ExtensionVariable
+	for i := range v {
+		out.Value[i] = babybear.Variable{Value: v[i], NbBits: 31}
+	}
+	return out
+}
+
+func randomExt(rng *rand.Rand) [4]*big.Int {
+	var out [4]*big.Int
+	for i := range out {
+		out[i] = new(big.Int).Rand(rng, babybear.MODULUS)
+	}
+	return out
+}
+
+func assignExt(v [4]*big.Int) [4]frontend.Variable {
+	var out [4]frontend.Variable
+	for i := range v {
+		out[i] = v[i]
+	}
+	return out
+}
+
+// mulECircuit checks a single extension product.
+type mulECircuit struct {
+	A, B, Product [4]frontend.Variable
+}
+
+func (circuit *mulECircuit) Define(api frontend.API) error {
+	chip := babybear.NewChip(api)
+	chip.AssertIsEqualE(chip.MulE(newExt(circuit.A), newExt(circuit.B)), newExt(circuit.Product))
+	return nil
+}
+
+func newMulECircuit(rng *rand.Rand) benchCircuit {
+	a, b := randomExt(rng), randomExt(rng)
+	return benchCircuit{&mulECircuit{}, &mulECircuit{A: assignExt(a), B: assignExt(b), Product: assignExt(babybear.BabyBear.MulE(a, b))}}
+}
+
+// poseidon2BabyBearCircuit checks a Poseidon2 permutation over BabyBear.
+type poseidon2BabyBearCircuit struct {
+	Input, Output [poseidon2.BABYBEAR_WIDTH]frontend.Variable
+}
+
+func (circuit *poseidon2BabyBearCircuit) Define(api frontend.API) error {
+	chip := babybear.NewChip(api)
+	hasher := poseidon2.NewBabyBearChip(api)
+	var state [poseidon2.BABYBEAR_WIDTH]babybear.Variable
+	for i, v := range circuit.Input {
+		state[i] = babybear.Variable{Value: v, NbBits: 31}
+	}
+	hasher.PermuteMut(&state)
+	for i, v := range circuit.Output {
+		chip.AssertIsEqualF(state[i], babybear.Variable{Value: v, NbBits: 31})
+	}
+	return nil
+}
+
+func newPoseidon2BabyBearCircuit(rng *rand.Rand) benchCircuit {
+	var state [poseidon2.BABYBEAR_WIDTH]uint64
+	assignment := &poseidon2BabyBearCircuit{}
+	for i := range state {
+		state[i] = rng.Uint64() % babybear.MODULUS.Uint64()
+		assignment.Input[i] = state[i]
+	}
+	poseidon2.PermuteBabyBearNative(&state)
+	for i, v := range state {
+		assignment.Output[i] = v
+	}
+	return benchCircuit{&poseidon2BabyBearCircuit{}, assignment}
+}
+
+// poseidon2Bn254Circuit checks a Poseidon2 permutation over BN254.
+type poseidon2Bn254Circuit struct {
+	Input, Output [poseidon2.WIDTH]frontend.Variable
+}
+
+func (circuit *poseidon2Bn254Circuit) Define(api frontend.API) error {
+	state := circuit.Input
+	poseidon2.NewChip(api).PermuteMut(&state)
+	for i, v := range circuit.Output {
+		api.AssertIsEqual(state[i], v)
+	}
+	return nil
+}
+
+func newPoseidon2Bn254Circuit(rng *rand.Rand) benchCircuit {
+	var state [poseidon2.WIDTH]*big.Int
+	assignment := &poseidon2Bn254Circuit{}
+	for i := range state {
+		state[i] = new(big.Int).Rand(rng, ecc.BN254.ScalarField())
+		assignment.Input[i] = new(big.Int).Set(state[i])
+	}
+	poseidon2.PermuteNative(&state)
+	for i, v := range state {
+		assignment.Output[i] = v
+	}
+	return benchCircuit{&poseidon2Bn254Circuit{}, assignment}
+}
+
+// friQueryCircuit checks a FRI query through all its folding rounds, starting from a reduced
+// opening at the largest height. The challenges are inputs rather than sampled.
+type friQueryCircuit struct {
+	Commits   [BENCH_FRI_LOG_MAX_HEIGHT - BENCH_FRI_LOG_BLOWUP][verifier.DIGEST_SIZE]frontend.Variable
+	Siblings  [BENCH_FRI_LOG_MAX_HEIGHT - BENCH_FRI_LOG_BLOWUP][4]frontend.Variable
+	Paths     [BENCH_FRI_LOG_MAX_HEIGHT - BENCH_FRI_LOG_BLOWUP][][verifier.DIGEST_SIZE]frontend.Variable
+	Betas     [BENCH_FRI_LOG_MAX_HEIGHT - BENCH_FRI_LOG_BLOWUP][4]frontend.Variable
+	IndexBits [BENCH_FRI_LOG_MAX_HEIGHT]frontend.Variable
+	Reduced   [4]frontend.Variable
+	FinalPoly [4]frontend.Variable
+}
+
+func newFriQueryShape() *friQueryCircuit {
+	circuit := &friQueryCircuit{}
+	for s := range circuit.Paths {
+		circuit.Paths[s] = make([][verifier.DIGEST_SIZE]frontend.Variable, BENCH_FRI_LOG_MAX_HEIGHT-1-s)
+	}
+	return circuit
+}
+
+func (circuit *friQueryCircuit) Define(api frontend.API) error {
+	chip := babybear.NewChip(api)
+	hasher := poseidon2.NewBabyBearChip(api)
+	felt := func(v frontend.Variable) babybear.Variable { return babybear.Variable{Value: v, NbBits: 31} }
+	digest := func(d [verifier.DIGEST_SIZE]frontend.Variable) [verifier.DIGEST_SIZE]babybear.Variable {
+		var out [verifier.DIGEST_SIZE]babybear.Variable
+		for i, v := range d {
+			out[i] = felt(v)
+		}
+		return out
+	}
+
+	proof := &verifier.FriProof{FinalPoly: newExt(circuit.FinalPoly), QueryProofs: make([]verifier.FriQueryProof, 1)}
+	var challenges verifier.FriChallenges
+	for s := range circuit.Commits {
+		proof.CommitPhaseCommits = append(proof.CommitPhaseCommits, digest(circuit.Commits[s]))
+		step := verifier.FriCommitPhaseStep{SiblingValue: newExt(circuit.Siblings[s])}
+		for _, sibling := range circuit.Paths[s] {
+			step.OpeningProof = append(step.OpeningProof, digest(sibling))
+		}
+		proof.QueryProofs[0].CommitPhaseOpenings = append(proof.QueryProofs[0].CommitPhaseOpenings, step)
+		challenges.Betas = append(challenges.Betas, newExt(circuit.Betas[s]))
+	}
+	for _, bit := range circuit.IndexBits {
+		api.AssertIsBoolean(bit)
+	}
+	challenges.QueryIndices = [][]frontend.Variable{circuit.IndexBits[:]}
+	config := verifier.FriConfig{LogBlowup: BENCH_FRI_LOG_BLOWUP, NumQueries: 1}
+	reduced := []map[int]babybear.ExtensionVariable{{BENCH_FRI_LOG_MAX_HEIGHT: newExt(circuit.Reduced)}}
+	verifier.VerifyFriChallenges(chip, hasher, config, proof, challenges, reduced)
+	return nil
+}
+
+// newFriQueryCircuit folds a random reduced opening with random siblings and challenges, like the
+// verifier, and commits to each pair with a random Merkle path.
+func newFriQueryCircuit(rng *rand.Rand) benchCircuit {
+	field := babybear.BabyBear
+	p := field.Modulus
+	felt := func(v *big.Int) [4]*big.Int { return [4]*big.Int{v, new(big.Int), new(big.Int), new(big.Int)} }
+	sub := func(a, b [4]*big.Int) [4]*big.Int {
+		var out [4]*big.Int
+		for i := range out {
+			out[i] = new(big.Int).Mod(new(big.Int).Sub(a[i], b[i]), p)
+		}
+		return out
+	}
+	add := func(a, b [4]*big.Int) [4]*big.Int { return sub(a, sub(felt(new(big.Int)), b)) }
+
+	assignment := newFriQueryShape()
+	index := rng.Intn(1 << BENCH_FRI_LOG_MAX_HEIGHT)
+	reversed := 0
+	for i := 0; i < BENCH_FRI_LOG_MAX_HEIGHT; i++ {
+		assignment.IndexBits[i] = index >> i & 1
+		reversed |= (index >> i & 1) << (BENCH_FRI_LOG_MAX_HEIGHT - 1 - i)
+	}
+	x := new(big.Int).Exp(field.TwoAdicGenerator(BENCH_FRI_LOG_MAX_HEIGHT), big.NewInt(int64(reversed)), p)
+
+	folded := randomExt(rng)
+	assignment.Reduced = assignExt(folded)
+	for s := range assignment.Commits {
+		sibling, beta := randomExt(rng), randomExt(rng)
+		assignment.Siblings[s], assignment.Betas[s] = assignExt(sibling), assignExt(beta)
+		bit := index >> s & 1
+		evals := [2][4]*big.Int{folded, sibling}
+		xs := [2]*big.Int{x, new(big.Int).Sub(p, x)}
+		if bit == 1 {
+			evals[0], evals[1] = evals[1], evals[0]
+			xs[0], xs[1] = xs[1], xs[0]
+		}
+
+		var row []uint64
+		for _, e := range evals {
+			for _, v := range e {
+				row = append(row, v.Uint64())
+			}
+		}
+		node := poseidon2.HashBabyBearNative(row)
+		for i := range assignment.Paths[s] {
+			var sibling [verifier.DIGEST_SIZE]uint64
+			for j := range sibling {
+				sibling[j] = rng.Uint64() % p.Uint64()
+				assignment.Paths[s][i][j] = sibling[j]
+			}
+			if index>>(s+1+i)&1 == 0 {
+				node = poseidon2.CompressBabyBearNative(node, sibling)
+			} else {
+				node = poseidon2.CompressBabyBearNative(sibling, node)
+			}
+		}
+		for j, v := range node {
+			assignment.Commits[s][j] = v
+		}
+
+		slope := field.MulE(sub(evals[1], evals[0]), field.InvE(felt(new(big.Int).Mod(new(big.Int).Sub(xs[1], xs[0]), p))))
+		folded = add(evals[0], field.MulE(sub(beta, felt(xs[0])), slope))
+		x = new(big.Int).Mod(new(big.Int).Mul(x, x), p)
+	}
+	assignment.FinalPoly = assignExt(folded)
+	return benchCircuit{newFriQueryShape(), assignment}
+}
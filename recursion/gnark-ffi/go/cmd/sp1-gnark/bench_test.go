@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/test"
+)
+
+func TestBenchCircuits(t *testing.T) {
+	// The assignments computed natively satisfy the sub-circuits, so that they can be proven.
+	for _, name := range BENCH_CIRCUITS[:len(BENCH_CIRCUITS)-1] {
+		c, err := newBenchCircuit(name, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := test.IsSolved(c.circuit, c.assignment, ecc.BN254.ScalarField()); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+
+	c, err := newBenchCircuit("fri_query", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.assignment.(*friQueryCircuit).Siblings[3][0] = 1
+	if err := test.IsSolved(c.circuit, c.assignment, ecc.BN254.ScalarField()); err == nil {
+		t.Fatal("expected a wrong sibling to be rejected")
+	}
+
+	if _, err := newBenchCircuit("verifier", ""); err == nil {
+		t.Fatal("expected the verifier to require a data directory")
+	}
+}
+
+func TestBench(t *testing.T) {
+	dataDir := t.TempDir()
+	for src, dst := range map[string]string{"basic_constraints.json": "constraints.json", "basic_witness.json": "witness.json"} {
+		data, err := os.ReadFile(filepath.Join("../../sp1/testdata", src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dataDir, dst), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	outputPath := filepath.Join(t.TempDir(), "bench.jsonl")
+	if err := bench(PLONK_SYSTEM, dataDir, "mul_e,verifier", true, outputPath); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var results []BenchResult
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var result BenchResult
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, result)
+	}
+	if len(results) != 2 || results[0].Circuit != "mul_e" || results[1].Circuit != "verifier" {
+		t.Fatalf("expected the results of mul_e and verifier, got %+v", results)
+	}
+	for _, result := range results {
+		if result.NbConstraints == 0 || result.PeakHeapBytes == 0 || result.ProvingSkipped {
+			t.Errorf("%s: incomplete result %+v", result.Circuit, result)
+		}
+	}
+
+	if err := bench(PLONK_SYSTEM, "", "mul_e,unknown", false, outputPath); err == nil {
+		t.Fatal("expected an unknown circuit to be refused")
+	}
+}
@@ -0,0 +1,176 @@
+// Command sp1-gnark builds the wrapper circuit of a data directory, proves witnesses with it and
+// verifies the proofs.
+//
+//	sp1-gnark build --data build/
+//	sp1-gnark prove --data build/ --witness witness.json --output proof.json
+//	sp1-gnark verify --data build/ --proof proof.json
+//	sp1-gnark check --data build/ [--witness witness.json]
+//	sp1-gnark export-solidity --data build/ --output contracts/
+//	sp1-gnark bench [--data build/] [--circuits mul_e,fri_query] [--prove=false]
+//
+// The data directory holds the constraints.json and the witness.json the circuit is built from,
+// and receives the artifacts of the build. The proof is written as JSON, to the standard output if
+// --output is not given.
+//
+// The circuit is proven with Groth16, or with PLONK and the KZG setup of the data directory given
+// --system plonk. Both systems can be built in the same data directory, and export-solidity writes
+// the verifier contracts of all the systems built in it.
+//
+// check runs the circuit of the data directory on a witness, its witness.json by default, with
+// gnark's test engine, without any key, and reports the first instruction the witness violates.
+//
+// bench compiles representative sub-circuits and the verifier circuit of the data directory, if
+// given, proves them with keys of an unsafe setup, and writes a line of JSON per circuit with its
+// constraint count, compile, setup and proving times, and peak heap, to compare releases.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1"
+)
+
+const usage = "usage: sp1-gnark <build|prove|verify|check|export-solidity|bench> --data <data dir> [--system groth16|plonk] [flags]"
+
+// The proving systems of the --system flag.
+const (
+	GROTH16_SYSTEM = string(sp1.GROTH16_SYSTEM)
+	PLONK_SYSTEM   = string(sp1.PLONK_SYSTEM)
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	flags := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	dataDir := flags.String("data", "", "the data directory of the circuit")
+	system := flags.String("system", GROTH16_SYSTEM, "the proving system, groth16 or plonk")
+	parallelism := flags.Int("parallelism", 0, "the number of goroutines parsing the constraints, converting the witness and solving the circuit, GOMAXPROCS by default")
+	var run func() error
+	switch os.Args[1] {
+	case "build":
+		srsPath := flags.String("srs", "", "a canonical PLONK SRS file used instead of downloading the Aztec Ignition SRS")
+		srsCacheDir := flags.String("srs-cache", "", "the directory caching the PLONK SRS trimmed to the circuit size")
+		run = func() error {
+			return build(*system, *dataDir, sp1.BuildOptions{SRSPath: *srsPath, SRSCacheDir: *srsCacheDir})
+		}
+	case "prove":
+		witnessPath := flags.String("witness", "", "the witness file to prove")
+		outputPath := flags.String("output", "", "the file the proof is written to")
+		run = func() error { return prove(*system, *dataDir, *witnessPath, *outputPath) }
+	case "verify":
+		proofPath := flags.String("proof", "", "the proof file written by prove")
+		run = func() error { return verify(*system, *dataDir, *proofPath) }
+	case "check":
+		witnessPath := flags.String("witness", "", "the witness file to check, the witness.json of the data directory by default")
+		run = func() error { return check(*dataDir, *witnessPath) }
+	case "export-solidity":
+		outDir := flags.String("output", "", "the directory the contracts are written to")
+		run = func() error { return exportSolidity(*dataDir, *outDir) }
+	case "bench":
+		circuits := flags.String("circuits", "", "the comma-separated circuits to bench, all those available by default")
+		proveCircuits := flags.Bool("prove", true, "whether to set up and prove the circuits, or only compile them")
+		outputPath := flags.String("output", "", "the file the results are written to")
+		run = func() error { return bench(*system, *dataDir, *circuits, *proveCircuits, *outputPath) }
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	flags.Parse(os.Args[2:])
+	sp1.PARALLELISM = *parallelism
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func checkSystem(system string) error {
+	if system != GROTH16_SYSTEM && system != PLONK_SYSTEM {
+		return fmt.Errorf("unknown proving system %q", system)
+	}
+	return nil
+}
+
+func build(system string, dataDir string, opts sp1.BuildOptions) error {
+	if err := checkSystem(system); err != nil {
+		return err
+	}
+	if dataDir == "" {
+		return fmt.Errorf("--data is required")
+	}
+	opts.System = sp1.ProvingSystem(system)
+	_, err := sp1.BuildContext(context.Background(), dataDir, opts)
+	return err
+}
+
+func prove(system string, dataDir string, witnessPath string, outputPath string) error {
+	if err := checkSystem(system); err != nil {
+		return err
+	}
+	if dataDir == "" || witnessPath == "" {
+		return fmt.Errorf("--data and --witness are required")
+	}
+	proof, err := sp1.ProveContext(context.Background(), dataDir, witnessPath, sp1.ProveOptions{System: sp1.ProvingSystem(system)})
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(proof, "", "  ")
+	if err != nil {
+		return err
+	}
+	if outputPath == "" {
+		_, err = fmt.Println(string(data))
+		return err
+	}
+	return os.WriteFile(outputPath, data, 0644)
+}
+
+func verify(system string, dataDir string, proofPath string) error {
+	if err := checkSystem(system); err != nil {
+		return err
+	}
+	if dataDir == "" || proofPath == "" {
+		return fmt.Errorf("--data and --proof are required")
+	}
+	data, err := os.ReadFile(proofPath)
+	if err != nil {
+		return err
+	}
+	var proof sp1.Proof
+	if err := json.Unmarshal(data, &proof); err != nil {
+		return fmt.Errorf("%s: %w", proofPath, err)
+	}
+	vkeyHash, commitedValuesDigest := proof.PublicInputs[0], proof.PublicInputs[1]
+	opts := sp1.VerifyOptions{System: sp1.ProvingSystem(system)}
+	if err := sp1.VerifyContext(context.Background(), dataDir, proof.RawProof, vkeyHash, commitedValuesDigest, opts); err != nil {
+		return err
+	}
+	fmt.Println("verified proof")
+	return nil
+}
+
+func check(dataDir string, witnessPath string) error {
+	if dataDir == "" {
+		return fmt.Errorf("--data is required")
+	}
+	if witnessPath == "" {
+		witnessPath = dataDir + "/" + sp1.WITNESS_JSON_FILE
+	}
+	if err := sp1.CheckWitness(dataDir+"/"+sp1.CONSTRAINTS_JSON_FILE, witnessPath); err != nil {
+		return err
+	}
+	fmt.Println("the witness satisfies the circuit")
+	return nil
+}
+
+func exportSolidity(dataDir string, outDir string) error {
+	if dataDir == "" || outDir == "" {
+		return fmt.Errorf("--data and --output are required")
+	}
+	return sp1.ExportSolidity(dataDir, outDir)
+}
@@ -1,19 +1,20 @@
 package main
 
 /*
+#include <stdlib.h>
 #include "./babybear.h"
 
 typedef struct {
 	char *PublicInputs[2];
 	char *EncodedProof;
 	char *RawProof;
+	char *Error;
 } C_PlonkBn254Proof;
 */
 import "C"
 import (
-	"encoding/json"
-	"fmt"
 	"os"
+	"strconv"
 	"sync"
 
 	"github.com/consensys/gnark-crypto/ecc"
@@ -25,24 +26,45 @@ import (
 	"github.com/consensys/gnark/test/unsafekzg"
 	"github.com/succinctlabs/sp1-recursion-gnark/sp1"
 	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/logging"
 	"github.com/succinctlabs/sp1-recursion-gnark/sp1/poseidon2"
 )
 
 func main() {}
 
+// PARALLELISM sets sp1.PARALLELISM for all the calls.
+func init() {
+	if n, err := strconv.Atoi(os.Getenv("PARALLELISM")); err == nil {
+		sp1.PARALLELISM = n
+	}
+}
+
+// ProvePlonkBn254 returns the proof, or only an Error if proving failed.
+//
 //export ProvePlonkBn254
 func ProvePlonkBn254(dataDir *C.char, witnessPath *C.char) *C.C_PlonkBn254Proof {
 	dataDirString := C.GoString(dataDir)
 	witnessPathString := C.GoString(witnessPath)
 
-	sp1PlonkBn254Proof := sp1.Prove(dataDirString, witnessPathString)
+	opts := sp1.ProveOptions{
+		SelfTest: os.Getenv("SELF_TEST_ARTIFACTS") == "true",
+		Check:    os.Getenv("CHECK_WITNESS") == "true",
+		Profile:  os.Getenv("PROFILE_SOLVER") == "true",
+		Progress: setProveProgress,
+	}
+	defer clearProveProgress()
+	sp1PlonkBn254Proof, err := sp1.ProveWithOptions(dataDirString, witnessPathString, opts)
 
-	ms := C.malloc(C.sizeof_C_PlonkBn254Proof)
+	ms := C.calloc(1, C.sizeof_C_PlonkBn254Proof)
 	if ms == nil {
 		return nil
 	}
 
 	structPtr := (*C.C_PlonkBn254Proof)(ms)
+	if err != nil {
+		structPtr.Error = C.CString(err.Error())
+		return structPtr
+	}
 	structPtr.PublicInputs[0] = C.CString(sp1PlonkBn254Proof.PublicInputs[0])
 	structPtr.PublicInputs[1] = C.CString(sp1PlonkBn254Proof.PublicInputs[1])
 	structPtr.EncodedProof = C.CString(sp1PlonkBn254Proof.EncodedProof)
@@ -50,12 +72,60 @@ func ProvePlonkBn254(dataDir *C.char, witnessPath *C.char) *C.C_PlonkBn254Proof
 	return structPtr
 }
 
+// The progress of the running ProvePlonkBn254 call, polled with ProgressPlonkBn254.
+var proveProgress struct {
+	sync.Mutex
+	stage       string
+	done, total int
+}
+
+func setProveProgress(stage string, done, total int) {
+	proveProgress.Lock()
+	defer proveProgress.Unlock()
+	proveProgress.stage, proveProgress.done, proveProgress.total = stage, done, total
+}
+
+func clearProveProgress() {
+	setProveProgress("", 0, 0)
+}
+
+// ProgressPlonkBn254 returns the stage of the running ProvePlonkBn254 call and stores its progress
+// in done and total, or returns nil when no proof is being generated.
+//
+//export ProgressPlonkBn254
+func ProgressPlonkBn254(done *C.int, total *C.int) *C.char {
+	proveProgress.Lock()
+	defer proveProgress.Unlock()
+	if proveProgress.stage == "" {
+		return nil
+	}
+	*done = C.int(proveProgress.done)
+	*total = C.int(proveProgress.total)
+	return C.CString(proveProgress.stage)
+}
+
+// SetLogLevelPlonkBn254 sets the minimum level of the logs of the FFI calls, one of debug, info,
+// warn and error, and returns an error message if the level is invalid.
+//
+//export SetLogLevelPlonkBn254
+func SetLogLevelPlonkBn254(level *C.char) *C.char {
+	l, err := logging.ParseLevel(C.GoString(level))
+	if err != nil {
+		return C.CString(err.Error())
+	}
+	logging.SetLevel(l)
+	return nil
+}
+
 //export BuildPlonkBn254
 func BuildPlonkBn254(dataDir *C.char) {
 	// Sanity check the required arguments have been provided.
 	dataDirString := C.GoString(dataDir)
 
-	sp1.Build(dataDirString)
+	sp1.BuildWithOptions(dataDirString, sp1.BuildOptions{
+		SRSPath:     os.Getenv("SRS_PATH"),
+		SRSCacheDir: os.Getenv("SRS_CACHE_DIR"),
+	})
 }
 
 //export VerifyPlonkBn254
@@ -90,6 +160,20 @@ func TestPlonkBn254(witnessPath *C.char, constraintsJson *C.char) *C.char {
 	return nil
 }
 
+//export CheckPlonkBn254
+func CheckPlonkBn254(witnessPath *C.char, constraintsJson *C.char) *C.char {
+	// Because of the global env variables used here, we need to lock this function
+	testMutex.Lock()
+	witnessPathString := C.GoString(witnessPath)
+	constraintsJsonString := C.GoString(constraintsJson)
+	err := sp1.CheckWitness(constraintsJsonString, witnessPathString)
+	testMutex.Unlock()
+	if err != nil {
+		return C.CString(err.Error())
+	}
+	return nil
+}
+
 func TestMain() error {
 	// Get the file name from an environment variable.
 	fileName := os.Getenv("WITNESS_JSON")
@@ -98,14 +182,7 @@ func TestMain() error {
 	}
 
 	// Read the file.
-	data, err := os.ReadFile(fileName)
-	if err != nil {
-		return err
-	}
-
-	// Deserialize the JSON data into a slice of Instruction structs
-	var inputs sp1.WitnessInput
-	err = json.Unmarshal(data, &inputs)
+	inputs, err := sp1.ReadWitnessInput(fileName)
 	if err != nil {
 		return err
 	}
@@ -117,7 +194,7 @@ func TestMain() error {
 	if err != nil {
 		return err
 	}
-	fmt.Println("[sp1] gnark verifier constraints:", scs.GetNbConstraints())
+	logging.Default().Info("compiled gnark verifier", "constraints", scs.GetNbConstraints())
 
 	// Run the dummy setup.
 	srs, srsLagrange, err := unsafekzg.NewSRS(scs)
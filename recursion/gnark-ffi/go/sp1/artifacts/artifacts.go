@@ -0,0 +1,256 @@
+// Package artifacts checks that the files of a built data directory belong together, so that a
+// proving key, verifying key or SRS from another build is caught before a long proof fails.
+package artifacts
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	kzg_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/kzg"
+	"github.com/consensys/gnark-crypto/kzg"
+	"github.com/consensys/gnark/backend/plonk"
+	plonk_bn254 "github.com/consensys/gnark/backend/plonk/bn254"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/scs"
+)
+
+// The names of the files of a data directory written by the build.
+const (
+	SRS_FILE               = "srs.bin"
+	SRS_LAGRANGE_FILE      = "srs_lagrange.bin"
+	VERIFIER_CONTRACT_FILE = "PlonkVerifier.sol"
+	CIRCUIT_FILE           = "circuit.bin"
+	VK_FILE                = "vk.bin"
+	PK_FILE                = "pk.bin"
+	MANIFEST_FILE          = "report.json"
+)
+
+// Error reports the artifact of a data directory that is inconsistent with the others.
+type Error struct {
+	Artifact string
+	Err      error
+}
+
+func (e *Error) Error() string {
+	return e.Artifact + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func inconsistent(artifact string, format string, args ...any) *Error {
+	return &Error{Artifact: artifact, Err: fmt.Errorf(format, args...)}
+}
+
+// Manifest is the part of the build report of a data directory recording its artifacts.
+type Manifest struct {
+	CircuitDigest   string            `json:"circuit_digest"`
+	ArtifactDigests map[string]string `json:"artifact_digests"`
+}
+
+// ReadManifest reads the manifest of the data directory dir.
+func ReadManifest(dir string) (Manifest, error) {
+	var manifest Manifest
+	data, err := os.ReadFile(filepath.Join(dir, MANIFEST_FILE))
+	if err != nil {
+		return manifest, err
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, err
+	}
+	return manifest, nil
+}
+
+// SelfTest checks that the artifacts of the data directory dir belong to the same build:
+//   - the constraint system, keys and verifier contract have the digests of the manifest,
+//   - the verifying key is the one embedded in the proving key, and has the size and public
+//     inputs of the constraint system,
+//   - the keys were set up with the SRS and its Lagrange form,
+//   - a proof of a small circuit set up with the SRS verifies.
+//
+// The error is an *Error naming the first inconsistent artifact.
+func SelfTest(dir string) error {
+	manifest, err := ReadManifest(dir)
+	if err != nil {
+		return &Error{Artifact: MANIFEST_FILE, Err: err}
+	}
+	// A manifest that none of the artifacts match is the stale one.
+	names := []string{CIRCUIT_FILE, PK_FILE, VK_FILE, VERIFIER_CONTRACT_FILE}
+	var mismatches []*Error
+	for _, name := range names {
+		expected, ok := manifest.ArtifactDigests[name]
+		if !ok {
+			return inconsistent(MANIFEST_FILE, "no digest of %s", name)
+		}
+		digest, err := fileDigest(filepath.Join(dir, name))
+		if err != nil {
+			return &Error{Artifact: name, Err: err}
+		}
+		if digest != expected {
+			mismatches = append(mismatches, inconsistent(name, "digest %s does not match the manifest digest %s", digest, expected))
+		}
+	}
+	if len(mismatches) == len(names) {
+		return inconsistent(MANIFEST_FILE, "none of the artifacts match its digests")
+	} else if len(mismatches) > 0 {
+		return mismatches[0]
+	}
+
+	// The digests match the manifest, so the files below are the ones of the build.
+	cs := plonk.NewCS(ecc.BN254)
+	if err := readFrom(filepath.Join(dir, CIRCUIT_FILE), cs); err != nil {
+		return &Error{Artifact: CIRCUIT_FILE, Err: err}
+	}
+	vk := &plonk_bn254.VerifyingKey{}
+	if err := readFrom(filepath.Join(dir, VK_FILE), vk); err != nil {
+		return &Error{Artifact: VK_FILE, Err: err}
+	}
+	pk := &plonk_bn254.ProvingKey{}
+	if err := readFrom(filepath.Join(dir, PK_FILE), pk); err != nil {
+		return &Error{Artifact: PK_FILE, Err: err}
+	}
+	if err := checkKeys(cs, pk, vk); err != nil {
+		return err
+	}
+
+	srs := kzg.NewSRS(ecc.BN254)
+	if err := readFrom(filepath.Join(dir, SRS_FILE), srs); err != nil {
+		return &Error{Artifact: SRS_FILE, Err: err}
+	}
+	srsLagrange := kzg.NewSRS(ecc.BN254)
+	if err := readFrom(filepath.Join(dir, SRS_LAGRANGE_FILE), srsLagrange); err != nil {
+		return &Error{Artifact: SRS_LAGRANGE_FILE, Err: err}
+	}
+	if err := checkSRS(pk, vk, srs.(*kzg_bn254.SRS), srsLagrange.(*kzg_bn254.SRS)); err != nil {
+		return err
+	}
+
+	if err := smokeTest(srs.(*kzg_bn254.SRS)); err != nil {
+		return &Error{Artifact: SRS_FILE, Err: fmt.Errorf("smoke proof: %w", err)}
+	}
+	return nil
+}
+
+// checkKeys checks the keys against each other and against the constraint system.
+func checkKeys(cs constraint.ConstraintSystem, pk *plonk_bn254.ProvingKey, vk *plonk_bn254.VerifyingKey) error {
+	var embedded, standalone bytes.Buffer
+	if _, err := pk.Vk.WriteRawTo(&embedded); err != nil {
+		return &Error{Artifact: PK_FILE, Err: err}
+	}
+	if _, err := vk.WriteRawTo(&standalone); err != nil {
+		return &Error{Artifact: VK_FILE, Err: err}
+	}
+	if !bytes.Equal(embedded.Bytes(), standalone.Bytes()) {
+		return inconsistent(PK_FILE, "the embedded verifying key is not %s", VK_FILE)
+	}
+
+	nbPublic := cs.GetNbPublicVariables()
+	if vk.NbPublicVariables != uint64(nbPublic) {
+		return inconsistent(VK_FILE, "%d public inputs, but the circuit has %d", vk.NbPublicVariables, nbPublic)
+	}
+	if size := ecc.NextPowerOfTwo(uint64(cs.GetNbConstraints() + nbPublic)); vk.Size != size {
+		return inconsistent(VK_FILE, "domain of size %d, but the circuit needs %d", vk.Size, size)
+	}
+	return nil
+}
+
+// checkSRS checks that the keys were set up with srs and srsLagrange.
+func checkSRS(pk *plonk_bn254.ProvingKey, vk *plonk_bn254.VerifyingKey, srs *kzg_bn254.SRS, srsLagrange *kzg_bn254.SRS) error {
+	if len(srs.Pk.G1) < len(pk.Kzg.G1) || !srs.Vk.G2[0].Equal(&vk.Kzg.G2[0]) || !srs.Vk.G2[1].Equal(&vk.Kzg.G2[1]) || !srs.Vk.G1.Equal(&vk.Kzg.G1) {
+		return inconsistent(SRS_FILE, "not the SRS %s was set up with", VK_FILE)
+	}
+	for i := range pk.Kzg.G1 {
+		if !srs.Pk.G1[i].Equal(&pk.Kzg.G1[i]) {
+			return inconsistent(SRS_FILE, "point %d is not the one of %s", i, PK_FILE)
+		}
+	}
+	if len(srsLagrange.Pk.G1) != len(pk.KzgLagrange.G1) {
+		return inconsistent(SRS_LAGRANGE_FILE, "%d points, but %s has %d", len(srsLagrange.Pk.G1), PK_FILE, len(pk.KzgLagrange.G1))
+	}
+	for i := range pk.KzgLagrange.G1 {
+		if !srsLagrange.Pk.G1[i].Equal(&pk.KzgLagrange.G1[i]) {
+			return inconsistent(SRS_LAGRANGE_FILE, "point %d is not the one of %s", i, PK_FILE)
+		}
+	}
+	return nil
+}
+
+// smokeCircuit proves the knowledge of a cube root of Y.
+type smokeCircuit struct {
+	X frontend.Variable
+	Y frontend.Variable `gnark:",public"`
+}
+
+func (circuit *smokeCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(api.Mul(circuit.X, circuit.X, circuit.X), circuit.Y)
+	return nil
+}
+
+// smokeTest proves and verifies the smoke circuit with a setup from srs, which fails if its G1 and
+// G2 points are not powers of the same secret.
+func smokeTest(srs *kzg_bn254.SRS) error {
+	cs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &smokeCircuit{})
+	if err != nil {
+		return err
+	}
+	size := ecc.NextPowerOfTwo(uint64(cs.GetNbConstraints() + cs.GetNbPublicVariables()))
+	if uint64(len(srs.Pk.G1)) < size {
+		return fmt.Errorf("%d points, but the smoke circuit needs %d", len(srs.Pk.G1), size)
+	}
+	srsLagrange := &kzg_bn254.SRS{Vk: srs.Vk}
+	if srsLagrange.Pk.G1, err = kzg_bn254.ToLagrangeG1(srs.Pk.G1[:size]); err != nil {
+		return err
+	}
+	pk, vk, err := plonk.Setup(cs, srs, srsLagrange)
+	if err != nil {
+		return err
+	}
+	witness, err := frontend.NewWitness(&smokeCircuit{X: 3, Y: 27}, ecc.BN254.ScalarField())
+	if err != nil {
+		return err
+	}
+	proof, err := plonk.Prove(cs, pk, witness)
+	if err != nil {
+		return err
+	}
+	publicWitness, err := witness.Public()
+	if err != nil {
+		return err
+	}
+	return plonk.Verify(proof, vk, publicWitness)
+}
+
+func readFrom(path string, r io.ReaderFrom) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := r.ReadFrom(bufio.NewReaderSize(file, 1024*1024)); err != nil {
+		return fmt.Errorf("failed to read: %w", err)
+	}
+	return nil
+}
+
+func fileDigest(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
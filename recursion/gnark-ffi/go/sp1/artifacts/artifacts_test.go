@@ -0,0 +1,143 @@
+package artifacts_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	kzg_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/kzg"
+	"github.com/consensys/gnark-crypto/kzg"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/artifacts"
+)
+
+// build builds the constraints fixture in a fresh dev data directory.
+func build(t *testing.T, constraints string) string {
+	dir := filepath.Join(t.TempDir(), "dev")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for src, dst := range map[string]string{constraints: sp1.CONSTRAINTS_JSON_FILE, "basic_witness.json": sp1.WITNESS_JSON_FILE} {
+		data, err := os.ReadFile(filepath.Join("../testdata", src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, dst), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sp1.Build(dir)
+	return dir
+}
+
+// copyDir returns a copy of the data directory dir with the artifact name taken from other.
+func copyDir(t *testing.T, dir string, other string, name string) string {
+	out := t.TempDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		src := filepath.Join(dir, entry.Name())
+		if entry.Name() == name {
+			src = filepath.Join(other, name)
+		}
+		data, err := os.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(out, entry.Name()), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return out
+}
+
+// writeFreshSRS writes to out an SRS with a fresh secret of the size of the SRS of dir, and its
+// Lagrange form.
+func writeFreshSRS(t *testing.T, dir string, out string) {
+	srs := kzg.NewSRS(ecc.BN254)
+	srsLagrange := kzg.NewSRS(ecc.BN254)
+	for path, r := range map[string]io.ReaderFrom{artifacts.SRS_FILE: srs, artifacts.SRS_LAGRANGE_FILE: srsLagrange} {
+		data, err := os.ReadFile(filepath.Join(dir, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.ReadFrom(bytes.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fresh, err := kzg_bn254.NewSRS(uint64(len(srs.(*kzg_bn254.SRS).Pk.G1)), big.NewInt(42))
+	if err != nil {
+		t.Fatal(err)
+	}
+	freshLagrange := &kzg_bn254.SRS{Vk: fresh.Vk}
+	size := len(srsLagrange.(*kzg_bn254.SRS).Pk.G1)
+	if freshLagrange.Pk.G1, err = kzg_bn254.ToLagrangeG1(fresh.Pk.G1[:size]); err != nil {
+		t.Fatal(err)
+	}
+	for name, w := range map[string]io.WriterTo{artifacts.SRS_FILE: fresh, artifacts.SRS_LAGRANGE_FILE: freshLagrange} {
+		var buf bytes.Buffer
+		if _, err := w.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(out, name), buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSelfTest(t *testing.T) {
+	dir := build(t, "basic_constraints.json")
+	if err := artifacts.SelfTest(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// A build of another circuit differs in everything, and a fresh SRS of the same size has
+	// another secret.
+	other := build(t, "hints_constraints.json")
+	fresh := t.TempDir()
+	writeFreshSRS(t, dir, fresh)
+	for _, tc := range []struct {
+		from     string
+		artifact string
+	}{
+		{other, artifacts.CIRCUIT_FILE},
+		{other, artifacts.PK_FILE},
+		{other, artifacts.VK_FILE},
+		{other, artifacts.VERIFIER_CONTRACT_FILE},
+		{other, artifacts.MANIFEST_FILE},
+		{other, artifacts.SRS_LAGRANGE_FILE},
+		{fresh, artifacts.SRS_FILE},
+		{fresh, artifacts.SRS_LAGRANGE_FILE},
+	} {
+		err := artifacts.SelfTest(copyDir(t, dir, tc.from, tc.artifact))
+		var inconsistent *artifacts.Error
+		if !errors.As(err, &inconsistent) || inconsistent.Artifact != tc.artifact {
+			t.Errorf("%s: expected the artifact to be reported, got %v", tc.artifact, err)
+		}
+	}
+
+	// A truncated SRS cannot be read.
+	mixed := copyDir(t, dir, dir, "")
+	if err := os.Truncate(filepath.Join(mixed, artifacts.SRS_FILE), 100); err != nil {
+		t.Fatal(err)
+	}
+	var inconsistent *artifacts.Error
+	if err := artifacts.SelfTest(mixed); !errors.As(err, &inconsistent) || inconsistent.Artifact != artifacts.SRS_FILE {
+		t.Errorf("expected the truncated SRS to be reported, got %v", err)
+	}
+
+	// Proving checks the artifacts first when asked to.
+	mixed = copyDir(t, dir, other, artifacts.PK_FILE)
+	_, err := sp1.ProveContext(context.Background(), mixed, filepath.Join(dir, sp1.WITNESS_JSON_FILE), sp1.ProveOptions{SelfTest: true})
+	if !errors.As(err, &inconsistent) || inconsistent.Artifact != artifacts.PK_FILE {
+		t.Errorf("expected the proof to report the proving key, got %v", err)
+	}
+}
@@ -6,20 +6,34 @@ package babybear
 import "C"
 
 import (
+	"fmt"
 	"math/big"
+	"math/bits"
 
 	"github.com/consensys/gnark/constraint/solver"
 	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/lookup/logderivlookup"
 	"github.com/consensys/gnark/std/rangecheck"
 )
 
-var MODULUS = new(big.Int).SetUint64(2013265921)
-var W = new(big.Int).SetUint64(11)
+// The modulus of BabyBear and the constant of its extension, the default field of a chip.
+var MODULUS = BabyBear.Modulus
+var W = BabyBear.W
+
+var invEHint = NewExtHint("InvE", InvEHint)
+var divEHint = NewExtHint("DivE", DivEHint)
 
 func init() {
 	solver.RegisterHint(InvFHint)
-	solver.RegisterHint(InvEHint)
+	solver.RegisterHint(DivFHint)
+	solver.RegisterHint(ExtHintDispatcher)
 	solver.RegisterHint(ReduceHint)
+	solver.RegisterHint(ToBytesHint)
+	solver.RegisterHint(IsLessThanHint)
+	solver.RegisterHint(FieldInvFHint)
+	solver.RegisterHint(FieldDivFHint)
+	solver.RegisterHint(FieldExtHintDispatcher)
+	solver.RegisterHint(FieldReduceHint)
 }
 
 type Variable struct {
@@ -32,15 +46,73 @@ type ExtensionVariable struct {
 }
 
 type Chip struct {
-	api          frontend.API
-	rangeChecker frontend.Rangechecker
+	api            frontend.API
+	field          *Field
+	rangeChecker   frontend.Rangechecker
+	towerExtension bool
+	muxLookups     bool
+	reduceBits     uint
+	trace          *Trace
+	tables         map[tableKey]*logderivlookup.Table
+	zero, one      ExtensionVariable
+}
+
+// ChipOption configures optional behavior of a Chip.
+type ChipOption func(*Chip)
+
+// WithBitDecomposition makes the chip range check values through explicit bit decompositions
+// instead of gnark's range checker, which uses a commitment when the builder supports one.
+func WithBitDecomposition() ChipOption {
+	return func(c *Chip) {
+		c.rangeChecker = bitDecompositionChecker{api: c.api}
+	}
 }
 
-func NewChip(api frontend.API) *Chip {
-	return &Chip{
+// WithField makes the chip compute over field instead of BabyBear.
+func WithField(field *Field) ChipOption {
+	return func(c *Chip) {
+		c.field = field
+	}
+}
+
+func NewChip(api frontend.API, opts ...ChipOption) *Chip {
+	c := &Chip{
 		api:          api,
+		field:        BabyBear,
+		reduceBits:   defaultReduceBits,
 		rangeChecker: rangecheck.New(api),
+		tables:       make(map[tableKey]*logderivlookup.Table),
+		zero:         ZeroE(),
+		one:          OneE(),
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
+}
+
+// Field returns the field the chip computes over.
+func (c *Chip) Field() *Field {
+	return c.field
+}
+
+// Zero returns the zero of the extension field, built once per chip.
+func (c *Chip) Zero() ExtensionVariable {
+	return c.zero
+}
+
+// One returns the one of the extension field, built once per chip.
+func (c *Chip) One() ExtensionVariable {
+	return c.one
+}
+
+// bitDecompositionChecker range checks a value by decomposing it into bits.
+type bitDecompositionChecker struct {
+	api frontend.API
+}
+
+func (b bitDecompositionChecker) Check(v frontend.Variable, nbBits int) {
+	b.api.ToBinary(v, nbBits)
 }
 
 func NewF(value string) Variable {
@@ -58,11 +130,63 @@ func NewE(value []string) ExtensionVariable {
 	return ExtensionVariable{Value: [4]Variable{a, b, c, d}}
 }
 
+// ZeroE returns the zero of the extension field.
+func ZeroE() ExtensionVariable {
+	return NewE([]string{"0", "0", "0", "0"})
+}
+
+// OneE returns the one of the extension field.
+func OneE() ExtensionVariable {
+	return NewE([]string{"1", "0", "0", "0"})
+}
+
+// NewFChecked is NewF for untrusted input: the value must be a canonical BabyBear element written
+// in decimal without a sign or leading zeros, which gnark would otherwise read as octal.
+func NewFChecked(value string) (Variable, error) {
+	return BabyBear.NewFChecked(value)
+}
+
+// NewEChecked is NewE for untrusted input: the value must have 4 coordinates accepted by
+// NewFChecked.
+func NewEChecked(value []string) (ExtensionVariable, error) {
+	return BabyBear.NewEChecked(value)
+}
+
 func Felts2Ext(a, b, c, d Variable) ExtensionVariable {
 	return ExtensionVariable{Value: [4]Variable{a, b, c, d}}
 }
 
+// NewEFromVars returns the extension element of the coordinates, the lowest degree one first, such
+// as the felts sampled by a challenger. It panics if a coordinate is nil.
+func NewEFromVars(coordinates [4]*Variable) ExtensionVariable {
+	var out ExtensionVariable
+	for i, coordinate := range coordinates {
+		if coordinate == nil {
+			panic(fmt.Sprintf("extension coordinate %d is nil", i))
+		}
+		out.Value[i] = *coordinate
+	}
+	return out
+}
+
+// Coordinate returns the coefficient of x^i of e.
+func (e ExtensionVariable) Coordinate(i int) Variable {
+	return e.Value[i]
+}
+
+// Coordinates returns the coordinates of e, the lowest degree one first.
+func (e ExtensionVariable) Coordinates() [4]Variable {
+	return e.Value
+}
+
+// Felt2Ext embeds a felt in the extension field as its constant coordinate.
+func Felt2Ext(a Variable) ExtensionVariable {
+	return Felts2Ext(a, NewF("0"), NewF("0"), NewF("0"))
+}
+
 func (c *Chip) AddF(a, b Variable) Variable {
+	defer c.traceOperation("AddF")()
+	c.fitOperands(func() uint { return max(a.NbBits, b.NbBits) + 1 }, &a, &b)
 	var maxBits uint
 	if a.NbBits > b.NbBits {
 		maxBits = a.NbBits
@@ -76,39 +200,67 @@ func (c *Chip) AddF(a, b Variable) Variable {
 }
 
 func (c *Chip) SubF(a, b Variable) Variable {
+	defer c.traceOperation("SubF")()
 	negB := c.NegF(b)
 	return c.AddF(a, negB)
 }
 
 func (c *Chip) MulF(a, b Variable) Variable {
+	defer c.traceOperation("MulF")()
+	c.fitOperands(func() uint { return c.nbBits(a) + c.nbBits(b) }, &a, &b)
 	return c.ReduceFast(Variable{
 		Value:  c.api.Mul(a.Value, b.Value),
-		NbBits: a.NbBits + b.NbBits,
+		NbBits: c.nbBits(a) + c.nbBits(b),
+	})
+}
+
+// MulAddF computes a * b + d with a single reduction.
+func (c *Chip) MulAddF(a, b, d Variable) Variable {
+	defer c.traceOperation("MulAddF")()
+	c.fitOperands(func() uint { return max(c.nbBits(a)+c.nbBits(b), d.NbBits) + 1 }, &a, &b, &d)
+	maxBits := c.nbBits(a) + c.nbBits(b)
+	if d.NbBits > maxBits {
+		maxBits = d.NbBits
+	}
+	return c.ReduceFast(Variable{
+		Value:  c.api.Add(c.api.Mul(a.Value, b.Value), d.Value),
+		NbBits: maxBits + 1,
 	})
 }
 
 func (c *Chip) MulFConst(a Variable, b int) Variable {
+	defer c.traceOperation("MulFConst")()
 	return c.ReduceFast(Variable{
 		Value:  c.api.Mul(a.Value, b),
 		NbBits: a.NbBits + 4,
 	})
 }
 
+// NegF returns the negation of a. A 31 bit a is negated as p - a, which is p rather than the
+// canonical zero when a is zero, so the result is marked 32 bits to be reduced when compared.
 func (c *Chip) NegF(a Variable) Variable {
+	defer c.traceOperation("NegF")()
 	if a.NbBits == 31 {
-		return Variable{Value: c.api.Sub(MODULUS, a.Value), NbBits: 31}
+		return Variable{Value: c.api.Sub(c.field.Modulus, a.Value), NbBits: 32}
 	}
-	negOne := NewF("2013265920")
+	negOne := Variable{Value: new(big.Int).Sub(c.field.Modulus, big.NewInt(1)), NbBits: 31}
 	return c.MulF(a, negOne)
 }
 
+// InvF returns the inverse of in, supplied by a hint and checked with a single multiplication. No
+// value passes the check for a zero input, so inverting zero makes the circuit unsatisfiable, and
+// callers that may invert zero select a nonzero input before the inversion.
 func (c *Chip) InvF(in Variable) Variable {
+	defer c.traceOperation("InvF")()
 	in = c.ReduceSlow(in)
-	result, err := c.api.Compiler().NewHint(InvFHint, 1, in.Value)
-	if err != nil {
-		panic(err)
+	if in, ok := c.foldConstant(in); ok && in.Value.(*big.Int).Sign() != 0 {
+		return Variable{Value: new(big.Int).ModInverse(in.Value.(*big.Int), c.field.Modulus), NbBits: 31}
 	}
+	result := c.newHint(invFHints, 1, in.Value)
 
+	// The inverse is range checked like the quotient of DivF, as the product could wrap around the
+	// native field otherwise.
+	c.rangeChecker.Check(result[0], 31)
 	xinv := Variable{
 		Value:  result[0],
 		NbBits: 31,
@@ -119,20 +271,84 @@ func (c *Chip) InvF(in Variable) Variable {
 	return xinv
 }
 
+// DivF divides a by the nonzero b. The quotient is a hint checked by a single multiplication, and
+// a zero b makes the circuit unsatisfiable.
+func (c *Chip) DivF(a, b Variable) Variable {
+	defer c.traceOperation("DivF")()
+	b = c.ReduceSlow(b)
+	// A constant divisor is inverted in Go, and the division is a multiplication by a constant.
+	if folded, ok := c.foldConstant(b); ok && folded.Value.(*big.Int).Sign() != 0 {
+		return c.MulF(a, c.InvF(folded))
+	}
+	// The reduced b is zero modulo p exactly when it is 0 or p.
+	c.api.AssertIsDifferent(c.api.Mul(b.Value, c.api.Sub(b.Value, c.field.Modulus)), 0)
+	result := c.newHint(divFHints, 1, a.Value, b.Value)
+
+	// The quotient is range checked, as the check would be satisfied by wrapping
+	// it around the native field otherwise.
+	c.rangeChecker.Check(result[0], 31)
+	quotient := Variable{
+		Value:  result[0],
+		NbBits: 31,
+	}
+	c.assertIsEqualModP(c.lazyMul(quotient, b), a)
+
+	return quotient
+}
+
+// assertIsEqualModP asserts that a and b are equal modulo p with a single reduction, of a - b
+// lifted by a multiple of p above b, whose remainder must then be 0 or p.
+func (c *Chip) assertIsEqualModP(a, b Variable) {
+	offset := new(big.Int).Lsh(c.field.Modulus, b.NbBits)
+	diff := Variable{
+		Value:  c.api.Sub(c.api.Add(a.Value, offset), b.Value),
+		NbBits: max(a.NbBits, b.NbBits+31) + 1,
+	}
+	r := c.ReduceSlow(diff).Value
+	c.api.AssertIsEqual(c.api.Mul(r, c.api.Sub(r, c.field.Modulus)), 0)
+}
+
+// AssertIsEqualF asserts that a and b are equal modulo p by comparing their reductions. Honest
+// reductions are canonical, so equal values are always accepted.
 func (c *Chip) AssertIsEqualF(a, b Variable) {
+	defer c.traceOperation("AssertIsEqualF")()
 	a2 := c.ReduceSlow(a)
 	b2 := c.ReduceSlow(b)
 	c.api.AssertIsEqual(a2.Value, b2.Value)
 }
 
 func (c *Chip) AssertIsEqualE(a, b ExtensionVariable) {
+	defer c.traceOperation("AssertIsEqualE")()
 	c.AssertIsEqualF(a.Value[0], b.Value[0])
 	c.AssertIsEqualF(a.Value[1], b.Value[1])
 	c.AssertIsEqualF(a.Value[2], b.Value[2])
 	c.AssertIsEqualF(a.Value[3], b.Value[3])
 }
 
+// PrintF prints the value of in prefixed by the label when the circuit is solved. The value is
+// reduced first, which adds the constraints of the reduction if it is not already.
+func (c *Chip) PrintF(label string, in Variable) {
+	defer c.traceOperation("PrintF")()
+	c.api.Println(label, c.ReduceSlow(in).Value)
+}
+
+// PrintE is the extension field variant of PrintF, which prints the coordinates in order.
+func (c *Chip) PrintE(label string, in ExtensionVariable) {
+	defer c.traceOperation("PrintE")()
+	c.api.Println(label, c.ReduceSlow(in.Value[0]).Value, c.ReduceSlow(in.Value[1]).Value, c.ReduceSlow(in.Value[2]).Value, c.ReduceSlow(in.Value[3]).Value)
+}
+
+// AssertIsBooleanF asserts that the input is 0 or 1, and returns it as a boolean usable as the
+// condition of a select. The reduced value is only boolean when it is canonical.
+func (c *Chip) AssertIsBooleanF(in Variable) frontend.Variable {
+	defer c.traceOperation("AssertIsBooleanF")()
+	in = c.ReduceSlow(in)
+	c.api.AssertIsBoolean(in.Value)
+	return in.Value
+}
+
 func (c *Chip) SelectF(cond frontend.Variable, a, b Variable) Variable {
+	defer c.traceOperation("SelectF")()
 	var nbBits uint
 	if a.NbBits > b.NbBits {
 		nbBits = a.NbBits
@@ -146,6 +362,7 @@ func (c *Chip) SelectF(cond frontend.Variable, a, b Variable) Variable {
 }
 
 func (c *Chip) SelectE(cond frontend.Variable, a, b ExtensionVariable) ExtensionVariable {
+	defer c.traceOperation("SelectE")()
 	return ExtensionVariable{
 		Value: [4]Variable{
 			c.SelectF(cond, a.Value[0], b.Value[0]),
@@ -156,12 +373,102 @@ func (c *Chip) SelectE(cond frontend.Variable, a, b ExtensionVariable) Extension
 	}
 }
 
+// IsLessThanF returns 1 if the canonical value of a is less than the canonical value of b and 0
+// otherwise.
+func (c *Chip) IsLessThanF(a, b Variable) frontend.Variable {
+	defer c.traceOperation("IsLessThanF")()
+	return c.isLessThan(c.reduceCanonical(a), c.reduceCanonical(b))
+}
+
+// MinF returns the smaller of the canonical values of a and b.
+func (c *Chip) MinF(a, b Variable) Variable {
+	defer c.traceOperation("MinF")()
+	min, _ := c.MinMaxF(a, b)
+	return min
+}
+
+// MaxF returns the larger of the canonical values of a and b.
+func (c *Chip) MaxF(a, b Variable) Variable {
+	defer c.traceOperation("MaxF")()
+	_, max := c.MinMaxF(a, b)
+	return max
+}
+
+// MinMaxF returns both the smaller and the larger of the canonical values of a and b using a
+// single comparison.
+func (c *Chip) MinMaxF(a, b Variable) (Variable, Variable) {
+	defer c.traceOperation("MinMaxF")()
+	a = c.reduceCanonical(a)
+	b = c.reduceCanonical(b)
+	isLess := c.isLessThan(a, b)
+	return c.SelectF(isLess, a, b), c.SelectF(isLess, b, a)
+}
+
+// AssertIsLessThanF asserts that the canonical value of a is less than the canonical value of b.
+// Unlike IsLessThanF, it needs no hint: b - a - 1 fits in 31 bits exactly when a < b.
+func (c *Chip) AssertIsLessThanF(a, b Variable) {
+	defer c.traceOperation("AssertIsLessThanF")()
+	a = c.reduceCanonical(a)
+	b = c.reduceCanonical(b)
+	c.rangeChecker.Check(c.api.Sub(c.api.Sub(b.Value, a.Value), 1), 31)
+}
+
+// isLessThan compares two canonical values. Since both are below the modulus, their difference
+// fits in 31 bits exactly when it is taken in the right order.
+func (c *Chip) isLessThan(a, b Variable) frontend.Variable {
+	result, err := c.api.Compiler().NewHint(IsLessThanHint, 1, a.Value, b.Value)
+	if err != nil {
+		panic(err)
+	}
+
+	isLess := result[0]
+	c.api.AssertIsBoolean(isLess)
+	diff := c.api.Select(isLess, c.api.Sub(c.api.Sub(b.Value, a.Value), 1), c.api.Sub(a.Value, b.Value))
+	c.rangeChecker.Check(diff, 31)
+
+	return isLess
+}
+
+// reduceCanonical reduces the input and asserts that the result is below the modulus.
+func (c *Chip) reduceCanonical(in Variable) Variable {
+	in = c.ReduceSlow(in)
+	c.rangeChecker.Check(c.api.Sub(new(big.Int).Sub(c.field.Modulus, big.NewInt(1)), in.Value), 31)
+	return in
+}
+
+// ReduceF returns the canonical value of the input, the unique representative below the modulus.
+// A felt exposed outside the circuit, such as through a public input, must be canonical for its
+// encoding to only depend on its value.
+func (c *Chip) ReduceF(in Variable) Variable {
+	defer c.traceOperation("ReduceF")()
+	return c.reduceCanonical(in)
+}
+
+// ReduceE returns the extension element with the canonical values of the coordinates of the input.
+func (c *Chip) ReduceE(in ExtensionVariable) ExtensionVariable {
+	defer c.traceOperation("ReduceE")()
+	var out ExtensionVariable
+	for i := range in.Value {
+		out.Value[i] = c.reduceCanonical(in.Value[i])
+	}
+	return out
+}
+
+// AssertIsCanonical asserts that the value of the input itself, without reducing it, is below the
+// modulus.
+func (c *Chip) AssertIsCanonical(in Variable) {
+	defer c.traceOperation("AssertIsCanonical")()
+	c.assertBitsLessThan(c.api.ToBinary(in.Value, 31), c.field.Modulus)
+}
+
 func (c *Chip) AddEF(a ExtensionVariable, b Variable) ExtensionVariable {
+	defer c.traceOperation("AddEF")()
 	v1 := c.AddF(a.Value[0], b)
 	return ExtensionVariable{Value: [4]Variable{v1, a.Value[1], a.Value[2], a.Value[3]}}
 }
 
 func (c *Chip) AddE(a, b ExtensionVariable) ExtensionVariable {
+	defer c.traceOperation("AddE")()
 	v1 := c.AddF(a.Value[0], b.Value[0])
 	v2 := c.AddF(a.Value[1], b.Value[1])
 	v3 := c.AddF(a.Value[2], b.Value[2])
@@ -169,7 +476,36 @@ func (c *Chip) AddE(a, b ExtensionVariable) ExtensionVariable {
 	return ExtensionVariable{Value: [4]Variable{v1, v2, v3, v4}}
 }
 
+// SumE adds any number of extension elements with a single reduction per coordinate.
+func (c *Chip) SumE(terms ...ExtensionVariable) ExtensionVariable {
+	defer c.traceOperation("SumE")()
+	if len(terms) == 0 {
+		return ZeroE()
+	}
+	var out ExtensionVariable
+	for i := 0; i < 4; i++ {
+		values := make([]frontend.Variable, len(terms))
+		var maxBits uint
+		for j, term := range terms {
+			values[j] = term.Value[i].Value
+			if term.Value[i].NbBits > maxBits {
+				maxBits = term.Value[i].NbBits
+			}
+		}
+		sum := values[0]
+		if len(values) > 1 {
+			sum = c.api.Add(values[0], values[1], values[2:]...)
+		}
+		out.Value[i] = c.ReduceFast(Variable{
+			Value:  sum,
+			NbBits: maxBits + uint(bits.Len(uint(len(terms)-1))),
+		})
+	}
+	return out
+}
+
 func (c *Chip) SubE(a, b ExtensionVariable) ExtensionVariable {
+	defer c.traceOperation("SubE")()
 	v1 := c.SubF(a.Value[0], b.Value[0])
 	v2 := c.SubF(a.Value[1], b.Value[1])
 	v3 := c.SubF(a.Value[2], b.Value[2])
@@ -178,32 +514,48 @@ func (c *Chip) SubE(a, b ExtensionVariable) ExtensionVariable {
 }
 
 func (c *Chip) SubEF(a ExtensionVariable, b Variable) ExtensionVariable {
+	defer c.traceOperation("SubEF")()
 	v1 := c.SubF(a.Value[0], b)
 	return ExtensionVariable{Value: [4]Variable{v1, a.Value[1], a.Value[2], a.Value[3]}}
 }
 
 func (c *Chip) MulE(a, b ExtensionVariable) ExtensionVariable {
-	v2 := [4]Variable{
-		NewF("0"),
-		NewF("0"),
-		NewF("0"),
-		NewF("0"),
+	defer c.traceOperation("MulE")()
+	var out ExtensionVariable
+	for i, coordinate := range c.lazyMulE(a, b) {
+		out.Value[i] = c.ReduceFast(coordinate)
 	}
+	return out
+}
 
-	for i := 0; i < 4; i++ {
-		for j := 0; j < 4; j++ {
-			if i+j >= 4 {
-				v2[i+j-4] = c.AddF(v2[i+j-4], c.MulFConst(c.MulF(a.Value[i], b.Value[j]), 11))
-			} else {
-				v2[i+j] = c.AddF(v2[i+j], c.MulF(a.Value[i], b.Value[j]))
-			}
-		}
+// lazyMulE returns the coordinates of a * b before their reduction.
+func (c *Chip) lazyMulE(a, b ExtensionVariable) [4]Variable {
+	c.fitMulE(&a, &b)
+	if c.towerExtension {
+		return c.lazyMulETower(a, b)
 	}
 
-	return ExtensionVariable{Value: v2}
+	// Each coordinate accumulates its products unreduced, the ones that wrap around x^4 multiplied
+	// by W.
+	var out [4]Variable
+	for k := 0; k < 4; k++ {
+		var low, high Variable
+		for i := 0; i <= k; i++ {
+			low = c.lazyAccumulate(low, c.lazyMul(a.Value[i], b.Value[k-i]))
+		}
+		for i := k + 1; i < 4; i++ {
+			high = c.lazyAccumulate(high, c.lazyMul(a.Value[i], b.Value[k+4-i]))
+		}
+		if k < 3 {
+			low = c.lazyAdd(low, c.lazyMulW(high))
+		}
+		out[k] = low
+	}
+	return out
 }
 
 func (c *Chip) MulEF(a ExtensionVariable, b Variable) ExtensionVariable {
+	defer c.traceOperation("MulEF")()
 	v1 := c.MulF(a.Value[0], b)
 	v2 := c.MulF(a.Value[1], b)
 	v3 := c.MulF(a.Value[2], b)
@@ -212,20 +564,20 @@ func (c *Chip) MulEF(a ExtensionVariable, b Variable) ExtensionVariable {
 }
 
 func (c *Chip) InvE(in ExtensionVariable) ExtensionVariable {
+	defer c.traceOperation("InvE")()
 	in.Value[0] = c.ReduceSlow(in.Value[0])
 	in.Value[1] = c.ReduceSlow(in.Value[1])
 	in.Value[2] = c.ReduceSlow(in.Value[2])
 	in.Value[3] = c.ReduceSlow(in.Value[3])
-	result, err := c.api.Compiler().NewHint(InvEHint, 4, in.Value[0].Value, in.Value[1].Value, in.Value[2].Value, in.Value[3].Value)
-	if err != nil {
-		panic(err)
+	if !c.isZeroConstantE(in) {
+		if folded, ok := c.foldExtHint(invEHint, in); ok {
+			return folded
+		}
+	}
+	out := c.CallExtHint(invEHint, in)
+	for i := 0; i < 4; i++ {
+		c.rangeChecker.Check(out.Value[i].Value, 31)
 	}
-
-	xinv := Variable{Value: result[0], NbBits: 31}
-	yinv := Variable{Value: result[1], NbBits: 31}
-	zinv := Variable{Value: result[2], NbBits: 31}
-	linv := Variable{Value: result[3], NbBits: 31}
-	out := ExtensionVariable{Value: [4]Variable{xinv, yinv, zinv, linv}}
 
 	product := c.MulE(in, out)
 	c.AssertIsEqualE(product, NewE([]string{"1", "0", "0", "0"}))
@@ -233,16 +585,96 @@ func (c *Chip) InvE(in ExtensionVariable) ExtensionVariable {
 	return out
 }
 
+// BatchInvF inverts every value, which must be nonzero: a zero value makes the circuit
+// unsatisfiable. Montgomery's trick would trade the inversions for three multiplications each, but
+// an inversion is already a hint checked by a single multiplication, so the trick costs about
+// twice the constraints and the values are inverted one by one.
+func (c *Chip) BatchInvF(values []Variable) []Variable {
+	defer c.traceOperation("BatchInvF")()
+	out := make([]Variable, len(values))
+	for i, v := range values {
+		out[i] = c.InvF(v)
+	}
+	return out
+}
+
+// BatchInvE is the extension field variant of BatchInvF, for which Montgomery's trick costs about
+// six times the constraints of the checked hints.
+func (c *Chip) BatchInvE(values []ExtensionVariable) []ExtensionVariable {
+	defer c.traceOperation("BatchInvE")()
+	out := make([]ExtensionVariable, len(values))
+	for i, v := range values {
+		out[i] = c.InvE(v)
+	}
+	return out
+}
+
+// IsZeroE returns 1 if every coordinate of the input is zero and 0 otherwise.
+func (c *Chip) IsZeroE(in ExtensionVariable) frontend.Variable {
+	defer c.traceOperation("IsZeroE")()
+	var isZero frontend.Variable = 1
+	for i := 0; i < 4; i++ {
+		isZero = c.api.Mul(isZero, c.isZeroF(in.Value[i]))
+	}
+	return isZero
+}
+
+// isZeroF returns 1 if the input is zero and 0 otherwise. The reduced value is below 2^31, so it
+// is zero exactly when it is 0 or the modulus, which needs no canonical reduction.
+func (c *Chip) isZeroF(in Variable) frontend.Variable {
+	in = c.ReduceSlow(in)
+	return c.api.IsZero(c.api.Mul(in.Value, c.api.Sub(in.Value, c.field.Modulus)))
+}
+
 func (c *Chip) Ext2Felt(in ExtensionVariable) [4]Variable {
 	return in.Value
 }
 
+// DivE divides a by the nonzero b. The quotient is a hint checked by a single multiplication, and
+// a zero b makes the circuit unsatisfiable. Unlike an inversion followed by a multiplication, a is
+// only reduced once, together with the check.
 func (c *Chip) DivE(a, b ExtensionVariable) ExtensionVariable {
-	bInv := c.InvE(b)
-	return c.MulE(a, bInv)
+	defer c.traceOperation("DivE")()
+	for i := 0; i < 4; i++ {
+		b.Value[i] = c.ReduceSlow(b.Value[i])
+	}
+	// A constant divisor is inverted in Go, and the division is a multiplication by a constant.
+	if !c.isZeroConstantE(b) {
+		if inverse, ok := c.foldExtHint(invEHint, b); ok {
+			return c.MulE(a, inverse)
+		}
+	}
+	c.api.AssertIsEqual(c.IsZeroE(b), 0)
+	quotient := c.callExtHint(divEHint, false, a, b)
+	for i := 0; i < 4; i++ {
+		c.rangeChecker.Check(quotient.Value[i].Value, 31)
+	}
+
+	// The coordinates of the product accumulated like in MulE, but not reduced.
+	for k := 0; k < 4; k++ {
+		var low, high Variable
+		for i := 0; i <= k; i++ {
+			low = c.lazyAccumulate(low, c.lazyMul(quotient.Value[i], b.Value[k-i]))
+		}
+		for i := k + 1; i < 4; i++ {
+			high = c.lazyAccumulate(high, c.lazyMul(quotient.Value[i], b.Value[k+4-i]))
+		}
+		if k < 3 {
+			low = c.lazyAdd(low, c.lazyMulW(high))
+		}
+		c.assertIsEqualModP(low, a.Value[k])
+	}
+	return quotient
+}
+
+// DivEF divides a by the nonzero felt b, with a single base field inversion.
+func (c *Chip) DivEF(a ExtensionVariable, b Variable) ExtensionVariable {
+	defer c.traceOperation("DivEF")()
+	return c.MulEF(a, c.InvF(b))
 }
 
 func (c *Chip) NegE(a ExtensionVariable) ExtensionVariable {
+	defer c.traceOperation("NegE")()
 	v1 := c.NegF(a.Value[0])
 	v2 := c.NegF(a.Value[1])
 	v3 := c.NegF(a.Value[2])
@@ -251,11 +683,170 @@ func (c *Chip) NegE(a ExtensionVariable) ExtensionVariable {
 }
 
 func (c *Chip) ToBinary(in Variable) []frontend.Variable {
-	return c.api.ToBinary(c.ReduceSlow(in).Value, 32)
+	defer c.traceOperation("ToBinary")()
+	return c.ToBinaryN(in, 32)
+}
+
+// ToBinaryN reduces the input and decomposes it into nbBits little-endian bits.
+func (c *Chip) ToBinaryN(in Variable, nbBits int) []frontend.Variable {
+	defer c.traceOperation("ToBinaryN")()
+	return c.api.ToBinary(c.ReduceSlow(in).Value, nbBits)
+}
+
+// ToBinaryStrict is ToBinary for bits that feed the transcript. The reduction only bounds the
+// remainder by 2^31, so ToBinary also accepts v + p for v < 2^31 - p. ToBinaryStrict additionally
+// asserts that the bits are below those of the modulus, which makes the decomposition unique.
+func (c *Chip) ToBinaryStrict(in Variable) []frontend.Variable {
+	defer c.traceOperation("ToBinaryStrict")()
+	bits := c.ToBinary(in)
+	c.assertBitsLessThan(bits, c.field.Modulus)
+	return bits
+}
+
+// Num2Bits decomposes the canonical value of the input into exactly n little-endian bits. A value
+// that does not fit in n bits makes the circuit unsatisfiable, and for n >= 31 the bits are
+// asserted to be below those of the modulus, so the decomposition is unique.
+func (c *Chip) Num2Bits(in Variable, n int) []frontend.Variable {
+	defer c.traceOperation("Num2Bits")()
+	bits := c.ToBinaryN(in, n)
+	c.assertBitsLessThan(bits, c.field.Modulus)
+	return bits
+}
+
+// Bits2Num packs little-endian bits, which are asserted boolean, into a felt.
+func (c *Chip) Bits2Num(bits []frontend.Variable) Variable {
+	defer c.traceOperation("Bits2Num")()
+	return Variable{Value: c.api.FromBinary(bits...), NbBits: max(uint(len(bits)), 31)}
+}
+
+// assertBitsLessThan asserts that the little-endian bits are lexicographically below those of the
+// constant bound.
+func (c *Chip) assertBitsLessThan(bits []frontend.Variable, bound *big.Int) {
+	if bound.BitLen() > len(bits) {
+		return
+	}
+	// prefixEqual is 1 while the bits seen so far, from the most significant one, equal those of the
+	// bound. Where the bound has a 0 the bit must then be 0 too.
+	var prefixEqual frontend.Variable = 1
+	for i := len(bits) - 1; i >= 0; i-- {
+		if bound.Bit(i) == 1 {
+			prefixEqual = c.api.Mul(prefixEqual, bits[i])
+		} else {
+			c.api.AssertIsEqual(c.api.Mul(prefixEqual, bits[i]), 0)
+		}
+	}
+	c.api.AssertIsEqual(prefixEqual, 0)
+}
+
+// NUM_ELMS_PER_BN254_ELM is the number of BabyBear elements a BN254 element is split into.
+const NUM_ELMS_PER_BN254_ELM = 8
+
+// bn254LimbBits is the number of bits of each limb of a split BN254 element, so that split elements
+// are below 2^248 and are the packings of babybears_to_bn254.
+const bn254LimbBits = 31
+
+// checkPackedField panics unless NUM_ELMS_PER_BN254_ELM limbs pack into the native field without
+// wrapping around its modulus.
+func (c *Chip) checkPackedField() {
+	if nbBits := c.api.Compiler().FieldBitLen(); nbBits <= NUM_ELMS_PER_BN254_ELM*bn254LimbBits {
+		panic(fmt.Sprintf("a field of %d bits does not hold %d limbs of %d bits", nbBits, NUM_ELMS_PER_BN254_ELM, bn254LimbBits))
+	}
+}
+
+// SplitIntoBabyBear is the inverse of the babybears_to_bn254 packing of a digest: it decomposes in
+// into NUM_ELMS_PER_BN254_ELM limbs of 31 bits, the first limb in the high bits, each asserted to be
+// a canonical element of the field of the chip. Only elements below 2^248 whose limbs are all
+// canonical are such packings, and any other element makes the circuit unsatisfiable.
+func (c *Chip) SplitIntoBabyBear(in frontend.Variable) [NUM_ELMS_PER_BN254_ELM]Variable {
+	defer c.traceOperation("SplitIntoBabyBear")()
+	c.checkPackedField()
+	bits := c.api.ToBinary(in, NUM_ELMS_PER_BN254_ELM*bn254LimbBits)
+	var limbs [NUM_ELMS_PER_BN254_ELM]Variable
+	for i := range limbs {
+		low := (len(limbs) - 1 - i) * bn254LimbBits
+		limbBits := bits[low : low+bn254LimbBits]
+		c.assertBitsLessThan(limbBits, c.field.Modulus)
+		limbs[i] = Variable{Value: c.api.FromBinary(limbBits...), NbBits: 31}
+	}
+	return limbs
+}
+
+// PackIntoBN254 is the inverse of SplitIntoBabyBear, the babybears_to_bn254 packing: it returns the
+// sum of the canonical limbs shifted by 31 bits each, the first limb in the high bits. The packing
+// is below 2^248, so it never wraps around the native modulus.
+func (c *Chip) PackIntoBN254(limbs [NUM_ELMS_PER_BN254_ELM]Variable) frontend.Variable {
+	defer c.traceOperation("PackIntoBN254")()
+	c.checkPackedField()
+	var acc frontend.Variable = 0
+	for _, limb := range limbs {
+		acc = c.api.Add(c.api.Mul(acc, 1<<bn254LimbBits), c.api.FromBinary(c.ToBinaryStrict(limb)...))
+	}
+	return acc
+}
+
+// BatchToBinary decomposes every input into nbBits little-endian bits. The output is identical to
+// calling ToBinaryN on each input, but the remainder of each reduction is range checked by its own
+// bit decomposition instead of a separate check, and all quotient checks share the range checker.
+func (c *Chip) BatchToBinary(vs []Variable, nbBits int) [][]frontend.Variable {
+	defer c.traceOperation("BatchToBinary")()
+	out := make([][]frontend.Variable, len(vs))
+	for i, v := range vs {
+		if v.NbBits <= 31 {
+			out[i] = c.api.ToBinary(v.Value, nbBits)
+			continue
+		}
+
+		result := c.newHint(reduceHints, 2, v.Value)
+		quotient := result[0]
+		remainder := result[1]
+		// The quotient is bounded like the one of ReduceWithMaxBits.
+		c.rangeChecker.Check(quotient, int(v.NbBits-30))
+
+		// The decomposition bounds the remainder by 2^nbBits, so the bits above 31 must be zero to
+		// match the 31 bit remainder check done by ReduceSlow.
+		bits := c.api.ToBinary(remainder, nbBits)
+		for j := 31; j < nbBits; j++ {
+			c.api.AssertIsEqual(bits[j], 0)
+		}
+		c.api.AssertIsEqual(v.Value, c.api.Add(c.api.Mul(quotient, c.field.Modulus), remainder))
+		out[i] = bits
+	}
+	return out
+}
+
+// RangeCheckF reduces the input and asserts that it fits in nbBits bits.
+func (c *Chip) RangeCheckF(in Variable, nbBits int) {
+	defer c.traceOperation("RangeCheckF")()
+	c.rangeChecker.Check(c.ReduceSlow(in).Value, nbBits)
+}
+
+// ToBytes reduces the input and decomposes it into 4 little-endian bytes.
+func (c *Chip) ToBytes(in Variable) [4]frontend.Variable {
+	defer c.traceOperation("ToBytes")()
+	in = c.ReduceSlow(in)
+	result, err := c.api.Compiler().NewHint(ToBytesHint, 4, in.Value)
+	if err != nil {
+		panic(err)
+	}
+
+	var bytes [4]frontend.Variable
+	var acc frontend.Variable = 0
+	for i := 0; i < 4; i++ {
+		c.rangeChecker.Check(result[i], 8)
+		bytes[i] = result[i]
+		acc = c.api.Add(acc, c.api.Mul(result[i], 1<<(8*i)))
+	}
+	c.api.AssertIsEqual(in.Value, acc)
+
+	return bytes
 }
 
 func (p *Chip) ReduceFast(x Variable) Variable {
-	if x.NbBits >= uint(120) {
+	defer p.traceOperation("ReduceFast")()
+	if folded, ok := p.foldConstant(x); ok {
+		return folded
+	}
+	if x.NbBits >= p.reduceBits {
 		return Variable{
 			Value:  p.ReduceWithMaxBits(x.Value, uint64(x.NbBits)),
 			NbBits: 31,
@@ -265,45 +856,169 @@ func (p *Chip) ReduceFast(x Variable) Variable {
 }
 
 func (p *Chip) ReduceSlow(x Variable) Variable {
-	if x.NbBits == 31 {
+	defer p.traceOperation("ReduceSlow")()
+	// A value of at most 31 bits is canonical, and below 30 bits its quotient bound would wrap.
+	if x.NbBits <= 31 {
 		return x
 	}
+	if folded, ok := p.foldConstant(x); ok {
+		return folded
+	}
 	return Variable{
 		Value:  p.ReduceWithMaxBits(x.Value, uint64(x.NbBits)),
 		NbBits: 31,
 	}
 }
 
+// ReduceMaxBits reduces a value that the caller knows fits in maxBits bits, which may be much
+// tighter than the tracked bound, so that the quotient check is smaller. The bound is enforced by
+// the range checks, so understating it makes the circuit unsatisfiable.
+func (p *Chip) ReduceMaxBits(x Variable, maxBits uint) Variable {
+	defer p.traceOperation("ReduceMaxBits")()
+	if maxBits > x.NbBits {
+		maxBits = x.NbBits
+	}
+	if maxBits <= 31 {
+		p.rangeChecker.Check(x.Value, int(maxBits))
+		return Variable{Value: x.Value, NbBits: 31}
+	}
+	return Variable{
+		Value:  p.ReduceWithMaxBits(x.Value, uint64(maxBits)),
+		NbBits: 31,
+	}
+}
+
+// ReduceWithMaxBits returns the canonical remainder of x, a value below 2^maxNbBits, modulo p. The
+// quotient is below 2^maxNbBits / p, which takes up to maxNbBits - 30 bits as p is below 2^31.
 func (p *Chip) ReduceWithMaxBits(x frontend.Variable, maxNbBits uint64) frontend.Variable {
-	result, err := p.api.Compiler().NewHint(ReduceHint, 2, x)
-	if err != nil {
-		panic(err)
+	if v, ok := p.api.Compiler().ConstantValue(x); ok && uint64(v.BitLen()) <= maxNbBits {
+		return new(big.Int).Mod(v, p.field.Modulus)
 	}
+	result := p.newHint(reduceHints, 2, x)
 
 	quotient := result[0]
-	p.rangeChecker.Check(quotient, int(maxNbBits-31))
+	p.rangeChecker.Check(quotient, int(maxNbBits-30))
 
 	remainder := result[1]
 	p.rangeChecker.Check(remainder, 31)
 
-	p.api.AssertIsEqual(x, p.api.Add(p.api.Mul(quotient, MODULUS), result[1]))
+	p.api.AssertIsEqual(x, p.api.Add(p.api.Mul(quotient, p.field.Modulus), result[1]))
 
 	return remainder
 }
 
+// The chip folds the operations on constants of the circuit, computing them in Go instead of
+// constraining them. A constant is only folded while it satisfies the bound tracked for it, since
+// the constraints of an out of bound value would make the circuit unsatisfiable instead.
+
+// foldConstant returns the canonical value of x if it is a constant within its bound.
+func (p *Chip) foldConstant(x Variable) (Variable, bool) {
+	v, ok := p.api.Compiler().ConstantValue(x.Value)
+	if !ok || uint(v.BitLen()) > x.NbBits {
+		return Variable{}, false
+	}
+	return Variable{Value: new(big.Int).Mod(v, p.field.Modulus), NbBits: 31}, true
+}
+
+// nbBits returns the bound of x, which is the bit length of x if it is a constant within its
+// bound, so that the products of constants are reduced no sooner than needed.
+func (p *Chip) nbBits(x Variable) uint {
+	if v, ok := p.api.Compiler().ConstantValue(x.Value); ok && uint(v.BitLen()) <= x.NbBits {
+		return uint(v.BitLen())
+	}
+	return x.NbBits
+}
+
+// foldExtHint calls the extension hint in Go if all the coordinates of the inputs are constants
+// within their bounds.
+func (p *Chip) foldExtHint(h ExtHint, inputs ...ExtensionVariable) (ExtensionVariable, bool) {
+	values := make([]*big.Int, 0, 4*len(inputs))
+	for _, in := range inputs {
+		for _, coordinate := range in.Value {
+			folded, ok := p.foldConstant(coordinate)
+			if !ok {
+				return ExtensionVariable{}, false
+			}
+			values = append(values, folded.Value.(*big.Int))
+		}
+	}
+	extHintsM.RLock()
+	fn := extFuncs[h.key]
+	extHintsM.RUnlock()
+	results, err := fn(p.field, values)
+	if err != nil {
+		panic(err)
+	}
+	var out ExtensionVariable
+	for i := range out.Value {
+		out.Value[i] = Variable{Value: results[i], NbBits: 31}
+	}
+	return out, true
+}
+
+// isZeroConstantE returns whether in is a constant zero, whose inversion is left to the
+// constraints, which make the circuit unsatisfiable.
+func (p *Chip) isZeroConstantE(in ExtensionVariable) bool {
+	for _, coordinate := range in.Value {
+		folded, ok := p.foldConstant(coordinate)
+		if !ok || folded.Value.(*big.Int).Sign() != 0 {
+			return false
+		}
+	}
+	return true
+}
+
 // The hint used to compute Reduce.
 func ReduceHint(_ *big.Int, inputs []*big.Int, results []*big.Int) error {
+	return reduceHint("ReduceHint", BabyBear, inputs, results)
+}
+
+// FieldReduceHint is ReduceHint over the field of its first input.
+func FieldReduceHint(_ *big.Int, inputs []*big.Int, results []*big.Int) error {
+	field, inputs, err := hintField(inputs)
+	if err != nil {
+		return err
+	}
+	return reduceHint("FieldReduceHint", field, inputs, results)
+}
+
+func reduceHint(name string, field *Field, inputs []*big.Int, results []*big.Int) error {
 	if len(inputs) != 1 {
-		panic("ReduceHint expects 1 input operand")
+		panic(name + " expects 1 input operand")
 	}
 	input := inputs[0]
-	quotient := new(big.Int).Div(input, MODULUS)
-	remainder := new(big.Int).Rem(input, MODULUS)
+	quotient := new(big.Int).Div(input, field.Modulus)
+	remainder := new(big.Int).Rem(input, field.Modulus)
 	results[0] = quotient
 	results[1] = remainder
 	return nil
 }
 
+// The hint used to compute IsLessThanF.
+func IsLessThanHint(_ *big.Int, inputs []*big.Int, results []*big.Int) error {
+	if len(inputs) != 2 {
+		panic("IsLessThanHint expects 2 input operands")
+	}
+	if inputs[0].Cmp(inputs[1]) < 0 {
+		results[0].SetUint64(1)
+	} else {
+		results[0].SetUint64(0)
+	}
+	return nil
+}
+
+// The hint used to compute ToBytes.
+func ToBytesHint(_ *big.Int, inputs []*big.Int, results []*big.Int) error {
+	if len(inputs) != 1 {
+		panic("ToBytesHint expects 1 input operand")
+	}
+	input := inputs[0].Uint64()
+	for i := 0; i < len(results); i++ {
+		results[i].SetUint64((input >> (8 * i)) & 0xff)
+	}
+	return nil
+}
+
 func InvFHint(_ *big.Int, inputs []*big.Int, results []*big.Int) error {
 	a := C.uint(inputs[0].Uint64())
 	ainv := C.babybearinv(a)
@@ -311,18 +1026,74 @@ func InvFHint(_ *big.Int, inputs []*big.Int, results []*big.Int) error {
 	return nil
 }
 
-func InvEHint(_ *big.Int, inputs []*big.Int, results []*big.Int) error {
+// FieldInvFHint is InvFHint over the field of its first input.
+func FieldInvFHint(_ *big.Int, inputs []*big.Int, results []*big.Int) error {
+	field, inputs, err := hintField(inputs)
+	if err != nil {
+		return err
+	}
+	if len(inputs) != 1 {
+		return fmt.Errorf("FieldInvFHint expects 1 input operand")
+	}
+	results[0].Set(field.inv(inputs[0]))
+	return nil
+}
+
+// The hint used to compute DivF.
+func DivFHint(_ *big.Int, inputs []*big.Int, results []*big.Int) error {
+	if len(inputs) != 2 {
+		return fmt.Errorf("DivFHint expects 2 input operands")
+	}
+	// The dividend may be unreduced.
+	binv := C.babybearinv(C.uint(inputs[1].Uint64()))
+	results[0].Mul(inputs[0], new(big.Int).SetUint64(uint64(binv)))
+	results[0].Mod(results[0], MODULUS)
+	return nil
+}
+
+// FieldDivFHint is DivFHint over the field of its first input.
+func FieldDivFHint(_ *big.Int, inputs []*big.Int, results []*big.Int) error {
+	field, inputs, err := hintField(inputs)
+	if err != nil {
+		return err
+	}
+	if len(inputs) != 2 {
+		return fmt.Errorf("FieldDivFHint expects 2 input operands")
+	}
+	results[0].Mul(inputs[0], field.inv(inputs[1]))
+	results[0].Mod(results[0], field.Modulus)
+	return nil
+}
+
+// InvEHint computes the inverse of an extension element of field, with the native BabyBear
+// arithmetic over BabyBear.
+func InvEHint(field *Field, inputs []*big.Int) ([4]*big.Int, error) {
+	if len(inputs) != 4 {
+		return [4]*big.Int{}, fmt.Errorf("InvEHint expects 4 input operands")
+	}
+	if field != BabyBear {
+		return field.InvE([4]*big.Int(inputs)), nil
+	}
 	a := C.uint(inputs[0].Uint64())
 	b := C.uint(inputs[1].Uint64())
 	c := C.uint(inputs[2].Uint64())
 	d := C.uint(inputs[3].Uint64())
-	ainv := C.babybearextinv(a, b, c, d, 0)
-	binv := C.babybearextinv(a, b, c, d, 1)
-	cinv := C.babybearextinv(a, b, c, d, 2)
-	dinv := C.babybearextinv(a, b, c, d, 3)
-	results[0].SetUint64(uint64(ainv))
-	results[1].SetUint64(uint64(binv))
-	results[2].SetUint64(uint64(cinv))
-	results[3].SetUint64(uint64(dinv))
-	return nil
+	var results [4]*big.Int
+	for i := 0; i < 4; i++ {
+		results[i] = new(big.Int).SetUint64(uint64(C.babybearextinv(a, b, c, d, C.uint(i))))
+	}
+	return results, nil
+}
+
+// DivEHint computes the quotient of the first extension element by the second one.
+func DivEHint(field *Field, inputs []*big.Int) ([4]*big.Int, error) {
+	if len(inputs) != 8 {
+		return [4]*big.Int{}, fmt.Errorf("DivEHint expects 8 input operands")
+	}
+	bInv, err := InvEHint(field, inputs[4:])
+	if err != nil {
+		return [4]*big.Int{}, err
+	}
+	// The dividend may be unreduced.
+	return field.MulE([4]*big.Int(inputs[:4]), bInv), nil
 }
@@ -0,0 +1,1416 @@
+package babybear
+
+import (
+	"fmt"
+	"math/big"
+	"math/bits"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/scs"
+	"github.com/consensys/gnark/test"
+)
+
+const batchSize = 64
+
+type TestBatchToBinaryCircuit struct {
+	Input    [batchSize]frontend.Variable `gnark:",public"`
+	Expected [batchSize][32]frontend.Variable
+	NbBits   [batchSize]uint `gnark:"-"`
+}
+
+func (circuit *TestBatchToBinaryCircuit) Define(api frontend.API) error {
+	chip := NewChip(api)
+
+	inputs := make([]Variable, batchSize)
+	for i := 0; i < batchSize; i++ {
+		inputs[i] = Variable{Value: circuit.Input[i], NbBits: circuit.NbBits[i]}
+	}
+
+	batch := chip.BatchToBinary(inputs, 32)
+	for i := 0; i < batchSize; i++ {
+		single := chip.ToBinaryN(inputs[i], 32)
+		for j := 0; j < 32; j++ {
+			api.AssertIsEqual(batch[i][j], single[j])
+			api.AssertIsEqual(batch[i][j], circuit.Expected[i][j])
+		}
+	}
+
+	return nil
+}
+
+type TestPerElementToBinaryCircuit struct {
+	Input  [batchSize]frontend.Variable `gnark:",public"`
+	NbBits [batchSize]uint              `gnark:"-"`
+	Batch  bool                         `gnark:"-"`
+}
+
+func (circuit *TestPerElementToBinaryCircuit) Define(api frontend.API) error {
+	chip := NewChip(api)
+
+	inputs := make([]Variable, batchSize)
+	for i := 0; i < batchSize; i++ {
+		inputs[i] = Variable{Value: circuit.Input[i], NbBits: circuit.NbBits[i]}
+	}
+
+	if circuit.Batch {
+		chip.BatchToBinary(inputs, 32)
+	} else {
+		for i := 0; i < batchSize; i++ {
+			chip.ToBinaryN(inputs[i], 32)
+		}
+	}
+
+	return nil
+}
+
+func TestBatchToBinary(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	modulusMinusOne := new(big.Int).Sub(MODULUS, big.NewInt(1))
+	// The largest value whose quotient fits the 33 bit check of a 64 bit reduction.
+	maxReducible := new(big.Int).Sub(new(big.Int).Lsh(MODULUS, 33), big.NewInt(1))
+	boundaries := []struct {
+		value  *big.Int
+		nbBits uint
+	}{
+		{big.NewInt(0), 31},
+		{big.NewInt(1), 31},
+		{modulusMinusOne, 31},
+		{big.NewInt(0x7fffffff), 31},
+		// Values of fewer than 30 bits have no quotient to check.
+		{big.NewInt(0xff), 8},
+		{big.NewInt(0x3fffffff), 30},
+		{big.NewInt(0), 64},
+		{new(big.Int).Set(MODULUS), 64},
+		{new(big.Int).Add(MODULUS, big.NewInt(1)), 64},
+		{new(big.Int).Mul(modulusMinusOne, modulusMinusOne), 64},
+		{maxReducible, 64},
+	}
+
+	var circuit, witness TestBatchToBinaryCircuit
+	for i := 0; i < batchSize; i++ {
+		b := boundaries[i%len(boundaries)]
+		value := b.value
+		if b.nbBits > 31 {
+			value = new(big.Int).Mod(value, MODULUS)
+		}
+		circuit.NbBits[i] = b.nbBits
+		witness.Input[i] = b.value
+		for j := 0; j < 32; j++ {
+			witness.Expected[i][j] = value.Bit(j)
+		}
+	}
+
+	assert.SolvingSucceeded(&circuit, &witness, test.WithCurves(ecc.BN254), test.WithBackends(backend.PLONK))
+}
+
+func TestBatchToBinaryConstraints(t *testing.T) {
+	var nbBits [batchSize]uint
+	for i := 0; i < batchSize; i++ {
+		nbBits[i] = 62
+	}
+
+	single, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &TestPerElementToBinaryCircuit{NbBits: nbBits})
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &TestPerElementToBinaryCircuit{NbBits: nbBits, Batch: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Logf("per-element: %d constraints, batch: %d constraints", single.GetNbConstraints(), batch.GetNbConstraints())
+	if batch.GetNbConstraints() >= single.GetNbConstraints() {
+		t.Fatalf("expected batch decomposition to be cheaper: %d >= %d", batch.GetNbConstraints(), single.GetNbConstraints())
+	}
+}
+
+type TestRangeCheckCircuit struct {
+	Input            [4]frontend.Variable `gnark:",public"`
+	Bytes            [4][4]frontend.Variable
+	BitDecomposition bool `gnark:"-"`
+}
+
+func (circuit *TestRangeCheckCircuit) Define(api frontend.API) error {
+	var chip *Chip
+	if circuit.BitDecomposition {
+		chip = NewChip(api, WithBitDecomposition())
+	} else {
+		chip = NewChip(api)
+	}
+
+	for i := 0; i < len(circuit.Input); i++ {
+		in := Variable{Value: circuit.Input[i], NbBits: 31}
+		chip.RangeCheckF(in, 31)
+		bytes := chip.ToBytes(in)
+		for j := 0; j < 4; j++ {
+			api.AssertIsEqual(bytes[j], circuit.Bytes[i][j])
+		}
+	}
+
+	return nil
+}
+
+func TestRangeCheck(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	values := []uint64{0, 255, 2013265920, 0x7fffffff}
+	var witness TestRangeCheckCircuit
+	for i, v := range values {
+		witness.Input[i] = v
+		for j := 0; j < 4; j++ {
+			witness.Bytes[i][j] = (v >> (8 * j)) & 0xff
+		}
+	}
+
+	for _, bitDecomposition := range []bool{false, true} {
+		circuit := TestRangeCheckCircuit{BitDecomposition: bitDecomposition}
+		assert.SolvingSucceeded(&circuit, &witness, test.WithCurves(ecc.BN254), test.WithBackends(backend.PLONK))
+
+		invalid := witness
+		invalid.Input[0] = uint64(1) << 31
+		invalid.Bytes[0] = [4]frontend.Variable{0, 0, 0, 128}
+		assert.SolvingFailed(&circuit, &invalid, test.WithCurves(ecc.BN254), test.WithBackends(backend.PLONK))
+	}
+}
+
+type TestByteChecksCircuit struct {
+	Input            [1000]frontend.Variable
+	BitDecomposition bool `gnark:"-"`
+}
+
+func (circuit *TestByteChecksCircuit) Define(api frontend.API) error {
+	var chip *Chip
+	if circuit.BitDecomposition {
+		chip = NewChip(api, WithBitDecomposition())
+	} else {
+		chip = NewChip(api)
+	}
+
+	for i := 0; i < len(circuit.Input); i++ {
+		chip.RangeCheckF(Variable{Value: circuit.Input[i], NbBits: 31}, 8)
+	}
+
+	return nil
+}
+
+func TestByteChecksConstraints(t *testing.T) {
+	commit, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &TestByteChecksCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bits, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &TestByteChecksCircuit{BitDecomposition: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Logf("range checker: %d constraints, bit decomposition: %d constraints", commit.GetNbConstraints(), bits.GetNbConstraints())
+	if commit.GetNbConstraints() >= bits.GetNbConstraints() {
+		t.Fatalf("expected the range checker to be cheaper: %d >= %d", commit.GetNbConstraints(), bits.GetNbConstraints())
+	}
+}
+
+type TestAssertIsLessThanCircuit struct {
+	A, B    frontend.Variable
+	NbBitsA uint `gnark:"-"`
+}
+
+func (circuit *TestAssertIsLessThanCircuit) Define(api frontend.API) error {
+	chip := NewChip(api)
+	chip.AssertIsLessThanF(Variable{Value: circuit.A, NbBits: circuit.NbBitsA}, Variable{Value: circuit.B, NbBits: 31})
+	return nil
+}
+
+func TestAssertIsLessThan(t *testing.T) {
+	assert := test.NewAssert(t)
+	modulusPlusOne := new(big.Int).Add(MODULUS, big.NewInt(1))
+	for _, c := range []struct {
+		a, b    frontend.Variable
+		nbBitsA uint
+		isLess  bool
+	}{
+		{3, 9, 31, true},
+		{0, 2013265920, 31, true},
+		{7, 7, 31, false},
+		{9, 3, 31, false},
+		// The canonical value of a is 1.
+		{modulusPlusOne, 5, 32, true},
+		{modulusPlusOne, 1, 32, false},
+		// A non-canonical b is rejected.
+		{1, MODULUS, 31, false},
+	} {
+		circuit := TestAssertIsLessThanCircuit{NbBitsA: c.nbBitsA}
+		witness := TestAssertIsLessThanCircuit{A: c.a, B: c.b}
+		if c.isLess {
+			assert.SolvingSucceeded(&circuit, &witness, test.WithCurves(ecc.BN254), test.WithBackends(backend.PLONK))
+		} else {
+			assert.SolvingFailed(&circuit, &witness, test.WithCurves(ecc.BN254), test.WithBackends(backend.PLONK))
+		}
+	}
+}
+
+type TestMinMaxCircuit struct {
+	A, B     frontend.Variable `gnark:",public"`
+	Min, Max frontend.Variable
+	IsLess   frontend.Variable
+	NbBitsA  uint `gnark:"-"`
+}
+
+func (circuit *TestMinMaxCircuit) Define(api frontend.API) error {
+	chip := NewChip(api)
+	a := Variable{Value: circuit.A, NbBits: circuit.NbBitsA}
+	b := Variable{Value: circuit.B, NbBits: 31}
+
+	min, max := chip.MinMaxF(a, b)
+	chip.AssertIsEqualF(min, chip.MinF(a, b))
+	chip.AssertIsEqualF(max, chip.MaxF(a, b))
+	api.AssertIsEqual(min.Value, circuit.Min)
+	api.AssertIsEqual(max.Value, circuit.Max)
+	api.AssertIsEqual(chip.IsLessThanF(a, b), circuit.IsLess)
+
+	return nil
+}
+
+func TestMinMax(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	modulusPlusOne := new(big.Int).Add(MODULUS, big.NewInt(1))
+	cases := []struct {
+		a, b, min, max, isLess frontend.Variable
+		nbBitsA                uint
+	}{
+		{7, 7, 7, 7, 0, 31},
+		{3, 9, 3, 9, 1, 31},
+		{2013265920, 0, 0, 2013265920, 0, 31},
+		// The unreduced value of a is larger than b but its canonical value is smaller.
+		{modulusPlusOne, 5, 1, 5, 1, 32},
+		{modulusPlusOne, 1, 1, 1, 0, 32},
+	}
+
+	for _, c := range cases {
+		circuit := TestMinMaxCircuit{NbBitsA: c.nbBitsA}
+		witness := TestMinMaxCircuit{A: c.a, B: c.b, Min: c.min, Max: c.max, IsLess: c.isLess}
+		assert.SolvingSucceeded(&circuit, &witness, test.WithCurves(ecc.BN254), test.WithBackends(backend.PLONK))
+	}
+
+	// A non-canonical value must be rejected.
+	circuit := TestMinMaxCircuit{NbBitsA: 31}
+	witness := TestMinMaxCircuit{A: MODULUS, B: 5, Min: 5, Max: MODULUS, IsLess: 0}
+	assert.SolvingFailed(&circuit, &witness, test.WithCurves(ecc.BN254), test.WithBackends(backend.PLONK))
+}
+
+type TestReduceMaxBitsCircuit struct {
+	Input    [16]frontend.Variable `gnark:",public"`
+	Expected frontend.Variable
+	MaxBits  uint `gnark:"-"`
+}
+
+func (circuit *TestReduceMaxBitsCircuit) Define(api frontend.API) error {
+	chip := NewChip(api)
+
+	// Accumulate without reducing so that the tracked bound is much larger than the actual one.
+	sum := Variable{Value: 0, NbBits: 31}
+	for i := 0; i < len(circuit.Input); i++ {
+		sum = Variable{Value: api.Add(sum.Value, circuit.Input[i]), NbBits: 100}
+	}
+
+	reduced := chip.ReduceMaxBits(sum, circuit.MaxBits)
+	chip.AssertIsEqualF(reduced, chip.ReduceSlow(sum))
+	api.AssertIsEqual(reduced.Value, circuit.Expected)
+
+	return nil
+}
+
+func TestReduceMaxBits(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	sum := func(values [16]frontend.Variable) *big.Int {
+		total := new(big.Int)
+		for _, v := range values {
+			total.Add(total, v.(*big.Int))
+		}
+		return total.Mod(total, MODULUS)
+	}
+
+	var canonical, large [16]frontend.Variable
+	for i := 0; i < 16; i++ {
+		canonical[i] = new(big.Int).Sub(MODULUS, big.NewInt(int64(i+1)))
+		large[i] = new(big.Int).Lsh(big.NewInt(1), 39)
+	}
+
+	// A sum of 16 canonical felts is below 2^35.
+	for _, maxBits := range []uint{35, 40, 62, 100} {
+		circuit := TestReduceMaxBitsCircuit{MaxBits: maxBits}
+		witness := TestReduceMaxBitsCircuit{Input: canonical, Expected: sum(canonical)}
+		assert.SolvingSucceeded(&circuit, &witness, test.WithCurves(ecc.BN254), test.WithBackends(backend.PLONK))
+	}
+
+	// The sum of the large inputs is 2^43, so a bound of 40 bits is understated.
+	circuit := TestReduceMaxBitsCircuit{MaxBits: 44}
+	witness := TestReduceMaxBitsCircuit{Input: large, Expected: sum(large)}
+	assert.SolvingSucceeded(&circuit, &witness, test.WithCurves(ecc.BN254), test.WithBackends(backend.PLONK))
+	circuit = TestReduceMaxBitsCircuit{MaxBits: 40}
+	assert.SolvingFailed(&circuit, &witness, test.WithCurves(ecc.BN254), test.WithBackends(backend.PLONK))
+}
+
+type TestReduceConstraintsCircuit struct {
+	Input   [100]frontend.Variable
+	MaxBits uint `gnark:"-"`
+}
+
+func (circuit *TestReduceConstraintsCircuit) Define(api frontend.API) error {
+	chip := NewChip(api)
+	for i := 0; i < len(circuit.Input); i++ {
+		x := Variable{Value: circuit.Input[i], NbBits: 100}
+		if circuit.MaxBits == 0 {
+			chip.ReduceSlow(x)
+		} else {
+			chip.ReduceMaxBits(x, circuit.MaxBits)
+		}
+	}
+	return nil
+}
+
+func TestReduceMaxBitsConstraints(t *testing.T) {
+	generic, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &TestReduceConstraintsCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bounded, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &TestReduceConstraintsCircuit{MaxBits: 35})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Logf("generic: %d constraints, bounded: %d constraints", generic.GetNbConstraints(), bounded.GetNbConstraints())
+	if bounded.GetNbConstraints() >= generic.GetNbConstraints() {
+		t.Fatalf("expected the bounded reduction to be cheaper: %d >= %d", bounded.GetNbConstraints(), generic.GetNbConstraints())
+	}
+}
+
+var addSubHint = NewExtHint("test.AddSub", func(field *Field, inputs []*big.Int) ([4]*big.Int, error) {
+	var out [4]*big.Int
+	for i := 0; i < 4; i++ {
+		out[i] = new(big.Int).Add(inputs[i], inputs[4+i])
+		out[i].Sub(out[i], inputs[8+i])
+		out[i].Mod(out[i], field.Modulus)
+	}
+	return out, nil
+})
+
+var failingHint = NewExtHint("test.Failing", func(_ *Field, inputs []*big.Int) ([4]*big.Int, error) {
+	return [4]*big.Int{}, fmt.Errorf("cannot compute %s", inputs[0])
+})
+
+type TestExtHintCircuit struct {
+	A, B, C [4]frontend.Variable
+	Fail    bool `gnark:"-"`
+}
+
+func (circuit *TestExtHintCircuit) Define(api frontend.API) error {
+	chip := NewChip(api)
+	a := newTestExt(circuit.A)
+	b := newTestExt(circuit.B)
+	c := newTestExt(circuit.C)
+
+	if circuit.Fail {
+		chip.CallExtHint(failingHint, a)
+		return nil
+	}
+
+	// Unreduced inputs must be reduced before being passed to the hint.
+	sum := chip.AddE(chip.AddE(a, b), a)
+	out := chip.CallExtHint(addSubHint, sum, b, c)
+	chip.AssertIsEqualE(out, chip.SubE(chip.AddE(sum, b), c))
+	return nil
+}
+
+func newTestExt(values [4]frontend.Variable) ExtensionVariable {
+	var out ExtensionVariable
+	for i := 0; i < 4; i++ {
+		out.Value[i] = Variable{Value: values[i], NbBits: 31}
+	}
+	return out
+}
+
+func TestExtHint(t *testing.T) {
+	assert := test.NewAssert(t)
+	witness := TestExtHintCircuit{
+		A: [4]frontend.Variable{"2013265920", "1", "2", "3"},
+		B: [4]frontend.Variable{"5", "2013265919", "7", "8"},
+		C: [4]frontend.Variable{"9", "10", "2013265900", "12"},
+	}
+	assert.SolvingSucceeded(&TestExtHintCircuit{}, &witness, test.WithCurves(ecc.BN254), test.WithBackends(backend.PLONK))
+
+	witness.Fail = true
+	assert.SolvingFailed(&TestExtHintCircuit{Fail: true}, &witness, test.WithCurves(ecc.BN254), test.WithBackends(backend.PLONK))
+}
+
+func TestExtHintNameCollision(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected registering a duplicate extension hint to panic")
+		}
+	}()
+	NewExtHint("test.AddSub", nil)
+}
+
+type TestInvECircuit struct {
+	In [4]frontend.Variable
+}
+
+func (circuit *TestInvECircuit) Define(api frontend.API) error {
+	NewChip(api).InvE(newTestExt(circuit.In))
+	return nil
+}
+
+func TestInvEZero(t *testing.T) {
+	// Like InvF, InvE has no solution on zero.
+	assert := test.NewAssert(t)
+	assert.SolvingSucceeded(&TestInvECircuit{}, &TestInvECircuit{In: [4]frontend.Variable{0, 0, 1, 0}}, test.WithCurves(ecc.BN254), test.WithBackends(backend.PLONK))
+	assert.SolvingFailed(&TestInvECircuit{}, &TestInvECircuit{In: [4]frontend.Variable{0, 0, 0, 0}}, test.WithCurves(ecc.BN254), test.WithBackends(backend.PLONK))
+}
+
+type TestMulEConstraintsCircuit struct {
+	A, B  [4]frontend.Variable
+	Tower bool `gnark:"-"`
+}
+
+func (circuit *TestMulEConstraintsCircuit) Define(api frontend.API) error {
+	chip := NewChip(api)
+	if circuit.Tower {
+		chip = NewChip(api, WithTowerExtension())
+	}
+	acc := newTestExt(circuit.A)
+	for i := 0; i < 16; i++ {
+		acc = chip.MulE(acc, newTestExt(circuit.B))
+	}
+	chip.AssertIsEqualE(acc, newTestExt(circuit.A))
+	return nil
+}
+
+func TestMulEConstraints(t *testing.T) {
+	flat, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &TestMulEConstraintsCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tower, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &TestMulEConstraintsCircuit{Tower: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("16 MulE: flat %d constraints, tower %d constraints", flat.GetNbConstraints(), tower.GetNbConstraints())
+}
+
+type TestConstantFoldingCircuit struct {
+	A [4]frontend.Variable
+	// Constant runs the operations on constants of the circuit only.
+	Constant bool `gnark:"-"`
+}
+
+func (circuit *TestConstantFoldingCircuit) Define(api frontend.API) error {
+	chip := NewChip(api)
+	a := NewE([]string{"1", "2", "3", "4"})
+	if !circuit.Constant {
+		a = newTestExt(circuit.A)
+	}
+	b := NewE([]string{"2013265920", "17", "0", "123456789"})
+
+	product := chip.MulE(a, b)
+	chip.AssertIsEqualE(chip.DivE(product, b), a)
+	chip.AssertIsEqualE(chip.MulE(chip.InvE(b), product), a)
+	x := chip.MulF(chip.AddF(a.Value[0], b.Value[1]), b.Value[0])
+	chip.AssertIsEqualF(chip.MulF(chip.DivF(x, b.Value[0]), chip.InvF(b.Value[1])), chip.AddF(chip.MulF(a.Value[0], chip.InvF(b.Value[1])), NewF("1")))
+	return nil
+}
+
+func TestConstantFolding(t *testing.T) {
+	assert := test.NewAssert(t)
+	assert.SolvingSucceeded(&TestConstantFoldingCircuit{}, &TestConstantFoldingCircuit{A: [4]frontend.Variable{1, 2, 3, 4}}, test.WithCurves(ecc.BN254), test.WithBackends(backend.PLONK))
+
+	folded, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &TestConstantFoldingCircuit{Constant: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if folded.GetNbConstraints() != 0 {
+		t.Errorf("expected the operations on constants to be folded, got %d constraints", folded.GetNbConstraints())
+	}
+}
+
+type TestMulEChainConstraintsCircuit struct {
+	A          [4]frontend.Variable
+	Schoolbook bool `gnark:"-"`
+	Tower      bool `gnark:"-"`
+}
+
+// schoolbookMulE is the former MulE, which reduced each of its 16 products and sums.
+func schoolbookMulE(c *Chip, a, b ExtensionVariable) ExtensionVariable {
+	v2 := [4]Variable{NewF("0"), NewF("0"), NewF("0"), NewF("0")}
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			if i+j >= 4 {
+				v2[i+j-4] = c.AddF(v2[i+j-4], c.MulFConst(c.MulF(a.Value[i], b.Value[j]), 11))
+			} else {
+				v2[i+j] = c.AddF(v2[i+j], c.MulF(a.Value[i], b.Value[j]))
+			}
+		}
+	}
+	return ExtensionVariable{Value: v2}
+}
+
+func (circuit *TestMulEChainConstraintsCircuit) Define(api frontend.API) error {
+	chip := NewChip(api)
+	if circuit.Tower {
+		chip = NewChip(api, WithTowerExtension())
+	}
+	a := newTestExt(circuit.A)
+	acc := a
+	for i := 0; i < 100; i++ {
+		if circuit.Schoolbook {
+			acc = schoolbookMulE(chip, acc, a)
+		} else {
+			acc = chip.MulE(acc, a)
+		}
+	}
+	chip.AssertIsEqualE(acc, a)
+	return nil
+}
+
+func TestMulEChainConstraints(t *testing.T) {
+	var nbConstraints [2]int
+	for i, schoolbook := range []bool{false, true} {
+		ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &TestMulEChainConstraintsCircuit{Schoolbook: schoolbook})
+		if err != nil {
+			t.Fatal(err)
+		}
+		nbConstraints[i] = ccs.GetNbConstraints()
+	}
+	t.Logf("100 chained MulE: %d constraints, %d with schoolbook reductions", nbConstraints[0], nbConstraints[1])
+	if nbConstraints[0] >= nbConstraints[1] {
+		t.Errorf("expected the lazy MulE to be cheaper than the schoolbook one")
+	}
+}
+
+// BenchmarkMulE reports the constraints of a MulE in a chain of 100, for the schoolbook MulE that
+// reduced each of its products, the flat MulE and the Karatsuba MulE of the tower representation.
+func BenchmarkMulE(b *testing.B) {
+	for _, variant := range []struct {
+		name    string
+		circuit TestMulEChainConstraintsCircuit
+	}{
+		{"schoolbook", TestMulEChainConstraintsCircuit{Schoolbook: true}},
+		{"flat", TestMulEChainConstraintsCircuit{}},
+		{"tower", TestMulEChainConstraintsCircuit{Tower: true}},
+	} {
+		b.Run(variant.name, func(b *testing.B) {
+			var nbConstraints int
+			for i := 0; i < b.N; i++ {
+				circuit := variant.circuit
+				ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &circuit)
+				if err != nil {
+					b.Fatal(err)
+				}
+				nbConstraints = ccs.GetNbConstraints()
+			}
+			b.ReportMetric(float64(nbConstraints)/100, "constraints/MulE")
+		})
+	}
+}
+
+// testTable returns n pseudo-random canonical entries, and their extension valued counterpart.
+func testTable(n int) ([]uint64, [][4]uint64) {
+	rng := rand.New(rand.NewSource(233))
+	table := make([]uint64, n)
+	tableE := make([][4]uint64, n)
+	for i := range table {
+		table[i] = rng.Uint64() % MODULUS.Uint64()
+		for j := 0; j < 4; j++ {
+			tableE[i][j] = rng.Uint64() % MODULUS.Uint64()
+		}
+	}
+	return table, tableE
+}
+
+type TestLookupConstTableCircuit struct {
+	Index     frontend.Variable
+	Expected  frontend.Variable
+	ExpectedE [4]frontend.Variable
+	Table     []uint64    `gnark:"-"`
+	TableE    [][4]uint64 `gnark:"-"`
+	Mux       bool        `gnark:"-"`
+}
+
+func (circuit *TestLookupConstTableCircuit) Define(api frontend.API) error {
+	chip := NewChip(api)
+	if circuit.Mux {
+		chip = NewChip(api, WithMuxLookups())
+	}
+	index := Variable{Value: circuit.Index, NbBits: 31}
+	expected := Variable{Value: circuit.Expected, NbBits: 31}
+	// The second read goes through the table built by the first one.
+	chip.AssertIsEqualF(chip.LookupConstTable(circuit.Table, index), expected)
+	chip.AssertIsEqualF(chip.LookupConstTable(circuit.Table, index), expected)
+	chip.AssertIsEqualE(chip.LookupConstTableE(circuit.TableE, index), newTestExt(circuit.ExpectedE))
+	return nil
+}
+
+func TestLookupConstTable(t *testing.T) {
+	assert := test.NewAssert(t)
+	table, tableE := testTable(1000)
+	for _, mux := range []bool{false, true} {
+		circuit := TestLookupConstTableCircuit{Table: table, TableE: tableE, Mux: mux}
+		for _, index := range []int{0, 1, 617, 999} {
+			witness := TestLookupConstTableCircuit{Index: index, Expected: table[index]}
+			for j := 0; j < 4; j++ {
+				witness.ExpectedE[j] = tableE[index][j]
+			}
+			assert.SolvingSucceeded(&circuit, &witness, test.WithCurves(ecc.BN254), test.WithBackends(backend.PLONK))
+		}
+
+		// Indices past the end of the table, including the negative ones, are rejected.
+		for _, index := range []uint64{1000, 1024, MODULUS.Uint64() - 1} {
+			witness := TestLookupConstTableCircuit{Index: index, Expected: 0, ExpectedE: [4]frontend.Variable{0, 0, 0, 0}}
+			assert.SolvingFailed(&circuit, &witness, test.WithCurves(ecc.BN254), test.WithBackends(backend.PLONK))
+		}
+	}
+}
+
+type TestLookupConstTableConstraintsCircuit struct {
+	Index [16]frontend.Variable
+	Reads int      `gnark:"-"`
+	Table []uint64 `gnark:"-"`
+	Mux   bool     `gnark:"-"`
+}
+
+func (circuit *TestLookupConstTableConstraintsCircuit) Define(api frontend.API) error {
+	chip := NewChip(api)
+	if circuit.Mux {
+		chip = NewChip(api, WithMuxLookups())
+	}
+	for i := 0; i < circuit.Reads; i++ {
+		chip.LookupConstTable(circuit.Table, Variable{Value: circuit.Index[i], NbBits: 31})
+	}
+	return nil
+}
+
+func TestLookupConstTableConstraints(t *testing.T) {
+	table, _ := testTable(1024)
+	nbConstraints := make(map[bool]map[int]int)
+	for _, mux := range []bool{false, true} {
+		nbConstraints[mux] = make(map[int]int)
+		for _, reads := range []int{1, 16} {
+			compiled, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &TestLookupConstTableConstraintsCircuit{Reads: reads, Table: table, Mux: mux})
+			if err != nil {
+				t.Fatal(err)
+			}
+			nbConstraints[mux][reads] = compiled.GetNbConstraints()
+		}
+	}
+	t.Logf("1024-entry table, 1 read: lookup %d constraints, mux %d constraints", nbConstraints[false][1], nbConstraints[true][1])
+	t.Logf("1024-entry table, 16 reads: lookup %d constraints, mux %d constraints", nbConstraints[false][16], nbConstraints[true][16])
+
+	// The lookup pays for the table once, so it only wins when the table is read several times.
+	if nbConstraints[false][16] >= nbConstraints[true][16] {
+		t.Fatalf("expected 16 lookups to use fewer constraints than 16 muxes")
+	}
+}
+
+type TestSelectFromSliceCircuit struct {
+	Index     frontend.Variable
+	Values    []frontend.Variable
+	ValuesE   [][4]frontend.Variable
+	Expected  frontend.Variable
+	ExpectedE [4]frontend.Variable
+}
+
+func (circuit *TestSelectFromSliceCircuit) Define(api frontend.API) error {
+	chip := NewChip(api)
+	indexBits := api.ToBinary(circuit.Index, bits.Len(uint(len(circuit.Values)-1)))
+	values := make([]Variable, len(circuit.Values))
+	for i, v := range circuit.Values {
+		values[i] = Variable{Value: v, NbBits: 31}
+	}
+	valuesE := make([]ExtensionVariable, len(circuit.ValuesE))
+	for i, v := range circuit.ValuesE {
+		valuesE[i] = newTestExt(v)
+	}
+	chip.AssertIsEqualF(chip.SelectFFromSlice(indexBits, values), Variable{Value: circuit.Expected, NbBits: 31})
+	chip.AssertIsEqualE(chip.SelectEFromSlice(indexBits, valuesE), newTestExt(circuit.ExpectedE))
+	return nil
+}
+
+func TestSelectFromSlice(t *testing.T) {
+	assert := test.NewAssert(t)
+	for _, n := range []int{9, 16} {
+		table, tableE := testTable(n)
+		circuit := TestSelectFromSliceCircuit{Values: make([]frontend.Variable, n), ValuesE: make([][4]frontend.Variable, n)}
+		witness := func(index int) *TestSelectFromSliceCircuit {
+			w := TestSelectFromSliceCircuit{Index: index, Values: make([]frontend.Variable, n), ValuesE: make([][4]frontend.Variable, n)}
+			for i := range table {
+				w.Values[i] = table[i]
+				for j := 0; j < 4; j++ {
+					w.ValuesE[i][j] = tableE[i][j]
+				}
+			}
+			w.Expected = 0
+			w.ExpectedE = [4]frontend.Variable{0, 0, 0, 0}
+			if index < n {
+				w.Expected = table[index]
+				for j := 0; j < 4; j++ {
+					w.ExpectedE[j] = tableE[index][j]
+				}
+			}
+			return &w
+		}
+		for index := 0; index < n; index++ {
+			assert.SolvingSucceeded(&circuit, witness(index), test.WithCurves(ecc.BN254), test.WithBackends(backend.PLONK))
+		}
+
+		// The indices past the end of the shorter slice still fit in its 4 index bits, and are
+		// rejected whatever the expected values.
+		for index := n; index < 1<<bits.Len(uint(n-1)); index++ {
+			assert.SolvingFailed(&circuit, witness(index), test.WithCurves(ecc.BN254), test.WithBackends(backend.PLONK))
+			w := witness(index)
+			w.Expected, w.ExpectedE = w.Values[n-1], w.ValuesE[n-1]
+			assert.SolvingFailed(&circuit, w, test.WithCurves(ecc.BN254), test.WithBackends(backend.PLONK))
+		}
+	}
+}
+
+type TestToBinaryStrictCircuit struct {
+	Input    frontend.Variable
+	Expected frontend.Variable `gnark:",public"`
+	Strict   bool              `gnark:"-"`
+}
+
+func (circuit *TestToBinaryStrictCircuit) Define(api frontend.API) error {
+	chip := NewChip(api)
+	x := Variable{Value: circuit.Input, NbBits: 64}
+	bits := chip.ToBinary(x)
+	if circuit.Strict {
+		bits = chip.ToBinaryStrict(x)
+	}
+	api.AssertIsEqual(api.FromBinary(bits...), circuit.Expected)
+	return nil
+}
+
+// aliasReduceHint is ReduceHint for a malicious prover, which returns the remainder plus the
+// modulus whenever it still fits in 31 bits.
+func aliasReduceHint(field *big.Int, inputs []*big.Int, results []*big.Int) error {
+	if err := ReduceHint(field, inputs, results); err != nil {
+		return err
+	}
+	if alias := new(big.Int).Add(results[1], MODULUS); alias.BitLen() <= 31 && results[0].Sign() > 0 {
+		results[0] = new(big.Int).Sub(results[0], big.NewInt(1))
+		results[1] = alias
+	}
+	return nil
+}
+
+func TestToBinaryStrict(t *testing.T) {
+	// 5 + 3p reduces to 5, which the malicious prover decomposes as the bits of 5 + p.
+	input := new(big.Int).Add(big.NewInt(5), new(big.Int).Mul(MODULUS, big.NewInt(3)))
+	alias := new(big.Int).Add(big.NewInt(5), MODULUS)
+	override := solver.OverrideHint(solver.GetHintID(ReduceHint), aliasReduceHint)
+
+	for _, strict := range []bool{false, true} {
+		ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &TestToBinaryStrictCircuit{Strict: strict})
+		if err != nil {
+			t.Fatal(err)
+		}
+		solve := func(expected *big.Int, opts ...solver.Option) error {
+			w, err := frontend.NewWitness(&TestToBinaryStrictCircuit{Input: input, Expected: expected}, ecc.BN254.ScalarField())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return ccs.IsSolved(w, opts...)
+		}
+
+		if err := solve(big.NewInt(5)); err != nil {
+			t.Errorf("strict=%t: the canonical decomposition is rejected: %v", strict, err)
+		}
+		err = solve(alias, override)
+		if !strict && err != nil {
+			t.Errorf("expected ToBinary to accept the decomposition of %s, got %v", alias, err)
+		}
+		if strict && err == nil {
+			t.Errorf("expected ToBinaryStrict to reject the decomposition of %s", alias)
+		}
+	}
+}
+
+func FuzzNewFChecked(f *testing.F) {
+	for _, seed := range []string{"0", "1", "2013265920", "2013265921", "-1", "+7", "", "0x10", "1e9", "99999999999999999999"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, value string) {
+		v, err := NewFChecked(value)
+		if err != nil {
+			return
+		}
+		parsed, ok := new(big.Int).SetString(v.Value.(string), 10)
+		if !ok || parsed.Sign() < 0 || parsed.Cmp(MODULUS) >= 0 || v.NbBits != 31 {
+			t.Fatalf("%q: accepted as %v", value, v)
+		}
+	})
+}
+
+func FuzzNewEChecked(f *testing.F) {
+	for _, seed := range []string{"1,2,3,4", "0,0,0,2013265920", "1,2,3", "1,2,3,4,5", "1,,3,4", "1,2,3,2013265921", ""} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, value string) {
+		coordinates := strings.Split(value, ",")
+		e, err := NewEChecked(coordinates)
+		if err != nil {
+			return
+		}
+		for i, v := range e.Value {
+			if expected, err := NewFChecked(coordinates[i]); err != nil || expected != v {
+				t.Fatalf("%q: coordinate %d accepted as %v", value, i, v)
+			}
+		}
+	})
+}
+
+type TestSplitIntoBabyBearCircuit struct {
+	Input frontend.Variable
+	Limbs [NUM_ELMS_PER_BN254_ELM]frontend.Variable `gnark:",public"`
+}
+
+func (circuit *TestSplitIntoBabyBearCircuit) Define(api frontend.API) error {
+	chip := NewChip(api)
+	limbs := chip.SplitIntoBabyBear(circuit.Input)
+	var acc frontend.Variable = 0
+	for i, limb := range limbs {
+		api.AssertIsEqual(limb.Value, circuit.Limbs[i])
+		acc = api.Add(api.Mul(acc, uint64(1)<<bn254LimbBits), limb.Value)
+	}
+	api.AssertIsEqual(acc, circuit.Input)
+
+	var packed [NUM_ELMS_PER_BN254_ELM]Variable
+	for i, limb := range circuit.Limbs {
+		packed[i] = Variable{Value: limb, NbBits: 31}
+	}
+	api.AssertIsEqual(chip.PackIntoBN254(packed), circuit.Input)
+	return nil
+}
+
+// splitAssignment packs the limbs like babybears_to_bn254 and returns the assignment of the split.
+func splitAssignment(limbs [NUM_ELMS_PER_BN254_ELM]*big.Int) *TestSplitIntoBabyBearCircuit {
+	assignment := &TestSplitIntoBabyBearCircuit{}
+	input := new(big.Int)
+	for i, limb := range limbs {
+		input.Lsh(input, bn254LimbBits).Add(input, limb)
+		assignment.Limbs[i] = limb
+	}
+	assignment.Input = input
+	return assignment
+}
+
+func TestSplitIntoBabyBear(t *testing.T) {
+	maxLimb := new(big.Int).Sub(MODULUS, big.NewInt(1))
+	var zero, max, random [NUM_ELMS_PER_BN254_ELM]*big.Int
+	rng := rand.New(rand.NewSource(0))
+	for i := range zero {
+		zero[i] = new(big.Int)
+		max[i] = maxLimb
+		random[i] = new(big.Int).Rand(rng, MODULUS)
+	}
+	assert := test.NewAssert(t)
+	for _, curve := range []ecc.ID{ecc.BN254, ecc.BLS12_381, ecc.BW6_761} {
+		for _, limbs := range [][NUM_ELMS_PER_BN254_ELM]*big.Int{zero, max, random} {
+			assert.CheckCircuit(&TestSplitIntoBabyBearCircuit{}, test.WithValidAssignment(splitAssignment(limbs)), test.WithCurves(curve))
+		}
+
+		// A limb of the modulus is not a canonical BabyBear element.
+		nonCanonical := random
+		nonCanonical[3] = MODULUS
+		assert.CheckCircuit(&TestSplitIntoBabyBearCircuit{}, test.WithInvalidAssignment(splitAssignment(nonCanonical)), test.WithCurves(curve))
+
+		// An element of more than 248 bits is not the packing of a digest.
+		tooLarge := splitAssignment(random)
+		tooLarge.Input = new(big.Int).Lsh(big.NewInt(1), NUM_ELMS_PER_BN254_ELM*bn254LimbBits)
+		assert.CheckCircuit(&TestSplitIntoBabyBearCircuit{}, test.WithInvalidAssignment(tooLarge), test.WithCurves(curve))
+	}
+}
+
+type TestExpFConstraintsCircuit struct {
+	X, Y     frontend.Variable
+	Exponent int64 `gnark:"-"`
+}
+
+func (circuit *TestExpFConstraintsCircuit) Define(api frontend.API) error {
+	chip := NewChip(api)
+	x := Variable{Value: circuit.X, NbBits: 31}
+	if circuit.Exponent >= 0 {
+		x = chip.ExpF(x, big.NewInt(circuit.Exponent))
+	}
+	chip.AssertIsEqualF(x, Variable{Value: circuit.Y, NbBits: 31})
+	return nil
+}
+
+func TestExpFConstraints(t *testing.T) {
+	nbConstraints := func(exponent int64) int {
+		ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &TestExpFConstraintsCircuit{Exponent: exponent})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ccs.GetNbConstraints()
+	}
+	// x^0 and x^1 are constants and copies, so they cost no more than no exponentiation at all.
+	baseline := nbConstraints(-1)
+	for _, exponent := range []int64{0, 1} {
+		if n := nbConstraints(exponent); n > baseline {
+			t.Errorf("x^%d: %d constraints, expected at most %d", exponent, n, baseline)
+		}
+	}
+}
+
+type TestBatchInvCircuit struct {
+	In         [][4]frontend.Variable
+	Montgomery bool `gnark:"-"`
+}
+
+// montgomeryInv is Montgomery's trick: a single inversion of the product of the values, and
+// prefix products to recover each inverse.
+func montgomeryInv[T any](values []T, mul func(a, b T) T, inv func(a T) T) []T {
+	out := make([]T, len(values))
+	if len(values) == 0 {
+		return out
+	}
+	prefixes := make([]T, len(values))
+	prefixes[0] = values[0]
+	for i := 1; i < len(values); i++ {
+		prefixes[i] = mul(prefixes[i-1], values[i])
+	}
+	acc := inv(prefixes[len(values)-1])
+	for i := len(values) - 1; i > 0; i-- {
+		out[i] = mul(acc, prefixes[i-1])
+		acc = mul(acc, values[i])
+	}
+	out[0] = acc
+	return out
+}
+
+func (circuit *TestBatchInvCircuit) Define(api frontend.API) error {
+	chip := NewChip(api)
+	felts := make([]Variable, len(circuit.In))
+	exts := make([]ExtensionVariable, len(circuit.In))
+	for i, in := range circuit.In {
+		felts[i] = Variable{Value: in[0], NbBits: 31}
+		exts[i] = newTestExt(in)
+	}
+	var feltInvs []Variable
+	var extInvs []ExtensionVariable
+	if circuit.Montgomery {
+		feltInvs = montgomeryInv(felts, chip.MulF, chip.InvF)
+		extInvs = montgomeryInv(exts, chip.MulE, chip.InvE)
+	} else {
+		feltInvs = chip.BatchInvF(felts)
+		extInvs = chip.BatchInvE(exts)
+	}
+	for i := range circuit.In {
+		chip.AssertIsEqualF(chip.MulF(felts[i], feltInvs[i]), NewF("1"))
+		chip.AssertIsEqualE(chip.MulE(exts[i], extInvs[i]), NewE([]string{"1", "0", "0", "0"}))
+	}
+	return nil
+}
+
+func TestBatchInv(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	for _, n := range []int{0, 1, 8} {
+		for _, montgomery := range []bool{false, true} {
+			assignment := &TestBatchInvCircuit{In: make([][4]frontend.Variable, n)}
+			for i := range assignment.In {
+				for j := range assignment.In[i] {
+					assignment.In[i][j] = 1 + rng.Int63n(MODULUS.Int64()-1)
+				}
+			}
+			circuit := &TestBatchInvCircuit{In: make([][4]frontend.Variable, n), Montgomery: montgomery}
+			if err := test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()); err != nil {
+				t.Errorf("n=%d, montgomery=%t: %v", n, montgomery, err)
+			}
+			if n > 0 {
+				assignment.In[n-1] = [4]frontend.Variable{0, 0, 0, 0}
+				if err := test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()); err == nil {
+					t.Errorf("n=%d, montgomery=%t: expected a zero value to be rejected", n, montgomery)
+				}
+			}
+		}
+	}
+}
+
+func TestBatchInvConstraints(t *testing.T) {
+	for _, n := range []int{4, 16} {
+		var nbConstraints [2]int
+		for i, montgomery := range []bool{false, true} {
+			ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &TestBatchInvCircuit{In: make([][4]frontend.Variable, n), Montgomery: montgomery})
+			if err != nil {
+				t.Fatal(err)
+			}
+			nbConstraints[i] = ccs.GetNbConstraints()
+		}
+		t.Logf("%d inversions: batch %d constraints, Montgomery %d constraints", n, nbConstraints[0], nbConstraints[1])
+		if nbConstraints[0] >= nbConstraints[1] {
+			t.Errorf("%d inversions: expected the batch to be cheaper than Montgomery's trick", n)
+		}
+	}
+}
+
+type TestDivCircuit struct {
+	A, B [4]frontend.Variable
+}
+
+func (circuit *TestDivCircuit) Define(api frontend.API) error {
+	chip := NewChip(api)
+	a, b := newTestExt(circuit.A), newTestExt(circuit.B)
+	chip.DivF(a.Value[0], b.Value[0])
+	chip.DivE(a, b)
+	return nil
+}
+
+// wrongDivFHint and wrongDivEHint return the quotient plus one.
+func wrongDivFHint(field *big.Int, inputs []*big.Int, results []*big.Int) error {
+	if err := DivFHint(field, inputs, results); err != nil {
+		return err
+	}
+	results[0].Add(results[0], big.NewInt(1)).Mod(results[0], MODULUS)
+	return nil
+}
+
+func wrongDivEHint(field *big.Int, inputs []*big.Int, results []*big.Int) error {
+	if err := ExtHintDispatcher(field, inputs, results); err != nil {
+		return err
+	}
+	if uint32(inputs[0].Uint64()) == divEHint.key {
+		results[0].Add(results[0], big.NewInt(1)).Mod(results[0], MODULUS)
+	}
+	return nil
+}
+
+func TestDiv(t *testing.T) {
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &TestDivCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrongF := solver.OverrideHint(solver.GetHintID(DivFHint), wrongDivFHint)
+	wrongE := solver.OverrideHint(solver.GetHintID(ExtHintDispatcher), wrongDivEHint)
+	for _, tc := range []struct {
+		name  string
+		a, b  [4]frontend.Variable
+		opts  []solver.Option
+		valid bool
+	}{
+		{"quotient", [4]frontend.Variable{5, 6, 7, 8}, [4]frontend.Variable{3, 0, 1, 2}, nil, true},
+		{"zero dividend", [4]frontend.Variable{0, 0, 0, 0}, [4]frontend.Variable{3, 0, 1, 2}, nil, true},
+		// 0 / 0 would accept any quotient.
+		{"zero divisor", [4]frontend.Variable{0, 0, 0, 0}, [4]frontend.Variable{0, 0, 0, 0}, nil, false},
+		{"zero divisor felt", [4]frontend.Variable{0, 6, 7, 8}, [4]frontend.Variable{0, 1, 0, 0}, nil, false},
+		{"wrong felt quotient", [4]frontend.Variable{5, 6, 7, 8}, [4]frontend.Variable{3, 0, 1, 2}, []solver.Option{wrongF}, false},
+		{"wrong extension quotient", [4]frontend.Variable{5, 6, 7, 8}, [4]frontend.Variable{3, 0, 1, 2}, []solver.Option{wrongE}, false},
+	} {
+		w, err := frontend.NewWitness(&TestDivCircuit{A: tc.a, B: tc.b}, ecc.BN254.ScalarField())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ccs.IsSolved(w, tc.opts...); (err == nil) != tc.valid {
+			t.Errorf("%s: expected valid=%t, got %v", tc.name, tc.valid, err)
+		}
+	}
+}
+
+type TestInvCircuit struct {
+	F frontend.Variable
+	E [4]frontend.Variable
+}
+
+func (circuit *TestInvCircuit) Define(api frontend.API) error {
+	chip := NewChip(api)
+	chip.InvF(Variable{Value: circuit.F, NbBits: 31})
+	chip.InvE(newTestExt(circuit.E))
+	return nil
+}
+
+// wrappedInvFHint and wrappedInvEHint return a value whose product with the input is 1 + p in the
+// native field, which reduces to 1 unless the value is range checked.
+func wrappedInvFHint(field *big.Int, inputs []*big.Int, results []*big.Int) error {
+	inverse := new(big.Int).ModInverse(inputs[0], field)
+	results[0].Mul(inverse, new(big.Int).Add(MODULUS, big.NewInt(1))).Mod(results[0], field)
+	return nil
+}
+
+func wrappedInvEHint(field *big.Int, inputs []*big.Int, results []*big.Int) error {
+	if err := ExtHintDispatcher(field, inputs, results); err != nil {
+		return err
+	}
+	// The inverse of a felt embedded in the extension only has a constant coordinate.
+	if uint32(inputs[0].Uint64()) == invEHint.key {
+		return wrappedInvFHint(field, inputs[1:2], results[:1])
+	}
+	return nil
+}
+
+func TestInvRangeChecksInverse(t *testing.T) {
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &TestInvCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrappedF := solver.OverrideHint(solver.GetHintID(InvFHint), wrappedInvFHint)
+	wrappedE := solver.OverrideHint(solver.GetHintID(ExtHintDispatcher), wrappedInvEHint)
+	for _, tc := range []struct {
+		name  string
+		opts  []solver.Option
+		valid bool
+	}{
+		{"inverse", nil, true},
+		{"wrapped felt inverse", []solver.Option{wrappedF}, false},
+		{"wrapped extension inverse", []solver.Option{wrappedE}, false},
+	} {
+		w, err := frontend.NewWitness(&TestInvCircuit{F: 7, E: [4]frontend.Variable{3, 0, 0, 0}}, ecc.BN254.ScalarField())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ccs.IsSolved(w, tc.opts...); (err == nil) != tc.valid {
+			t.Errorf("%s: expected valid=%t, got %v", tc.name, tc.valid, err)
+		}
+	}
+}
+
+type TestDivConstraintsCircuit struct {
+	A, B    [4]frontend.Variable
+	Inverse bool `gnark:"-"`
+}
+
+func (circuit *TestDivConstraintsCircuit) Define(api frontend.API) error {
+	chip := NewChip(api)
+	a, b := newTestExt(circuit.A), newTestExt(circuit.B)
+	f := a.Value[0]
+	for i := 0; i < 10; i++ {
+		if circuit.Inverse {
+			f = chip.MulF(f, chip.InvF(b.Value[0]))
+			a = chip.MulE(a, chip.InvE(b))
+		} else {
+			f = chip.DivF(f, b.Value[0])
+			a = chip.DivE(a, b)
+		}
+		// The next dividend is unreduced.
+		f = chip.AddF(f, b.Value[0])
+		a = chip.AddE(a, b)
+	}
+	chip.AssertIsEqualF(f, a.Value[0])
+	return nil
+}
+
+func TestDivConstraints(t *testing.T) {
+	var nbConstraints [2]int
+	for i, inverse := range []bool{false, true} {
+		ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &TestDivConstraintsCircuit{Inverse: inverse})
+		if err != nil {
+			t.Fatal(err)
+		}
+		nbConstraints[i] = ccs.GetNbConstraints()
+	}
+	t.Logf("10 chained DivF and DivE: %d constraints, %d with an inversion and a multiplication", nbConstraints[0], nbConstraints[1])
+	if nbConstraints[0] >= nbConstraints[1] {
+		t.Errorf("expected the division to be cheaper than the inversion and multiplication")
+	}
+}
+
+type TestNum2BitsCircuit struct {
+	Input frontend.Variable
+	N     int `gnark:"-"`
+}
+
+func (circuit *TestNum2BitsCircuit) Define(api frontend.API) error {
+	chip := NewChip(api)
+	in := Variable{Value: circuit.Input, NbBits: 31}
+	bits := chip.Num2Bits(in, circuit.N)
+	if len(bits) != circuit.N {
+		return fmt.Errorf("expected %d bits, got %d", circuit.N, len(bits))
+	}
+	chip.AssertIsEqualF(chip.Bits2Num(bits), in)
+	return nil
+}
+
+func TestNum2Bits(t *testing.T) {
+	maxCanonical := new(big.Int).Sub(MODULUS, big.NewInt(1))
+	for _, tc := range []struct {
+		n     int
+		input *big.Int
+		valid bool
+	}{
+		{31, maxCanonical, true},
+		{32, maxCanonical, true},
+		{40, big.NewInt(12345), true},
+		{8, big.NewInt(255), true},
+		// The modulus fits in 31 bits, but is not canonical.
+		{31, MODULUS, false},
+		{32, MODULUS, false},
+		{8, big.NewInt(256), false},
+	} {
+		circuit := &TestNum2BitsCircuit{N: tc.n}
+		err := test.IsSolved(circuit, &TestNum2BitsCircuit{Input: tc.input}, ecc.BN254.ScalarField())
+		if tc.valid && err != nil {
+			t.Errorf("%d bits of %s: %v", tc.n, tc.input, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("expected %d bits of %s to be rejected", tc.n, tc.input)
+		}
+	}
+}
+
+type TestReduceFCircuit struct {
+	Inputs   [2]frontend.Variable
+	Expected frontend.Variable `gnark:",public"`
+}
+
+func (circuit *TestReduceFCircuit) Define(api frontend.API) error {
+	chip := NewChip(api)
+	for _, input := range circuit.Inputs {
+		api.AssertIsEqual(chip.ReduceF(Variable{Value: input, NbBits: 33}).Value, circuit.Expected)
+	}
+	return nil
+}
+
+type TestAssertIsCanonicalCircuit struct {
+	Input frontend.Variable
+}
+
+func (circuit *TestAssertIsCanonicalCircuit) Define(api frontend.API) error {
+	NewChip(api).AssertIsCanonical(Variable{Value: circuit.Input, NbBits: 31})
+	return nil
+}
+
+func TestReduceF(t *testing.T) {
+	// Two representations of 5 reduce to the same public input, and a prover whose remainders are
+	// the non-canonical 5 + p cannot expose that instead.
+	inputs := [2]frontend.Variable{new(big.Int).Add(big.NewInt(5), MODULUS), new(big.Int).Add(big.NewInt(5), new(big.Int).Mul(MODULUS, big.NewInt(3)))}
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &TestReduceFCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	override := solver.OverrideHint(solver.GetHintID(ReduceHint), aliasReduceHint)
+	for _, tc := range []struct {
+		expected int64
+		opts     []solver.Option
+		valid    bool
+	}{
+		{5, nil, true},
+		{5 + MODULUS.Int64(), nil, false},
+		{5 + MODULUS.Int64(), []solver.Option{override}, false},
+	} {
+		w, err := frontend.NewWitness(&TestReduceFCircuit{Inputs: inputs, Expected: tc.expected}, ecc.BN254.ScalarField())
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ccs.IsSolved(w, tc.opts...)
+		if tc.valid && err != nil {
+			t.Errorf("expected the public input %d to be accepted, got %v", tc.expected, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("expected the public input %d to be rejected", tc.expected)
+		}
+	}
+
+	for value, valid := range map[string]bool{"2013265920": true, "2013265921": false} {
+		err := test.IsSolved(&TestAssertIsCanonicalCircuit{}, &TestAssertIsCanonicalCircuit{Input: value}, ecc.BN254.ScalarField())
+		if valid && err != nil {
+			t.Errorf("expected %s to be canonical, got %v", value, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("expected %s not to be canonical", value)
+		}
+	}
+}
+
+type TestTraceCircuit struct {
+	A, B  [4]frontend.Variable
+	trace *Trace `gnark:"-"`
+}
+
+func (circuit *TestTraceCircuit) Define(api frontend.API) error {
+	chip := NewChip(api, WithTrace(circuit.trace))
+	a, b := newTestExt(circuit.A), newTestExt(circuit.B)
+	// An untraced constraint.
+	api.AssertIsDifferent(circuit.A[0], 0)
+	product := chip.MulE(a, b)
+	for i := 0; i < 3; i++ {
+		product = chip.MulE(product, a)
+	}
+	chip.AssertIsEqualE(chip.MulE(chip.InvE(a), product), chip.MulE(chip.MulE(chip.MulE(a, a), a), b))
+	chip.PrintE("product", product)
+	return nil
+}
+
+func TestTrace(t *testing.T) {
+	trace := &Trace{}
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &TestTraceCircuit{trace: trace})
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile, err := trace.Profile(ccs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := make(map[string]int)
+	total := 0
+	for _, p := range profile {
+		calls[p.Name] = p.Calls
+		total += p.NbConstraints
+		if p.Name == UNTRACED_OPERATION && p.NbConstraints == 0 {
+			t.Error("expected the untraced constraint to be reported")
+		}
+	}
+	if total != ccs.GetNbConstraints() {
+		t.Errorf("the profile has %d constraints, the circuit %d", total, ccs.GetNbConstraints())
+	}
+	// The MulE checking the inverse is part of the InvE.
+	for name, expected := range map[string]int{"MulE": 8, "InvE": 1, "AssertIsEqualE": 1, "PrintE": 1} {
+		if calls[name] != expected {
+			t.Errorf("expected %d %s calls, got %d in %+v", expected, name, calls[name], profile)
+		}
+	}
+
+	var out strings.Builder
+	if err := trace.DumpProfile(&out, ccs); err != nil {
+		t.Fatal(err)
+	}
+	expected := fmt.Sprintf("MulE 8 calls %d constraints", profile[1].NbConstraints)
+	if line := strings.Split(out.String(), "\n")[1]; strings.Join(strings.Fields(line), " ") != expected {
+		t.Errorf("expected the line %q, got\n%s", expected, out.String())
+	}
+
+	report, err := trace.Report(ccs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(report, out.String()) || !strings.HasSuffix(report, fmt.Sprintf("total: %d constraints\n", ccs.GetNbConstraints())) {
+		t.Errorf("expected the report to end with the total, got\n%s", report)
+	}
+
+	// Without a trace, the chip leaves no marker.
+	untraced, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &TestTraceCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&Trace{}).Profile(untraced); err != nil {
+		t.Errorf("expected an empty trace to match the untraced circuit, got %v", err)
+	}
+
+	// The solver prints the labelled, reduced coordinates.
+	assignment := &TestTraceCircuit{A: [4]frontend.Variable{1, 2, 3, 4}, B: [4]frontend.Variable{5, 6, 7, 8}}
+	w, err := frontend.NewWitness(assignment, ecc.BN254.ScalarField())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ccs.IsSolved(w); err != nil {
+		t.Fatal(err)
+	}
+}
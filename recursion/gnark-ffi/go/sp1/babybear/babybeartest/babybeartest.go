@@ -0,0 +1,151 @@
+// Package babybeartest tests gadgets built on the BabyBear chip against reference arithmetic. A
+// gadget is run inside a throwaway circuit on random canonical inputs, one set per seed, and its
+// outputs are asserted equal to the reference outputs with gnark's test engine.
+package babybeartest
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+)
+
+var modulus = uint32(babybear.MODULUS.Uint64())
+
+// Gadget computes its outputs from canonical base field inputs with the chip.
+type Gadget func(chip *babybear.Chip, in []babybear.Variable) []babybear.Variable
+
+// GadgetE computes its outputs from canonical extension field inputs with the chip.
+type GadgetE func(chip *babybear.Chip, in []babybear.ExtensionVariable) []babybear.ExtensionVariable
+
+// RunGadget runs the gadget on nInputs random canonical felts for each of the seeds 0 to seeds - 1,
+// and checks that its outputs equal the canonical outputs of the reference on the same inputs.
+// The chip is created with the given options. A failure is reported with its seed and inputs, and
+// the remaining seeds still run.
+func RunGadget(t testing.TB, gadget Gadget, reference func([]uint32) []uint32, nInputs int, seeds int, opts ...babybear.ChipOption) {
+	t.Helper()
+	for seed := 0; seed < seeds; seed++ {
+		rng := rand.New(rand.NewSource(int64(seed)))
+		inputs := make([]uint32, nInputs)
+		for i := range inputs {
+			inputs[i] = randomFelt(rng)
+		}
+		expected := reference(inputs)
+		if err := CheckGadget(gadget, inputs, expected, opts...); err != nil {
+			t.Errorf("seed %d: inputs %v: expected %v: %v", seed, inputs, expected, err)
+		}
+	}
+}
+
+// RunGadgetE is the extension field variant of RunGadget. Each input and output holds the four
+// coordinates of an extension element.
+func RunGadgetE(t testing.TB, gadget GadgetE, reference func([][4]uint32) [][4]uint32, nInputs int, seeds int, opts ...babybear.ChipOption) {
+	t.Helper()
+	flatReference := func(in []uint32) []uint32 {
+		return flattenValues(reference(unflattenValues(in)))
+	}
+	RunGadget(t, flattenGadget(gadget), flatReference, 4*nInputs, seeds, opts...)
+}
+
+// CheckGadget runs the gadget on the canonical inputs, with a chip created with the given
+// options, and checks that its outputs are the canonical expected ones.
+func CheckGadget(gadget Gadget, inputs, expected []uint32, opts ...babybear.ChipOption) error {
+	circuit := gadgetCircuit{
+		In:     make([]frontend.Variable, len(inputs)),
+		Out:    make([]frontend.Variable, len(expected)),
+		config: &gadgetConfig{gadget: gadget, opts: opts},
+	}
+	assignment := gadgetCircuit{In: feltValues(inputs), Out: feltValues(expected)}
+	return test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField())
+}
+
+// CheckGadgetE is the extension field variant of CheckGadget.
+func CheckGadgetE(gadget GadgetE, inputs, expected [][4]uint32, opts ...babybear.ChipOption) error {
+	return CheckGadget(flattenGadget(gadget), flattenValues(inputs), flattenValues(expected), opts...)
+}
+
+// flattenGadget turns an extension field gadget into a base field one, on the coordinates of its
+// inputs and outputs.
+func flattenGadget(gadget GadgetE) Gadget {
+	return func(chip *babybear.Chip, in []babybear.Variable) []babybear.Variable {
+		exts := make([]babybear.ExtensionVariable, len(in)/4)
+		for i := range exts {
+			copy(exts[i].Value[:], in[4*i:4*i+4])
+		}
+		var out []babybear.Variable
+		for _, e := range gadget(chip, exts) {
+			out = append(out, e.Value[:]...)
+		}
+		return out
+	}
+}
+
+func flattenValues(values [][4]uint32) []uint32 {
+	out := make([]uint32, 0, 4*len(values))
+	for _, e := range values {
+		out = append(out, e[:]...)
+	}
+	return out
+}
+
+func unflattenValues(values []uint32) [][4]uint32 {
+	out := make([][4]uint32, len(values)/4)
+	for i := range out {
+		copy(out[i][:], values[4*i:4*i+4])
+	}
+	return out
+}
+
+// gadgetCircuit asserts that the gadget maps In to Out.
+type gadgetCircuit struct {
+	In  []frontend.Variable
+	Out []frontend.Variable
+
+	// The test engine compares the circuit with its clone, and functions are only deeply equal
+	// behind the same pointer.
+	config *gadgetConfig `gnark:"-"`
+}
+
+type gadgetConfig struct {
+	gadget Gadget
+	opts   []babybear.ChipOption
+}
+
+func (circuit *gadgetCircuit) Define(api frontend.API) error {
+	chip := babybear.NewChip(api, circuit.config.opts...)
+	in := make([]babybear.Variable, len(circuit.In))
+	for i := range in {
+		in[i] = babybear.Variable{Value: circuit.In[i], NbBits: 31}
+	}
+
+	out := circuit.config.gadget(chip, in)
+	if len(out) != len(circuit.Out) {
+		return fmt.Errorf("the gadget returned %d outputs, the reference %d", len(out), len(circuit.Out))
+	}
+	for i := range out {
+		chip.AssertIsEqualF(out[i], babybear.Variable{Value: circuit.Out[i], NbBits: 31})
+	}
+	return nil
+}
+
+// randomFelt returns a uniformly random canonical felt, or one of the values at the boundaries of
+// the field, where reductions are most likely to go wrong, for one input in four.
+func randomFelt(rng *rand.Rand) uint32 {
+	if rng.Intn(4) == 0 {
+		boundaries := []uint32{0, 1, 2, modulus - 2, modulus - 1}
+		return boundaries[rng.Intn(len(boundaries))]
+	}
+	return uint32(rng.Int63n(int64(modulus)))
+}
+
+func feltValues(values []uint32) []frontend.Variable {
+	out := make([]frontend.Variable, len(values))
+	for i, v := range values {
+		out[i] = v
+	}
+	return out
+}
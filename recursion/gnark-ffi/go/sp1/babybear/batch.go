@@ -0,0 +1,91 @@
+package babybear
+
+import "fmt"
+
+// The batch operations apply the chip to slices of elements, which must have the same length.
+// They share reductions across the elements where the per-element methods cannot.
+
+// lazyInnerProductBits is the bound of the unreduced sums of InnerProductE, below the native
+// modulus so that they never wrap around it.
+const lazyInnerProductBits = 252
+
+func checkBatchLengths(op string, a, b int) {
+	if a != b {
+		panic(fmt.Sprintf("%s: operands of lengths %d and %d", op, a, b))
+	}
+}
+
+// AddFs returns the element-wise sums of a and b.
+func (c *Chip) AddFs(a, b []Variable) []Variable {
+	defer c.traceOperation("AddFs")()
+	checkBatchLengths("AddFs", len(a), len(b))
+	out := make([]Variable, len(a))
+	for i := range a {
+		out[i] = c.AddF(a[i], b[i])
+	}
+	return out
+}
+
+// MulFs returns the element-wise products of a and b.
+func (c *Chip) MulFs(a, b []Variable) []Variable {
+	defer c.traceOperation("MulFs")()
+	checkBatchLengths("MulFs", len(a), len(b))
+	out := make([]Variable, len(a))
+	for i := range a {
+		out[i] = c.MulF(a[i], b[i])
+	}
+	return out
+}
+
+// AssertIsEqualFs asserts that a and b are equal element-wise. Reduced pairs are compared
+// directly, like AssertIsEqualF does, and the others with a single reduction of their difference
+// instead of one reduction of each side.
+func (c *Chip) AssertIsEqualFs(a, b []Variable) {
+	defer c.traceOperation("AssertIsEqualFs")()
+	checkBatchLengths("AssertIsEqualFs", len(a), len(b))
+	for i := range a {
+		if a[i].NbBits == 31 && b[i].NbBits == 31 {
+			c.api.AssertIsEqual(a[i].Value, b[i].Value)
+		} else {
+			c.assertIsEqualModP(a[i], b[i])
+		}
+	}
+}
+
+// AssertIsEqualEs is the extension field variant of AssertIsEqualFs.
+func (c *Chip) AssertIsEqualEs(a, b []ExtensionVariable) {
+	defer c.traceOperation("AssertIsEqualEs")()
+	checkBatchLengths("AssertIsEqualEs", len(a), len(b))
+	flatA := make([]Variable, 0, 4*len(a))
+	flatB := make([]Variable, 0, 4*len(b))
+	for i := range a {
+		flatA = append(flatA, a[i].Value[:]...)
+		flatB = append(flatB, b[i].Value[:]...)
+	}
+	c.AssertIsEqualFs(flatA, flatB)
+}
+
+// InnerProductE returns the sum of the products a[i] * b[i]. The products are accumulated
+// unreduced, like the products of MulE, and each coordinate is reduced once, or whenever its sum
+// would reach 2^lazyInnerProductBits. The inputs must be below 2^120, like the inputs of MulE.
+func (c *Chip) InnerProductE(a, b []ExtensionVariable) ExtensionVariable {
+	defer c.traceOperation("InnerProductE")()
+	checkBatchLengths("InnerProductE", len(a), len(b))
+	if len(a) == 0 {
+		return ZeroE()
+	}
+	var sums [4]Variable
+	for i := range a {
+		for k, term := range c.lazyMulE(a[i], b[i]) {
+			if sums[k].Value != nil && max(sums[k].NbBits, term.NbBits)+1 > lazyInnerProductBits {
+				sums[k] = c.ReduceSlow(sums[k])
+			}
+			sums[k] = c.lazyAccumulate(sums[k], term)
+		}
+	}
+	var out ExtensionVariable
+	for k, sum := range sums {
+		out.Value[k] = c.ReduceFast(sum)
+	}
+	return out
+}
@@ -0,0 +1,102 @@
+package babybear
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// ExpF returns a^e for a constant exponent e >= 0, by left-to-right square-and-multiply. a^0 is
+// the constant one and a^1 is a itself, neither adds constraints.
+func (c *Chip) ExpF(a Variable, e *big.Int) Variable {
+	defer c.traceOperation("ExpF")()
+	if e.Sign() < 0 {
+		panic(fmt.Sprintf("negative exponent %s", e))
+	}
+	if e.Sign() == 0 {
+		return NewF("1")
+	}
+	result := a
+	for i := e.BitLen() - 2; i >= 0; i-- {
+		result = c.MulF(result, result)
+		if e.Bit(i) == 1 {
+			result = c.MulF(result, a)
+		}
+	}
+	return result
+}
+
+// ExpE is the extension field variant of ExpF.
+func (c *Chip) ExpE(a ExtensionVariable, e *big.Int) ExtensionVariable {
+	defer c.traceOperation("ExpE")()
+	if e.Sign() < 0 {
+		panic(fmt.Sprintf("negative exponent %s", e))
+	}
+	if e.Sign() == 0 {
+		return NewE([]string{"1", "0", "0", "0"})
+	}
+	result := a
+	for i := e.BitLen() - 2; i >= 0; i-- {
+		result = c.MulE(result, result)
+		if e.Bit(i) == 1 {
+			result = c.MulE(result, a)
+		}
+	}
+	return result
+}
+
+// ExpPowerOf2F returns a^(2^log2) by squaring a log2 times.
+func (c *Chip) ExpPowerOf2F(a Variable, log2 int) Variable {
+	defer c.traceOperation("ExpPowerOf2F")()
+	for i := 0; i < log2; i++ {
+		a = c.MulF(a, a)
+	}
+	return a
+}
+
+// ExpPowerOf2E is the extension field variant of ExpPowerOf2F.
+func (c *Chip) ExpPowerOf2E(a ExtensionVariable, log2 int) ExtensionVariable {
+	defer c.traceOperation("ExpPowerOf2E")()
+	for i := 0; i < log2; i++ {
+		a = c.MulE(a, a)
+	}
+	return a
+}
+
+// ExpFBits returns a raised to the exponent whose little-endian bits are given, which must be
+// boolean, such as the output of ToBinary. Each bit selects whether the running power of a is
+// multiplied in, so the cost only depends on the number of bits.
+func (c *Chip) ExpFBits(a Variable, bits []frontend.Variable) Variable {
+	defer c.traceOperation("ExpFBits")()
+	result := NewF("1")
+	power := a
+	for i, bit := range bits {
+		result = c.SelectF(bit, c.MulF(result, power), result)
+		if i < len(bits)-1 {
+			power = c.MulF(power, power)
+		}
+	}
+	return result
+}
+
+// ReverseBitsLen returns the low bitLen little-endian bits of an index in reverse order, which are
+// the little-endian bits of reverse_bits_len(index, bitLen). It only permutes wires.
+func (c *Chip) ReverseBitsLen(bits []frontend.Variable, bitLen int) []frontend.Variable {
+	if bitLen > len(bits) {
+		panic(fmt.Sprintf("cannot reverse %d bits of a %d bit decomposition", bitLen, len(bits)))
+	}
+	out := make([]frontend.Variable, bitLen)
+	for i := range out {
+		out[i] = bits[bitLen-1-i]
+	}
+	return out
+}
+
+// ExpReverseBitsLen returns base^reverse_bits_len(index, bitLen) for the index whose little-endian
+// bits are given, like exp_reverse_bits_len in the SP1 recursion compiler. Only the low bitLen bits
+// are used.
+func (c *Chip) ExpReverseBitsLen(base Variable, bits []frontend.Variable, bitLen int) Variable {
+	defer c.traceOperation("ExpReverseBitsLen")()
+	return c.ExpFBits(base, c.ReverseBitsLen(bits, bitLen))
+}
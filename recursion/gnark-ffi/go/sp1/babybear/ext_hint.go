@@ -0,0 +1,125 @@
+package babybear
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/big"
+	"sync"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// ExtHintFunc computes an extension element of field from the flattened coordinates of the hint
+// inputs.
+type ExtHintFunc func(field *Field, inputs []*big.Int) ([4]*big.Int, error)
+
+// ExtHint is a hint whose output is a single extension element.
+type ExtHint struct {
+	name string
+	key  uint32
+}
+
+var (
+	extHintsM sync.RWMutex
+	extHints  = make(map[uint32]ExtHint)
+	extFuncs  = make(map[uint32]ExtHintFunc)
+)
+
+// NewExtHint registers fn as an extension hint. Every extension hint is solved through the single
+// ExtHintDispatcher solver hint, which finds fn from a key derived from name, so the name must be
+// unique and stable across builds. Over the fields other than BabyBear, the hint is solved through
+// FieldExtHintDispatcher.
+func NewExtHint(name string, fn ExtHintFunc) ExtHint {
+	hf := fnv.New32a()
+	hf.Write([]byte(name))
+	h := ExtHint{name: name, key: hf.Sum32()}
+
+	extHintsM.Lock()
+	defer extHintsM.Unlock()
+	if existing, ok := extHints[h.key]; ok {
+		panic(fmt.Errorf("extension hint %s collides with %s", name, existing.name))
+	}
+	extHints[h.key] = h
+	extFuncs[h.key] = fn
+	return h
+}
+
+// CallExtHint reduces and flattens the inputs, calls the hint and returns its output as an
+// extension element. The output is unconstrained: the caller must check it.
+func (c *Chip) CallExtHint(h ExtHint, inputs ...ExtensionVariable) ExtensionVariable {
+	defer c.traceOperation("CallExtHint")()
+	return c.callExtHint(h, true, inputs...)
+}
+
+// callExtHint is CallExtHint, only reducing the inputs if asked to. A hint given unreduced inputs
+// must reduce them itself.
+func (c *Chip) callExtHint(h ExtHint, reduce bool, inputs ...ExtensionVariable) ExtensionVariable {
+	flattened := make([]frontend.Variable, 0, 1+4*len(inputs))
+	flattened = append(flattened, h.key)
+	for _, in := range inputs {
+		for i := 0; i < 4; i++ {
+			if reduce {
+				in.Value[i] = c.ReduceSlow(in.Value[i])
+			}
+			flattened = append(flattened, in.Value[i].Value)
+		}
+	}
+
+	result := c.newHint(extHintDispatchers, 4, flattened...)
+
+	var out ExtensionVariable
+	for i := 0; i < 4; i++ {
+		out.Value[i] = Variable{Value: result[i], NbBits: 31}
+	}
+	return out
+}
+
+// The hint used to solve every extension hint over BabyBear. The first input is the key of the
+// hint to call.
+func ExtHintDispatcher(_ *big.Int, inputs []*big.Int, results []*big.Int) error {
+	return dispatchExtHint("ExtHintDispatcher", BabyBear, inputs, results)
+}
+
+// FieldExtHintDispatcher is ExtHintDispatcher over the field of its first input, followed by the
+// key of the hint to call.
+func FieldExtHintDispatcher(_ *big.Int, inputs []*big.Int, results []*big.Int) error {
+	field, inputs, err := hintField(inputs)
+	if err != nil {
+		return err
+	}
+	return dispatchExtHint("FieldExtHintDispatcher", field, inputs, results)
+}
+
+func dispatchExtHint(name string, field *Field, inputs []*big.Int, results []*big.Int) error {
+	if len(inputs) == 0 || len(results) != 4 {
+		return fmt.Errorf("%s expects a hint key and 4 outputs", name)
+	}
+
+	extHintsM.RLock()
+	h, ok := extHints[uint32(inputs[0].Uint64())]
+	fn := extFuncs[h.key]
+	extHintsM.RUnlock()
+	if !ok {
+		return fmt.Errorf("no extension hint registered with key %s", inputs[0])
+	}
+
+	out, err := fn(field, inputs[1:])
+	if err != nil {
+		return fmt.Errorf("%s: %w", h.name, err)
+	}
+	for i := 0; i < 4; i++ {
+		if out[i] == nil {
+			return fmt.Errorf("%s: missing output coordinate %d", h.name, i)
+		}
+		results[i].Set(out[i])
+	}
+	return nil
+}
+
+// ExtHintName returns the name of the extension hint solved for the given ExtHintDispatcher key.
+func ExtHintName(key uint32) (string, bool) {
+	extHintsM.RLock()
+	defer extHintsM.RUnlock()
+	h, ok := extHints[key]
+	return h.name, ok
+}
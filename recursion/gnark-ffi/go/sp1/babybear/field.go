@@ -0,0 +1,262 @@
+package babybear
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"sync"
+
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/consensys/gnark/frontend"
+)
+
+// Field is a prime field with the quartic extension F[x]/(x^4 - W), over which a chip computes.
+// The bounds tracked by the chip assume a 31 bit modulus, whose reduced values fit in 31 bits and
+// whose quotients fit in 30 bits less than their dividends, and a W of at most 4 bits, which the
+// lazy extension arithmetic adds to the bounds of its products. A Field is created by NewField, and
+// must not be modified.
+type Field struct {
+	Name    string
+	Modulus *big.Int
+	// The constant of the binomial defining the extension.
+	W *big.Int
+	// The largest s such that 2^s divides the modulus minus one, and a generator of the
+	// multiplicative group, whose powers give the generators of the subgroups of order 2^s.
+	TwoAdicity int
+	Generator  *big.Int
+}
+
+// BabyBear is 15 * 2^27 + 1, the field of SP1 and the default field of a chip.
+var BabyBear = mustField(NewField("BabyBear", 2013265921, 11, 27, 31))
+
+// KoalaBear is 127 * 2^24 + 1, for which x^3 is a permutation, with the parameters of Plonky3.
+var KoalaBear = mustField(NewField("KoalaBear", 2130706433, 3, 24, 3))
+
+// The fields created by NewField, by modulus, which the generic hints are given.
+var (
+	fieldsM sync.RWMutex
+	fields  = make(map[uint64]*Field)
+)
+
+// NewField checks the parameters of a field and registers it for the generic hints, which only
+// know it by its modulus, so a modulus is only accepted once. The extension must be a field:
+// x^4 - w is irreducible exactly when w is not a square and 4 divides the modulus minus one, which
+// rules out Mersenne31, whose extensions are built on the complex numbers instead.
+func NewField(name string, modulus uint64, w uint64, twoAdicity int, generator uint64) (*Field, error) {
+	p := new(big.Int).SetUint64(modulus)
+	if p.BitLen() != 31 || !p.ProbablyPrime(20) {
+		return nil, fmt.Errorf("%s: %d is not a 31 bit prime", name, modulus)
+	}
+	if s := bits.TrailingZeros64(modulus - 1); s != twoAdicity {
+		return nil, fmt.Errorf("%s: the two-adicity of %d is %d, not %d", name, modulus, s, twoAdicity)
+	}
+	if twoAdicity < 2 {
+		return nil, fmt.Errorf("%s: %d has no quartic binomial extension, as 4 does not divide %d", name, modulus, modulus-1)
+	}
+	if w >= 1<<4 || big.Jacobi(new(big.Int).SetUint64(w), p) != -1 {
+		return nil, fmt.Errorf("%s: x^4 - %d is not an irreducible binomial with a 4 bit constant", name, w)
+	}
+	if !isGenerator(generator, modulus) {
+		return nil, fmt.Errorf("%s: %d does not generate the multiplicative group", name, generator)
+	}
+
+	fieldsM.Lock()
+	defer fieldsM.Unlock()
+	if existing, ok := fields[modulus]; ok {
+		return nil, fmt.Errorf("%s: the modulus %d is already the one of %s", name, modulus, existing.Name)
+	}
+	f := &Field{
+		Name:       name,
+		Modulus:    p,
+		W:          new(big.Int).SetUint64(w),
+		TwoAdicity: twoAdicity,
+		Generator:  new(big.Int).SetUint64(generator),
+	}
+	fields[modulus] = f
+	return f, nil
+}
+
+func mustField(f *Field, err error) *Field {
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// isGenerator returns whether g has order p - 1, that is whether g^((p - 1) / q) is not 1 for any
+// prime factor q of p - 1.
+func isGenerator(g uint64, p uint64) bool {
+	if g == 0 || g >= p {
+		return false
+	}
+	order := p - 1
+	for q, n := uint64(2), order; n > 1; q++ {
+		if q*q > n {
+			q = n
+		}
+		if n%q != 0 {
+			continue
+		}
+		for n%q == 0 {
+			n /= q
+		}
+		e := new(big.Int).SetUint64(order / q)
+		if new(big.Int).Exp(new(big.Int).SetUint64(g), e, new(big.Int).SetUint64(p)).Cmp(big.NewInt(1)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// TwoAdicGenerator returns the generator of the subgroup of order 2^bits, the power of the
+// generator of the field Plonky3 uses.
+func (f *Field) TwoAdicGenerator(bits int) *big.Int {
+	if bits < 0 || bits > f.TwoAdicity {
+		panic(fmt.Sprintf("%s has no subgroup of order 2^%d", f.Name, bits))
+	}
+	e := new(big.Int).Rsh(new(big.Int).Sub(f.Modulus, big.NewInt(1)), uint(bits))
+	return e.Exp(f.Generator, e, f.Modulus)
+}
+
+// NewFChecked is NewF for untrusted input: the value must be a canonical element of f written in
+// decimal without a sign or leading zeros, which gnark would otherwise read as octal.
+func (f *Field) NewFChecked(value string) (Variable, error) {
+	v, ok := new(big.Int).SetString(value, 10)
+	if !ok || v.String() != value {
+		return Variable{}, fmt.Errorf("%q is not a decimal number", value)
+	}
+	if v.Sign() < 0 || v.Cmp(f.Modulus) >= 0 {
+		return Variable{}, fmt.Errorf("%s is not a canonical %s element", value, f.Name)
+	}
+	return NewF(value), nil
+}
+
+// NewEChecked is NewE for untrusted input: the value must have 4 coordinates accepted by
+// f.NewFChecked.
+func (f *Field) NewEChecked(value []string) (ExtensionVariable, error) {
+	if len(value) != 4 {
+		return ExtensionVariable{}, fmt.Errorf("extension element must have 4 coordinates, got %d", len(value))
+	}
+	var out ExtensionVariable
+	for i, coordinate := range value {
+		v, err := f.NewFChecked(coordinate)
+		if err != nil {
+			return ExtensionVariable{}, fmt.Errorf("extension coordinate %d: %w", i, err)
+		}
+		out.Value[i] = v
+	}
+	return out, nil
+}
+
+// The arithmetic of the field in Go, which solves the generic hints. The inputs may be unreduced;
+// the outputs are canonical.
+
+// inv returns the inverse of a, or 0 if a is zero.
+func (f *Field) inv(a *big.Int) *big.Int {
+	inverse := new(big.Int).ModInverse(new(big.Int).Mod(a, f.Modulus), f.Modulus)
+	if inverse == nil {
+		return new(big.Int)
+	}
+	return inverse
+}
+
+// AddE returns the sum of the extension elements a and b.
+func (f *Field) AddE(a, b [4]*big.Int) [4]*big.Int {
+	var out [4]*big.Int
+	for i := range out {
+		out[i] = new(big.Int).Add(a[i], b[i])
+		out[i].Mod(out[i], f.Modulus)
+	}
+	return out
+}
+
+// SubE returns the difference of the extension elements a and b.
+func (f *Field) SubE(a, b [4]*big.Int) [4]*big.Int {
+	var out [4]*big.Int
+	for i := range out {
+		out[i] = new(big.Int).Sub(a[i], b[i])
+		out[i].Mod(out[i], f.Modulus)
+	}
+	return out
+}
+
+// MulE returns the product of the extension elements a and b.
+func (f *Field) MulE(a, b [4]*big.Int) [4]*big.Int {
+	var out [4]*big.Int
+	for i := range out {
+		out[i] = new(big.Int)
+	}
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			term := new(big.Int).Mul(a[i], b[j])
+			if i+j >= 4 {
+				term.Mul(term, f.W)
+			}
+			out[(i+j)%4].Add(out[(i+j)%4], term)
+		}
+	}
+	for i := range out {
+		out[i].Mod(out[i], f.Modulus)
+	}
+	return out
+}
+
+// InvE returns the inverse of the extension element a, or 0 if a is zero. Writing a as
+// A(y) + B(y) x over the quadratic extension of y = x^2, its product with A(y) - B(y) x is the norm
+// A^2 - y B^2, which belongs to the quadratic extension and is inverted there.
+func (f *Field) InvE(a [4]*big.Int) [4]*big.Int {
+	conjugate := [4]*big.Int{a[0], new(big.Int).Neg(a[1]), a[2], new(big.Int).Neg(a[3])}
+	norm := f.MulE(a, conjugate)
+	// The norm is n0 + n2 y, whose inverse is (n0 - n2 y) / (n0^2 - W n2^2).
+	n0, n2 := norm[0], norm[2]
+	d := new(big.Int).Mul(n0, n0)
+	d.Sub(d, new(big.Int).Mul(f.W, new(big.Int).Mul(n2, n2)))
+	dInv := f.inv(d)
+	normInv := [4]*big.Int{new(big.Int).Mul(n0, dInv), new(big.Int), new(big.Int).Neg(new(big.Int).Mul(n2, dInv)), new(big.Int)}
+	return f.MulE(conjugate, normInv)
+}
+
+// hintField splits the inputs of a generic hint into the field whose modulus is the first input
+// and the inputs of the hint itself.
+func hintField(inputs []*big.Int) (*Field, []*big.Int, error) {
+	if len(inputs) == 0 {
+		return nil, nil, errors.New("missing the modulus of the field")
+	}
+	fieldsM.RLock()
+	field, ok := fields[inputs[0].Uint64()]
+	fieldsM.RUnlock()
+	if !ok || !inputs[0].IsUint64() {
+		return nil, nil, fmt.Errorf("no field has the modulus %s", inputs[0])
+	}
+	return field, inputs[1:], nil
+}
+
+// fieldHint is a hint whose solution depends on the field. The chips over BabyBear call the
+// BabyBear hint, with the inputs it had before the chip was generic, so that their constraint
+// systems are unchanged, and the other chips call the generic hint, given the modulus first.
+type fieldHint struct {
+	babyBear solver.Hint
+	generic  solver.Hint
+}
+
+var (
+	reduceHints        = fieldHint{ReduceHint, FieldReduceHint}
+	invFHints          = fieldHint{InvFHint, FieldInvFHint}
+	divFHints          = fieldHint{DivFHint, FieldDivFHint}
+	extHintDispatchers = fieldHint{ExtHintDispatcher, FieldExtHintDispatcher}
+)
+
+// newHint calls the variant of h for the field of the chip.
+func (c *Chip) newHint(h fieldHint, nbOutputs int, inputs ...frontend.Variable) []frontend.Variable {
+	hint := h.babyBear
+	if c.field != BabyBear {
+		hint = h.generic
+		inputs = append([]frontend.Variable{c.field.Modulus}, inputs...)
+	}
+	result, err := c.api.Compiler().NewHint(hint, nbOutputs, inputs...)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
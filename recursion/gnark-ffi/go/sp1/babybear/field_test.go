@@ -0,0 +1,198 @@
+package babybear
+
+import (
+	"math/big"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/scs"
+	"github.com/consensys/gnark/test"
+)
+
+func TestNewField(t *testing.T) {
+	for field, generator := range map[*Field]uint64{
+		// The two-adic generators of Plonky3, also hard-coded by the verifier for BabyBear.
+		BabyBear:  0x1a427a41,
+		KoalaBear: 0x6ac49f88,
+	} {
+		g := field.TwoAdicGenerator(field.TwoAdicity)
+		if g.Uint64() != generator {
+			t.Errorf("%s: two-adic generator %d, expected %d", field.Name, g, generator)
+		}
+		if half := field.TwoAdicGenerator(field.TwoAdicity - 1); half.Cmp(new(big.Int).Exp(g, big.NewInt(2), field.Modulus)) != 0 {
+			t.Errorf("%s: the generator of order 2^%d is not the square of the one of order 2^%d", field.Name, field.TwoAdicity-1, field.TwoAdicity)
+		}
+	}
+
+	for name, c := range map[string]struct {
+		modulus    uint64
+		w          uint64
+		twoAdicity int
+		generator  uint64
+		err        string
+	}{
+		"mersenne31":     {1<<31 - 1, 3, 1, 7, "no quartic binomial extension"},
+		"composite":      {2013265923, 11, 1, 31, "not a 31 bit prime"},
+		"too small":      {65537, 3, 16, 3, "not a 31 bit prime"},
+		"two-adicity":    {2013265921, 11, 26, 31, "two-adicity of 2013265921 is 27"},
+		"square w":       {2013265921, 4, 27, 31, "x^4 - 4 is not an irreducible"},
+		"large w":        {2013265921, 22, 27, 31, "x^4 - 22 is not an irreducible"},
+		"generator":      {2013265921, 11, 27, 11, "11 does not generate"},
+		"already exists": {2013265921, 11, 27, 31, "already the one of BabyBear"},
+	} {
+		_, err := NewField(name, c.modulus, c.w, c.twoAdicity, c.generator)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: expected an error containing %q, got %v", name, c.err, err)
+		}
+	}
+}
+
+func randomExt(rng *rand.Rand, field *Field) [4]*big.Int {
+	var out [4]*big.Int
+	for i := range out {
+		out[i] = new(big.Int).Rand(rng, field.Modulus)
+	}
+	return out
+}
+
+func TestFieldInvE(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	for i := 0; i < 100; i++ {
+		// The Go inversion matches the native BabyBear one.
+		a := randomExt(rng, BabyBear)
+		native, err := InvEHint(BabyBear, a[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inverse := BabyBear.InvE(a); !equalE(inverse, native) {
+			t.Fatalf("BabyBear inverse of %v: %v, expected %v", a, inverse, native)
+		}
+
+		for _, field := range []*Field{BabyBear, KoalaBear} {
+			a := randomExt(rng, field)
+			if product := field.MulE(a, field.InvE(a)); !equalE(product, [4]*big.Int{big.NewInt(1), big.NewInt(0), big.NewInt(0), big.NewInt(0)}) {
+				t.Fatalf("%s: the product of %v and its inverse is %v", field.Name, a, product)
+			}
+		}
+	}
+}
+
+func equalE(a, b [4]*big.Int) bool {
+	for i := range a {
+		if a[i].Cmp(b[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// TestFieldCircuit computes with a chip over Field, and compares the results with the arithmetic
+// of the field in Go.
+type TestFieldCircuit struct {
+	A, B     [4]frontend.Variable
+	Expected [8][4]frontend.Variable
+	Field    *Field `gnark:"-"`
+	Tower    bool   `gnark:"-"`
+}
+
+func (circuit *TestFieldCircuit) Define(api frontend.API) error {
+	opts := []ChipOption{WithField(circuit.Field)}
+	if circuit.Tower {
+		opts = append(opts, WithTowerExtension())
+	}
+	chip := NewChip(api, opts...)
+	a, b := newTestExt(circuit.A), newTestExt(circuit.B)
+	ab := chip.MulE(a, b)
+	results := []ExtensionVariable{
+		chip.AddE(a, b),
+		chip.SubE(a, b),
+		ab,
+		chip.MulE(ab, chip.MulE(b, b)),
+		chip.InvE(a),
+		chip.DivE(a, b),
+		chip.NegE(a),
+		Felts2Ext(chip.MulF(a.Value[0], b.Value[0]), chip.InvF(a.Value[0]), chip.DivF(a.Value[1], b.Value[1]), chip.ReduceF(chip.MulFConst(a.Value[2], 7))),
+	}
+	for i, result := range results {
+		chip.AssertIsEqualE(result, newTestExt(circuit.Expected[i]))
+	}
+	return nil
+}
+
+func fieldAssignment(field *Field, a, b [4]*big.Int) *TestFieldCircuit {
+	p := field.Modulus
+	mod := func(v *big.Int) *big.Int { return v.Mod(v, p) }
+	zero := [4]*big.Int{new(big.Int), new(big.Int), new(big.Int), new(big.Int)}
+	ab := field.MulE(a, b)
+	felts := [4]*big.Int{
+		mod(new(big.Int).Mul(a[0], b[0])),
+		new(big.Int).ModInverse(a[0], p),
+		mod(new(big.Int).Mul(a[1], new(big.Int).ModInverse(b[1], p))),
+		mod(new(big.Int).Mul(a[2], big.NewInt(7))),
+	}
+	expected := [][4]*big.Int{field.AddE(a, b), field.SubE(a, b), ab, field.MulE(ab, field.MulE(b, b)), field.InvE(a), field.MulE(a, field.InvE(b)), field.SubE(zero, a), felts}
+
+	assignment := &TestFieldCircuit{}
+	for i := range a {
+		assignment.A[i], assignment.B[i] = a[i], b[i]
+	}
+	for i, e := range expected {
+		for j := range e {
+			assignment.Expected[i][j] = e[j]
+		}
+	}
+	return assignment
+}
+
+func TestFieldChip(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	koalaBearMax := new(big.Int).Sub(KoalaBear.Modulus, big.NewInt(1))
+	for _, field := range []*Field{BabyBear, KoalaBear} {
+		for _, tower := range []bool{false, true} {
+			circuit := &TestFieldCircuit{Field: field, Tower: tower}
+			for seed := 0; seed < 4; seed++ {
+				a, b := randomExt(rng, field), randomExt(rng, field)
+				if seed == 0 && field == KoalaBear {
+					// Coordinates above the BabyBear modulus are canonical KoalaBear elements.
+					a[0], b[3] = koalaBearMax, koalaBearMax
+				}
+				assignment := fieldAssignment(field, a, b)
+				if err := test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()); err != nil {
+					t.Fatalf("%s, tower %t: %v", field.Name, tower, err)
+				}
+
+				assignment.Expected[2][1] = new(big.Int).Add(assignment.Expected[2][1].(*big.Int), big.NewInt(1))
+				if err := test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()); err == nil {
+					t.Fatalf("%s, tower %t: expected a wrong product to be rejected", field.Name, tower)
+				}
+			}
+		}
+	}
+
+	// The results over KoalaBear are not the ones over BabyBear.
+	a, b := randomExt(rng, BabyBear), randomExt(rng, BabyBear)
+	if err := test.IsSolved(&TestFieldCircuit{Field: BabyBear}, fieldAssignment(KoalaBear, a, b), ecc.BN254.ScalarField()); err == nil {
+		t.Fatal("expected the KoalaBear results to be rejected by a BabyBear chip")
+	}
+}
+
+func TestFieldChipConstraintSystem(t *testing.T) {
+	// The generic hints are found by the solver of the compiled constraint system, from the modulus
+	// of their first input.
+	rng := rand.New(rand.NewSource(1))
+	assignment := fieldAssignment(KoalaBear, randomExt(rng, KoalaBear), randomExt(rng, KoalaBear))
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &TestFieldCircuit{Field: KoalaBear})
+	if err != nil {
+		t.Fatal(err)
+	}
+	witness, err := frontend.NewWitness(assignment, ecc.BN254.ScalarField())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ccs.IsSolved(witness); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,182 @@
+package babybear_test
+
+import (
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+)
+
+// fuzzCircuit asserts that the gadget maps In to Out. Unlike the circuits of babybeartest, the
+// inputs are any 32 bit values, so that the chip also sees the non-canonical felts an unreduced
+// operation leaves.
+type fuzzCircuit struct {
+	In  []frontend.Variable
+	Out []frontend.Variable
+
+	config *fuzzConfig `gnark:"-"`
+}
+
+type fuzzConfig struct {
+	gadget func(chip *babybear.Chip, in []babybear.Variable) []babybear.Variable
+	opts   []babybear.ChipOption
+}
+
+func (circuit *fuzzCircuit) Define(api frontend.API) error {
+	chip := babybear.NewChip(api, circuit.config.opts...)
+	in := make([]babybear.Variable, len(circuit.In))
+	for i, v := range circuit.In {
+		in[i] = babybear.Variable{Value: v, NbBits: 32}
+	}
+	for i, v := range circuit.config.gadget(chip, in) {
+		chip.AssertIsEqualF(v, babybear.Variable{Value: circuit.Out[i], NbBits: 31})
+	}
+	return nil
+}
+
+func solveFuzz(gadget func(*babybear.Chip, []babybear.Variable) []babybear.Variable, in, out []uint32, opts ...babybear.ChipOption) error {
+	circuit := fuzzCircuit{
+		In:     make([]frontend.Variable, len(in)),
+		Out:    make([]frontend.Variable, len(out)),
+		config: &fuzzConfig{gadget: gadget, opts: opts},
+	}
+	assignment := fuzzCircuit{In: make([]frontend.Variable, len(in)), Out: make([]frontend.Variable, len(out))}
+	for i, v := range in {
+		assignment.In[i] = v
+	}
+	for i, v := range out {
+		assignment.Out[i] = v
+	}
+	return test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField())
+}
+
+func canonical(x uint32) uint32 { return uint32(uint64(x) % p) }
+
+func ext(in []babybear.Variable) babybear.ExtensionVariable {
+	return babybear.Felts2Ext(in[0], in[1], in[2], in[3])
+}
+
+func canonicalE(in []uint32) [4]uint32 {
+	return [4]uint32{canonical(in[0]), canonical(in[1]), canonical(in[2]), canonical(in[3])}
+}
+
+func FuzzArithmeticF(f *testing.F) {
+	m := uint32(p)
+	for _, seed := range [][3]uint32{
+		{1, 2, 3},
+		{0, 0, 0},
+		{m - 1, m - 1, m - 1},
+		// Values at and above the modulus.
+		{m, m + 1, 2*m - 1},
+		{1<<32 - 1, 1<<31 - 1, 2 * m},
+		// Zero divisors, and a cancelling subtraction of aliases.
+		{5, m, 0},
+		{m + 7, 7, 2 * m},
+	} {
+		f.Add(seed[0], seed[1], seed[2])
+	}
+
+	arithmetic := func(chip *babybear.Chip, in []babybear.Variable) []babybear.Variable {
+		return []babybear.Variable{
+			chip.AddF(in[0], in[1]),
+			chip.SubF(in[0], in[1]),
+			chip.SubF(in[0], in[0]),
+			chip.MulF(in[0], in[1]),
+			chip.MulAddF(in[0], in[1], in[2]),
+			chip.NegF(in[2]),
+		}
+	}
+	division := func(chip *babybear.Chip, in []babybear.Variable) []babybear.Variable {
+		return []babybear.Variable{chip.DivF(in[0], in[1]), chip.InvF(in[1])}
+	}
+
+	f.Fuzz(func(t *testing.T, a, b, c uint32) {
+		x, y, z := canonical(a), canonical(b), canonical(c)
+		expected := []uint32{addF(x, y), subF(x, y), 0, mulF(x, y), addF(mulF(x, y), z), subF(0, z)}
+		if err := solveFuzz(arithmetic, []uint32{a, b, c}, expected); err != nil {
+			t.Fatalf("%d, %d, %d: %v", a, b, c, err)
+		}
+
+		if y == 0 {
+			// Zero has no inverse, so any claimed quotient must be rejected.
+			if err := solveFuzz(division, []uint32{a, b}, []uint32{0, 0}); err == nil {
+				t.Fatalf("%d / %d: expected a zero divisor to be rejected", a, b)
+			}
+			return
+		}
+		inv := inverseF(y)
+		if err := solveFuzz(division, []uint32{a, b}, []uint32{mulF(x, inv), inv}); err != nil {
+			t.Fatalf("%d / %d: %v", a, b, err)
+		}
+	})
+}
+
+func FuzzArithmeticE(f *testing.F) {
+	m := uint32(p)
+	f.Add(uint32(1), uint32(2), uint32(3), uint32(4), uint32(5), uint32(6), uint32(7), uint32(8))
+	f.Add(m-1, m-1, m-1, m-1, m-1, m-1, m-1, m-1)
+	f.Add(m, uint32(1<<32-1), 2*m, m+1, uint32(1<<31-1), m-1, uint32(0), 2*m+5)
+	// A zero divisor written with aliases of zero, and a divisor with a single non-zero coordinate.
+	f.Add(uint32(9), uint32(8), uint32(7), uint32(6), m, 2*m, uint32(0), m)
+	f.Add(uint32(9), uint32(8), uint32(7), uint32(6), uint32(0), uint32(0), uint32(0), m+3)
+
+	arithmetic := func(chip *babybear.Chip, in []babybear.Variable) []babybear.Variable {
+		a, b := ext(in[:4]), ext(in[4:])
+		var out []babybear.Variable
+		for _, e := range []babybear.ExtensionVariable{chip.AddE(a, b), chip.SubE(a, b), chip.SubE(a, a), chip.MulE(a, b), chip.NegE(b)} {
+			out = append(out, e.Value[:]...)
+		}
+		return out
+	}
+	division := func(chip *babybear.Chip, in []babybear.Variable) []babybear.Variable {
+		a, b := ext(in[:4]), ext(in[4:])
+		quotient, inv := chip.DivE(a, b), chip.InvE(b)
+		return append(quotient.Value[:], inv.Value[:]...)
+	}
+
+	f.Fuzz(func(t *testing.T, a0, a1, a2, a3, b0, b1, b2, b3 uint32) {
+		in := []uint32{a0, a1, a2, a3, b0, b1, b2, b3}
+		x, y := canonicalE(in[:4]), canonicalE(in[4:])
+		var expected []uint32
+		for _, e := range [][4]uint32{
+			{addF(x[0], y[0]), addF(x[1], y[1]), addF(x[2], y[2]), addF(x[3], y[3])},
+			{subF(x[0], y[0]), subF(x[1], y[1]), subF(x[2], y[2]), subF(x[3], y[3])},
+			{},
+			mulE(x, y),
+			{subF(0, y[0]), subF(0, y[1]), subF(0, y[2]), subF(0, y[3])},
+		} {
+			expected = append(expected, e[:]...)
+		}
+		for name, opts := range map[string][]babybear.ChipOption{"flat": nil, "tower": {babybear.WithTowerExtension()}} {
+			if err := solveFuzz(arithmetic, in, expected, opts...); err != nil {
+				t.Fatalf("%s: %v: %v", name, in, err)
+			}
+		}
+
+		if y == [4]uint32{} {
+			if err := solveFuzz(division, in, make([]uint32, 8)); err == nil {
+				t.Fatalf("%v: expected a zero divisor to be rejected", in)
+			}
+			return
+		}
+		inv := invE(y)
+		quotient := mulE(x, inv)
+		if err := solveFuzz(division, in, append(quotient[:], inv[:]...)); err != nil {
+			t.Fatalf("%v: %v", in, err)
+		}
+	})
+}
+
+// inverseF inverts the non-zero canonical x by Fermat's little theorem.
+func inverseF(x uint32) uint32 {
+	result, base := uint32(1), x
+	for e := p - 2; e > 0; e >>= 1 {
+		if e&1 == 1 {
+			result = mulF(result, base)
+		}
+		base = mulF(base, base)
+	}
+	return result
+}
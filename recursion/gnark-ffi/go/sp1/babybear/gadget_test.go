@@ -0,0 +1,380 @@
+package babybear_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear/babybeartest"
+)
+
+const seeds = 16
+
+var p = babybear.MODULUS.Uint64()
+
+func addF(a, b uint32) uint32 { return uint32((uint64(a) + uint64(b)) % p) }
+func subF(a, b uint32) uint32 { return uint32((uint64(a) + p - uint64(b)) % p) }
+func mulF(a, b uint32) uint32 { return uint32(uint64(a) * uint64(b) % p) }
+
+// The extension arithmetic of the tests is the one of babybear.BabyBear, on uint32 coordinates.
+
+func bigE(a [4]uint32) [4]*big.Int {
+	var out [4]*big.Int
+	for i := range out {
+		out[i] = new(big.Int).SetUint64(uint64(a[i]))
+	}
+	return out
+}
+
+func uint32E(a [4]*big.Int) [4]uint32 {
+	var out [4]uint32
+	for i := range out {
+		out[i] = uint32(a[i].Uint64())
+	}
+	return out
+}
+
+func mulE(a, b [4]uint32) [4]uint32 { return uint32E(babybear.BabyBear.MulE(bigE(a), bigE(b))) }
+func invE(a [4]uint32) [4]uint32    { return uint32E(babybear.BabyBear.InvE(bigE(a))) }
+
+func TestArithmeticF(t *testing.T) {
+	babybeartest.RunGadget(t, func(chip *babybear.Chip, in []babybear.Variable) []babybear.Variable {
+		return []babybear.Variable{
+			chip.AddF(in[0], in[1]),
+			chip.SubF(in[0], in[1]),
+			chip.MulF(in[0], in[1]),
+			chip.MulAddF(in[0], in[1], in[2]),
+			chip.NegF(in[2]),
+		}
+	}, func(in []uint32) []uint32 {
+		return []uint32{
+			addF(in[0], in[1]),
+			subF(in[0], in[1]),
+			mulF(in[0], in[1]),
+			addF(mulF(in[0], in[1]), in[2]),
+			subF(0, in[2]),
+		}
+	}, 3, seeds)
+}
+
+func TestMinMaxCanonical(t *testing.T) {
+	babybeartest.RunGadget(t, func(chip *babybear.Chip, in []babybear.Variable) []babybear.Variable {
+		min, max := chip.MinMaxF(in[0], in[1])
+		isLess := babybear.Variable{Value: chip.IsLessThanF(in[0], in[1]), NbBits: 31}
+		return []babybear.Variable{min, max, isLess}
+	}, func(in []uint32) []uint32 {
+		if in[0] < in[1] {
+			return []uint32{in[0], in[1], 1}
+		}
+		return []uint32{in[1], in[0], 0}
+	}, 2, seeds)
+}
+
+func TestToBinaryStrictCanonical(t *testing.T) {
+	babybeartest.RunGadget(t, func(chip *babybear.Chip, in []babybear.Variable) []babybear.Variable {
+		bits := chip.ToBinaryStrict(in[0])
+		out := make([]babybear.Variable, len(bits))
+		for i, bit := range bits {
+			out[i] = babybear.Variable{Value: bit, NbBits: 31}
+		}
+		return out
+	}, func(in []uint32) []uint32 {
+		out := make([]uint32, 32)
+		for i := range out {
+			out[i] = in[0] >> i & 1
+		}
+		return out
+	}, 1, seeds)
+}
+
+func TestMulE(t *testing.T) {
+	// Products of unreduced products reach the largest bounds of the lazy accumulation.
+	babybeartest.RunGadgetE(t, func(chip *babybear.Chip, in []babybear.ExtensionVariable) []babybear.ExtensionVariable {
+		ab := chip.MulE(in[0], in[1])
+		bb := chip.MulE(in[1], in[1])
+		return []babybear.ExtensionVariable{ab, chip.MulE(ab, bb)}
+	}, func(in [][4]uint32) [][4]uint32 {
+		ab := mulE(in[0], in[1])
+		return [][4]uint32{ab, mulE(ab, mulE(in[1], in[1]))}
+	}, 2, seeds)
+}
+
+func TestTowerMulE(t *testing.T) {
+	// The products are unreduced when multiplied again, which exercises the bounds of the lazy
+	// tower arithmetic.
+	babybeartest.RunGadgetE(t, func(chip *babybear.Chip, in []babybear.ExtensionVariable) []babybear.ExtensionVariable {
+		ab := chip.MulE(in[0], in[1])
+		bb := chip.MulE(in[1], in[1])
+		return []babybear.ExtensionVariable{ab, chip.MulE(ab, bb)}
+	}, func(in [][4]uint32) [][4]uint32 {
+		ab := mulE(in[0], in[1])
+		return [][4]uint32{ab, mulE(ab, mulE(in[1], in[1]))}
+	}, 2, seeds, babybear.WithTowerExtension())
+}
+
+func TestTowerInvE(t *testing.T) {
+	babybeartest.RunGadgetE(t, func(chip *babybear.Chip, in []babybear.ExtensionVariable) []babybear.ExtensionVariable {
+		return []babybear.ExtensionVariable{chip.InvE(in[0]), chip.DivE(in[0], in[1])}
+	}, func(in [][4]uint32) [][4]uint32 {
+		return [][4]uint32{invE(in[0]), mulE(in[0], invE(in[1]))}
+	}, 2, seeds, babybear.WithTowerExtension())
+}
+
+func TestDivF(t *testing.T) {
+	// The quotient of a product by one of its factors is the other factor, and the dividend may be
+	// unreduced. The divisor is made nonzero.
+	babybeartest.RunGadget(t, func(chip *babybear.Chip, in []babybear.Variable) []babybear.Variable {
+		b := chip.AddF(chip.MulF(in[1], in[1]), babybear.NewF("1"))
+		product := chip.MulF(in[0], b)
+		return []babybear.Variable{
+			chip.DivF(product, b),
+			chip.DivF(chip.AddF(product, product), b),
+		}
+	}, func(in []uint32) []uint32 {
+		return []uint32{in[0], addF(in[0], in[0])}
+	}, 2, seeds)
+}
+
+func TestDivE(t *testing.T) {
+	// The quotient of a product by one of its factors is the other factor, with either extension
+	// arithmetic.
+	gadget := func(chip *babybear.Chip, in []babybear.ExtensionVariable) []babybear.ExtensionVariable {
+		return []babybear.ExtensionVariable{chip.DivE(chip.MulE(in[0], in[1]), in[1])}
+	}
+	reference := func(in [][4]uint32) [][4]uint32 {
+		return in[:1]
+	}
+	babybeartest.RunGadgetE(t, gadget, reference, 2, seeds)
+	babybeartest.RunGadgetE(t, gadget, reference, 2, seeds, babybear.WithTowerExtension())
+}
+
+func TestTowerRoundTrip(t *testing.T) {
+	babybeartest.RunGadgetE(t, func(chip *babybear.Chip, in []babybear.ExtensionVariable) []babybear.ExtensionVariable {
+		return []babybear.ExtensionVariable{babybear.TowerToFlat(babybear.FlatToTower(in[0]))}
+	}, func(in [][4]uint32) [][4]uint32 {
+		return in
+	}, 1, seeds)
+}
+
+// exponents are constant exponents at the edges of square-and-multiply, the exponent of the
+// inverse, and an exponent larger than the field.
+var exponents = []*big.Int{
+	big.NewInt(0), big.NewInt(1), big.NewInt(2), big.NewInt(7),
+	new(big.Int).Sub(babybear.MODULUS, big.NewInt(2)),
+	new(big.Int).Lsh(big.NewInt(0x123456789), 70),
+}
+
+func expF(a uint32, e *big.Int) uint32 {
+	return uint32(new(big.Int).Exp(new(big.Int).SetUint64(uint64(a)), e, babybear.MODULUS).Uint64())
+}
+
+// expE computes a^e right to left, unlike the chip.
+func expE(a [4]uint32, e *big.Int) [4]uint32 {
+	result := [4]uint32{1, 0, 0, 0}
+	for i := 0; i < e.BitLen(); i++ {
+		if e.Bit(i) == 1 {
+			result = mulE(result, a)
+		}
+		a = mulE(a, a)
+	}
+	return result
+}
+
+func TestExpF(t *testing.T) {
+	babybeartest.RunGadget(t, func(chip *babybear.Chip, in []babybear.Variable) []babybear.Variable {
+		out := make([]babybear.Variable, 0, len(exponents)+2)
+		for _, e := range exponents {
+			out = append(out, chip.ExpF(in[0], e))
+		}
+		out = append(out, chip.ExpPowerOf2F(in[0], 5))
+		return append(out, chip.ExpFBits(in[0], chip.ToBinary(in[1])))
+	}, func(in []uint32) []uint32 {
+		out := make([]uint32, 0, len(exponents)+2)
+		for _, e := range exponents {
+			out = append(out, expF(in[0], e))
+		}
+		out = append(out, expF(in[0], big.NewInt(32)))
+		return append(out, expF(in[0], new(big.Int).SetUint64(uint64(in[1]))))
+	}, 2, seeds)
+}
+
+func TestExpE(t *testing.T) {
+	babybeartest.RunGadgetE(t, func(chip *babybear.Chip, in []babybear.ExtensionVariable) []babybear.ExtensionVariable {
+		out := make([]babybear.ExtensionVariable, 0, len(exponents)+1)
+		for _, e := range exponents {
+			out = append(out, chip.ExpE(in[0], e))
+		}
+		return append(out, chip.ExpPowerOf2E(in[0], 5))
+	}, func(in [][4]uint32) [][4]uint32 {
+		out := make([][4]uint32, 0, len(exponents)+1)
+		for _, e := range exponents {
+			out = append(out, expE(in[0], e))
+		}
+		return append(out, expE(in[0], big.NewInt(32)))
+	}, 1, seeds)
+}
+
+func TestMixedE(t *testing.T) {
+	// The mixed operations agree with the operations on the felt embedded in the extension field.
+	babybeartest.RunGadgetE(t, func(chip *babybear.Chip, in []babybear.ExtensionVariable) []babybear.ExtensionVariable {
+		a := in[0]
+		// The felt is made nonzero so that it can be inverted.
+		b := chip.AddF(chip.MulF(in[1].Value[0], in[1].Value[0]), babybear.NewF("1"))
+		embedded := babybear.Felts2Ext(b, babybear.NewF("0"), babybear.NewF("0"), babybear.NewF("0"))
+		return []babybear.ExtensionVariable{
+			chip.SubE(chip.AddEF(a, b), chip.AddE(a, embedded)),
+			chip.SubE(chip.SubEF(a, b), chip.SubE(a, embedded)),
+			chip.SubE(chip.MulEF(a, b), chip.MulE(a, embedded)),
+			chip.SubE(chip.DivEF(a, b), chip.DivE(a, embedded)),
+		}
+	}, func(in [][4]uint32) [][4]uint32 {
+		return make([][4]uint32, 4)
+	}, 2, seeds)
+}
+
+func TestFelt2Ext(t *testing.T) {
+	babybeartest.RunGadget(t, func(chip *babybear.Chip, in []babybear.Variable) []babybear.Variable {
+		out := chip.Ext2Felt(babybear.Felt2Ext(in[0]))
+		return out[:]
+	}, func(in []uint32) []uint32 {
+		return []uint32{in[0], 0, 0, 0}
+	}, 1, seeds)
+}
+
+func TestExtensionAccessors(t *testing.T) {
+	// Another package builds elements from felts and reads their coordinates back.
+	babybeartest.RunGadgetE(t, func(chip *babybear.Chip, in []babybear.ExtensionVariable) []babybear.ExtensionVariable {
+		a, b := in[0].Coordinates(), in[1]
+		reversed := babybear.NewEFromVars([4]*babybear.Variable{&a[3], &a[2], &a[1], &a[0]})
+		mixed := babybear.Felts2Ext(b.Coordinate(0), a[1], b.Coordinate(2), a[3])
+		return []babybear.ExtensionVariable{reversed, mixed, chip.AddE(b, chip.Zero()), chip.MulE(b, chip.One()), chip.One()}
+	}, func(in [][4]uint32) [][4]uint32 {
+		a, b := in[0], in[1]
+		return [][4]uint32{{a[3], a[2], a[1], a[0]}, {b[0], a[1], b[2], a[3]}, b, b, {1, 0, 0, 0}}
+	}, 2, seeds)
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a nil coordinate to be refused")
+		}
+	}()
+	babybear.NewEFromVars([4]*babybear.Variable{})
+}
+
+func TestIsZeroE(t *testing.T) {
+	isZero := func(chip *babybear.Chip, in babybear.ExtensionVariable) babybear.ExtensionVariable {
+		return babybear.Felt2Ext(babybear.Variable{Value: chip.IsZeroE(in), NbBits: 31})
+	}
+	babybeartest.RunGadgetE(t, func(chip *babybear.Chip, in []babybear.ExtensionVariable) []babybear.ExtensionVariable {
+		// The difference of an element with itself is zero, and p in a coordinate is zero too.
+		withModulus := in[0]
+		withModulus.Value[2] = babybear.Variable{Value: babybear.MODULUS, NbBits: 32}
+		return []babybear.ExtensionVariable{
+			isZero(chip, in[0]),
+			isZero(chip, chip.SubE(in[0], in[0])),
+			isZero(chip, babybear.ZeroE()),
+			isZero(chip, babybear.OneE()),
+			isZero(chip, chip.SubE(withModulus, babybear.Felts2Ext(in[0].Value[0], in[0].Value[1], babybear.NewF("0"), in[0].Value[3]))),
+		}
+	}, func(in [][4]uint32) [][4]uint32 {
+		var expected uint32
+		if in[0] == [4]uint32{} {
+			expected = 1
+		}
+		return [][4]uint32{{expected}, {1}, {1}, {0}, {1}}
+	}, 1, seeds)
+}
+
+func reverseBitsLen(index uint32, bitLen int) uint32 {
+	var out uint32
+	for i := 0; i < bitLen; i++ {
+		out |= (index >> i & 1) << (bitLen - 1 - i)
+	}
+	return out
+}
+
+func TestExpReverseBitsLen(t *testing.T) {
+	// The index is a random felt, so its bits above bitLen are usually set and must be ignored.
+	for _, bitLen := range []int{0, 1, 7, 20, 27} {
+		babybeartest.RunGadget(t, func(chip *babybear.Chip, in []babybear.Variable) []babybear.Variable {
+			bits := chip.ToBinary(in[1])
+			return []babybear.Variable{
+				chip.ExpReverseBitsLen(in[0], bits, bitLen),
+				chip.ExpReverseBitsLen(in[0], chip.ToBinary(babybear.NewF("0")), bitLen),
+			}
+		}, func(in []uint32) []uint32 {
+			return []uint32{expF(in[0], big.NewInt(int64(reverseBitsLen(in[1], bitLen)))), 1}
+		}, 2, seeds)
+	}
+}
+
+func TestBatchF(t *testing.T) {
+	babybeartest.RunGadget(t, func(chip *babybear.Chip, in []babybear.Variable) []babybear.Variable {
+		a, b := in[:2], in[2:]
+		products := chip.MulFs(a, b)
+		// The unreduced products are compared through their difference, the inputs directly.
+		chip.AssertIsEqualFs(products, chip.MulFs(b, a))
+		chip.AssertIsEqualFs(a, a)
+		return append(chip.AddFs(a, b), products...)
+	}, func(in []uint32) []uint32 {
+		return []uint32{addF(in[0], in[2]), addF(in[1], in[3]), mulF(in[0], in[2]), mulF(in[1], in[3])}
+	}, 4, seeds)
+}
+
+// innerProductGadget computes the inner product of 40 pairs of the inputs, whose bounds are
+// widened to 2^120 so that the sums are reduced along the way, and checks it against MulE.
+func innerProductGadget(chip *babybear.Chip, in []babybear.ExtensionVariable) []babybear.ExtensionVariable {
+	a := make([]babybear.ExtensionVariable, 40)
+	b := make([]babybear.ExtensionVariable, 40)
+	for i := range a {
+		a[i], b[i] = in[i%2], in[(i+1)%3]
+		for k := 0; k < 4; k++ {
+			a[i].Value[k].NbBits = 120
+			b[i].Value[k].NbBits = 120
+		}
+	}
+	out := chip.InnerProductE(a, b)
+	products := make([]babybear.ExtensionVariable, len(a))
+	for i := range a {
+		products[i] = chip.MulE(in[i%2], in[(i+1)%3])
+	}
+	chip.AssertIsEqualEs([]babybear.ExtensionVariable{out}, []babybear.ExtensionVariable{chip.SumE(products...)})
+	return []babybear.ExtensionVariable{out, chip.InnerProductE(in[:1], in[1:2])}
+}
+
+func innerProductReference(in [][4]uint32) [][4]uint32 {
+	var sum [4]uint32
+	for i := 0; i < 40; i++ {
+		product := mulE(in[i%2], in[(i+1)%3])
+		for k := range sum {
+			sum[k] = addF(sum[k], product[k])
+		}
+	}
+	return [][4]uint32{sum, mulE(in[0], in[1])}
+}
+
+func TestInnerProductE(t *testing.T) {
+	babybeartest.RunGadgetE(t, innerProductGadget, innerProductReference, 3, seeds)
+	babybeartest.RunGadgetE(t, innerProductGadget, innerProductReference, 3, seeds, babybear.WithTowerExtension())
+}
+
+func TestSwap(t *testing.T) {
+	babybeartest.RunGadget(t, func(chip *babybear.Chip, in []babybear.Variable) []babybear.Variable {
+		first, second := chip.SwapF(chip.ToBinary(in[0])[0], in[1], in[2])
+		return []babybear.Variable{first, second}
+	}, func(in []uint32) []uint32 {
+		if in[0]&1 == 1 {
+			return []uint32{in[2], in[1]}
+		}
+		return []uint32{in[1], in[2]}
+	}, 3, seeds)
+	babybeartest.RunGadgetE(t, func(chip *babybear.Chip, in []babybear.ExtensionVariable) []babybear.ExtensionVariable {
+		first, second := chip.SwapE(chip.ToBinary(in[0].Value[0])[0], in[1], in[2])
+		return []babybear.ExtensionVariable{first, second}
+	}, func(in [][4]uint32) [][4]uint32 {
+		if in[0][0]&1 == 1 {
+			return [][4]uint32{in[2], in[1]}
+		}
+		return [][4]uint32{in[1], in[2]}
+	}, 3, seeds)
+}
@@ -0,0 +1,197 @@
+package babybear
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/lookup/logderivlookup"
+	"github.com/consensys/gnark/std/selector"
+)
+
+// tableKey identifies a constant table by its backing array, so that repeated lookups into the
+// same table share a single log-derivative argument.
+type tableKey struct {
+	base *uint64
+	ext  *[4]uint64
+	n    int
+}
+
+// WithMuxLookups makes the chip read constant tables through a multiplexer even when the builder
+// supports commitments. This is cheaper for tables that are only read once or twice.
+func WithMuxLookups() ChipOption {
+	return func(c *Chip) {
+		c.muxLookups = true
+	}
+}
+
+// LookupConstTable returns table[index]. When the builder supports commitments the table is read
+// through a log-derivative lookup, whose cost is linear in the table size once and constant per
+// read, and otherwise through a multiplexer. The index is reduced and checked to be below the
+// length of the table, and the entries must be canonical.
+func (c *Chip) LookupConstTable(table []uint64, index Variable) Variable {
+	defer c.traceOperation("LookupConstTable")()
+	for i, entry := range table {
+		if entry >= c.field.Modulus.Uint64() {
+			panic(fmt.Sprintf("table entry %d: %d is not a canonical %s element", i, entry, c.field.Name))
+		}
+	}
+	idx := c.checkTableIndex(index, len(table))
+	if !c.useLookupTables() {
+		inputs := make([]frontend.Variable, len(table))
+		for i, entry := range table {
+			inputs[i] = entry
+		}
+		return Variable{Value: selector.Mux(c.api, idx, inputs...), NbBits: 31}
+	}
+
+	key := tableKey{base: &table[0], n: len(table)}
+	t, ok := c.tables[key]
+	if !ok {
+		t = logderivlookup.New(c.api)
+		for _, entry := range table {
+			t.Insert(entry)
+		}
+		c.tables[key] = t
+	}
+	return Variable{Value: t.Lookup(idx)[0], NbBits: 31}
+}
+
+// LookupConstTableE is the extension valued variant of LookupConstTable. On the lookup path the
+// coordinates are stored in a single table of 4 * len(table) entries and read with 4 queries.
+func (c *Chip) LookupConstTableE(table [][4]uint64, index Variable) ExtensionVariable {
+	defer c.traceOperation("LookupConstTableE")()
+	for i, entry := range table {
+		for j, coordinate := range entry {
+			if coordinate >= c.field.Modulus.Uint64() {
+				panic(fmt.Sprintf("table entry %d: coordinate %d: %d is not a canonical %s element", i, j, coordinate, c.field.Name))
+			}
+		}
+	}
+	idx := c.checkTableIndex(index, len(table))
+	var out ExtensionVariable
+	if !c.useLookupTables() {
+		for j := 0; j < 4; j++ {
+			inputs := make([]frontend.Variable, len(table))
+			for i, entry := range table {
+				inputs[i] = entry[j]
+			}
+			out.Value[j] = Variable{Value: selector.Mux(c.api, idx, inputs...), NbBits: 31}
+		}
+		return out
+	}
+
+	key := tableKey{ext: &table[0], n: len(table)}
+	t, ok := c.tables[key]
+	if !ok {
+		t = logderivlookup.New(c.api)
+		for _, entry := range table {
+			for _, coordinate := range entry {
+				t.Insert(coordinate)
+			}
+		}
+		c.tables[key] = t
+	}
+	base := c.api.Mul(idx, 4)
+	values := t.Lookup(base, c.api.Add(base, 1), c.api.Add(base, 2), c.api.Add(base, 3))
+	for j := 0; j < 4; j++ {
+		out.Value[j] = Variable{Value: values[j], NbBits: 31}
+	}
+	return out
+}
+
+func (c *Chip) useLookupTables() bool {
+	if c.muxLookups {
+		return false
+	}
+	_, ok := c.api.Compiler().(frontend.Committer)
+	return ok
+}
+
+// checkTableIndex reduces the index and asserts that it is below n. For a table whose length is
+// not a power of two, both the index and n - 1 - index must fit in the bits of n - 1.
+func (c *Chip) checkTableIndex(index Variable, n int) frontend.Variable {
+	if n == 0 {
+		panic("lookup into an empty table")
+	}
+	idx := c.ReduceSlow(index).Value
+	if n == 1 {
+		c.api.AssertIsEqual(idx, 0)
+		return idx
+	}
+	nbBits := bits.Len(uint(n - 1))
+	c.rangeChecker.Check(idx, nbBits)
+	if n&(n-1) != 0 {
+		c.rangeChecker.Check(c.api.Sub(n-1, idx), nbBits)
+	}
+	return idx
+}
+
+// SelectFFromSlice returns values[index] for the index whose little-endian bits are given, which
+// must be boolean and exactly as many as the bits of len(values) - 1. The values are selected
+// pairwise by a balanced tree, one bit per level, so n values cost n - 1 selects. Indices past the
+// end of a slice whose length is not a power of two are rejected.
+func (c *Chip) SelectFFromSlice(indexBits []frontend.Variable, values []Variable) Variable {
+	defer c.traceOperation("SelectFFromSlice")()
+	c.checkSliceIndex(indexBits, len(values))
+	return selectTree(indexBits, values, c.SelectF)
+}
+
+// SelectEFromSlice is the extension field variant of SelectFFromSlice.
+func (c *Chip) SelectEFromSlice(indexBits []frontend.Variable, values []ExtensionVariable) ExtensionVariable {
+	defer c.traceOperation("SelectEFromSlice")()
+	c.checkSliceIndex(indexBits, len(values))
+	return selectTree(indexBits, values, c.SelectE)
+}
+
+// SwapF returns (b, a) if cond is 1 and (a, b) if it is 0, like the pair of a Merkle path node
+// and its sibling ordered by an index bit. cond must be boolean. Both outputs share a single
+// product, where two SelectF would each need one.
+func (c *Chip) SwapF(cond frontend.Variable, a, b Variable) (Variable, Variable) {
+	defer c.traceOperation("SwapF")()
+	nbBits := max(a.NbBits, b.NbBits)
+	d := c.api.Mul(cond, c.api.Sub(b.Value, a.Value))
+	return Variable{Value: c.api.Add(a.Value, d), NbBits: nbBits}, Variable{Value: c.api.Sub(b.Value, d), NbBits: nbBits}
+}
+
+// SwapE is the extension field variant of SwapF.
+func (c *Chip) SwapE(cond frontend.Variable, a, b ExtensionVariable) (ExtensionVariable, ExtensionVariable) {
+	defer c.traceOperation("SwapE")()
+	var first, second ExtensionVariable
+	for i := 0; i < 4; i++ {
+		first.Value[i], second.Value[i] = c.SwapF(cond, a.Value[i], b.Value[i])
+	}
+	return first, second
+}
+
+// selectTree halves the values with each bit. The last value of an odd level has no sibling and is
+// carried up unselected, since an index selecting its missing sibling is out of range.
+func selectTree[T any](indexBits []frontend.Variable, values []T, sel func(frontend.Variable, T, T) T) T {
+	level := append([]T(nil), values...)
+	for _, bit := range indexBits {
+		half := len(level) / 2
+		for j := 0; j < half; j++ {
+			level[j] = sel(bit, level[2*j+1], level[2*j])
+		}
+		if len(level)%2 == 1 {
+			level[half] = level[len(level)-1]
+			half++
+		}
+		level = level[:half]
+	}
+	return level[0]
+}
+
+// checkSliceIndex asserts that the index given by its bits is below n, which only constrains
+// anything when n is not a power of two.
+func (c *Chip) checkSliceIndex(indexBits []frontend.Variable, n int) {
+	if n == 0 {
+		panic("select from an empty slice")
+	}
+	if nbBits := bits.Len(uint(n - 1)); len(indexBits) != nbBits {
+		panic(fmt.Sprintf("%d index bits for a slice of %d values, expected %d", len(indexBits), n, nbBits))
+	}
+	if n&(n-1) != 0 {
+		c.rangeChecker.Check(c.api.Sub(n-1, c.api.FromBinary(indexBits...)), len(indexBits))
+	}
+}
@@ -0,0 +1,104 @@
+package babybear
+
+import (
+	"fmt"
+	"math/big"
+	"math/bits"
+)
+
+// The polynomial gadgets evaluate polynomials with coefficients or values in the extension field,
+// as the verification of STARK openings needs them.
+
+// EvalPolyE returns the evaluation at x of the polynomial of the coefficients, from the constant
+// one up, with Horner's method. The polynomial without coefficients is zero.
+func (c *Chip) EvalPolyE(coefficients []ExtensionVariable, x ExtensionVariable) ExtensionVariable {
+	defer c.traceOperation("EvalPolyE")()
+	if len(coefficients) == 0 {
+		return ZeroE()
+	}
+	acc := coefficients[len(coefficients)-1]
+	for i := len(coefficients) - 2; i >= 0; i-- {
+		acc = c.AddE(c.MulE(acc, x), coefficients[i])
+	}
+	return acc
+}
+
+// EvalCosetE returns the evaluation at z of the polynomial of degree below n whose evaluations
+// over the coset shift * H are given, where H is the subgroup of order n, a power of two,
+// generated by g = TwoAdicGenerator(log2(n)). The i-th evaluation is the one at shift * g^i. The
+// barycentric formula
+//
+//	f(z) = (z^n - shift^n) / (n shift^(n-1)) * sum_i v_i g^i / (z - shift g^i)
+//
+// takes a division per evaluation. z must not belong to the coset, where a denominator is zero
+// and makes the circuit unsatisfiable.
+func (c *Chip) EvalCosetE(evaluations []ExtensionVariable, shift uint64, z ExtensionVariable) ExtensionVariable {
+	defer c.traceOperation("EvalCosetE")()
+	n := len(evaluations)
+	logN := bits.Len(uint(n)) - 1
+	if n == 0 || n != 1<<logN || logN > c.field.TwoAdicity {
+		panic(fmt.Sprintf("%s has no subgroup of order %d", c.field.Name, n))
+	}
+	p := c.field.Modulus
+	s := new(big.Int).SetUint64(shift)
+	if s.Sign() == 0 || s.Cmp(p) >= 0 {
+		panic(fmt.Sprintf("the shift %d is not a nonzero canonical %s element", shift, c.field.Name))
+	}
+
+	g := c.field.TwoAdicGenerator(logN)
+	gi := big.NewInt(1)
+	terms := make([]ExtensionVariable, n)
+	for i, v := range evaluations {
+		point := new(big.Int).Mul(s, gi)
+		terms[i] = c.DivE(c.MulEF(v, NewF(gi.String())), c.SubEF(z, NewF(point.Mod(point, p).String())))
+		gi = new(big.Int).Mod(gi.Mul(gi, g), p)
+	}
+
+	shiftN := new(big.Int).Exp(s, big.NewInt(int64(n)), p)
+	scale := new(big.Int).Mul(big.NewInt(int64(n)), new(big.Int).Exp(s, big.NewInt(int64(n-1)), p))
+	scale.ModInverse(scale.Mod(scale, p), p)
+	vanishing := c.SubEF(c.ExpPowerOf2E(z, logN), NewF(shiftN.String()))
+	return c.MulEF(c.MulE(vanishing, c.SumE(terms...)), NewF(scale.String()))
+}
+
+// InterpolateE returns the evaluation at z of the polynomial of degree below len(xs) that takes
+// the value ys[i] at xs[i], in the Lagrange form
+//
+//	sum_i ys[i] prod_{j != i} (z - xs[j]) / (xs[i] - xs[j]).
+//
+// The denominators take a quadratic number of products, meant for small degrees, which fold away
+// when the points are constants. The points must be distinct: a zero denominator makes the circuit
+// unsatisfiable.
+func (c *Chip) InterpolateE(xs, ys []ExtensionVariable, z ExtensionVariable) ExtensionVariable {
+	defer c.traceOperation("InterpolateE")()
+	checkBatchLengths("InterpolateE", len(xs), len(ys))
+	n := len(xs)
+	if n == 0 {
+		return ZeroE()
+	}
+
+	// The numerator of the i-th term is the product of the differences before and after i.
+	differences := make([]ExtensionVariable, n)
+	for j, x := range xs {
+		differences[j] = c.SubE(z, x)
+	}
+	prefixes := make([]ExtensionVariable, n)
+	prefixes[0] = OneE()
+	for i := 1; i < n; i++ {
+		prefixes[i] = c.MulE(prefixes[i-1], differences[i-1])
+	}
+
+	terms := make([]ExtensionVariable, n)
+	suffix := OneE()
+	for i := n - 1; i >= 0; i-- {
+		denominator := OneE()
+		for j := range xs {
+			if j != i {
+				denominator = c.MulE(denominator, c.SubE(xs[i], xs[j]))
+			}
+		}
+		terms[i] = c.DivE(c.MulE(ys[i], c.MulE(prefixes[i], suffix)), denominator)
+		suffix = c.MulE(suffix, differences[i])
+	}
+	return c.SumE(terms...)
+}
@@ -0,0 +1,141 @@
+package babybear
+
+import (
+	"math/big"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+)
+
+const (
+	polyLogSize = 3
+	polySize    = 1 << polyLogSize
+	// The degree bound of the interpolated polynomials.
+	interpolationSize = 4
+)
+
+// TestPolyCircuit evaluates the same polynomial at Z from its coefficients, its evaluations over
+// the coset Shift * H, and its values at interpolationSize points, when its degree allows it.
+type TestPolyCircuit struct {
+	Coefficients [polySize][4]frontend.Variable
+	Evaluations  [polySize][4]frontend.Variable
+	Xs, Ys       [interpolationSize][4]frontend.Variable
+	Z, Expected  [4]frontend.Variable
+	Field        *Field `gnark:"-"`
+	Shift        uint64 `gnark:"-"`
+}
+
+func (circuit *TestPolyCircuit) Define(api frontend.API) error {
+	chip := NewChip(api, WithField(circuit.Field))
+	ext := func(values [][4]frontend.Variable) []ExtensionVariable {
+		out := make([]ExtensionVariable, len(values))
+		for i, v := range values {
+			out[i] = newTestExt(v)
+		}
+		return out
+	}
+	z, expected := newTestExt(circuit.Z), newTestExt(circuit.Expected)
+	chip.AssertIsEqualE(chip.EvalPolyE(ext(circuit.Coefficients[:]), z), expected)
+	chip.AssertIsEqualE(chip.EvalCosetE(ext(circuit.Evaluations[:]), circuit.Shift, z), expected)
+	chip.AssertIsEqualE(chip.InterpolateE(ext(circuit.Xs[:]), ext(circuit.Ys[:]), z), expected)
+	return nil
+}
+
+// evalPoly is EvalPolyE in Go.
+func evalPoly(field *Field, coefficients [][4]*big.Int, x [4]*big.Int) [4]*big.Int {
+	acc := [4]*big.Int{new(big.Int), new(big.Int), new(big.Int), new(big.Int)}
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		acc = field.MulE(acc, x)
+		for j := range acc {
+			acc[j].Add(acc[j], coefficients[i][j]).Mod(acc[j], field.Modulus)
+		}
+	}
+	return acc
+}
+
+// polyAssignment assigns a random polynomial of degree below interpolationSize, so that all the
+// evaluations agree.
+func polyAssignment(rng *rand.Rand, field *Field, shift uint64) *TestPolyCircuit {
+	coefficients := make([][4]*big.Int, polySize)
+	for i := range coefficients {
+		coefficients[i] = [4]*big.Int{new(big.Int), new(big.Int), new(big.Int), new(big.Int)}
+		if i < interpolationSize {
+			coefficients[i] = randomExt(rng, field)
+		}
+	}
+	z := randomExt(rng, field)
+	assignment := &TestPolyCircuit{}
+	set := func(dst *[4]frontend.Variable, v [4]*big.Int) {
+		for j := range v {
+			dst[j] = v[j]
+		}
+	}
+
+	g := field.TwoAdicGenerator(polyLogSize)
+	point := new(big.Int).SetUint64(shift)
+	for i := range coefficients {
+		set(&assignment.Coefficients[i], coefficients[i])
+		x := [4]*big.Int{point, new(big.Int), new(big.Int), new(big.Int)}
+		set(&assignment.Evaluations[i], evalPoly(field, coefficients, x))
+		point = new(big.Int).Mod(new(big.Int).Mul(point, g), field.Modulus)
+	}
+	for i := 0; i < interpolationSize; i++ {
+		x := randomExt(rng, field)
+		set(&assignment.Xs[i], x)
+		set(&assignment.Ys[i], evalPoly(field, coefficients, x))
+	}
+	set(&assignment.Z, z)
+	set(&assignment.Expected, evalPoly(field, coefficients, z))
+	return assignment
+}
+
+func TestPolyEvaluation(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	for _, field := range []*Field{BabyBear, KoalaBear} {
+		circuit := &TestPolyCircuit{Field: field, Shift: field.Generator.Uint64()}
+		for seed := 0; seed < 4; seed++ {
+			assignment := polyAssignment(rng, field, circuit.Shift)
+			if err := test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()); err != nil {
+				t.Fatalf("%s: %v", field.Name, err)
+			}
+
+			for name, tamper := range map[string]func(a *TestPolyCircuit){
+				"evaluation": func(a *TestPolyCircuit) { a.Evaluations[5][2] = big.NewInt(1) },
+				"value":      func(a *TestPolyCircuit) { a.Ys[1][0] = big.NewInt(1) },
+				// A point of the coset, where a barycentric denominator is zero.
+				"z on the coset": func(a *TestPolyCircuit) {
+					a.Z = [4]frontend.Variable{circuit.Shift, 0, 0, 0}
+					a.Expected = a.Evaluations[0]
+				},
+				// Repeated points, where a Lagrange denominator is zero.
+				"repeated point": func(a *TestPolyCircuit) { a.Xs[2], a.Ys[2] = a.Xs[0], a.Ys[0] },
+			} {
+				tampered := polyAssignment(rand.New(rand.NewSource(int64(seed))), field, circuit.Shift)
+				tamper(tampered)
+				if err := test.IsSolved(circuit, tampered, ecc.BN254.ScalarField()); err == nil {
+					t.Fatalf("%s: expected the %s tampering to be rejected", field.Name, name)
+				}
+			}
+		}
+	}
+
+	if err := test.IsSolved(&testCosetSizeCircuit{}, &testCosetSizeCircuit{Z: [4]frontend.Variable{0, 0, 0, 0}}, ecc.BN254.ScalarField()); err == nil || !strings.Contains(err.Error(), "no subgroup of order 3") {
+		t.Errorf("expected a coset of 3 evaluations to be refused, got %v", err)
+	}
+}
+
+// testCosetSizeCircuit evaluates over a coset whose size is not a power of two.
+type testCosetSizeCircuit struct {
+	Z [4]frontend.Variable
+}
+
+func (circuit *testCosetSizeCircuit) Define(api frontend.API) error {
+	chip := NewChip(api)
+	z := newTestExt(circuit.Z)
+	chip.EvalCosetE([]ExtensionVariable{z, z, z}, 31, z)
+	return nil
+}
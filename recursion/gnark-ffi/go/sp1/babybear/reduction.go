@@ -0,0 +1,69 @@
+package babybear
+
+import "fmt"
+
+// The chip tracks a bound on every value, and reduces the result of an operation once its bound
+// reaches the reduction threshold. The default threshold of 120 bits keeps the product of two
+// unreduced values below the native modulus. WithMaxUnreducedDepth raises it for depths above 2,
+// so that long chains of operations on reduced values, such as Horner evaluations, are reduced
+// less often, and larger reductions are cheaper per bit. Either way, the operands of an operation are reduced first when
+// its result could otherwise wrap around the native modulus.
+
+// The default reduction threshold, in bits.
+const defaultReduceBits = 120
+
+// The bits the extension products add to the sum of the bounds of their operands, accumulating up
+// to 4 products and multiplying by W, in the flat and the tower representations alike.
+const mulEGrowthBits = 7
+
+// WithMaxUnreducedDepth makes the chip leave the results of up to n chained multiplications of
+// reduced values unreduced: a value is reduced once its bound reaches 31 (n + 1) bits. n ranges
+// from 1 to 7, the depth whose bound still fits below the native modulus. The option never lowers
+// the default threshold of 120 bits, which depths 1 and 2 keep.
+func WithMaxUnreducedDepth(n int) ChipOption {
+	if n < 1 || n > 7 {
+		panic(fmt.Sprintf("unreduced depth %d is not between 1 and 7", n))
+	}
+	return func(c *Chip) {
+		c.reduceBits = max(defaultReduceBits, uint(31*(n+1)))
+	}
+}
+
+// maxUnreducedBits returns the largest bound of an unreduced value. ReduceWithMaxBits is sound up
+// to 2 bits below the native modulus, and assertIsEqualModP adds a bit to its operands.
+func (c *Chip) maxUnreducedBits() uint {
+	return uint(c.api.Compiler().FieldBitLen() - 3)
+}
+
+// fitOperands reduces the widest of the operands until bound, the bound of the result of the
+// operation computed from them, is at most maxUnreducedBits.
+func (c *Chip) fitOperands(bound func() uint, operands ...*Variable) {
+	for bound() > c.maxUnreducedBits() {
+		widest := operands[0]
+		for _, operand := range operands[1:] {
+			if operand.NbBits > widest.NbBits {
+				widest = operand
+			}
+		}
+		if widest.NbBits == 31 {
+			panic(fmt.Sprintf("the bound of %d bits does not fit below the native modulus", bound()))
+		}
+		*widest = c.ReduceSlow(*widest)
+	}
+}
+
+// fitMulE is fitOperands for the extension product of a and b.
+func (c *Chip) fitMulE(a, b *ExtensionVariable) {
+	operands := make([]*Variable, 0, 8)
+	for i := range a.Value {
+		operands = append(operands, &a.Value[i], &b.Value[i])
+	}
+	widest := func(e *ExtensionVariable) uint {
+		var nbBits uint
+		for _, coordinate := range e.Value {
+			nbBits = max(nbBits, coordinate.NbBits)
+		}
+		return nbBits
+	}
+	c.fitOperands(func() uint { return widest(a) + widest(b) + mulEGrowthBits }, operands...)
+}
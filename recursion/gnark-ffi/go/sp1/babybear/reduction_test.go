@@ -0,0 +1,138 @@
+package babybear
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/scs"
+	"github.com/consensys/gnark/test"
+)
+
+const hornerDegree = 64
+
+type TestHornerCircuit struct {
+	Coefficients [hornerDegree][4]frontend.Variable
+	X, Expected  [4]frontend.Variable
+	opts         []ChipOption `gnark:"-"`
+}
+
+func (circuit *TestHornerCircuit) Define(api frontend.API) error {
+	chip := NewChip(api, circuit.opts...)
+	x := newTestExt(circuit.X)
+	acc := newTestExt(circuit.Coefficients[hornerDegree-1])
+	for i := hornerDegree - 2; i >= 0; i-- {
+		acc = chip.AddE(chip.MulE(acc, x), newTestExt(circuit.Coefficients[i]))
+	}
+	chip.AssertIsEqualE(acc, newTestExt(circuit.Expected))
+	return nil
+}
+
+func hornerAssignment(rng *rand.Rand) *TestHornerCircuit {
+	assignment := &TestHornerCircuit{}
+	var coefficients [hornerDegree][4]*big.Int
+	for i := range coefficients {
+		coefficients[i] = randomExt(rng, BabyBear)
+	}
+	x := randomExt(rng, BabyBear)
+	acc := coefficients[hornerDegree-1]
+	for i := hornerDegree - 2; i >= 0; i-- {
+		acc = BabyBear.MulE(acc, x)
+		for j := range acc {
+			acc[j].Add(acc[j], coefficients[i][j]).Mod(acc[j], MODULUS)
+		}
+	}
+	for j := 0; j < 4; j++ {
+		for i := range coefficients {
+			assignment.Coefficients[i][j] = coefficients[i][j]
+		}
+		assignment.X[j], assignment.Expected[j] = x[j], acc[j]
+	}
+	return assignment
+}
+
+func TestMaxUnreducedDepth(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	assignment := hornerAssignment(rng)
+	nbConstraints := func(opts ...ChipOption) int {
+		ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &TestHornerCircuit{opts: opts})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ccs.GetNbConstraints()
+	}
+
+	for _, tower := range []bool{false, true} {
+		var towerOpts []ChipOption
+		if tower {
+			towerOpts = append(towerOpts, WithTowerExtension())
+		}
+		defaultConstraints := nbConstraints(towerOpts...)
+		for depth := 1; depth <= 7; depth++ {
+			opts := append([]ChipOption{WithMaxUnreducedDepth(depth)}, towerOpts...)
+			if err := test.IsSolved(&TestHornerCircuit{opts: opts}, assignment, ecc.BN254.ScalarField()); err != nil {
+				t.Fatalf("depth %d, tower %t: %v", depth, tower, err)
+			}
+			t.Logf("depth %d, tower %t: %d constraints, %d by default", depth, tower, nbConstraints(opts...), defaultConstraints)
+		}
+		// Shallow depths keep the default threshold rather than reducing more often.
+		for _, depth := range []int{1, 2} {
+			if shallow := nbConstraints(append([]ChipOption{WithMaxUnreducedDepth(depth)}, towerOpts...)...); shallow > defaultConstraints {
+				t.Errorf("tower %t: %d constraints at depth %d, above the %d by default", tower, shallow, depth, defaultConstraints)
+			}
+		}
+		// The Horner steps stay unreduced until their bound exceeds 186 bits instead of 120 bits.
+		if deep := nbConstraints(append([]ChipOption{WithMaxUnreducedDepth(5)}, towerOpts...)...); deep >= defaultConstraints*95/100 {
+			t.Errorf("tower %t: %d constraints at depth 5, expected a saving over the %d by default", tower, deep, defaultConstraints)
+		}
+	}
+
+	for _, depth := range []int{0, 8} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected depth %d to be refused", depth)
+				}
+			}()
+			WithMaxUnreducedDepth(depth)
+		}()
+	}
+}
+
+// TestWideOperandsCircuit multiplies operands whose bounds add up beyond the native modulus.
+type TestWideOperandsCircuit struct {
+	A, B     [4]frontend.Variable
+	Expected [4]frontend.Variable
+}
+
+func (circuit *TestWideOperandsCircuit) Define(api frontend.API) error {
+	chip := NewChip(api)
+	a, b := newTestExt(circuit.A), newTestExt(circuit.B)
+	for i := range a.Value {
+		a.Value[i].NbBits, b.Value[i].NbBits = 200, 200
+	}
+	chip.AssertIsEqualE(chip.MulE(a, b), newTestExt(circuit.Expected))
+	chip.AssertIsEqualF(chip.MulF(a.Value[0], b.Value[0]), newTestExt(circuit.Expected).Value[0])
+	return nil
+}
+
+func TestWideOperands(t *testing.T) {
+	// The products of values of 200 bits would wrap around the native modulus: the operands are
+	// reduced first, so the results are still checked modulo p.
+	rng := rand.New(rand.NewSource(0))
+	a, b := randomExt(rng, BabyBear), randomExt(rng, BabyBear)
+	b[1], b[2], b[3] = new(big.Int), new(big.Int), new(big.Int)
+	product := BabyBear.MulE(a, b)
+	assignment := &TestWideOperandsCircuit{}
+	for i := range a {
+		// Unreduced representatives of a and b, below their bound.
+		assignment.A[i] = new(big.Int).Add(a[i], new(big.Int).Lsh(MODULUS, 160))
+		assignment.B[i] = new(big.Int).Add(b[i], new(big.Int).Lsh(MODULUS, 150))
+		assignment.Expected[i] = product[i]
+	}
+	if err := test.IsSolved(&TestWideOperandsCircuit{}, assignment, ecc.BN254.ScalarField()); err != nil {
+		t.Fatal(err)
+	}
+}
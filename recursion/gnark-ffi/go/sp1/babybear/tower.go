@@ -0,0 +1,91 @@
+package babybear
+
+// The quartic extension F[x]/(x^4 - W) is isomorphic to the tower F2[x]/(x^2 - y) over the
+// quadratic extension F2 = F[y]/(y^2 - W), through y = x^2. In this basis a0 + a1 x + a2 x^2 +
+// a3 x^3 is (a0 + a2 y) + (a1 + a3 y) x, so converting between the flat form used by Plonky3 and
+// the tower form only permutes coordinates and costs no constraints.
+
+// WithTowerExtension makes the chip multiply extension elements in the tower representation with
+// Karatsuba's method, using 9 base field multiplications instead of 16 and reducing only the
+// outputs. InvE and DivE verify their results with MulE, so they switch representation as well.
+func WithTowerExtension() ChipOption {
+	return func(c *Chip) {
+		c.towerExtension = true
+	}
+}
+
+// FlatToTower returns the coordinates of a in the tower basis, as two elements of F2.
+func FlatToTower(a ExtensionVariable) [2][2]Variable {
+	return [2][2]Variable{{a.Value[0], a.Value[2]}, {a.Value[1], a.Value[3]}}
+}
+
+// TowerToFlat is the inverse of FlatToTower.
+func TowerToFlat(a [2][2]Variable) ExtensionVariable {
+	return ExtensionVariable{Value: [4]Variable{a[0][0], a[1][0], a[0][1], a[1][1]}}
+}
+
+func (c *Chip) lazyMulETower(a, b ExtensionVariable) [4]Variable {
+	a2 := FlatToTower(a)
+	b2 := FlatToTower(b)
+
+	// (a0 + a1 x)(b0 + b1 x) = a0 b0 + y a1 b1 + ((a0 + a1)(b0 + b1) - a0 b0 - a1 b1) x.
+	v0 := c.mulE2(a2[0], b2[0])
+	v1 := c.mulE2(a2[1], b2[1])
+	s := c.mulE2(c.addE2(a2[0], a2[1]), c.addE2(b2[0], b2[1]))
+	out := [2][2]Variable{
+		{c.lazyAdd(v0[0], c.lazyMulW(v1[1])), c.lazyAdd(v0[1], v1[0])},
+		c.lazySubE2(s, c.addE2(v0, v1)),
+	}
+
+	return TowerToFlat(out).Value
+}
+
+// mulE2 multiplies p0 + p1 y by q0 + q1 y in F2 with Karatsuba's method, without reducing.
+func (c *Chip) mulE2(p, q [2]Variable) [2]Variable {
+	p0q0 := c.lazyMul(p[0], q[0])
+	p1q1 := c.lazyMul(p[1], q[1])
+	cross := c.lazyMul(c.lazyAdd(p[0], p[1]), c.lazyAdd(q[0], q[1]))
+	return [2]Variable{
+		c.lazyAdd(p0q0, c.lazyMulW(p1q1)),
+		c.lazySub(cross, c.lazyAdd(p0q0, p1q1)),
+	}
+}
+
+func (c *Chip) addE2(p, q [2]Variable) [2]Variable {
+	return [2]Variable{c.lazyAdd(p[0], q[0]), c.lazyAdd(p[1], q[1])}
+}
+
+func (c *Chip) lazySubE2(p, q [2]Variable) [2]Variable {
+	return [2]Variable{c.lazySub(p[0], q[0]), c.lazySub(p[1], q[1])}
+}
+
+// The lazy operations work on the unreduced integer values and only track their bounds. Inputs are
+// reduced by fitMulE until the outputs fit below the native modulus, which bounds every
+// intermediate value of lazyMulETower too.
+
+func (c *Chip) lazyAdd(a, b Variable) Variable {
+	return Variable{Value: c.api.Add(a.Value, b.Value), NbBits: max(a.NbBits, b.NbBits) + 1}
+}
+
+// lazyAccumulate is lazyAdd, with the zero Variable standing for an empty sum.
+func (c *Chip) lazyAccumulate(sum, term Variable) Variable {
+	if sum.Value == nil {
+		return term
+	}
+	return c.lazyAdd(sum, term)
+}
+
+func (c *Chip) lazyMul(a, b Variable) Variable {
+	return Variable{Value: c.api.Mul(a.Value, b.Value), NbBits: c.nbBits(a) + c.nbBits(b)}
+}
+
+// lazyMulW multiplies by W, which NewField bounds by 2^4.
+func (c *Chip) lazyMulW(a Variable) Variable {
+	return Variable{Value: c.api.Mul(a.Value, c.field.W), NbBits: a.NbBits + 4}
+}
+
+// lazySub is only used on Karatsuba cross terms, which are sums of products of the inputs and so
+// never negative as integers.
+func (c *Chip) lazySub(a, b Variable) Variable {
+	return Variable{Value: c.api.Sub(a.Value, b.Value), NbBits: a.NbBits}
+}
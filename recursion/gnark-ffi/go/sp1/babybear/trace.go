@@ -0,0 +1,181 @@
+package babybear
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/consensys/gnark/constraint"
+	cs "github.com/consensys/gnark/constraint/bn254"
+	"github.com/consensys/gnark/constraint/solver"
+)
+
+func init() {
+	solver.RegisterHint(traceMarkerHint)
+}
+
+// The operation names of the constraints that no traced operation synthesized, and of the ones
+// added once the circuit is defined, such as the range check tables.
+const (
+	UNTRACED_OPERATION = "untraced"
+	DEFERRED_OPERATION = "deferred"
+)
+
+// Trace records the operations of a chip created with WithTrace, so that the constraints of the
+// compiled circuit can be attributed to them. The builder does not expose its constraint count while
+// the circuit is defined, so each operation is delimited by marker hints left in the constraint
+// system, like the instruction markers of the opcode statistics. Only the outermost operation is
+// recorded: the constraints of an InvE are its own, not those of the MulE it checks the inverse
+// with. A trace records a single compilation of a circuit, and its markers are not meant to be
+// proven.
+type Trace struct {
+	// The operation started by each marker, or the empty string for the end of one.
+	marks []string
+	depth int
+}
+
+// OperationProfile is the number of calls of an operation and of the constraints they synthesized.
+type OperationProfile struct {
+	Name          string
+	Calls         int
+	NbConstraints int
+}
+
+// WithTrace makes the chip record its operations in t.
+func WithTrace(t *Trace) ChipOption {
+	return func(c *Chip) {
+		c.trace = t
+	}
+}
+
+// The hint called around the traced operations. Its only purpose is to leave an instruction in the
+// constraint system recording how many constraints precede it.
+func traceMarkerHint(_ *big.Int, _ []*big.Int, results []*big.Int) error {
+	results[0].SetUint64(0)
+	return nil
+}
+
+// traceOperation marks the start of an operation when the chip is traced, and returns the function
+// marking its end.
+func (c *Chip) traceOperation(name string) func() {
+	if c.trace == nil {
+		return func() {}
+	}
+	t := c.trace
+	if t.depth == 0 {
+		c.traceMark(name)
+	}
+	t.depth++
+	return func() {
+		t.depth--
+		if t.depth == 0 {
+			c.traceMark("")
+		}
+	}
+}
+
+// TraceOperation is traceOperation for the gadgets built on the chip, such as the Poseidon2 chip
+// and the FRI verifier, so that their constraints are profiled along with those of the chip. The
+// returned function ends the operation.
+func (c *Chip) TraceOperation(name string) func() {
+	return c.traceOperation(name)
+}
+
+func (c *Chip) traceMark(name string) {
+	if _, err := c.api.Compiler().NewHint(traceMarkerHint, 1, len(c.trace.marks)); err != nil {
+		panic(err)
+	}
+	c.trace.marks = append(c.trace.marks, name)
+}
+
+// Profile attributes the constraints of scs, the circuit compiled while tracing, to the traced
+// operations, the most expensive first. The constraints outside of any operation are reported as
+// UNTRACED_OPERATION and the deferred ones as DEFERRED_OPERATION, so that the constraints of the
+// profile sum to those of scs.
+func (t *Trace) Profile(scs constraint.ConstraintSystem) ([]OperationProfile, error) {
+	system, ok := scs.(*cs.SparseR1CS)
+	if !ok {
+		return nil, fmt.Errorf("cannot profile a %T constraint system", scs)
+	}
+	offsets := markerOffsets(system)
+	if len(offsets) != len(t.marks) {
+		return nil, fmt.Errorf("the constraint system has %d trace markers, the trace %d", len(offsets), len(t.marks))
+	}
+
+	profiles := make(map[string]*OperationProfile)
+	add := func(name string, calls int, nbConstraints int) {
+		p, ok := profiles[name]
+		if !ok {
+			p = &OperationProfile{Name: name}
+			profiles[name] = p
+		}
+		p.Calls += calls
+		p.NbConstraints += nbConstraints
+	}
+	previous := 0
+	for i, offset := range offsets {
+		name, calls := UNTRACED_OPERATION, 0
+		if i > 0 && t.marks[i-1] != "" {
+			name, calls = t.marks[i-1], 1
+		}
+		add(name, calls, offset-previous)
+		previous = offset
+	}
+	add(DEFERRED_OPERATION, 0, scs.GetNbConstraints()-previous)
+
+	out := make([]OperationProfile, 0, len(profiles))
+	for _, p := range profiles {
+		out = append(out, *p)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].NbConstraints != out[j].NbConstraints {
+			return out[i].NbConstraints > out[j].NbConstraints
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out, nil
+}
+
+// DumpProfile writes the profile of scs to w as a table of lines like
+// "MulE  1843 calls  412301 constraints".
+func (t *Trace) DumpProfile(w io.Writer, scs constraint.ConstraintSystem) error {
+	profile, err := t.Profile(scs)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, p := range profile {
+		fmt.Fprintf(tw, "%s\t%d calls\t%d constraints\n", p.Name, p.Calls, p.NbConstraints)
+	}
+	return tw.Flush()
+}
+
+// Report returns the profile of scs as DumpProfile writes it, followed by the total number of
+// constraints.
+func (t *Trace) Report(scs constraint.ConstraintSystem) (string, error) {
+	var out strings.Builder
+	if err := t.DumpProfile(&out, scs); err != nil {
+		return "", err
+	}
+	fmt.Fprintf(&out, "total: %d constraints\n", scs.GetNbConstraints())
+	return out.String(), nil
+}
+
+// markerOffsets returns, for every trace marker of the constraint system, the number of
+// constraints that precede it.
+func markerOffsets(system *cs.SparseR1CS) []int {
+	id := solver.GetHintID(traceMarkerHint)
+	var offsets []int
+	for i, instruction := range system.Instructions {
+		if _, ok := system.Blueprints[instruction.BlueprintID].(*constraint.BlueprintGenericHint); !ok {
+			continue
+		}
+		if solver.HintID(system.GetInstruction(i).Calldata[1]) == id {
+			offsets = append(offsets, int(instruction.ConstraintOffset))
+		}
+	}
+	return offsets
+}
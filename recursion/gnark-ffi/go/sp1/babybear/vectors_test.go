@@ -0,0 +1,127 @@
+package babybear_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear/babybeartest"
+)
+
+// The vectors of testdata/vectors.json, which the babybear_vectors example of the
+// sp1-recursion-gnark-ffi crate prints from the Plonky3 field:
+//
+//	cargo run --example babybear_vectors -p sp1-recursion-gnark-ffi > go/sp1/babybear/testdata/vectors.json
+//
+// testdata/reference_vectors.json holds vectors of the same cases computed by an independent
+// reference of the field, and is checked too. The inverses are those of a.
+type baseVector struct {
+	A, B                              uint32
+	E                                 uint64
+	Add, Sub, Mul, Neg, Inv, Div, Exp uint32
+}
+
+type extVector struct {
+	A, B                              [4]uint32
+	C                                 uint32
+	E                                 uint64
+	Add, Sub, Mul, Neg, Inv, Div, Exp [4]uint32
+	MulBase                           [4]uint32 `json:"mul_base"`
+	DivBase                           [4]uint32 `json:"div_base"`
+}
+
+// vectorFiles are the files of vectors, by the field that computed them.
+var vectorFiles = map[string]string{
+	"plonky3":   "testdata/vectors.json",
+	"reference": "testdata/reference_vectors.json",
+}
+
+func readVectors(t *testing.T, path string) (base []baseVector, extension []extVector) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("%s is missing, print it with: cargo run --example babybear_vectors -p sp1-recursion-gnark-ffi > go/sp1/babybear/testdata/vectors.json", path)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors struct {
+		Base      []baseVector
+		Extension []extVector
+	}
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors.Base) == 0 || len(vectors.Extension) == 0 {
+		t.Fatal("expected base and extension field vectors")
+	}
+	return vectors.Base, vectors.Extension
+}
+
+func TestVectorsF(t *testing.T) {
+	for name, path := range vectorFiles {
+		t.Run(name, func(t *testing.T) { checkVectorsF(t, path) })
+	}
+}
+
+func checkVectorsF(t *testing.T, path string) {
+	base, _ := readVectors(t, path)
+	for i, v := range base {
+		e := new(big.Int).SetUint64(v.E)
+		gadget := func(chip *babybear.Chip, in []babybear.Variable) []babybear.Variable {
+			return []babybear.Variable{
+				chip.AddF(in[0], in[1]),
+				chip.SubF(in[0], in[1]),
+				chip.MulF(in[0], in[1]),
+				chip.NegF(in[0]),
+				chip.InvF(in[0]),
+				chip.DivF(in[0], in[1]),
+				chip.ExpF(in[0], e),
+			}
+		}
+		expected := []uint32{v.Add, v.Sub, v.Mul, v.Neg, v.Inv, v.Div, v.Exp}
+		if err := babybeartest.CheckGadget(gadget, []uint32{v.A, v.B}, expected); err != nil {
+			t.Errorf("vector %d: %+v: %v", i, v, err)
+		}
+	}
+}
+
+func TestVectorsE(t *testing.T) {
+	for name, path := range vectorFiles {
+		t.Run(name, func(t *testing.T) { checkVectorsE(t, path) })
+	}
+}
+
+func checkVectorsE(t *testing.T, path string) {
+	_, extension := readVectors(t, path)
+	for i, v := range extension {
+		e := new(big.Int).SetUint64(v.E)
+		gadget := func(chip *babybear.Chip, in []babybear.ExtensionVariable) []babybear.ExtensionVariable {
+			c := in[2].Value[0]
+			return []babybear.ExtensionVariable{
+				chip.AddE(in[0], in[1]),
+				chip.SubE(in[0], in[1]),
+				chip.MulE(in[0], in[1]),
+				chip.NegE(in[0]),
+				chip.InvE(in[0]),
+				chip.DivE(in[0], in[1]),
+				chip.ExpE(in[0], e),
+				chip.MulEF(in[0], c),
+				chip.DivEF(in[0], c),
+			}
+		}
+		inputs := [][4]uint32{v.A, v.B, {v.C}}
+		expected := [][4]uint32{v.Add, v.Sub, v.Mul, v.Neg, v.Inv, v.Div, v.Exp, v.MulBase, v.DivBase}
+		for name, opts := range map[string][]babybear.ChipOption{
+			"flat":  nil,
+			"tower": {babybear.WithTowerExtension()},
+		} {
+			if err := babybeartest.CheckGadgetE(gadget, inputs, expected, opts...); err != nil {
+				t.Errorf("%s: vector %d: %+v: %v", name, i, v, err)
+			}
+		}
+	}
+}
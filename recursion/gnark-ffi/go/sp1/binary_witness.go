@@ -0,0 +1,248 @@
+package sp1
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+)
+
+// The binary witness format stores the values of a witness packed rather than as decimal strings.
+// All integers are little-endian:
+//
+//	magic    [4]byte  "SP1W"
+//	version  u32      1
+//	nVars    u32
+//	nFelts   u32
+//	nExts    u32
+//	felts    nFelts × u32
+//	exts     nExts × 4 × u32
+//	vars     nVars × 32 bytes, big-endian like gnark's field elements
+//	vkey_hash               32 bytes, big-endian
+//	commited_values_digest  32 bytes, big-endian
+//
+// Values are validated as strictly as in the JSON format: felts must be canonical BabyBear
+// elements and vars canonical BN254 elements.
+const (
+	binaryWitnessMagic   = "SP1W"
+	binaryWitnessVersion = 1
+	binaryWitnessHeader  = 20
+)
+
+// isBinaryWitness reports whether data starts with the magic bytes of the binary witness format.
+// JSON witnesses start with a brace or whitespace, so the formats cannot be confused.
+func isBinaryWitness(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(binaryWitnessMagic))
+}
+
+// WriteWitnessBinary writes the witness in the binary witness format. The witness is validated
+// first, so that only witnesses ReadWitnessInput accepts are written.
+func WriteWitnessBinary(w io.Writer, witnessInput WitnessInput) error {
+	size := binaryWitnessHeader + 4*len(witnessInput.Felts) + 16*len(witnessInput.Exts) + 32*len(witnessInput.Vars) + 64
+	data := make([]byte, 0, size)
+	data = append(data, binaryWitnessMagic...)
+	for _, n := range []int{binaryWitnessVersion, len(witnessInput.Vars), len(witnessInput.Felts), len(witnessInput.Exts)} {
+		data = binary.LittleEndian.AppendUint32(data, uint32(n))
+	}
+
+	for i, f := range witnessInput.Felts {
+		value, err := parseCanonicalFelt(f)
+		if err != nil {
+			return fmt.Errorf("felt %d: %w", i, err)
+		}
+		data = binary.LittleEndian.AppendUint32(data, value)
+	}
+	for i, e := range witnessInput.Exts {
+		if err := e.validate(); err != nil {
+			return fmt.Errorf("ext %d: %w", i, err)
+		}
+		for _, coordinate := range e {
+			value, _ := parseCanonicalFelt(coordinate)
+			data = binary.LittleEndian.AppendUint32(data, value)
+		}
+	}
+	for i, v := range witnessInput.Vars {
+		value, err := parseBN254(v)
+		if err != nil {
+			return fmt.Errorf("var %d: %w", i, err)
+		}
+		data = appendBytes32(data, value)
+	}
+	vkeyHash, err := parseBN254(witnessInput.VkeyHash)
+	if err != nil {
+		return fmt.Errorf("invalid vkey hash: %w", err)
+	}
+	if err := validateCommittedValuesDigest(witnessInput.CommitedValuesDigest); err != nil {
+		return err
+	}
+	digest, _ := parseBN254(witnessInput.CommitedValuesDigest)
+	data = appendBytes32(appendBytes32(data, vkeyHash), digest)
+
+	_, err = w.Write(data)
+	return err
+}
+
+// The number of bytes decodeWitnessBinary reads at once.
+const binaryWitnessChunk = 1 << 16
+
+// decodeWitnessBinary decodes and validates a witness in the binary witness format of size bytes.
+// The values are read chunk by chunk, from the mapping of the file when it is mapped and from the
+// file otherwise, so the file is never copied as a whole. The witness still holds every value as
+// a decimal string, which takes several times the size of the file, but the strings are not
+// allocated one by one.
+func decodeWitnessBinary(r io.ReaderAt, size int64) (WitnessInput, error) {
+	var witnessInput WitnessInput
+	header := make([]byte, binaryWitnessHeader)
+	if read, _ := r.ReadAt(header, 0); read != len(header) || !isBinaryWitness(header) {
+		return witnessInput, fmt.Errorf("truncated binary witness header")
+	}
+	if version := binary.LittleEndian.Uint32(header[4:]); version != binaryWitnessVersion {
+		return witnessInput, fmt.Errorf("unsupported binary witness version %d", version)
+	}
+	nVars := uint64(binary.LittleEndian.Uint32(header[8:]))
+	nFelts := uint64(binary.LittleEndian.Uint32(header[12:]))
+	nExts := uint64(binary.LittleEndian.Uint32(header[16:]))
+	// The size is checked before allocating, so a corrupted header cannot cause huge allocations.
+	if expected := binaryWitnessHeader + 4*nFelts + 16*nExts + 32*nVars + 64; uint64(size) != expected {
+		return witnessInput, fmt.Errorf("binary witness of %d vars, %d felts and %d exts must have %d bytes, got %d", nVars, nFelts, nExts, expected, size)
+	}
+
+	feltModulus := babybear.MODULUS.Uint64()
+	felt := func(dst []byte, src []byte) ([]byte, error) {
+		value := uint64(binary.LittleEndian.Uint32(src))
+		if value >= feltModulus {
+			return dst, fmt.Errorf("%d is not a canonical BabyBear element", value)
+		}
+		return strconv.AppendUint(dst, value, 10), nil
+	}
+	modulus := ecc.BN254.ScalarField()
+	value := new(big.Int)
+	bn254 := func(dst []byte, src []byte) ([]byte, error) {
+		value.SetBytes(src)
+		if value.Cmp(modulus) >= 0 {
+			return dst, fmt.Errorf("%s is not in the BN254 scalar field", value)
+		}
+		return value.Append(dst, 10), nil
+	}
+
+	offset := int64(binaryWitnessHeader)
+	var err error
+	witnessInput.Felts, err = decodeBinarySection(r, &offset, nFelts, 4, felt, func(i uint64) string {
+		return fmt.Sprintf("felt %d", i)
+	})
+	if err != nil {
+		return witnessInput, err
+	}
+	coordinates, err := decodeBinarySection(r, &offset, 4*nExts, 4, felt, func(i uint64) string {
+		return fmt.Sprintf("ext %d: extension coordinate %d", i/4, i%4)
+	})
+	if err != nil {
+		return witnessInput, err
+	}
+	witnessInput.Exts = make([]ExtValue, nExts)
+	for i := range witnessInput.Exts {
+		copy(witnessInput.Exts[i][:], coordinates[4*i:])
+	}
+	// The vkey hash and the committed values digest follow the vars.
+	vars, err := decodeBinarySection(r, &offset, nVars+2, 32, bn254, func(i uint64) string {
+		switch i {
+		case nVars:
+			return "invalid vkey hash"
+		case nVars + 1:
+			return "invalid committed values digest"
+		}
+		return fmt.Sprintf("var %d", i)
+	})
+	if err != nil {
+		return witnessInput, err
+	}
+	witnessInput.Vars = vars[:nVars:nVars]
+	witnessInput.VkeyHash, witnessInput.CommitedValuesDigest = vars[nVars], vars[nVars+1]
+	return witnessInput, validateCommittedValuesDigest(witnessInput.CommitedValuesDigest)
+}
+
+// decodeBinarySection decodes n values of width bytes read from r at offset, which it advances.
+// decode appends the decimal string of a value to its first argument, and name describes the
+// value at an index in errors. The strings of the values of a chunk are slices of one string.
+func decodeBinarySection(r io.ReaderAt, offset *int64, n uint64, width int, decode func([]byte, []byte) ([]byte, error), name func(uint64) string) ([]string, error) {
+	values := make([]string, n)
+	chunk := make([]byte, binaryWitnessChunk/width*width)
+	ends := make([]int, len(chunk)/width)
+	var digits []byte
+	var err error
+	for i := uint64(0); i < n; {
+		section := chunk[:min(uint64(len(chunk)), (n-i)*uint64(width))]
+		if read, err := r.ReadAt(section, *offset); read != len(section) {
+			return nil, err
+		}
+		*offset += int64(len(section))
+		digits = digits[:0]
+		for j := 0; j < len(section)/width; j++ {
+			if digits, err = decode(digits, section[j*width:(j+1)*width]); err != nil {
+				return nil, fmt.Errorf("%s: %w", name(i+uint64(j)), err)
+			}
+			ends[j] = len(digits)
+		}
+		all, start := string(digits), 0
+		for j := 0; j < len(section)/width; j, i = j+1, i+1 {
+			values[i] = all[start:ends[j]]
+			start = ends[j]
+		}
+	}
+	return values, nil
+}
+
+// ConvertWitnessInput converts a witness file, in either format and possibly gzip compressed, to
+// the JSON format if outputPath ends with .json and to the binary witness format otherwise.
+func ConvertWitnessInput(inputPath string, outputPath string) error {
+	witnessInput, err := ReadWitnessInput(inputPath)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	if strings.HasSuffix(outputPath, ".json") {
+		err = json.NewEncoder(writer).Encode(witnessInput)
+	} else {
+		err = WriteWitnessBinary(writer, witnessInput)
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", outputPath, err)
+	}
+	return nil
+}
+
+// parseCanonicalFelt parses a felt as serialized in JSON witnesses, which unlike parseFelt must
+// be canonical.
+func parseCanonicalFelt(s string) (uint32, error) {
+	if _, err := babybear.NewFChecked(s); err != nil {
+		return 0, err
+	}
+	value, err := strconv.ParseUint(s, 10, 32)
+	return uint32(value), err
+}
+
+func appendBytes32(data []byte, value *big.Int) []byte {
+	var buf [32]byte
+	value.FillBytes(buf[:])
+	return append(data, buf[:]...)
+}
@@ -0,0 +1,174 @@
+package sp1
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// encodeTestWitness returns the binary encoding of the basic witness fixture: 2 vars, 3 felts
+// and 1 ext.
+func encodeTestWitness(t *testing.T) []byte {
+	var buf bytes.Buffer
+	if err := WriteWitnessBinary(&buf, readTestWitness(t, "testdata/basic_witness.json")); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestBinaryWitnessRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	expected, err := ReadWitnessInput("testdata/basic_witness.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	binaryPath := filepath.Join(dir, "witness.bin")
+	if err := ConvertWitnessInput("testdata/basic_witness.json", binaryPath); err != nil {
+		t.Fatal(err)
+	}
+	jsonPath := filepath.Join(dir, "witness.json")
+	if err := ConvertWitnessInput(binaryPath, jsonPath); err != nil {
+		t.Fatal(err)
+	}
+	compressedPath := filepath.Join(dir, "witness.bin.gz")
+	writeCompressed(t, binaryPath, compressedPath)
+
+	for _, mmap := range []bool{true, false} {
+		setMmapInputs(t, mmap)
+		for _, path := range []string{binaryPath, jsonPath, compressedPath} {
+			witnessInput, err := ReadWitnessInput(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(witnessInput, expected) {
+				t.Errorf("mmap=%t: %s: %+v != %+v", mmap, filepath.Base(path), witnessInput, expected)
+			}
+		}
+	}
+
+	if err := RunTestEngine("testdata/basic_constraints.json", binaryPath); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadBinaryWitnessErrors(t *testing.T) {
+	for name, test := range map[string]struct {
+		mutate   func([]byte) []byte
+		expected string
+	}{
+		"version": {func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[4:], 2)
+			return data
+		}, "unsupported binary witness version 2"},
+		"truncated header": {func(data []byte) []byte { return data[:10] }, "truncated binary witness header"},
+		"truncated":        {func(data []byte) []byte { return data[:len(data)-1] }, "must have 176 bytes, got 175"},
+		"trailing":         {func(data []byte) []byte { return append(data, 0) }, "must have 176 bytes, got 177"},
+		"count": {func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[12:], 1<<31)
+			return data
+		}, "must have 8589934756 bytes"},
+		"felt": {func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[24:], 2013265921)
+			return data
+		}, "felt 1: 2013265921 is not a canonical BabyBear element"},
+		"ext": {func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[44:], 0xffffffff)
+			return data
+		}, "ext 0: extension coordinate 3: 4294967295 is not a canonical BabyBear element"},
+		"var": {func(data []byte) []byte {
+			copy(data[80:112], bytes.Repeat([]byte{0xff}, 32))
+			return data
+		}, "var 1: "},
+		"vkey hash": {func(data []byte) []byte {
+			copy(data[112:144], bytes.Repeat([]byte{0xff}, 32))
+			return data
+		}, "invalid vkey hash"},
+		"digest": {func(data []byte) []byte {
+			data[144] = 0x20
+			return data
+		}, "does not fit in 253 bits"},
+	} {
+		data := test.mutate(encodeTestWitness(t))
+		path := filepath.Join(t.TempDir(), "witness.bin")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		for _, mmap := range []bool{true, false} {
+			setMmapInputs(t, mmap)
+			if _, err := ReadWitnessInput(path); err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("%s, mmap=%t: expected an error containing %q, got %v", name, mmap, test.expected, err)
+			}
+		}
+		if _, err := DecodeWitnessInput(bytes.NewReader(data)); err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("%s: expected the streamed witness to fail with %q, got %v", name, test.expected, err)
+		}
+	}
+}
+
+func TestBinaryWitnessChunks(t *testing.T) {
+	// Each section spans several chunks, and the last chunks are partial.
+	witnessInput := WitnessInput{VkeyHash: "7", CommitedValuesDigest: "11"}
+	for i := 0; i < 3*binaryWitnessChunk/4+5; i++ {
+		witnessInput.Felts = append(witnessInput.Felts, strconv.Itoa(i*7919%2013265921))
+	}
+	for i := 0; i < binaryWitnessChunk/16+3; i++ {
+		witnessInput.Exts = append(witnessInput.Exts, ExtValue{strconv.Itoa(i), "0", strconv.Itoa(2013265920 - i), "1"})
+	}
+	for i := 0; i < binaryWitnessChunk/32+1; i++ {
+		witnessInput.Vars = append(witnessInput.Vars, new(big.Int).Lsh(big.NewInt(int64(i)), 200).String())
+	}
+	var buf bytes.Buffer
+	if err := WriteWitnessBinary(&buf, witnessInput); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "witness.bin")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mmap := range []bool{true, false} {
+		setMmapInputs(t, mmap)
+		input, err := openInput(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := input.(inputFile); !ok {
+			t.Errorf("mmap=%t: expected the witness to be read at offsets, got %T", mmap, input)
+		}
+		input.Close()
+		decoded, err := ReadWitnessInput(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(decoded, witnessInput) {
+			t.Errorf("mmap=%t: the decoded witness differs", mmap)
+		}
+	}
+	decoded, err := DecodeWitnessInput(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, witnessInput) {
+		t.Error("the streamed witness differs")
+	}
+}
+
+func TestWriteBinaryWitnessErrors(t *testing.T) {
+	for expected, witnessInput := range map[string]WitnessInput{
+		`felt 0: "08" is not a decimal number`:                     {Felts: []string{"08"}, VkeyHash: "0", CommitedValuesDigest: "0"},
+		"ext 0: extension coordinate 1: 2013265921 is not a canon": {Exts: []ExtValue{{"0", "2013265921", "0", "0"}}, VkeyHash: "0", CommitedValuesDigest: "0"},
+		"var 0: -1 is not in the BN254 scalar field":               {Vars: []string{"-1"}, VkeyHash: "0", CommitedValuesDigest: "0"},
+		"invalid committed values digest":                          {VkeyHash: "0"},
+	} {
+		if err := WriteWitnessBinary(&bytes.Buffer{}, witnessInput); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected an error containing %q, got %v", expected, err)
+		}
+	}
+}
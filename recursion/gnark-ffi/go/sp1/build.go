@@ -1,201 +1,259 @@
 package sp1
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"log"
+	"io"
 	"os"
-	"strings"
+	"time"
 
 	"github.com/consensys/gnark-crypto/ecc"
-	"github.com/consensys/gnark-crypto/kzg"
+	"github.com/consensys/gnark/backend"
 	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/constraint/solver"
 	"github.com/consensys/gnark/frontend"
 	"github.com/consensys/gnark/frontend/cs/scs"
-	"github.com/consensys/gnark/test/unsafekzg"
-	"github.com/succinctlabs/sp1-recursion-gnark/sp1/trusted_setup"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/logging"
 )
 
-func Build(dataDir string) {
+// BuildOptions configures BuildWithOptions.
+type BuildOptions struct {
+	// System is the proving system the circuit is built for, PLONK by default. The SRS options
+	// only apply to PLONK, and a Groth16 build only stops for its context before it starts.
+	System ProvingSystem
+	// Logger receives the logs of the build. The default logger is used if it is nil.
+	Logger logging.Logger
+	// SRSPath is a file of a canonical KZG SRS serialized by gnark, like SRS_FILE, that the SRS of
+	// the circuit is trimmed from instead of the downloaded Aztec Ignition SRS.
+	SRSPath string
+	// SRSCacheDir is a directory where the canonical SRS trimmed to the size of the circuit is
+	// cached, for the data directories of the circuits of the same size. Its files are checked
+	// against their digest when read.
+	SRSCacheDir string
+}
+
+// Build compiles the circuit and writes its artifacts to dataDir. The outer curve is always BN254:
+// the outer Poseidon2 constants, the Aztec Ignition SRS and the Solidity verifiers are those of
+// BN254, and only the felt packings also hold over BLS12-381.
+func Build(dataDir string) BuildReport {
+	return BuildWithOptions(dataDir, BuildOptions{})
+}
+
+// BuildWithOptions is Build, logging to the logger of opts.
+func BuildWithOptions(dataDir string, opts BuildOptions) BuildReport {
+	report, err := BuildContext(context.Background(), dataDir, opts)
+	if err != nil {
+		panic(err)
+	}
+	return report
+}
+
+// BuildContext is BuildWithOptions, returning its errors instead of panicking. It stops once ctx is
+// done, between two phases or during the synthesis of the circuit, and returns the error of ctx
+// wrapped with the name of the phase. The artifacts are written to temporary files in dataDir and
+// only renamed once the build succeeded, so that a failed build leaves the directory unchanged.
+func BuildContext(ctx context.Context, dataDir string, opts BuildOptions) (BuildReport, error) {
+	if isGroth16, err := opts.System.isGroth16(); err != nil {
+		return BuildReport{}, err
+	} else if isGroth16 {
+		if err := checkContext(ctx, "read_witness"); err != nil {
+			return BuildReport{}, err
+		}
+		return BuildGroth16(dataDir, opts)
+	}
+
 	// Set the enviroment variable for the constraints file.
 	//
 	// TODO: There might be some non-determinism if a single process is running this command
 	// multiple times.
-	os.Setenv("CONSTRAINTS_JSON", dataDir+"/"+CONSTRAINTS_JSON_FILE)
+	os.Setenv("CONSTRAINTS_JSON", resolveInput(dataDir+"/"+CONSTRAINTS_JSON_FILE))
+
+	logger := logging.OrDefault(opts.Logger)
+	metrics := NewMetrics(logger)
+	files := newStaging(dataDir)
+	defer files.discard()
 
 	// Read the file.
-	witnessInputPath := dataDir + "/witness.json"
-	data, err := os.ReadFile(witnessInputPath)
+	if err := checkContext(ctx, "read_witness"); err != nil {
+		return BuildReport{}, err
+	}
+	endReadWitness := metrics.Start("read_witness")
+	witnessInputPath := resolveInput(dataDir + "/witness.json")
+	witnessInput, err := ReadWitnessInput(witnessInputPath)
 	if err != nil {
-		panic(err)
+		return BuildReport{}, fmt.Errorf("read_witness: %w", err)
 	}
+	endReadWitness()
 
-	// Deserialize the JSON data into a slice of Instruction structs
-	var witnessInput WitnessInput
-	err = json.Unmarshal(data, &witnessInput)
+	// Initialize the circuit, which exposes a hash of the public values as its single public input
+	// if SINGLE_PUBLIC_INPUT is set.
+	mode := TWO_PUBLIC_INPUTS_MODE
+	if os.Getenv("SINGLE_PUBLIC_INPUT") == "true" {
+		mode = SINGLE_PUBLIC_INPUT_MODE
+	}
+	circuit, stats, err := newModeCircuit(witnessInput, mode)
 	if err != nil {
-		panic(err)
+		return BuildReport{}, err
 	}
+	stats.metrics = metrics
+	stats.logger = logger
+	stats.ctx = ctx
 
-	// Initialize the circuit.
-	circuit := NewCircuit(witnessInput)
-
-	// Compile the circuit.
-	scs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &circuit)
+	// Compile the circuit, profiling the constraints of every region if PROFILE_CIRCUIT is set.
+	if err := checkContext(ctx, "compile"); err != nil {
+		return BuildReport{}, err
+	}
+	start := time.Now()
+	endCompile := metrics.Start("compile")
+	profilePath := ""
+	if os.Getenv("PROFILE_CIRCUIT") == "true" {
+		if profilePath, err = files.path(CIRCUIT_PROFILE_PATH); err != nil {
+			return BuildReport{}, err
+		}
+	}
+	scs, err := compileCircuit(circuit, stats, profilePath)
 	if err != nil {
-		panic(err)
+		return BuildReport{}, fmt.Errorf("compile: %w", err)
 	}
-
-	// Download the trusted setup.
-	var srs kzg.SRS = kzg.NewSRS(ecc.BN254)
-	var srsLagrange kzg.SRS = kzg.NewSRS(ecc.BN254)
-	srsFileName := dataDir + "/" + SRS_FILE
-	srsLagrangeFileName := dataDir + "/" + SRS_LAGRANGE_FILE
-
-	srsLagrangeFile, err := os.Create(srsLagrangeFileName)
+	endCompile()
+	report := NewBuildReport(scs, stats)
+	report.CompileTimeMs = time.Since(start).Milliseconds()
+	report.CircuitDigest, err = CircuitDigest(scs)
 	if err != nil {
-		log.Fatal("error creating srs file: ", err)
-		panic(err)
+		return BuildReport{}, err
 	}
-	defer srsLagrangeFile.Close()
-
-	if !strings.Contains(dataDir, "dev") {
-		if _, err := os.Stat(srsFileName); os.IsNotExist(err) {
-			fmt.Println("downloading aztec ignition srs")
-			trusted_setup.DownloadAndSaveAztecIgnitionSrs(174, srsFileName)
-
-			srsFile, err := os.Open(srsFileName)
-			if err != nil {
-				panic(err)
-			}
-			defer srsFile.Close()
-
-			_, err = srs.ReadFrom(srsFile)
-			srsFile.Close()
-			if err != nil {
-				panic(err)
-			}
-
-			srsLagrange = trusted_setup.ToLagrange(scs, srs)
-			_, err = srsLagrange.WriteTo(srsLagrangeFile)
-			if err != nil {
-				panic(err)
-			}
-		} else {
-			srsFile, err := os.Open(srsFileName)
-			if err != nil {
-				panic(err)
-			}
-			defer srsFile.Close()
-
-			_, err = srs.ReadFrom(srsFile)
-			srsFile.Close()
-			if err != nil {
-				panic(err)
-			}
-
-			srsLagrangeFile, err := os.Open(srsLagrangeFileName)
-			if err != nil {
-				panic(err)
-			}
-			defer srsLagrangeFile.Close()
-
-			_, err = srsLagrange.ReadFrom(srsLagrangeFile)
-			srsLagrangeFile.Close()
-			if err != nil {
-				panic(err)
-			}
-		}
-	} else {
-		srs, srsLagrange, err = unsafekzg.NewSRS(scs)
-		if err != nil {
-			panic(err)
-		}
-
-		srsFile, err := os.Create(srsFileName)
-		if err != nil {
-			panic(err)
-		}
-		defer srsFile.Close()
 
-		_, err = srs.WriteTo(srsFile)
+	// Measure the constraints saved by eliminating common subexpressions by compiling the circuit a
+	// second time without it.
+	if stats.nbEliminated > 0 {
+		report.CSEConstraintDelta, err = cseConstraintDelta(ctx, witnessInput, mode, scs.GetNbConstraints())
 		if err != nil {
-			panic(err)
+			return BuildReport{}, fmt.Errorf("compile: %w", err)
 		}
+	}
 
-		_, err = srsLagrange.WriteTo(srsLagrangeFile)
-		if err != nil {
-			panic(err)
-		}
+	// Download the trusted setup.
+	if err := checkContext(ctx, "setup"); err != nil {
+		return BuildReport{}, err
+	}
+	endSetup := metrics.Start("setup")
+	endSrs := metrics.Start("srs")
+	srs, srsLagrange, err := loadSRS(dataDir, scs, opts, logger)
+	if err != nil {
+		return BuildReport{}, fmt.Errorf("srs: %w", err)
 	}
+	endSrs()
 
 	// Generate the proving and verifying key.
 	pk, vk, err := plonk.Setup(scs, srs, srsLagrange)
 	if err != nil {
-		panic(err)
+		return BuildReport{}, fmt.Errorf("setup: %w", err)
 	}
+	endSetup()
 
 	// Generate proof.
-	assignment := NewCircuit(witnessInput)
-	witness, err := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
+	if err := checkContext(ctx, "witness"); err != nil {
+		return BuildReport{}, err
+	}
+	endWitness := metrics.Start("witness")
+	_, witness, err := newFullWitness(scs, witnessInput)
 	if err != nil {
-		panic(err)
+		return BuildReport{}, fmt.Errorf("witness: %w", err)
+	}
+	endWitness()
+	if err := checkContext(ctx, "prove"); err != nil {
+		return BuildReport{}, err
 	}
-	proof, err := plonk.Prove(scs, pk, witness)
+	endProve := metrics.Start("prove")
+	solverOpts := []solver.Option{solver.WithLogger(logging.Zerolog(logger)), solverParallelism()}
+	if ctx.Done() != nil {
+		solverOpts = append(solverOpts, instrumentHints(cancelHint(ctx))...)
+	}
+	proof, err := plonk.Prove(scs, pk, witness, backend.WithSolverOptions(solverOpts...))
 	if err != nil {
-		panic(err)
+		return BuildReport{}, fmt.Errorf("prove: %w", err)
 	}
+	endProve()
 
 	// Verify proof.
+	if err := checkContext(ctx, "verify"); err != nil {
+		return BuildReport{}, err
+	}
+	endVerify := metrics.Start("verify")
 	publicWitness, err := witness.Public()
 	if err != nil {
-		panic(err)
+		return BuildReport{}, fmt.Errorf("verify: %w", err)
 	}
 	err = plonk.Verify(proof, vk, publicWitness)
 	if err != nil {
-		panic(err)
+		return BuildReport{}, fmt.Errorf("verify: %w", err)
 	}
+	endVerify()
 
-	// Create the build directory.
-	os.MkdirAll(dataDir, 0755)
-
-	// Write the solidity verifier.
-	solidityVerifierFile, err := os.Create(dataDir + "/" + VERIFIER_CONTRACT_PATH)
-	if err != nil {
-		panic(err)
+	// Stage the solidity verifier, the R1CS, the verifier key and the proving key.
+	if err := checkContext(ctx, "serialize"); err != nil {
+		return BuildReport{}, err
+	}
+	endSerialize := metrics.Start("serialize")
+	if err := files.write(VERIFIER_CONTRACT_PATH, vk.ExportSolidity); err != nil {
+		return BuildReport{}, fmt.Errorf("serialize: %w", err)
 	}
-	vk.ExportSolidity(solidityVerifierFile)
+	for name, artifact := range map[string]io.WriterTo{CIRCUIT_PATH: scs, VK_PATH: vk, PK_PATH: pk} {
+		err := files.write(name, func(w io.Writer) error {
+			_, err := artifact.WriteTo(w)
+			return err
+		})
+		if err != nil {
+			return BuildReport{}, fmt.Errorf("serialize: %w", err)
+		}
+	}
+	endSerialize()
 
-	// Write the R1CS.
-	scsFile, err := os.Create(dataDir + "/" + CIRCUIT_PATH)
-	if err != nil {
-		panic(err)
+	// Stage the build report.
+	report.Phases = metrics.Phases
+	metrics.Log("built circuit")
+	for _, name := range []string{CIRCUIT_PATH, VK_PATH, PK_PATH, VERIFIER_CONTRACT_PATH} {
+		if err := report.addDigest(name, files.temps[name]); err != nil {
+			return BuildReport{}, err
+		}
 	}
-	defer scsFile.Close()
-	_, err = scs.WriteTo(scsFile)
-	if err != nil {
-		panic(err)
+	if err := files.write(REPORT_PATH, report.writeJSON); err != nil {
+		return BuildReport{}, err
 	}
 
-	// Write the verifier key.
-	vkFile, err := os.Create(dataDir + "/" + VK_PATH)
-	if err != nil {
-		panic(err)
+	// Replace the artifacts of the previous build, unless the build was cancelled in the meantime.
+	if err := checkContext(ctx, "serialize"); err != nil {
+		return BuildReport{}, err
 	}
-	defer vkFile.Close()
-	_, err = vk.WriteTo(vkFile)
-	if err != nil {
-		panic(err)
+	if err := files.commit(); err != nil {
+		return BuildReport{}, err
+	}
+	report.WriteTable(os.Stdout)
+
+	return report, nil
+}
+
+// checkContext returns the error of ctx wrapped with the name of the phase about to start, if ctx is
+// done.
+func checkContext(ctx context.Context, phase string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s: %w", phase, err)
 	}
+	return nil
+}
 
-	// Write the proving key.
-	pkFile, err := os.Create(dataDir + "/" + PK_PATH)
+// cseConstraintDelta compiles the circuit without eliminating common subexpressions and returns how
+// many more constraints it has than nbConstraints.
+func cseConstraintDelta(ctx context.Context, witnessInput WitnessInput, mode string, nbConstraints int) (int, error) {
+	baseline, stats, err := newModeCircuit(witnessInput, mode)
 	if err != nil {
-		panic(err)
+		return 0, err
 	}
-	defer pkFile.Close()
-	_, err = pk.WriteTo(pkFile)
+	stats.skipCSE = true
+	stats.ctx = ctx
+	baselineScs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, baseline)
 	if err != nil {
-		panic(err)
+		return 0, err
 	}
+	return baselineScs.GetNbConstraints() - nbConstraints, nil
 }
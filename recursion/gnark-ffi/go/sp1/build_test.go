@@ -0,0 +1,378 @@
+package sp1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newDevDataDir returns a fresh dev data directory containing the given fixtures.
+func newDevDataDir(t *testing.T, constraintsPath string, witnessPath string) string {
+	dataDir := filepath.Join(t.TempDir(), "dev")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for src, dst := range map[string]string{constraintsPath: CONSTRAINTS_JSON_FILE, witnessPath: WITNESS_JSON_FILE} {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dataDir, dst), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dataDir
+}
+
+func TestBuildReport(t *testing.T) {
+	dataDir := newDevDataDir(t, "testdata/basic_constraints.json", "testdata/basic_witness.json")
+	report := Build(dataDir)
+
+	data, err := os.ReadFile(filepath.Join(dataDir, REPORT_PATH))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved BuildReport
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+
+	if saved.NbConstraints == 0 || saved.NbConstraints != report.NbConstraints {
+		t.Fatalf("unexpected constraint count: %d", saved.NbConstraints)
+	}
+	if saved.NbPublicInputs != 2 {
+		t.Fatalf("expected 2 public inputs, got %d", saved.NbPublicInputs)
+	}
+	if saved.WitnessSize != saved.NbPublicInputs+saved.NbSecretInputs {
+		t.Fatalf("witness size %d does not match the inputs", saved.WitnessSize)
+	}
+	if saved.NbHints == 0 {
+		t.Fatal("expected the circuit to use hints")
+	}
+
+	total := 0
+	for _, count := range saved.OpcodeCounts {
+		total += count
+	}
+	if total != saved.NbInstructions || saved.NbInstructions != 14 {
+		t.Fatalf("instruction total %d does not match opcode counts %d", saved.NbInstructions, total)
+	}
+	if saved.OpcodeCounts["WitnessF"] != 3 {
+		t.Fatalf("expected 3 WitnessF instructions, got %d", saved.OpcodeCounts["WitnessF"])
+	}
+
+	for _, name := range []string{CIRCUIT_PATH, VK_PATH, PK_PATH, VERIFIER_CONTRACT_PATH} {
+		if len(saved.ArtifactDigests[name]) != 64 {
+			t.Fatalf("missing digest for %s", name)
+		}
+	}
+}
+
+func TestBuildIsReproducible(t *testing.T) {
+	first := Build(newDevDataDir(t, "testdata/basic_constraints.json", "testdata/basic_witness.json"))
+	second := Build(newDevDataDir(t, "testdata/basic_constraints.json", "testdata/basic_witness.json"))
+
+	if len(first.CircuitDigest) != 64 {
+		t.Fatalf("invalid circuit digest: %q", first.CircuitDigest)
+	}
+	if first.CircuitDigest != second.CircuitDigest {
+		t.Fatalf("circuit digests differ: %s != %s", first.CircuitDigest, second.CircuitDigest)
+	}
+	if first.ArtifactDigests[CIRCUIT_PATH] != second.ArtifactDigests[CIRCUIT_PATH] {
+		t.Fatal("compiled constraint systems differ")
+	}
+	if first.ArtifactDigests[VK_PATH] != second.ArtifactDigests[VK_PATH] {
+		t.Fatal("verifying keys differ")
+	}
+}
+
+func TestProveTruncatedArtifacts(t *testing.T) {
+	dataDir := newDevDataDir(t, "testdata/basic_constraints.json", "testdata/basic_witness.json")
+	Build(dataDir)
+	for _, name := range []string{CIRCUIT_PATH, PK_PATH, VK_PATH} {
+		path := filepath.Join(dataDir, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data[:len(data)/2], 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err = Prove(dataDir, filepath.Join(dataDir, WITNESS_JSON_FILE))
+		if err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("expected proving to fail on the truncated %s, got %v", name, err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPhaseMetrics(t *testing.T) {
+	dataDir := newDevDataDir(t, "testdata/basic_constraints.json", "testdata/basic_witness.json")
+	report := Build(dataDir)
+	proof, err := Prove(dataDir, filepath.Join(dataDir, WITNESS_JSON_FILE))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		phases   []Phase
+		expected []string
+	}{
+		{report.Phases, []string{"read_witness", "compile", "setup", "witness", "prove", "verify", "serialize"}},
+		{proof.Phases, []string{"load", "read_witness", "witness", "prove", "verify", "serialize"}},
+	} {
+		if len(tc.phases) != len(tc.expected) {
+			t.Fatalf("expected phases %v, got %+v", tc.expected, tc.phases)
+		}
+		for i, phase := range tc.phases {
+			if phase.Name != tc.expected[i] || phase.DurationMs < 0 {
+				t.Fatalf("unexpected phase %+v, expected %s", phase, tc.expected[i])
+			}
+		}
+	}
+
+	compile := report.Phases[1].Phases
+	if len(compile) != 2 || compile[0].Name != "parse" || compile[1].Name != "synthesize" {
+		t.Fatalf("expected parse and synthesize to be nested in compile, got %+v", compile)
+	}
+	if setup := report.Phases[2].Phases; len(setup) != 1 || setup[0].Name != "srs" {
+		t.Fatalf("expected srs to be nested in setup, got %+v", setup)
+	}
+}
+
+func TestOpcodePhaseBuckets(t *testing.T) {
+	t.Setenv("COLLECT_OPCODE_STATS", "true")
+	report := Build(newDevDataDir(t, "testdata/basic_constraints.json", "testdata/basic_witness.json"))
+
+	synthesize := report.Phases[1].Phases[1]
+	if len(synthesize.Phases) != len(report.OpcodeCounts) {
+		t.Fatalf("expected a synthesis bucket per opcode, got %+v", synthesize.Phases)
+	}
+	for _, bucket := range synthesize.Phases {
+		if _, ok := report.OpcodeCounts[bucket.Name]; !ok || bucket.DurationMs < 0 {
+			t.Fatalf("unexpected bucket %+v", bucket)
+		}
+	}
+}
+
+type logRecord struct {
+	level  string
+	msg    string
+	fields map[string]any
+}
+
+// recordingLogger records the logs written to it, possibly from solver goroutines.
+type recordingLogger struct {
+	mu      sync.Mutex
+	records []logRecord
+}
+
+func (l *recordingLogger) record(level, msg string, keyvals []any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fields := make(map[string]any)
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		fields[fmt.Sprint(keyvals[i])] = keyvals[i+1]
+	}
+	l.records = append(l.records, logRecord{level, msg, fields})
+}
+
+func (l *recordingLogger) Debug(msg string, keyvals ...any) { l.record("debug", msg, keyvals) }
+func (l *recordingLogger) Info(msg string, keyvals ...any)  { l.record("info", msg, keyvals) }
+func (l *recordingLogger) Warn(msg string, keyvals ...any)  { l.record("warn", msg, keyvals) }
+func (l *recordingLogger) Error(msg string, keyvals ...any) { l.record("error", msg, keyvals) }
+
+// find returns the first record with the given message whose fields include fields.
+func (l *recordingLogger) find(msg string, fields map[string]any) *logRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, record := range l.records {
+		if record.msg != msg {
+			continue
+		}
+		matches := true
+		for key, value := range fields {
+			if fmt.Sprint(record.fields[key]) != fmt.Sprint(value) {
+				matches = false
+			}
+		}
+		if matches {
+			return &l.records[i]
+		}
+	}
+	return nil
+}
+
+func TestPipelineLogs(t *testing.T) {
+	dataDir := newDevDataDir(t, "testdata/print_constraints.json", "testdata/basic_witness.json")
+
+	buildLogger := &recordingLogger{}
+	BuildWithOptions(dataDir, BuildOptions{Logger: buildLogger})
+	proveLogger := &recordingLogger{}
+	proof, err := ProveWithOptions(dataDir, filepath.Join(dataDir, WITNESS_JSON_FILE), ProveOptions{Logger: proveLogger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyLogger := &recordingLogger{}
+	if err := VerifyWithOptions(dataDir, proof.RawProof, "123", "456", VerifyOptions{Logger: verifyLogger}); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyWithOptions(dataDir, proof.RawProof, "123", "457", VerifyOptions{Logger: verifyLogger}); err == nil {
+		t.Fatal("expected a wrong public input to be rejected")
+	}
+
+	for _, tc := range []struct {
+		logger *recordingLogger
+		msg    string
+		fields map[string]any
+	}{
+		{buildLogger, "phase started", map[string]any{"phase": "compile"}},
+		{buildLogger, "phase finished", map[string]any{"phase": "compile"}},
+		{buildLogger, "interpreted constraints", map[string]any{"instructions": 15}},
+		{buildLogger, "generating unsafe srs", map[string]any{"data_dir": dataDir}},
+		{buildLogger, "built circuit", nil},
+		{proveLogger, "phase started", map[string]any{"phase": "load"}},
+		{proveLogger, "phase finished", map[string]any{"phase": "serialize"}},
+		// The solver logs the values printed by the circuit, here the product f2 of the witness.
+		{proveLogger, "f2 15", nil},
+		{proveLogger, "proved", nil},
+		{verifyLogger, "verified proof", map[string]any{"vkey_hash": "123"}},
+		{verifyLogger, "invalid proof", map[string]any{"vkey_hash": "123"}},
+	} {
+		if tc.logger.find(tc.msg, tc.fields) == nil {
+			t.Errorf("expected a %q record with fields %v", tc.msg, tc.fields)
+		}
+	}
+	if finished := proveLogger.find("phase finished", map[string]any{"phase": "prove"}); finished == nil {
+		t.Error("expected the end of the prove phase to be logged")
+	} else if _, ok := finished.fields["duration_ms"].(int64); !ok {
+		t.Errorf("expected the duration of the prove phase, got %v", finished.fields)
+	}
+}
+
+func TestBuildSRSCacheHit(t *testing.T) {
+	devDir := newDevDataDir(t, "testdata/basic_constraints.json", "testdata/basic_witness.json")
+	Build(devDir)
+
+	// A data directory outside of development reuses the SRS files found in it.
+	dataDir := filepath.Join(t.TempDir(), "circuit")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{CONSTRAINTS_JSON_FILE, WITNESS_JSON_FILE, SRS_FILE, SRS_LAGRANGE_FILE} {
+		data, err := os.ReadFile(filepath.Join(devDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dataDir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	logger := &recordingLogger{}
+	BuildWithOptions(dataDir, BuildOptions{Logger: logger})
+	if logger.find("srs cache hit", map[string]any{"path": filepath.Join(dataDir, SRS_FILE)}) == nil {
+		t.Fatalf("expected an srs cache hit, got %+v", logger.records)
+	}
+}
+
+// cancellingLogger cancels a build when the given phase starts.
+type cancellingLogger struct {
+	recordingLogger
+	phase  string
+	cancel context.CancelFunc
+}
+
+func (l *cancellingLogger) Debug(msg string, keyvals ...any) {
+	l.record("debug", msg, keyvals)
+	if msg == "phase started" && fmt.Sprint(keyvals[1]) == l.phase {
+		l.cancel()
+	}
+}
+
+// dirNames returns the sorted names of the files in dir.
+func dirNames(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestBuildCancelled(t *testing.T) {
+	dataDir := newDevDataDir(t, "testdata/basic_constraints.json", "testdata/basic_witness.json")
+	data, err := json.Marshal(syntheticConstraints(100_000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, CONSTRAINTS_JSON_FILE), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("PROFILE_CIRCUIT", "true")
+	defer os.Unsetenv("PROFILE_CIRCUIT")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logger := &cancellingLogger{phase: "synthesize", cancel: cancel}
+	_, err = BuildContext(ctx, dataDir, BuildOptions{Logger: logger})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the build to be cancelled, got %v", err)
+	}
+	// The synthesis stops at the first check, without interpreting the stream.
+	if !strings.HasPrefix(err.Error(), "compile: ") || !strings.Contains(err.Error(), "synthesize: instruction 0: ") {
+		t.Errorf("expected the error to name the phase and instruction, got %v", err)
+	}
+	if logger.find("interpreted constraints", nil) != nil {
+		t.Error("expected the synthesis to stop before the end of the stream")
+	}
+	if names := dirNames(t, dataDir); len(names) != 2 || names[0] != CONSTRAINTS_JSON_FILE || names[1] != WITNESS_JSON_FILE {
+		t.Errorf("expected the cancelled build to leave only its inputs, got %v", names)
+	}
+}
+
+func TestBuildCancelledKeepsArtifacts(t *testing.T) {
+	dataDir := newDevDataDir(t, "testdata/basic_constraints.json", "testdata/basic_witness.json")
+	if _, err := BuildContext(context.Background(), dataDir, BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	names := dirNames(t, dataDir)
+	for _, name := range names {
+		if strings.Contains(name, ".tmp-") {
+			t.Errorf("expected the build to rename its temporary files, found %s", name)
+		}
+	}
+	vk, err := os.ReadFile(filepath.Join(dataDir, VK_PATH))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A rebuild cancelled while proving leaves the artifacts of the previous build.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err = BuildContext(ctx, dataDir, BuildOptions{Logger: &cancellingLogger{phase: "prove", cancel: cancel}})
+	if !errors.Is(err, context.Canceled) || !strings.HasPrefix(err.Error(), "prove: ") {
+		t.Fatalf("expected the build to be cancelled while proving, got %v", err)
+	}
+	if rebuilt := dirNames(t, dataDir); !reflect.DeepEqual(rebuilt, names) {
+		t.Errorf("expected the files %v, got %v", names, rebuilt)
+	}
+	if data, err := os.ReadFile(filepath.Join(dataDir, VK_PATH)); err != nil || !bytes.Equal(data, vk) {
+		t.Errorf("expected the verifying key of the previous build to be kept")
+	}
+}
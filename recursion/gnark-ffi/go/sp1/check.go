@@ -0,0 +1,169 @@
+package sp1
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/test"
+)
+
+// RunTestEngine synthesizes the circuit described by the constraints file under gnark's test
+// engine and checks that the witness satisfies it, without compiling the circuit or running a
+// setup. If the witness does not satisfy the circuit, the error describes the failing assertion.
+func RunTestEngine(constraintsPath string, witnessPath string) error {
+	os.Setenv("CONSTRAINTS_JSON", constraintsPath)
+
+	// Read the file.
+	witnessInput, err := ReadWitnessInput(witnessPath)
+	if err != nil {
+		return err
+	}
+
+	circuit := NewCircuit(witnessInput)
+	assignment := NewCircuit(witnessInput)
+	return test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField())
+}
+
+// checkPosition is the index of the instruction being synthesized, or the number of instructions
+// once they have all been synthesized.
+type checkPosition struct {
+	index int
+}
+
+// CheckFailure locates the first assertion of the circuit that a witness violates.
+type CheckFailure struct {
+	// The failing instruction, or the number of instructions if the failing assertion follows them.
+	Index  int
+	Opcode string
+	Args   [][]string
+	// The source annotation of the instruction, if any, and the CycleTracker regions enclosing it,
+	// the outermost first.
+	Source  string
+	Regions []string
+	// The values of the ids read by the instruction, from the native evaluation of the stream.
+	Values map[string]string
+	// The mismatch found by the native evaluation, if it fails at the same instruction.
+	Trace *TraceFailure
+	// The error of the test engine.
+	Err error
+}
+
+func (f *CheckFailure) Error() string {
+	var sb strings.Builder
+	if f.Opcode == "" {
+		fmt.Fprintf(&sb, "the checks following the last instruction (%d) are not satisfied", f.Index)
+	} else {
+		fmt.Fprintf(&sb, "instruction %d (%s %s) is not satisfied", f.Index, f.Opcode, formatArgs(f.Args))
+	}
+	if f.Source != "" {
+		fmt.Fprintf(&sb, "\n  source: %s", f.Source)
+	}
+	if len(f.Regions) > 0 {
+		fmt.Fprintf(&sb, "\n  region: %s", strings.Join(f.Regions, " > "))
+	}
+	// Report the operands in the order of the instruction.
+	printed := make(map[string]bool)
+	for _, arg := range f.Args {
+		for _, id := range arg {
+			if value, ok := f.Values[id]; ok && !printed[id] {
+				fmt.Fprintf(&sb, "\n  %s = %s", id, value)
+				printed[id] = true
+			}
+		}
+	}
+	if f.Trace != nil {
+		fmt.Fprintf(&sb, "\n  native evaluation: %s: %s != %s", f.Trace.Message, f.Trace.Left, f.Trace.Right)
+	}
+	fmt.Fprintf(&sb, "\n  %v", f.Err)
+	return sb.String()
+}
+
+func (f *CheckFailure) Unwrap() error {
+	return f.Err
+}
+
+// enclosingRegions returns the CycleTracker regions left open by the instructions, the outermost
+// first, or those open before the first misnested one.
+func enclosingRegions(constraints []Constraint) []string {
+	var open []string
+	for _, cs := range constraints {
+		if cs.Opcode != "CycleTracker" {
+			continue
+		}
+		next, err := toggleRegion(open, cs.Args[0][0])
+		if err != nil {
+			break
+		}
+		open = next
+	}
+	return open
+}
+
+func formatArgs(args [][]string) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = strings.Join(arg, " ")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// CheckWitness checks the witness against the circuit under gnark's test engine, like
+// RunTestEngine, but keeps the stream as written so that a failing assertion can be attributed to
+// its instruction. The returned *CheckFailure reports the instruction, its operands and their
+// values from the native evaluation of the stream.
+func CheckWitness(constraintsPath string, witnessPath string) error {
+	os.Setenv("CONSTRAINTS_JSON", constraintsPath)
+	witnessInput, err := ReadWitnessInput(witnessPath)
+	if err != nil {
+		return err
+	}
+
+	position := &checkPosition{index: -1}
+	circuit := NewCircuit(witnessInput)
+	circuit.position = position
+	assignment := NewCircuit(witnessInput)
+	solveErr := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField())
+	if solveErr == nil {
+		return nil
+	}
+	if position.index < 0 {
+		// The stream could not be read.
+		return solveErr
+	}
+
+	constraints, err := ReadConstraints(constraintsPath)
+	if err != nil {
+		return err
+	}
+	failure := &CheckFailure{Index: position.index, Err: solveErr}
+	if position.index >= len(constraints) {
+		return failure
+	}
+	cs := constraints[position.index]
+	failure.Opcode = cs.Opcode
+	failure.Args = cs.Args
+	failure.Source = cs.Source
+	failure.Regions = enclosingRegions(constraints[:position.index])
+
+	// Evaluate the stream natively up to the failing instruction to recover its operands.
+	report, err := traceConstraints(constraints[:position.index+1], witnessInput)
+	if err != nil {
+		return failure
+	}
+	failure.Values = make(map[string]string)
+	for _, arg := range instructionReads(cs) {
+		for _, id := range arg {
+			for _, values := range []map[string]string{report.Vars, report.Felts, report.Exts} {
+				if value, ok := values[id]; ok {
+					failure.Values[id] = value
+				}
+			}
+		}
+	}
+	if report.Failure != nil && report.Failure.Index == position.index {
+		failure.Trace = report.Failure
+	}
+	return failure
+}
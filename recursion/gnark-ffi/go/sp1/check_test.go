@@ -0,0 +1,127 @@
+package sp1
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRunTestEngine(t *testing.T) {
+	err := RunTestEngine("testdata/basic_constraints.json", "testdata/basic_witness.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// corruptedWitness writes the basic witness with a third felt that no longer equals the product of
+// the first two, and returns its path.
+func corruptedWitness(t *testing.T) string {
+	data, err := os.ReadFile("testdata/basic_witness.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var witnessInput WitnessInput
+	if err := json.Unmarshal(data, &witnessInput); err != nil {
+		t.Fatal(err)
+	}
+
+	// The third felt is asserted to equal the product of the first two.
+	witnessInput.Felts[2] = "16"
+	data, err = json.Marshal(witnessInput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	witnessPath := filepath.Join(t.TempDir(), "witness.json")
+	if err := os.WriteFile(witnessPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return witnessPath
+}
+
+func TestRunTestEngineCorruptedWitness(t *testing.T) {
+	err := RunTestEngine("testdata/basic_constraints.json", corruptedWitness(t))
+	if err == nil {
+		t.Fatal("expected the corrupted witness to be rejected")
+	}
+	t.Log(err)
+	if !strings.Contains(err.Error(), "AssertIsEqualF") {
+		t.Fatalf("expected the error to point at the failing assertion, got: %v", err)
+	}
+}
+
+func TestCheckWitness(t *testing.T) {
+	if err := CheckWitness("testdata/basic_constraints.json", "testdata/basic_witness.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The position is the one of the file even when the stream would be rewritten.
+	t.Setenv("FUSE_INSTRUCTIONS", "true")
+	t.Setenv("ELIMINATE_COMMON_SUBEXPRESSIONS", "true")
+	err := CheckWitness("testdata/basic_constraints.json", corruptedWitness(t))
+	var failure *CheckFailure
+	if !errors.As(err, &failure) {
+		t.Fatalf("expected a CheckFailure, got %v", err)
+	}
+	t.Log(err)
+	if failure.Index != 4 || failure.Opcode != "AssertEqF" || !reflect.DeepEqual(failure.Args, [][]string{{"f2"}, {"f3"}}) {
+		t.Fatalf("expected instruction 4 (AssertEqF f2, f3), got %d (%s %v)", failure.Index, failure.Opcode, failure.Args)
+	}
+	if !reflect.DeepEqual(failure.Values, map[string]string{"f2": "15", "f3": "16"}) {
+		t.Fatalf("unexpected operand values %v", failure.Values)
+	}
+	if failure.Trace == nil || failure.Trace.Left != "15" || failure.Trace.Right != "16" {
+		t.Fatalf("expected the native evaluation to find 15 != 16, got %+v", failure.Trace)
+	}
+	for _, expected := range []string{"instruction 4 (AssertEqF f2, f3)", "f2 = 15", "f3 = 16", "15 != 16", "AssertIsEqual"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Fatalf("expected the diagnostic to contain %q, got: %v", expected, err)
+		}
+	}
+}
+
+func TestCheckWitnessAnnotations(t *testing.T) {
+	// The failing assertion of the regions stream is the only instruction of the challenger region.
+	err := CheckWitness("testdata/regions_constraints.json", corruptedWitness(t))
+	var failure *CheckFailure
+	if !errors.As(err, &failure) || failure.Index != 5 {
+		t.Fatalf("expected a CheckFailure at instruction 5, got %v", err)
+	}
+	if !reflect.DeepEqual(failure.Regions, []string{"challenger"}) || !strings.Contains(err.Error(), "region: challenger") {
+		t.Fatalf("expected the failure to be in the challenger region, got %v", failure.Regions)
+	}
+
+	data, err := os.ReadFile("testdata/basic_constraints.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertion := `{"opcode": "AssertEqF", "args": [["f2"], ["f3"]]}`
+	if !strings.Contains(string(data), assertion) {
+		t.Fatal("the basic stream has no assertion to annotate")
+	}
+	annotated := strings.Replace(string(data), assertion, `{"opcode": "AssertEqF", "args": [["f2"], ["f3"]], "source": "recursion/program/src/fri.rs:42"}`, 1)
+	constraintsPath := filepath.Join(t.TempDir(), "constraints.json")
+	if err := os.WriteFile(constraintsPath, []byte(annotated), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = CheckWitness(constraintsPath, corruptedWitness(t))
+	if !errors.As(err, &failure) || failure.Source != "recursion/program/src/fri.rs:42" || failure.Regions != nil {
+		t.Fatalf("expected the source of the assertion outside of any region, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "source: recursion/program/src/fri.rs:42") {
+		t.Fatalf("expected the diagnostic to report the source, got: %v", err)
+	}
+}
+
+func TestProveChecksWitness(t *testing.T) {
+	// Without a build, proving can only get as far as the check.
+	dataDir := newDevDataDir(t, "testdata/basic_constraints.json", "testdata/basic_witness.json")
+	_, err := ProveWithOptions(dataDir, corruptedWitness(t), ProveOptions{Check: true})
+	var failure *CheckFailure
+	if !errors.As(err, &failure) || failure.Index != 4 {
+		t.Fatalf("expected Prove to fail the check at instruction 4, got %v", err)
+	}
+}
@@ -0,0 +1,386 @@
+package sp1
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/consensys/gnark-crypto/ecc"
+)
+
+// The number of instructions a worker decodes at a time.
+var PARSE_CHUNK_SIZE int = 4096
+
+// The number of arguments of each opcode, or -n for opcodes taking at least n arguments.
+var opcodeArities = map[string]int{
+	"ImmV": 2, "ImmF": 2, "ImmE": 2,
+	"AddV": 3, "AddF": 3, "AddE": 3, "AddEF": 3,
+	"SubV": 3, "SubF": 3, "SubE": 3, "SubEF": 3,
+	"MulV": 3, "MulF": 3, "MulE": 3, "MulEF": 3, "MulAddF": 4, "SumE": -2,
+	"DivE": 3, "NegE": 2, "InvE": 2,
+	"Num2BitsV": 3, "Num2BitsF": 2,
+	"Permute": 3, "PermuteBabyBear": 16,
+	"SelectV": 4, "SelectF": 4, "SelectE": 4,
+	"Ext2Felt": 5, "CircuitFelts2Ext": 5,
+	"AssertEqV": 2, "AssertEqF": 2, "AssertEqE": 2,
+	"PrintV": 1, "PrintF": 1, "PrintE": 1,
+	"WitnessV": 2, "WitnessF": 2, "WitnessE": 2,
+	"CommitVkeyHash": 1, "CommitCommitedValuesDigest": 1,
+	"CycleTracker": 1,
+}
+
+// ReadConstraints reads and validates a constraints file. The instructions are decoded by
+// PARALLELISM workers and returned in the order of the file.
+func ReadConstraints(path string) ([]Constraint, error) {
+	input, err := openInput(path)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+	constraints, err := parseConstraints(input, parallelism())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return constraints, nil
+}
+
+// validateConstraint checks that an instruction has the shape its opcode expects.
+func validateConstraint(cs Constraint) error {
+	arity, ok := opcodeArities[cs.Opcode]
+	if !ok {
+		return fmt.Errorf("unhandled opcode: %s", cs.Opcode)
+	}
+	if (arity >= 0 && len(cs.Args) != arity) || (arity < 0 && len(cs.Args) < -arity) {
+		return fmt.Errorf("%s: unexpected number of arguments %d", cs.Opcode, len(cs.Args))
+	}
+	for i, arg := range cs.Args {
+		if len(arg) == 0 {
+			return fmt.Errorf("%s: argument %d is empty", cs.Opcode, i)
+		}
+	}
+	switch cs.Opcode {
+	case "ImmE":
+		if len(cs.Args[1]) != 4 {
+			return fmt.Errorf("ImmE: expected 4 coordinates, got %d", len(cs.Args[1]))
+		}
+	case "WitnessV", "WitnessF", "WitnessE":
+		if _, err := witnessIndex(cs); err != nil {
+			return err
+		}
+	case "Num2BitsV":
+		nbBits, err := strconv.Atoi(cs.Args[2][0])
+		if err != nil || nbBits < 0 || nbBits > ecc.BN254.ScalarField().BitLen() {
+			return fmt.Errorf("Num2BitsV: invalid number of bits %q", cs.Args[2][0])
+		}
+		if len(cs.Args[0]) > nbBits {
+			return fmt.Errorf("Num2BitsV: %d outputs for %d bits", len(cs.Args[0]), nbBits)
+		}
+	case "Num2BitsF":
+		if len(cs.Args[0]) > 32 {
+			return fmt.Errorf("Num2BitsF: %d outputs for 32 bits", len(cs.Args[0]))
+		}
+	}
+	return nil
+}
+
+// CheckSingleAssignment checks that no instruction assigns an id already assigned by an earlier
+// one. The compiler may reassign ids, so the stream is only checked when REJECT_REASSIGNMENTS is
+// set. Permutations update their arguments in place and do not count as assignments.
+func CheckSingleAssignment(constraints []Constraint) error {
+	assigned := make(map[string]int)
+	for i, cs := range constraints {
+		if cs.Opcode == "Permute" || cs.Opcode == "PermuteBabyBear" {
+			continue
+		}
+		for _, id := range instructionOutputs(cs) {
+			if previous, ok := assigned[id]; ok {
+				return fmt.Errorf("instruction %d: %s reassigns %s, assigned by instruction %d", i, cs.Opcode, id, previous)
+			}
+			assigned[id] = i
+		}
+	}
+	return nil
+}
+
+// witnessIndex returns the index of the witness value a WitnessV, WitnessF or WitnessE instruction
+// reads.
+func witnessIndex(cs Constraint) (int, error) {
+	i, err := strconv.Atoi(cs.Args[1][0])
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("%s: invalid witness index %q", cs.Opcode, cs.Args[1][0])
+	}
+	return i, nil
+}
+
+// witnessValue returns the witness value a WitnessV, WitnessF or WitnessE instruction reads.
+func witnessValue[T any](cs Constraint, values []T) (T, error) {
+	var value T
+	i, err := witnessIndex(cs)
+	if err != nil {
+		return value, err
+	}
+	if i >= len(values) {
+		return value, fmt.Errorf("%s: witness index %d is out of range, the witness has %d values", cs.Opcode, i, len(values))
+	}
+	return values[i], nil
+}
+
+// normalizeImmediates rewrites the immediates of an instruction as canonical decimals. The
+// compiler emits constants either signed or reduced, so a negative immediate -c becomes p-c, where
+// p is the BabyBear modulus for felts and the BN254 scalar field modulus for vars.
+func normalizeImmediates(cs *Constraint) error {
+	switch cs.Opcode {
+	case "ImmV":
+		v, err := parseVar(cs.Args[1][0])
+		if err != nil {
+			return fmt.Errorf("ImmV: %w", err)
+		}
+		cs.Args[1][0] = v.String()
+	case "ImmF", "ImmE":
+		for i, coordinate := range cs.Args[1] {
+			f, err := parseFelt(coordinate)
+			if err != nil {
+				return fmt.Errorf("%s: %w", cs.Opcode, err)
+			}
+			cs.Args[1][i] = strconv.FormatUint(f, 10)
+		}
+	}
+	return nil
+}
+
+type rawChunk struct {
+	index        int
+	instructions []json.RawMessage
+}
+
+type parsedChunk struct {
+	index       int
+	constraints []Constraint
+	err         error
+}
+
+// parseConstraints decodes a JSON array of instructions. A reader splits the array into chunks of
+// raw instructions, which nbWorkers workers decode and validate. The first error cancels the
+// remaining work.
+func parseConstraints(r io.Reader, nbWorkers int) ([]Constraint, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	chunks := make(chan rawChunk, nbWorkers)
+	results := make(chan parsedChunk, nbWorkers)
+
+	var readErr error
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		defer close(chunks)
+		splitter := instructionSplitter{r: bufio.NewReaderSize(r, 1<<20)}
+		index := 0
+		for {
+			instructions := make([]json.RawMessage, 0, PARSE_CHUNK_SIZE)
+			for len(instructions) < PARSE_CHUNK_SIZE {
+				instruction, err := splitter.next()
+				if err != nil {
+					readErr = fmt.Errorf("instruction %d: error deserializing JSON: %v", index+len(instructions), err)
+					return
+				}
+				if instruction == nil {
+					break
+				}
+				instructions = append(instructions, instruction)
+			}
+			if len(instructions) == 0 {
+				return
+			}
+			select {
+			case chunks <- rawChunk{index: index, instructions: instructions}:
+			case <-ctx.Done():
+				return
+			}
+			index += len(instructions)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < nbWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range chunks {
+				parsed := parsedChunk{index: chunk.index, constraints: make([]Constraint, len(chunk.instructions))}
+				for i, instruction := range chunk.instructions {
+					err := json.Unmarshal(instruction, &parsed.constraints[i])
+					if err == nil {
+						err = validateConstraint(parsed.constraints[i])
+					}
+					if err == nil {
+						err = normalizeImmediates(&parsed.constraints[i])
+					}
+					if err != nil {
+						parsed.err = fmt.Errorf("instruction %d: %w", chunk.index+i, err)
+						break
+					}
+				}
+				select {
+				case results <- parsed:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var parsed []parsedChunk
+	var firstErr *parsedChunk
+	for chunk := range results {
+		if chunk.err != nil {
+			if firstErr == nil || chunk.index < firstErr.index {
+				failed := chunk
+				firstErr = &failed
+			}
+			cancel()
+			continue
+		}
+		parsed = append(parsed, chunk)
+	}
+	<-readerDone
+	if firstErr != nil {
+		return nil, firstErr.err
+	}
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	sort.Slice(parsed, func(i, j int) bool { return parsed[i].index < parsed[j].index })
+	total := 0
+	for _, chunk := range parsed {
+		total += len(chunk.constraints)
+	}
+	constraints := make([]Constraint, 0, total)
+	for _, chunk := range parsed {
+		constraints = append(constraints, chunk.constraints...)
+	}
+	return constraints, nil
+}
+
+// instructionSplitter finds the elements of a JSON array without decoding them. Malformed elements
+// are left for json.Unmarshal to report.
+type instructionSplitter struct {
+	r       *bufio.Reader
+	started bool
+	done    bool
+}
+
+// next returns the next element of the array, or nil at the end of the array.
+func (s *instructionSplitter) next() ([]byte, error) {
+	if s.done {
+		return nil, nil
+	}
+	c, err := s.nextNonWhitespace()
+	if !s.started {
+		if err != nil || c != '[' {
+			return nil, fmt.Errorf("expected an array of instructions")
+		}
+		s.started = true
+		c, err = s.nextNonWhitespace()
+		if err == nil && c == ']' {
+			return nil, s.finish()
+		}
+	}
+
+	element := []byte{c}
+	depth := 0
+	inString, escaped := false, false
+	switch c {
+	case '"':
+		inString = true
+	case '{', '[':
+		depth++
+	case '}', ']':
+		if depth == 0 && c == ']' {
+			return []byte{}, s.finish()
+		}
+		depth--
+	case ',':
+		return []byte{}, nil
+	}
+	for err == nil {
+		// Scan the buffered bytes in place and copy them over in one go.
+		if _, err = s.r.Peek(1); err != nil {
+			break
+		}
+		buffered, _ := s.r.Peek(s.r.Buffered())
+		for i, c := range buffered {
+			if inString {
+				if escaped {
+					escaped = false
+				} else if c == '\\' {
+					escaped = true
+				} else if c == '"' {
+					inString = false
+				}
+				continue
+			}
+			switch c {
+			case '"':
+				inString = true
+			case '{', '[':
+				depth++
+			case '}', ']':
+				if depth > 0 || c != ']' {
+					depth--
+					continue
+				}
+				element = append(element, buffered[:i]...)
+				s.r.Discard(i + 1)
+				return element, s.finish()
+			case ',':
+				if depth == 0 {
+					element = append(element, buffered[:i]...)
+					s.r.Discard(i + 1)
+					return element, nil
+				}
+			}
+		}
+		element = append(element, buffered...)
+		s.r.Discard(len(buffered))
+	}
+	if err == io.EOF {
+		return nil, fmt.Errorf("unexpected end of input")
+	}
+	return nil, err
+}
+
+// finish checks that nothing follows the closing bracket of the array.
+func (s *instructionSplitter) finish() error {
+	s.done = true
+	if _, err := s.nextNonWhitespace(); err != io.EOF {
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("unexpected data after the array of instructions")
+	}
+	return nil
+}
+
+func (s *instructionSplitter) nextNonWhitespace() (byte, error) {
+	for {
+		c, err := s.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch c {
+		case ' ', '\t', '\n', '\r':
+		default:
+			return c, nil
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package sp1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// syntheticConstraints returns a valid stream of n instructions.
+func syntheticConstraints(n int) []Constraint {
+	constraints := make([]Constraint, 0, n)
+	constraints = append(constraints, Constraint{Opcode: "ImmF", Args: [][]string{{"f0"}, {"1"}}})
+	for i := 1; i < n; i++ {
+		a := fmt.Sprintf("f%d", i-1)
+		constraints = append(constraints, Constraint{Opcode: "AddF", Args: [][]string{{fmt.Sprintf("f%d", i)}, {a}, {a}}})
+	}
+	return constraints
+}
+
+func TestParseConstraints(t *testing.T) {
+	constraints := syntheticConstraints(10_000)
+	data, err := json.Marshal(constraints)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, nbWorkers := range []int{1, 3, 8} {
+		PARSE_CHUNK_SIZE = 100
+		parsed, err := parseConstraints(bytes.NewReader(data), nbWorkers)
+		PARSE_CHUNK_SIZE = 4096
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(parsed, constraints) {
+			t.Fatalf("%d workers: parsed instructions are out of order", nbWorkers)
+		}
+	}
+
+	parsed, err := ReadConstraints("testdata/basic_constraints.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != 14 || parsed[13].Opcode != "CommitCommitedValuesDigest" {
+		t.Fatalf("unexpected instructions: %+v", parsed)
+	}
+}
+
+func TestParseConstraintsErrors(t *testing.T) {
+	constraints := syntheticConstraints(1000)
+	data, err := json.Marshal(constraints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := string(data)
+	bad := `{"opcode":"AddF","args":[["f1"],["f0"]]}`
+	second := `{"opcode":"ImmF","args":[["f0"],["1"]]},`
+
+	for input, expected := range map[string]string{
+		`{}`: "expected an array",
+		strings.Replace(valid, second, second+bad+",", 1):                       "instruction 1: AddF: unexpected number of arguments 2",
+		strings.Replace(valid, second, second+`{"opcode":"Foo","args":[]},`, 1): "instruction 1: unhandled opcode: Foo",
+		strings.Replace(valid, `"f500"`, `"f500`, 1):                            "instruction 500",
+		valid[:len(valid)-1]: "instruction 999: error deserializing JSON: unexpected end of input",
+		strings.Replace(valid, second, `{"opcode":"ImmF","args":[["f0"],["-x"]]},`, 1):                             "instruction 0: ImmF: \"-x\" is not a number",
+		strings.Replace(valid, second, second+`{"opcode":"WitnessF","args":[["f9"],["-1"]]},`, 1):                  "instruction 1: WitnessF: invalid witness index \"-1\"",
+		strings.Replace(valid, second, second+`{"opcode":"Num2BitsV","args":[["b0","b1","b2"],["v0"],["2"]]},`, 1): "instruction 1: Num2BitsV: 3 outputs for 2 bits",
+	} {
+		PARSE_CHUNK_SIZE = 16
+		_, err := parseConstraints(strings.NewReader(input), 4)
+		PARSE_CHUNK_SIZE = 4096
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected an error containing %q, got %v", expected, err)
+		}
+	}
+}
+
+func TestNegativeImmediates(t *testing.T) {
+	signed, err := ReadConstraints("testdata/signed_immediates_constraints.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reduced, err := ReadConstraints("testdata/reduced_immediates_constraints.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(signed, reduced) {
+		t.Fatalf("signed and reduced immediates parse differently:\n%+v\n%+v", signed, reduced)
+	}
+
+	signedDigest, err := compileDigest(t, "testdata/signed_immediates_constraints.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reducedDigest, err := compileDigest(t, "testdata/reduced_immediates_constraints.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signedDigest != reducedDigest {
+		t.Fatalf("signed and reduced immediates compile to different circuits: %s != %s", signedDigest, reducedDigest)
+	}
+	if err := RunTestEngine("testdata/signed_immediates_constraints.json", "testdata/basic_witness.json"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func BenchmarkParseConstraints(b *testing.B) {
+	data, err := json.Marshal(syntheticConstraints(500_000))
+	if err != nil {
+		b.Fatal(err)
+	}
+	path := b.TempDir() + "/constraints.json"
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			var constraints []Constraint
+			if err := json.Unmarshal(data, &constraints); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := ReadConstraints(path); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestParseConstraintsSplitting(t *testing.T) {
+	parsed, err := parseConstraints(strings.NewReader(" [ ] \n"), 2)
+	if err != nil || len(parsed) != 0 {
+		t.Fatalf("expected an empty stream, got %v, %v", parsed, err)
+	}
+
+	// Brackets, commas and escaped quotes inside strings do not split instructions.
+	input := `[{"opcode":"ImmV","args":[["v]0,"],["1"]]},{"opcode":"ImmV","args":[["v\"1["],["2"]]}]`
+	parsed, err = parseConstraints(strings.NewReader(input), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != 2 || parsed[0].Args[0][0] != "v]0," || parsed[1].Args[0][0] != `v"1[` {
+		t.Fatalf("unexpected instructions: %+v", parsed)
+	}
+
+	if _, err := parseConstraints(strings.NewReader(`[] []`), 2); err == nil || !strings.Contains(err.Error(), "after the array") {
+		t.Fatalf("expected trailing data to be rejected, got %v", err)
+	}
+	if _, err := parseConstraints(strings.NewReader(`[{"opcode":"PrintV","args":[["v0"]]},]`), 2); err == nil || !strings.Contains(err.Error(), "instruction 1") {
+		t.Fatalf("expected a trailing comma to be rejected, got %v", err)
+	}
+}
+
+func TestCheckSingleAssignment(t *testing.T) {
+	for _, path := range []string{"testdata/basic_constraints.json", "testdata/hints_constraints.json"} {
+		constraints, err := ReadConstraints(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := CheckSingleAssignment(constraints); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+
+	// Permutations update their state in place.
+	constraints := []Constraint{
+		{Opcode: "ImmV", Args: [][]string{{"v0"}, {"1"}}},
+		{Opcode: "ImmV", Args: [][]string{{"v1"}, {"2"}}},
+		{Opcode: "ImmV", Args: [][]string{{"v2"}, {"3"}}},
+		{Opcode: "Permute", Args: [][]string{{"v0"}, {"v1"}, {"v2"}}},
+		{Opcode: "Permute", Args: [][]string{{"v0"}, {"v1"}, {"v2"}}},
+	}
+	if err := CheckSingleAssignment(constraints); err != nil {
+		t.Fatal(err)
+	}
+	constraints = append(constraints, Constraint{Opcode: "AddV", Args: [][]string{{"v1"}, {"v0"}, {"v2"}}})
+	expected := "instruction 5: AddV reassigns v1, assigned by instruction 1"
+	if err := CheckSingleAssignment(constraints); err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+}
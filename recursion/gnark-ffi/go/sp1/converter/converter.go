@@ -0,0 +1,115 @@
+// Package converter converts between BN254 variables and BabyBear felts, in the layouts the SP1
+// recursion circuit packs digests with.
+//
+// A digest of NUM_FELTS felts packs into a BN254 scalar 31 bits per felt, the first felt in the
+// high bits, like babybears_to_bn254. A digest of NUM_BYTES byte felts packs big-endian with the top
+// MASKED_BITS bits dropped, like babybear_bytes_to_bn254. These layouts only cover the packings of
+// digests, and the first one is that of SplitIntoBabyBear, here split by a hint.
+package converter
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/consensys/gnark/frontend"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+)
+
+// The number of felts of a packed digest and the bits of each.
+const NUM_FELTS = 8
+const FELT_BITS = 31
+
+// The number of bytes of a packed byte digest, and the number of its top bits dropped so that it
+// fits in a BN254 scalar.
+const NUM_BYTES = 32
+const MASKED_BITS = 3
+
+func init() {
+	solver.RegisterHint(SplitFeltsHint)
+}
+
+// BabyBearsToBN254 packs the felts into a BN254 scalar below 2^248. The felts are decomposed with
+// ToBinaryStrict, so each digest has a single packing.
+func BabyBearsToBN254(api frontend.API, chip *babybear.Chip, felts [NUM_FELTS]babybear.Variable) frontend.Variable {
+	var packed frontend.Variable = 0
+	for _, felt := range felts {
+		packed = api.Add(api.Mul(packed, 1<<FELT_BITS), api.FromBinary(chip.ToBinaryStrict(felt)...))
+	}
+	return packed
+}
+
+// BN254ToBabyBears is the inverse of BabyBearsToBN254. The felts are hinted and packed back into
+// in, so in must be below 2^248 with each 31 bit limb a canonical felt, or the circuit is
+// unsatisfiable.
+func BN254ToBabyBears(api frontend.API, chip *babybear.Chip, in frontend.Variable) [NUM_FELTS]babybear.Variable {
+	hinted, err := api.Compiler().NewHint(SplitFeltsHint, NUM_FELTS, in)
+	if err != nil {
+		panic(err)
+	}
+	var felts [NUM_FELTS]babybear.Variable
+	for i := range felts {
+		felts[i] = babybear.Variable{Value: hinted[i], NbBits: 31}
+	}
+	// The strict decomposition bounds every felt, so the packing does not wrap around the modulus.
+	api.AssertIsEqual(BabyBearsToBN254(api, chip, felts), in)
+	return felts
+}
+
+// BabyBearBytesToBN254 packs the byte felts into a BN254 scalar below 2^253. Each felt is asserted
+// to be a byte, the top MASKED_BITS bits of the first one included.
+func BabyBearBytesToBN254(api frontend.API, chip *babybear.Chip, bytes [NUM_BYTES]babybear.Variable) frontend.Variable {
+	var packed frontend.Variable = 0
+	for i, b := range bytes {
+		bits := chip.ToBinaryN(b, 8)
+		if i == 0 {
+			bits = bits[:8-MASKED_BITS]
+		}
+		packed = api.Add(api.Mul(packed, 256), api.FromBinary(bits...))
+	}
+	return packed
+}
+
+// BN254ToBabyBearBytes is the inverse of BabyBearBytesToBN254 on the scalars below 2^253, the top
+// MASKED_BITS bits of the first byte being zero. Larger scalars make the circuit unsatisfiable.
+func BN254ToBabyBearBytes(api frontend.API, in frontend.Variable) [NUM_BYTES]babybear.Variable {
+	bits := api.ToBinary(in, 8*NUM_BYTES-MASKED_BITS)
+	var bytes [NUM_BYTES]babybear.Variable
+	for i := range bytes {
+		// The bytes are big-endian and the bits little-endian.
+		low := 8 * (NUM_BYTES - 1 - i)
+		bytes[i] = babybear.Variable{Value: api.FromBinary(bits[low:min(low+8, len(bits))]...), NbBits: 31}
+	}
+	return bytes
+}
+
+// BabyBearsToBN254Native is BabyBearsToBN254 on canonical felts outside of a circuit.
+func BabyBearsToBN254Native(felts [NUM_FELTS]uint32) *big.Int {
+	packed := new(big.Int)
+	for _, felt := range felts {
+		packed.Lsh(packed, FELT_BITS).Add(packed, new(big.Int).SetUint64(uint64(felt)))
+	}
+	return packed
+}
+
+// BabyBearBytesToBN254Native is BabyBearBytesToBN254 outside of a circuit.
+func BabyBearBytesToBN254Native(bytes [NUM_BYTES]byte) *big.Int {
+	packed := new(big.Int).SetBytes(bytes[:])
+	mask := new(big.Int).Lsh(big.NewInt(1), 8*NUM_BYTES-MASKED_BITS)
+	return packed.And(packed, mask.Sub(mask, big.NewInt(1)))
+}
+
+// The hint used to split BN254ToBabyBears, which returns the 31 bit limbs of the input from the
+// high ones.
+func SplitFeltsHint(_ *big.Int, inputs []*big.Int, results []*big.Int) error {
+	if len(inputs) != 1 || len(results) != NUM_FELTS {
+		return fmt.Errorf("SplitFeltsHint expects 1 input and %d outputs", NUM_FELTS)
+	}
+	mask := big.NewInt(1<<FELT_BITS - 1)
+	rest := new(big.Int).Set(inputs[0])
+	for i := NUM_FELTS - 1; i >= 0; i-- {
+		results[i].And(rest, mask)
+		rest.Rsh(rest, FELT_BITS)
+	}
+	return nil
+}
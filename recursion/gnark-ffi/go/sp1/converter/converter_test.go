@@ -0,0 +1,172 @@
+package converter
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+)
+
+type feltsCircuit struct {
+	Felts  [NUM_FELTS]frontend.Variable
+	Packed frontend.Variable `gnark:",public"`
+}
+
+func (circuit *feltsCircuit) Define(api frontend.API) error {
+	chip := babybear.NewChip(api)
+	var felts [NUM_FELTS]babybear.Variable
+	for i, v := range circuit.Felts {
+		felts[i] = babybear.Variable{Value: v, NbBits: 31}
+	}
+	api.AssertIsEqual(BabyBearsToBN254(api, chip, felts), circuit.Packed)
+	for i, felt := range BN254ToBabyBears(api, chip, circuit.Packed) {
+		api.AssertIsEqual(felt.Value, circuit.Felts[i])
+	}
+	return nil
+}
+
+type bytesCircuit struct {
+	Bytes  [NUM_BYTES]frontend.Variable
+	Packed frontend.Variable `gnark:",public"`
+}
+
+func (circuit *bytesCircuit) Define(api frontend.API) error {
+	chip := babybear.NewChip(api)
+	var bytes [NUM_BYTES]babybear.Variable
+	for i, v := range circuit.Bytes {
+		bytes[i] = babybear.Variable{Value: v, NbBits: 31}
+	}
+	api.AssertIsEqual(BabyBearBytesToBN254(api, chip, bytes), circuit.Packed)
+	return nil
+}
+
+type bytesInverseCircuit struct {
+	Bytes  [NUM_BYTES]frontend.Variable
+	Packed frontend.Variable `gnark:",public"`
+}
+
+func (circuit *bytesInverseCircuit) Define(api frontend.API) error {
+	for i, b := range BN254ToBabyBearBytes(api, circuit.Packed) {
+		api.AssertIsEqual(b.Value, circuit.Bytes[i])
+	}
+	return nil
+}
+
+func randomFelts(rng *rand.Rand) [NUM_FELTS]uint32 {
+	var felts [NUM_FELTS]uint32
+	for i := range felts {
+		felts[i] = uint32(rng.Int63n(int64(babybear.MODULUS.Uint64())))
+	}
+	return felts
+}
+
+func feltsAssignment(felts [NUM_FELTS]uint32, packed *big.Int) *feltsCircuit {
+	assignment := &feltsCircuit{Packed: packed}
+	for i, felt := range felts {
+		assignment.Felts[i] = felt
+	}
+	return assignment
+}
+
+func TestBabyBearsToBN254(t *testing.T) {
+	// The vkey hash of the SP1 wrapper packs the first felt in the high bits.
+	felts := [NUM_FELTS]uint32{1, 0, 0, 0, 0, 0, 0, 2}
+	expected := new(big.Int).Lsh(big.NewInt(1), 7*FELT_BITS)
+	expected.Add(expected, big.NewInt(2))
+	if packed := BabyBearsToBN254Native(felts); packed.Cmp(expected) != 0 {
+		t.Fatalf("packed %s, expected %s", packed, expected)
+	}
+
+	rng := rand.New(rand.NewSource(0))
+	for i := 0; i < 8; i++ {
+		felts := randomFelts(rng)
+		assignment := feltsAssignment(felts, BabyBearsToBN254Native(felts))
+		if err := test.IsSolved(&feltsCircuit{}, assignment, ecc.BN254.ScalarField()); err != nil {
+			t.Fatalf("felts %v: %v", felts, err)
+		}
+	}
+
+	felts = randomFelts(rng)
+	packed := BabyBearsToBN254Native(felts)
+	// The last felt plus p is the same felt as 3, with another packing.
+	p := babybear.MODULUS.Uint64()
+	canonical, alias := felts, felts
+	canonical[NUM_FELTS-1], alias[NUM_FELTS-1] = 3, 3+uint32(p)
+	for name, assignment := range map[string]*feltsCircuit{
+		"wrong packing":      feltsAssignment(felts, new(big.Int).Add(packed, big.NewInt(1))),
+		"non-canonical felt": feltsAssignment(alias, new(big.Int).Add(BabyBearsToBN254Native(canonical), babybear.MODULUS)),
+		"too large":          feltsAssignment(felts, new(big.Int).Add(packed, new(big.Int).Lsh(big.NewInt(1), NUM_FELTS*FELT_BITS))),
+	} {
+		if err := test.IsSolved(&feltsCircuit{}, assignment, ecc.BN254.ScalarField()); err == nil {
+			t.Errorf("%s: expected the packing to be rejected", name)
+		}
+	}
+}
+
+func TestBabyBearBytesToBN254(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	for i := 0; i < 8; i++ {
+		var bytes [NUM_BYTES]byte
+		rng.Read(bytes[:])
+		packed := BabyBearBytesToBN254Native(bytes)
+		if packed.BitLen() > 8*NUM_BYTES-MASKED_BITS {
+			t.Fatalf("packing %s exceeds %d bits", packed, 8*NUM_BYTES-MASKED_BITS)
+		}
+
+		assignment := &bytesCircuit{Packed: packed}
+		inverse := &bytesInverseCircuit{Packed: packed}
+		for j, b := range bytes {
+			assignment.Bytes[j] = b
+			inverse.Bytes[j] = b
+		}
+		if err := test.IsSolved(&bytesCircuit{}, assignment, ecc.BN254.ScalarField()); err != nil {
+			t.Fatalf("bytes %x: %v", bytes, err)
+		}
+		// The inverse returns the first byte with its top bits masked off.
+		inverse.Bytes[0] = bytes[0] & (1<<(8-MASKED_BITS) - 1)
+		if err := test.IsSolved(&bytesInverseCircuit{}, inverse, ecc.BN254.ScalarField()); err != nil {
+			t.Fatalf("bytes %x: %v", bytes, err)
+		}
+	}
+
+	// A felt above 255 is rejected, though it packs like the bytes 1, 1.
+	assignment := &bytesCircuit{Packed: 256 + 1}
+	for i := range assignment.Bytes {
+		assignment.Bytes[i] = 0
+	}
+	assignment.Bytes[NUM_BYTES-1] = 257
+	if err := test.IsSolved(&bytesCircuit{}, assignment, ecc.BN254.ScalarField()); err == nil {
+		t.Error("expected a felt above 255 to be rejected")
+	}
+}
+
+// The packings fit in the 255 bit scalar field of BLS12-381 as well.
+func TestBLS12381(t *testing.T) {
+	field := ecc.BLS12_381.ScalarField()
+	rng := rand.New(rand.NewSource(0))
+	felts := randomFelts(rng)
+	if err := test.IsSolved(&feltsCircuit{}, feltsAssignment(felts, BabyBearsToBN254Native(felts)), field); err != nil {
+		t.Fatalf("felts %v: %v", felts, err)
+	}
+
+	var bytes [NUM_BYTES]byte
+	rng.Read(bytes[:])
+	packed := BabyBearBytesToBN254Native(bytes)
+	assignment := &bytesCircuit{Packed: packed}
+	inverse := &bytesInverseCircuit{Packed: packed}
+	for j, b := range bytes {
+		assignment.Bytes[j] = b
+		inverse.Bytes[j] = b
+	}
+	inverse.Bytes[0] = bytes[0] & (1<<(8-MASKED_BITS) - 1)
+	if err := test.IsSolved(&bytesCircuit{}, assignment, field); err != nil {
+		t.Fatalf("bytes %x: %v", bytes, err)
+	}
+	if err := test.IsSolved(&bytesInverseCircuit{}, inverse, field); err != nil {
+		t.Fatalf("bytes %x: %v", bytes, err)
+	}
+}
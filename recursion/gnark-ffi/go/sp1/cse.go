@@ -0,0 +1,138 @@
+package sp1
+
+import (
+	"strconv"
+	"strings"
+)
+
+// The opcodes without side effects, whose result only depends on their operands.
+var pureOpcodes = map[string]bool{
+	"ImmV": true, "ImmF": true, "ImmE": true,
+	"AddV": true, "AddF": true, "AddE": true, "AddEF": true,
+	"SubV": true, "SubF": true, "SubE": true, "SubEF": true,
+	"MulV": true, "MulF": true, "MulE": true, "MulEF": true, "MulAddF": true, "SumE": true,
+	"DivE": true, "NegE": true, "InvE": true,
+	"Num2BitsV": true, "Num2BitsF": true,
+	"SelectV": true, "SelectF": true, "SelectE": true,
+	"Ext2Felt": true, "CircuitFelts2Ext": true,
+}
+
+// EliminateCommonSubexpressions removes pure instructions that repeat an earlier instruction with
+// the same opcode and operands, and makes later instructions read the earlier result instead.
+// Asserts, prints, witness reads, commits and permutations are never eliminated, and neither is an
+// instruction whose results or operands are assigned more than once in the stream, since the same
+// ids may then hold different values, as after a permutation overwrites its state.
+//
+// It returns the rewritten stream and the number of eliminated instructions.
+func EliminateCommonSubexpressions(constraints []Constraint) ([]Constraint, int) {
+	nbWrites := make(map[string]int)
+	for _, cs := range constraints {
+		for _, id := range instructionOutputs(cs) {
+			nbWrites[id]++
+		}
+	}
+
+	rename := make(map[string]string)
+	first := make(map[string][]string)
+	out := make([]Constraint, 0, len(constraints))
+	for _, cs := range constraints {
+		outputs := instructionOutputs(cs)
+		isOutput := make(map[string]bool, len(outputs))
+		for _, id := range outputs {
+			isOutput[id] = true
+		}
+
+		// Read the operands through the renaming of eliminated results.
+		args := make([][]string, len(cs.Args))
+		for i, arg := range cs.Args {
+			args[i] = make([]string, len(arg))
+			for j, id := range arg {
+				if renamed, ok := rename[id]; ok && !isOutput[id] {
+					id = renamed
+				}
+				args[i][j] = id
+			}
+		}
+		cs = Constraint{Opcode: cs.Opcode, Args: args}
+
+		if !pureOpcodes[cs.Opcode] || !writtenOnce(nbWrites, outputs) || reassigned(nbWrites, instructionOperands(cs)) {
+			out = append(out, cs)
+			continue
+		}
+
+		key := instructionKey(cs)
+		if previous, ok := first[key]; ok {
+			for i, id := range outputs {
+				rename[id] = previous[i]
+			}
+			continue
+		}
+		first[key] = outputs
+		out = append(out, cs)
+	}
+	return out, len(constraints) - len(out)
+}
+
+// instructionOutputs returns the ids assigned by an instruction.
+func instructionOutputs(cs Constraint) []string {
+	switch cs.Opcode {
+	case "AssertEqV", "AssertEqF", "AssertEqE", "PrintV", "PrintF", "PrintE", "CommitVkeyHash", "CommitCommitedValuesDigest", "CycleTracker":
+		return nil
+	case "Permute", "PermuteBabyBear":
+		outputs := make([]string, len(cs.Args))
+		for i, arg := range cs.Args {
+			outputs[i] = arg[0]
+		}
+		return outputs
+	case "Ext2Felt":
+		return []string{cs.Args[0][0], cs.Args[1][0], cs.Args[2][0], cs.Args[3][0]}
+	default:
+		return cs.Args[0]
+	}
+}
+
+// instructionOperands returns the arguments of a pure instruction that are not outputs.
+func instructionOperands(cs Constraint) [][]string {
+	if cs.Opcode == "Ext2Felt" {
+		return cs.Args[4:]
+	}
+	return cs.Args[1:]
+}
+
+// instructionKey identifies an instruction by its opcode and operands.
+func instructionKey(cs Constraint) string {
+	var sb strings.Builder
+	sb.WriteString(cs.Opcode)
+	if cs.Opcode != "Ext2Felt" {
+		// Num2Bits instructions may list a different number of output bits.
+		sb.WriteString("/")
+		sb.WriteString(strconv.Itoa(len(cs.Args[0])))
+	}
+	for _, arg := range instructionOperands(cs) {
+		sb.WriteString("|")
+		sb.WriteString(strings.Join(arg, ","))
+	}
+	return sb.String()
+}
+
+func writtenOnce(nbWrites map[string]int, ids []string) bool {
+	for _, id := range ids {
+		if nbWrites[id] != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// reassigned reports whether an operand is assigned more than once. Immediate values and bit
+// counts are never assigned, and read the same everywhere.
+func reassigned(nbWrites map[string]int, operands [][]string) bool {
+	for _, arg := range operands {
+		for _, id := range arg {
+			if nbWrites[id] > 1 {
+				return true
+			}
+		}
+	}
+	return false
+}
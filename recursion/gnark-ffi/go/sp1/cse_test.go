@@ -0,0 +1,124 @@
+package sp1
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEliminateCommonSubexpressions(t *testing.T) {
+	constraints := readTestConstraints(t, "testdata/cse_constraints.json")
+	eliminated, nbEliminated := EliminateCommonSubexpressions(constraints)
+
+	// The second MulF and InvE are duplicates, and so is the second MulE once e2 reads as e1.
+	if nbEliminated != 3 || len(eliminated) != len(constraints)-3 {
+		t.Fatalf("expected 3 eliminated instructions, got %d", nbEliminated)
+	}
+	if !reflect.DeepEqual(eliminated[3].Args, [][]string{{"f4"}, {"f2"}, {"f2"}}) {
+		t.Fatalf("expected f3 to be read as f2, got %v", eliminated[3].Args)
+	}
+	if !reflect.DeepEqual(eliminated[9], Constraint{Opcode: "MulE", Args: [][]string{{"e3"}, {"e1"}, {"e1"}}}) {
+		t.Fatalf("unexpected instruction: %+v", eliminated[9])
+	}
+	if !reflect.DeepEqual(eliminated[10].Args, [][]string{{"e3"}, {"e3"}}) {
+		t.Fatalf("expected e4 to be read as e3, got %v", eliminated[10].Args)
+	}
+	for _, cs := range eliminated {
+		if cs.Opcode == "WitnessF" && cs.Args[0][0] == "f7" {
+			return
+		}
+	}
+	t.Fatal("expected witness reads to be kept")
+}
+
+func TestEliminateCommonSubexpressionsPreservesValues(t *testing.T) {
+	constraints := readTestConstraints(t, "testdata/cse_constraints.json")
+	eliminated, _ := EliminateCommonSubexpressions(constraints)
+
+	witnessInput := readTestWitness(t, "testdata/basic_witness.json")
+	reference, err := traceConstraints(constraints, witnessInput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := traceConstraints(eliminated, witnessInput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reference.Failure != nil || report.Failure != nil {
+		t.Fatalf("unexpected failures: %v, %v", reference.Failure, report.Failure)
+	}
+	for id, value := range report.Felts {
+		if reference.Felts[id] != value {
+			t.Fatalf("%s = %s after elimination, expected %s", id, value, reference.Felts[id])
+		}
+	}
+	for id, value := range report.Exts {
+		if reference.Exts[id] != value {
+			t.Fatalf("%s = %s after elimination, expected %s", id, value, reference.Exts[id])
+		}
+	}
+}
+
+func TestEliminateCommonSubexpressionsReassignedIds(t *testing.T) {
+	// v2 is overwritten by the permutation, so it must not be replaced by v0.
+	constraints := []Constraint{
+		{Opcode: "ImmV", Args: [][]string{{"v0"}, {"1"}}},
+		{Opcode: "ImmV", Args: [][]string{{"v1"}, {"2"}}},
+		{Opcode: "ImmV", Args: [][]string{{"v2"}, {"1"}}},
+		{Opcode: "Permute", Args: [][]string{{"v0"}, {"v1"}, {"v2"}}},
+	}
+	eliminated, nbEliminated := EliminateCommonSubexpressions(constraints)
+	if nbEliminated != 0 || !reflect.DeepEqual(eliminated, constraints) {
+		t.Fatalf("expected no elimination, got %+v", eliminated)
+	}
+}
+
+func TestEliminateCommonSubexpressionsReassignedOperands(t *testing.T) {
+	// The permutation overwrites v0 and v1, so v4 differs from v3 despite the same operands.
+	constraints := []Constraint{
+		{Opcode: "ImmV", Args: [][]string{{"v0"}, {"1"}}},
+		{Opcode: "ImmV", Args: [][]string{{"v1"}, {"2"}}},
+		{Opcode: "ImmV", Args: [][]string{{"v2"}, {"3"}}},
+		{Opcode: "AddV", Args: [][]string{{"v3"}, {"v0"}, {"v1"}}},
+		{Opcode: "Permute", Args: [][]string{{"v0"}, {"v1"}, {"v2"}}},
+		{Opcode: "AddV", Args: [][]string{{"v4"}, {"v0"}, {"v1"}}},
+		{Opcode: "AddV", Args: [][]string{{"v5"}, {"v1"}, {"v0"}}},
+		{Opcode: "AssertEqV", Args: [][]string{{"v4"}, {"v5"}}},
+		{Opcode: "ImmV", Args: [][]string{{"v6"}, {"3"}}},
+		{Opcode: "AssertEqV", Args: [][]string{{"v3"}, {"v6"}}},
+	}
+	eliminated, nbEliminated := EliminateCommonSubexpressions(constraints)
+	if nbEliminated != 0 || !reflect.DeepEqual(eliminated, constraints) {
+		t.Fatalf("expected no elimination, got %+v", eliminated)
+	}
+
+	data, err := json.Marshal(constraints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	constraintsPath := filepath.Join(t.TempDir(), "constraints.json")
+	if err := os.WriteFile(constraintsPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ELIMINATE_COMMON_SUBEXPRESSIONS", "true")
+	if err := RunTestEngine(constraintsPath, "testdata/basic_witness.json"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEliminateCommonSubexpressionsCircuit(t *testing.T) {
+	t.Setenv("ELIMINATE_COMMON_SUBEXPRESSIONS", "true")
+	if err := RunTestEngine("testdata/cse_constraints.json", "testdata/basic_witness.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	report := Build(newDevDataDir(t, "testdata/cse_constraints.json", "testdata/basic_witness.json"))
+	if report.NbEliminated != 3 || report.NbInstructions != 17 {
+		t.Fatalf("unexpected elimination statistics: %d eliminated, %d instructions", report.NbEliminated, report.NbInstructions)
+	}
+	if report.CSEConstraintDelta <= 0 {
+		t.Fatalf("expected eliminating an inversion to save constraints, got %d", report.CSEConstraintDelta)
+	}
+}
@@ -0,0 +1,119 @@
+package sp1
+
+import (
+	"fmt"
+
+	"github.com/consensys/gnark/frontend"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+)
+
+// Witness holds the witness values read by the WitnessV, WitnessF and WitnessE instructions of a
+// constraints file, at the index given by each instruction. A circuit embedding the verifier
+// declares a Witness field, so that gnark allocates its variables, and passes it to DefineInto.
+// The slices must have the lengths given by the CircuitMetadata of the constraints file, both in
+// the circuit compiled and in the assignment. The assignment is filled like NewCircuit does, from
+// the vars, felts and exts of the witness JSON.
+type Witness struct {
+	Vars  []frontend.Variable
+	Felts []babybear.Variable
+	Exts  []babybear.ExtensionVariable
+}
+
+// CircuitMetadata describes the verifier circuit of a constraints file.
+type CircuitMetadata struct {
+	ConstraintsPath string
+	NbInstructions  int
+	// The lengths of the slices of the Witness.
+	NbVars  int
+	NbFelts int
+	NbExts  int
+}
+
+// NewWitness allocates a Witness of the lengths given by the metadata, to be compiled. Like the
+// witness values, felts and extension coordinates are declared canonical.
+func (m *CircuitMetadata) NewWitness() Witness {
+	witness := Witness{
+		Vars:  make([]frontend.Variable, m.NbVars),
+		Felts: make([]babybear.Variable, m.NbFelts),
+		Exts:  make([]babybear.ExtensionVariable, m.NbExts),
+	}
+	for i := range witness.Felts {
+		witness.Felts[i].NbBits = 31
+	}
+	for i := range witness.Exts {
+		for j := range witness.Exts[i].Value {
+			witness.Exts[i].Value[j].NbBits = 31
+		}
+	}
+	return witness
+}
+
+// NewWitnessAssignment returns the Witness assigning the values of a witness input.
+func NewWitnessAssignment(witnessInput WitnessInput) Witness {
+	circuit := NewCircuit(witnessInput)
+	return Witness{Vars: circuit.Vars, Felts: circuit.Felts, Exts: circuit.Exts}
+}
+
+// BuildVerifierCircuit reads the constraints file and returns the verifier circuit it describes,
+// ready to be compiled, with its metadata. The returned circuit is a *Circuit, whose Define
+// interprets the file like the circuit built by Build. Its DefineInto method runs the same
+// interpreter from the Define of another circuit.
+func BuildVerifierCircuit(constraintsPath string) (frontend.Circuit, *CircuitMetadata, error) {
+	constraints, err := ReadConstraints(constraintsPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	metadata := &CircuitMetadata{ConstraintsPath: constraintsPath, NbInstructions: len(constraints)}
+	for i, cs := range constraints {
+		var n *int
+		switch cs.Opcode {
+		case "WitnessV":
+			n = &metadata.NbVars
+		case "WitnessF":
+			n = &metadata.NbFelts
+		case "WitnessE":
+			n = &metadata.NbExts
+		default:
+			continue
+		}
+		index, err := witnessIndex(cs)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: instruction %d: %w", constraintsPath, i, err)
+		}
+		*n = max(*n, index+1)
+	}
+
+	witness := metadata.NewWitness()
+	circuit := &Circuit{
+		Vars:            witness.Vars,
+		Felts:           witness.Felts,
+		Exts:            witness.Exts,
+		constraintsPath: constraintsPath,
+	}
+	return circuit, metadata, nil
+}
+
+// DefineInto runs the interpreter on the witness from the Define of another circuit. It returns
+// the values the constraints file commits to, which Define asserts equal to the public inputs:
+// the vkey hash and the committed values digest, the latter range checked like in Define. The
+// caller decides how to expose or constrain them.
+func (circuit *Circuit) DefineInto(api frontend.API, witness *Witness) (publicOutputs []frontend.Variable, err error) {
+	publicOutputs = make([]frontend.Variable, 2)
+	err = circuit.synthesize(api, witness, func(index int, v frontend.Variable) {
+		if publicOutputs[index] != nil {
+			api.AssertIsEqual(publicOutputs[index], v)
+			return
+		}
+		publicOutputs[index] = v
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, name := range []string{"CommitVkeyHash", "CommitCommitedValuesDigest"} {
+		if publicOutputs[i] == nil {
+			return nil, fmt.Errorf("the constraints do not contain a %s instruction", name)
+		}
+	}
+	api.ToBinary(publicOutputs[1], COMMITTED_VALUES_DIGEST_BITS)
+	return publicOutputs, nil
+}
@@ -0,0 +1,86 @@
+package sp1_test
+
+import (
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/scs"
+	"github.com/consensys/gnark/test"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1"
+)
+
+// applicationCircuit embeds the verifier and adds its own constraint on the committed values
+// digest.
+type applicationCircuit struct {
+	Verifier             sp1.Witness
+	VkeyHash             frontend.Variable `gnark:",public"`
+	CommitedValuesDigest frontend.Variable `gnark:",public"`
+	// Must be the committed values digest plus one.
+	Next frontend.Variable
+
+	verifier *sp1.Circuit `gnark:"-"`
+}
+
+func (circuit *applicationCircuit) Define(api frontend.API) error {
+	outputs, err := circuit.verifier.DefineInto(api, &circuit.Verifier)
+	if err != nil {
+		return err
+	}
+	api.AssertIsEqual(circuit.VkeyHash, outputs[0])
+	api.AssertIsEqual(circuit.CommitedValuesDigest, outputs[1])
+	api.AssertIsEqual(circuit.Next, api.Add(outputs[1], 1))
+	return nil
+}
+
+func TestEmbedVerifierCircuit(t *testing.T) {
+	verifier, metadata, err := sp1.BuildVerifierCircuit("testdata/basic_constraints.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.NbInstructions != 14 || metadata.NbVars != 2 || metadata.NbFelts != 3 || metadata.NbExts != 1 {
+		t.Fatalf("unexpected metadata %+v", metadata)
+	}
+
+	// On its own, the verifier circuit is the one Build compiles.
+	witnessInput, err := sp1.ReadWitnessInput("testdata/basic_witness.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONSTRAINTS_JSON", "testdata/basic_constraints.json")
+	built := sp1.NewCircuit(witnessInput)
+	digests := make([]string, 2)
+	for i, c := range []frontend.Circuit{verifier, &built} {
+		compiled, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if digests[i], err = sp1.CircuitDigest(compiled); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if digests[0] != digests[1] {
+		t.Fatalf("verifier circuit digest %s differs from the built circuit %s", digests[0], digests[1])
+	}
+
+	circuit := applicationCircuit{Verifier: metadata.NewWitness(), verifier: verifier.(*sp1.Circuit)}
+	if _, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &circuit); err != nil {
+		t.Fatal(err)
+	}
+
+	for next, valid := range map[int]bool{457: true, 458: false} {
+		assignment := applicationCircuit{
+			Verifier:             sp1.NewWitnessAssignment(witnessInput),
+			VkeyHash:             witnessInput.VkeyHash,
+			CommitedValuesDigest: witnessInput.CommitedValuesDigest,
+			Next:                 next,
+		}
+		err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField())
+		if valid && err != nil {
+			t.Fatal(err)
+		}
+		if !valid && err == nil {
+			t.Fatalf("expected the application constraint to reject %d", next)
+		}
+	}
+}
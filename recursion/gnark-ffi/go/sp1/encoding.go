@@ -0,0 +1,146 @@
+package sp1
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/plonk"
+	plonk_bn254 "github.com/consensys/gnark/backend/plonk/bn254"
+)
+
+// EncodeProofHex returns the 0x-prefixed hex encoding of the raw serialization of a proof.
+func EncodeProofHex(proof plonk.Proof) (string, error) {
+	data, err := serializeRaw(proof)
+	if err != nil {
+		return "", err
+	}
+	return "0x" + hex.EncodeToString(data), nil
+}
+
+// EncodeProofBase64 returns the standard base64 encoding of the raw serialization of a proof.
+func EncodeProofBase64(proof plonk.Proof) (string, error) {
+	data, err := serializeRaw(proof)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// DecodeProofHex decodes a hex encoded PLONK BN254 proof, with or without the 0x prefix.
+func DecodeProofHex(encoded string) (plonk.Proof, error) {
+	data, err := decodeHex(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proof encoding: %w", err)
+	}
+	return deserializeProof(data)
+}
+
+// DecodeProofBase64 decodes a base64 encoded PLONK BN254 proof.
+func DecodeProofBase64(encoded string) (plonk.Proof, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proof encoding: %w", err)
+	}
+	return deserializeProof(data)
+}
+
+// EncodeVerifyingKeyHex returns the 0x-prefixed hex encoding of the raw serialization of a
+// verifying key.
+func EncodeVerifyingKeyHex(vk plonk.VerifyingKey) (string, error) {
+	data, err := serializeRaw(vk)
+	if err != nil {
+		return "", err
+	}
+	return "0x" + hex.EncodeToString(data), nil
+}
+
+// EncodeVerifyingKeyBase64 returns the standard base64 encoding of the raw serialization of a
+// verifying key.
+func EncodeVerifyingKeyBase64(vk plonk.VerifyingKey) (string, error) {
+	data, err := serializeRaw(vk)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// DecodeVerifyingKeyHex decodes a hex encoded PLONK BN254 verifying key, with or without the 0x
+// prefix.
+func DecodeVerifyingKeyHex(encoded string) (plonk.VerifyingKey, error) {
+	data, err := decodeHex(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid verifying key encoding: %w", err)
+	}
+	return deserializeVerifyingKey(data)
+}
+
+// DecodeVerifyingKeyBase64 decodes a base64 encoded PLONK BN254 verifying key.
+func DecodeVerifyingKeyBase64(encoded string) (plonk.VerifyingKey, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid verifying key encoding: %w", err)
+	}
+	return deserializeVerifyingKey(data)
+}
+
+type rawWriter interface {
+	WriteRawTo(w io.Writer) (int64, error)
+}
+
+func serializeRaw(v rawWriter) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := v.WriteRawTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeHex(encoded string) ([]byte, error) {
+	return hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(encoded), "0x"))
+}
+
+func deserializeProof(data []byte) (plonk.Proof, error) {
+	proof := plonk.NewProof(ecc.BN254)
+	if _, ok := proof.(*plonk_bn254.Proof); !ok {
+		return nil, fmt.Errorf("unexpected proof backend %T", proof)
+	}
+	if err := readExactly(proof, data); err != nil {
+		return nil, fmt.Errorf("invalid proof: %w", err)
+	}
+	return proof, nil
+}
+
+func deserializeVerifyingKey(data []byte) (plonk.VerifyingKey, error) {
+	vk := plonk.NewVerifyingKey(ecc.BN254)
+	if _, ok := vk.(*plonk_bn254.VerifyingKey); !ok {
+		return nil, fmt.Errorf("unexpected verifying key backend %T", vk)
+	}
+	if err := readExactly(vk, data); err != nil {
+		return nil, fmt.Errorf("invalid verifying key: %w", err)
+	}
+	return vk, nil
+}
+
+// readExactly deserializes data into v, failing if gnark reports an error, panics, or leaves
+// trailing bytes.
+func readExactly(v io.ReaderFrom, data []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed encoding: %v", r)
+		}
+	}()
+
+	n, err := v.ReadFrom(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to read %d bytes: %w", len(data), err)
+	}
+	if n != int64(len(data)) {
+		return fmt.Errorf("expected %d bytes, read %d", len(data), n)
+	}
+	return nil
+}
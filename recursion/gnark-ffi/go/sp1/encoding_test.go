@@ -0,0 +1,120 @@
+package sp1
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"strings"
+	"testing"
+)
+
+func readGolden(t *testing.T, path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestProofEncodingGolden(t *testing.T) {
+	goldenHex := readGolden(t, "testdata/basic_proof.hex")
+	goldenBase64 := readGolden(t, "testdata/basic_proof.b64")
+
+	proof, err := DecodeProofHex(goldenHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encodedHex, err := EncodeProofHex(proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encodedHex != goldenHex {
+		t.Fatal("hex encoding of the proof is not stable")
+	}
+
+	encodedBase64, err := EncodeProofBase64(proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encodedBase64 != goldenBase64 {
+		t.Fatal("base64 encoding of the proof is not stable")
+	}
+	if _, err := DecodeProofBase64(goldenBase64); err != nil {
+		t.Fatal(err)
+	}
+
+	// The prefix is optional when decoding.
+	if _, err := DecodeProofHex(strings.TrimPrefix(goldenHex, "0x")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyingKeyEncodingGolden(t *testing.T) {
+	goldenHex := readGolden(t, "testdata/basic_vk.hex")
+
+	vk, err := DecodeVerifyingKeyHex(goldenHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encodedHex, err := EncodeVerifyingKeyHex(vk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encodedHex != goldenHex {
+		t.Fatal("hex encoding of the verifying key is not stable")
+	}
+
+	encodedBase64, err := EncodeVerifyingKeyBase64(vk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeVerifyingKeyBase64(encodedBase64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reencoded, err := EncodeVerifyingKeyHex(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reencoded != goldenHex {
+		t.Fatal("base64 round trip of the verifying key is not stable")
+	}
+}
+
+func TestTamperedProofEncoding(t *testing.T) {
+	goldenHex := readGolden(t, "testdata/basic_proof.hex")
+	raw, err := decodeHex(goldenHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Flip bits in the first point so that it is no longer on the curve.
+	corrupted := append([]byte{}, raw...)
+	corrupted[10] ^= 0xff
+
+	cases := map[string]string{
+		"empty":          "",
+		"not hex":        "0xzz",
+		"odd length":     goldenHex[:len(goldenHex)-1],
+		"truncated":      goldenHex[:len(goldenHex)-10],
+		"trailing bytes": goldenHex + "00",
+		"invalid point":  "0x" + hex.EncodeToString(corrupted),
+	}
+	for name, encoded := range cases {
+		if _, err := DecodeProofHex(encoded); err == nil {
+			t.Errorf("%s: expected decoding to fail", name)
+		} else {
+			t.Logf("%s: %v", name, err)
+		}
+	}
+
+	if _, err := DecodeProofBase64("not base64!"); err == nil {
+		t.Error("expected invalid base64 to fail")
+	}
+	if _, err := DecodeProofBase64(base64.StdEncoding.EncodeToString(raw[:len(raw)-3])); err == nil {
+		t.Error("expected truncated base64 to fail")
+	}
+	if _, err := DecodeVerifyingKeyHex(goldenHex); err == nil {
+		t.Error("expected a proof to be rejected as a verifying key")
+	}
+}
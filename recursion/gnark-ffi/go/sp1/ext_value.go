@@ -0,0 +1,45 @@
+package sp1
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+)
+
+// ExtValue is a quartic extension element as serialized in witness files: four canonical BabyBear
+// coordinates encoded as decimal strings.
+type ExtValue [4]string
+
+func (e ExtValue) MarshalJSON() ([]byte, error) {
+	if err := e.validate(); err != nil {
+		return nil, err
+	}
+	return json.Marshal([4]string(e))
+}
+
+func (e *ExtValue) UnmarshalJSON(data []byte) error {
+	var coordinates []string
+	if err := json.Unmarshal(data, &coordinates); err != nil {
+		return fmt.Errorf("extension element must be an array of decimal strings: %w", err)
+	}
+	if len(coordinates) != 4 {
+		return fmt.Errorf("extension element must have 4 coordinates, got %d", len(coordinates))
+	}
+	value := ExtValue(coordinates)
+	if err := value.validate(); err != nil {
+		return err
+	}
+	*e = value
+	return nil
+}
+
+// ExtensionVariable converts the value into a circuit extension element.
+func (e ExtValue) ExtensionVariable() babybear.ExtensionVariable {
+	return babybear.NewE(e[:])
+}
+
+func (e ExtValue) validate() error {
+	_, err := babybear.NewEChecked(e[:])
+	return err
+}
@@ -0,0 +1,49 @@
+package sp1
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestExtValueRoundTrip(t *testing.T) {
+	// As serialized by GnarkWitness on the Rust side.
+	data := []byte(`{"vars":[],"felts":[],"exts":[["1","2","3","4"],["2013265920","0","0","999"]],"vkey_hash":"0","commited_values_digest":"0"}`)
+
+	var witnessInput WitnessInput
+	if err := json.Unmarshal(data, &witnessInput); err != nil {
+		t.Fatal(err)
+	}
+	if witnessInput.Exts[1] != (ExtValue{"2013265920", "0", "0", "999"}) {
+		t.Fatalf("unexpected extension value: %v", witnessInput.Exts[1])
+	}
+
+	encoded, err := json.Marshal(witnessInput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(encoded) != string(data) {
+		t.Fatalf("round trip mismatch:\n%s\n%s", encoded, data)
+	}
+}
+
+func TestExtValueMalformed(t *testing.T) {
+	for input, expected := range map[string]string{
+		`["1","2","3"]`:              "4 coordinates, got 3",
+		`["1","2","3","4","5"]`:      "4 coordinates, got 5",
+		`["1","2","-3","4"]`:         "coordinate 2",
+		`["1","2","3","abc"]`:        "coordinate 3",
+		`["2013265921","0","0","0"]`: "coordinate 0",
+		`[1,2,3,4]`:                  "array of decimal strings",
+	} {
+		var value ExtValue
+		err := json.Unmarshal([]byte(input), &value)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%s: expected error containing %q, got %v", input, expected, err)
+		}
+	}
+
+	if _, err := json.Marshal(ExtValue{"1", "2", "3", "-4"}); err == nil {
+		t.Error("expected marshalling a non-canonical value to fail")
+	}
+}
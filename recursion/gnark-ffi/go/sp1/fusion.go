@@ -0,0 +1,78 @@
+package sp1
+
+// FuseInstructions rewrites patterns of the instruction stream into fused pseudo-ops that the
+// circuit evaluates with fewer reductions. An intermediate result is only fused away when no other
+// instruction mentions it. The recognized patterns are:
+//
+//   - MulF t, a, b immediately followed by AddF s, t, c (or AddF s, c, t) becomes MulAddF s, a, b, c.
+//   - A run of consecutive AddE where each instruction adds the result of the previous one to
+//     another operand, such as AddE t, a, b followed by AddE s, t, c, becomes SumE s, a, b, c.
+//
+// It returns the rewritten stream and the number of fused instructions it emitted.
+func FuseInstructions(constraints []Constraint) ([]Constraint, int) {
+	occurrences := make(map[string]int)
+	for _, cs := range constraints {
+		for _, arg := range cs.Args {
+			for _, id := range arg {
+				occurrences[id]++
+			}
+		}
+	}
+
+	// Returns the other operand of a binary instruction reading t, if t is its only other mention.
+	otherOperand := func(cs Constraint, opcode string, t string) (string, bool) {
+		if cs.Opcode != opcode || occurrences[t] != 2 {
+			return "", false
+		}
+		if cs.Args[1][0] == t && cs.Args[2][0] != t {
+			return cs.Args[2][0], true
+		}
+		if cs.Args[2][0] == t && cs.Args[1][0] != t {
+			return cs.Args[1][0], true
+		}
+		return "", false
+	}
+
+	fused := make([]Constraint, 0, len(constraints))
+	nbFusions := 0
+	for i := 0; i < len(constraints); i++ {
+		cs := constraints[i]
+		switch cs.Opcode {
+		case "MulF":
+			if i+1 < len(constraints) {
+				if c, ok := otherOperand(constraints[i+1], "AddF", cs.Args[0][0]); ok {
+					fused = append(fused, Constraint{
+						Opcode: "MulAddF",
+						Args:   [][]string{constraints[i+1].Args[0], cs.Args[1], cs.Args[2], {c}},
+					})
+					nbFusions++
+					i++
+					continue
+				}
+			}
+		case "AddE":
+			out := cs.Args[0][0]
+			terms := [][]string{cs.Args[1], cs.Args[2]}
+			j := i + 1
+			for ; j < len(constraints); j++ {
+				term, ok := otherOperand(constraints[j], "AddE", out)
+				if !ok {
+					break
+				}
+				terms = append(terms, []string{term})
+				out = constraints[j].Args[0][0]
+			}
+			if len(terms) > 2 {
+				fused = append(fused, Constraint{
+					Opcode: "SumE",
+					Args:   append([][]string{{out}}, terms...),
+				})
+				nbFusions++
+				i = j - 1
+				continue
+			}
+		}
+		fused = append(fused, cs)
+	}
+	return fused, nbFusions
+}
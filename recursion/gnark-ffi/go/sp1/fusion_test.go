@@ -0,0 +1,83 @@
+package sp1
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func readTestConstraints(t *testing.T, path string) []Constraint {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var constraints []Constraint
+	if err := json.Unmarshal(data, &constraints); err != nil {
+		t.Fatal(err)
+	}
+	return constraints
+}
+
+func readTestWitness(t *testing.T, path string) WitnessInput {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var witnessInput WitnessInput
+	if err := json.Unmarshal(data, &witnessInput); err != nil {
+		t.Fatal(err)
+	}
+	return witnessInput
+}
+
+func TestFuseInstructions(t *testing.T) {
+	constraints := readTestConstraints(t, "testdata/fusion_constraints.json")
+	fused, nbFusions := FuseInstructions(constraints)
+
+	if nbFusions != 2 || len(fused) != len(constraints)-3 {
+		t.Fatalf("expected 2 fusions removing 3 instructions, got %d fusions and %d instructions", nbFusions, len(fused))
+	}
+	if fused[3].Opcode != "MulAddF" || fused[7].Opcode != "SumE" || len(fused[7].Args) != 5 {
+		t.Fatalf("unexpected fused instructions: %+v, %+v", fused[3], fused[7])
+	}
+	// f6 is read twice, so its MulF is kept.
+	if fused[10].Opcode != "MulF" || fused[11].Opcode != "AddF" {
+		t.Fatalf("expected shared intermediate results not to be fused: %+v", fused[10:12])
+	}
+
+	// Fusion preserves the value of every id that remains in the stream.
+	witnessInput := readTestWitness(t, "testdata/basic_witness.json")
+	reference, err := traceConstraints(constraints, witnessInput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := traceConstraints(fused, witnessInput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reference.Failure != nil || report.Failure != nil {
+		t.Fatalf("unexpected failures: %v, %v", reference.Failure, report.Failure)
+	}
+	for id, value := range report.Felts {
+		if reference.Felts[id] != value {
+			t.Fatalf("%s = %s after fusion, expected %s", id, value, reference.Felts[id])
+		}
+	}
+	for id, value := range report.Exts {
+		if reference.Exts[id] != value {
+			t.Fatalf("%s = %s after fusion, expected %s", id, value, reference.Exts[id])
+		}
+	}
+}
+
+func TestFusedCircuit(t *testing.T) {
+	t.Setenv("FUSE_INSTRUCTIONS", "true")
+	if err := RunTestEngine("testdata/fusion_constraints.json", "testdata/basic_witness.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	report := Build(newDevDataDir(t, "testdata/fusion_constraints.json", "testdata/basic_witness.json"))
+	if report.NbFusions != 2 || report.OpcodeCounts["SumE"] != 1 || report.OpcodeCounts["MulAddF"] != 1 {
+		t.Fatalf("unexpected fusion statistics: %d fusions, opcodes %v", report.NbFusions, report.OpcodeCounts)
+	}
+}
@@ -0,0 +1,83 @@
+package sp1
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+)
+
+// addFixtureSeeds seeds the corpus with the fixtures matching pattern and truncations of them,
+// which cut instructions and values in the middle.
+func addFixtureSeeds(f *testing.F, pattern string) {
+	paths, err := filepath.Glob(pattern)
+	if err != nil || len(paths) == 0 {
+		f.Fatalf("no fixtures match %s", pattern)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+		for _, n := range []int{1, 2, len(data) / 3, len(data) / 2, len(data) - 2, len(data) - 1} {
+			if n > 0 && n < len(data) {
+				f.Add(data[:n])
+			}
+		}
+	}
+}
+
+func FuzzParseConstraints(f *testing.F) {
+	addFixtureSeeds(f, "testdata/*_constraints.json")
+	f.Add([]byte(`[]`))
+	f.Add([]byte(`[{"opcode":"WitnessV","args":[["v0"],["-1"]]}]`))
+	f.Add([]byte(`[{"opcode":"Num2BitsV","args":[["v1","v2","v3"],["v0"],["2"]]}]`))
+	f.Add([]byte(`[{"opcode":"ImmE","args":[["e0"],["1","2"]]}]`))
+	f.Add([]byte(`[{"opcode":"AddF","args":[["f1"],[],["f0"]]}]`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		constraints, err := parseConstraints(bytes.NewReader(data), 2)
+		if err != nil {
+			return
+		}
+		for i, cs := range constraints {
+			if err := validateConstraint(cs); err != nil {
+				t.Fatalf("instruction %d: accepted an invalid instruction: %v", i, err)
+			}
+		}
+	})
+}
+
+func FuzzDecodeWitnessInput(f *testing.F) {
+	addFixtureSeeds(f, "testdata/*_witness.json")
+	f.Add([]byte(`{"exts":[null]}`))
+	f.Add([]byte(`{"felts":["2013265921"],"vkey_hash":"0","commited_values_digest":"0"}`))
+	var binaryWitness bytes.Buffer
+	if err := WriteWitnessBinary(&binaryWitness, WitnessInput{
+		Vars:                 []string{"123"},
+		Felts:                []string{"3", "2013265920"},
+		Exts:                 []ExtValue{{"1", "2", "3", "4"}},
+		VkeyHash:             "123",
+		CommitedValuesDigest: "456",
+	}); err != nil {
+		f.Fatal(err)
+	}
+	f.Add(binaryWitness.Bytes())
+	f.Add(binaryWitness.Bytes()[:binaryWitnessHeader])
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		witnessInput, err := decodeWitnessInput(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		// An accepted witness must be assignable.
+		assignment := NewCircuit(witnessInput)
+		if _, err := frontend.NewWitness(&assignment, ecc.BN254.ScalarField()); err != nil {
+			t.Fatalf("accepted a witness that cannot be assigned: %v", err)
+		}
+	})
+}
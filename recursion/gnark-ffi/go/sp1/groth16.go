@@ -0,0 +1,299 @@
+package sp1
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/backend/groth16"
+	groth16_bn254 "github.com/consensys/gnark/backend/groth16/bn254"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/logging"
+)
+
+// BuildGroth16 compiles the circuit of the constraints file in dataDir to an R1CS, runs the Groth16
+// setup and checks a proof of the witness in dataDir, like Build does for PLONK. The R1CS and the
+// keys are written next to the PLONK artifacts, as GROTH16_CIRCUIT_PATH, GROTH16_VK_PATH and
+// GROTH16_PK_PATH.
+//
+// The setup is generated locally, so the keys are only as trustworthy as the machine that built
+// them.
+func BuildGroth16(dataDir string, opts BuildOptions) (BuildReport, error) {
+	os.Setenv("CONSTRAINTS_JSON", resolveInput(dataDir+"/"+CONSTRAINTS_JSON_FILE))
+
+	logger := logging.OrDefault(opts.Logger)
+	metrics := NewMetrics(logger)
+	files := newStaging(dataDir)
+	defer files.discard()
+
+	// Read the file.
+	endReadWitness := metrics.Start("read_witness")
+	witnessInput, err := ReadWitnessInput(resolveInput(dataDir + "/" + WITNESS_JSON_FILE))
+	if err != nil {
+		return BuildReport{}, fmt.Errorf("read_witness: %w", err)
+	}
+	endReadWitness()
+
+	// Compile the circuit.
+	mode := TWO_PUBLIC_INPUTS_MODE
+	if os.Getenv("SINGLE_PUBLIC_INPUT") == "true" {
+		mode = SINGLE_PUBLIC_INPUT_MODE
+	}
+	circuit, stats, err := newModeCircuit(witnessInput, mode)
+	if err != nil {
+		return BuildReport{}, err
+	}
+	stats.metrics = metrics
+	stats.logger = logger
+	endCompile := metrics.Start("compile")
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, circuit)
+	if err != nil {
+		return BuildReport{}, fmt.Errorf("compile: %w", err)
+	}
+	endCompile()
+	report := NewBuildReport(ccs, stats)
+	report.CircuitDigest, err = CircuitDigest(ccs)
+	if err != nil {
+		return BuildReport{}, err
+	}
+
+	// Generate the proving and verifying key.
+	endSetup := metrics.Start("setup")
+	pk, vk, err := groth16.Setup(ccs)
+	if err != nil {
+		return BuildReport{}, fmt.Errorf("setup: %w", err)
+	}
+	endSetup()
+
+	// Prove and verify the witness, so that a build whose keys cannot prove it fails.
+	endProve := metrics.Start("prove")
+	_, fullWitness, err := newFullWitness(ccs, witnessInput)
+	if err != nil {
+		return BuildReport{}, fmt.Errorf("witness: %w", err)
+	}
+	proof, err := groth16.Prove(ccs, pk, fullWitness, groth16ProverOptions(logger)...)
+	if err != nil {
+		return BuildReport{}, fmt.Errorf("prove: %w", err)
+	}
+	endProve()
+	endVerify := metrics.Start("verify")
+	publicWitness, err := fullWitness.Public()
+	if err != nil {
+		return BuildReport{}, fmt.Errorf("verify: %w", err)
+	}
+	if err := groth16.Verify(proof, vk, publicWitness, groth16VerifierOptions()...); err != nil {
+		return BuildReport{}, fmt.Errorf("verify: %w", err)
+	}
+	endVerify()
+
+	// Stage the R1CS, the verifier key and the proving key.
+	endSerialize := metrics.Start("serialize")
+	for name, artifact := range map[string]io.WriterTo{GROTH16_CIRCUIT_PATH: ccs, GROTH16_VK_PATH: vk, GROTH16_PK_PATH: pk} {
+		err := files.write(name, func(w io.Writer) error {
+			_, err := artifact.WriteTo(w)
+			return err
+		})
+		if err != nil {
+			return BuildReport{}, fmt.Errorf("serialize: %w", err)
+		}
+	}
+	for _, name := range []string{GROTH16_CIRCUIT_PATH, GROTH16_VK_PATH, GROTH16_PK_PATH} {
+		if err := report.addDigest(name, files.temps[name]); err != nil {
+			return BuildReport{}, err
+		}
+	}
+	endSerialize()
+	report.Phases = metrics.Phases
+	metrics.Log("built groth16 circuit")
+
+	return report, files.commit()
+}
+
+// ProveGroth16 proves the witness at witnessPath with the Groth16 artifacts built in dataDir by
+// BuildGroth16, and checks the proof before returning it.
+func ProveGroth16(dataDir string, witnessPath string, opts ProveOptions) (Proof, error) {
+	if dataDir == "" {
+		return Proof{}, fmt.Errorf("dataDirStr is required")
+	}
+	os.Setenv("CONSTRAINTS_JSON", resolveInput(dataDir+"/"+CONSTRAINTS_JSON_FILE))
+	logger := logging.OrDefault(opts.Logger)
+	metrics := NewMetrics(logger)
+
+	// Read the R1CS, the proving key and the verifier key.
+	endLoad := metrics.Start("load")
+	ccs, pk, vk, err := readGroth16Artifacts(dataDir)
+	if err != nil {
+		return Proof{}, fmt.Errorf("load: %w", err)
+	}
+	endLoad()
+
+	// Generate the witness.
+	endWitness := metrics.Start("witness")
+	witnessInput, err := ReadWitnessInput(witnessPath)
+	if err != nil {
+		return Proof{}, fmt.Errorf("read_witness: %w", err)
+	}
+	assignment, fullWitness, err := newFullWitness(ccs, witnessInput)
+	if err != nil {
+		return Proof{}, fmt.Errorf("witness: %w", err)
+	}
+	publicWitness, err := fullWitness.Public()
+	if err != nil {
+		return Proof{}, fmt.Errorf("witness: %w", err)
+	}
+	endWitness()
+
+	// Generate the proof.
+	endProve := metrics.Start("prove")
+	proof, err := groth16.Prove(ccs, pk, fullWitness, groth16ProverOptions(logger)...)
+	if err != nil {
+		return Proof{}, fmt.Errorf("prove: %w", err)
+	}
+	endProve()
+
+	// Verify proof.
+	endVerify := metrics.Start("verify")
+	if err := groth16.Verify(proof, vk, publicWitness, groth16VerifierOptions()...); err != nil {
+		return Proof{}, fmt.Errorf("verify: %w", err)
+	}
+	endVerify()
+
+	sp1Groth16Bn254Proof, err := NewSP1Groth16Bn254Proof(proof, witnessInput)
+	if err != nil {
+		return Proof{}, fmt.Errorf("serialize: %w", err)
+	}
+	if hashed, ok := assignment.(*HashedCircuit); ok {
+		sp1Groth16Bn254Proof.PublicInputHash = fmt.Sprint(hashed.PublicInputHash)
+	}
+	sp1Groth16Bn254Proof.Phases = metrics.Phases
+	metrics.Log("proved")
+
+	return sp1Groth16Bn254Proof, nil
+}
+
+// VerifyGroth16 checks a proof returned by ProveGroth16, given as the hex encoding of its raw
+// bytes, against the verifier key built in dataDir and the public inputs.
+func VerifyGroth16(dataDir string, proof string, vkeyHash string, commitedValuesDigest string, opts VerifyOptions) error {
+	logger := logging.OrDefault(opts.Logger)
+	if err := validateCommittedValuesDigest(commitedValuesDigest); err != nil {
+		return err
+	}
+
+	proofBytes, err := decodeHex(proof)
+	if err != nil {
+		return err
+	}
+	groth16Proof := groth16.NewProof(ecc.BN254)
+	if _, err := groth16Proof.ReadFrom(bytes.NewReader(proofBytes)); err != nil {
+		return fmt.Errorf("invalid proof: %w", err)
+	}
+
+	vk := groth16.NewVerifyingKey(ecc.BN254)
+	if err := readArtifact(dataDir+"/"+GROTH16_VK_PATH, vk); err != nil {
+		return err
+	}
+
+	// Compute the public witness, hashing the public values if the circuit was built with a single
+	// public input.
+	mode := publicInputsMode(groth16NbPublicInputs(vk))
+	circuit, _, err := newModeCircuit(WitnessInput{
+		VkeyHash:             vkeyHash,
+		CommitedValuesDigest: commitedValuesDigest,
+	}, mode)
+	if err != nil {
+		return err
+	}
+	witness, err := frontend.NewWitness(circuit, ecc.BN254.ScalarField(), frontend.PublicOnly())
+	if err != nil {
+		return err
+	}
+
+	if err := groth16.Verify(groth16Proof, vk, witness, groth16VerifierOptions()...); err != nil {
+		logger.Warn("invalid proof", "vkey_hash", vkeyHash, "error", err)
+		return err
+	}
+	logger.Info("verified proof", "vkey_hash", vkeyHash, "mode", mode)
+	return nil
+}
+
+// groth16ProverOptions returns the options of the Groth16 prover. The challenge of the commitment
+// of the range checks is derived with SHA-256, the only hash the Solidity verifier supports.
+func groth16ProverOptions(logger logging.Logger) []backend.ProverOption {
+	return []backend.ProverOption{
+		backend.WithSolverOptions(solver.WithLogger(logging.Zerolog(logger)), solverParallelism()),
+		backend.WithProverHashToFieldFunction(sha256.New()),
+	}
+}
+
+// groth16VerifierOptions returns the options of the Groth16 verifier matching groth16ProverOptions.
+func groth16VerifierOptions() []backend.VerifierOption {
+	return []backend.VerifierOption{backend.WithVerifierHashToFieldFunction(sha256.New())}
+}
+
+// NewSP1Groth16Bn254Proof returns the Proof of a Groth16 proof. Its raw proof is the proof
+// serialized with its points compressed, and its encoded proof the ABI encoding of the arguments
+// of the Solidity verifier: the 8 coordinates of the proof, followed by those of the commitments
+// and of their proof of knowledge.
+func NewSP1Groth16Bn254Proof(proof groth16.Proof, witnessInput WitnessInput) (Proof, error) {
+	var buf bytes.Buffer
+	if _, err := proof.WriteTo(&buf); err != nil {
+		return Proof{}, err
+	}
+	// MarshalSolidity writes the number of commitments after the coordinates of the proof.
+	encoded := proof.(*groth16_bn254.Proof).MarshalSolidity()
+	if len(encoded) > 8*fr.Bytes {
+		encoded = append(encoded[:8*fr.Bytes:8*fr.Bytes], encoded[8*fr.Bytes+4:]...)
+	}
+	return Proof{
+		PublicInputs: [2]string{witnessInput.VkeyHash, witnessInput.CommitedValuesDigest},
+		EncodedProof: hex.EncodeToString(encoded),
+		RawProof:     hex.EncodeToString(buf.Bytes()),
+	}, nil
+}
+
+// groth16NbPublicInputs returns the number of public inputs of the circuit of vk, which counts the
+// commitments among its public witness.
+func groth16NbPublicInputs(vk groth16.VerifyingKey) int {
+	return vk.NbPublicWitness() - len(vk.(*groth16_bn254.VerifyingKey).PublicAndCommitmentCommitted)
+}
+
+// readGroth16Artifacts reads the R1CS, the proving key and the verifier key built in dataDir by
+// BuildGroth16.
+func readGroth16Artifacts(dataDir string) (constraint.ConstraintSystem, groth16.ProvingKey, groth16.VerifyingKey, error) {
+	ccs := groth16.NewCS(ecc.BN254)
+	if err := readArtifact(dataDir+"/"+GROTH16_CIRCUIT_PATH, ccs); err != nil {
+		return nil, nil, nil, err
+	}
+	pk := groth16.NewProvingKey(ecc.BN254)
+	if err := readArtifact(dataDir+"/"+GROTH16_PK_PATH, pk); err != nil {
+		return nil, nil, nil, err
+	}
+	vk := groth16.NewVerifyingKey(ecc.BN254)
+	if err := readArtifact(dataDir+"/"+GROTH16_VK_PATH, vk); err != nil {
+		return nil, nil, nil, err
+	}
+	return ccs, pk, vk, nil
+}
+
+// readArtifact reads the artifact at path into v.
+func readArtifact(path string, v io.ReaderFrom) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := v.ReadFrom(bufio.NewReaderSize(file, 1024*1024)); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package sp1
+
+import (
+	"context"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGroth16Pipeline(t *testing.T) {
+	dataDir := newDevDataDir(t, "testdata/basic_constraints.json", "testdata/basic_witness.json")
+	report, err := BuildGroth16(dataDir, BuildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.NbPublicInputs != 2 || report.NbConstraints == 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	for _, name := range []string{GROTH16_CIRCUIT_PATH, GROTH16_VK_PATH, GROTH16_PK_PATH} {
+		if _, err := os.Stat(filepath.Join(dataDir, name)); err != nil {
+			t.Fatal(err)
+		}
+		if report.ArtifactDigests[name] == "" {
+			t.Fatalf("no digest of %s", name)
+		}
+	}
+
+	proof, err := ProveGroth16(dataDir, filepath.Join(dataDir, WITNESS_JSON_FILE), ProveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The Solidity verifier takes the 8 coordinates of the points of the proof, followed by the 2 of
+	// the commitment of the range checks and the 2 of its proof of knowledge.
+	if encoded, err := hex.DecodeString(proof.EncodedProof); err != nil || len(encoded) != 12*32 {
+		t.Fatalf("unexpected encoded proof %q", proof.EncodedProof)
+	}
+	vkeyHash, digest := proof.PublicInputs[0], proof.PublicInputs[1]
+	if err := VerifyGroth16(dataDir, proof.RawProof, vkeyHash, digest, VerifyOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyGroth16(dataDir, proof.RawProof, "1", digest, VerifyOptions{}); err == nil {
+		t.Fatal("expected the proof to be rejected for another vkey hash")
+	}
+}
+
+func TestProveGroth16WithoutBuild(t *testing.T) {
+	dataDir := newDevDataDir(t, "testdata/basic_constraints.json", "testdata/basic_witness.json")
+	if _, err := ProveGroth16(dataDir, filepath.Join(dataDir, WITNESS_JSON_FILE), ProveOptions{}); err == nil {
+		t.Fatal("expected proving without the groth16 artifacts to fail")
+	}
+}
+
+// Both systems are built, proven and verified through the same API in one data directory, without
+// overwriting the artifacts of each other.
+func TestProvingSystems(t *testing.T) {
+	dataDir := newDevDataDir(t, "testdata/basic_constraints.json", "testdata/basic_witness.json")
+	systems := []ProvingSystem{PLONK_SYSTEM, GROTH16_SYSTEM}
+	for _, system := range systems {
+		if _, err := BuildContext(context.Background(), dataDir, BuildOptions{System: system}); err != nil {
+			t.Fatalf("%s: %v", system, err)
+		}
+	}
+	for _, name := range []string{CIRCUIT_PATH, VK_PATH, PK_PATH, GROTH16_CIRCUIT_PATH, GROTH16_VK_PATH, GROTH16_PK_PATH} {
+		if _, err := os.Stat(filepath.Join(dataDir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	proofs := make(map[ProvingSystem]Proof)
+	for _, system := range systems {
+		proof, err := ProveContext(context.Background(), dataDir, filepath.Join(dataDir, WITNESS_JSON_FILE), ProveOptions{System: system})
+		if err != nil {
+			t.Fatalf("%s: %v", system, err)
+		}
+		vkeyHash, digest := proof.PublicInputs[0], proof.PublicInputs[1]
+		if err := VerifyContext(context.Background(), dataDir, proof.RawProof, vkeyHash, digest, VerifyOptions{System: system}); err != nil {
+			t.Fatalf("%s: %v", system, err)
+		}
+		proofs[system] = proof
+	}
+
+	// A proof of one system is not one of the other.
+	plonkProof, groth16Proof := proofs[PLONK_SYSTEM], proofs[GROTH16_SYSTEM]
+	if err := VerifyContext(context.Background(), dataDir, groth16Proof.RawProof, groth16Proof.PublicInputs[0], groth16Proof.PublicInputs[1], VerifyOptions{System: PLONK_SYSTEM}); err == nil {
+		t.Error("expected the Groth16 proof to be rejected by the PLONK verifier")
+	}
+	if err := VerifyContext(context.Background(), dataDir, plonkProof.RawProof, plonkProof.PublicInputs[0], plonkProof.PublicInputs[1], VerifyOptions{System: GROTH16_SYSTEM}); err == nil {
+		t.Error("expected the PLONK proof to be rejected by the Groth16 verifier")
+	}
+
+	if _, err := BuildContext(context.Background(), dataDir, BuildOptions{System: "stark"}); err == nil {
+		t.Error("expected an unknown proving system to be rejected")
+	}
+}
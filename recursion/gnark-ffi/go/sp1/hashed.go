@@ -0,0 +1,96 @@
+package sp1
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark/frontend"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/poseidon2"
+)
+
+var TWO_PUBLIC_INPUTS_MODE string = "two_public_inputs"
+var SINGLE_PUBLIC_INPUT_MODE string = "single_public_input"
+
+// HashedCircuit synthesizes the same constraints as Circuit, but keeps the vkey hash and the
+// committed values digest secret and exposes their hash, computed by ComputePublicInputHash, as
+// its only public input. Build selects it when SINGLE_PUBLIC_INPUT is set.
+type HashedCircuit struct {
+	PublicInputHash      frontend.Variable `gnark:",public"`
+	VkeyHash             frontend.Variable
+	CommitedValuesDigest frontend.Variable
+	Vars                 []frontend.Variable
+	Felts                []babybear.Variable
+	Exts                 []babybear.ExtensionVariable
+
+	// The circuit synthesized over the variables above, which also collects the statistics of the
+	// build.
+	circuit Circuit `gnark:"-"`
+}
+
+func NewHashedCircuit(witnessInput WitnessInput) (HashedCircuit, error) {
+	vkeyHash, err := parseBN254(witnessInput.VkeyHash)
+	if err != nil {
+		return HashedCircuit{}, fmt.Errorf("invalid vkey hash: %w", err)
+	}
+	committedValuesDigest, err := parseBN254(witnessInput.CommitedValuesDigest)
+	if err != nil {
+		return HashedCircuit{}, fmt.Errorf("invalid committed values digest: %w", err)
+	}
+
+	circuit := NewCircuit(witnessInput)
+	return HashedCircuit{
+		PublicInputHash:      ComputePublicInputHash(vkeyHash, committedValuesDigest),
+		VkeyHash:             circuit.VkeyHash,
+		CommitedValuesDigest: circuit.CommitedValuesDigest,
+		Vars:                 circuit.Vars,
+		Felts:                circuit.Felts,
+		Exts:                 circuit.Exts,
+	}, nil
+}
+
+func (circuit *HashedCircuit) Define(api frontend.API) error {
+	circuit.circuit.VkeyHash = circuit.VkeyHash
+	circuit.circuit.CommitedValuesDigest = circuit.CommitedValuesDigest
+	circuit.circuit.Vars = circuit.Vars
+	circuit.circuit.Felts = circuit.Felts
+	circuit.circuit.Exts = circuit.Exts
+	if err := circuit.circuit.Define(api); err != nil {
+		return err
+	}
+
+	state := [poseidon2.WIDTH]frontend.Variable{circuit.VkeyHash, circuit.CommitedValuesDigest, 0}
+	poseidon2.NewChip(api).PermuteMut(&state)
+	api.AssertIsEqual(circuit.PublicInputHash, state[0])
+	return nil
+}
+
+// ComputePublicInputHash returns the public input of a HashedCircuit: the first element of the
+// Poseidon2 BN254 permutation of the state [vkeyHash, committedValuesDigest, 0].
+func ComputePublicInputHash(vkeyHash *big.Int, committedValuesDigest *big.Int) *big.Int {
+	state := [poseidon2.WIDTH]*big.Int{new(big.Int).Set(vkeyHash), new(big.Int).Set(committedValuesDigest), new(big.Int)}
+	poseidon2.PermuteNative(&state)
+	return state[0]
+}
+
+// publicInputsMode returns the mode of a circuit with nbPublicVariables public inputs.
+func publicInputsMode(nbPublicVariables int) string {
+	if nbPublicVariables == 1 {
+		return SINGLE_PUBLIC_INPUT_MODE
+	}
+	return TWO_PUBLIC_INPUTS_MODE
+}
+
+// newModeCircuit returns the circuit of the given public inputs mode, assigned from witnessInput,
+// along with the Circuit that collects its statistics.
+func newModeCircuit(witnessInput WitnessInput, mode string) (frontend.Circuit, *Circuit, error) {
+	if mode == SINGLE_PUBLIC_INPUT_MODE {
+		hashed, err := NewHashedCircuit(witnessInput)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &hashed, &hashed.circuit, nil
+	}
+	circuit := NewCircuit(witnessInput)
+	return &circuit, &circuit, nil
+}
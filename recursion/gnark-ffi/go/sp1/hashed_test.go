@@ -0,0 +1,74 @@
+package sp1
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// buildProveVerify builds the basic fixtures, proves them and checks the proof verifies against
+// the fixture public values only.
+func buildProveVerify(t *testing.T) (BuildReport, Proof) {
+	dataDir := newDevDataDir(t, "testdata/basic_constraints.json", "testdata/basic_witness.json")
+	report := Build(dataDir)
+	proof, err := Prove(dataDir, filepath.Join(dataDir, WITNESS_JSON_FILE))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Verify(dataDir, proof.RawProof, "123", "456"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(dataDir, proof.RawProof, "123", "457"); err == nil {
+		t.Fatal("expected the proof not to verify against another committed values digest")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dataDir, REPORT_PATH))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved BuildReport
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.PublicInputsMode != report.PublicInputsMode {
+		t.Fatalf("saved mode %q does not match %q", saved.PublicInputsMode, report.PublicInputsMode)
+	}
+	return report, proof
+}
+
+func TestTwoPublicInputsMode(t *testing.T) {
+	report, proof := buildProveVerify(t)
+	if report.NbPublicInputs != 2 || report.PublicInputsMode != TWO_PUBLIC_INPUTS_MODE {
+		t.Fatalf("unexpected public inputs: %d (%s)", report.NbPublicInputs, report.PublicInputsMode)
+	}
+	if proof.PublicInputHash != "" {
+		t.Fatalf("unexpected public input hash %s", proof.PublicInputHash)
+	}
+}
+
+func TestSinglePublicInputMode(t *testing.T) {
+	t.Setenv("SINGLE_PUBLIC_INPUT", "true")
+	report, proof := buildProveVerify(t)
+	if report.NbPublicInputs != 1 || report.PublicInputsMode != SINGLE_PUBLIC_INPUT_MODE {
+		t.Fatalf("unexpected public inputs: %d (%s)", report.NbPublicInputs, report.PublicInputsMode)
+	}
+
+	expected := ComputePublicInputHash(big.NewInt(123), big.NewInt(456))
+	if proof.PublicInputHash != expected.String() {
+		t.Fatalf("expected public input hash %s, got %s", expected, proof.PublicInputHash)
+	}
+	if proof.PublicInputs != [2]string{"123", "456"} {
+		t.Fatalf("expected the preimage in the public inputs, got %v", proof.PublicInputs)
+	}
+
+	path := filepath.Join(t.TempDir(), "public_values.json")
+	if err := proof.ExportPublicValues(path, "v1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPublicValues(path); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,261 @@
+package sp1
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+)
+
+// MMAP_INPUTS enables memory-mapping uncompressed input files instead of reading them through a
+// buffer, when the MMAP_INPUTS environment variable is true. Mapping failures, and platforms without
+// mmap, fall back to buffered reads. It is off by default because the mapped pages count towards
+// the peak RSS measured by BenchmarkReadWitnessInputPeakRSS, which is higher than with the buffer.
+var MMAP_INPUTS = os.Getenv("MMAP_INPUTS") == "true"
+
+// openInput opens a constraints or witness file for streaming. Files starting with the gzip magic
+// bytes or named *.gz are decompressed on the fly.
+func openInput(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var reader io.Reader
+	var closer io.Closer = file
+	var magic []byte
+	mapped, err := mapInput(file)
+	if err == nil {
+		reader, closer = mapped, mapped
+		magic = mapped.data[:min(2, len(mapped.data))]
+	} else {
+		buffered := bufio.NewReader(file)
+		reader = buffered
+		magic, _ = buffered.Peek(2)
+	}
+	if !strings.HasSuffix(path, ".gz") && !(len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b) {
+		if mapped != nil {
+			return mapped, nil
+		}
+		if info, err := file.Stat(); err == nil && info.Mode().IsRegular() {
+			return &bufferedFile{Reader: reader.(*bufio.Reader), file: file, size: info.Size()}, nil
+		}
+		return readCloser{Reader: reader, Closer: closer}, nil
+	}
+
+	decompressed, err := gzip.NewReader(reader)
+	if err != nil {
+		closer.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return readCloser{Reader: decompressed, Closer: closer}, nil
+}
+
+// resolveInput returns path, or its compressed counterpart path.gz if only that one exists.
+func resolveInput(path string) string {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if _, err := os.Stat(path + ".gz"); err == nil {
+			return path + ".gz"
+		}
+	}
+	return path
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// bufferedFile is an uncompressed input file that could not be mapped. It is streamed through a
+// buffer, but binary witnesses read it at offsets instead, like a mapped file.
+type bufferedFile struct {
+	*bufio.Reader
+	file *os.File
+	size int64
+}
+
+func (f *bufferedFile) ReadAt(p []byte, offset int64) (int, error) {
+	return f.file.ReadAt(p, offset)
+}
+
+func (f *bufferedFile) Size() int64 {
+	return f.size
+}
+
+func (f *bufferedFile) Close() error {
+	return f.file.Close()
+}
+
+// inputFile is an uncompressed input file, mapped or not, whose bytes can be read at offsets.
+type inputFile interface {
+	io.ReaderAt
+	Size() int64
+}
+
+// ReadWitnessInput reads a witness file in either the JSON or the binary witness format, which
+// may be gzip compressed.
+func ReadWitnessInput(path string) (WitnessInput, error) {
+	input, err := openInput(path)
+	if err != nil {
+		return WitnessInput{}, err
+	}
+	defer input.Close()
+
+	witnessInput, err := decodeWitnessInput(input)
+	if err != nil {
+		return witnessInput, fmt.Errorf("%s: %w", path, err)
+	}
+	return witnessInput, nil
+}
+
+// DecodeWitnessInput is ReadWitnessInput from input.
+func DecodeWitnessInput(input io.Reader) (WitnessInput, error) {
+	return decodeWitnessInput(input)
+}
+
+// decodeWitnessInput decodes and validates a witness in either the JSON or the binary witness
+// format, so that a malformed file is rejected here rather than when the circuit is solved.
+func decodeWitnessInput(input io.Reader) (WitnessInput, error) {
+	var witnessInput WitnessInput
+	var err error
+	// A binary witness in a file is decoded from the file, in place when it is mapped, rather
+	// than from a copy of it.
+	if file, ok := input.(inputFile); ok {
+		magic := make([]byte, len(binaryWitnessMagic))
+		if read, _ := file.ReadAt(magic, 0); read == len(magic) && isBinaryWitness(magic) {
+			return decodeWitnessBinary(file, file.Size())
+		}
+	}
+	// A mapped file is decoded in place, the decoder would otherwise buffer the whole witness.
+	if mapped, ok := input.(*mappedFile); ok {
+		// The extension elements are decoded one by one so that an invalid one is reported with
+		// its index.
+		var decoded struct {
+			WitnessInput
+			Exts []json.RawMessage `json:"exts"`
+		}
+		err = json.Unmarshal(mapped.data, &decoded)
+		witnessInput = decoded.WitnessInput
+		if err != nil {
+			return witnessInput, err
+		}
+		if decoded.Exts != nil {
+			witnessInput.Exts = make([]ExtValue, len(decoded.Exts))
+		}
+		for i, e := range decoded.Exts {
+			if err := json.Unmarshal(e, &witnessInput.Exts[i]); err != nil {
+				return witnessInput, fmt.Errorf("ext %d: %w", i, err)
+			}
+		}
+	} else {
+		buffered := bufio.NewReader(input)
+		if magic, _ := buffered.Peek(len(binaryWitnessMagic)); isBinaryWitness(magic) {
+			data, err := io.ReadAll(buffered)
+			if err != nil {
+				return WitnessInput{}, err
+			}
+			return decodeWitnessBinary(bytes.NewReader(data), int64(len(data)))
+		}
+		witnessInput, err = decodeWitnessStream(buffered)
+		if err != nil {
+			return witnessInput, err
+		}
+	}
+
+	for i, v := range witnessInput.Vars {
+		if _, err := parseBN254(v); err != nil {
+			return witnessInput, fmt.Errorf("var %d: %w", i, err)
+		}
+	}
+	for i, f := range witnessInput.Felts {
+		if _, err := babybear.NewFChecked(f); err != nil {
+			return witnessInput, fmt.Errorf("felt %d: %w", i, err)
+		}
+	}
+	if _, err := parseBN254(witnessInput.VkeyHash); err != nil {
+		return witnessInput, fmt.Errorf("invalid vkey hash: %w", err)
+	}
+	return witnessInput, validateCommittedValuesDigest(witnessInput.CommitedValuesDigest)
+}
+
+// decodeWitnessStream decodes a JSON witness value by value, so that the witness is held in memory
+// once, as its decoded values: beyond them, decoding only holds the buffer of r and the decoder
+// buffer, which grows to the largest value and the whitespace before it, a few kilobytes for the
+// witnesses sp1 writes however large they are. Decoding the document as a whole would also hold
+// all of it, and a copy of its extension elements. Like json.Unmarshal, it matches the keys of the
+// object case-insensitively and ignores unknown ones.
+func decodeWitnessStream(r io.Reader) (WitnessInput, error) {
+	var witnessInput WitnessInput
+	decoder := json.NewDecoder(r)
+	if _, err := expectDelim(decoder, '{'); err != nil {
+		return witnessInput, err
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return witnessInput, err
+		}
+		key, _ := token.(string)
+		switch {
+		case strings.EqualFold(key, "vars"):
+			err = decodeStream(decoder, &witnessInput.Vars, "var")
+		case strings.EqualFold(key, "felts"):
+			err = decodeStream(decoder, &witnessInput.Felts, "felt")
+		case strings.EqualFold(key, "exts"):
+			err = decodeStream(decoder, &witnessInput.Exts, "ext")
+		case strings.EqualFold(key, "vkey_hash"):
+			err = decoder.Decode(&witnessInput.VkeyHash)
+		case strings.EqualFold(key, "commited_values_digest"):
+			err = decoder.Decode(&witnessInput.CommitedValuesDigest)
+		default:
+			var skipped json.RawMessage
+			err = decoder.Decode(&skipped)
+		}
+		if err != nil {
+			return witnessInput, err
+		}
+	}
+	_, err := expectDelim(decoder, '}')
+	return witnessInput, err
+}
+
+// decodeStream decodes a JSON array, or null, into values one element at a time. An element that
+// fails to decode is reported with its index, prefixed with name.
+func decodeStream[T any](decoder *json.Decoder, values *[]T, name string) error {
+	isNull, err := expectDelim(decoder, '[')
+	if err != nil || isNull {
+		return err
+	}
+	*values = []T{}
+	for i := 0; decoder.More(); i++ {
+		var value T
+		if err := decoder.Decode(&value); err != nil {
+			return fmt.Errorf("%s %d: %w", name, i, err)
+		}
+		*values = append(*values, value)
+	}
+	_, err = expectDelim(decoder, ']')
+	return err
+}
+
+// expectDelim reads the delimiter delim, or a null when delim opens an array, which isNull
+// reports.
+func expectDelim(decoder *json.Decoder, delim json.Delim) (isNull bool, err error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return false, err
+	}
+	if token == nil && delim == '[' {
+		return true, nil
+	}
+	if token != delim {
+		return false, fmt.Errorf("expected %v, got %v", delim, token)
+	}
+	return false, nil
+}
@@ -0,0 +1,344 @@
+package sp1
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// writeCompressed writes a gzip-compressed copy of src to dst.
+func writeCompressed(t *testing.T, src string, dst string) {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Create(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	writer := gzip.NewWriter(file)
+	if _, err := writer.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompressedInputs(t *testing.T) {
+	dir := t.TempDir()
+
+	// The gzip magic bytes are detected regardless of the file name.
+	constraintsPath := filepath.Join(dir, "constraints.json")
+	witnessPath := filepath.Join(dir, "witness.json.gz")
+	writeCompressed(t, "testdata/basic_constraints.json", constraintsPath)
+	writeCompressed(t, "testdata/basic_witness.json", witnessPath)
+
+	if err := RunTestEngine(constraintsPath, witnessPath); err != nil {
+		t.Fatal(err)
+	}
+	report, err := Trace(constraintsPath, witnessPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Failure != nil || report.NbInstructions != 14 {
+		t.Fatalf("unexpected trace of the compressed fixtures: %+v", report)
+	}
+
+	if resolved := resolveInput(filepath.Join(dir, "witness.json")); resolved != witnessPath {
+		t.Fatalf("expected the compressed witness to be picked up, got %s", resolved)
+	}
+	if resolved := resolveInput(constraintsPath); resolved != constraintsPath {
+		t.Fatalf("expected the uncompressed path to be kept, got %s", resolved)
+	}
+}
+
+func TestTruncatedCompressedInput(t *testing.T) {
+	dir := t.TempDir()
+	compressedPath := filepath.Join(dir, "full.json.gz")
+	writeCompressed(t, "testdata/basic_witness.json", compressedPath)
+	data, err := os.ReadFile(compressedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	witnessPath := filepath.Join(dir, "witness.json.gz")
+	if err := os.WriteFile(witnessPath, data[:len(data)/2], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ReadWitnessInput(witnessPath)
+	if err == nil || !strings.Contains(err.Error(), witnessPath) {
+		t.Fatalf("expected an error naming %s, got %v", witnessPath, err)
+	}
+}
+
+// setMmapInputs sets MMAP_INPUTS until the end of the test.
+func setMmapInputs(tb testing.TB, enabled bool) {
+	previous := MMAP_INPUTS
+	MMAP_INPUTS = enabled
+	tb.Cleanup(func() { MMAP_INPUTS = previous })
+}
+
+func TestMappedInput(t *testing.T) {
+	setMmapInputs(t, true)
+	input, err := openInput("testdata/basic_witness.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapped, ok := input.(*mappedFile)
+	if !ok {
+		t.Skip("mmap is not supported on this platform")
+	}
+	if err := input.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := input.Close(); err != nil || mapped.data != nil {
+		t.Fatalf("expected closing twice to be a no-op, got %v", err)
+	}
+
+	expected, err := ReadWitnessInput("testdata/basic_witness.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	setMmapInputs(t, false)
+	input, err = openInput("testdata/basic_witness.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer input.Close()
+	if _, ok := input.(*mappedFile); ok {
+		t.Fatal("expected a buffered reader when mmap is disabled")
+	}
+	witnessInput, err := ReadWitnessInput("testdata/basic_witness.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(witnessInput, expected) {
+		t.Fatalf("buffered and mapped reads differ: %+v != %+v", witnessInput, expected)
+	}
+}
+
+func TestMappedInputFallback(t *testing.T) {
+	setMmapInputs(t, true)
+	// Empty files cannot be mapped and are read through the buffered path.
+	emptyPath := filepath.Join(t.TempDir(), "witness.json")
+	if err := os.WriteFile(emptyPath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	input, err := openInput(emptyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer input.Close()
+	if _, ok := input.(*mappedFile); ok {
+		t.Fatal("expected an empty file to fall back to a buffered reader")
+	}
+	if _, err := ReadWitnessInput(emptyPath); err == nil || !strings.Contains(err.Error(), emptyPath) {
+		t.Fatalf("expected an error naming %s, got %v", emptyPath, err)
+	}
+}
+
+func BenchmarkReadWitnessInput(b *testing.B) {
+	witnessInput := WitnessInput{VkeyHash: "0", CommitedValuesDigest: "0"}
+	for i := 0; i < 1_000_000; i++ {
+		witnessInput.Felts = append(witnessInput.Felts, strconv.Itoa(i))
+	}
+	dir := b.TempDir()
+	data, err := json.Marshal(witnessInput)
+	if err != nil {
+		b.Fatal(err)
+	}
+	jsonPath := filepath.Join(dir, "witness.json")
+	if err := os.WriteFile(jsonPath, data, 0644); err != nil {
+		b.Fatal(err)
+	}
+	binaryPath := filepath.Join(dir, "witness.bin")
+	if err := ConvertWitnessInput(jsonPath, binaryPath); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, format := range []string{"json", "binary"} {
+		path := map[string]string{"json": jsonPath, "binary": binaryPath}[format]
+		for _, mmap := range []bool{true, false} {
+			b.Run("format="+format+"/mmap="+strconv.FormatBool(mmap), func(b *testing.B) {
+				setMmapInputs(b, mmap)
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := ReadWitnessInput(path); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestReadWitnessInputErrors(t *testing.T) {
+	for witness, expected := range map[string]string{
+		`{"vars":["0x"],"vkey_hash":"0","commited_values_digest":"0"}`:                                         `var 0: "0x" is not a number`,
+		`{"vars":["010"],"vkey_hash":"0","commited_values_digest":"0"}`:                                        `var 0: "010" is not a number`,
+		`{"felts":["08"],"vkey_hash":"0","commited_values_digest":"0"}`:                                        `felt 0: "08" is not a decimal number`,
+		`{"felts":["2013265921"],"vkey_hash":"0","commited_values_digest":"0"}`:                                "felt 0: 2013265921 is not a canonical BabyBear element",
+		`{"exts":[["1","2","3"]],"vkey_hash":"0","commited_values_digest":"0"}`:                                "ext 0: extension element must have 4 coordinates, got 3",
+		`{"exts":[["1","2","3","4"],["0","0","0","2013265921"]],"vkey_hash":"0","commited_values_digest":"0"}`: "ext 1: extension coordinate 3: 2013265921 is not a canonical BabyBear element",
+		`{"vkey_hash":"0x+1","commited_values_digest":"0"}`:                                                    "invalid vkey hash",
+		`{"vkey_hash":"0"}`: "invalid committed values digest",
+	} {
+		path := filepath.Join(t.TempDir(), "witness.json")
+		if err := os.WriteFile(path, []byte(witness), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadWitnessInput(path); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%s: expected an error containing %q, got %v", witness, expected, err)
+		}
+		if _, err := DecodeWitnessInput(strings.NewReader(witness)); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%s: expected the streamed witness to fail with %q, got %v", witness, expected, err)
+		}
+	}
+}
+
+func TestDecodeWitnessStream(t *testing.T) {
+	data, err := os.ReadFile("testdata/basic_witness.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := ReadWitnessInput("testdata/basic_witness.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	witnessInput, err := DecodeWitnessInput(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(witnessInput, expected) {
+		t.Fatalf("streamed and mapped reads differ: %+v != %+v", witnessInput, expected)
+	}
+
+	// The keys are matched like json.Unmarshal matches them.
+	for witness, expected := range map[string]WitnessInput{
+		`{"vars":null,"felts":[],"vkey_hash":"1","commited_values_digest":"2"}`:           {Felts: []string{}, VkeyHash: "1", CommitedValuesDigest: "2"},
+		`{"VARS":["3"],"Vkey_Hash":"1","commited_values_digest":"2","unknown":[{"a":1}]}`: {Vars: []string{"3"}, VkeyHash: "1", CommitedValuesDigest: "2"},
+	} {
+		witnessInput, err := DecodeWitnessInput(strings.NewReader(witness))
+		if err != nil {
+			t.Fatalf("%s: %v", witness, err)
+		}
+		if !reflect.DeepEqual(witnessInput, expected) {
+			t.Errorf("%s: decoded %+v, expected %+v", witness, witnessInput, expected)
+		}
+	}
+	for _, witness := range []string{`[]`, `{"vars":"1"}`, `{"vars":[1]}`, `{"exts":[["1","2","3","4"]`, ``} {
+		if _, err := DecodeWitnessInput(strings.NewReader(witness)); err == nil {
+			t.Errorf("%s: expected the witness to be rejected", witness)
+		}
+	}
+}
+
+// witnessGenerator generates the vars of a JSON witness of n vars of 76 digits without holding
+// them, and calls done once they are read.
+type witnessGenerator struct {
+	n       int
+	pending []byte
+	done    func()
+}
+
+func (g *witnessGenerator) Read(p []byte) (int, error) {
+	for len(g.pending) == 0 {
+		switch {
+		case g.n < 0:
+			return 0, io.EOF
+		case g.n == 0:
+			g.done()
+			g.pending = []byte(`"0"],"vkey_hash":"0","commited_values_digest":"0"}`)
+		default:
+			g.pending = []byte(fmt.Sprintf(`"1%075d",`, g.n))
+		}
+		g.n--
+	}
+	n := copy(p, g.pending)
+	g.pending = g.pending[n:]
+	return n, nil
+}
+
+func TestDecodeWitnessStreamMemory(t *testing.T) {
+	const n = 200_000
+	documentSize := 79 * n
+
+	// The heap is measured once the whole document is read, when decoding it as a whole would hold
+	// all of it.
+	var before, atEnd runtime.MemStats
+	generator := &witnessGenerator{n: n, done: func() {
+		runtime.GC()
+		runtime.ReadMemStats(&atEnd)
+	}}
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	witnessInput, err := DecodeWitnessInput(io.MultiReader(strings.NewReader(`{"vars":[`), generator))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(witnessInput.Vars) != n+1 {
+		t.Fatalf("decoded %d vars, expected %d", len(witnessInput.Vars), n+1)
+	}
+
+	// Each var takes 79 bytes of the document, and 80 bytes and a string header of the slice once
+	// decoded, with the slack of the slice.
+	values := n * (80 + 2*16)
+	if held := int(atEnd.HeapAlloc) - int(before.HeapAlloc); held > values+documentSize/2 {
+		t.Errorf("decoding a witness of %d bytes held %d bytes, its values take %d", documentSize, held, values)
+	}
+}
+
+func TestProveWitnessSize(t *testing.T) {
+	dataDir := newDevDataDir(t, "testdata/basic_constraints.json", "testdata/basic_witness.json")
+	Build(dataDir)
+	witnessInput, err := ReadWitnessInput(filepath.Join(dataDir, WITNESS_JSON_FILE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prove := func(witnessInput WitnessInput) (Proof, error) {
+		data, err := json.Marshal(witnessInput)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(t.TempDir(), "witness.json")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return ProveContext(context.Background(), dataDir, path, ProveOptions{})
+	}
+
+	// A marshalled witness proves like the original.
+	proof, err := prove(witnessInput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(dataDir, proof.RawProof, "123", "456"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A witness with a value more or less than the build is rejected with the counts.
+	longer := witnessInput
+	longer.Felts = append(append([]string{}, witnessInput.Felts...), "7")
+	expected := "witness: 2 vars, 4 felts and 1 exts make 12 witness values, but the circuit expects 11"
+	if _, err := prove(longer); err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+	shorter := witnessInput
+	shorter.Exts = nil
+	expected = "witness: 2 vars, 3 felts and 0 exts make 7 witness values, but the circuit expects 11"
+	if _, err := prove(shorter); err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+}
@@ -0,0 +1,160 @@
+package sp1
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Liveness lists, for every instruction, the ids that no later instruction mentions, so that the
+// interpreter can drop them once the instruction ran. The lists are stored back to back to keep
+// the analysis small next to the values it frees.
+type Liveness struct {
+	ids     []string
+	offsets []int32
+}
+
+// Dead returns the ids whose last mention is instruction i.
+func (l *Liveness) Dead(i int) []string {
+	return l.ids[l.offsets[i]:l.offsets[i+1]]
+}
+
+// ComputeLiveness finds the last mention of every id of the stream. Immediates are skipped.
+func ComputeLiveness(constraints []Constraint) *Liveness {
+	seen := make(map[string]struct{})
+	var ids []string
+	offsets := make([]int32, len(constraints)+1)
+	for i := len(constraints) - 1; i >= 0; i-- {
+		for _, arg := range constraints[i].Args {
+			for _, id := range arg {
+				if _, ok := seen[id]; ok || !isId(id) {
+					continue
+				}
+				seen[id] = struct{}{}
+				ids = append(ids, id)
+			}
+		}
+		offsets[i] = int32(len(ids))
+	}
+
+	// The ids were collected from the last instruction backwards.
+	for i, j := 0, len(ids)-1; i < j; i, j = i+1, j-1 {
+		ids[i], ids[j] = ids[j], ids[i]
+	}
+	for i := range offsets {
+		offsets[i] = int32(len(ids)) - offsets[i]
+	}
+	return &Liveness{ids: ids, offsets: offsets}
+}
+
+// AllocateSlots renames the ids of the stream to slots, so that the interpreter stores at most as
+// many values as are live at once. The slot of an id goes back to a free list after the instruction
+// it dies at, and later assignments of new ids take their slot from it. It returns the renamed
+// stream, its liveness in slots and the number of slots. Print instructions get the id they print
+// as a last argument, so that their label is kept.
+func AllocateSlots(constraints []Constraint, liveness *Liveness) ([]Constraint, *Liveness, int) {
+	var names []string
+	slots := make(map[string]string)
+	var free []string
+	slot := func(id string) string {
+		if s, ok := slots[id]; ok {
+			return s
+		}
+		if n := len(free); n > 0 {
+			slots[id] = free[n-1]
+			free = free[:n-1]
+		} else {
+			names = append(names, "s"+strconv.Itoa(len(names)))
+			slots[id] = names[len(names)-1]
+		}
+		return slots[id]
+	}
+
+	renamed := make([]Constraint, len(constraints))
+	dead := &Liveness{ids: make([]string, 0, len(liveness.ids)), offsets: make([]int32, 1, len(constraints)+1)}
+	for i, cs := range constraints {
+		args := make([][]string, len(cs.Args), len(cs.Args)+1)
+		for j, arg := range cs.Args {
+			args[j] = make([]string, len(arg))
+			for k, id := range arg {
+				if isId(id) {
+					args[j][k] = slot(id)
+				} else {
+					args[j][k] = id
+				}
+			}
+		}
+		switch cs.Opcode {
+		case "PrintV", "PrintF", "PrintE":
+			args = append(args, cs.Args[0])
+		}
+		renamed[i] = Constraint{Opcode: cs.Opcode, Args: args, Source: cs.Source}
+
+		for _, id := range liveness.Dead(i) {
+			if s, ok := slots[id]; ok {
+				delete(slots, id)
+				free = append(free, s)
+				dead.ids = append(dead.ids, s)
+			}
+		}
+		dead.offsets = append(dead.offsets, int32(len(dead.ids)))
+	}
+	return renamed, dead, len(names)
+}
+
+// annotatedLiveness returns the liveness annotations of the stream, or nil if it has none. An
+// annotation dropping an id that a later instruction reads is rejected.
+func annotatedLiveness(constraints []Constraint) (*Liveness, error) {
+	annotated := false
+	for _, cs := range constraints {
+		if cs.Dead != nil {
+			annotated = true
+			break
+		}
+	}
+	if !annotated {
+		return nil, nil
+	}
+
+	liveness := &Liveness{offsets: make([]int32, 1, len(constraints)+1)}
+	dropped := make(map[string]int)
+	for i, cs := range constraints {
+		nbOutputArgs := len(cs.Args) - len(instructionReads(cs))
+		for _, arg := range cs.Args[nbOutputArgs:] {
+			for _, id := range arg {
+				if at, ok := dropped[id]; ok {
+					return nil, fmt.Errorf("instruction %d: %s is read after being dropped at instruction %d", i, id, at)
+				}
+			}
+		}
+		// Assigning a dropped id makes it live again.
+		for _, arg := range cs.Args[:nbOutputArgs] {
+			for _, id := range arg {
+				delete(dropped, id)
+			}
+		}
+		for _, id := range cs.Dead {
+			dropped[id] = i
+		}
+		liveness.ids = append(liveness.ids, cs.Dead...)
+		liveness.offsets = append(liveness.offsets, int32(len(liveness.ids)))
+	}
+	return liveness, nil
+}
+
+// instructionReads returns the trailing arguments of an instruction that it reads. Permutations
+// read and write all of their arguments in place.
+func instructionReads(cs Constraint) [][]string {
+	switch cs.Opcode {
+	case "AssertEqV", "AssertEqF", "AssertEqE", "PrintV", "PrintF", "PrintE", "CommitVkeyHash", "CommitCommitedValuesDigest", "Permute", "PermuteBabyBear":
+		return cs.Args
+	case "Ext2Felt":
+		return cs.Args[4:]
+	default:
+		return cs.Args[1:]
+	}
+}
+
+// isId reports whether an argument names a value rather than being an immediate.
+func isId(arg string) bool {
+	return arg != "" && (arg[0] < '0' || arg[0] > '9') && arg[0] != '-'
+}
@@ -0,0 +1,201 @@
+package sp1
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/scs"
+)
+
+// compileDigest compiles the circuit of a constraints file for the basic witness and returns the
+// digest of the constraint system.
+func compileDigest(t *testing.T, constraintsPath string) (string, error) {
+	t.Setenv("CONSTRAINTS_JSON", constraintsPath)
+	circuit := NewCircuit(readTestWitness(t, "testdata/basic_witness.json"))
+	compiled, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &circuit)
+	if err != nil {
+		return "", err
+	}
+	return CircuitDigest(compiled)
+}
+
+func TestComputeLiveness(t *testing.T) {
+	constraints := []Constraint{
+		{Opcode: "ImmF", Args: [][]string{{"f0"}, {"3"}}},
+		{Opcode: "AddF", Args: [][]string{{"f1"}, {"f0"}, {"f0"}}},
+		{Opcode: "MulF", Args: [][]string{{"f2"}, {"f1"}, {"f0"}}},
+		{Opcode: "AssertEqF", Args: [][]string{{"f2"}, {"f1"}}},
+	}
+	expected := [][]string{{}, {}, {"f0"}, {"f1", "f2"}}
+	liveness := ComputeLiveness(constraints)
+	for i := range constraints {
+		if dead := liveness.Dead(i); !reflect.DeepEqual(dead, expected[i]) {
+			t.Fatalf("expected instruction %d to drop %v, got %v", i, expected[i], dead)
+		}
+	}
+}
+
+func TestFreeDeadVariables(t *testing.T) {
+	for _, path := range []string{
+		"testdata/basic_constraints.json",
+		"testdata/fusion_constraints.json",
+		"testdata/cse_constraints.json",
+		"testdata/mule_constraints.json",
+	} {
+		t.Setenv("FREE_DEAD_VARIABLES", "")
+		expected, err := compileDigest(t, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("FREE_DEAD_VARIABLES", "true")
+		digest, err := compileDigest(t, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if digest != expected {
+			t.Fatalf("%s: dropping dead variables changed the circuit", path)
+		}
+	}
+	if err := RunTestEngine("testdata/basic_constraints.json", "testdata/basic_witness.json"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLivenessAnnotations(t *testing.T) {
+	t.Setenv("FREE_DEAD_VARIABLES", "true")
+	constraints := readTestConstraints(t, "testdata/basic_constraints.json")
+	liveness := ComputeLiveness(constraints)
+	for i := range constraints {
+		constraints[i].Dead = liveness.Dead(i)
+	}
+	writeConstraints := func() string {
+		data, err := json.Marshal(constraints)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(t.TempDir(), "annotated_constraints.json")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	expected, err := compileDigest(t, "testdata/basic_constraints.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest, err := compileDigest(t, writeConstraints()); err != nil || digest != expected {
+		t.Fatalf("expected the annotated stream to compile to the same circuit, got %v", err)
+	}
+
+	// f2 is still compared to f3 by the fifth instruction.
+	constraints[2].Dead = []string{"f2"}
+	_, err = compileDigest(t, writeConstraints())
+	if err == nil || !strings.Contains(err.Error(), "instruction 4: f2 is read after being dropped at instruction 2") {
+		t.Fatalf("expected the early drop to be rejected, got %v", err)
+	}
+}
+
+func TestAllocateSlots(t *testing.T) {
+	constraints := []Constraint{
+		{Opcode: "ImmF", Args: [][]string{{"f0"}, {"3"}}},
+		{Opcode: "AddF", Args: [][]string{{"f1"}, {"f0"}, {"f0"}}},
+		{Opcode: "MulF", Args: [][]string{{"f2"}, {"f1"}, {"f0"}}},
+		{Opcode: "PrintF", Args: [][]string{{"f2"}}},
+		{Opcode: "AddF", Args: [][]string{{"f3"}, {"f2"}, {"f1"}}},
+		{Opcode: "AssertEqF", Args: [][]string{{"f3"}, {"f3"}}},
+	}
+	renamed, liveness, nbSlots := AllocateSlots(constraints, ComputeLiveness(constraints))
+
+	// f3 takes the slot f0 freed after the third instruction.
+	expected := [][][]string{
+		{{"s0"}, {"3"}},
+		{{"s1"}, {"s0"}, {"s0"}},
+		{{"s2"}, {"s1"}, {"s0"}},
+		{{"s2"}, {"f2"}},
+		{{"s0"}, {"s2"}, {"s1"}},
+		{{"s0"}, {"s0"}},
+	}
+	expectedDead := [][]string{{}, {}, {"s0"}, {}, {"s1", "s2"}, {"s0"}}
+	for i := range constraints {
+		if !reflect.DeepEqual(renamed[i].Args, expected[i]) {
+			t.Fatalf("expected instruction %d to be renamed to %v, got %v", i, expected[i], renamed[i].Args)
+		}
+		if dead := liveness.Dead(i); !reflect.DeepEqual(dead, expectedDead[i]) {
+			t.Fatalf("expected instruction %d to free %v, got %v", i, expectedDead[i], dead)
+		}
+	}
+	if nbSlots != 3 {
+		t.Fatalf("expected 3 slots, got %d", nbSlots)
+	}
+	if constraints[1].Args[0][0] != "f1" {
+		t.Fatal("expected the stream to be left unchanged")
+	}
+
+	// A chain only ever holds the value it reads and the one it writes.
+	chain := syntheticConstraints(1000)
+	if _, _, nbSlots := AllocateSlots(chain, ComputeLiveness(chain)); nbSlots != 2 {
+		t.Fatalf("expected a chain to use 2 slots, got %d", nbSlots)
+	}
+}
+
+// liveHeapContext records the largest live heap seen by the checks of the context of the
+// synthesis, collecting the garbage every few checks before reading the heap.
+type liveHeapContext struct {
+	context.Context
+	nbChecks int
+	peak     uint64
+}
+
+func (c *liveHeapContext) Err() error {
+	c.nbChecks++
+	if c.nbChecks%16 == 0 {
+		runtime.GC()
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		c.peak = max(c.peak, stats.HeapAlloc)
+	}
+	return c.Context.Err()
+}
+
+func BenchmarkFreeDeadVariables(b *testing.B) {
+	// Start the chain from a witness so that the intermediate values are not folded into constants.
+	constraints := syntheticConstraints(400_000)
+	constraints[0] = Constraint{Opcode: "WitnessF", Args: [][]string{{"f0"}, {"0"}}}
+	data, err := json.Marshal(constraints)
+	if err != nil {
+		b.Fatal(err)
+	}
+	path := filepath.Join(b.TempDir(), "constraints.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		b.Fatal(err)
+	}
+	b.Setenv("CONSTRAINTS_JSON", path)
+
+	for _, enabled := range []string{"false", "true"} {
+		b.Run("enabled="+enabled, func(b *testing.B) {
+			b.Setenv("FREE_DEAD_VARIABLES", enabled)
+			var peak uint64
+			for i := 0; i < b.N; i++ {
+				// Sample the live heap, without the garbage, while the circuit is synthesized.
+				ctx := &liveHeapContext{Context: context.Background()}
+				circuit := NewCircuit(WitnessInput{Vars: []string{}, Felts: []string{"1"}, Exts: []ExtValue{}})
+				circuit.ctx = ctx
+				runtime.GC()
+				if _, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &circuit); err != nil {
+					b.Fatal(err)
+				}
+				peak = max(peak, ctx.peak)
+			}
+			b.ReportMetric(float64(peak)/(1<<20), "peak-live-heap-MB")
+		})
+	}
+}
@@ -0,0 +1,107 @@
+// Package logging defines the leveled, structured logger that the prover pipeline writes to, so
+// that services embedding it can route its logs.
+package logging
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"sort"
+
+	"github.com/rs/zerolog"
+)
+
+// Logger is a leveled logger taking a message and alternating keys and values, like *slog.Logger,
+// which implements it.
+type Logger interface {
+	Debug(msg string, keyvals ...any)
+	Info(msg string, keyvals ...any)
+	Warn(msg string, keyvals ...any)
+	Error(msg string, keyvals ...any)
+}
+
+var level = new(slog.LevelVar)
+
+var defaultLogger Logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+
+// Default returns the logger used when none is set: text records on stderr, at the level set with
+// SetLevel, which is Info by default.
+func Default() Logger {
+	return defaultLogger
+}
+
+// SetLevel sets the minimum level of the records of the default logger.
+func SetLevel(l slog.Level) {
+	level.Set(l)
+}
+
+// ParseLevel parses a level name, such as "debug" or "WARN", for SetLevel.
+func ParseLevel(name string) (slog.Level, error) {
+	var l slog.Level
+	err := l.UnmarshalText([]byte(name))
+	return l, err
+}
+
+// OrDefault returns l, or the default logger if l is nil.
+func OrDefault(l Logger) Logger {
+	if l == nil {
+		return Default()
+	}
+	return l
+}
+
+type nop struct{}
+
+func (nop) Debug(string, ...any) {}
+func (nop) Info(string, ...any)  {}
+func (nop) Warn(string, ...any)  {}
+func (nop) Error(string, ...any) {}
+
+// Nop returns a logger discarding every record.
+func Nop() Logger {
+	return nop{}
+}
+
+// Zerolog returns a zerolog.Logger writing its events to l, for the gnark APIs that log to one,
+// such as the solver printing the values of api.Println.
+func Zerolog(l Logger) zerolog.Logger {
+	return zerolog.New(zerologWriter{l}).Level(zerolog.DebugLevel)
+}
+
+type zerologWriter struct {
+	logger Logger
+}
+
+// Write decodes a zerolog event and writes it to the logger at the same level, with its fields.
+func (w zerologWriter) Write(p []byte) (int, error) {
+	var fields map[string]any
+	if err := json.Unmarshal(p, &fields); err != nil {
+		w.logger.Warn("undecodable log event", "event", string(p))
+		return len(p), nil
+	}
+	msg, _ := fields[zerolog.MessageFieldName].(string)
+	levelName, _ := fields[zerolog.LevelFieldName].(string)
+	delete(fields, zerolog.MessageFieldName)
+	delete(fields, zerolog.LevelFieldName)
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	keyvals := make([]any, 0, 2*len(keys))
+	for _, key := range keys {
+		keyvals = append(keyvals, key, fields[key])
+	}
+
+	switch levelName {
+	case zerolog.LevelTraceValue, zerolog.LevelDebugValue:
+		w.logger.Debug(msg, keyvals...)
+	case zerolog.LevelWarnValue:
+		w.logger.Warn(msg, keyvals...)
+	case zerolog.LevelErrorValue, zerolog.LevelFatalValue, zerolog.LevelPanicValue:
+		w.logger.Error(msg, keyvals...)
+	default:
+		w.logger.Info(msg, keyvals...)
+	}
+	return len(p), nil
+}
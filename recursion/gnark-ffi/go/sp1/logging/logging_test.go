@@ -0,0 +1,50 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestZerolog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	zl := Zerolog(logger)
+	zl.Debug().Str("caller", "sp1.go:278").Msg("15")
+	zl.Warn().Int("n", 3).Msg("warning")
+	zl.Error().Msg("failure")
+	zl.Info().Msg("done")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	for i, expected := range []string{
+		`level=DEBUG msg=15 caller=sp1.go:278`,
+		`level=WARN msg=warning n=3`,
+		`level=ERROR msg=failure`,
+		`level=INFO msg=done`,
+	} {
+		if i >= len(lines) || !strings.Contains(lines[i], expected) {
+			t.Errorf("line %d: expected %q in %q", i, expected, lines)
+		}
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for name, expected := range map[string]slog.Level{"debug": slog.LevelDebug, "INFO": slog.LevelInfo, "warn": slog.LevelWarn, "error": slog.LevelError} {
+		if level, err := ParseLevel(name); err != nil || level != expected {
+			t.Errorf("%s: got %v, %v", name, level, err)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("expected an unknown level to be rejected")
+	}
+}
+
+func TestOrDefault(t *testing.T) {
+	if OrDefault(nil) != Default() {
+		t.Error("expected the default logger for nil")
+	}
+	if nop := Nop(); OrDefault(nop) != nop {
+		t.Error("expected a set logger to be kept")
+	}
+}
@@ -0,0 +1,152 @@
+package merkle
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+
+	"github.com/consensys/gnark/frontend"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/poseidon2"
+)
+
+const DIGEST_SIZE = poseidon2.BABYBEAR_DIGEST_SIZE
+
+// Dims are the dimensions of a committed matrix.
+type Dims struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// HashOpenedRows recomputes the root of a Plonky3 MMCS tree from the rows opened at a leaf index,
+// rows[i] being the row of the matrix of dimensions dims[i], and from the siblings of the Merkle
+// path. indexBits are the little-endian bits of the index, as returned by ToBinaryStrict.
+//
+// A matrix of height zero is not committed, like the matrices of the chips absent from a shard, so
+// its row must be empty and is excluded from the tree.
+//
+// The shape of the opening is part of the circuit, so it panics if the rows or the proof do not
+// match dims.
+func HashOpenedRows(
+	chip *babybear.Chip,
+	hasher *poseidon2.Poseidon2BabyBearChip,
+	rows [][]babybear.Variable,
+	dims []Dims,
+	indexBits []frontend.Variable,
+	proof [][DIGEST_SIZE]babybear.Variable,
+) [DIGEST_SIZE]babybear.Variable {
+	schedule, err := injectionSchedule(dims)
+	if err != nil {
+		panic(err)
+	}
+	if len(rows) != len(dims) {
+		panic(fmt.Sprintf("expected a row for each of the %d matrices, got %d", len(dims), len(rows)))
+	}
+	for i, row := range rows {
+		width := dims[i].Width
+		if dims[i].Height == 0 {
+			width = 0
+		}
+		if len(row) != width {
+			panic(fmt.Sprintf("matrix %d: expected a row of %d elements, got %d", i, width, len(row)))
+		}
+	}
+	if depth := len(schedule) - 1; len(proof) != depth || len(indexBits) < depth {
+		panic(fmt.Sprintf("expected a path of %d siblings, got %d siblings and %d index bits", depth, len(proof), len(indexBits)))
+	}
+	hashRows := func(matrices []int) [DIGEST_SIZE]babybear.Variable {
+		var inputs []babybear.Variable
+		for _, i := range matrices {
+			inputs = append(inputs, rows[i]...)
+		}
+		return hasher.Hash(inputs)
+	}
+
+	root := hashRows(schedule[0])
+	for i, sibling := range proof {
+		var left, right [DIGEST_SIZE]babybear.Variable
+		for j := range root {
+			left[j], right[j] = chip.SwapF(indexBits[i], root[j], sibling[j])
+		}
+		root = hasher.Compress(left, right)
+		if injected := schedule[i+1]; len(injected) > 0 {
+			root = hasher.Compress(root, hashRows(injected))
+		}
+	}
+	return root
+}
+
+// VerifyBatch asserts that the rows opened at a leaf index and the siblings of the Merkle path
+// hash to commit, like verify_batch in the SP1 recursion circuit. The arguments are those of
+// HashOpenedRows.
+func VerifyBatch(
+	chip *babybear.Chip,
+	hasher *poseidon2.Poseidon2BabyBearChip,
+	commit [DIGEST_SIZE]babybear.Variable,
+	rows [][]babybear.Variable,
+	dims []Dims,
+	indexBits []frontend.Variable,
+	proof [][DIGEST_SIZE]babybear.Variable,
+) {
+	root := HashOpenedRows(chip, hasher, rows, dims, indexBits, proof)
+	for i := range root {
+		chip.AssertIsEqualF(root[i], commit[i])
+	}
+}
+
+// injectionSchedule returns, for each level of the tree from the leaves to the root, the matrices
+// whose rows are hashed at that level, like verify_batch in the SP1 recursion circuit.
+//
+// The tallest matrices, up to their height padded to a power of two, are hashed together into the
+// leaf. A shorter matrix is injected at the level whose width is its padded height, by compressing
+// the node on the path with the hash of the rows of the matrices of exactly that height, so rows
+// of taller matrices enter deeper in the tree. Matrices of the same height are hashed in the order
+// of dims, and matrices of height zero are in no level.
+func injectionSchedule(dims []Dims) ([][]int, error) {
+	var order []int
+	for i, d := range dims {
+		if d.Width < 0 || d.Height < 0 {
+			return nil, fmt.Errorf("matrix %d has invalid dimensions %dx%d", i, d.Width, d.Height)
+		}
+		if d.Height > 0 {
+			order = append(order, i)
+		}
+	}
+	if len(order) == 0 {
+		return nil, fmt.Errorf("no matrices to open")
+	}
+
+	// Tallest first; the sort is stable so matrices of the same height keep their order.
+	sort.SliceStable(order, func(a, b int) bool { return dims[order[a]].Height > dims[order[b]].Height })
+	takeWhile := func(take func(height int) bool) []int {
+		var matrices []int
+		for len(order) > 0 && take(dims[order[0]].Height) {
+			matrices = append(matrices, order[0])
+			order = order[1:]
+		}
+		return matrices
+	}
+
+	height := nextPowerOfTwo(dims[order[0]].Height)
+	schedule := [][]int{takeWhile(func(h int) bool { return nextPowerOfTwo(h) == height })}
+	for height > 1 {
+		height >>= 1
+		var injected []int
+		if len(order) > 0 && nextPowerOfTwo(dims[order[0]].Height) == height {
+			next := dims[order[0]].Height
+			injected = takeWhile(func(h int) bool { return h == next })
+		}
+		schedule = append(schedule, injected)
+	}
+	if len(order) > 0 {
+		return nil, fmt.Errorf("matrix %d of height %d is not committed at any level", order[0], dims[order[0]].Height)
+	}
+	return schedule, nil
+}
+
+func nextPowerOfTwo(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	return 1 << bits.Len(uint(n-1))
+}
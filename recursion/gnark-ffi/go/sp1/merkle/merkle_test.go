@@ -0,0 +1,295 @@
+package merkle
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/poseidon2"
+)
+
+// mmcsFixture holds openings of a commitment to matrices of heights 32, 8, 2 and 1, so rows are
+// injected at levels that skip one or more heights. testdata/mmcs_openings.json is the JSON printed
+// by the ignored test of the SP1 recursion circuit, which commits with the Plonky3 MMCS:
+//
+//	cargo test -p sp1-recursion-circuit --release mmcs::tests::test_generate_mmcs_fixture -- --ignored --nocapture
+//
+// nativeTestFixture commits to the same matrices with the native Poseidon2, for the tests that
+// only need a valid opening.
+type mmcsFixture struct {
+	Dims     []Dims              `json:"dims"`
+	Commit   [DIGEST_SIZE]uint64 `json:"commit"`
+	Openings []struct {
+		Index int                   `json:"index"`
+		Rows  [][]uint64            `json:"rows"`
+		Proof [][DIGEST_SIZE]uint64 `json:"proof"`
+	} `json:"openings"`
+}
+
+type hashOpenedRowsCircuit struct {
+	Rows      [][]frontend.Variable
+	IndexBits []frontend.Variable
+	Proof     [][DIGEST_SIZE]frontend.Variable
+	Commit    [DIGEST_SIZE]frontend.Variable `gnark:",public"`
+
+	dims []Dims `gnark:"-"`
+}
+
+func (circuit *hashOpenedRowsCircuit) Define(api frontend.API) error {
+	chip := babybear.NewChip(api)
+	felt := func(v frontend.Variable) babybear.Variable { return babybear.Variable{Value: v, NbBits: 31} }
+	rows := make([][]babybear.Variable, len(circuit.Rows))
+	for i, row := range circuit.Rows {
+		for _, v := range row {
+			rows[i] = append(rows[i], felt(v))
+		}
+	}
+	proof := make([][DIGEST_SIZE]babybear.Variable, len(circuit.Proof))
+	for i, sibling := range circuit.Proof {
+		for j, v := range sibling {
+			proof[i][j] = felt(v)
+		}
+	}
+	var commit [DIGEST_SIZE]babybear.Variable
+	for i, v := range circuit.Commit {
+		commit[i] = felt(v)
+	}
+	VerifyBatch(chip, poseidon2.NewBabyBearChip(api), commit, rows, circuit.dims, circuit.IndexBits, proof)
+	return nil
+}
+
+func readTestFixture(t *testing.T) mmcsFixture {
+	data, err := os.ReadFile("testdata/mmcs_openings.json")
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("testdata/mmcs_openings.json is missing, save the JSON printed by: cargo test -p sp1-recursion-circuit --release mmcs::tests::test_generate_mmcs_fixture -- --ignored --nocapture")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fixture mmcsFixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		t.Fatal(err)
+	}
+	return fixture
+}
+
+// The dimensions of the matrices of test_generate_mmcs_fixture.
+var testDims = []Dims{{Width: 3, Height: 32}, {Width: 4, Height: 8}, {Width: 5, Height: 32}, {Width: 9, Height: 2}, {Width: 2, Height: 1}}
+
+// nativeTestFixture opens the matrices of test_generate_mmcs_fixture at the same indices, committing
+// to them like the Plonky3 MMCS: the rows of the tallest matrices are hashed into the leaves, and
+// those of a shorter matrix are compressed into the node of its height.
+func nativeTestFixture() mmcsFixture {
+	p := babybear.MODULUS.Uint64()
+	matrices := make([][][]uint64, len(testDims))
+	for k, dims := range testDims {
+		matrices[k] = make([][]uint64, dims.Height)
+		for r := range matrices[k] {
+			for c := 0; c < dims.Width; c++ {
+				v := (uint64(k)*1000003 + uint64(r)*7919 + uint64(c)*31 + 1) * 104729
+				matrices[k][r] = append(matrices[k][r], v%p)
+			}
+		}
+	}
+	hashRows := func(height, index int) ([DIGEST_SIZE]uint64, bool) {
+		var inputs []uint64
+		found := false
+		for k, dims := range testDims {
+			if dims.Height == height {
+				inputs = append(inputs, matrices[k][index]...)
+				found = true
+			}
+		}
+		return poseidon2.HashBabyBearNative(inputs), found
+	}
+
+	layer := make([][DIGEST_SIZE]uint64, 32)
+	for i := range layer {
+		layer[i], _ = hashRows(len(layer), i)
+	}
+	layers := [][][DIGEST_SIZE]uint64{layer}
+	for len(layer) > 1 {
+		next := make([][DIGEST_SIZE]uint64, len(layer)/2)
+		for i := range next {
+			next[i] = poseidon2.CompressBabyBearNative(layer[2*i], layer[2*i+1])
+			if injected, ok := hashRows(len(next), i); ok {
+				next[i] = poseidon2.CompressBabyBearNative(next[i], injected)
+			}
+		}
+		layers = append(layers, next)
+		layer = next
+	}
+
+	fixture := mmcsFixture{Dims: testDims, Commit: layer[0]}
+	for _, index := range []int{0, 13, 31} {
+		fixture.Openings = append(fixture.Openings, struct {
+			Index int                   `json:"index"`
+			Rows  [][]uint64            `json:"rows"`
+			Proof [][DIGEST_SIZE]uint64 `json:"proof"`
+		}{Index: index})
+		opening := &fixture.Openings[len(fixture.Openings)-1]
+		for k, dims := range testDims {
+			opening.Rows = append(opening.Rows, matrices[k][index*dims.Height/32])
+		}
+		for level := range layers[:len(layers)-1] {
+			opening.Proof = append(opening.Proof, layers[level][(index>>level)^1])
+		}
+	}
+	return fixture
+}
+
+// solveOpening solves the circuit on the opening of the native fixture with the given index, after
+// applying mutate to the assignment.
+func solveOpening(opening int, mutate func(*hashOpenedRowsCircuit)) error {
+	return solveFixtureOpening(nativeTestFixture(), opening, mutate)
+}
+
+func solveFixtureOpening(fixture mmcsFixture, opening int, mutate func(*hashOpenedRowsCircuit)) error {
+	o := fixture.Openings[opening]
+	circuit := hashOpenedRowsCircuit{
+		Rows:      make([][]frontend.Variable, len(o.Rows)),
+		IndexBits: make([]frontend.Variable, len(o.Proof)),
+		Proof:     make([][DIGEST_SIZE]frontend.Variable, len(o.Proof)),
+		dims:      fixture.Dims,
+	}
+	assignment := hashOpenedRowsCircuit{
+		Rows:      make([][]frontend.Variable, len(o.Rows)),
+		IndexBits: make([]frontend.Variable, len(o.Proof)),
+		Proof:     make([][DIGEST_SIZE]frontend.Variable, len(o.Proof)),
+	}
+	for i, row := range o.Rows {
+		circuit.Rows[i] = make([]frontend.Variable, len(row))
+		for _, v := range row {
+			assignment.Rows[i] = append(assignment.Rows[i], v)
+		}
+	}
+	for i, sibling := range o.Proof {
+		assignment.IndexBits[i] = (o.Index >> i) & 1
+		for j, v := range sibling {
+			assignment.Proof[i][j] = v
+		}
+	}
+	for i, v := range fixture.Commit {
+		assignment.Commit[i] = v
+	}
+	if mutate != nil {
+		mutate(&assignment)
+	}
+	return test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField())
+}
+
+// TestHashOpenedRowsPlonky3 checks the openings of the Plonky3 MMCS.
+func TestHashOpenedRowsPlonky3(t *testing.T) {
+	fixture := readTestFixture(t)
+	if !reflect.DeepEqual(fixture.Dims, testDims) {
+		t.Fatalf("dims %v, expected those of test_generate_mmcs_fixture %v", fixture.Dims, testDims)
+	}
+	for opening := range fixture.Openings {
+		if err := solveFixtureOpening(fixture, opening, nil); err != nil {
+			t.Errorf("opening %d: %v", opening, err)
+		}
+	}
+}
+
+func TestHashOpenedRows(t *testing.T) {
+	for opening := range nativeTestFixture().Openings {
+		if err := solveOpening(opening, nil); err != nil {
+			t.Errorf("opening %d: %v", opening, err)
+		}
+	}
+
+	for name, mutate := range map[string]func(*hashOpenedRowsCircuit){
+		"tallest row":  func(c *hashOpenedRowsCircuit) { c.Rows[2][4] = 1 },
+		"injected row": func(c *hashOpenedRowsCircuit) { c.Rows[1][0] = 1 },
+		"root row":     func(c *hashOpenedRowsCircuit) { c.Rows[4][1] = 1 },
+		"sibling":      func(c *hashOpenedRowsCircuit) { c.Proof[3][7] = 1 },
+		"index":        func(c *hashOpenedRowsCircuit) { c.IndexBits[2] = 1 - c.IndexBits[2].(int) },
+		// The rows of matrices of the same height are hashed in the order of the dimensions.
+		"order": func(c *hashOpenedRowsCircuit) { c.Rows[0][0], c.Rows[2][0] = c.Rows[2][0], c.Rows[0][0] },
+	} {
+		if err := solveOpening(1, mutate); err == nil {
+			t.Errorf("%s: expected the opening to be rejected", name)
+		}
+	}
+}
+
+// Matrices without rows or without columns add nothing to the hashes, so they open against the
+// commitment of the same matrices without them.
+func TestHashOpenedRowsEmptyMatrices(t *testing.T) {
+	for name, dims := range map[string]Dims{
+		"zero height": {Width: 3, Height: 0},
+		"zero width":  {Width: 0, Height: 32},
+		"root":        {Width: 0, Height: 1},
+	} {
+		fixture := nativeTestFixture()
+		fixture.Dims = append([]Dims{dims}, fixture.Dims...)
+		for i := range fixture.Openings {
+			fixture.Openings[i].Rows = append([][]uint64{{}}, fixture.Openings[i].Rows...)
+		}
+		for opening := range fixture.Openings {
+			if err := solveFixtureOpening(fixture, opening, nil); err != nil {
+				t.Errorf("%s: opening %d: %v", name, opening, err)
+			}
+		}
+	}
+
+	// The row of a matrix without rows must be empty.
+	fixture := nativeTestFixture()
+	fixture.Dims = append(fixture.Dims, Dims{Width: 1, Height: 0})
+	fixture.Openings[0].Rows = append(fixture.Openings[0].Rows, []uint64{1})
+	if err := solveFixtureOpening(fixture, 0, nil); err == nil || !strings.Contains(err.Error(), "expected a row of 0 elements") {
+		t.Errorf("expected the row of a matrix of height zero to be rejected, got %v", err)
+	}
+}
+
+func TestInjectionSchedule(t *testing.T) {
+	schedule, err := injectionSchedule(testDims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The rows of the matrix of height 8 enter two levels above the leaves, past the empty level of
+	// width 16.
+	expected := [][]int{{0, 2}, nil, {1}, nil, {3}, {4}}
+	if !reflect.DeepEqual(schedule, expected) {
+		t.Errorf("schedule %v, expected %v", schedule, expected)
+	}
+
+	// Matrices of heights 5 to 8 are leaves of a tree of height 8.
+	schedule, err = injectionSchedule([]Dims{{Width: 1, Height: 5}, {Width: 1, Height: 8}, {Width: 1, Height: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := [][]int{{1, 0}, nil, {2}, nil}; !reflect.DeepEqual(schedule, expected) {
+		t.Errorf("schedule %v, expected %v", schedule, expected)
+	}
+
+	// A matrix of height zero is in no level.
+	schedule, err = injectionSchedule([]Dims{{Width: 1, Height: 8}, {Width: 3, Height: 0}, {Width: 0, Height: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := [][]int{{0}, nil, {2}, nil}; !reflect.DeepEqual(schedule, expected) {
+		t.Errorf("schedule %v, expected %v", schedule, expected)
+	}
+
+	for name, dims := range map[string][]Dims{
+		// A matrix of height 3 pads to 4, but only the matrices of height exactly 4 are injected at
+		// that level.
+		"uncommitted": {{Width: 1, Height: 8}, {Width: 1, Height: 4}, {Width: 1, Height: 3}},
+		"empty":       {},
+		"no rows":     {{Width: 1, Height: 0}},
+		"height":      {{Width: 1, Height: 4}, {Width: 1, Height: -1}},
+	} {
+		if _, err := injectionSchedule(dims); err == nil {
+			t.Errorf("%s: expected an invalid schedule", name)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package sp1
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/logging"
+)
+
+// Phase is the wall-clock duration of a step of Build or Prove, and of the steps nested in it.
+type Phase struct {
+	Name       string  `json:"name"`
+	DurationMs int64   `json:"duration_ms"`
+	Phases     []Phase `json:"phases,omitempty"`
+}
+
+// Metrics records the duration of phases and logs their boundaries. Phases started while another
+// one is running are nested in it. All methods are no-ops on a nil *Metrics.
+type Metrics struct {
+	Phases []Phase
+	open   []*openPhase
+	logger logging.Logger
+}
+
+type openPhase struct {
+	name    string
+	start   time.Time
+	buckets map[string]time.Duration
+	phases  []Phase
+}
+
+// NewMetrics returns metrics logging to logger, or to the default logger if it is nil.
+func NewMetrics(logger logging.Logger) *Metrics {
+	return &Metrics{logger: logging.OrDefault(logger)}
+}
+
+// Start begins a phase and returns the function ending it. Phases must end in the reverse order
+// they started.
+func (m *Metrics) Start(name string) func() {
+	if m == nil {
+		return func() {}
+	}
+	m.logger.Debug("phase started", "phase", name)
+	m.open = append(m.open, &openPhase{name: name, start: time.Now()})
+	depth := len(m.open)
+	return func() {
+		open := m.open[depth-1]
+		m.open = m.open[:depth-1]
+
+		phase := Phase{Name: open.name, DurationMs: time.Since(open.start).Milliseconds(), Phases: open.phases}
+		names := make([]string, 0, len(open.buckets))
+		for name := range open.buckets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			phase.Phases = append(phase.Phases, Phase{Name: name, DurationMs: open.buckets[name].Milliseconds()})
+		}
+
+		m.logger.Debug("phase finished", "phase", open.name, "duration_ms", phase.DurationMs)
+		if depth == 1 {
+			m.Phases = append(m.Phases, phase)
+		} else {
+			parent := m.open[depth-2]
+			parent.phases = append(parent.phases, phase)
+		}
+	}
+}
+
+// AddToBucket adds a duration to a named bucket of the innermost running phase. Buckets are
+// reported as nested phases, which is cheaper than starting a phase for many short steps.
+func (m *Metrics) AddToBucket(name string, d time.Duration) {
+	if m == nil || len(m.open) == 0 {
+		return
+	}
+	open := m.open[len(m.open)-1]
+	if open.buckets == nil {
+		open.buckets = make(map[string]time.Duration)
+	}
+	open.buckets[name] += d
+}
+
+// Log logs the duration of every top-level phase.
+func (m *Metrics) Log(msg string) {
+	if m == nil {
+		return
+	}
+	keyvals := make([]any, 0, 2*len(m.Phases))
+	for _, phase := range m.Phases {
+		keyvals = append(keyvals, phase.Name+"_ms", phase.DurationMs)
+	}
+	m.logger.Info(msg, keyvals...)
+}
+
+func writePhases(w io.Writer, phases []Phase, indent string) {
+	for _, phase := range phases {
+		fmt.Fprintf(w, "%s%s\t%dms\n", indent, phase.Name, phase.DurationMs)
+		writePhases(w, phase.Phases, indent+"  ")
+	}
+}
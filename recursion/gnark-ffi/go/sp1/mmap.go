@@ -0,0 +1,52 @@
+package sp1
+
+import (
+	"bytes"
+	"errors"
+	"os"
+)
+
+var errMmapUnsupported = errors.New("mmap is not supported on this platform")
+
+// mappedFile is a read-only memory mapping of an input file. It implements io.Reader and
+// io.ReaderAt over the mapped bytes, and Close unmaps them before closing the file.
+type mappedFile struct {
+	*bytes.Reader
+	data   []byte
+	file   *os.File
+	closed bool
+}
+
+// mapInput maps file into memory. On failure the file is left open and positioned at its start,
+// so the caller can fall back to reading it.
+func mapInput(file *os.File) (*mappedFile, error) {
+	if !MMAP_INPUTS {
+		return nil, errMmapUnsupported
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() || info.Size() == 0 || int64(int(info.Size())) != info.Size() {
+		return nil, errMmapUnsupported
+	}
+	data, err := mmap(file, int(info.Size()))
+	if err != nil {
+		return nil, err
+	}
+	return &mappedFile{Reader: bytes.NewReader(data), data: data, file: file}, nil
+}
+
+func (m *mappedFile) Close() error {
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+	err := munmap(m.data)
+	m.data = nil
+	m.Reader = bytes.NewReader(nil)
+	if closeErr := m.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package sp1
+
+import "os"
+
+func mmap(file *os.File, size int) ([]byte, error) {
+	return nil, errMmapUnsupported
+}
+
+func munmap(data []byte) error {
+	return nil
+}
@@ -0,0 +1,90 @@
+//go:build unix
+
+package sp1
+
+import (
+	"bufio"
+	"encoding/binary"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+// TestReadWitnessInputChild reads the witness named by SP1_WITNESS_CHILD, for
+// BenchmarkReadWitnessInputPeakRSS to measure the peak RSS of a process doing only that.
+func TestReadWitnessInputChild(t *testing.T) {
+	path := os.Getenv("SP1_WITNESS_CHILD")
+	if path == "" {
+		t.Skip("only run by BenchmarkReadWitnessInputPeakRSS")
+	}
+	MMAP_INPUTS = os.Getenv("SP1_WITNESS_CHILD_MMAP") == "true"
+	if _, err := ReadWitnessInput(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// BenchmarkReadWitnessInputPeakRSS reports the peak RSS of reading a synthetic binary witness of
+// SP1_BENCH_WITNESS_BYTES bytes, 1 GB by default, with and without mmap. The decoded witness holds
+// a decimal string per felt, roughly 3 times the size of the file, which bounds what mapping can
+// save: the file itself is never copied either way.
+func BenchmarkReadWitnessInputPeakRSS(b *testing.B) {
+	size := int64(1 << 30)
+	if s := os.Getenv("SP1_BENCH_WITNESS_BYTES"); s != "" {
+		var err error
+		if size, err = strconv.ParseInt(s, 10, 64); err != nil {
+			b.Fatal(err)
+		}
+	}
+	path := filepath.Join(b.TempDir(), "witness.bin")
+	writeSyntheticWitness(b, path, uint32((size-binaryWitnessHeader-64)/4))
+
+	for _, mmap := range []bool{true, false} {
+		b.Run("mmap="+strconv.FormatBool(mmap), func(b *testing.B) {
+			var peak int64
+			for i := 0; i < b.N; i++ {
+				cmd := exec.Command(os.Args[0], "-test.run=^TestReadWitnessInputChild$")
+				cmd.Env = append(os.Environ(), "SP1_WITNESS_CHILD="+path, "SP1_WITNESS_CHILD_MMAP="+strconv.FormatBool(mmap))
+				if output, err := cmd.CombinedOutput(); err != nil {
+					b.Fatalf("%v: %s", err, output)
+				}
+				maxRSS := cmd.ProcessState.SysUsage().(*syscall.Rusage).Maxrss
+				// Linux reports the peak RSS in kilobytes, macOS in bytes.
+				if runtime.GOOS != "darwin" {
+					maxRSS *= 1024
+				}
+				peak = max(peak, int64(maxRSS))
+			}
+			b.ReportMetric(float64(peak), "peak-rss-bytes")
+		})
+	}
+}
+
+// writeSyntheticWitness writes a binary witness of nFelts felts and no vars or exts.
+func writeSyntheticWitness(b *testing.B, path string, nFelts uint32) {
+	file, err := os.Create(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	writer := bufio.NewWriter(file)
+	header := []byte(binaryWitnessMagic)
+	for _, n := range []uint32{binaryWitnessVersion, 0, nFelts, 0} {
+		header = binary.LittleEndian.AppendUint32(header, n)
+	}
+	writer.Write(header)
+	var felt [4]byte
+	for i := uint32(0); i < nFelts; i++ {
+		binary.LittleEndian.PutUint32(felt[:], i%2013265921)
+		writer.Write(felt[:])
+	}
+	writer.Write(make([]byte, 64))
+	if err := writer.Flush(); err != nil {
+		b.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		b.Fatal(err)
+	}
+}
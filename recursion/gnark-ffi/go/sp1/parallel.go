@@ -0,0 +1,114 @@
+package sp1
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/constraint/solver"
+)
+
+// The number of goroutines parsing the constraints, converting the witness values and solving the
+// circuit, or GOMAXPROCS if it is not positive. The circuit itself is synthesized by a single
+// goroutine, since the gnark builders are not safe for concurrent use, so that the instructions of
+// independent regions cannot be added to the same constraint system concurrently.
+var PARALLELISM int = 0
+
+// The fewest witness values converted by a goroutine.
+const minParallelValues = 1024
+
+// parallelism returns the number of goroutines of PARALLELISM.
+func parallelism() int {
+	if PARALLELISM > 0 {
+		return PARALLELISM
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// solverParallelism returns the solver option running PARALLELISM tasks.
+func solverParallelism() solver.Option {
+	return solver.WithNbTasks(parallelism())
+}
+
+// parallelize splits [0, n) into consecutive ranges of at least minSize elements, and calls work
+// on each of them from up to parallelism goroutines. It returns the error of the first range that
+// failed.
+func parallelize(n int, minSize int, work func(start, end int) error) error {
+	nbTasks := min(parallelism(), max(1, n/minSize))
+	if nbTasks <= 1 {
+		return work(0, n)
+	}
+	errs := make([]error, nbTasks)
+	var wg sync.WaitGroup
+	for i := 0; i < nbTasks; i++ {
+		start, end := i*n/nbTasks, (i+1)*n/nbTasks
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = work(start, end)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// precomputeAssignment replaces the decimal values of the vars, felts and extension elements of
+// circuit by the field elements they stand for, parsed by PARALLELISM goroutines, so that the
+// witness is filled without parsing them one by one.
+func precomputeAssignment(circuit *Circuit) error {
+	parse := func(value any) (fr.Element, error) {
+		var element fr.Element
+		s, ok := value.(string)
+		if !ok {
+			_, err := element.SetInterface(value)
+			return element, err
+		}
+		_, err := element.SetString(s)
+		return element, err
+	}
+
+	err := parallelize(len(circuit.Vars), minParallelValues, func(start, end int) error {
+		for i := start; i < end; i++ {
+			element, err := parse(circuit.Vars[i])
+			if err != nil {
+				return fmt.Errorf("var %d: %w", i, err)
+			}
+			circuit.Vars[i] = element
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = parallelize(len(circuit.Felts), minParallelValues, func(start, end int) error {
+		for i := start; i < end; i++ {
+			element, err := parse(circuit.Felts[i].Value)
+			if err != nil {
+				return fmt.Errorf("felt %d: %w", i, err)
+			}
+			circuit.Felts[i].Value = element
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return parallelize(len(circuit.Exts), minParallelValues/4, func(start, end int) error {
+		for i := start; i < end; i++ {
+			for j := range circuit.Exts[i].Value {
+				element, err := parse(circuit.Exts[i].Value[j].Value)
+				if err != nil {
+					return fmt.Errorf("ext %d: %w", i, err)
+				}
+				circuit.Exts[i].Value[j].Value = element
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,94 @@
+package sp1
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+)
+
+func TestParallelize(t *testing.T) {
+	defer func(parallelism int) { PARALLELISM = parallelism }(PARALLELISM)
+	for _, parallelism := range []int{1, 3, 8} {
+		PARALLELISM = parallelism
+		for _, n := range []int{0, 1, 9, 100, 1001} {
+			var mu sync.Mutex
+			counts := make([]int, n)
+			nbRanges := 0
+			err := parallelize(n, 10, func(start, end int) error {
+				mu.Lock()
+				defer mu.Unlock()
+				nbRanges++
+				for i := start; i < end; i++ {
+					counts[i]++
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, count := range counts {
+				if count != 1 {
+					t.Fatalf("parallelism %d, n %d: element %d was visited %d times", parallelism, n, i, count)
+				}
+			}
+			if expected := min(parallelism, max(1, n/10)); nbRanges != expected {
+				t.Errorf("parallelism %d, n %d: %d ranges, expected %d", parallelism, n, nbRanges, expected)
+			}
+		}
+	}
+
+	PARALLELISM = 4
+	failure := errors.New("range failed")
+	err := parallelize(100, 10, func(start, end int) error {
+		if start > 0 {
+			return failure
+		}
+		return nil
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("expected the error of a range, got %v", err)
+	}
+}
+
+func TestPrecomputeAssignment(t *testing.T) {
+	defer func(parallelism int) { PARALLELISM = parallelism }(PARALLELISM)
+	witnessInput := WitnessInput{VkeyHash: "123", CommitedValuesDigest: "456"}
+	for i := 0; i < 5000; i++ {
+		witnessInput.Vars = append(witnessInput.Vars, strconv.Itoa(i*7919)+"000000000000000000000000000000")
+		witnessInput.Felts = append(witnessInput.Felts, strconv.Itoa(i))
+		if i%2 == 0 {
+			witnessInput.Exts = append(witnessInput.Exts, ExtValue{strconv.Itoa(i), "1", "2", strconv.Itoa(2013265920 - i)})
+		}
+	}
+
+	// The witness filled from the parsed values is the one filled from the decimal strings.
+	stringCircuit := NewCircuit(witnessInput)
+	expected, err := frontend.NewWitness(&stringCircuit, ecc.BN254.ScalarField())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, parallelism := range []int{1, 4} {
+		PARALLELISM = parallelism
+		circuit := NewCircuit(witnessInput)
+		if err := precomputeAssignment(&circuit); err != nil {
+			t.Fatal(err)
+		}
+		witness, err := frontend.NewWitness(&circuit, ecc.BN254.ScalarField())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(witness.Vector(), expected.Vector()) {
+			t.Fatalf("parallelism %d: the witness differs from the one of the decimal values", parallelism)
+		}
+	}
+
+	invalid := NewCircuit(WitnessInput{Vars: []string{"1", "x"}})
+	if err := precomputeAssignment(&invalid); err == nil {
+		t.Fatal("expected an invalid var to be reported")
+	}
+}
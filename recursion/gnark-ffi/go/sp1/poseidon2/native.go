@@ -0,0 +1,185 @@
+package poseidon2
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+)
+
+var (
+	nativeConstantsOnce sync.Once
+	nativeRC3           [NUM_EXTERNAL_ROUNDS + NUM_INTERNAL_ROUNDS][WIDTH]*big.Int
+	nativeRC16          [30][BABYBEAR_WIDTH]uint64
+)
+
+func initNativeConstants() {
+	for r := range RC3 {
+		for i := range RC3[r] {
+			value, ok := new(big.Int).SetString(fmt.Sprint(RC3[r][i]), 0)
+			if !ok {
+				panic(fmt.Errorf("invalid round constant %v", RC3[r][i]))
+			}
+			nativeRC3[r][i] = value
+		}
+	}
+	for r := range RC16 {
+		for i := range RC16[r] {
+			value, ok := new(big.Int).SetString(fmt.Sprint(RC16[r][i].Value), 10)
+			if !ok {
+				panic(fmt.Errorf("invalid round constant %v", RC16[r][i].Value))
+			}
+			nativeRC16[r][i] = value.Uint64() % babybear.MODULUS.Uint64()
+		}
+	}
+}
+
+// PermuteNative computes the same permutation as Poseidon2Chip.PermuteMut outside of a circuit.
+func PermuteNative(state *[WIDTH]*big.Int) {
+	nativeConstantsOnce.Do(initNativeConstants)
+	modulus := ecc.BN254.ScalarField()
+
+	matrixPermute := func() {
+		sum := new(big.Int).Add(state[0], state[1])
+		sum.Add(sum, state[2])
+		for i := 0; i < WIDTH; i++ {
+			state[i].Add(state[i], sum).Mod(state[i], modulus)
+		}
+	}
+	sboxP := func(x *big.Int) {
+		x.Exp(x, big.NewInt(DEGREE), modulus)
+	}
+	externalRound := func(r int) {
+		for i := 0; i < WIDTH; i++ {
+			state[i].Add(state[i], nativeRC3[r][i])
+			sboxP(state[i])
+		}
+		matrixPermute()
+	}
+
+	for i := 0; i < WIDTH; i++ {
+		state[i] = new(big.Int).Mod(state[i], modulus)
+	}
+	matrixPermute()
+
+	rounds := NUM_EXTERNAL_ROUNDS + NUM_INTERNAL_ROUNDS
+	roundsFBeginning := NUM_EXTERNAL_ROUNDS / 2
+	pEnd := roundsFBeginning + NUM_INTERNAL_ROUNDS
+	for r := 0; r < roundsFBeginning; r++ {
+		externalRound(r)
+	}
+	for r := roundsFBeginning; r < pEnd; r++ {
+		state[0].Add(state[0], nativeRC3[r][0])
+		sboxP(state[0])
+		sum := new(big.Int).Add(state[0], state[1])
+		sum.Add(sum, state[2])
+		for i := 0; i < WIDTH; i++ {
+			state[i].Mul(state[i], big.NewInt(int64(i/2+1))).Add(state[i], sum).Mod(state[i], modulus)
+		}
+	}
+	for r := pEnd; r < rounds; r++ {
+		externalRound(r)
+	}
+}
+
+// PermuteBabyBearNative computes the same permutation as Poseidon2BabyBearChip.PermuteMut outside
+// of a circuit.
+func PermuteBabyBearNative(state *[BABYBEAR_WIDTH]uint64) {
+	nativeConstantsOnce.Do(initNativeConstants)
+	p := babybear.MODULUS.Uint64()
+
+	sboxP := func(x uint64) uint64 {
+		x2 := x * x % p
+		x4 := x2 * x2 % p
+		return x4 * x2 % p * x % p
+	}
+	externalLinearLayer := func() {
+		for i := 0; i < BABYBEAR_WIDTH; i += 4 {
+			s := state[i : i+4]
+			t01 := s[0] + s[1]
+			t23 := s[2] + s[3]
+			t0123 := t01 + t23
+			t01123 := t0123 + s[1]
+			t01233 := t0123 + s[3]
+			s[3] = (t01233 + 2*s[0]) % p
+			s[1] = (t01123 + 2*s[2]) % p
+			s[0] = (t01123 + t01) % p
+			s[2] = (t01233 + t23) % p
+		}
+		var sums [4]uint64
+		for i := 0; i < BABYBEAR_WIDTH; i++ {
+			sums[i%4] += state[i]
+		}
+		for i := 0; i < BABYBEAR_WIDTH; i++ {
+			state[i] = (state[i] + sums[i%4]) % p
+		}
+	}
+	externalRound := func(r int) {
+		for i := 0; i < BABYBEAR_WIDTH; i++ {
+			state[i] = sboxP((state[i] + nativeRC16[r][i]) % p)
+		}
+		externalLinearLayer()
+	}
+
+	// The internal diagonal minus one, followed by the inverse of the Montgomery factor 2^32.
+	matInternalDiagM1 := [BABYBEAR_WIDTH]uint64{p - 2, 1, 2, 4, 8, 16, 32, 64, 128, 256, 512, 1024, 2048, 4096, 8192, 32768}
+	const montyInverse = 943718400
+
+	for i := 0; i < BABYBEAR_WIDTH; i++ {
+		state[i] %= p
+	}
+	externalLinearLayer()
+
+	rounds := BABYBEAR_NUM_EXTERNAL_ROUNDS + BABYBEAR_NUM_INTERNAL_ROUNDS
+	roundsFBeginning := BABYBEAR_NUM_EXTERNAL_ROUNDS / 2
+	pEnd := roundsFBeginning + BABYBEAR_NUM_INTERNAL_ROUNDS
+	for r := 0; r < roundsFBeginning; r++ {
+		externalRound(r)
+	}
+	for r := roundsFBeginning; r < pEnd; r++ {
+		state[0] = sboxP((state[0] + nativeRC16[r][0]) % p)
+		var sum uint64
+		for i := 0; i < BABYBEAR_WIDTH; i++ {
+			sum += state[i]
+		}
+		sum %= p
+		for i := 0; i < BABYBEAR_WIDTH; i++ {
+			state[i] = (state[i]*matInternalDiagM1[i] + sum) % p * montyInverse % p
+		}
+	}
+	for r := pEnd; r < rounds; r++ {
+		externalRound(r)
+	}
+}
+
+const BABYBEAR_RATE = 8
+const BABYBEAR_DIGEST_SIZE = 8
+
+// HashBabyBearNative hashes canonical BabyBear elements with the padding-free Poseidon2 sponge used
+// by the SP1 prover: each chunk of BABYBEAR_RATE inputs overwrites the front of the state, which is
+// then permuted, and the digest is the front of the final state.
+func HashBabyBearNative(inputs []uint64) [BABYBEAR_DIGEST_SIZE]uint64 {
+	var state [BABYBEAR_WIDTH]uint64
+	for start := 0; start < len(inputs); start += BABYBEAR_RATE {
+		copy(state[:BABYBEAR_RATE], inputs[start:min(start+BABYBEAR_RATE, len(inputs))])
+		PermuteBabyBearNative(&state)
+	}
+	var digest [BABYBEAR_DIGEST_SIZE]uint64
+	copy(digest[:], state[:BABYBEAR_DIGEST_SIZE])
+	return digest
+}
+
+// CompressBabyBearNative compresses two digests with the truncated Poseidon2 permutation used by
+// the SP1 prover's Merkle trees: the state is the concatenation of the digests, and the output is
+// the front of the permuted state.
+func CompressBabyBearNative(left, right [BABYBEAR_DIGEST_SIZE]uint64) [BABYBEAR_DIGEST_SIZE]uint64 {
+	var state [BABYBEAR_WIDTH]uint64
+	copy(state[:BABYBEAR_DIGEST_SIZE], left[:])
+	copy(state[BABYBEAR_DIGEST_SIZE:], right[:])
+	PermuteBabyBearNative(&state)
+	var digest [BABYBEAR_DIGEST_SIZE]uint64
+	copy(digest[:], state[:BABYBEAR_DIGEST_SIZE])
+	return digest
+}
@@ -1,6 +1,9 @@
 package poseidon2
 
 import (
+	"fmt"
+
+	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark/frontend"
 )
 
@@ -15,7 +18,13 @@ type Poseidon2Chip struct {
 	zero, one             frontend.Variable
 }
 
+// NewChip returns the Poseidon2 chip of api. Its round constants are those of the BN254 scalar
+// field the SP1 prover hashes with, so it panics over another field, where it would compute another
+// permutation.
 func NewChip(api frontend.API) *Poseidon2Chip {
+	if field := api.Compiler().Field(); field.Cmp(ecc.BN254.ScalarField()) != 0 {
+		panic(fmt.Sprintf("the Poseidon2 constants are those of BN254, not of the field of modulus %s", field))
+	}
 	return &Poseidon2Chip{
 		api: api,
 		internal_linear_layer: [WIDTH]frontend.Variable{
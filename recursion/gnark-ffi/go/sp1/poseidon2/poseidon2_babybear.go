@@ -15,14 +15,18 @@ type Poseidon2BabyBearChip struct {
 	fieldApi *babybear.Chip
 }
 
-func NewBabyBearChip(api frontend.API) *Poseidon2BabyBearChip {
+// NewBabyBearChip returns a Poseidon2 chip over BabyBear, whose field chip is created with opts.
+// With babybear.WithTrace, the permutations are traced as "Poseidon2".
+func NewBabyBearChip(api frontend.API, opts ...babybear.ChipOption) *Poseidon2BabyBearChip {
 	return &Poseidon2BabyBearChip{
 		api:      api,
-		fieldApi: babybear.NewChip(api),
+		fieldApi: babybear.NewChip(api, opts...),
 	}
 }
 
 func (p *Poseidon2BabyBearChip) PermuteMut(state *[BABYBEAR_WIDTH]babybear.Variable) {
+	defer p.fieldApi.TraceOperation("Poseidon2")()
+
 	// The initial linear layer.
 	p.externalLinearLayer(state)
 
@@ -51,6 +55,32 @@ func (p *Poseidon2BabyBearChip) PermuteMut(state *[BABYBEAR_WIDTH]babybear.Varia
 	}
 }
 
+// Hash is the circuit counterpart of HashBabyBearNative.
+func (p *Poseidon2BabyBearChip) Hash(inputs []babybear.Variable) [BABYBEAR_DIGEST_SIZE]babybear.Variable {
+	var state [BABYBEAR_WIDTH]babybear.Variable
+	for i := range state {
+		state[i] = babybear.NewF("0")
+	}
+	for start := 0; start < len(inputs); start += BABYBEAR_RATE {
+		copy(state[:BABYBEAR_RATE], inputs[start:min(start+BABYBEAR_RATE, len(inputs))])
+		p.PermuteMut(&state)
+	}
+	var digest [BABYBEAR_DIGEST_SIZE]babybear.Variable
+	copy(digest[:], state[:BABYBEAR_DIGEST_SIZE])
+	return digest
+}
+
+// Compress is the circuit counterpart of CompressBabyBearNative.
+func (p *Poseidon2BabyBearChip) Compress(left, right [BABYBEAR_DIGEST_SIZE]babybear.Variable) [BABYBEAR_DIGEST_SIZE]babybear.Variable {
+	var state [BABYBEAR_WIDTH]babybear.Variable
+	copy(state[:BABYBEAR_DIGEST_SIZE], left[:])
+	copy(state[BABYBEAR_DIGEST_SIZE:], right[:])
+	p.PermuteMut(&state)
+	var digest [BABYBEAR_DIGEST_SIZE]babybear.Variable
+	copy(digest[:], state[:BABYBEAR_DIGEST_SIZE])
+	return digest
+}
+
 func (p *Poseidon2BabyBearChip) addRc(state *[BABYBEAR_WIDTH]babybear.Variable, rc [BABYBEAR_WIDTH]babybear.Variable) {
 	for i := 0; i < BABYBEAR_WIDTH; i++ {
 		state[i] = p.fieldApi.AddF(state[i], rc[i])
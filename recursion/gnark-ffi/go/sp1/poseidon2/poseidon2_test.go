@@ -1,12 +1,15 @@
 package poseidon2
 
 import (
+	"math/big"
 	"testing"
 
 	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark/backend"
 	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/scs"
 	"github.com/consensys/gnark/test"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
 )
 
 type TestPoseidon2Circuit struct {
@@ -50,3 +53,189 @@ func TestPoseidon2(t *testing.T) {
 	witness = TestPoseidon2Circuit{Input: input, ExpectedOutput: expected_output}
 	assert.ProverSucceeded(&circuit, &witness, test.WithCurves(ecc.BN254), test.WithBackends(backend.PLONK))
 }
+
+func TestPoseidon2OtherField(t *testing.T) {
+	if _, err := frontend.Compile(ecc.BLS12_381.ScalarField(), scs.NewBuilder, &TestPoseidon2Circuit{}); err == nil {
+		t.Fatal("expected the BN254 permutation to be refused over BLS12-381")
+	}
+}
+
+func TestPermuteNative(t *testing.T) {
+	state := [WIDTH]*big.Int{big.NewInt(0), big.NewInt(0), big.NewInt(0)}
+	PermuteNative(&state)
+
+	expected := [WIDTH]string{
+		"2ed1da00b14d635bd35b88ab49390d5c13c90da7e9e3a5f1ea69cd87a0aa3e82",
+		"1e21e979cc3fd844b88c2016fd18f4db07a698aa27deca67ca509f5b0a4480d0",
+		"2c40d0115da2c9b55553b231be55295f411e628ed0cd0e187917066515f0a060",
+	}
+	for i := 0; i < WIDTH; i++ {
+		if state[i].Text(16) != expected[i] {
+			t.Errorf("state[%d] = %s, expected %s", i, state[i].Text(16), expected[i])
+		}
+	}
+}
+
+type TestPoseidon2BabyBearCircuit struct {
+	Input, ExpectedOutput [BABYBEAR_WIDTH]frontend.Variable `gnark:",public"`
+}
+
+func (circuit *TestPoseidon2BabyBearCircuit) Define(api frontend.API) error {
+	poseidon2Chip := NewBabyBearChip(api)
+	fieldAPI := babybear.NewChip(api)
+
+	var state [BABYBEAR_WIDTH]babybear.Variable
+	for i := 0; i < BABYBEAR_WIDTH; i++ {
+		state[i] = babybear.Variable{Value: circuit.Input[i], NbBits: 31}
+	}
+
+	poseidon2Chip.PermuteMut(&state)
+
+	for i := 0; i < BABYBEAR_WIDTH; i++ {
+		fieldAPI.AssertIsEqualF(babybear.Variable{Value: circuit.ExpectedOutput[i], NbBits: 31}, state[i])
+	}
+
+	return nil
+}
+
+func TestPermuteBabyBearNative(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	var state [BABYBEAR_WIDTH]uint64
+	var input, expectedOutput [BABYBEAR_WIDTH]frontend.Variable
+	for i := 0; i < BABYBEAR_WIDTH; i++ {
+		state[i] = uint64(i) * 123456789 % babybear.MODULUS.Uint64()
+		input[i] = state[i]
+	}
+	PermuteBabyBearNative(&state)
+	for i := 0; i < BABYBEAR_WIDTH; i++ {
+		expectedOutput[i] = state[i]
+	}
+
+	circuit := TestPoseidon2BabyBearCircuit{Input: input, ExpectedOutput: expectedOutput}
+	witness := TestPoseidon2BabyBearCircuit{Input: input, ExpectedOutput: expectedOutput}
+	assert.SolvingSucceeded(&circuit, &witness, test.WithCurves(ecc.BN254), test.WithBackends(backend.PLONK))
+}
+
+type TestHashBabyBearCircuit struct {
+	Input          [20]frontend.Variable                   `gnark:",public"`
+	ExpectedOutput [BABYBEAR_DIGEST_SIZE]frontend.Variable `gnark:",public"`
+}
+
+func (circuit *TestHashBabyBearCircuit) Define(api frontend.API) error {
+	poseidon2Chip := NewBabyBearChip(api)
+	fieldAPI := babybear.NewChip(api)
+
+	var state [BABYBEAR_WIDTH]babybear.Variable
+	for i := 0; i < BABYBEAR_WIDTH; i++ {
+		state[i] = babybear.NewF("0")
+	}
+	for start := 0; start < len(circuit.Input); start += BABYBEAR_RATE {
+		for i := start; i < len(circuit.Input) && i < start+BABYBEAR_RATE; i++ {
+			state[i-start] = babybear.Variable{Value: circuit.Input[i], NbBits: 31}
+		}
+		poseidon2Chip.PermuteMut(&state)
+	}
+
+	for i := 0; i < BABYBEAR_DIGEST_SIZE; i++ {
+		fieldAPI.AssertIsEqualF(babybear.Variable{Value: circuit.ExpectedOutput[i], NbBits: 31}, state[i])
+	}
+
+	return nil
+}
+
+func TestHashBabyBearNative(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	inputs := make([]uint64, 20)
+	var input [20]frontend.Variable
+	for i := range inputs {
+		inputs[i] = uint64(i+1) * 987654321 % babybear.MODULUS.Uint64()
+		input[i] = inputs[i]
+	}
+	digest := HashBabyBearNative(inputs)
+	var expectedOutput [BABYBEAR_DIGEST_SIZE]frontend.Variable
+	for i := range digest {
+		expectedOutput[i] = digest[i]
+	}
+
+	// A partial last chunk keeps the tail of the previous state.
+	var state [BABYBEAR_WIDTH]uint64
+	copy(state[:], inputs[:8])
+	PermuteBabyBearNative(&state)
+	copy(state[:], inputs[8:16])
+	PermuteBabyBearNative(&state)
+	copy(state[:], inputs[16:])
+	PermuteBabyBearNative(&state)
+	for i := range digest {
+		if digest[i] != state[i] {
+			t.Fatalf("digest[%d] = %d, expected %d", i, digest[i], state[i])
+		}
+	}
+
+	circuit := TestHashBabyBearCircuit{Input: input, ExpectedOutput: expectedOutput}
+	witness := TestHashBabyBearCircuit{Input: input, ExpectedOutput: expectedOutput}
+	assert.SolvingSucceeded(&circuit, &witness, test.WithCurves(ecc.BN254), test.WithBackends(backend.PLONK))
+}
+
+type TestTraceBabyBearCircuit struct {
+	Input [20]frontend.Variable
+	trace *babybear.Trace `gnark:"-"`
+}
+
+func (circuit *TestTraceBabyBearCircuit) Define(api frontend.API) error {
+	poseidon2Chip := NewBabyBearChip(api, babybear.WithTrace(circuit.trace))
+	fieldAPI := babybear.NewChip(api, babybear.WithTrace(circuit.trace))
+	inputs := make([]babybear.Variable, len(circuit.Input))
+	for i, v := range circuit.Input {
+		inputs[i] = babybear.Variable{Value: v, NbBits: 31}
+	}
+	digest := poseidon2Chip.Hash(inputs)
+	fieldAPI.AssertIsEqualF(digest[0], digest[1])
+	return nil
+}
+
+func TestTraceBabyBear(t *testing.T) {
+	trace := &babybear.Trace{}
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &TestTraceBabyBearCircuit{trace: trace})
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile, err := trace.Profile(ccs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The field operations of the permutations are part of them.
+	calls := make(map[string]int)
+	for _, p := range profile {
+		calls[p.Name] = p.Calls
+	}
+	if len(calls) != 4 || calls["Poseidon2"] != 3 || calls["AssertIsEqualF"] != 1 {
+		t.Errorf("expected 3 Poseidon2 and 1 AssertIsEqualF calls, got %+v", profile)
+	}
+}
+
+// plonky3ZeroPermutation is the permutation of the zero state by sp1_core::utils::inner_perm, the
+// Plonky3 Poseidon2 the SP1 prover hashes with.
+var plonky3ZeroPermutation = [BABYBEAR_WIDTH]uint64{
+	348670919, 1568590631, 1535107508, 186917780, 587749971, 1827585060, 1218809104, 691692291,
+	1480664293, 1491566329, 366224457, 490018300, 732772134, 560796067, 484676252, 405025962,
+}
+
+func TestPermuteBabyBearPlonky3(t *testing.T) {
+	var state [BABYBEAR_WIDTH]uint64
+	PermuteBabyBearNative(&state)
+	if state != plonky3ZeroPermutation {
+		t.Fatalf("the native permutation of zero is %v, expected %v", state, plonky3ZeroPermutation)
+	}
+
+	var input, expectedOutput [BABYBEAR_WIDTH]frontend.Variable
+	for i := range input {
+		input[i] = 0
+		expectedOutput[i] = plonky3ZeroPermutation[i]
+	}
+	assert := test.NewAssert(t)
+	circuit := TestPoseidon2BabyBearCircuit{Input: input, ExpectedOutput: expectedOutput}
+	witness := TestPoseidon2BabyBearCircuit{Input: input, ExpectedOutput: expectedOutput}
+	assert.SolvingSucceeded(&circuit, &witness, test.WithCurves(ecc.BN254), test.WithBackends(backend.PLONK))
+}
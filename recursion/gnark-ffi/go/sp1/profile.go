@@ -0,0 +1,143 @@
+package sp1
+
+import (
+	"math/big"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/logging"
+)
+
+// HintProfile is the number of calls to a hint and the time spent in them while solving.
+type HintProfile struct {
+	Name   string `json:"name"`
+	Calls  int64  `json:"calls"`
+	TimeUs int64  `json:"time_us"`
+}
+
+// SolverProfile breaks down the time spent solving the witness. Hints run concurrently, so their
+// time can exceed the solve time on several cores.
+type SolverProfile struct {
+	SolveMs    int64         `json:"solve_ms"`
+	HintCalls  int64         `json:"hint_calls"`
+	HintTimeUs int64         `json:"hint_time_us"`
+	Hints      []HintProfile `json:"hints"`
+}
+
+type hintCounter struct {
+	name  string
+	calls atomic.Int64
+	time  atomic.Int64
+}
+
+// hintProfiler wraps every registered hint to count its calls and time. Extension hints share the
+// ExtHintDispatcher solver hint, or FieldExtHintDispatcher, and are counted under their own names.
+type hintProfiler struct {
+	counters []*hintCounter
+	extM     sync.Mutex
+	ext      map[uint32]*hintCounter
+}
+
+func newHintProfiler() *hintProfiler {
+	return &hintProfiler{ext: make(map[uint32]*hintCounter)}
+}
+
+// hintWrapper returns an instrumented version of the registered hint fn.
+type hintWrapper func(id solver.HintID, fn solver.Hint) solver.Hint
+
+// instrumentHints returns the solver options replacing every registered hint with its version
+// instrumented by all the wrappers, the first one being the closest to the hint.
+func instrumentHints(wrappers ...hintWrapper) []solver.Option {
+	var opts []solver.Option
+	for _, fn := range solver.GetRegisteredHints() {
+		id := solver.GetHintID(fn)
+		for _, wrap := range wrappers {
+			fn = wrap(id, fn)
+		}
+		opts = append(opts, solver.OverrideHint(id, fn))
+	}
+	return opts
+}
+
+// wrap is a hintWrapper counting the calls and time of the hint. It must see the registered hint
+// to find its name.
+func (p *hintProfiler) wrap(id solver.HintID, fn solver.Hint) solver.Hint {
+	counter := &hintCounter{name: solver.GetHintName(fn)}
+	p.counters = append(p.counters, counter)
+	// The key of the extension hint is the first input of the BabyBear dispatcher, and follows the
+	// modulus of the field for the generic one.
+	keyIndex := -1
+	switch id {
+	case solver.GetHintID(babybear.ExtHintDispatcher):
+		keyIndex = 0
+	case solver.GetHintID(babybear.FieldExtHintDispatcher):
+		keyIndex = 1
+	}
+	return func(mod *big.Int, inputs []*big.Int, results []*big.Int) error {
+		start := time.Now()
+		err := fn(mod, inputs, results)
+		elapsed := time.Since(start)
+		c := counter
+		if keyIndex >= 0 && len(inputs) > keyIndex {
+			c = p.extCounter(uint32(inputs[keyIndex].Uint64()), counter)
+		}
+		c.calls.Add(1)
+		c.time.Add(int64(elapsed))
+		return err
+	}
+}
+
+func (p *hintProfiler) extCounter(key uint32, dispatcher *hintCounter) *hintCounter {
+	name, ok := babybear.ExtHintName(key)
+	if !ok {
+		return dispatcher
+	}
+	p.extM.Lock()
+	defer p.extM.Unlock()
+	counter, ok := p.ext[key]
+	if !ok {
+		counter = &hintCounter{name: name}
+		p.ext[key] = counter
+	}
+	return counter
+}
+
+// profile returns the hints that were called, the most expensive first.
+func (p *hintProfiler) profile(solve time.Duration) *SolverProfile {
+	profile := &SolverProfile{SolveMs: solve.Milliseconds()}
+	var hintTime time.Duration
+	counters := p.counters
+	for _, counter := range p.ext {
+		counters = append(counters, counter)
+	}
+	for _, counter := range counters {
+		calls := counter.calls.Load()
+		if calls == 0 {
+			continue
+		}
+		elapsed := time.Duration(counter.time.Load())
+		hintTime += elapsed
+		profile.HintCalls += calls
+		profile.Hints = append(profile.Hints, HintProfile{Name: counter.name, Calls: calls, TimeUs: elapsed.Microseconds()})
+	}
+	profile.HintTimeUs = hintTime.Microseconds()
+	sort.Slice(profile.Hints, func(i, j int) bool {
+		if profile.Hints[i].TimeUs != profile.Hints[j].TimeUs {
+			return profile.Hints[i].TimeUs > profile.Hints[j].TimeUs
+		}
+		return profile.Hints[i].Name < profile.Hints[j].Name
+	})
+	return profile
+}
+
+// Log logs the solve time and the calls and time of every hint.
+func (p *SolverProfile) Log(logger logging.Logger) {
+	logger.Info("solver profile", "solve_ms", p.SolveMs, "hint_calls", p.HintCalls, "hint_time_us", p.HintTimeUs)
+	for _, hint := range p.Hints {
+		logger.Info("solver profile", "hint", hint.Name, "calls", hint.Calls, "time_us", hint.TimeUs)
+	}
+}
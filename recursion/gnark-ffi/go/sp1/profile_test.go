@@ -0,0 +1,52 @@
+package sp1
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestProveWithProfile(t *testing.T) {
+	dataDir := newDevDataDir(t, "testdata/hints_constraints.json", "testdata/basic_witness.json")
+	Build(dataDir)
+	proof, err := ProveWithProfile(dataDir, filepath.Join(dataDir, WITNESS_JSON_FILE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(dataDir, proof.RawProof, "123", "456"); err != nil {
+		t.Fatal(err)
+	}
+
+	profile := proof.SolverProfile
+	if profile == nil {
+		t.Fatal("expected a solver profile")
+	}
+	calls := make(map[string]int64)
+	var nbCalls, timeUs int64
+	for _, hint := range profile.Hints {
+		if hint.Calls <= 0 || hint.TimeUs < 0 {
+			t.Fatalf("unexpected hint profile %+v", hint)
+		}
+		calls[hint.Name] = hint.Calls
+		nbCalls += hint.Calls
+		timeUs += hint.TimeUs
+	}
+	// Three InvE and one DivE, each solved with a single hint call.
+	if calls["InvE"] != 3 || calls["DivE"] != 1 {
+		t.Fatalf("expected 3 InvE and 1 DivE hint calls, got %d and %d in %+v", calls["InvE"], calls["DivE"], profile.Hints)
+	}
+	if nbCalls != profile.HintCalls {
+		t.Fatalf("hint calls sum to %d, expected %d", nbCalls, profile.HintCalls)
+	}
+	// Per-hint times are rounded down individually.
+	if timeUs > profile.HintTimeUs || profile.HintTimeUs > timeUs+int64(len(profile.Hints)) {
+		t.Fatalf("hint times sum to %dus, expected about %dus", timeUs, profile.HintTimeUs)
+	}
+
+	proof, err = Prove(dataDir, filepath.Join(dataDir, WITNESS_JSON_FILE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proof.SolverProfile != nil {
+		t.Fatal("expected Prove not to profile the solver")
+	}
+}
@@ -0,0 +1,116 @@
+package sp1
+
+import (
+	"context"
+	"math/big"
+	"sync/atomic"
+	"time"
+
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/logging"
+)
+
+// The minimum time between two progress reports within a stage.
+var PROGRESS_INTERVAL time.Duration = 200 * time.Millisecond
+
+// ProgressFunc receives the progress of a stage of Prove: done out of total steps. It is called
+// with done = 0 when the stage starts and done = total when it ends. The stages solving the witness
+// count one step per hint of the circuit, and report the number of hints solved at most once every
+// PROGRESS_INTERVAL, possibly from a solver goroutine. The prove stage has one more step for the
+// proof itself, which gnark does not report on. The other stages have a single step.
+type ProgressFunc func(stage string, done, total int)
+
+// ProveOptions configures ProveWithOptions.
+type ProveOptions struct {
+	// System is the proving system the data directory was built for, PLONK by default. With
+	// Groth16, only the Logger is used among the other options, and the proof only stops for its
+	// context before it starts.
+	System ProvingSystem
+	// SelfTest runs artifacts.SelfTest on the data directory before anything else, so that
+	// artifacts of different builds are reported before a long proof fails.
+	SelfTest bool
+	// Check runs CheckWitness before anything else but SelfTest, so that a witness that does not
+	// satisfy the circuit fails with the failing instruction instead of an opaque solver error.
+	Check bool
+	// Profile solves the witness once with instrumented hints before proving, see ProveWithProfile.
+	Profile bool
+	// Progress is called at the boundaries of the stages self_test (with SelfTest only), check
+	// (with Check only), load, read_witness, witness, solve (with Profile only), prove, verify and
+	// serialize, in this order.
+	Progress ProgressFunc
+	// Logger receives the logs of the proof, including the values printed by the circuit. The
+	// default logger is used if it is nil.
+	Logger logging.Logger
+}
+
+// progressReporter reports the number of hints solved during a stage.
+type progressReporter struct {
+	progress ProgressFunc
+	stage    string
+	total    int
+	done     atomic.Int64
+	last     atomic.Int64
+}
+
+// stages starts the phases of Prove, recording them in metrics and reporting their boundaries.
+type stages struct {
+	ctx      context.Context
+	metrics  *Metrics
+	progress ProgressFunc
+}
+
+// start begins a stage of total steps and returns the function ending it. It does not start the
+// stage once ctx is done, and returns the error of ctx wrapped with the name of the stage instead.
+func (s stages) start(name string, total int) (func(), error) {
+	if err := checkContext(s.ctx, name); err != nil {
+		return nil, err
+	}
+	end := s.metrics.Start(name)
+	s.report(name, 0, total)
+	return func() {
+		end()
+		s.report(name, total, total)
+	}, nil
+}
+
+func (s stages) report(name string, done, total int) {
+	if s.progress != nil {
+		s.progress(name, done, total)
+	}
+}
+
+// hints returns a reporter of the hints solved during a stage of total steps, or nil without a
+// ProgressFunc.
+func (s stages) hints(name string, total int) *progressReporter {
+	if s.progress == nil {
+		return nil
+	}
+	return &progressReporter{progress: s.progress, stage: name, total: total}
+}
+
+// wrap is a hintWrapper counting the solved hints.
+func (r *progressReporter) wrap(_ solver.HintID, fn solver.Hint) solver.Hint {
+	return func(mod *big.Int, inputs []*big.Int, results []*big.Int) error {
+		err := fn(mod, inputs, results)
+		done := r.done.Add(1)
+		now := time.Now().UnixNano()
+		last := r.last.Load()
+		if now-last >= int64(PROGRESS_INTERVAL) && r.last.CompareAndSwap(last, now) {
+			r.progress(r.stage, int(min(done, int64(r.total-1))), r.total)
+		}
+		return err
+	}
+}
+
+// cancelHint returns a hintWrapper failing the hint once ctx is done, so that solving the
+// witness, which takes most of the time of a proof, stops promptly.
+func cancelHint(ctx context.Context) hintWrapper {
+	return func(_ solver.HintID, fn solver.Hint) solver.Hint {
+		return func(mod *big.Int, inputs []*big.Int, results []*big.Int) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return fn(mod, inputs, results)
+		}
+	}
+}
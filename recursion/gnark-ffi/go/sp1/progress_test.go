@@ -0,0 +1,102 @@
+package sp1
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type progressEvent struct {
+	stage       string
+	done, total int
+}
+
+func TestProveProgress(t *testing.T) {
+	dataDir := newDevDataDir(t, "testdata/hints_constraints.json", "testdata/basic_witness.json")
+	Build(dataDir)
+
+	interval := PROGRESS_INTERVAL
+	PROGRESS_INTERVAL = 0
+	defer func() { PROGRESS_INTERVAL = interval }()
+
+	for _, profile := range []bool{false, true} {
+		var m sync.Mutex
+		var events []progressEvent
+		opts := ProveOptions{Profile: profile, Progress: func(stage string, done, total int) {
+			m.Lock()
+			defer m.Unlock()
+			events = append(events, progressEvent{stage, done, total})
+		}}
+		proof, err := ProveWithOptions(dataDir, filepath.Join(dataDir, WITNESS_JSON_FILE), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Verify(dataDir, proof.RawProof, "123", "456"); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []string{"load", "read_witness", "witness", "prove", "verify", "serialize"}
+		if profile {
+			expected = []string{"load", "read_witness", "witness", "solve", "prove", "verify", "serialize"}
+		}
+		var stages []string
+		var open *progressEvent
+		checkpoints := make(map[string]int)
+		for i, event := range events {
+			switch {
+			case event.done == 0:
+				if open != nil {
+					t.Fatalf("%s started before %s ended", event.stage, open.stage)
+				}
+				open = &events[i]
+				stages = append(stages, event.stage)
+			case open == nil || event.stage != open.stage || event.total != open.total:
+				t.Fatalf("unexpected event %+v", event)
+			case event.done == event.total:
+				open = nil
+			case event.done > 0 && event.done < event.total:
+				checkpoints[event.stage]++
+			default:
+				t.Fatalf("unexpected event %+v", event)
+			}
+		}
+		if !reflect.DeepEqual(stages, expected) || open != nil {
+			t.Fatalf("expected stages %v, got %v", expected, stages)
+		}
+		if checkpoints["prove"] == 0 || (profile && checkpoints["solve"] == 0) {
+			t.Fatalf("expected checkpoints while solving, got %v", checkpoints)
+		}
+	}
+}
+
+func TestProveCancelled(t *testing.T) {
+	dataDir := newDevDataDir(t, "testdata/hints_constraints.json", "testdata/basic_witness.json")
+	Build(dataDir)
+	witnessPath := filepath.Join(dataDir, WITNESS_JSON_FILE)
+
+	expired, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+	if _, err := ProveContext(expired, dataDir, witnessPath, ProveOptions{}); !errors.Is(err, context.DeadlineExceeded) || !strings.HasPrefix(err.Error(), "load: ") {
+		t.Errorf("expected the proof to stop before loading, got %v", err)
+	}
+
+	// Cancelling while solving the witness fails its next hint.
+	for _, stage := range []string{"solve", "prove"} {
+		ctx, cancel := context.WithCancel(context.Background())
+		opts := ProveOptions{Profile: true, Progress: func(s string, done, total int) {
+			if s == stage && done == 0 {
+				cancel()
+			}
+		}}
+		_, err := ProveContext(ctx, dataDir, witnessPath, opts)
+		cancel()
+		if !errors.Is(err, context.Canceled) || !strings.HasPrefix(err.Error(), stage+": ") {
+			t.Errorf("%s: expected the proof to be cancelled, got %v", stage, err)
+		}
+	}
+}
@@ -2,81 +2,321 @@ package sp1
 
 import (
 	"bufio"
-	"encoding/json"
+	"context"
+	"fmt"
 	"os"
+	"time"
 
 	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/backend"
 	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint"
+	cs "github.com/consensys/gnark/constraint/bn254"
+	"github.com/consensys/gnark/constraint/solver"
 	"github.com/consensys/gnark/frontend"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/artifacts"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/logging"
 )
 
-func Prove(dataDir string, witnessPath string) Proof {
+func Prove(dataDir string, witnessPath string) (Proof, error) {
+	return ProveWithOptions(dataDir, witnessPath, ProveOptions{})
+}
+
+// ProveWithProfile is Prove, and also solves the witness once with every hint instrumented before
+// proving. The breakdown of the solve time is logged and attached to the proof. Since the prover
+// solves the witness again, proving takes about one solve longer than with Prove.
+func ProveWithProfile(dataDir string, witnessPath string) (Proof, error) {
+	return ProveWithOptions(dataDir, witnessPath, ProveOptions{Profile: true})
+}
+
+// ProveWithOptions is Prove, with optional profiling, progress reporting and logging.
+func ProveWithOptions(dataDir string, witnessPath string, opts ProveOptions) (Proof, error) {
+	return ProveContext(context.Background(), dataDir, witnessPath, opts)
+}
+
+// ProveContext is ProveWithOptions, stopping once ctx is done, between two stages or while solving
+// the witness, with the error of ctx wrapped with the name of the stage.
+func ProveContext(ctx context.Context, dataDir string, witnessPath string, opts ProveOptions) (Proof, error) {
 	// Sanity check the required arguments have been provided.
 	if dataDir == "" {
-		panic("dataDirStr is required")
+		return Proof{}, fmt.Errorf("dataDirStr is required")
 	}
-	os.Setenv("CONSTRAINTS_JSON", dataDir+"/"+CONSTRAINTS_JSON_FILE)
+	if isGroth16, err := opts.System.isGroth16(); err != nil {
+		return Proof{}, err
+	} else if isGroth16 {
+		if err := checkContext(ctx, "load"); err != nil {
+			return Proof{}, err
+		}
+		return ProveGroth16(dataDir, witnessPath, opts)
+	}
+	constraintsPath := resolveInput(dataDir + "/" + CONSTRAINTS_JSON_FILE)
+	logger := logging.OrDefault(opts.Logger)
 
-	// Read the R1CS.
-	scsFile, err := os.Open(dataDir + "/" + CIRCUIT_PATH)
-	if err != nil {
-		panic(err)
+	metrics := NewMetrics(logger)
+	stages := stages{ctx: ctx, metrics: metrics, progress: opts.Progress}
+
+	// Check the artifacts against each other.
+	if opts.SelfTest {
+		endSelfTest, err := stages.start("self_test", 1)
+		if err != nil {
+			return Proof{}, err
+		}
+		if err := artifacts.SelfTest(dataDir); err != nil {
+			return Proof{}, fmt.Errorf("self_test: %w", err)
+		}
+		endSelfTest()
 	}
-	scs := plonk.NewCS(ecc.BN254)
-	scs.ReadFrom(scsFile)
 
-	// Read the proving key.
-	pkFile, err := os.Open(dataDir + "/" + PK_PATH)
-	if err != nil {
-		panic(err)
+	// Check the witness against the interpreted circuit.
+	if opts.Check {
+		endCheck, err := stages.start("check", 1)
+		if err != nil {
+			return Proof{}, err
+		}
+		if err := CheckWitness(constraintsPath, witnessPath); err != nil {
+			return Proof{}, err
+		}
+		endCheck()
 	}
-	pk := plonk.NewProvingKey(ecc.BN254)
-	bufReader := bufio.NewReaderSize(pkFile, 1024*1024)
-	pk.UnsafeReadFrom(bufReader)
 
-	// Read the verifier key.
-	vkFile, err := os.Open(dataDir + "/" + VK_PATH)
+	// Read the R1CS, the proving key and the verifier key.
+	endLoad, err := stages.start("load", 1)
 	if err != nil {
-		panic(err)
+		return Proof{}, err
 	}
-	vk := plonk.NewVerifyingKey(ecc.BN254)
-	vk.ReadFrom(vkFile)
+	prover, err := NewProver(dataDir)
+	if err != nil {
+		return Proof{}, fmt.Errorf("load: %w", err)
+	}
+	endLoad()
 
 	// Read the file.
-	data, err := os.ReadFile(witnessPath)
+	endReadWitness, err := stages.start("read_witness", 1)
+	if err != nil {
+		return Proof{}, err
+	}
+	witnessInput, err := ReadWitnessInput(witnessPath)
 	if err != nil {
-		panic(err)
+		return Proof{}, fmt.Errorf("read_witness: %w", err)
 	}
 
-	// Deserialize the JSON data into a slice of Instruction structs
-	var witnessInput WitnessInput
-	err = json.Unmarshal(data, &witnessInput)
+	endReadWitness()
+
+	return prover.prove(stages, logger, witnessInput, opts)
+}
+
+// Prover proves witnesses with the circuit and keys of a data directory, which it reads once. It
+// is safe for concurrent use.
+type Prover struct {
+	scs constraint.ConstraintSystem
+	pk  plonk.ProvingKey
+	vk  plonk.VerifyingKey
+}
+
+// NewProver reads the circuit and keys built in dataDir.
+func NewProver(dataDir string) (*Prover, error) {
+	scs, pk, vk, err := readProvingArtifacts(dataDir)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	return &Prover{scs: scs, pk: pk, vk: vk}, nil
+}
+
+// Prove is ProveContext with the artifacts of the prover, for witnessInput. The SelfTest and Check
+// options are ignored, so the stages start with witness.
+func (p *Prover) Prove(ctx context.Context, witnessInput WitnessInput, opts ProveOptions) (Proof, error) {
+	logger := logging.OrDefault(opts.Logger)
+	stages := stages{ctx: ctx, metrics: NewMetrics(logger), progress: opts.Progress}
+	return p.prove(stages, logger, witnessInput, opts)
+}
+
+// prove runs the stages of ProveContext from witness on.
+func (p *Prover) prove(stages stages, logger logging.Logger, witnessInput WitnessInput, opts ProveOptions) (Proof, error) {
+	ctx, metrics := stages.ctx, stages.metrics
+	scs, pk, vk := p.scs, p.pk, p.vk
 
 	// Generate the witness.
-	assignment := NewCircuit(witnessInput)
-	witness, err := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
+	endWitness, err := stages.start("witness", 1)
 	if err != nil {
-		panic(err)
+		return Proof{}, err
 	}
+	assignment, witness, err := newFullWitness(scs, witnessInput)
+	if err != nil {
+		return Proof{}, fmt.Errorf("witness: %w", err)
+	}
+	logger.Debug("witness assigned", "mode", publicInputsMode(scs.GetNbPublicVariables()), "vars", len(witnessInput.Vars), "felts", len(witnessInput.Felts), "exts", len(witnessInput.Exts))
 	publicWitness, err := witness.Public()
 	if err != nil {
-		panic(err)
+		return Proof{}, fmt.Errorf("witness: %w", err)
+	}
+
+	endWitness()
+
+	nbHints := 0
+	if opts.Progress != nil {
+		nbHints = countHints(scs)
+	}
+
+	// The solver logs the values printed by the circuit, and fails the hints once ctx is done.
+	solverLogger := solver.WithLogger(logging.Zerolog(logger))
+	var cancel []hintWrapper
+	if ctx.Done() != nil {
+		cancel = append(cancel, cancelHint(ctx))
+	}
+
+	var solverProfile *SolverProfile
+	if opts.Profile {
+		endSolve, err := stages.start("solve", nbHints)
+		if err != nil {
+			return Proof{}, err
+		}
+		profiler := newHintProfiler()
+		wrappers := append([]hintWrapper{profiler.wrap}, cancel...)
+		if reporter := stages.hints("solve", nbHints); reporter != nil {
+			wrappers = append(wrappers, reporter.wrap)
+		}
+		start := time.Now()
+		if _, err := scs.Solve(witness, append(instrumentHints(wrappers...), solverLogger, solverParallelism())...); err != nil {
+			return Proof{}, fmt.Errorf("solve: %w", contextError(ctx, err))
+		}
+		solverProfile = profiler.profile(time.Since(start))
+		endSolve()
+		solverProfile.Log(logger)
 	}
 
 	// Generate the proof.
-	proof, err := plonk.Prove(scs, pk, witness)
+	endProve, err := stages.start("prove", nbHints+1)
 	if err != nil {
-		panic(err)
+		return Proof{}, err
 	}
+	wrappers := cancel
+	if reporter := stages.hints("prove", nbHints+1); reporter != nil {
+		wrappers = append(wrappers, reporter.wrap)
+	}
+	solverOpts := []solver.Option{solverLogger, solverParallelism()}
+	if len(wrappers) > 0 {
+		solverOpts = append(solverOpts, instrumentHints(wrappers...)...)
+	}
+	proverOpts := []backend.ProverOption{backend.WithSolverOptions(solverOpts...)}
+	proof, err := plonk.Prove(scs, pk, witness, proverOpts...)
+	if err != nil {
+		return Proof{}, fmt.Errorf("prove: %w", contextError(ctx, err))
+	}
+	endProve()
 
 	// Verify proof.
+	endVerify, err := stages.start("verify", 1)
+	if err != nil {
+		return Proof{}, err
+	}
 	err = plonk.Verify(proof, vk, publicWitness)
 	if err != nil {
-		panic(err)
+		return Proof{}, fmt.Errorf("verify: %w", err)
+	}
+	endVerify()
+
+	endSerialize, err := stages.start("serialize", 1)
+	if err != nil {
+		return Proof{}, err
+	}
+	sp1PlonkBn254Proof := NewSP1PlonkBn254Proof(&proof, witnessInput)
+	if hashed, ok := assignment.(*HashedCircuit); ok {
+		sp1PlonkBn254Proof.PublicInputHash = fmt.Sprint(hashed.PublicInputHash)
+	}
+	endSerialize()
+	sp1PlonkBn254Proof.Phases = metrics.Phases
+	sp1PlonkBn254Proof.SolverProfile = solverProfile
+	metrics.Log("proved")
+
+	return sp1PlonkBn254Proof, nil
+}
+
+// newFullWitness assigns the circuit of scs from witnessInput, and returns the assignment and its
+// witness. A witness with another number of values than the circuit was built with is rejected
+// here, where the counts can still be reported.
+func newFullWitness(scs constraint.ConstraintSystem, witnessInput WitnessInput) (frontend.Circuit, witness.Witness, error) {
+	nbPublic := nbPublicInputs(scs)
+	assignment, circuit, err := newModeCircuit(witnessInput, publicInputsMode(nbPublic))
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := precomputeAssignment(circuit); err != nil {
+		return nil, nil, err
+	}
+	fullWitness, err := frontend.NewWitness(assignment, ecc.BN254.ScalarField())
+	if err != nil {
+		return nil, nil, err
+	}
+	expected := nbPublic + scs.GetNbSecretVariables()
+	if size := len(fullWitness.Vector().(fr.Vector)); size != expected {
+		return nil, nil, fmt.Errorf("%d vars, %d felts and %d exts make %d witness values, but the circuit expects %d", len(witnessInput.Vars), len(witnessInput.Felts), len(witnessInput.Exts), size, expected)
+	}
+	return assignment, fullWitness, nil
+}
+
+// nbPublicInputs returns the number of public inputs of the circuit of scs. An R1CS counts the
+// constant wire among its public variables, but the witness does not hold it.
+func nbPublicInputs(scs constraint.ConstraintSystem) int {
+	if system, ok := scs.(*cs.R1CS); ok && system.Type == constraint.SystemR1CS {
+		return scs.GetNbPublicVariables() - 1
+	}
+	return scs.GetNbPublicVariables()
+}
+
+// contextError returns the error of ctx if it is done, since the solver reports a hint failing
+// because of it with an error that does not wrap it, and err otherwise.
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// readConstraintSystem reads the R1CS built in dataDir.
+func readConstraintSystem(dataDir string) (constraint.ConstraintSystem, error) {
+	scsFile, err := os.Open(dataDir + "/" + CIRCUIT_PATH)
+	if err != nil {
+		return nil, err
+	}
+	defer scsFile.Close()
+	scs := plonk.NewCS(ecc.BN254)
+	if _, err := scs.ReadFrom(bufio.NewReaderSize(scsFile, 1024*1024)); err != nil {
+		return nil, fmt.Errorf("%s: %w", scsFile.Name(), err)
+	}
+	return scs, nil
+}
+
+// readProvingArtifacts reads the R1CS, the proving key and the verifier key built in dataDir.
+func readProvingArtifacts(dataDir string) (constraint.ConstraintSystem, plonk.ProvingKey, plonk.VerifyingKey, error) {
+	scs, err := readConstraintSystem(dataDir)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Read the proving key.
+	pkFile, err := os.Open(dataDir + "/" + PK_PATH)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer pkFile.Close()
+	pk := plonk.NewProvingKey(ecc.BN254)
+	bufReader := bufio.NewReaderSize(pkFile, 1024*1024)
+	if _, err := pk.UnsafeReadFrom(bufReader); err != nil {
+		return nil, nil, nil, fmt.Errorf("%s: %w", pkFile.Name(), err)
+	}
+
+	// Read the verifier key.
+	vkFile, err := os.Open(dataDir + "/" + VK_PATH)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer vkFile.Close()
+	vk := plonk.NewVerifyingKey(ecc.BN254)
+	if _, err := vk.ReadFrom(vkFile); err != nil {
+		return nil, nil, nil, fmt.Errorf("%s: %w", vkFile.Name(), err)
 	}
 
-	return NewSP1PlonkBn254Proof(&proof, witnessInput)
+	return scs, pk, vk, nil
 }
@@ -0,0 +1,163 @@
+package sp1
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/consensys/gnark-crypto/ecc"
+)
+
+var PLONK_BN254_BACKEND string = "plonk_bn254"
+
+// COMMITTED_VALUES_DIGEST_BITS is the number of bits of the committed values digest kept by the
+// wrapper, which masks off the top 3 bits of the SHA-256 digest.
+var COMMITTED_VALUES_DIGEST_BITS int = 253
+
+// PublicValues are the public inputs of a proof in the format expected by on-chain tooling, with
+// each input encoded as a 0x-prefixed 32 byte big-endian hex string.
+type PublicValues struct {
+	VkeyHash              string `json:"vkey_hash"`
+	CommittedValuesDigest string `json:"committed_values_digest"`
+	// Set for proofs of a HashedCircuit, whose only public input it is.
+	PublicInputHash string `json:"public_input_hash,omitempty"`
+	Backend         string `json:"backend"`
+	VerifierVersion string `json:"verifier_version"`
+}
+
+// ExportPublicValues writes the public inputs of the proof to path as JSON, tagged with the version
+// of the SP1 verifier contract the proof is meant for.
+func (p *Proof) ExportPublicValues(path string, verifierVersion string) error {
+	vkeyHash, err := parseBN254(p.PublicInputs[0])
+	if err != nil {
+		return fmt.Errorf("invalid vkey hash: %w", err)
+	}
+	committedValuesDigest, err := parseBN254(p.PublicInputs[1])
+	if err != nil {
+		return fmt.Errorf("invalid committed values digest: %w", err)
+	}
+
+	publicValues := PublicValues{
+		VkeyHash:              encodeBytes32(vkeyHash),
+		CommittedValuesDigest: encodeBytes32(committedValuesDigest),
+		Backend:               PLONK_BN254_BACKEND,
+		VerifierVersion:       verifierVersion,
+	}
+	if p.PublicInputHash != "" {
+		publicInputHash, err := parseBN254(p.PublicInputHash)
+		if err != nil {
+			return fmt.Errorf("invalid public input hash: %w", err)
+		}
+		publicValues.PublicInputHash = encodeBytes32(publicInputHash)
+	}
+	data, err := json.MarshalIndent(publicValues, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// LoadPublicValues reads and validates public values written by ExportPublicValues.
+func LoadPublicValues(path string) (*PublicValues, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var publicValues PublicValues
+	if err := json.Unmarshal(data, &publicValues); err != nil {
+		return nil, fmt.Errorf("failed to parse public values %s: %w", path, err)
+	}
+	if publicValues.Backend != PLONK_BN254_BACKEND {
+		return nil, fmt.Errorf("unsupported backend %q", publicValues.Backend)
+	}
+	vkeyHash, committedValuesDigest, err := publicValues.Inputs()
+	if err != nil {
+		return nil, err
+	}
+	if publicValues.PublicInputHash != "" {
+		publicInputHash, err := decodeBytes32(publicValues.PublicInputHash)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public input hash: %w", err)
+		}
+		if publicInputHash.Cmp(ComputePublicInputHash(vkeyHash, committedValuesDigest)) != 0 {
+			return nil, fmt.Errorf("public input hash does not match the public values")
+		}
+	}
+	return &publicValues, nil
+}
+
+// Inputs returns the vkey hash and committed values digest as BN254 scalars.
+func (pv *PublicValues) Inputs() (*big.Int, *big.Int, error) {
+	vkeyHash, err := decodeBytes32(pv.VkeyHash)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid vkey hash: %w", err)
+	}
+	committedValuesDigest, err := decodeBytes32(pv.CommittedValuesDigest)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid committed values digest: %w", err)
+	}
+	return vkeyHash, committedValuesDigest, nil
+}
+
+// parseBN254 parses a decimal or 0x-prefixed hex string into a BN254 scalar.
+func parseBN254(s string) (*big.Int, error) {
+	value := new(big.Int)
+	var ok bool
+	// Only accept what gnark reads as the same number: it would reject a sign after the prefix and
+	// read a leading zero as an octal prefix.
+	if strings.HasPrefix(s, "0x") {
+		_, ok = value.SetString(s[2:], 16)
+		ok = ok && s[2] != '+' && s[2] != '-'
+	} else {
+		_, ok = value.SetString(s, 10)
+		ok = ok && value.String() == s
+	}
+	if !ok {
+		return nil, fmt.Errorf("%q is not a number", s)
+	}
+	if value.Sign() < 0 || value.Cmp(ecc.BN254.ScalarField()) >= 0 {
+		return nil, fmt.Errorf("%s is not in the BN254 scalar field", value)
+	}
+	return value, nil
+}
+
+func encodeBytes32(value *big.Int) string {
+	var buf [32]byte
+	value.FillBytes(buf[:])
+	return "0x" + hex.EncodeToString(buf[:])
+}
+
+func decodeBytes32(s string) (*big.Int, error) {
+	if !strings.HasPrefix(s, "0x") {
+		return nil, fmt.Errorf("%q is missing the 0x prefix", s)
+	}
+	data, err := hex.DecodeString(s[2:])
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != 32 {
+		return nil, fmt.Errorf("expected 32 bytes, got %d", len(data))
+	}
+	value := new(big.Int).SetBytes(data)
+	if value.Cmp(ecc.BN254.ScalarField()) >= 0 {
+		return nil, fmt.Errorf("%s is not in the BN254 scalar field", s)
+	}
+	return value, nil
+}
+
+// validateCommittedValuesDigest checks that a decimal or 0x-prefixed hex committed values digest
+// fits in COMMITTED_VALUES_DIGEST_BITS bits, as the circuit requires.
+func validateCommittedValuesDigest(s string) error {
+	digest, err := parseBN254(s)
+	if err != nil {
+		return fmt.Errorf("invalid committed values digest: %w", err)
+	}
+	if digest.BitLen() > COMMITTED_VALUES_DIGEST_BITS {
+		return fmt.Errorf("committed values digest %s does not fit in %d bits", digest, COMMITTED_VALUES_DIGEST_BITS)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package sp1
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/test"
+)
+
+func TestPublicValuesRoundTrip(t *testing.T) {
+	proof := Proof{PublicInputs: [2]string{
+		"123",
+		"0x1f2e3d4c5b6a79881f2e3d4c5b6a79881f2e3d4c5b6a79881f2e3d4c5b6a7988",
+	}}
+	path := filepath.Join(t.TempDir(), "public_values.json")
+	if err := proof.ExportPublicValues(path, "v1.0.8-testnet"); err != nil {
+		t.Fatal(err)
+	}
+
+	publicValues, err := LoadPublicValues(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if publicValues.VkeyHash != "0x000000000000000000000000000000000000000000000000000000000000007b" {
+		t.Fatalf("unexpected vkey hash encoding: %s", publicValues.VkeyHash)
+	}
+	if publicValues.VerifierVersion != "v1.0.8-testnet" || publicValues.Backend != PLONK_BN254_BACKEND {
+		t.Fatalf("unexpected metadata: %+v", publicValues)
+	}
+
+	vkeyHash, committedValuesDigest, err := publicValues.Inputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vkeyHash.String() != "123" {
+		t.Fatalf("unexpected vkey hash: %s", vkeyHash)
+	}
+	if "0x"+committedValuesDigest.Text(16) != proof.PublicInputs[1] {
+		t.Fatalf("unexpected committed values digest: %s", committedValuesDigest.Text(16))
+	}
+}
+
+func TestPublicValuesMalformed(t *testing.T) {
+	modulus := ecc.BN254.ScalarField().String()
+	for _, inputs := range [][2]string{
+		{"abc", "1"},
+		{"1", modulus},
+		{"-1", "1"},
+	} {
+		proof := Proof{PublicInputs: inputs}
+		if err := proof.ExportPublicValues(filepath.Join(t.TempDir(), "pv.json"), ""); err == nil {
+			t.Errorf("expected %v to be rejected", inputs)
+		}
+	}
+
+	valid := "0x000000000000000000000000000000000000000000000000000000000000007b"
+	for name, publicValues := range map[string]PublicValues{
+		"missing prefix": {VkeyHash: valid[2:], CommittedValuesDigest: valid, Backend: PLONK_BN254_BACKEND},
+		"short":          {VkeyHash: "0x7b", CommittedValuesDigest: valid, Backend: PLONK_BN254_BACKEND},
+		"not hex":        {VkeyHash: valid, CommittedValuesDigest: "0x" + string(make([]byte, 64)), Backend: PLONK_BN254_BACKEND},
+		"out of range":   {VkeyHash: valid, CommittedValuesDigest: "0x" + "ff" + valid[4:], Backend: PLONK_BN254_BACKEND},
+		"wrong backend":  {VkeyHash: valid, CommittedValuesDigest: valid, Backend: "groth16_bn254"},
+	} {
+		data, err := json.Marshal(publicValues)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(t.TempDir(), "pv.json")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadPublicValues(path); err == nil {
+			t.Errorf("%s: expected loading to fail", name)
+		}
+	}
+}
+
+func TestCommittedValuesDigestRange(t *testing.T) {
+	t.Setenv("CONSTRAINTS_JSON", "testdata/basic_constraints.json")
+	basic, err := ReadWitnessInput("testdata/basic_witness.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limit := new(big.Int).Lsh(big.NewInt(1), uint(COMMITTED_VALUES_DIGEST_BITS))
+	for _, tc := range []struct {
+		digest *big.Int
+		valid  bool
+	}{
+		{new(big.Int).Sub(limit, big.NewInt(1)), true},
+		// The smallest digest with a masked bit set is still a BN254 scalar.
+		{limit, false},
+	} {
+		// The fixture commits its second var as the committed values digest.
+		witnessInput := basic
+		witnessInput.Vars = []string{basic.Vars[0], tc.digest.String()}
+		witnessInput.CommitedValuesDigest = tc.digest.String()
+
+		data, err := json.Marshal(witnessInput)
+		if err != nil {
+			t.Fatal(err)
+		}
+		witnessPath := filepath.Join(t.TempDir(), "witness.json")
+		if err := os.WriteFile(witnessPath, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, loadErr := ReadWitnessInput(witnessPath)
+
+		circuit := NewCircuit(witnessInput)
+		assignment := NewCircuit(witnessInput)
+		solveErr := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField())
+
+		if tc.valid && (loadErr != nil || solveErr != nil) {
+			t.Fatalf("expected digest %s to be accepted, got %v and %v", tc.digest, loadErr, solveErr)
+		}
+		if !tc.valid {
+			if loadErr == nil || !strings.Contains(loadErr.Error(), "does not fit in 253 bits") {
+				t.Fatalf("expected the witness loader to reject digest %s, got %v", tc.digest, loadErr)
+			}
+			if solveErr == nil {
+				t.Fatalf("expected the circuit to reject digest %s", tc.digest)
+			}
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package sp1
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/scs"
+	"github.com/consensys/gnark/profile"
+	pprof "github.com/google/pprof/profile"
+)
+
+func init() {
+	solver.RegisterHint(regionMarkerHint)
+}
+
+// The hint called at every CycleTracker instruction when the circuit is profiled. Like
+// instructionMarkerHint, it only records how many constraints precede the instruction.
+func regionMarkerHint(_ *big.Int, _ []*big.Int, results []*big.Int) error {
+	results[0].SetUint64(0)
+	return nil
+}
+
+// regionRecorder records the CycleTracker instructions of the stream. The recursion compiler
+// emits the same name at the start and at the end of a region, so regions nest like a stack.
+type regionRecorder struct {
+	open    []string
+	toggles []string
+}
+
+func (r *regionRecorder) toggle(api frontend.API, name string) error {
+	open, err := toggleRegion(r.open, name)
+	if err != nil {
+		return err
+	}
+	r.open = open
+	r.toggles = append(r.toggles, name)
+	_, err = api.Compiler().NewHint(regionMarkerHint, 1, len(r.toggles))
+	return err
+}
+
+func (r *regionRecorder) finish() error {
+	if len(r.open) > 0 {
+		return fmt.Errorf("region %s is not closed", r.open[len(r.open)-1])
+	}
+	return nil
+}
+
+// toggleRegion closes the region if it is the innermost open one, and opens it otherwise.
+func toggleRegion(open []string, name string) ([]string, error) {
+	if n := len(open); n > 0 && open[n-1] == name {
+		return open[:n-1], nil
+	}
+	for _, region := range open {
+		if region == name {
+			return nil, fmt.Errorf("region %s ends inside region %s", name, open[len(open)-1])
+		}
+	}
+	return append(open, name), nil
+}
+
+// compileCircuit compiles the circuit. If profilePath is set, the compilation is profiled with
+// gnark's profiler and the profile written there lists the regions enclosing every constraint as
+// its outermost frames, and the innermost one as its region label.
+func compileCircuit(circuit frontend.Circuit, stats *Circuit, profilePath string) (constraint.ConstraintSystem, error) {
+	if profilePath == "" {
+		return frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, circuit)
+	}
+
+	regions := &regionRecorder{}
+	stats.regions = regions
+	defer func() { stats.regions = nil }()
+	circuitProfile := profile.Start(profile.WithPath(profilePath))
+	compiled, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, circuit)
+	circuitProfile.Stop()
+	if err != nil {
+		return nil, err
+	}
+	if err := regions.annotateProfile(profilePath, compiled); err != nil {
+		return nil, fmt.Errorf("%s: %w", profilePath, err)
+	}
+	return compiled, nil
+}
+
+// annotateProfile rewrites the profile of the compilation of scs with the regions. gnark records
+// one sample per constraint in the order they are added, so the samples between two markers belong
+// to the same regions.
+func (r *regionRecorder) annotateProfile(path string, scs constraint.ConstraintSystem) error {
+	offsets := hintOffsets(scs, regionMarkerHint)
+	if len(offsets) != len(r.toggles) {
+		return fmt.Errorf("found %d region markers for %d CycleTracker instructions", len(offsets), len(r.toggles))
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	p, err := pprof.Parse(file)
+	file.Close()
+	if err != nil {
+		return err
+	}
+	if len(p.Sample) != scs.GetNbConstraints() {
+		return fmt.Errorf("the profile has %d samples for %d constraints", len(p.Sample), scs.GetNbConstraints())
+	}
+
+	regionLocations := make(map[string]*pprof.Location)
+	location := func(name string) *pprof.Location {
+		if l, ok := regionLocations[name]; ok {
+			return l
+		}
+		f := &pprof.Function{ID: uint64(len(p.Function) + 1), Name: "region " + name, SystemName: name}
+		p.Function = append(p.Function, f)
+		l := &pprof.Location{ID: uint64(len(p.Location) + 1), Line: []pprof.Line{{Function: f}}}
+		p.Location = append(p.Location, l)
+		regionLocations[name] = l
+		return l
+	}
+
+	var open []string
+	next := 0
+	for i, sample := range p.Sample {
+		for ; next < len(offsets) && offsets[next] <= i; next++ {
+			if open, err = toggleRegion(open, r.toggles[next]); err != nil {
+				return err
+			}
+		}
+		if len(open) == 0 {
+			continue
+		}
+		// The locations of a sample go from the leaf to the root. The parsed samples share their
+		// backing arrays, so the locations are copied before being extended.
+		locations := make([]*pprof.Location, len(sample.Location), len(sample.Location)+len(open))
+		copy(locations, sample.Location)
+		for j := len(open) - 1; j >= 0; j-- {
+			locations = append(locations, location(open[j]))
+		}
+		sample.Location = locations
+		sample.Label = map[string][]string{"region": {open[len(open)-1]}}
+	}
+
+	file, err = os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return p.Write(file)
+}
@@ -0,0 +1,88 @@
+package sp1
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/scs"
+	pprof "github.com/google/pprof/profile"
+)
+
+func TestCompileCircuitProfile(t *testing.T) {
+	t.Setenv("CONSTRAINTS_JSON", "testdata/regions_constraints.json")
+	circuit := NewCircuit(readTestWitness(t, "testdata/basic_witness.json"))
+	path := filepath.Join(t.TempDir(), CIRCUIT_PROFILE_PATH)
+	profiled, err := compileCircuit(&circuit, &circuit, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unprofiled, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &circuit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profiled.GetNbConstraints() != unprofiled.GetNbConstraints() {
+		t.Fatalf("profiling changed the number of constraints: %d != %d", profiled.GetNbConstraints(), unprofiled.GetNbConstraints())
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	p, err := pprof.Parse(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every sample of a region has the enclosing regions as its outermost frames.
+	counts := make(map[string]int)
+	for _, sample := range p.Sample {
+		var regions []string
+		for _, location := range sample.Location {
+			if name := location.Line[0].Function.Name; strings.HasPrefix(name, "region ") {
+				regions = append(regions, strings.TrimPrefix(name, "region "))
+			}
+		}
+		label := sample.Label["region"]
+		if len(regions) == 0 {
+			if label != nil {
+				t.Fatalf("sample outside of any region labeled %v", label)
+			}
+			continue
+		}
+		if len(label) != 1 || label[0] != regions[0] {
+			t.Fatalf("sample in regions %v labeled %v", regions, label)
+		}
+		counts[strings.Join(regions, " < ")]++
+	}
+	for _, stack := range []string{"challenger", "fri-query", "pcs-round-0 < fri-query"} {
+		if counts[stack] == 0 {
+			t.Errorf("no constraints attributed to %s: %v", stack, counts)
+		}
+	}
+	if len(counts) != 3 {
+		t.Errorf("unexpected regions: %v", counts)
+	}
+}
+
+func TestUnbalancedRegions(t *testing.T) {
+	for stream, expected := range map[string]string{
+		`[{"opcode":"CycleTracker","args":[["a"]]}]`: "region a is not closed",
+		`[{"opcode":"CycleTracker","args":[["a"]]},{"opcode":"CycleTracker","args":[["b"]]},{"opcode":"CycleTracker","args":[["a"]]}]`: "instruction 2: region a ends inside region b",
+	} {
+		path := filepath.Join(t.TempDir(), "constraints.json")
+		if err := os.WriteFile(path, []byte(stream), 0644); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("CONSTRAINTS_JSON", path)
+		circuit := NewCircuit(readTestWitness(t, "testdata/basic_witness.json"))
+		_, err := compileCircuit(&circuit, &circuit, filepath.Join(t.TempDir(), CIRCUIT_PROFILE_PATH))
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected an error containing %q, got %v", expected, err)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package sp1
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/consensys/gnark/constraint"
+	cs "github.com/consensys/gnark/constraint/bn254"
+)
+
+// BuildReport describes the size of a compiled circuit and the artifacts produced by Build.
+type BuildReport struct {
+	NbConstraints       int            `json:"nb_constraints"`
+	NbPublicInputs      int            `json:"nb_public_inputs"`
+	PublicInputsMode    string         `json:"public_inputs_mode"`
+	NbSecretInputs      int            `json:"nb_secret_inputs"`
+	NbInternalVariables int            `json:"nb_internal_variables"`
+	WitnessSize         int            `json:"witness_size"`
+	NbHints             int            `json:"nb_hints"`
+	NbInstructions      int            `json:"nb_instructions"`
+	OpcodeCounts        map[string]int `json:"opcode_counts"`
+	// Filled in when COLLECT_OPCODE_STATS is set.
+	OpcodeConstraints   map[string]int     `json:"opcode_constraints,omitempty"`
+	DeferredConstraints int                `json:"deferred_constraints,omitempty"`
+	HotRanges           []InstructionRange `json:"hot_ranges,omitempty"`
+	NbFusions           int                `json:"nb_fusions"`
+	NbEliminated        int                `json:"nb_eliminated"`
+	CSEConstraintDelta  int                `json:"cse_constraint_delta"`
+	CompileTimeMs       int64              `json:"compile_time_ms"`
+	Phases              []Phase            `json:"phases"`
+	CircuitDigest       string             `json:"circuit_digest"`
+	ArtifactDigests     map[string]string  `json:"artifact_digests"`
+}
+
+// NewBuildReport collects the statistics of a compiled constraint system.
+func NewBuildReport(scs constraint.ConstraintSystem, circuit *Circuit) BuildReport {
+	report := BuildReport{
+		NbConstraints:       scs.GetNbConstraints(),
+		NbPublicInputs:      nbPublicInputs(scs),
+		PublicInputsMode:    publicInputsMode(nbPublicInputs(scs)),
+		NbSecretInputs:      scs.GetNbSecretVariables(),
+		NbInternalVariables: scs.GetNbInternalVariables(),
+		WitnessSize:         nbPublicInputs(scs) + scs.GetNbSecretVariables(),
+		NbHints:             countHints(scs),
+		NbFusions:           circuit.nbFusions,
+		NbEliminated:        circuit.nbEliminated,
+		OpcodeCounts:        make(map[string]int),
+		ArtifactDigests:     make(map[string]string),
+	}
+	for opcode, count := range circuit.opcodeCounts {
+		report.OpcodeCounts[opcode] = count
+		report.NbInstructions += count
+	}
+	if circuit.opcodes != nil {
+		report.addOpcodeStats(scs, circuit.opcodes)
+	}
+	return report
+}
+
+// CircuitDigest returns the hex encoded SHA-256 digest of the serialized constraint system. Two
+// builds from the same constraints file produce the same digest.
+func CircuitDigest(scs constraint.ConstraintSystem) (string, error) {
+	hasher := sha256.New()
+	if _, err := scs.WriteTo(hasher); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// AddArtifactDigest records the SHA-256 digest of an artifact in the data directory.
+func (r *BuildReport) AddArtifactDigest(dataDir string, name string) error {
+	return r.addDigest(name, dataDir+"/"+name)
+}
+
+// addDigest records the SHA-256 digest of the file at path as the digest of the artifact name.
+func (r *BuildReport) addDigest(name string, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return err
+	}
+	r.ArtifactDigests[name] = hex.EncodeToString(hasher.Sum(nil))
+	return nil
+}
+
+// ReadBuildReport reads a report saved by Save.
+func ReadBuildReport(path string) (BuildReport, error) {
+	var report BuildReport
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return report, err
+	}
+	return report, json.Unmarshal(data, &report)
+}
+
+// Save writes the report as JSON to the given path.
+func (r *BuildReport) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func (r *BuildReport) writeJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// WriteTable writes a human readable summary of the report.
+func (r *BuildReport) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "constraints\t%d\n", r.NbConstraints)
+	fmt.Fprintf(tw, "public inputs\t%d (%s)\n", r.NbPublicInputs, r.PublicInputsMode)
+	fmt.Fprintf(tw, "secret inputs\t%d\n", r.NbSecretInputs)
+	fmt.Fprintf(tw, "internal variables\t%d\n", r.NbInternalVariables)
+	fmt.Fprintf(tw, "witness size\t%d\n", r.WitnessSize)
+	fmt.Fprintf(tw, "hints\t%d\n", r.NbHints)
+	fmt.Fprintf(tw, "instructions\t%d\n", r.NbInstructions)
+	fmt.Fprintf(tw, "fusions\t%d\n", r.NbFusions)
+	fmt.Fprintf(tw, "eliminated instructions\t%d (%d constraints)\n", r.NbEliminated, r.CSEConstraintDelta)
+	fmt.Fprintf(tw, "compile time\t%dms\n", r.CompileTimeMs)
+	fmt.Fprintf(tw, "circuit digest\t%s\n", r.CircuitDigest)
+	writePhases(tw, r.Phases, "")
+
+	opcodes := make([]string, 0, len(r.OpcodeCounts))
+	for opcode := range r.OpcodeCounts {
+		opcodes = append(opcodes, opcode)
+	}
+	sort.Slice(opcodes, func(i, j int) bool {
+		if r.OpcodeConstraints[opcodes[i]] != r.OpcodeConstraints[opcodes[j]] {
+			return r.OpcodeConstraints[opcodes[i]] > r.OpcodeConstraints[opcodes[j]]
+		}
+		if r.OpcodeCounts[opcodes[i]] != r.OpcodeCounts[opcodes[j]] {
+			return r.OpcodeCounts[opcodes[i]] > r.OpcodeCounts[opcodes[j]]
+		}
+		return opcodes[i] < opcodes[j]
+	})
+	for _, opcode := range opcodes {
+		if r.OpcodeConstraints != nil {
+			fmt.Fprintf(tw, "  %s\t%d\t%d constraints\n", opcode, r.OpcodeCounts[opcode], r.OpcodeConstraints[opcode])
+		} else {
+			fmt.Fprintf(tw, "  %s\t%d\n", opcode, r.OpcodeCounts[opcode])
+		}
+	}
+	if r.OpcodeConstraints != nil {
+		fmt.Fprintf(tw, "  deferred\t\t%d constraints\n", r.DeferredConstraints)
+	}
+	for _, hot := range r.HotRanges {
+		fmt.Fprintf(tw, "instructions %d-%d\t%d constraints\n", hot.Start, hot.End, hot.NbConstraints)
+	}
+
+	names := make([]string, 0, len(r.ArtifactDigests))
+	for name := range r.ArtifactDigests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(tw, "%s\t%s\n", name, r.ArtifactDigests[name])
+	}
+
+	return tw.Flush()
+}
+
+// countHints returns the number of hint calls in the constraint system.
+func countHints(scs constraint.ConstraintSystem) int {
+	system, ok := scs.(*cs.SparseR1CS)
+	if !ok {
+		return 0
+	}
+	nbHints := 0
+	for _, instruction := range system.Instructions {
+		if _, ok := system.Blueprints[instruction.BlueprintID].(*constraint.BlueprintGenericHint); ok {
+			nbHints++
+		}
+	}
+	return nbHints
+}
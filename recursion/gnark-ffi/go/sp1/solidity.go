@@ -0,0 +1,169 @@
+package sp1
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	groth16_bn254 "github.com/consensys/gnark/backend/groth16/bn254"
+	"github.com/consensys/gnark/backend/plonk"
+	plonk_bn254 "github.com/consensys/gnark/backend/plonk/bn254"
+)
+
+var GROTH16_VERIFIER_CONTRACT_PATH string = "Groth16Verifier.sol"
+var SP1_PLONK_VERIFIER_CONTRACT_PATH string = "SP1PlonkVerifier.sol"
+var SP1_GROTH16_VERIFIER_CONTRACT_PATH string = "SP1Groth16Verifier.sol"
+
+// The wrappers take the public values of the program instead of their digest, and pass the
+// public inputs to the verifier in the order of the circuit.
+var sp1PlonkVerifierTemplate = template.Must(template.New("SP1PlonkVerifier").Parse(`// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.20;
+
+import {PlonkVerifier} from "./{{.VerifierPath}}";
+
+/// @title SP1 PLONK verifier
+/// @notice Verifies the PLONK proofs of the SP1 wrapper circuit, whose public inputs are the vkey
+/// hash of the program and the digest of its committed public values.
+contract SP1PlonkVerifier is PlonkVerifier {
+    error InvalidProof();
+
+    /// @notice Returns the committed values digest of the public values: their SHA-256 digest with
+    /// the top {{.MaskedBits}} bits masked off.
+    function hashPublicValues(bytes calldata publicValues) public pure returns (bytes32) {
+        return sha256(publicValues) & bytes32(uint256((1 << {{.DigestBits}}) - 1));
+    }
+
+    /// @notice Reverts unless proofBytes is a proof of the program of vkeyHash committing to
+    /// publicValues, encoded as the encoded_proof of the prover.
+    function verifySP1Proof(bytes32 vkeyHash, bytes calldata publicValues, bytes calldata proofBytes) external view {
+        uint256[] memory inputs = new uint256[](2);
+        inputs[0] = uint256(vkeyHash);
+        inputs[1] = uint256(hashPublicValues(publicValues));
+        if (!this.Verify(proofBytes, inputs)) {
+            revert InvalidProof();
+        }
+    }
+}
+`))
+
+var sp1Groth16VerifierTemplate = template.Must(template.New("SP1Groth16Verifier").Parse(`// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.20;
+
+import {Verifier} from "./{{.VerifierPath}}";
+
+/// @title SP1 Groth16 verifier
+/// @notice Verifies the Groth16 proofs of the SP1 wrapper circuit, whose public inputs are the
+/// vkey hash of the program and the digest of its committed public values.
+contract SP1Groth16Verifier is Verifier {
+    /// @notice Returns the committed values digest of the public values: their SHA-256 digest with
+    /// the top {{.MaskedBits}} bits masked off.
+    function hashPublicValues(bytes calldata publicValues) public pure returns (bytes32) {
+        return sha256(publicValues) & bytes32(uint256((1 << {{.DigestBits}}) - 1));
+    }
+
+    /// @notice Reverts unless proofBytes is a proof of the program of vkeyHash committing to
+    /// publicValues, encoded as the encoded_proof of the prover.
+    function verifySP1Proof(bytes32 vkeyHash, bytes calldata publicValues, bytes calldata proofBytes) external view {
+        uint256[2] memory inputs = [uint256(vkeyHash), uint256(hashPublicValues(publicValues))];
+{{- if .NbCommitments}}
+        (uint256[8] memory proof, uint256[{{.CommitmentsLength}}] memory commitments, uint256[2] memory commitmentPok) =
+            abi.decode(proofBytes, (uint256[8], uint256[{{.CommitmentsLength}}], uint256[2]));
+        this.verifyProof(proof, commitments, commitmentPok, inputs);
+{{- else}}
+        uint256[8] memory proof = abi.decode(proofBytes, (uint256[8]));
+        this.verifyProof(proof, inputs);
+{{- end}}
+    }
+}
+`))
+
+type wrapperTemplateData struct {
+	VerifierPath      string
+	DigestBits        int
+	MaskedBits        int
+	NbCommitments     int
+	CommitmentsLength int
+}
+
+// ExportSolidity writes to outDir the on-chain verifier contract of each proving system built in
+// dataDir, along with the SP1 wrapper contract that computes its public inputs from the vkey hash
+// and the public values of a program: PlonkVerifier.sol and SP1PlonkVerifier.sol for PLONK, and
+// Groth16Verifier.sol and SP1Groth16Verifier.sol for Groth16.
+//
+// The wrappers pass the committed values digest as a public input, so circuits built with a single
+// public input are rejected.
+func ExportSolidity(dataDir string, outDir string) error {
+	nbExported := 0
+	plonkVkPath := filepath.Join(dataDir, VK_PATH)
+	if _, err := os.Stat(plonkVkPath); err == nil {
+		vk := plonk.NewVerifyingKey(ecc.BN254)
+		if err := readArtifact(plonkVkPath, vk); err != nil {
+			return err
+		}
+		if err := checkWrappable(int(vk.(*plonk_bn254.VerifyingKey).NbPublicVariables)); err != nil {
+			return fmt.Errorf("%s: %w", VK_PATH, err)
+		}
+		data := wrapperTemplateData{VerifierPath: VERIFIER_CONTRACT_PATH}
+		if err := exportContracts(outDir, VERIFIER_CONTRACT_PATH, vk.ExportSolidity, SP1_PLONK_VERIFIER_CONTRACT_PATH, sp1PlonkVerifierTemplate, data); err != nil {
+			return err
+		}
+		nbExported++
+	}
+
+	groth16VkPath := filepath.Join(dataDir, GROTH16_VK_PATH)
+	if _, err := os.Stat(groth16VkPath); err == nil {
+		vk := groth16.NewVerifyingKey(ecc.BN254)
+		if err := readArtifact(groth16VkPath, vk); err != nil {
+			return err
+		}
+		if err := checkWrappable(groth16NbPublicInputs(vk)); err != nil {
+			return fmt.Errorf("%s: %w", GROTH16_VK_PATH, err)
+		}
+		nbCommitments := len(vk.(*groth16_bn254.VerifyingKey).PublicAndCommitmentCommitted)
+		if nbCommitments > 1 {
+			return fmt.Errorf("%s: the verifier contract supports a single commitment, the circuit has %d", GROTH16_VK_PATH, nbCommitments)
+		}
+		data := wrapperTemplateData{VerifierPath: GROTH16_VERIFIER_CONTRACT_PATH, NbCommitments: nbCommitments, CommitmentsLength: 2 * nbCommitments}
+		if err := exportContracts(outDir, GROTH16_VERIFIER_CONTRACT_PATH, vk.ExportSolidity, SP1_GROTH16_VERIFIER_CONTRACT_PATH, sp1Groth16VerifierTemplate, data); err != nil {
+			return err
+		}
+		nbExported++
+	}
+
+	if nbExported == 0 {
+		return fmt.Errorf("%s contains neither %s nor %s", dataDir, VK_PATH, GROTH16_VK_PATH)
+	}
+	return nil
+}
+
+// checkWrappable checks that the circuit of a verifier key with nbPublicInputs public inputs
+// exposes the vkey hash and the committed values digest.
+func checkWrappable(nbPublicInputs int) error {
+	if mode := publicInputsMode(nbPublicInputs); mode != TWO_PUBLIC_INPUTS_MODE {
+		return fmt.Errorf("cannot wrap the verifier of a %s circuit", mode)
+	}
+	return nil
+}
+
+// exportContracts writes the verifier contract exported by exportVerifier, and the wrapper rendered
+// from tmpl, to outDir.
+func exportContracts(outDir string, verifierPath string, exportVerifier func(w io.Writer) error, wrapperPath string, tmpl *template.Template, data wrapperTemplateData) error {
+	data.DigestBits = COMMITTED_VALUES_DIGEST_BITS
+	data.MaskedBits = 256 - COMMITTED_VALUES_DIGEST_BITS
+	files := newStaging(outDir)
+	defer files.discard()
+	if err := files.write(verifierPath, exportVerifier); err != nil {
+		return err
+	}
+	err := files.write(wrapperPath, func(w io.Writer) error {
+		return tmpl.Execute(w, data)
+	})
+	if err != nil {
+		return err
+	}
+	return files.commit()
+}
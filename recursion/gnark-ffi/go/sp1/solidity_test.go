@@ -0,0 +1,61 @@
+package sp1
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportSolidity(t *testing.T) {
+	dataDir := newDevDataDir(t, "testdata/basic_constraints.json", "testdata/basic_witness.json")
+	outDir := t.TempDir()
+	if err := ExportSolidity(dataDir, outDir); err == nil {
+		t.Fatal("expected exporting from an unbuilt data directory to fail")
+	}
+
+	Build(dataDir)
+	if _, err := BuildGroth16(dataDir, BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExportSolidity(dataDir, outDir); err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string][]string{
+		VERIFIER_CONTRACT_PATH:         {"contract PlonkVerifier"},
+		GROTH16_VERIFIER_CONTRACT_PATH: {"contract Verifier", "uint256[2] calldata commitments"},
+		SP1_PLONK_VERIFIER_CONTRACT_PATH: {
+			`import {PlonkVerifier} from "./PlonkVerifier.sol";`,
+			"(1 << 253) - 1",
+			"this.Verify(proofBytes, inputs)",
+		},
+		SP1_GROTH16_VERIFIER_CONTRACT_PATH: {
+			`import {Verifier} from "./Groth16Verifier.sol";`,
+			"(1 << 253) - 1",
+			"abi.decode(proofBytes, (uint256[8], uint256[2], uint256[2]))",
+			"this.verifyProof(proof, commitments, commitmentPok, inputs);",
+		},
+	} {
+		data, err := os.ReadFile(filepath.Join(outDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range expected {
+			if !strings.Contains(string(data), s) {
+				t.Errorf("%s does not contain %q", name, s)
+			}
+		}
+	}
+}
+
+func TestExportSoliditySinglePublicInput(t *testing.T) {
+	t.Setenv("SINGLE_PUBLIC_INPUT", "true")
+	dataDir := newDevDataDir(t, "testdata/basic_constraints.json", "testdata/basic_witness.json")
+	if _, err := BuildGroth16(dataDir, BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	err := ExportSolidity(dataDir, t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), SINGLE_PUBLIC_INPUT_MODE) {
+		t.Fatalf("expected the single public input circuit to be rejected, got %v", err)
+	}
+}
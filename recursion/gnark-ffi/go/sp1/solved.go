@@ -0,0 +1,453 @@
+package sp1
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/logging"
+)
+
+// The solved witness format stores a witness together with the outputs of every hint solving it,
+// so that a proof can be resumed without solving the hints again. All integers are
+// little-endian:
+//
+//	magic     [4]byte  "SP1S"
+//	version   u32      1
+//	manifest  32 bytes, the digest of the build the witness was solved against
+//	vkey_hash               u32 length, then the string of the witness input
+//	commited_values_digest  u32 length, then the string of the witness input
+//	witness   u64 length, then the full gnark witness in gnark's binary encoding
+//	nHints    u64
+//	hints     nHints × (id u32, nInputs u32, nOutputs u32, inputs and outputs × 32 bytes)
+//
+// Field elements are big-endian like gnark's. Solved witnesses named *.gz are gzip compressed.
+const (
+	solvedWitnessMagic   = "SP1S"
+	solvedWitnessVersion = 1
+)
+
+// solvedWitness is a full gnark witness and the outputs of the hints of the circuit on it, keyed
+// by the id and the inputs of the hint.
+type solvedWitness struct {
+	manifest             [32]byte
+	vkeyHash             string
+	commitedValuesDigest string
+	witness              witness.Witness
+	hints                map[string][]fr.Element
+}
+
+// hintKey returns the key of the outputs of the hint id on inputs.
+func hintKey(id solver.HintID, inputs []*big.Int) string {
+	key := binary.LittleEndian.AppendUint32(make([]byte, 0, 4+32*len(inputs)), uint32(id))
+	for _, input := range inputs {
+		key = appendBytes32(key, input)
+	}
+	return string(key)
+}
+
+// record is a hintWrapper saving the outputs of every hint call.
+func (s *solvedWitness) record(mu *sync.Mutex) hintWrapper {
+	return func(id solver.HintID, fn solver.Hint) solver.Hint {
+		return func(mod *big.Int, inputs []*big.Int, results []*big.Int) error {
+			if err := fn(mod, inputs, results); err != nil {
+				return err
+			}
+			// The solver reduces the outputs and reuses the big integers, so they are copied.
+			outputs := make([]fr.Element, len(results))
+			for i, result := range results {
+				outputs[i].SetBigInt(result)
+			}
+			mu.Lock()
+			s.hints[hintKey(id, inputs)] = outputs
+			mu.Unlock()
+			return nil
+		}
+	}
+}
+
+// replay is a hintWrapper returning the saved outputs of every hint call instead of calling it.
+func (s *solvedWitness) replay(id solver.HintID, _ solver.Hint) solver.Hint {
+	return func(_ *big.Int, inputs []*big.Int, results []*big.Int) error {
+		outputs, ok := s.hints[hintKey(id, inputs)]
+		if !ok || len(outputs) != len(results) {
+			return fmt.Errorf("hint %d was not solved on these inputs", id)
+		}
+		for i := range outputs {
+			outputs[i].BigInt(results[i])
+		}
+		return nil
+	}
+}
+
+func (s *solvedWitness) writeTo(w io.Writer) error {
+	witnessData, err := s.witness.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	data := append([]byte(solvedWitnessMagic), binary.LittleEndian.AppendUint32(nil, solvedWitnessVersion)...)
+	data = append(data, s.manifest[:]...)
+	for _, str := range []string{s.vkeyHash, s.commitedValuesDigest} {
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(str)))
+		data = append(data, str...)
+	}
+	data = binary.LittleEndian.AppendUint64(data, uint64(len(witnessData)))
+	data = append(data, witnessData...)
+	data = binary.LittleEndian.AppendUint64(data, uint64(len(s.hints)))
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+
+	// The hints are sorted so that the same witness is always saved the same way.
+	keys := make([]string, 0, len(s.hints))
+	for key := range s.hints {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		outputs := s.hints[key]
+		data = data[:0]
+		data = append(data, key[:4]...)
+		data = binary.LittleEndian.AppendUint32(data, uint32((len(key)-4)/32))
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(outputs)))
+		data = append(data, key[4:]...)
+		for i := range outputs {
+			encoded := outputs[i].Bytes()
+			data = append(data, encoded[:]...)
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeSolvedWitness decodes a solved witness. Sizes are checked against the remaining data
+// before allocating, so that a corrupted file cannot cause huge allocations.
+func decodeSolvedWitness(data []byte) (*solvedWitness, error) {
+	if len(data) < 8+32 || !bytes.HasPrefix(data, []byte(solvedWitnessMagic)) {
+		return nil, fmt.Errorf("truncated solved witness header")
+	}
+	if version := binary.LittleEndian.Uint32(data[4:]); version != solvedWitnessVersion {
+		return nil, fmt.Errorf("unsupported solved witness version %d", version)
+	}
+	s := &solvedWitness{hints: make(map[string][]fr.Element)}
+	copy(s.manifest[:], data[8:])
+	data = data[8+32:]
+
+	next := func(n uint64, what string) ([]byte, error) {
+		if uint64(len(data)) < n {
+			return nil, fmt.Errorf("truncated solved witness: %s", what)
+		}
+		out := data[:n]
+		data = data[n:]
+		return out, nil
+	}
+	for _, out := range []*string{&s.vkeyHash, &s.commitedValuesDigest} {
+		length, err := next(4, "public input")
+		if err != nil {
+			return nil, err
+		}
+		str, err := next(uint64(binary.LittleEndian.Uint32(length)), "public input")
+		if err != nil {
+			return nil, err
+		}
+		*out = string(str)
+	}
+	length, err := next(8, "witness")
+	if err != nil {
+		return nil, err
+	}
+	witnessData, err := next(binary.LittleEndian.Uint64(length), "witness")
+	if err != nil {
+		return nil, err
+	}
+	if s.witness, err = witness.New(ecc.BN254.ScalarField()); err != nil {
+		return nil, err
+	}
+	if err := s.witness.UnmarshalBinary(witnessData); err != nil {
+		return nil, fmt.Errorf("invalid witness: %w", err)
+	}
+
+	count, err := next(8, "hints")
+	if err != nil {
+		return nil, err
+	}
+	nbHints := binary.LittleEndian.Uint64(count)
+	for i := uint64(0); i < nbHints; i++ {
+		header, err := next(12, "hints")
+		if err != nil {
+			return nil, err
+		}
+		nbInputs := uint64(binary.LittleEndian.Uint32(header[4:]))
+		nbOutputs := uint64(binary.LittleEndian.Uint32(header[8:]))
+		inputs, err := next(32*nbInputs, "hints")
+		if err != nil {
+			return nil, err
+		}
+		encoded, err := next(32*nbOutputs, "hints")
+		if err != nil {
+			return nil, err
+		}
+		outputs := make([]fr.Element, nbOutputs)
+		for j := range outputs {
+			if outputs[j], err = fr.BigEndian.Element((*[32]byte)(encoded[32*j:])); err != nil {
+				return nil, fmt.Errorf("hint %d: output %d: %w", i, j, err)
+			}
+		}
+		s.hints[string(header[:4])+string(inputs)] = outputs
+	}
+	if len(data) != 0 {
+		return nil, fmt.Errorf("%d trailing bytes after the solved witness", len(data))
+	}
+	return s, nil
+}
+
+// manifestDigest returns the digest of the artifacts of the build of dataDir recorded in its build
+// report, which changes whenever the circuit or its keys are rebuilt.
+func manifestDigest(dataDir string) ([32]byte, error) {
+	report, err := ReadBuildReport(dataDir + "/" + REPORT_PATH)
+	if err != nil {
+		return [32]byte{}, fmt.Errorf("failed to read the build report: %w", err)
+	}
+	hasher := sha256.New()
+	fmt.Fprintf(hasher, "%s\n", report.CircuitDigest)
+	for _, name := range []string{CIRCUIT_PATH, PK_PATH, VK_PATH} {
+		digest, ok := report.ArtifactDigests[name]
+		if !ok {
+			return [32]byte{}, fmt.Errorf("the build report has no digest of %s", name)
+		}
+		fmt.Fprintf(hasher, "%s %s\n", name, digest)
+	}
+	var out [32]byte
+	copy(out[:], hasher.Sum(nil))
+	return out, nil
+}
+
+// SolveAndSave solves the witness at witnessPath against the circuit built in dataDir and saves
+// it to solvedPath, gzip compressed if it is named *.gz, so that ProveFromSolved can prove it
+// without solving it again. It runs the stages check (with opts.Check only), load, read_witness,
+// witness, solve and save, and stops once ctx is done like ProveContext.
+func SolveAndSave(ctx context.Context, dataDir string, witnessPath string, solvedPath string, opts ProveOptions) error {
+	logger := logging.OrDefault(opts.Logger)
+	metrics := NewMetrics(logger)
+	stages := stages{ctx: ctx, metrics: metrics, progress: opts.Progress}
+	constraintsPath := resolveInput(dataDir + "/" + CONSTRAINTS_JSON_FILE)
+
+	if opts.Check {
+		endCheck, err := stages.start("check", 1)
+		if err != nil {
+			return err
+		}
+		if err := CheckWitness(constraintsPath, witnessPath); err != nil {
+			return err
+		}
+		endCheck()
+	}
+	os.Setenv("CONSTRAINTS_JSON", constraintsPath)
+
+	// Read the R1CS and the digest of the build.
+	endLoad, err := stages.start("load", 1)
+	if err != nil {
+		return err
+	}
+	manifest, err := manifestDigest(dataDir)
+	if err != nil {
+		return fmt.Errorf("load: %w", err)
+	}
+	scs, err := readConstraintSystem(dataDir)
+	if err != nil {
+		return fmt.Errorf("load: %w", err)
+	}
+	endLoad()
+
+	endReadWitness, err := stages.start("read_witness", 1)
+	if err != nil {
+		return err
+	}
+	witnessInput, err := ReadWitnessInput(witnessPath)
+	if err != nil {
+		return fmt.Errorf("read_witness: %w", err)
+	}
+	endReadWitness()
+
+	endWitness, err := stages.start("witness", 1)
+	if err != nil {
+		return err
+	}
+	_, fullWitness, err := newFullWitness(scs, witnessInput)
+	if err != nil {
+		return fmt.Errorf("witness: %w", err)
+	}
+	endWitness()
+
+	// Solve the witness, recording the outputs of the hints.
+	nbHints := 0
+	if opts.Progress != nil {
+		nbHints = countHints(scs)
+	}
+	endSolve, err := stages.start("solve", nbHints)
+	if err != nil {
+		return err
+	}
+	solved := &solvedWitness{
+		manifest:             manifest,
+		vkeyHash:             witnessInput.VkeyHash,
+		commitedValuesDigest: witnessInput.CommitedValuesDigest,
+		witness:              fullWitness,
+		hints:                make(map[string][]fr.Element),
+	}
+	wrappers := []hintWrapper{solved.record(&sync.Mutex{})}
+	if ctx.Done() != nil {
+		wrappers = append(wrappers, cancelHint(ctx))
+	}
+	if reporter := stages.hints("solve", nbHints); reporter != nil {
+		wrappers = append(wrappers, reporter.wrap)
+	}
+	solverOpts := append(instrumentHints(wrappers...), solver.WithLogger(logging.Zerolog(logger)), solverParallelism())
+	if _, err := scs.Solve(fullWitness, solverOpts...); err != nil {
+		return fmt.Errorf("solve: %w", contextError(ctx, err))
+	}
+	endSolve()
+
+	endSave, err := stages.start("save", 1)
+	if err != nil {
+		return err
+	}
+	files := newStaging(filepath.Dir(solvedPath))
+	defer files.discard()
+	err = files.write(filepath.Base(solvedPath), func(w io.Writer) error {
+		if !strings.HasSuffix(solvedPath, ".gz") {
+			return solved.writeTo(w)
+		}
+		compressed := gzip.NewWriter(w)
+		if err := solved.writeTo(compressed); err != nil {
+			return err
+		}
+		return compressed.Close()
+	})
+	if err != nil {
+		return fmt.Errorf("save: %w", err)
+	}
+	if err := files.commit(); err != nil {
+		return fmt.Errorf("save: %w", err)
+	}
+	endSave()
+	metrics.Log("solved witness")
+	return nil
+}
+
+// ProveFromSolved proves a witness saved by SolveAndSave, replaying the outputs of its hints
+// instead of solving them again. The solved witness must have been saved against the same build
+// of dataDir. It runs the stages load, read_solved, prove, verify and serialize, and stops once ctx
+// is done like ProveContext. opts.Check and opts.Profile are ignored.
+func ProveFromSolved(ctx context.Context, dataDir string, solvedPath string, opts ProveOptions) (Proof, error) {
+	logger := logging.OrDefault(opts.Logger)
+	metrics := NewMetrics(logger)
+	stages := stages{ctx: ctx, metrics: metrics, progress: opts.Progress}
+
+	endLoad, err := stages.start("load", 1)
+	if err != nil {
+		return Proof{}, err
+	}
+	manifest, err := manifestDigest(dataDir)
+	if err != nil {
+		return Proof{}, fmt.Errorf("load: %w", err)
+	}
+	scs, pk, vk, err := readProvingArtifacts(dataDir)
+	if err != nil {
+		return Proof{}, fmt.Errorf("load: %w", err)
+	}
+	endLoad()
+
+	endRead, err := stages.start("read_solved", 1)
+	if err != nil {
+		return Proof{}, err
+	}
+	input, err := openInput(solvedPath)
+	if err != nil {
+		return Proof{}, fmt.Errorf("read_solved: %w", err)
+	}
+	data, err := io.ReadAll(input)
+	input.Close()
+	if err != nil {
+		return Proof{}, fmt.Errorf("read_solved: %s: %w", solvedPath, err)
+	}
+	solved, err := decodeSolvedWitness(data)
+	if err != nil {
+		return Proof{}, fmt.Errorf("read_solved: %s: %w", solvedPath, err)
+	}
+	if solved.manifest != manifest {
+		return Proof{}, fmt.Errorf("read_solved: %s was solved against another build than the one of %s", solvedPath, dataDir)
+	}
+	publicWitness, err := solved.witness.Public()
+	if err != nil {
+		return Proof{}, fmt.Errorf("read_solved: %w", err)
+	}
+	endRead()
+
+	nbHints := 0
+	if opts.Progress != nil {
+		nbHints = countHints(scs)
+	}
+	endProve, err := stages.start("prove", nbHints+1)
+	if err != nil {
+		return Proof{}, err
+	}
+	wrappers := []hintWrapper{solved.replay}
+	if ctx.Done() != nil {
+		wrappers = append(wrappers, cancelHint(ctx))
+	}
+	if reporter := stages.hints("prove", nbHints+1); reporter != nil {
+		wrappers = append(wrappers, reporter.wrap)
+	}
+	solverOpts := append(instrumentHints(wrappers...), solver.WithLogger(logging.Zerolog(logger)), solverParallelism())
+	proof, err := plonk.Prove(scs, pk, solved.witness, backend.WithSolverOptions(solverOpts...))
+	if err != nil {
+		return Proof{}, fmt.Errorf("prove: %w", contextError(ctx, err))
+	}
+	endProve()
+
+	endVerify, err := stages.start("verify", 1)
+	if err != nil {
+		return Proof{}, err
+	}
+	if err := plonk.Verify(proof, vk, publicWitness); err != nil {
+		return Proof{}, fmt.Errorf("verify: %w", err)
+	}
+	endVerify()
+
+	endSerialize, err := stages.start("serialize", 1)
+	if err != nil {
+		return Proof{}, err
+	}
+	sp1PlonkBn254Proof := NewSP1PlonkBn254Proof(&proof, WitnessInput{
+		VkeyHash:             solved.vkeyHash,
+		CommitedValuesDigest: solved.commitedValuesDigest,
+	})
+	if publicInputsMode(scs.GetNbPublicVariables()) == SINGLE_PUBLIC_INPUT_MODE {
+		publicInputs := publicWitness.Vector().(fr.Vector)
+		sp1PlonkBn254Proof.PublicInputHash = publicInputs[0].String()
+	}
+	endSerialize()
+	sp1PlonkBn254Proof.Phases = metrics.Phases
+	metrics.Log("proved")
+
+	return sp1PlonkBn254Proof, nil
+}
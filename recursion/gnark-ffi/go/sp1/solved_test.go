@@ -0,0 +1,70 @@
+package sp1
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProveFromSolved(t *testing.T) {
+	dataDir := newDevDataDir(t, "testdata/hints_constraints.json", "testdata/basic_witness.json")
+	Build(dataDir)
+	ctx := context.Background()
+
+	for _, name := range []string{"solved_witness.bin", "solved_witness.bin.gz"} {
+		solvedPath := filepath.Join(t.TempDir(), name)
+		if err := SolveAndSave(ctx, dataDir, filepath.Join(dataDir, WITNESS_JSON_FILE), solvedPath, ProveOptions{}); err != nil {
+			t.Fatal(err)
+		}
+
+		// The proof only depends on the files of the build and of the solved witness.
+		proof, err := ProveFromSolved(ctx, dataDir, solvedPath, ProveOptions{})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if proof.PublicInputs != [2]string{"123", "456"} {
+			t.Errorf("%s: unexpected public inputs %v", name, proof.PublicInputs)
+		}
+		if err := Verify(dataDir, proof.RawProof, "123", "456"); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestProveFromSolvedRejectsStaleWitness(t *testing.T) {
+	dataDir := newDevDataDir(t, "testdata/hints_constraints.json", "testdata/basic_witness.json")
+	Build(dataDir)
+	solvedPath := filepath.Join(t.TempDir(), "solved_witness.bin")
+	if err := SolveAndSave(context.Background(), dataDir, filepath.Join(dataDir, WITNESS_JSON_FILE), solvedPath, ProveOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A witness solved against another build is rejected.
+	otherDir := newDevDataDir(t, "testdata/basic_constraints.json", "testdata/basic_witness.json")
+	Build(otherDir)
+	if _, err := ProveFromSolved(context.Background(), otherDir, solvedPath, ProveOptions{}); err == nil || !strings.Contains(err.Error(), "solved against another build") {
+		t.Errorf("expected a stale solved witness to be rejected, got %v", err)
+	}
+
+	// A witness whose hints were tampered with fails to prove.
+	data, err := os.ReadFile(solvedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 1
+	if err := os.WriteFile(solvedPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ProveFromSolved(context.Background(), dataDir, solvedPath, ProveOptions{}); err == nil || !strings.HasPrefix(err.Error(), "prove: ") {
+		t.Errorf("expected a tampered solved witness to fail to prove, got %v", err)
+	}
+
+	if err := os.WriteFile(solvedPath, data[:len(data)-1], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ProveFromSolved(context.Background(), dataDir, solvedPath, ProveOptions{}); err == nil || !strings.Contains(err.Error(), "truncated solved witness") {
+		t.Errorf("expected a truncated solved witness to be rejected, got %v", err)
+	}
+}
@@ -1,24 +1,57 @@
 package sp1
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/consensys/gnark/frontend"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/artifacts"
 	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/logging"
 	"github.com/succinctlabs/sp1-recursion-gnark/sp1/poseidon2"
 )
 
-var SRS_FILE string = "srs.bin"
-var SRS_LAGRANGE_FILE string = "srs_lagrange.bin"
+var SRS_FILE string = artifacts.SRS_FILE
+var SRS_LAGRANGE_FILE string = artifacts.SRS_LAGRANGE_FILE
 var CONSTRAINTS_JSON_FILE string = "constraints.json"
 var WITNESS_JSON_FILE string = "witness.json"
-var VERIFIER_CONTRACT_PATH string = "PlonkVerifier.sol"
-var CIRCUIT_PATH string = "circuit.bin"
-var VK_PATH string = "vk.bin"
-var PK_PATH string = "pk.bin"
+var VERIFIER_CONTRACT_PATH string = artifacts.VERIFIER_CONTRACT_FILE
+var CIRCUIT_PATH string = artifacts.CIRCUIT_FILE
+var VK_PATH string = artifacts.VK_FILE
+var PK_PATH string = artifacts.PK_FILE
+var REPORT_PATH string = artifacts.MANIFEST_FILE
+var CIRCUIT_PROFILE_PATH string = "circuit.pprof"
+var GROTH16_CIRCUIT_PATH string = "groth16_circuit.bin"
+var GROTH16_VK_PATH string = "groth16_vk.bin"
+var GROTH16_PK_PATH string = "groth16_pk.bin"
+
+// ProvingSystem is the proof system the circuit of a data directory is built, proven and verified
+// with. The artifacts of the two systems have different names, so both can be built in the same
+// data directory.
+type ProvingSystem string
+
+// The proving systems of BuildOptions, ProveOptions and VerifyOptions. The empty system is PLONK.
+const (
+	PLONK_SYSTEM   ProvingSystem = "plonk"
+	GROTH16_SYSTEM ProvingSystem = "groth16"
+)
+
+// isGroth16 reports whether the system is Groth16, or returns an error if it is unknown.
+func (s ProvingSystem) isGroth16() (bool, error) {
+	switch s {
+	case "", PLONK_SYSTEM:
+		return false, nil
+	case GROTH16_SYSTEM:
+		return true, nil
+	}
+	return false, fmt.Errorf("unknown proving system %q", s)
+}
+
+// The number of instructions synthesized between two checks of the context of the build.
+var CANCEL_CHECK_INTERVAL int = 1024
 
 type Circuit struct {
 	VkeyHash             frontend.Variable `gnark:",public"`
@@ -26,45 +59,135 @@ type Circuit struct {
 	Vars                 []frontend.Variable
 	Felts                []babybear.Variable
 	Exts                 []babybear.ExtensionVariable
+
+	// The number of instructions of each opcode, filled in by Define.
+	opcodeCounts map[string]int `gnark:"-"`
+	// The number of fused instructions, filled in by Define when FUSE_INSTRUCTIONS is set.
+	nbFusions int `gnark:"-"`
+	// The number of eliminated instructions, filled in by Define when
+	// ELIMINATE_COMMON_SUBEXPRESSIONS is set, unless skipCSE is.
+	nbEliminated int  `gnark:"-"`
+	skipCSE      bool `gnark:"-"`
+	// The opcode of every synthesized instruction, recorded by Define when COLLECT_OPCODE_STATS is
+	// set.
+	opcodes []string `gnark:"-"`
+	// Records the duration of parsing and synthesis, if set.
+	metrics *Metrics `gnark:"-"`
+	// Receives the logs of the interpreter. The default logger is used if it is nil.
+	logger logging.Logger `gnark:"-"`
+	// Stops the synthesis once done, if set.
+	ctx context.Context `gnark:"-"`
+	// Tracks the instruction being synthesized for CheckWitness, if set. The stream is then not
+	// rewritten, so that the positions are the ones of the constraints file.
+	position *checkPosition `gnark:"-"`
+	// The constraints file, set by BuildVerifierCircuit. Otherwise CONSTRAINTS_JSON is read.
+	constraintsPath string `gnark:"-"`
+	// Records the CycleTracker instructions when the circuit is compiled with a profile, if set.
+	regions *regionRecorder `gnark:"-"`
 }
 
 type Constraint struct {
 	Opcode string     `json:"opcode"`
 	Args   [][]string `json:"args"`
+	// The ids that no later instruction mentions, if the stream carries liveness annotations.
+	Dead []string `json:"dead,omitempty"`
+	// Where the recursion program emitted the instruction, such as a file and a line, if the stream
+	// carries source annotations. Only diagnostics report it.
+	Source string `json:"source,omitempty"`
 }
 
 type WitnessInput struct {
 	Vars                 []string   `json:"vars"`
 	Felts                []string   `json:"felts"`
-	Exts                 [][]string `json:"exts"`
+	Exts                 []ExtValue `json:"exts"`
 	VkeyHash             string     `json:"vkey_hash"`
 	CommitedValuesDigest string     `json:"commited_values_digest"`
 }
 
 type Proof struct {
 	PublicInputs [2]string `json:"public_inputs"`
-	EncodedProof string    `json:"encoded_proof"`
-	RawProof     string    `json:"raw_proof"`
+	// The only public input of proofs of a HashedCircuit, computed from PublicInputs.
+	PublicInputHash string  `json:"public_input_hash,omitempty"`
+	EncodedProof    string  `json:"encoded_proof"`
+	RawProof        string  `json:"raw_proof"`
+	Phases          []Phase `json:"phases,omitempty"`
+	// Set by ProveWithProfile.
+	SolverProfile *SolverProfile `json:"solver_profile,omitempty"`
 }
 
 func (circuit *Circuit) Define(api frontend.API) error {
-	// Get the file name from an environment variable.
-	fileName := os.Getenv("CONSTRAINTS_JSON")
+	publicInputs := [2]frontend.Variable{circuit.VkeyHash, circuit.CommitedValuesDigest}
+	witness := &Witness{Vars: circuit.Vars, Felts: circuit.Felts, Exts: circuit.Exts}
+	err := circuit.synthesize(api, witness, func(index int, v frontend.Variable) {
+		api.AssertIsEqual(publicInputs[index], v)
+	})
+	if err != nil {
+		return err
+	}
+
+	// The committed values digest is a SHA-256 digest whose top bits the wrapper masks off, so that
+	// a prover cannot commit to an alias of it modulo the field.
+	api.ToBinary(circuit.CommitedValuesDigest, COMMITTED_VALUES_DIGEST_BITS)
+
+	return nil
+}
+
+// synthesize runs the interpreter on the witness. The value of every CommitVkeyHash and
+// CommitCommitedValuesDigest instruction is passed to commit, with the index of the public input it
+// commits to.
+func (circuit *Circuit) synthesize(api frontend.API, witness *Witness, commit func(index int, v frontend.Variable)) error {
+	// Get the file name from the circuit or an environment variable.
+	fileName := circuit.constraintsPath
+	if fileName == "" {
+		fileName = os.Getenv("CONSTRAINTS_JSON")
+	}
 	if fileName == "" {
 		fileName = "constraints.json"
 	}
 
-	// Read the file.
-	data, err := os.ReadFile(fileName)
+	// Read and validate the instructions.
+	endParse := circuit.metrics.Start("parse")
+	constraints, err := ReadConstraints(fileName)
+	endParse()
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+		return fmt.Errorf("failed to read constraints: %w", err)
+	}
+	if os.Getenv("REJECT_REASSIGNMENTS") == "true" {
+		if err := CheckSingleAssignment(constraints); err != nil {
+			return fmt.Errorf("invalid constraints: %w", err)
+		}
 	}
+	if err := circuit.checkContext(0); err != nil {
+		return err
+	}
+	defer circuit.metrics.Start("synthesize")()
 
-	// Deserialize the JSON data into a slice of Instruction structs.
-	var constraints []Constraint
-	err = json.Unmarshal(data, &constraints)
-	if err != nil {
-		return fmt.Errorf("error deserializing JSON: %v", err)
+	// Optionally remove duplicate computations, then fuse common instruction patterns into cheaper
+	// pseudo-ops.
+	circuit.nbEliminated = 0
+	if os.Getenv("ELIMINATE_COMMON_SUBEXPRESSIONS") == "true" && !circuit.skipCSE && circuit.position == nil {
+		constraints, circuit.nbEliminated = EliminateCommonSubexpressions(constraints)
+	}
+	circuit.nbFusions = 0
+	if os.Getenv("FUSE_INSTRUCTIONS") == "true" && circuit.position == nil {
+		constraints, circuit.nbFusions = FuseInstructions(constraints)
+	}
+
+	// Optionally drop every id after its last use and reuse its slot, so that long streams do not
+	// keep all their intermediate values alive. The annotations of the stream are only valid if it
+	// was not rewritten.
+	var liveness *Liveness
+	if os.Getenv("FREE_DEAD_VARIABLES") == "true" {
+		if circuit.nbEliminated == 0 && circuit.nbFusions == 0 {
+			liveness, err = annotatedLiveness(constraints)
+			if err != nil {
+				return fmt.Errorf("invalid liveness annotations: %w", err)
+			}
+		}
+		if liveness == nil {
+			liveness = ComputeLiveness(constraints)
+		}
+		constraints, liveness, _ = AllocateSlots(constraints, liveness)
 	}
 
 	hashAPI := poseidon2.NewChip(api)
@@ -73,9 +196,37 @@ func (circuit *Circuit) Define(api frontend.API) error {
 	vars := make(map[string]frontend.Variable)
 	felts := make(map[string]babybear.Variable)
 	exts := make(map[string]babybear.ExtensionVariable)
+	circuit.opcodeCounts = make(map[string]int)
+
+	// Optionally mark the start of every instruction to attribute constraints to opcodes.
+	collectStats := os.Getenv("COLLECT_OPCODE_STATS") == "true"
+	circuit.opcodes = nil
+	mark := func(index int) {
+		if _, err := api.Compiler().NewHint(instructionMarkerHint, 1, index); err != nil {
+			panic(err)
+		}
+	}
 
 	// Iterate through the instructions and handle each opcode.
-	for _, cs := range constraints {
+	var instructionStart time.Time
+	for i, cs := range constraints {
+		if i%CANCEL_CHECK_INTERVAL == 0 {
+			if err := circuit.checkContext(i); err != nil {
+				return err
+			}
+		}
+		circuit.opcodeCounts[cs.Opcode]++
+		if collectStats {
+			if i > 0 {
+				circuit.metrics.AddToBucket(constraints[i-1].Opcode, time.Since(instructionStart))
+			}
+			instructionStart = time.Now()
+			circuit.opcodes = append(circuit.opcodes, cs.Opcode)
+			mark(i)
+		}
+		if circuit.position != nil {
+			circuit.position.index = i
+		}
 		switch cs.Opcode {
 		case "ImmV":
 			vars[cs.Args[0][0]] = frontend.Variable(cs.Args[1][0])
@@ -105,8 +256,16 @@ func (circuit *Circuit) Define(api frontend.API) error {
 			felts[cs.Args[0][0]] = fieldAPI.MulF(felts[cs.Args[1][0]], felts[cs.Args[2][0]])
 		case "MulE":
 			exts[cs.Args[0][0]] = fieldAPI.MulE(exts[cs.Args[1][0]], exts[cs.Args[2][0]])
+		case "MulAddF":
+			felts[cs.Args[0][0]] = fieldAPI.MulAddF(felts[cs.Args[1][0]], felts[cs.Args[2][0]], felts[cs.Args[3][0]])
 		case "MulEF":
 			exts[cs.Args[0][0]] = fieldAPI.MulEF(exts[cs.Args[1][0]], felts[cs.Args[2][0]])
+		case "SumE":
+			terms := make([]babybear.ExtensionVariable, len(cs.Args)-1)
+			for i := 1; i < len(cs.Args); i++ {
+				terms[i-1] = exts[cs.Args[i][0]]
+			}
+			exts[cs.Args[0][0]] = fieldAPI.SumE(terms...)
 		case "DivE":
 			exts[cs.Args[0][0]] = fieldAPI.DivE(exts[cs.Args[1][0]], exts[cs.Args[2][0]])
 		case "NegE":
@@ -123,7 +282,9 @@ func (circuit *Circuit) Define(api frontend.API) error {
 				vars[cs.Args[0][i]] = bits[i]
 			}
 		case "Num2BitsF":
-			bits := fieldAPI.ToBinary(felts[cs.Args[1][0]])
+			// The decompositions of felts feed the transcript, as sampled bits or as observed values, so
+			// they must be unique.
+			bits := fieldAPI.ToBinaryStrict(felts[cs.Args[1][0]])
 			for i := 0; i < len(cs.Args[0]); i++ {
 				vars[cs.Args[0][i]] = bits[i]
 			}
@@ -160,46 +321,84 @@ func (circuit *Circuit) Define(api frontend.API) error {
 		case "AssertEqE":
 			fieldAPI.AssertIsEqualE(exts[cs.Args[0][0]], exts[cs.Args[1][0]])
 		case "PrintV":
-			api.Println(vars[cs.Args[0][0]])
+			api.Println(cs.Args[len(cs.Args)-1][0], vars[cs.Args[0][0]])
 		case "PrintF":
-			f := felts[cs.Args[0][0]]
-			api.Println(f.Value)
+			fieldAPI.PrintF(cs.Args[len(cs.Args)-1][0], felts[cs.Args[0][0]])
 		case "PrintE":
-			e := exts[cs.Args[0][0]]
-			api.Println(e.Value[0].Value)
-			api.Println(e.Value[1].Value)
-			api.Println(e.Value[2].Value)
-			api.Println(e.Value[3].Value)
+			fieldAPI.PrintE(cs.Args[len(cs.Args)-1][0], exts[cs.Args[0][0]])
 		case "WitnessV":
-			i, err := strconv.Atoi(cs.Args[1][0])
+			v, err := witnessValue(cs, witness.Vars)
 			if err != nil {
-				panic(err)
+				return fmt.Errorf("instruction %d: %w", i, err)
 			}
-			vars[cs.Args[0][0]] = circuit.Vars[i]
+			vars[cs.Args[0][0]] = v
 		case "WitnessF":
-			i, err := strconv.Atoi(cs.Args[1][0])
+			v, err := witnessValue(cs, witness.Felts)
 			if err != nil {
-				panic(err)
+				return fmt.Errorf("instruction %d: %w", i, err)
 			}
-			felts[cs.Args[0][0]] = circuit.Felts[i]
+			felts[cs.Args[0][0]] = v
 		case "WitnessE":
-			i, err := strconv.Atoi(cs.Args[1][0])
+			v, err := witnessValue(cs, witness.Exts)
 			if err != nil {
-				panic(err)
+				return fmt.Errorf("instruction %d: %w", i, err)
+			}
+			exts[cs.Args[0][0]] = v
+		case "CycleTracker":
+			if circuit.regions != nil {
+				if err := circuit.regions.toggle(api, cs.Args[0][0]); err != nil {
+					return fmt.Errorf("instruction %d: %w", i, err)
+				}
 			}
-			exts[cs.Args[0][0]] = circuit.Exts[i]
 		case "CommitVkeyHash":
-			element := vars[cs.Args[0][0]]
-			api.AssertIsEqual(circuit.VkeyHash, element)
+			commit(0, vars[cs.Args[0][0]])
 		case "CommitCommitedValuesDigest":
-			element := vars[cs.Args[0][0]]
-			api.AssertIsEqual(circuit.CommitedValuesDigest, element)
+			commit(1, vars[cs.Args[0][0]])
 		case "CircuitFelts2Ext":
 			exts[cs.Args[0][0]] = babybear.Felts2Ext(felts[cs.Args[1][0]], felts[cs.Args[2][0]], felts[cs.Args[3][0]], felts[cs.Args[4][0]])
 		default:
 			return fmt.Errorf("unhandled opcode: %s", cs.Opcode)
 		}
+		if liveness != nil {
+			for _, id := range liveness.Dead(i) {
+				delete(vars, id)
+				delete(felts, id)
+				delete(exts, id)
+			}
+		}
+	}
+	if circuit.position != nil {
+		circuit.position.index = len(constraints)
+	}
+	if circuit.regions != nil {
+		if err := circuit.regions.finish(); err != nil {
+			return err
+		}
 	}
+	if collectStats {
+		if len(constraints) > 0 {
+			circuit.metrics.AddToBucket(constraints[len(constraints)-1].Opcode, time.Since(instructionStart))
+		}
+		mark(len(constraints))
+	}
+	logging.OrDefault(circuit.logger).Debug(
+		"interpreted constraints",
+		"path", fileName,
+		"instructions", len(constraints),
+		"eliminated", circuit.nbEliminated,
+		"fused", circuit.nbFusions,
+	)
+	return nil
+}
 
+// checkContext returns the error of the context of the circuit, if it is done, wrapped with the
+// index of the instruction about to be synthesized.
+func (circuit *Circuit) checkContext(index int) error {
+	if circuit.ctx == nil {
+		return nil
+	}
+	if err := circuit.ctx.Err(); err != nil {
+		return fmt.Errorf("synthesize: instruction %d: %w", index, err)
+	}
 	return nil
 }
@@ -0,0 +1,193 @@
+package sp1
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	kzg_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/kzg"
+	"github.com/consensys/gnark-crypto/kzg"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/test/unsafekzg"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/logging"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/store"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/trusted_setup"
+)
+
+// loadSRS returns the canonical and Lagrange SRS for the circuit, and saves them in dataDir as
+// SRS_FILE and SRS_LAGRANGE_FILE.
+//
+// Development builds, whose dataDir contains "dev", use a fresh unsafe SRS. Otherwise both files
+// are read from dataDir when SRS_FILE is already there and large enough for the circuit. If not, the
+// canonical SRS is trimmed to the size of the circuit from the SRSCacheDir of opts, the SRSPath of
+// opts, or the Aztec Ignition SRS, which is only downloaded when neither has it, and converted to
+// the Lagrange basis. The files are replaced together, once both are written.
+func loadSRS(dataDir string, scs constraint.ConstraintSystem, opts BuildOptions, logger logging.Logger) (kzg.SRS, kzg.SRS, error) {
+	srsFileName := dataDir + "/" + SRS_FILE
+	srsLagrangeFileName := dataDir + "/" + SRS_LAGRANGE_FILE
+	files := newStaging(dataDir)
+	defer files.discard()
+
+	if strings.Contains(dataDir, "dev") {
+		logger.Info("generating unsafe srs", "data_dir", dataDir)
+		srs, srsLagrange, err := unsafekzg.NewSRS(scs)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := writeSRS(files, SRS_FILE, srs); err != nil {
+			return nil, nil, err
+		}
+		if err := writeSRS(files, SRS_LAGRANGE_FILE, srsLagrange); err != nil {
+			return nil, nil, err
+		}
+		return srs, srsLagrange, files.commit()
+	}
+
+	size := trusted_setup.SRSSize(scs)
+	if _, err := os.Stat(srsFileName); err == nil {
+		srs, err := readSRS(srsFileName)
+		if err != nil {
+			return nil, nil, err
+		}
+		if srsLen(srs) < size {
+			logger.Info("srs cache too small", "path", srsFileName, "size", srsLen(srs), "required_size", size)
+		} else {
+			logger.Info("srs cache hit", "path", srsFileName, "lagrange_path", srsLagrangeFileName)
+			srsLagrange, err := readSRS(srsLagrangeFileName)
+			if err != nil {
+				return nil, nil, err
+			}
+			return srs, srsLagrange, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+
+	srs, err := trimmedSRS(dataDir, size, opts, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	srsLagrange := trusted_setup.ToLagrange(scs, srs)
+	if err := writeSRS(files, SRS_FILE, srs); err != nil {
+		return nil, nil, err
+	}
+	if err := writeSRS(files, SRS_LAGRANGE_FILE, srsLagrange); err != nil {
+		return nil, nil, err
+	}
+	return srs, srsLagrange, files.commit()
+}
+
+// trimmedSRS returns the canonical SRS of size points, from the SRSCacheDir of opts when it has
+// it. Otherwise the SRS is trimmed from the file at the SRSPath of opts, or from the Aztec Ignition
+// SRS downloaded to a temporary file of dataDir, and saved to the cache.
+func trimmedSRS(dataDir string, size int, opts BuildOptions, logger logging.Logger) (kzg.SRS, error) {
+	cachePath := ""
+	if opts.SRSCacheDir != "" {
+		cachePath = filepath.Join(opts.SRSCacheDir, fmt.Sprintf("srs_%d.bin", size))
+		srs := kzg.NewSRS(ecc.BN254)
+		_, err := store.ReadFile(cachePath, store.SRS, "", srs)
+		if err == nil {
+			logger.Info("trimmed srs cache hit", "path", cachePath, "size", size)
+			return srs, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			// A corrupted or stale file is replaced.
+			logger.Warn("ignoring the cached srs", "path", cachePath, "error", err)
+		}
+	}
+
+	var srs kzg.SRS
+	if opts.SRSPath != "" {
+		logger.Info("reading srs", "path", opts.SRSPath)
+		var err error
+		srs, err = readSRS(opts.SRSPath)
+		if err != nil {
+			return nil, err
+		}
+		if err := trusted_setup.SanityCheck(srs); err != nil {
+			return nil, fmt.Errorf("%s: kzg sanity check failed: %w", opts.SRSPath, err)
+		}
+	} else {
+		logger.Info("srs cache miss, downloading aztec ignition srs", "path", dataDir+"/"+SRS_FILE)
+		file, err := os.CreateTemp(dataDir, ".ignition.tmp-*")
+		if err != nil {
+			return nil, err
+		}
+		downloadPath := file.Name()
+		file.Close()
+		defer os.Remove(downloadPath)
+		trusted_setup.DownloadAndSaveAztecIgnitionSrs(174, downloadPath, logger)
+		srs, err = readSRS(downloadPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	srs, err := trusted_setup.Trim(srs, size)
+	if err != nil {
+		return nil, err
+	}
+
+	if cachePath != "" {
+		if err := cacheSRS(cachePath, srs); err != nil {
+			logger.Warn("failed to cache the trimmed srs", "path", cachePath, "error", err)
+		} else {
+			logger.Info("cached the trimmed srs", "path", cachePath, "size", size)
+		}
+	}
+	return srs, nil
+}
+
+// cacheSRS saves srs to path, through a temporary file so that concurrent builds never read a
+// partially written one.
+func cacheSRS(path string, srs kzg.SRS) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tempPath := file.Name()
+	file.Close()
+	defer os.Remove(tempPath)
+	if err := store.WriteFile(tempPath, store.SRS, "", srs); err != nil {
+		return err
+	}
+	if err := os.Chmod(tempPath, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
+
+// srsLen returns the number of points of the canonical srs.
+func srsLen(srs kzg.SRS) int {
+	if srs, ok := srs.(*kzg_bn254.SRS); ok {
+		return len(srs.Pk.G1)
+	}
+	return 0
+}
+
+func readSRS(path string) (kzg.SRS, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	srs := kzg.NewSRS(ecc.BN254)
+	if _, err := srs.ReadFrom(file); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return srs, nil
+}
+
+func writeSRS(files *staging, name string, srs kzg.SRS) error {
+	return files.write(name, func(w io.Writer) error {
+		_, err := srs.WriteTo(w)
+		return err
+	})
+}
@@ -0,0 +1,171 @@
+package sp1
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	kzg_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/kzg"
+	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/scs"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/trusted_setup"
+)
+
+type srsCircuit struct {
+	X frontend.Variable
+	Y frontend.Variable `gnark:",public"`
+}
+
+func (circuit *srsCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(api.Mul(circuit.X, circuit.X, circuit.X), circuit.Y)
+	return nil
+}
+
+func compileSRSCircuit(t *testing.T) constraint.ConstraintSystem {
+	cs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &srsCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cs
+}
+
+// writeCanonicalSRS writes an unsafe canonical SRS of size points to a file and returns its path.
+func writeCanonicalSRS(t *testing.T, size uint64, tau int64) string {
+	srs, err := kzg_bn254.NewSRS(size, big.NewInt(tau))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "canonical.bin")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := srs.WriteTo(file); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newDataDir(t *testing.T) string {
+	dataDir := filepath.Join(t.TempDir(), "circuit")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return dataDir
+}
+
+func TestLoadSRSTrimmed(t *testing.T) {
+	cs := compileSRSCircuit(t)
+	size := trusted_setup.SRSSize(cs)
+	cacheDir := filepath.Join(t.TempDir(), "srs")
+	opts := BuildOptions{SRSPath: writeCanonicalSRS(t, 64, 42), SRSCacheDir: cacheDir}
+
+	// The SRS of the local file is trimmed to the size of the circuit, and cached.
+	dataDir := newDataDir(t)
+	logger := &recordingLogger{}
+	srs, srsLagrange, err := loadSRS(dataDir, cs, opts, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := srsLen(srs); n != size {
+		t.Fatalf("expected an srs of %d points, got %d", size, n)
+	}
+	if _, _, err := plonk.Setup(cs, srs, srsLagrange); err != nil {
+		t.Fatal(err)
+	}
+	cachePath := filepath.Join(cacheDir, fmt.Sprintf("srs_%d.bin", size))
+	if logger.find("cached the trimmed srs", map[string]any{"path": cachePath}) == nil {
+		t.Fatalf("expected the trimmed srs to be cached, got %+v", logger.records)
+	}
+	for _, name := range []string{SRS_FILE, SRS_LAGRANGE_FILE} {
+		if _, err := os.Stat(filepath.Join(dataDir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Another data directory reads the cache without the local file, which would otherwise be
+	// downloaded.
+	logger = &recordingLogger{}
+	if _, _, err := loadSRS(newDataDir(t), cs, BuildOptions{SRSCacheDir: cacheDir}, logger); err != nil {
+		t.Fatal(err)
+	}
+	if logger.find("trimmed srs cache hit", map[string]any{"path": cachePath}) == nil {
+		t.Fatalf("expected a trimmed srs cache hit, got %+v", logger.records)
+	}
+
+	// The files of the data directory are still read first.
+	logger = &recordingLogger{}
+	if _, _, err := loadSRS(dataDir, cs, opts, logger); err != nil {
+		t.Fatal(err)
+	}
+	if logger.find("srs cache hit", map[string]any{"path": filepath.Join(dataDir, SRS_FILE)}) == nil {
+		t.Fatalf("expected an srs cache hit, got %+v", logger.records)
+	}
+
+	// A corrupted cache is trimmed again from the local file.
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 1
+	if err := os.WriteFile(cachePath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	logger = &recordingLogger{}
+	if _, _, err := loadSRS(newDataDir(t), cs, opts, logger); err != nil {
+		t.Fatal(err)
+	}
+	if logger.find("ignoring the cached srs", map[string]any{"path": cachePath}) == nil || logger.find("cached the trimmed srs", nil) == nil {
+		t.Fatalf("expected the corrupted srs to be replaced, got %+v", logger.records)
+	}
+}
+
+func TestLoadSRSRefused(t *testing.T) {
+	cs := compileSRSCircuit(t)
+	size := trusted_setup.SRSSize(cs)
+
+	// The G2 point of an SRS with another secret does not open the commitments of the first.
+	mixed := writeCanonicalSRS(t, 64, 42)
+	other, err := kzg_bn254.NewSRS(64, big.NewInt(43))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srs, err := readSRS(mixed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srs.(*kzg_bn254.SRS).Vk = other.Vk
+	file, err := os.Create(mixed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srs.WriteTo(file); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	for name, c := range map[string]struct {
+		path string
+		err  string
+	}{
+		"too small":    {writeCanonicalSRS(t, uint64(size-1), 42), "points"},
+		"inconsistent": {mixed, "sanity check"},
+		"missing":      {filepath.Join(t.TempDir(), "missing.bin"), "no such file"},
+	} {
+		dataDir := newDataDir(t)
+		_, _, err := loadSRS(dataDir, cs, BuildOptions{SRSPath: c.path}, &recordingLogger{})
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: expected an error containing %q, got %v", name, c.err, err)
+		}
+		if names := dirNames(t, dataDir); len(names) != 0 {
+			t.Errorf("%s: expected no file to be left in the data directory, got %v", name, names)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package sp1
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// staging stages files next to their destination in dir, so that a build that fails or is
+// cancelled leaves no partially written file, and the files of a previous build are only replaced
+// once all the new ones are written.
+type staging struct {
+	dir   string
+	names []string
+	temps map[string]string
+}
+
+func newStaging(dir string) *staging {
+	return &staging{dir: dir, temps: make(map[string]string)}
+}
+
+// path returns an empty temporary file standing for the artifact name, for writers taking a path.
+func (s *staging) path(name string) (string, error) {
+	if _, ok := s.temps[name]; ok {
+		return "", fmt.Errorf("artifact %s is staged twice", name)
+	}
+	file, err := os.CreateTemp(s.dir, "."+name+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	path := file.Name()
+	s.names = append(s.names, name)
+	s.temps[name] = path
+	if err := file.Close(); err != nil {
+		return "", err
+	}
+	return path, os.Chmod(path, 0644)
+}
+
+// write stages the artifact name with the contents written by write.
+func (s *staging) write(name string, write func(w io.Writer) error) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	if err := write(w); err != nil {
+		file.Close()
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return file.Close()
+}
+
+// commit renames the staged artifacts to their names, in the order they were staged.
+func (s *staging) commit() error {
+	for len(s.names) > 0 {
+		name := s.names[0]
+		if err := os.Rename(s.temps[name], filepath.Join(s.dir, name)); err != nil {
+			return err
+		}
+		s.names = s.names[1:]
+		delete(s.temps, name)
+	}
+	return nil
+}
+
+// discard removes the staged artifacts that were not committed.
+func (s *staging) discard() {
+	for _, name := range s.names {
+		os.Remove(s.temps[name])
+	}
+	s.names = nil
+	s.temps = make(map[string]string)
+}
@@ -0,0 +1,86 @@
+package sp1
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/consensys/gnark/constraint"
+	cs "github.com/consensys/gnark/constraint/bn254"
+	"github.com/consensys/gnark/constraint/solver"
+)
+
+// The number of consecutive instructions grouped into a range of the hotness statistics.
+var STATS_RANGE_SIZE int = 1000
+
+// The number of most expensive instruction ranges kept in the hotness statistics.
+var STATS_TOP_RANGES int = 10
+
+func init() {
+	solver.RegisterHint(instructionMarkerHint)
+}
+
+// InstructionRange is a range [Start, End) of instructions and the constraints they synthesized.
+type InstructionRange struct {
+	Start         int `json:"start"`
+	End           int `json:"end"`
+	NbConstraints int `json:"nb_constraints"`
+}
+
+// The hint called before every instruction when COLLECT_OPCODE_STATS is set. Its only purpose is to
+// leave an instruction in the constraint system recording how many constraints precede it.
+func instructionMarkerHint(_ *big.Int, _ []*big.Int, results []*big.Int) error {
+	results[0].SetUint64(0)
+	return nil
+}
+
+// addOpcodeStats attributes the constraints between consecutive instruction markers to the opcode
+// of each instruction. Constraints added after the last instruction, such as the range check
+// tables, are reported as deferred.
+func (r *BuildReport) addOpcodeStats(scs constraint.ConstraintSystem, opcodes []string) {
+	offsets := hintOffsets(scs, instructionMarkerHint)
+	if len(offsets) != len(opcodes)+1 {
+		return
+	}
+
+	r.OpcodeConstraints = make(map[string]int)
+	costs := make([]int, len(opcodes))
+	for i, opcode := range opcodes {
+		costs[i] = offsets[i+1] - offsets[i]
+		r.OpcodeConstraints[opcode] += costs[i]
+	}
+	r.DeferredConstraints = scs.GetNbConstraints() - offsets[len(opcodes)]
+
+	ranges := make([]InstructionRange, 0, len(opcodes)/STATS_RANGE_SIZE+1)
+	for start := 0; start < len(opcodes); start += STATS_RANGE_SIZE {
+		end := start + STATS_RANGE_SIZE
+		if end > len(opcodes) {
+			end = len(opcodes)
+		}
+		ranges = append(ranges, InstructionRange{Start: start, End: end, NbConstraints: offsets[end] - offsets[start]})
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].NbConstraints > ranges[j].NbConstraints })
+	if len(ranges) > STATS_TOP_RANGES {
+		ranges = ranges[:STATS_TOP_RANGES]
+	}
+	r.HotRanges = ranges
+}
+
+// hintOffsets returns, for every call to the hint in the constraint system, the number of
+// constraints that precede it.
+func hintOffsets(scs constraint.ConstraintSystem, hint solver.Hint) []int {
+	system, ok := scs.(*cs.SparseR1CS)
+	if !ok {
+		return nil
+	}
+	id := solver.GetHintID(hint)
+	var offsets []int
+	for i, instruction := range system.Instructions {
+		if _, ok := system.Blueprints[instruction.BlueprintID].(*constraint.BlueprintGenericHint); !ok {
+			continue
+		}
+		if solver.HintID(system.GetInstruction(i).Calldata[1]) == id {
+			offsets = append(offsets, int(instruction.ConstraintOffset))
+		}
+	}
+	return offsets
+}
@@ -0,0 +1,63 @@
+package sp1
+
+import (
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/scs"
+)
+
+func compileReport(t *testing.T, constraintsPath string) BuildReport {
+	t.Setenv("CONSTRAINTS_JSON", constraintsPath)
+	circuit := NewCircuit(readTestWitness(t, "testdata/basic_witness.json"))
+	compiled, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &circuit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewBuildReport(compiled, &circuit)
+}
+
+func TestOpcodeStats(t *testing.T) {
+	disabled := compileReport(t, "testdata/mule_constraints.json")
+	if disabled.OpcodeConstraints != nil || disabled.HotRanges != nil {
+		t.Fatal("expected opcode statistics to be off by default")
+	}
+
+	t.Setenv("COLLECT_OPCODE_STATS", "true")
+	STATS_RANGE_SIZE = 4
+	defer func() { STATS_RANGE_SIZE = 1000 }()
+	report := compileReport(t, "testdata/mule_constraints.json")
+
+	nbInstructions, nbConstraints := 0, report.DeferredConstraints
+	for opcode, count := range report.OpcodeCounts {
+		nbInstructions += count
+		nbConstraints += report.OpcodeConstraints[opcode]
+	}
+	if nbInstructions != report.NbInstructions || nbInstructions != 22 {
+		t.Fatalf("opcode counts sum to %d, expected %d", nbInstructions, report.NbInstructions)
+	}
+	if nbConstraints != report.NbConstraints {
+		t.Fatalf("attributed constraints sum to %d, expected %d", nbConstraints, report.NbConstraints)
+	}
+	if report.NbConstraints != disabled.NbConstraints {
+		t.Fatalf("collecting statistics changed the constraint count from %d to %d", disabled.NbConstraints, report.NbConstraints)
+	}
+
+	for opcode, cost := range report.OpcodeConstraints {
+		if opcode != "MulE" && cost >= report.OpcodeConstraints["MulE"] {
+			t.Fatalf("expected MulE to rank first, but %s has %d constraints against %d", opcode, cost, report.OpcodeConstraints["MulE"])
+		}
+	}
+	if len(report.HotRanges) != 6 {
+		t.Fatalf("expected 6 instruction ranges, got %d", len(report.HotRanges))
+	}
+	for i := 1; i < len(report.HotRanges); i++ {
+		if report.HotRanges[i].NbConstraints > report.HotRanges[i-1].NbConstraints {
+			t.Fatalf("instruction ranges are not sorted: %+v", report.HotRanges)
+		}
+	}
+	if report.HotRanges[0].Start == 0 || report.HotRanges[0].Start >= 16 {
+		t.Fatalf("expected the hottest range to contain MulE instructions, got %+v", report.HotRanges[0])
+	}
+}
@@ -0,0 +1,233 @@
+// Package store writes and reads the constraint system, keys and SRS of a circuit framed by a header
+// recording the format of the file, the circuit the artifact belongs to and the gnark version it
+// was serialized with. Reading refuses an artifact of another circuit or gnark release, or one
+// whose content does not match its header, instead of deserializing it into keys that fail the
+// proofs.
+//
+// A file starts with MAGIC, the big-endian uint32 size of the JSON encoded Header, and the header,
+// followed by the artifact serialized with its WriteTo method.
+package store
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+
+	"github.com/consensys/gnark"
+)
+
+// The magic bytes starting a file of the store, and the version of its format.
+const MAGIC = "SP1GNARK"
+const FORMAT_VERSION = 1
+
+// The largest header read, far above the size of any valid one.
+const maxHeaderSize = 1 << 16
+
+const gnarkModule = "github.com/consensys/gnark"
+
+// Kind is the kind of artifact of a file.
+type Kind string
+
+const (
+	CIRCUIT       Kind = "circuit"
+	PROVING_KEY   Kind = "proving_key"
+	VERIFYING_KEY Kind = "verifying_key"
+	// An SRS belongs to no circuit, and is stored with an empty circuit digest.
+	SRS Kind = "srs"
+)
+
+// Header describes the artifact of a file. The circuit digest of a constraint system is the digest
+// of its serialization, like sp1.CircuitDigest, and the one of a key is the digest of the
+// constraint system it was set up for.
+type Header struct {
+	FormatVersion int    `json:"format_version"`
+	Kind          Kind   `json:"kind"`
+	CircuitDigest string `json:"circuit_digest"`
+	GnarkVersion  string `json:"gnark_version"`
+	PayloadSize   int64  `json:"payload_size"`
+	PayloadDigest string `json:"payload_digest"`
+}
+
+// MismatchError reports a field of a header that does not have the expected value.
+type MismatchError struct {
+	Field    string
+	Expected string
+	Actual   string
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("the artifact has %s %q, expected %q", e.Field, e.Actual, e.Expected)
+}
+
+// GnarkVersion returns the version of the gnark module of the binary, which is a pseudo-version
+// between the releases, or the release of the gnark sources without build information.
+func GnarkVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path != gnarkModule {
+				continue
+			}
+			if dep.Replace != nil {
+				dep = dep.Replace
+			}
+			if dep.Version != "" && dep.Version != "(devel)" {
+				return dep.Version
+			}
+		}
+	}
+	return "v" + gnark.Version.String()
+}
+
+// Write writes artifact to w, framed by the header of an artifact of the given kind for the
+// circuit of digest circuitDigest. The circuit digest of a CIRCUIT is the digest of the artifact
+// itself, so circuitDigest may be empty for one. The artifact is serialized twice, first to hash
+// it into the header.
+func Write(w io.Writer, kind Kind, circuitDigest string, artifact io.WriterTo) error {
+	hasher := sha256.New()
+	size, err := artifact.WriteTo(hasher)
+	if err != nil {
+		return err
+	}
+	digest := hex.EncodeToString(hasher.Sum(nil))
+	if kind == CIRCUIT {
+		if circuitDigest != "" && circuitDigest != digest {
+			return &MismatchError{Field: "circuit digest", Expected: circuitDigest, Actual: digest}
+		}
+		circuitDigest = digest
+	}
+	header := Header{
+		FormatVersion: FORMAT_VERSION,
+		Kind:          kind,
+		CircuitDigest: circuitDigest,
+		GnarkVersion:  GnarkVersion(),
+		PayloadSize:   size,
+		PayloadDigest: digest,
+	}
+	if err := writeHeader(w, header); err != nil {
+		return err
+	}
+	n, err := artifact.WriteTo(w)
+	if err != nil {
+		return err
+	}
+	if n != size {
+		return fmt.Errorf("the artifact serialized to %d bytes, then %d", size, n)
+	}
+	return nil
+}
+
+func writeHeader(w io.Writer, header Header) error {
+	data, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(data)))
+	for _, b := range [][]byte{[]byte(MAGIC), size[:], data} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadHeader reads the header of a file of the store, without checking it.
+func ReadHeader(r io.Reader) (Header, error) {
+	var header Header
+	prefix := make([]byte, len(MAGIC)+4)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return header, fmt.Errorf("failed to read the header: %w", err)
+	}
+	if string(prefix[:len(MAGIC)]) != MAGIC {
+		return header, fmt.Errorf("not an artifact of the store")
+	}
+	size := binary.BigEndian.Uint32(prefix[len(MAGIC):])
+	if size > maxHeaderSize {
+		return header, fmt.Errorf("header of %d bytes", size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return header, fmt.Errorf("failed to read the header: %w", err)
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return header, fmt.Errorf("failed to decode the header: %w", err)
+	}
+	return header, nil
+}
+
+// Read reads an artifact of the given kind from r into artifact. It refuses, with a
+// *MismatchError, an artifact of another format version, kind or gnark version, or of another
+// circuit than the one of digest circuitDigest when it is not empty. The artifact must then have
+// the size and digest of its header.
+func Read(r io.Reader, kind Kind, circuitDigest string, artifact io.ReaderFrom) (Header, error) {
+	header, err := ReadHeader(r)
+	if err != nil {
+		return header, err
+	}
+	if header.FormatVersion != FORMAT_VERSION {
+		return header, &MismatchError{Field: "format version", Expected: fmt.Sprint(FORMAT_VERSION), Actual: fmt.Sprint(header.FormatVersion)}
+	}
+	if header.Kind != kind {
+		return header, &MismatchError{Field: "kind", Expected: string(kind), Actual: string(header.Kind)}
+	}
+	if version := GnarkVersion(); header.GnarkVersion != version {
+		return header, &MismatchError{Field: "gnark version", Expected: version, Actual: header.GnarkVersion}
+	}
+	if circuitDigest != "" && header.CircuitDigest != circuitDigest {
+		return header, &MismatchError{Field: "circuit digest", Expected: circuitDigest, Actual: header.CircuitDigest}
+	}
+
+	// The artifact may read ahead of what it decodes, so it is limited to the payload, whose
+	// remainder is hashed after it. A corrupted payload is reported as such even when it fails to
+	// decode.
+	hasher := sha256.New()
+	payload := io.TeeReader(io.LimitReader(r, header.PayloadSize), hasher)
+	_, readErr := artifact.ReadFrom(payload)
+	if _, err := io.Copy(io.Discard, payload); err != nil {
+		return header, err
+	}
+	digest := hex.EncodeToString(hasher.Sum(nil))
+	if digest != header.PayloadDigest {
+		return header, &MismatchError{Field: "payload digest", Expected: header.PayloadDigest, Actual: digest}
+	}
+	if readErr != nil {
+		return header, fmt.Errorf("failed to read the %s: %w", kind, readErr)
+	}
+	if kind == CIRCUIT && digest != header.CircuitDigest {
+		return header, &MismatchError{Field: "circuit digest", Expected: digest, Actual: header.CircuitDigest}
+	}
+	return header, nil
+}
+
+// WriteFile is Write to the file at path.
+func WriteFile(path string, kind Kind, circuitDigest string, artifact io.WriterTo) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w := bufio.NewWriterSize(file, 1024*1024)
+	if err := Write(w, kind, circuitDigest, artifact); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// ReadFile is Read from the file at path.
+func ReadFile(path string, kind Kind, circuitDigest string, artifact io.ReaderFrom) (Header, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Header{}, err
+	}
+	defer file.Close()
+	return Read(bufio.NewReaderSize(file, 1024*1024), kind, circuitDigest, artifact)
+}
@@ -0,0 +1,179 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/scs"
+	"github.com/consensys/gnark/test/unsafekzg"
+)
+
+// cubeCircuit proves the knowledge of a cube root of Y - offset, so that each offset is another
+// circuit.
+type cubeCircuit struct {
+	X frontend.Variable
+	Y frontend.Variable `gnark:",public"`
+
+	offset int `gnark:"-"`
+}
+
+func (circuit *cubeCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(api.Add(api.Mul(circuit.X, circuit.X, circuit.X), circuit.offset), circuit.Y)
+	return nil
+}
+
+type artifacts struct {
+	cs constraint.ConstraintSystem
+	pk plonk.ProvingKey
+	vk plonk.VerifyingKey
+}
+
+func setup(t *testing.T, offset int) artifacts {
+	cs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &cubeCircuit{offset: offset})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srs, srsLagrange, err := unsafekzg.NewSRS(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, vk, err := plonk.Setup(cs, srs, srsLagrange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return artifacts{cs: cs, pk: pk, vk: vk}
+}
+
+func write(t *testing.T, kind Kind, circuitDigest string, artifact io.WriterTo) []byte {
+	var buf bytes.Buffer
+	if err := Write(&buf, kind, circuitDigest, artifact); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// rewriteHeader returns data with its header changed by mutate.
+func rewriteHeader(t *testing.T, data []byte, mutate func(*Header)) []byte {
+	r := bytes.NewReader(data)
+	header, err := ReadHeader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mutate(&header)
+	var buf bytes.Buffer
+	if err := writeHeader(&buf, header); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	a := setup(t, 0)
+	dir := t.TempDir()
+	circuitPath := filepath.Join(dir, "circuit.bin")
+	if err := WriteFile(circuitPath, CIRCUIT, "", a.cs); err != nil {
+		t.Fatal(err)
+	}
+	cs := plonk.NewCS(ecc.BN254)
+	header, err := ReadFile(circuitPath, CIRCUIT, "", cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.CircuitDigest != header.PayloadDigest || header.GnarkVersion != GnarkVersion() || header.FormatVersion != FORMAT_VERSION {
+		t.Fatalf("unexpected header %+v", header)
+	}
+	if cs.GetNbConstraints() != a.cs.GetNbConstraints() {
+		t.Fatalf("read a circuit of %d constraints, wrote %d", cs.GetNbConstraints(), a.cs.GetNbConstraints())
+	}
+
+	// The keys are read for the circuit read before them.
+	pkPath := filepath.Join(dir, "pk.bin")
+	if err := WriteFile(pkPath, PROVING_KEY, header.CircuitDigest, a.pk); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadFile(pkPath, PROVING_KEY, header.CircuitDigest, plonk.NewProvingKey(ecc.BN254)); err != nil {
+		t.Fatal(err)
+	}
+	vk := plonk.NewVerifyingKey(ecc.BN254)
+	if _, err := Read(bytes.NewReader(write(t, VERIFYING_KEY, header.CircuitDigest, a.vk)), VERIFYING_KEY, header.CircuitDigest, vk); err != nil {
+		t.Fatal(err)
+	}
+	var expected, actual bytes.Buffer
+	a.vk.WriteTo(&expected)
+	vk.WriteTo(&actual)
+	if !bytes.Equal(expected.Bytes(), actual.Bytes()) {
+		t.Fatal("the verifying key did not round trip")
+	}
+
+	if version := GnarkVersion(); !strings.HasPrefix(version, "v0.") {
+		t.Errorf("unexpected gnark version %q", version)
+	}
+}
+
+func TestReadMismatch(t *testing.T) {
+	a, other := setup(t, 0), setup(t, 1)
+	circuit := write(t, CIRCUIT, "", a.cs)
+	header, err := ReadHeader(bytes.NewReader(circuit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := header.CircuitDigest
+	otherCircuit := write(t, CIRCUIT, "", other.cs)
+	otherHeader, err := ReadHeader(bytes.NewReader(otherCircuit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otherHeader.CircuitDigest == digest {
+		t.Fatal("expected the circuits to have different digests")
+	}
+	vk := write(t, VERIFYING_KEY, digest, a.vk)
+
+	tampered := append([]byte(nil), vk...)
+	tampered[len(tampered)-1] ^= 1
+	for name, c := range map[string]struct {
+		data  []byte
+		kind  Kind
+		field string
+	}{
+		"format version": {rewriteHeader(t, vk, func(h *Header) { h.FormatVersion++ }), VERIFYING_KEY, "format version"},
+		"kind":           {vk, PROVING_KEY, "kind"},
+		"gnark version":  {rewriteHeader(t, vk, func(h *Header) { h.GnarkVersion = "v0.9.1" }), VERIFYING_KEY, "gnark version"},
+		"other circuit":  {write(t, VERIFYING_KEY, otherHeader.CircuitDigest, other.vk), VERIFYING_KEY, "circuit digest"},
+		"payload":        {tampered, VERIFYING_KEY, "payload digest"},
+		"relabelled":     {rewriteHeader(t, otherCircuit, func(h *Header) { h.CircuitDigest = digest }), CIRCUIT, "circuit digest"},
+	} {
+		var artifact io.ReaderFrom = plonk.NewVerifyingKey(ecc.BN254)
+		if c.kind == CIRCUIT {
+			artifact = plonk.NewCS(ecc.BN254)
+		}
+		_, err := Read(bytes.NewReader(c.data), c.kind, digest, artifact)
+		var mismatch *MismatchError
+		if !errors.As(err, &mismatch) || mismatch.Field != c.field {
+			t.Errorf("%s: expected a %s mismatch, got %v", name, c.field, err)
+		}
+	}
+
+	if err := Write(io.Discard, CIRCUIT, otherHeader.CircuitDigest, a.cs); err == nil {
+		t.Error("expected a circuit written with the digest of another to be refused")
+	}
+	for name, data := range map[string][]byte{
+		"raw":       func() []byte { var buf bytes.Buffer; a.vk.WriteTo(&buf); return buf.Bytes() }(),
+		"truncated": vk[:len(vk)-10],
+		"header":    vk[:len(MAGIC)+2],
+	} {
+		if _, err := Read(bytes.NewReader(data), VERIFYING_KEY, digest, plonk.NewVerifyingKey(ecc.BN254)); err == nil {
+			t.Errorf("%s: expected the artifact to be refused", name)
+		}
+	}
+}
@@ -0,0 +1,434 @@
+package sp1
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/poseidon2"
+)
+
+// The number of most recent writes of each involved id that a TraceFailure reports.
+var TRACE_HISTORY int = 8
+
+// TraceReport is the result of evaluating a constraint stream outside of the circuit.
+type TraceReport struct {
+	NbInstructions int               `json:"nb_instructions"`
+	Vars           map[string]string `json:"vars"`
+	Felts          map[string]string `json:"felts"`
+	Exts           map[string]string `json:"exts"`
+	// The first instruction whose check does not hold, or nil if the witness satisfies every check.
+	Failure *TraceFailure `json:"failure,omitempty"`
+}
+
+// TraceFailure describes the first failing instruction of a trace.
+type TraceFailure struct {
+	Index   int                     `json:"index"`
+	Opcode  string                  `json:"opcode"`
+	Left    string                  `json:"left"`
+	Right   string                  `json:"right"`
+	Message string                  `json:"message"`
+	Writes  map[string][]TraceWrite `json:"writes"`
+}
+
+// TraceWrite is an instruction that assigned an id.
+type TraceWrite struct {
+	Index  int    `json:"index"`
+	Opcode string `json:"opcode"`
+}
+
+func (f *TraceFailure) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "instruction %d (%s) failed: %s: %s != %s", f.Index, f.Opcode, f.Message, f.Left, f.Right)
+	ids := make([]string, 0, len(f.Writes))
+	for id := range f.Writes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		fmt.Fprintf(&sb, "\n  %s written by", id)
+		for _, w := range f.Writes[id] {
+			fmt.Fprintf(&sb, " %d (%s)", w.Index, w.Opcode)
+		}
+	}
+	return sb.String()
+}
+
+// Trace evaluates the constraint stream natively on the witness, recording the value of every id,
+// and reports the first instruction whose check fails. Unlike RunTestEngine it does not use gnark,
+// so it pinpoints the failing instruction and the instructions that produced its operands.
+func Trace(constraintsPath string, witnessPath string) (*TraceReport, error) {
+	constraints, err := ReadConstraints(constraintsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	witnessInput, err := ReadWitnessInput(witnessPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return traceConstraints(constraints, witnessInput)
+}
+
+func traceConstraints(constraints []Constraint, witnessInput WitnessInput) (*TraceReport, error) {
+	t := newTracer(witnessInput)
+	for i, cs := range constraints {
+		t.index = i
+		t.opcode = cs.Opcode
+		if err := t.step(cs); err != nil {
+			return nil, fmt.Errorf("instruction %d (%s): %w", i, cs.Opcode, err)
+		}
+		if t.failure != nil {
+			break
+		}
+	}
+	return t.report(len(constraints)), nil
+}
+
+type tracer struct {
+	witness WitnessInput
+	vars    map[string]*big.Int
+	felts   map[string]uint64
+	exts    map[string][4]uint64
+	writes  map[string][]TraceWrite
+	index   int
+	opcode  string
+	failure *TraceFailure
+}
+
+func newTracer(witness WitnessInput) *tracer {
+	return &tracer{
+		witness: witness,
+		vars:    make(map[string]*big.Int),
+		felts:   make(map[string]uint64),
+		exts:    make(map[string][4]uint64),
+		writes:  make(map[string][]TraceWrite),
+	}
+}
+
+func (t *tracer) step(cs Constraint) error {
+	p := babybear.MODULUS.Uint64()
+	switch cs.Opcode {
+	case "ImmV":
+		v, err := parseVar(cs.Args[1][0])
+		if err != nil {
+			return err
+		}
+		t.setV(cs.Args[0][0], v)
+	case "ImmF":
+		f, err := parseFelt(cs.Args[1][0])
+		if err != nil {
+			return err
+		}
+		t.setF(cs.Args[0][0], f)
+	case "ImmE":
+		var e [4]uint64
+		for i := 0; i < 4; i++ {
+			f, err := parseFelt(cs.Args[1][i])
+			if err != nil {
+				return err
+			}
+			e[i] = f
+		}
+		t.setE(cs.Args[0][0], e)
+	case "AddV":
+		t.setV(cs.Args[0][0], new(big.Int).Add(t.v(cs.Args[1][0]), t.v(cs.Args[2][0])))
+	case "AddF":
+		t.setF(cs.Args[0][0], (t.f(cs.Args[1][0])+t.f(cs.Args[2][0]))%p)
+	case "AddE":
+		t.setE(cs.Args[0][0], canonicalExt(babybear.BabyBear.AddE(bigExt(t.e(cs.Args[1][0])), bigExt(t.e(cs.Args[2][0])))))
+	case "AddEF":
+		t.setE(cs.Args[0][0], canonicalExt(babybear.BabyBear.AddE(bigExt(t.e(cs.Args[1][0])), bigExt([4]uint64{t.f(cs.Args[2][0])}))))
+	case "SubV":
+		t.setV(cs.Args[0][0], new(big.Int).Sub(t.v(cs.Args[1][0]), t.v(cs.Args[2][0])))
+	case "SubF":
+		t.setF(cs.Args[0][0], (t.f(cs.Args[1][0])+p-t.f(cs.Args[2][0]))%p)
+	case "SubE":
+		t.setE(cs.Args[0][0], canonicalExt(babybear.BabyBear.SubE(bigExt(t.e(cs.Args[1][0])), bigExt(t.e(cs.Args[2][0])))))
+	case "SubEF":
+		t.setE(cs.Args[0][0], canonicalExt(babybear.BabyBear.SubE(bigExt(t.e(cs.Args[1][0])), bigExt([4]uint64{t.f(cs.Args[2][0])}))))
+	case "MulV":
+		t.setV(cs.Args[0][0], new(big.Int).Mul(t.v(cs.Args[1][0]), t.v(cs.Args[2][0])))
+	case "MulF":
+		t.setF(cs.Args[0][0], t.f(cs.Args[1][0])*t.f(cs.Args[2][0])%p)
+	case "MulAddF":
+		t.setF(cs.Args[0][0], (t.f(cs.Args[1][0])*t.f(cs.Args[2][0])+t.f(cs.Args[3][0]))%p)
+	case "MulE":
+		t.setE(cs.Args[0][0], canonicalExt(babybear.BabyBear.MulE(bigExt(t.e(cs.Args[1][0])), bigExt(t.e(cs.Args[2][0])))))
+	case "MulEF":
+		t.setE(cs.Args[0][0], canonicalExt(babybear.BabyBear.MulE(bigExt(t.e(cs.Args[1][0])), bigExt([4]uint64{t.f(cs.Args[2][0])}))))
+	case "DivE":
+		b := t.e(cs.Args[2][0])
+		if b == [4]uint64{} {
+			t.fail("division by zero", formatExt(b), "non-zero", cs.Args[2][0])
+			return nil
+		}
+		t.setE(cs.Args[0][0], canonicalExt(babybear.BabyBear.MulE(bigExt(t.e(cs.Args[1][0])), babybear.BabyBear.InvE(bigExt(b)))))
+	case "SumE":
+		sum := bigExt([4]uint64{})
+		for i := 1; i < len(cs.Args); i++ {
+			sum = babybear.BabyBear.AddE(sum, bigExt(t.e(cs.Args[i][0])))
+		}
+		t.setE(cs.Args[0][0], canonicalExt(sum))
+	case "NegE":
+		t.setE(cs.Args[0][0], canonicalExt(babybear.BabyBear.SubE(bigExt([4]uint64{}), bigExt(t.e(cs.Args[1][0])))))
+	case "InvE":
+		a := t.e(cs.Args[1][0])
+		if a == [4]uint64{} {
+			t.fail("inverse of zero", formatExt(a), "non-zero", cs.Args[1][0])
+			return nil
+		}
+		t.setE(cs.Args[0][0], canonicalExt(babybear.BabyBear.InvE(bigExt(a))))
+	case "Num2BitsV":
+		numBits, err := strconv.Atoi(cs.Args[2][0])
+		if err != nil {
+			return fmt.Errorf("error converting number of bits to int: %v", err)
+		}
+		v := t.v(cs.Args[1][0])
+		if v.BitLen() > numBits {
+			t.fail(fmt.Sprintf("value does not fit in %d bits", numBits), v.String(), fmt.Sprintf("< 2^%d", numBits), cs.Args[1][0])
+			return nil
+		}
+		for i := 0; i < len(cs.Args[0]); i++ {
+			t.setV(cs.Args[0][i], big.NewInt(int64(v.Bit(i))))
+		}
+	case "Num2BitsF":
+		f := t.f(cs.Args[1][0])
+		for i := 0; i < len(cs.Args[0]); i++ {
+			t.setV(cs.Args[0][i], big.NewInt(int64(f>>i&1)))
+		}
+	case "Permute":
+		var state [poseidon2.WIDTH]*big.Int
+		for i := 0; i < poseidon2.WIDTH; i++ {
+			state[i] = t.v(cs.Args[i][0])
+		}
+		poseidon2.PermuteNative(&state)
+		for i := 0; i < poseidon2.WIDTH; i++ {
+			t.setV(cs.Args[i][0], state[i])
+		}
+	case "PermuteBabyBear":
+		var state [poseidon2.BABYBEAR_WIDTH]uint64
+		for i := 0; i < poseidon2.BABYBEAR_WIDTH; i++ {
+			state[i] = t.f(cs.Args[i][0])
+		}
+		poseidon2.PermuteBabyBearNative(&state)
+		for i := 0; i < poseidon2.BABYBEAR_WIDTH; i++ {
+			t.setF(cs.Args[i][0], state[i])
+		}
+	case "SelectV", "SelectF", "SelectE":
+		cond := t.v(cs.Args[1][0])
+		if !cond.IsUint64() || cond.Uint64() > 1 {
+			t.fail("selector is not boolean", cond.String(), "0 or 1", cs.Args[1][0])
+			return nil
+		}
+		choice := cs.Args[3][0]
+		if cond.Uint64() == 1 {
+			choice = cs.Args[2][0]
+		}
+		switch cs.Opcode {
+		case "SelectV":
+			t.setV(cs.Args[0][0], t.v(choice))
+		case "SelectF":
+			t.setF(cs.Args[0][0], t.f(choice))
+		case "SelectE":
+			t.setE(cs.Args[0][0], t.e(choice))
+		}
+	case "Ext2Felt":
+		e := t.e(cs.Args[4][0])
+		for i := 0; i < 4; i++ {
+			t.setF(cs.Args[i][0], e[i])
+		}
+	case "AssertEqV":
+		a, b := t.v(cs.Args[0][0]), t.v(cs.Args[1][0])
+		if a.Cmp(b) != 0 {
+			t.fail("values differ", a.String(), b.String(), cs.Args[0][0], cs.Args[1][0])
+		}
+	case "AssertEqF":
+		a, b := t.f(cs.Args[0][0]), t.f(cs.Args[1][0])
+		if a != b {
+			t.fail("values differ", strconv.FormatUint(a, 10), strconv.FormatUint(b, 10), cs.Args[0][0], cs.Args[1][0])
+		}
+	case "AssertEqE":
+		a, b := t.e(cs.Args[0][0]), t.e(cs.Args[1][0])
+		if a != b {
+			t.fail("values differ", formatExt(a), formatExt(b), cs.Args[0][0], cs.Args[1][0])
+		}
+	case "PrintV", "PrintF", "PrintE", "CycleTracker":
+	case "WitnessV", "WitnessF", "WitnessE":
+		i, err := strconv.Atoi(cs.Args[1][0])
+		if err != nil {
+			return err
+		}
+		switch cs.Opcode {
+		case "WitnessV":
+			if i < 0 || i >= len(t.witness.Vars) {
+				return fmt.Errorf("witness var %d out of range", i)
+			}
+			v, err := parseVar(t.witness.Vars[i])
+			if err != nil {
+				return err
+			}
+			t.setV(cs.Args[0][0], v)
+		case "WitnessF":
+			if i < 0 || i >= len(t.witness.Felts) {
+				return fmt.Errorf("witness felt %d out of range", i)
+			}
+			f, err := parseFelt(t.witness.Felts[i])
+			if err != nil {
+				return err
+			}
+			t.setF(cs.Args[0][0], f)
+		case "WitnessE":
+			if i < 0 || i >= len(t.witness.Exts) {
+				return fmt.Errorf("witness ext %d out of range", i)
+			}
+			var e [4]uint64
+			for j := 0; j < 4; j++ {
+				f, err := parseFelt(t.witness.Exts[i][j])
+				if err != nil {
+					return err
+				}
+				e[j] = f
+			}
+			t.setE(cs.Args[0][0], e)
+		}
+	case "CommitVkeyHash", "CommitCommitedValuesDigest":
+		public := t.witness.VkeyHash
+		if cs.Opcode == "CommitCommitedValuesDigest" {
+			public = t.witness.CommitedValuesDigest
+		}
+		expected, err := parseVar(public)
+		if err != nil {
+			return err
+		}
+		if v := t.v(cs.Args[0][0]); v.Cmp(expected) != 0 {
+			t.fail("committed value differs from the public input", v.String(), expected.String(), cs.Args[0][0])
+		}
+	case "CircuitFelts2Ext":
+		var e [4]uint64
+		for i := 0; i < 4; i++ {
+			e[i] = t.f(cs.Args[i+1][0])
+		}
+		t.setE(cs.Args[0][0], e)
+	default:
+		return fmt.Errorf("unhandled opcode: %s", cs.Opcode)
+	}
+
+	return nil
+}
+
+func (t *tracer) v(id string) *big.Int {
+	if v, ok := t.vars[id]; ok {
+		return new(big.Int).Set(v)
+	}
+	return new(big.Int)
+}
+
+func (t *tracer) f(id string) uint64 {
+	return t.felts[id]
+}
+
+func (t *tracer) e(id string) [4]uint64 {
+	return t.exts[id]
+}
+
+func (t *tracer) setV(id string, v *big.Int) {
+	t.vars[id] = v.Mod(v, ecc.BN254.ScalarField())
+	t.recordWrite(id)
+}
+
+func (t *tracer) setF(id string, f uint64) {
+	t.felts[id] = f
+	t.recordWrite(id)
+}
+
+func (t *tracer) setE(id string, e [4]uint64) {
+	t.exts[id] = e
+	t.recordWrite(id)
+}
+
+func (t *tracer) recordWrite(id string) {
+	writes := append(t.writes[id], TraceWrite{Index: t.index, Opcode: t.opcode})
+	if len(writes) > TRACE_HISTORY {
+		writes = writes[len(writes)-TRACE_HISTORY:]
+	}
+	t.writes[id] = writes
+}
+
+func (t *tracer) fail(message string, left string, right string, ids ...string) {
+	writes := make(map[string][]TraceWrite)
+	for _, id := range ids {
+		writes[id] = t.writes[id]
+	}
+	t.failure = &TraceFailure{
+		Index:   t.index,
+		Opcode:  t.opcode,
+		Left:    left,
+		Right:   right,
+		Message: message,
+		Writes:  writes,
+	}
+}
+
+func (t *tracer) report(nbInstructions int) *TraceReport {
+	report := &TraceReport{
+		NbInstructions: nbInstructions,
+		Vars:           make(map[string]string, len(t.vars)),
+		Felts:          make(map[string]string, len(t.felts)),
+		Exts:           make(map[string]string, len(t.exts)),
+		Failure:        t.failure,
+	}
+	for id, v := range t.vars {
+		report.Vars[id] = v.String()
+	}
+	for id, f := range t.felts {
+		report.Felts[id] = strconv.FormatUint(f, 10)
+	}
+	for id, e := range t.exts {
+		report.Exts[id] = formatExt(e)
+	}
+	return report
+}
+
+func parseVar(s string) (*big.Int, error) {
+	v, ok := new(big.Int).SetString(s, 0)
+	if !ok {
+		return nil, fmt.Errorf("%q is not a number", s)
+	}
+	return v.Mod(v, ecc.BN254.ScalarField()), nil
+}
+
+func parseFelt(s string) (uint64, error) {
+	v, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	return v.Mod(v, babybear.MODULUS).Uint64(), nil
+}
+
+// bigExt and canonicalExt convert between the canonical coordinates the tracer stores and the
+// operands of the extension arithmetic of babybear.BabyBear.
+func bigExt(a [4]uint64) [4]*big.Int {
+	var out [4]*big.Int
+	for i := range out {
+		out[i] = new(big.Int).SetUint64(a[i])
+	}
+	return out
+}
+
+func canonicalExt(a [4]*big.Int) [4]uint64 {
+	var out [4]uint64
+	for i := range out {
+		out[i] = a[i].Uint64()
+	}
+	return out
+}
+
+func formatExt(e [4]uint64) string {
+	return fmt.Sprintf("[%d, %d, %d, %d]", e[0], e[1], e[2], e[3])
+}
@@ -0,0 +1,69 @@
+package sp1
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	report, err := Trace("testdata/basic_constraints.json", "testdata/basic_witness.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Failure != nil {
+		t.Fatalf("unexpected failure: %s", report.Failure)
+	}
+	if report.NbInstructions != 14 {
+		t.Fatalf("expected 14 instructions, got %d", report.NbInstructions)
+	}
+	if report.Felts["f2"] != "15" {
+		t.Fatalf("expected f2 = 15, got %s", report.Felts["f2"])
+	}
+	if report.Exts["e2"] != "[1, 0, 0, 0]" {
+		t.Fatalf("expected e0 * e0^-1 = 1, got %s", report.Exts["e2"])
+	}
+}
+
+func TestTraceCorruptedWitness(t *testing.T) {
+	data, err := os.ReadFile("testdata/basic_witness.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var witnessInput WitnessInput
+	if err := json.Unmarshal(data, &witnessInput); err != nil {
+		t.Fatal(err)
+	}
+	witnessInput.Felts[2] = "16"
+	data, err = json.Marshal(witnessInput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	witnessPath := filepath.Join(t.TempDir(), "witness.json")
+	if err := os.WriteFile(witnessPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Trace("testdata/basic_constraints.json", witnessPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failure := report.Failure
+	if failure == nil {
+		t.Fatal("expected the corrupted witness to be rejected")
+	}
+	t.Log(failure)
+	if failure.Index != 4 || failure.Opcode != "AssertEqF" || failure.Left != "15" || failure.Right != "16" {
+		t.Fatalf("unexpected failure: %+v", failure)
+	}
+	if w := failure.Writes["f2"]; len(w) != 1 || w[0].Index != 2 || w[0].Opcode != "MulF" {
+		t.Fatalf("unexpected writes of f2: %+v", w)
+	}
+	if w := failure.Writes["f3"]; len(w) != 1 || w[0].Index != 3 || w[0].Opcode != "WitnessF" {
+		t.Fatalf("unexpected writes of f3: %+v", w)
+	}
+	if _, ok := report.Exts["e0"]; ok {
+		t.Fatal("expected the trace to stop at the failing instruction")
+	}
+}
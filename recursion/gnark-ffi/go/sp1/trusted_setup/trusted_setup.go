@@ -1,28 +1,29 @@
 package trusted_setup
 
 import (
-	"log"
+	"errors"
+	"fmt"
 	"os"
 
-	stdbits "math/bits"
-
+	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark-crypto/ecc/bn254"
 	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
 	kzg_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/kzg"
 	"github.com/consensys/gnark-crypto/kzg"
 	"github.com/consensys/gnark-ignition-verifier/ignition"
 	"github.com/consensys/gnark/constraint"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/logging"
 )
 
-func sanityCheck(srs *kzg_bn254.SRS) {
+func sanityCheck(srs *kzg_bn254.SRS) error {
 	// we can now use the SRS to verify a proof
 	// create a polynomial
-	f := randomPolynomial(60)
+	f := randomPolynomial(min(60, len(srs.Pk.G1)))
 
 	// commit the polynomial
 	digest, err := kzg_bn254.Commit(f, srs.Pk)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	// compute opening proof at a random point
@@ -30,20 +31,30 @@ func sanityCheck(srs *kzg_bn254.SRS) {
 	point.SetString("4321")
 	proof, err := kzg_bn254.Open(f, point, srs.Pk)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	// verify the claimed valued
 	expected := eval(f, point)
 	if !proof.ClaimedValue.Equal(&expected) {
-		log.Fatal("inconsistent claimed value")
+		return errors.New("inconsistent claimed value")
 	}
 
 	// verify correct proof
-	err = kzg_bn254.Verify(&digest, &proof, point, srs.Vk)
-	if err != nil {
-		log.Fatal(err)
+	return kzg_bn254.Verify(&digest, &proof, point, srs.Vk)
+}
+
+// SanityCheck checks that a KZG commitment with canonicalSRS opens at a random point, which fails
+// unless its G1 points are the powers of the secret of its G2 point.
+func SanityCheck(canonicalSRS kzg.SRS) error {
+	srs, ok := canonicalSRS.(*kzg_bn254.SRS)
+	if !ok {
+		return errors.New("unrecognized curve")
+	}
+	if len(srs.Pk.G1) < 2 {
+		return fmt.Errorf("srs of %d points", len(srs.Pk.G1))
 	}
+	return sanityCheck(srs)
 }
 
 func randomPolynomial(size int) []fr.Element {
@@ -66,7 +77,15 @@ func eval(p []fr.Element, point fr.Element) fr.Element {
 	return res
 }
 
-func DownloadAndSaveAztecIgnitionSrs(startIdx int, fileName string) {
+// DownloadAndSaveAztecIgnitionSrs verifies the contributions to the Aztec Ignition ceremony from
+// startIdx on, and saves the SRS of the last one to fileName. It logs its progress to logger, and
+// logs and panics on failure.
+func DownloadAndSaveAztecIgnitionSrs(startIdx int, fileName string, logger logging.Logger) {
+	fatal := func(msg string, err error, keyvals ...any) {
+		logger.Error(msg, append(keyvals, "error", err)...)
+		panic(fmt.Errorf("%s: %w", msg, err))
+	}
+
 	config := ignition.Config{
 		BaseURL:  "https://aztec-ignition.s3.amazonaws.com/",
 		Ceremony: "MAIN IGNITION", // "TINY_TEST_5"
@@ -77,43 +96,42 @@ func DownloadAndSaveAztecIgnitionSrs(startIdx int, fileName string) {
 		err := os.MkdirAll(config.CacheDir, os.ModePerm)
 
 		if err != nil {
-			log.Fatal("when creating cache dir: ", err)
-			panic(err)
+			fatal("when creating cache dir", err)
 		}
 	}
 
-	log.Println("fetch manifest")
+	logger.Info("fetch manifest", "base_url", config.BaseURL)
 
 	manifest, err := ignition.NewManifest(config)
 
 	if err != nil {
-		log.Fatal("when fetching manifest: ", err)
+		fatal("when fetching manifest", err)
 	}
 
 	current, next := ignition.NewContribution(manifest.NumG1Points), ignition.NewContribution(manifest.NumG1Points)
 
 	if err := current.Get(manifest.Participants[startIdx], config); err != nil {
-		log.Fatal("when fetching contribution: ", err)
+		fatal("when fetching contribution", err, "contribution", startIdx)
 	}
 	if err := next.Get(manifest.Participants[startIdx+1], config); err != nil {
-		log.Fatal("when fetching contribution: ", err)
+		fatal("when fetching contribution", err, "contribution", startIdx+1)
 	}
 	if !next.Follows(&current) {
-		log.Fatalf("contribution %d does not follow contribution %d", startIdx+1, startIdx)
+		fatal("contribution does not follow the previous one", errors.New("invalid contribution"), "contribution", startIdx+1)
 	}
 
 	for i := startIdx + 2; i < len(manifest.Participants); i++ {
-		log.Println("processing contribution ", i+1)
+		logger.Info("processing contribution", "contribution", i+1, "participants", len(manifest.Participants))
 		current, next = next, current
 		if err := next.Get(manifest.Participants[i], config); err != nil {
-			log.Fatal("when fetching contribution ", i+1, ": ", err)
+			fatal("when fetching contribution", err, "contribution", i+1)
 		}
 		if !next.Follows(&current) {
-			log.Fatal("contribution ", i+1, " does not follow contribution ", i, ": ", err)
+			fatal("contribution does not follow the previous one", errors.New("invalid contribution"), "contribution", i+1)
 		}
 	}
 
-	log.Println("success ✅: all contributions are valid")
+	logger.Info("all contributions are valid")
 
 	_, _, _, g2gen := bn254.Generators()
 	// we use the last contribution to build a kzg SRS for bn254
@@ -131,31 +149,56 @@ func DownloadAndSaveAztecIgnitionSrs(startIdx int, fileName string) {
 	}
 
 	// sanity check
-	sanityCheck(&srs)
-	log.Println("success ✅: kzg sanity check with SRS")
+	if err := sanityCheck(&srs); err != nil {
+		fatal("kzg sanity check with SRS failed", err)
+	}
+	logger.Info("kzg sanity check with SRS passed")
 
 	fSRS, err := os.Create(fileName)
 	if err != nil {
-		log.Fatal("error creating srs file: ", err)
-		panic(err)
+		fatal("error creating srs file", err, "path", fileName)
 	}
 	defer fSRS.Close()
 
 	_, err = srs.WriteTo(fSRS)
 	if err != nil {
-		log.Fatal("error writing srs file: ", err)
-		panic(err)
+		fatal("error writing srs file", err, "path", fileName)
+	}
+}
+
+// domainSize returns the size of the evaluation domain of PLONK for scs.
+func domainSize(scs constraint.ConstraintSystem) int {
+	return int(ecc.NextPowerOfTwo(uint64(scs.GetNbPublicVariables() + scs.GetNbConstraints())))
+}
+
+// SRSSize returns the number of points of the canonical SRS that PLONK sets scs up with: one per
+// element of its evaluation domain, and 3 more for the blinding of the polynomials.
+func SRSSize(scs constraint.ConstraintSystem) int {
+	return domainSize(scs) + 3
+}
+
+// Trim returns canonicalSRS restricted to its first size points, which is the SRS of the circuits
+// of that SRSSize.
+func Trim(canonicalSRS kzg.SRS, size int) (kzg.SRS, error) {
+	srs, ok := canonicalSRS.(*kzg_bn254.SRS)
+	if !ok {
+		return nil, errors.New("unrecognized curve")
+	}
+	if len(srs.Pk.G1) < size {
+		return nil, fmt.Errorf("srs of %d points, %d are needed", len(srs.Pk.G1), size)
 	}
+	return &kzg_bn254.SRS{Pk: kzg_bn254.ProvingKey{G1: srs.Pk.G1[:size]}, Vk: srs.Vk}, nil
 }
 
+// ToLagrange converts the first points of canonicalSRS to the Lagrange basis of the evaluation
+// domain of scs.
 func ToLagrange(scs constraint.ConstraintSystem, canonicalSRS kzg.SRS) kzg.SRS {
 	var lagrangeSRS kzg.SRS
 
 	switch srs := canonicalSRS.(type) {
 	case *kzg_bn254.SRS:
 		var err error
-		sizeSystem := scs.GetNbPublicVariables() + scs.GetNbConstraints()
-		nextPowerTwo := 1 << stdbits.Len(uint(sizeSystem))
+		nextPowerTwo := domainSize(scs)
 		newSRS := &kzg_bn254.SRS{Vk: srs.Vk}
 		newSRS.Pk.G1, err = kzg_bn254.ToLagrangeG1(srs.Pk.G1[:nextPowerTwo])
 		if err != nil {
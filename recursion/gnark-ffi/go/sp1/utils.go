@@ -42,7 +42,7 @@ func NewCircuit(witnessInput WitnessInput) Circuit {
 		felts[i] = babybear.NewF(witnessInput.Felts[i])
 	}
 	for i := 0; i < len(witnessInput.Exts); i++ {
-		exts[i] = babybear.NewE(witnessInput.Exts[i])
+		exts[i] = witnessInput.Exts[i].ExtensionVariable()
 	}
 	return Circuit{
 		VkeyHash:             witnessInput.VkeyHash,
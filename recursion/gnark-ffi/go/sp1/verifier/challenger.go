@@ -0,0 +1,88 @@
+package verifier
+
+import (
+	"github.com/consensys/gnark/frontend"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/poseidon2"
+)
+
+// HASH_RATE is the number of observed elements absorbed by each duplexing of the challenger.
+const HASH_RATE = poseidon2.BABYBEAR_RATE
+
+// Challenger is the circuit counterpart of the DuplexChallenger the SP1 prover samples its
+// Fiat-Shamir challenges with. The transcript is fixed by the circuit, so the buffers are plain
+// slices and duplexing happens at the same points as in the prover: when HASH_RATE elements were
+// observed, and when sampling after an observation or from an empty output buffer.
+type Challenger struct {
+	chip   *babybear.Chip
+	hasher *poseidon2.Poseidon2BabyBearChip
+
+	spongeState  [PERMUTATION_WIDTH]babybear.Variable
+	inputBuffer  []babybear.Variable
+	outputBuffer []babybear.Variable
+}
+
+// NewChallenger returns a challenger with a zero sponge state and empty buffers.
+func NewChallenger(chip *babybear.Chip, hasher *poseidon2.Poseidon2BabyBearChip) *Challenger {
+	c := &Challenger{chip: chip, hasher: hasher}
+	for i := range c.spongeState {
+		c.spongeState[i] = babybear.NewF("0")
+	}
+	return c
+}
+
+// duplexing overwrites the start of the sponge state with the observed elements, permutes it and
+// makes the whole state available to sample.
+func (c *Challenger) duplexing() {
+	copy(c.spongeState[:], c.inputBuffer)
+	c.inputBuffer = c.inputBuffer[:0]
+	c.hasher.PermuteMut(&c.spongeState)
+	c.outputBuffer = append(c.outputBuffer[:0], c.spongeState[:]...)
+}
+
+// ObserveVariable absorbs a field element, which discards the elements left to sample.
+func (c *Challenger) ObserveVariable(value *babybear.Variable) {
+	c.outputBuffer = c.outputBuffer[:0]
+	c.inputBuffer = append(c.inputBuffer, *value)
+	if len(c.inputBuffer) == HASH_RATE {
+		c.duplexing()
+	}
+}
+
+// ObserveE absorbs the coefficients of an extension element, the lowest degree one first.
+func (c *Challenger) ObserveE(value *babybear.ExtensionVariable) {
+	for i := range value.Value {
+		c.ObserveVariable(&value.Value[i])
+	}
+}
+
+// ObserveCommitment absorbs the elements of a digest in order.
+func (c *Challenger) ObserveCommitment(commitment [DIGEST_SIZE]babybear.Variable) {
+	for i := range commitment {
+		c.ObserveVariable(&commitment[i])
+	}
+}
+
+// SampleF returns the next field element of the output buffer, which is consumed from the end.
+func (c *Challenger) SampleF() *babybear.Variable {
+	if len(c.inputBuffer) != 0 || len(c.outputBuffer) == 0 {
+		c.duplexing()
+	}
+	last := len(c.outputBuffer) - 1
+	value := c.outputBuffer[last]
+	c.outputBuffer = c.outputBuffer[:last]
+	return &value
+}
+
+// SampleE returns the extension element whose coefficients are the next 4 sampled field elements,
+// the lowest degree one first.
+func (c *Challenger) SampleE() *babybear.ExtensionVariable {
+	e := babybear.NewEFromVars([4]*babybear.Variable{c.SampleF(), c.SampleF(), c.SampleF(), c.SampleF()})
+	return &e
+}
+
+// SampleBits returns the n least significant bits of the canonical value of a sampled field
+// element, little-endian.
+func (c *Challenger) SampleBits(n int) []frontend.Variable {
+	return c.chip.ToBinaryStrict(*c.SampleF())[:n]
+}
@@ -0,0 +1,237 @@
+package verifier
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/poseidon2"
+)
+
+// challengerCircuit observes 1, 2, 2, 2, samples a field element, observes a commitment, and then
+// samples an extension element and bits.
+type challengerCircuit struct {
+	Commitment [DIGEST_SIZE]frontend.Variable
+	Samples    [7]frontend.Variable
+	Bits       frontend.Variable
+}
+
+func (circuit *challengerCircuit) Define(api frontend.API) error {
+	chip := babybear.NewChip(api)
+	challenger := NewChallenger(chip, poseidon2.NewBabyBearChip(api))
+	for _, v := range []string{"1", "2", "2", "2"} {
+		value := babybear.NewF(v)
+		challenger.ObserveVariable(&value)
+	}
+	samples := []babybear.Variable{*challenger.SampleF(), *challenger.SampleF()}
+
+	var commitment [DIGEST_SIZE]babybear.Variable
+	for i, v := range circuit.Commitment {
+		commitment[i] = babybear.Variable{Value: v, NbBits: 31}
+	}
+	challenger.ObserveCommitment(commitment)
+	samples = append(samples, challenger.SampleE().Value[:]...)
+	samples = append(samples, *challenger.SampleF())
+	for i, sample := range samples {
+		chip.AssertIsEqualF(sample, babybear.Variable{Value: circuit.Samples[i], NbBits: 31})
+	}
+	api.AssertIsEqual(api.FromBinary(challenger.SampleBits(10)...), circuit.Bits)
+	return nil
+}
+
+// TestChallenger derives its challenges from the Go permutation, so it checks the order in which
+// the challenger duplexes and samples; TestChallengerTranscript checks the challenges themselves
+// against the prover.
+func TestChallenger(t *testing.T) {
+	var commitment [DIGEST_SIZE]uint64
+	for i := range commitment {
+		commitment[i] = uint64(i+1) * 987654321 % babybear.MODULUS.Uint64()
+	}
+
+	// The DuplexChallenger duplexes before the first sample and once the 8 elements of the
+	// commitment are observed, and samples from the end of the permuted state.
+	var state [PERMUTATION_WIDTH]uint64
+	copy(state[:], []uint64{1, 2, 2, 2})
+	poseidon2.PermuteBabyBearNative(&state)
+	expected := []uint64{state[15], state[14]}
+	copy(state[:], commitment[:])
+	poseidon2.PermuteBabyBearNative(&state)
+	expected = append(expected, state[15], state[14], state[13], state[12], state[11])
+	bits := state[10] & (1<<10 - 1)
+
+	var assignment challengerCircuit
+	for i, v := range commitment {
+		assignment.Commitment[i] = v
+	}
+	for i, v := range expected {
+		assignment.Samples[i] = v
+	}
+	assignment.Bits = bits
+
+	assert := test.NewAssert(t)
+	assert.CheckCircuit(&challengerCircuit{}, test.WithValidAssignment(&assignment), test.WithCurves(ecc.BN254))
+
+	// A challenge from a transcript duplexed at another point is rejected.
+	copy(state[:], commitment[:])
+	poseidon2.PermuteBabyBearNative(&state)
+	wrong := assignment
+	wrong.Samples[2] = state[15]
+	assert.CheckCircuit(&challengerCircuit{}, test.WithInvalidAssignment(&wrong), test.WithCurves(ecc.BN254))
+}
+
+// observeECircuit observes an extension element and samples a field element.
+type observeECircuit struct {
+	Value  [4]frontend.Variable
+	Sample frontend.Variable
+}
+
+func (circuit *observeECircuit) Define(api frontend.API) error {
+	chip := babybear.NewChip(api)
+	challenger := NewChallenger(chip, poseidon2.NewBabyBearChip(api))
+	var value babybear.ExtensionVariable
+	for i, v := range circuit.Value {
+		value.Value[i] = babybear.Variable{Value: v, NbBits: 31}
+	}
+	challenger.ObserveE(&value)
+	chip.AssertIsEqualF(*challenger.SampleF(), babybear.Variable{Value: circuit.Sample, NbBits: 31})
+	return nil
+}
+
+func TestChallengerObserveE(t *testing.T) {
+	// The coefficients are observed in order, like elements observed one by one.
+	var state [PERMUTATION_WIDTH]uint64
+	copy(state[:], []uint64{5, 6, 7, 8})
+	poseidon2.PermuteBabyBearNative(&state)
+	assignment := observeECircuit{Value: [4]frontend.Variable{5, 6, 7, 8}, Sample: state[15]}
+	assert := test.NewAssert(t)
+	assert.CheckCircuit(&observeECircuit{}, test.WithValidAssignment(&assignment), test.WithCurves(ecc.BN254))
+
+	reversed := assignment
+	reversed.Value = [4]frontend.Variable{8, 7, 6, 5}
+	assert.CheckCircuit(&observeECircuit{}, test.WithInvalidAssignment(&reversed), test.WithCurves(ecc.BN254))
+}
+
+// challengerTranscript is the transcript of testdata/challenger_transcript.json, printed by the
+// challenger_transcript example from the DuplexChallenger<BabyBear, Poseidon2, 16, 8> of the SP1
+// prover:
+//
+//	cargo run --example challenger_transcript -p sp1-recursion-gnark-ffi > go/sp1/verifier/testdata/challenger_transcript.json
+type challengerTranscript struct {
+	Ops []challengerOp `json:"ops"`
+}
+
+// challengerOp observes its values, or samples them, as felts, extension elements or the given
+// number of bits.
+type challengerOp struct {
+	Op     string   `json:"op"`
+	Bits   int      `json:"bits"`
+	Values []uint64 `json:"values"`
+}
+
+// transcriptCircuit replays the operations of a transcript, observing Observed and asserting that
+// the challenges are Sampled, in order.
+type transcriptCircuit struct {
+	Observed []frontend.Variable
+	Sampled  []frontend.Variable
+
+	ops []challengerOp `gnark:"-"`
+}
+
+func (circuit *transcriptCircuit) Define(api frontend.API) error {
+	chip := babybear.NewChip(api)
+	challenger := NewChallenger(chip, poseidon2.NewBabyBearChip(api))
+	observed, sampled := circuit.Observed, circuit.Sampled
+	next := func(values *[]frontend.Variable, n int) []babybear.Variable {
+		felts := make([]babybear.Variable, n)
+		for i, v := range (*values)[:n] {
+			felts[i] = babybear.Variable{Value: v, NbBits: 31}
+		}
+		*values = (*values)[n:]
+		return felts
+	}
+	for _, op := range circuit.ops {
+		switch op.Op {
+		case "observe":
+			for _, value := range next(&observed, len(op.Values)) {
+				challenger.ObserveVariable(&value)
+			}
+		case "observe_commitment":
+			var commitment [DIGEST_SIZE]babybear.Variable
+			copy(commitment[:], next(&observed, DIGEST_SIZE))
+			challenger.ObserveCommitment(commitment)
+		case "observe_ext":
+			var value babybear.ExtensionVariable
+			copy(value.Value[:], next(&observed, len(value.Value)))
+			challenger.ObserveE(&value)
+		case "sample":
+			for _, expected := range next(&sampled, len(op.Values)) {
+				chip.AssertIsEqualF(*challenger.SampleF(), expected)
+			}
+		case "sample_ext":
+			sample := challenger.SampleE()
+			for i, expected := range next(&sampled, len(sample.Value)) {
+				chip.AssertIsEqualF(sample.Value[i], expected)
+			}
+		case "sample_bits":
+			api.AssertIsEqual(api.FromBinary(challenger.SampleBits(op.Bits)...), next(&sampled, 1)[0].Value)
+		default:
+			return fmt.Errorf("unknown challenger operation %q", op.Op)
+		}
+	}
+	return nil
+}
+
+func TestChallengerTranscript(t *testing.T) {
+	data, err := os.ReadFile("testdata/challenger_transcript.json")
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("testdata/challenger_transcript.json is missing, print it with: cargo run --example challenger_transcript -p sp1-recursion-gnark-ffi > go/sp1/verifier/testdata/challenger_transcript.json")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var transcript challengerTranscript
+	if err := json.Unmarshal(data, &transcript); err != nil {
+		t.Fatal(err)
+	}
+
+	var assignment transcriptCircuit
+	for _, op := range transcript.Ops {
+		for _, v := range op.Values {
+			if op.Op == "sample" || op.Op == "sample_ext" || op.Op == "sample_bits" {
+				assignment.Sampled = append(assignment.Sampled, v)
+			} else {
+				assignment.Observed = append(assignment.Observed, v)
+			}
+		}
+	}
+	circuit := transcriptCircuit{
+		Observed: make([]frontend.Variable, len(assignment.Observed)),
+		Sampled:  make([]frontend.Variable, len(assignment.Sampled)),
+		ops:      transcript.Ops,
+	}
+	assert := test.NewAssert(t)
+	assert.CheckCircuit(&circuit, test.WithValidAssignment(&assignment), test.WithCurves(ecc.BN254))
+
+	// The first observations are absorbed in order, and the challenges are taken from the end of
+	// the permuted state.
+	for name, mutate := range map[string]func(*transcriptCircuit){
+		"observed": func(c *transcriptCircuit) { c.Observed[1], c.Observed[2] = c.Observed[2], c.Observed[1] },
+		"sampled":  func(c *transcriptCircuit) { c.Sampled[1], c.Sampled[2] = c.Sampled[2], c.Sampled[1] },
+	} {
+		wrong := transcriptCircuit{
+			Observed: append([]frontend.Variable(nil), assignment.Observed...),
+			Sampled:  append([]frontend.Variable(nil), assignment.Sampled...),
+		}
+		mutate(&wrong)
+		if err := test.IsSolved(&circuit, &wrong, ecc.BN254.ScalarField()); err == nil {
+			t.Errorf("%s: expected the transcript to be rejected", name)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package verifier
+
+import (
+	"fmt"
+
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/hash/sha2"
+	"github.com/consensys/gnark/std/math/uints"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/converter"
+)
+
+// CommittedValuesDigestBytes returns the 32 bytes of the committed value digest of pv, the SHA-256
+// digest of the public values of the program, like words_to_bytes in the SP1 recursion circuit.
+func CommittedValuesDigestBytes(pv *PublicValues) [converter.NUM_BYTES]babybear.Variable {
+	var out [converter.NUM_BYTES]babybear.Variable
+	for i, word := range pv.CommittedValueDigest {
+		copy(out[4*i:], word[:])
+	}
+	return out
+}
+
+// CommittedValuesDigest packs the committed value digest bytes of pv into the committed values
+// digest public input with BabyBearBytesToBN254, like the SP1 recursion circuit.
+func CommittedValuesDigest(api frontend.API, chip *babybear.Chip, pv *PublicValues) frontend.Variable {
+	return converter.BabyBearBytesToBN254(api, chip, CommittedValuesDigestBytes(pv))
+}
+
+// AssertPublicValuesDigest asserts that the committed value digest of pv is the SHA-256 digest of
+// publicValues, the public values committed by the program.
+func AssertPublicValuesDigest(api frontend.API, chip *babybear.Chip, pv *PublicValues, publicValues []uints.U8) error {
+	hasher, err := sha2.New(api)
+	if err != nil {
+		return fmt.Errorf("sha2: %w", err)
+	}
+	hasher.Write(publicValues)
+	digest := hasher.Sum()
+	for i, b := range CommittedValuesDigestBytes(pv) {
+		chip.AssertIsEqualF(b, babybear.Variable{Value: digest[i].Val, NbBits: 31})
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package verifier
+
+import (
+	"crypto/sha256"
+	"math/big"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/math/uints"
+	"github.com/consensys/gnark/test"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/converter"
+)
+
+type committedValuesCircuit struct {
+	PublicValues          []frontend.Variable
+	Digest                [4 * PV_DIGEST_NUM_WORDS]frontend.Variable
+	CommittedValuesDigest frontend.Variable `gnark:",public"`
+}
+
+func (circuit *committedValuesCircuit) Define(api frontend.API) error {
+	chip := babybear.NewChip(api)
+	var pv PublicValues
+	for i, v := range circuit.Digest {
+		pv.CommittedValueDigest[i/4][i%4] = babybear.Variable{Value: v, NbBits: 31}
+	}
+	bytes, err := uints.New[uints.U32](api)
+	if err != nil {
+		return err
+	}
+	publicValues := make([]uints.U8, len(circuit.PublicValues))
+	for i, v := range circuit.PublicValues {
+		publicValues[i] = bytes.ByteValueOf(v)
+	}
+	if err := AssertPublicValuesDigest(api, chip, &pv, publicValues); err != nil {
+		return err
+	}
+	api.AssertIsEqual(CommittedValuesDigest(api, chip, &pv), circuit.CommittedValuesDigest)
+	return nil
+}
+
+func newCommittedValuesAssignment(publicValues []byte) committedValuesCircuit {
+	digest := sha256.Sum256(publicValues)
+	assignment := committedValuesCircuit{PublicValues: make([]frontend.Variable, len(publicValues))}
+	for i, b := range publicValues {
+		assignment.PublicValues[i] = b
+	}
+	for i, b := range digest {
+		assignment.Digest[i] = b
+	}
+	assignment.CommittedValuesDigest = converter.BabyBearBytesToBN254Native(digest)
+	return assignment
+}
+
+func TestAssertPublicValuesDigest(t *testing.T) {
+	// Long enough to span two SHA-256 blocks.
+	publicValues := make([]byte, 100)
+	for i := range publicValues {
+		publicValues[i] = byte(7*i + 0xe0)
+	}
+	circuit := committedValuesCircuit{PublicValues: make([]frontend.Variable, len(publicValues))}
+	solve := func(mutate func(*committedValuesCircuit)) error {
+		assignment := newCommittedValuesAssignment(publicValues)
+		if mutate != nil {
+			mutate(&assignment)
+		}
+		return test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField())
+	}
+	if err := solve(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, mutate := range map[string]func(*committedValuesCircuit){
+		"public value": func(c *committedValuesCircuit) { c.PublicValues[42] = 0 },
+		"digest byte":  func(c *committedValuesCircuit) { c.Digest[31] = c.Digest[31].(byte) ^ 1 },
+		"public input": func(c *committedValuesCircuit) {
+			c.CommittedValuesDigest = new(big.Int).Add(c.CommittedValuesDigest.(*big.Int), big.NewInt(1))
+		},
+	} {
+		if err := solve(mutate); err == nil {
+			t.Errorf("%s: expected the digest to be rejected", name)
+		}
+	}
+}
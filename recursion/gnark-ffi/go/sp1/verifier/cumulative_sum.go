@@ -0,0 +1,19 @@
+package verifier
+
+import "github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+
+// AssertGlobalCumulativeSumZero asserts that the final cumulative sums of the permutation
+// arguments of all chips, sums[shard][chip], add up to zero, which is what makes the LogUp
+// argument sound across shards. A nil sum stands for a chip absent from its shard and, like a
+// shard without chips, contributes nothing.
+func AssertGlobalCumulativeSumZero(chip *babybear.Chip, sums [][]*babybear.ExtensionVariable) {
+	var terms []babybear.ExtensionVariable
+	for _, shard := range sums {
+		for _, sum := range shard {
+			if sum != nil {
+				terms = append(terms, *sum)
+			}
+		}
+	}
+	chip.AssertIsEqualE(chip.SumE(terms...), chip.Zero())
+}
@@ -0,0 +1,86 @@
+package verifier
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+)
+
+// cumulativeSumCircuit holds the sums of the chips marked in present[shard][chip], in order.
+type cumulativeSumCircuit struct {
+	Sums [][4]frontend.Variable
+
+	present [][]bool `gnark:"-"`
+}
+
+func (circuit *cumulativeSumCircuit) Define(api frontend.API) error {
+	chip := babybear.NewChip(api)
+	sums := make([][]*babybear.ExtensionVariable, len(circuit.present))
+	next := 0
+	for i, shard := range circuit.present {
+		sums[i] = make([]*babybear.ExtensionVariable, len(shard))
+		for j, present := range shard {
+			if !present {
+				continue
+			}
+			sum := babybear.ExtensionVariable{}
+			for k, v := range circuit.Sums[next] {
+				sum.Value[k] = babybear.Variable{Value: v, NbBits: 31}
+			}
+			sums[i][j] = &sum
+			next++
+		}
+	}
+	AssertGlobalCumulativeSumZero(chip, sums)
+	return nil
+}
+
+// solveCumulativeSums solves the circuit on a fixture of sums[shard][chip], where absent chips are
+// null.
+func solveCumulativeSums(t *testing.T, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var shards [][]*[4]string
+	if err := json.Unmarshal(data, &shards); err != nil {
+		t.Fatal(err)
+	}
+
+	circuit := cumulativeSumCircuit{present: make([][]bool, len(shards))}
+	var assignment cumulativeSumCircuit
+	for i, shard := range shards {
+		circuit.present[i] = make([]bool, len(shard))
+		for j, sum := range shard {
+			if sum == nil {
+				continue
+			}
+			circuit.present[i][j] = true
+			assignment.Sums = append(assignment.Sums, [4]frontend.Variable{sum[0], sum[1], sum[2], sum[3]})
+		}
+	}
+	circuit.Sums = make([][4]frontend.Variable, len(assignment.Sums))
+	return test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField())
+}
+
+func TestAssertGlobalCumulativeSumZero(t *testing.T) {
+	// The honest fixture has a shard without chips and absent chips in the others.
+	if err := solveCumulativeSums(t, "testdata/cumulative_sums.json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := solveCumulativeSums(t, "testdata/perturbed_cumulative_sums.json"); err == nil {
+		t.Fatal("expected a perturbed cumulative sum to be rejected")
+	}
+}
+
+func TestAssertGlobalCumulativeSumZeroEmpty(t *testing.T) {
+	circuit := cumulativeSumCircuit{present: [][]bool{{}, {false}}}
+	if err := test.IsSolved(&circuit, &cumulativeSumCircuit{}, ecc.BN254.ScalarField()); err != nil {
+		t.Fatal(err)
+	}
+}
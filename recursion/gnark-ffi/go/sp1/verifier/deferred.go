@@ -0,0 +1,65 @@
+package verifier
+
+import (
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/poseidon2"
+)
+
+// PublicValuesDigest hashes the first NUM_PV_ELMS_TO_HASH felts of pv, like
+// calculate_public_values_digest in the SP1 recursion program.
+func PublicValuesDigest(hasher *poseidon2.Poseidon2BabyBearChip, pv *PublicValues) [DIGEST_SIZE]babybear.Variable {
+	return hasher.Hash(pv.Felts()[:NUM_PV_ELMS_TO_HASH])
+}
+
+// AssertPublicValuesHash asserts that the digest of pv is PublicValuesDigest when its exit code is
+// zero, like verify_public_values_hash in the SP1 recursion program.
+func AssertPublicValuesHash(chip *babybear.Chip, hasher *poseidon2.Poseidon2BabyBearChip, pv *PublicValues) {
+	isSuccess := chip.IsLessThanF(pv.ExitCode, constant(1))
+	for i, v := range PublicValuesDigest(hasher, pv) {
+		chip.AssertIsEqualF(chip.SelectF(isSuccess, pv.Digest[i], v), v)
+	}
+}
+
+// ReconstructDeferredDigest chains the deferred proofs digest from start over the public values of
+// the deferred proofs, like the SP1 deferred program: the digest of each proof is the Poseidon2
+// hash of the previous digest, the sp1 vk digest of the proof and its committed value digest.
+func ReconstructDeferredDigest(
+	hasher *poseidon2.Poseidon2BabyBearChip,
+	start [DIGEST_SIZE]babybear.Variable,
+	pvs []*PublicValues,
+) [DIGEST_SIZE]babybear.Variable {
+	digest := start
+	for _, pv := range pvs {
+		inputs := make([]babybear.Variable, 0, 2*DIGEST_SIZE+4*PV_DIGEST_NUM_WORDS)
+		inputs = append(inputs, digest[:]...)
+		inputs = append(inputs, pv.Sp1VkDigest[:]...)
+		for _, word := range pv.CommittedValueDigest {
+			inputs = append(inputs, word[:]...)
+		}
+		digest = hasher.Hash(inputs)
+	}
+	return digest
+}
+
+// AssertDeferredPublicValues asserts the checks the SP1 deferred program does on the public
+// values of the deferred proofs, and returns the deferred proofs digest reconstructed from start
+// over them. Each proof must be complete, compressed with the verifying key of digest
+// compressVkDigest, and have a valid public values digest.
+//
+// This is only the public values half of the aggregation of deferred proofs: it does not verify
+// the proofs, and the circuit has no verifier of recursion proofs to do so. The caller must verify
+// each proof against pvs, as the SP1 compress program does before the wrap circuit sees its digest.
+func AssertDeferredPublicValues(
+	chip *babybear.Chip,
+	hasher *poseidon2.Poseidon2BabyBearChip,
+	start [DIGEST_SIZE]babybear.Variable,
+	compressVkDigest [DIGEST_SIZE]babybear.Variable,
+	pvs []*PublicValues,
+) [DIGEST_SIZE]babybear.Variable {
+	for _, pv := range pvs {
+		chip.AssertIsEqualF(pv.IsComplete, constant(1))
+		chip.AssertIsEqualFs(pv.CompressVkDigest[:], compressVkDigest[:])
+		AssertPublicValuesHash(chip, hasher, pv)
+	}
+	return ReconstructDeferredDigest(hasher, start, pvs)
+}
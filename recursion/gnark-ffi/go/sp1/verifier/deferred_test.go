@@ -0,0 +1,145 @@
+package verifier
+
+import (
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/poseidon2"
+)
+
+const (
+	sp1VkDigestOffset      = endDeferredDigestOffset + DIGEST_SIZE
+	compressVkDigestOffset = sp1VkDigestOffset + DIGEST_SIZE
+)
+
+type deferredProofsCircuit struct {
+	PublicValues     [][RECURSIVE_PROOF_NUM_PV_ELTS]frontend.Variable
+	Start            [DIGEST_SIZE]frontend.Variable
+	CompressVkDigest [DIGEST_SIZE]frontend.Variable
+	End              [DIGEST_SIZE]frontend.Variable `gnark:",public"`
+}
+
+func (circuit *deferredProofsCircuit) Define(api frontend.API) error {
+	chip := babybear.NewChip(api)
+	hasher := poseidon2.NewBabyBearChip(api)
+	digest := func(values [DIGEST_SIZE]frontend.Variable) [DIGEST_SIZE]babybear.Variable {
+		var out [DIGEST_SIZE]babybear.Variable
+		for i, v := range values {
+			out[i] = babybear.Variable{Value: v, NbBits: 31}
+		}
+		return out
+	}
+	pvs := make([]*PublicValues, len(circuit.PublicValues))
+	for i, values := range circuit.PublicValues {
+		felts := make([]babybear.Variable, len(values))
+		for j, v := range values {
+			felts[j] = babybear.Variable{Value: v, NbBits: 31}
+		}
+		var err error
+		if pvs[i], err = NewPublicValues(felts); err != nil {
+			return err
+		}
+	}
+	end := AssertDeferredPublicValues(chip, hasher, digest(circuit.Start), digest(circuit.CompressVkDigest), pvs)
+	expected := digest(circuit.End)
+	chip.AssertIsEqualFs(end[:], expected[:])
+	return nil
+}
+
+// newDeferredProof returns the public values of a complete proof, compressed with compressVk, for
+// the program of digest vk committing to the value digest seeded by seed.
+func newDeferredProof(t *testing.T, vk, compressVk [DIGEST_SIZE]uint64, seed uint64) [RECURSIVE_PROOF_NUM_PV_ELTS]uint64 {
+	pv := readTestPublicValues(t, "complete")
+	for i := 0; i < 4*PV_DIGEST_NUM_WORDS; i++ {
+		pv[i] = (seed*31 + uint64(i)*7) % 256
+	}
+	copy(pv[sp1VkDigestOffset:], vk[:])
+	copy(pv[compressVkDigestOffset:], compressVk[:])
+	pv[isCompleteOffset] = 1
+	pv[RECURSIVE_PROOF_NUM_PV_ELTS-1] = 0
+	setPublicValuesDigest(&pv)
+	return pv
+}
+
+func setPublicValuesDigest(pv *[RECURSIVE_PROOF_NUM_PV_ELTS]uint64) {
+	digest := poseidon2.HashBabyBearNative(pv[:NUM_PV_ELMS_TO_HASH])
+	copy(pv[NUM_PV_ELMS_TO_HASH:], digest[:])
+}
+
+// reconstructDeferredDigestNative is ReconstructDeferredDigest outside of a circuit.
+func reconstructDeferredDigestNative(start [DIGEST_SIZE]uint64, pvs [][RECURSIVE_PROOF_NUM_PV_ELTS]uint64) [DIGEST_SIZE]uint64 {
+	digest := start
+	for _, pv := range pvs {
+		inputs := append([]uint64{}, digest[:]...)
+		inputs = append(inputs, pv[sp1VkDigestOffset:sp1VkDigestOffset+DIGEST_SIZE]...)
+		inputs = append(inputs, pv[:4*PV_DIGEST_NUM_WORDS]...)
+		digest = poseidon2.HashBabyBearNative(inputs)
+	}
+	return digest
+}
+
+func TestAssertDeferredPublicValues(t *testing.T) {
+	vk := [DIGEST_SIZE]uint64{11, 12, 13, 14, 15, 16, 17, 18}
+	compressVk := [DIGEST_SIZE]uint64{21, 22, 23, 24, 25, 26, 27, 28}
+	start := [DIGEST_SIZE]uint64{1, 2, 3, 4, 5, 6, 7, 8}
+	proofs := func() [][RECURSIVE_PROOF_NUM_PV_ELTS]uint64 {
+		return [][RECURSIVE_PROOF_NUM_PV_ELTS]uint64{
+			newDeferredProof(t, vk, compressVk, 1),
+			newDeferredProof(t, vk, compressVk, 2),
+			newDeferredProof(t, vk, compressVk, 3),
+		}
+	}
+	expected := reconstructDeferredDigestNative(start, proofs())
+
+	solve := func(pvs [][RECURSIVE_PROOF_NUM_PV_ELTS]uint64, compressVk, end [DIGEST_SIZE]uint64) error {
+		circuit := deferredProofsCircuit{PublicValues: make([][RECURSIVE_PROOF_NUM_PV_ELTS]frontend.Variable, len(pvs))}
+		assignment := deferredProofsCircuit{PublicValues: make([][RECURSIVE_PROOF_NUM_PV_ELTS]frontend.Variable, len(pvs))}
+		for i := range pvs {
+			for j, v := range pvs[i] {
+				assignment.PublicValues[i][j] = v
+			}
+		}
+		for i := 0; i < DIGEST_SIZE; i++ {
+			assignment.Start[i] = start[i]
+			assignment.CompressVkDigest[i] = compressVk[i]
+			assignment.End[i] = end[i]
+		}
+		return test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField())
+	}
+	if err := solve(proofs(), compressVk, expected); err != nil {
+		t.Fatal(err)
+	}
+	if err := solve(nil, compressVk, start); err != nil {
+		t.Fatalf("expected no proofs to keep the start digest: %v", err)
+	}
+
+	// A failed execution does not bind its digest.
+	pvs := proofs()
+	pvs[1][RECURSIVE_PROOF_NUM_PV_ELTS-1] = 1
+	pvs[1][NUM_PV_ELMS_TO_HASH]++
+	if err := solve(pvs, compressVk, expected); err != nil {
+		t.Fatalf("expected the digest of a failed execution to be ignored: %v", err)
+	}
+
+	for name, mutate := range map[string]func([][RECURSIVE_PROOF_NUM_PV_ELTS]uint64){
+		"incomplete":   func(pvs [][RECURSIVE_PROOF_NUM_PV_ELTS]uint64) { pvs[0][isCompleteOffset] = 0 },
+		"compress vk":  func(pvs [][RECURSIVE_PROOF_NUM_PV_ELTS]uint64) { pvs[2][compressVkDigestOffset+3]++ },
+		"digest":       func(pvs [][RECURSIVE_PROOF_NUM_PV_ELTS]uint64) { pvs[1][NUM_PV_ELMS_TO_HASH+7]++ },
+		"hashed value": func(pvs [][RECURSIVE_PROOF_NUM_PV_ELTS]uint64) { pvs[1][startPcOffset]++ },
+		"sp1 vk": func(pvs [][RECURSIVE_PROOF_NUM_PV_ELTS]uint64) {
+			pvs[0][sp1VkDigestOffset]++
+			setPublicValuesDigest(&pvs[0])
+		},
+		"committed value": func(pvs [][RECURSIVE_PROOF_NUM_PV_ELTS]uint64) { pvs[2][5]++; setPublicValuesDigest(&pvs[2]) },
+		"order":           func(pvs [][RECURSIVE_PROOF_NUM_PV_ELTS]uint64) { pvs[0], pvs[1] = pvs[1], pvs[0] },
+	} {
+		pvs := proofs()
+		mutate(pvs)
+		if err := solve(pvs, compressVk, expected); err == nil {
+			t.Errorf("%s: expected the deferred proofs to be rejected", name)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package verifier
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/consensys/gnark/frontend"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/merkle"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/poseidon2"
+)
+
+// The generator of the BabyBear multiplicative group, which shifts the low degree extension
+// domains off the trace domains.
+const GENERATOR = 31
+
+// FriConfig holds the parameters of the FRI protocol, like FriConfig in Plonky3.
+type FriConfig struct {
+	LogBlowup       int
+	NumQueries      int
+	ProofOfWorkBits int
+}
+
+// The FRI configurations of the SP1 prover without FRI_QUERIES: that of the core and recursion
+// shards, that of the compressed proofs with a larger blowup and fewer queries, and that of the
+// outer proofs.
+var SP1_FRI_CONFIG = FriConfig{LogBlowup: 1, NumQueries: 100, ProofOfWorkBits: 16}
+var COMPRESSED_FRI_CONFIG = FriConfig{LogBlowup: 3, NumQueries: 33, ProofOfWorkBits: 16}
+var OUTER_FRI_CONFIG = FriConfig{LogBlowup: 4, NumQueries: 25, ProofOfWorkBits: 16}
+
+// A lower bound on the conjectured security, in bits, of the FRI configurations of SP1 proofs.
+const MIN_FRI_SECURITY_BITS = 100
+
+// ConjecturedSecurityBits returns the conjectured soundness of the configuration, like
+// conjectured_soundness_bits in Plonky3: each query contributes the bits of the blowup, and the
+// proof of work its own bits.
+func (c FriConfig) ConjecturedSecurityBits() int {
+	return c.LogBlowup*c.NumQueries + c.ProofOfWorkBits
+}
+
+// CheckSecurity returns an error if the configuration cannot be verified, or if its conjectured
+// security is below minBits. Dev mode proofs of SP1 make a single query, so they must be checked
+// with a lower bound.
+func (c FriConfig) CheckSecurity(minBits int) error {
+	if c.LogBlowup < 1 || c.LogBlowup > TWO_ADICITY {
+		return fmt.Errorf("invalid log blowup %d", c.LogBlowup)
+	}
+	if c.NumQueries < 1 {
+		return fmt.Errorf("invalid number of queries %d", c.NumQueries)
+	}
+	if c.ProofOfWorkBits < 0 || c.ProofOfWorkBits >= babybear.MODULUS.BitLen() {
+		return fmt.Errorf("invalid proof of work bits %d", c.ProofOfWorkBits)
+	}
+	if bits := c.ConjecturedSecurityBits(); bits < minBits {
+		return fmt.Errorf("conjectured security of %d bits, expected at least %d", bits, minBits)
+	}
+	return nil
+}
+
+// FriCommitPhaseStep opens a folding round at a query: the evaluation paired with the folded one,
+// and the Merkle path of the pair in the commitment of the round.
+type FriCommitPhaseStep struct {
+	SiblingValue babybear.ExtensionVariable
+	OpeningProof [][DIGEST_SIZE]babybear.Variable
+}
+
+// FriQueryProof holds the openings of every folding round at a query.
+type FriQueryProof struct {
+	CommitPhaseOpenings []FriCommitPhaseStep
+}
+
+// FriProof is a Plonky3 FRI proof whose final polynomial is a constant.
+type FriProof struct {
+	CommitPhaseCommits [][DIGEST_SIZE]babybear.Variable
+	QueryProofs        []FriQueryProof
+	FinalPoly          babybear.ExtensionVariable
+	PowWitness         babybear.Variable
+}
+
+// FriChallenges are the challenges sampled while verifying a FRI proof: a folding challenge per
+// round, and the little-endian bits of each query index.
+type FriChallenges struct {
+	Betas        []babybear.ExtensionVariable
+	QueryIndices [][]frontend.Variable
+}
+
+// BatchOpening opens the rows of the matrices of a batch commitment at a query, OpenedValues[i]
+// being the row of the i-th matrix.
+type BatchOpening struct {
+	OpenedValues [][]babybear.Variable
+	OpeningProof [][DIGEST_SIZE]babybear.Variable
+}
+
+// TwoAdicPcsProof is a Plonky3 TwoAdicFriPcs opening proof. QueryOpenings[q][r] opens the batch
+// commitment of round r at query q.
+type TwoAdicPcsProof struct {
+	FriProof      FriProof
+	QueryOpenings [][]BatchOpening
+}
+
+// TwoAdicPcsMat is a committed matrix whose columns are evaluated over a trace domain of size
+// 2^LogDomainSize, and the values its columns take at the opening points: Values[i][j] is column
+// j at Points[i].
+//
+// A matrix of a chip absent from the shard has no rows and a negative LogDomainSize. It is
+// excluded from its round, so its opened rows must be empty and its values are not checked.
+type TwoAdicPcsMat struct {
+	LogDomainSize int
+	Points        []babybear.ExtensionVariable
+	Values        [][]babybear.ExtensionVariable
+}
+
+// TwoAdicPcsRound is a batch commitment and the openings claimed for its matrices.
+type TwoAdicPcsRound struct {
+	BatchCommit [DIGEST_SIZE]babybear.Variable
+	Mats        []TwoAdicPcsMat
+}
+
+// VerifyTwoAdicPcs verifies that the matrices committed in the rounds take the claimed values at
+// the opening points, like TwoAdicFriPcs::verify in Plonky3. The challenger must have observed
+// the commitments and sampled the points already.
+//
+// At each query, every opened row is checked against its batch commitment, and the quotients
+// (p(x) - p(z)) / (x - z) of all columns are combined with powers of a random alpha, separately
+// for each height. FRI then checks that these combinations are low degree, which they can only be
+// if the claimed values are the evaluations of the committed columns.
+//
+// Like in Plonky3, the folding rounds never reach the matrices over a trace domain of size 1, so
+// their rows are only checked against their commitment and their values are not checked. A
+// zero-width matrix contributes no quotient.
+//
+// The shape of the proof is part of the circuit, so it panics if the proof does not match the
+// rounds and the configuration.
+func VerifyTwoAdicPcs(
+	chip *babybear.Chip,
+	hasher *poseidon2.Poseidon2BabyBearChip,
+	challenger *Challenger,
+	config FriConfig,
+	rounds []TwoAdicPcsRound,
+	proof *TwoAdicPcsProof,
+) {
+	alpha := *challenger.SampleE()
+	challenges := VerifyFriShapeAndSampleChallenges(chip, challenger, config, &proof.FriProof)
+	logMaxHeight := len(proof.FriProof.CommitPhaseCommits) + config.LogBlowup
+	if len(proof.QueryOpenings) != config.NumQueries {
+		panic(fmt.Sprintf("expected the openings of %d queries, got %d", config.NumQueries, len(proof.QueryOpenings)))
+	}
+
+	reducedOpenings := make([]map[int]babybear.ExtensionVariable, config.NumQueries)
+	for q, queryOpening := range proof.QueryOpenings {
+		if len(queryOpening) != len(rounds) {
+			panic(fmt.Sprintf("query %d: expected the openings of %d rounds, got %d", q, len(rounds), len(queryOpening)))
+		}
+		indexBits := challenges.QueryIndices[q]
+		reduced := make(map[int]babybear.ExtensionVariable)
+		alphaPow := make(map[int]babybear.ExtensionVariable)
+		for r, round := range rounds {
+			batchOpening := queryOpening[r]
+			if len(batchOpening.OpenedValues) != len(round.Mats) {
+				panic(fmt.Sprintf("query %d: round %d: expected the rows of %d matrices, got %d", q, r, len(round.Mats), len(batchOpening.OpenedValues)))
+			}
+
+			dims := make([]merkle.Dims, len(round.Mats))
+			logBatchMaxHeight := -1
+			for i, mat := range round.Mats {
+				if mat.LogDomainSize < 0 {
+					// The commitment has no rows of an absent matrix, so merkle.VerifyBatch skips it.
+					dims[i] = merkle.Dims{Height: 0}
+					continue
+				}
+				logHeight := mat.LogDomainSize + config.LogBlowup
+				if logHeight > logMaxHeight {
+					panic(fmt.Sprintf("round %d: matrix %d of height 2^%d is taller than the FRI domain of size 2^%d", r, i, logHeight, logMaxHeight))
+				}
+				dims[i] = merkle.Dims{Width: len(batchOpening.OpenedValues[i]), Height: 1 << logHeight}
+				logBatchMaxHeight = max(logBatchMaxHeight, logHeight)
+			}
+			if logBatchMaxHeight < 0 {
+				// Only absent matrices, whose rows must be empty.
+				for i, row := range batchOpening.OpenedValues {
+					if len(row) != 0 {
+						panic(fmt.Sprintf("query %d: round %d: matrix %d has no rows, got a row of %d values", q, r, i, len(row)))
+					}
+				}
+				continue
+			}
+			merkle.VerifyBatch(chip, hasher, round.BatchCommit, batchOpening.OpenedValues, dims, indexBits[logMaxHeight-logBatchMaxHeight:], batchOpening.OpeningProof)
+
+			endReduce := chip.TraceOperation("FriReduceOpenings")
+			for i, mat := range round.Mats {
+				if mat.LogDomainSize <= 0 {
+					continue
+				}
+				logHeight := mat.LogDomainSize + config.LogBlowup
+				row := batchOpening.OpenedValues[i]
+				g := babybear.NewF(strconv.FormatUint(twoAdicGenerator(logHeight), 10))
+				x := chip.MulF(babybear.NewF(strconv.Itoa(GENERATOR)), chip.ExpReverseBitsLen(g, indexBits[logMaxHeight-logHeight:], logHeight))
+				if len(mat.Values) != len(mat.Points) {
+					panic(fmt.Sprintf("round %d: matrix %d: expected values at %d points, got %d", r, i, len(mat.Points), len(mat.Values)))
+				}
+				for k, z := range mat.Points {
+					if len(mat.Values[k]) != len(row) {
+						panic(fmt.Sprintf("round %d: matrix %d: expected %d values at point %d, got %d", r, i, len(row), k, len(mat.Values[k])))
+					}
+					denominator := chip.SubE(babybear.Felt2Ext(x), z)
+					for j, pAtX := range row {
+						quotient := chip.DivE(chip.SubE(babybear.Felt2Ext(pAtX), mat.Values[k][j]), denominator)
+						pow, ok := alphaPow[logHeight]
+						if !ok {
+							pow = chip.One()
+						}
+						term := chip.MulE(pow, quotient)
+						if sum, ok := reduced[logHeight]; ok {
+							term = chip.AddE(sum, term)
+						}
+						reduced[logHeight] = term
+						alphaPow[logHeight] = chip.MulE(pow, alpha)
+					}
+				}
+			}
+			endReduce()
+		}
+		reducedOpenings[q] = reduced
+	}
+
+	VerifyFriChallenges(chip, hasher, config, &proof.FriProof, challenges, reducedOpenings)
+}
+
+// VerifyFriShapeAndSampleChallenges checks the shape of a FRI proof and replays its transcript:
+// it observes each commitment and samples its folding challenge, observes the final polynomial,
+// checks the proof of work, and samples the query indices.
+func VerifyFriShapeAndSampleChallenges(chip *babybear.Chip, challenger *Challenger, config FriConfig, proof *FriProof) FriChallenges {
+	if len(proof.QueryProofs) != config.NumQueries {
+		panic(fmt.Sprintf("expected %d query proofs, got %d", config.NumQueries, len(proof.QueryProofs)))
+	}
+	for q, queryProof := range proof.QueryProofs {
+		if len(queryProof.CommitPhaseOpenings) != len(proof.CommitPhaseCommits) {
+			panic(fmt.Sprintf("query %d: expected openings of %d rounds, got %d", q, len(proof.CommitPhaseCommits), len(queryProof.CommitPhaseOpenings)))
+		}
+	}
+
+	var challenges FriChallenges
+	for _, commit := range proof.CommitPhaseCommits {
+		challenger.ObserveCommitment(commit)
+		challenges.Betas = append(challenges.Betas, *challenger.SampleE())
+	}
+	challenger.ObserveE(&proof.FinalPoly)
+
+	// The proof of work is a witness after which the sampled bits are zero.
+	challenger.ObserveVariable(&proof.PowWitness)
+	for _, bit := range challenger.SampleBits(config.ProofOfWorkBits) {
+		chip.AssertIsEqualF(babybear.Variable{Value: bit, NbBits: 31}, babybear.NewF("0"))
+	}
+
+	logMaxHeight := len(proof.CommitPhaseCommits) + config.LogBlowup
+	if logMaxHeight > TWO_ADICITY {
+		panic(fmt.Sprintf("the FRI domain of size 2^%d is larger than the two-adic subgroup of size 2^%d", logMaxHeight, TWO_ADICITY))
+	}
+	for q := 0; q < config.NumQueries; q++ {
+		challenges.QueryIndices = append(challenges.QueryIndices, challenger.SampleBits(logMaxHeight))
+	}
+	return challenges
+}
+
+// VerifyFriChallenges checks every query of a FRI proof against the reduced openings of the
+// query, reducedOpenings[q][h] being the combination of the quotients of the matrices of height
+// 2^h at query q. Like in Plonky3, the reduced openings of height at most the blowup do not enter
+// any folding round and are ignored.
+func VerifyFriChallenges(
+	chip *babybear.Chip,
+	hasher *poseidon2.Poseidon2BabyBearChip,
+	config FriConfig,
+	proof *FriProof,
+	challenges FriChallenges,
+	reducedOpenings []map[int]babybear.ExtensionVariable,
+) {
+	logMaxHeight := len(proof.CommitPhaseCommits) + config.LogBlowup
+	for q, queryProof := range proof.QueryProofs {
+		folded := verifyFriQuery(chip, hasher, config, proof.CommitPhaseCommits, challenges.QueryIndices[q], &queryProof, challenges.Betas, reducedOpenings[q], logMaxHeight)
+		chip.AssertIsEqualE(folded, proof.FinalPoly)
+	}
+}
+
+// verifyFriQuery folds the reduced openings of a query round by round, checking the pair of
+// evaluations of each round against its commitment, and returns the final folded evaluation.
+//
+// The evaluations of a round at x and -x are the rows of the pair at the index without its low
+// bit. The low bit tells which of them is the evaluation folded so far, and the pair is
+// interpolated at the folding challenge.
+func verifyFriQuery(
+	chip *babybear.Chip,
+	hasher *poseidon2.Poseidon2BabyBearChip,
+	config FriConfig,
+	commits [][DIGEST_SIZE]babybear.Variable,
+	indexBits []frontend.Variable,
+	proof *FriQueryProof,
+	betas []babybear.ExtensionVariable,
+	reducedOpenings map[int]babybear.ExtensionVariable,
+	logMaxHeight int,
+) babybear.ExtensionVariable {
+	for logHeight := range reducedOpenings {
+		if logHeight > logMaxHeight {
+			panic(fmt.Sprintf("a reduced opening of height 2^%d is taller than the FRI domain of size 2^%d", logHeight, logMaxHeight))
+		}
+	}
+
+	folded := chip.Zero()
+	g := babybear.NewF(strconv.FormatUint(twoAdicGenerator(logMaxHeight), 10))
+	x := chip.ExpReverseBitsLen(g, indexBits, logMaxHeight)
+	for offset, step := range proof.CommitPhaseOpenings {
+		logFoldedHeight := logMaxHeight - 1 - offset
+		if ro, ok := reducedOpenings[logFoldedHeight+1]; ok {
+			folded = chip.AddE(folded, ro)
+		}
+
+		bit := indexBits[offset]
+		var evals [2]babybear.ExtensionVariable
+		evals[0], evals[1] = chip.SwapE(bit, folded, step.SiblingValue)
+		row := append(append([]babybear.Variable(nil), evals[0].Value[:]...), evals[1].Value[:]...)
+		dims := []merkle.Dims{{Width: len(row), Height: 1 << logFoldedHeight}}
+		merkle.VerifyBatch(chip, hasher, commits[offset], [][]babybear.Variable{row}, dims, indexBits[offset+1:], step.OpeningProof)
+
+		// The points of the pair are x and -x, in the order of the evaluations.
+		endFold := chip.TraceOperation("FriFold")
+		negX := chip.NegF(x)
+		var xs [2]babybear.Variable
+		xs[0], xs[1] = chip.SwapF(bit, x, negX)
+		slope := chip.DivEF(chip.SubE(evals[1], evals[0]), chip.SubF(xs[1], xs[0]))
+		folded = chip.AddE(evals[0], chip.MulE(chip.SubEF(betas[offset], xs[0]), slope))
+		x = chip.MulF(x, x)
+		endFold()
+	}
+	return folded
+}
@@ -0,0 +1,868 @@
+package verifier
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"math/big"
+	"math/bits"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/poseidon2"
+)
+
+var p = babybear.MODULUS.Uint64()
+
+type ext = [4]uint64
+
+// The extension arithmetic of the native prover is the one of babybear.BabyBear.
+
+func bigE(a ext) [4]*big.Int {
+	var out [4]*big.Int
+	for i := range out {
+		out[i] = new(big.Int).SetUint64(a[i])
+	}
+	return out
+}
+
+func canonicalE(a [4]*big.Int) ext {
+	var out ext
+	for i := range out {
+		out[i] = a[i].Uint64()
+	}
+	return out
+}
+
+func addE(a, b ext) ext { return canonicalE(babybear.BabyBear.AddE(bigE(a), bigE(b))) }
+func subE(a, b ext) ext { return canonicalE(babybear.BabyBear.SubE(bigE(a), bigE(b))) }
+func mulE(a, b ext) ext { return canonicalE(babybear.BabyBear.MulE(bigE(a), bigE(b))) }
+func invE(a ext) ext    { return canonicalE(babybear.BabyBear.InvE(bigE(a))) }
+
+func expF(a uint64, e int) uint64 {
+	out := uint64(1)
+	for ; e > 0; e-- {
+		out = out * a % p
+	}
+	return out
+}
+
+func reverseBits(index int, bitLen int) int {
+	out := 0
+	for i := 0; i < bitLen; i++ {
+		out |= (index >> i & 1) << (bitLen - 1 - i)
+	}
+	return out
+}
+
+// nativeChallenger is the DuplexChallenger of the SP1 prover.
+type nativeChallenger struct {
+	state  [PERMUTATION_WIDTH]uint64
+	input  []uint64
+	output []uint64
+}
+
+func (c *nativeChallenger) clone() *nativeChallenger {
+	return &nativeChallenger{state: c.state, input: append([]uint64(nil), c.input...), output: append([]uint64(nil), c.output...)}
+}
+
+func (c *nativeChallenger) duplexing() {
+	copy(c.state[:], c.input)
+	c.input = c.input[:0]
+	poseidon2.PermuteBabyBearNative(&c.state)
+	c.output = append(c.output[:0], c.state[:]...)
+}
+
+func (c *nativeChallenger) observe(values ...uint64) {
+	for _, v := range values {
+		c.output = c.output[:0]
+		c.input = append(c.input, v)
+		if len(c.input) == HASH_RATE {
+			c.duplexing()
+		}
+	}
+}
+
+func (c *nativeChallenger) sampleF() uint64 {
+	if len(c.input) != 0 || len(c.output) == 0 {
+		c.duplexing()
+	}
+	v := c.output[len(c.output)-1]
+	c.output = c.output[:len(c.output)-1]
+	return v
+}
+
+func (c *nativeChallenger) sampleE() ext {
+	return ext{c.sampleF(), c.sampleF(), c.sampleF(), c.sampleF()}
+}
+
+func (c *nativeChallenger) sampleBits(n int) int {
+	return int(c.sampleF() & (1<<n - 1))
+}
+
+// nativeTree is a Merkle tree over the rows of matrices whose heights are powers of two, layers[0]
+// holding the leaves.
+type nativeTree struct {
+	layers [][][DIGEST_SIZE]uint64
+}
+
+func commitRows(rows [][]uint64) *nativeTree {
+	return commitMatrices([][][]uint64{rows})
+}
+
+// commitMatrices commits to the matrices like the Plonky3 MMCS: the rows of the tallest matrices
+// are hashed into the leaves, and those of a shorter matrix are injected into the level of its
+// height, matrices of the same height in their order. Matrices without rows are not committed.
+func commitMatrices(matrices [][][]uint64) *nativeTree {
+	hashRows := func(height, index int) ([DIGEST_SIZE]uint64, bool) {
+		var inputs []uint64
+		found := false
+		for _, rows := range matrices {
+			if len(rows) == height {
+				inputs = append(inputs, rows[index]...)
+				found = true
+			}
+		}
+		return poseidon2.HashBabyBearNative(inputs), found
+	}
+
+	maxHeight := 0
+	for _, rows := range matrices {
+		maxHeight = max(maxHeight, len(rows))
+	}
+	layer := make([][DIGEST_SIZE]uint64, maxHeight)
+	for i := range layer {
+		layer[i], _ = hashRows(maxHeight, i)
+	}
+	tree := &nativeTree{layers: [][][DIGEST_SIZE]uint64{layer}}
+	for len(layer) > 1 {
+		next := make([][DIGEST_SIZE]uint64, len(layer)/2)
+		for i := range next {
+			next[i] = poseidon2.CompressBabyBearNative(layer[2*i], layer[2*i+1])
+			if injected, ok := hashRows(len(next), i); ok {
+				next[i] = poseidon2.CompressBabyBearNative(next[i], injected)
+			}
+		}
+		tree.layers = append(tree.layers, next)
+		layer = next
+	}
+	return tree
+}
+
+func (t *nativeTree) root() [DIGEST_SIZE]uint64 {
+	return t.layers[len(t.layers)-1][0]
+}
+
+func (t *nativeTree) proof(index int) [][DIGEST_SIZE]uint64 {
+	var siblings [][DIGEST_SIZE]uint64
+	for _, layer := range t.layers[:len(t.layers)-1] {
+		siblings = append(siblings, layer[index^1])
+		index >>= 1
+	}
+	return siblings
+}
+
+// pcsShape is the shape of the matrices of a pcsFixture: the log sizes of the trace domains of the
+// matrices of each round, negative for a matrix without rows, and their widths. The first matrix of
+// the first round is opened at zeta and zeta + 1, and the others at zeta, unless the matrices are
+// traces opened at zeta and at the point of their next row.
+type pcsShape struct {
+	config         FriConfig
+	logDomainSizes [][]int
+	widths         [][]int
+	traces         bool
+}
+
+// The shape of testdata/two_adic_pcs_proof.json: a round of two matrices over a domain of size 8
+// and one of a matrix over a domain of size 4.
+var defaultPcsShape = pcsShape{
+	config:         FriConfig{LogBlowup: 1, NumQueries: 2, ProofOfWorkBits: 2},
+	logDomainSizes: [][]int{{3, 3}, {2}},
+	widths:         [][]int{{2, 1}, {2}},
+}
+
+// pcsFixture is a TwoAdicFriPcs proof, generated by pcsProve or read by readPcsProof, of the
+// openings of matrices of a pcsShape.
+type pcsFixture struct {
+	config         FriConfig
+	logDomainSizes [][]int
+	// The matrices of round r are opened at the points of their next rows too if nextRows[r].
+	nextRows []bool
+	commits  [][DIGEST_SIZE]uint64
+	// values[r][i][k][j] is column j of matrix i of round r at its k-th point.
+	values [][][][]ext
+
+	friCommits [][DIGEST_SIZE]uint64
+	finalPoly  ext
+	powWitness uint64
+	// siblings[q][s] and friPaths[q][s] open the folding round s at query q.
+	siblings [][]ext
+	friPaths [][][][DIGEST_SIZE]uint64
+	// rows[q][r][i] is the row of matrix i of round r opened at query q.
+	rows       [][][][]uint64
+	batchPaths [][][][DIGEST_SIZE]uint64
+}
+
+// openingPoints returns the points matrix i of round r is opened at.
+func (f *pcsFixture) openingPoints(r, i int, zeta ext) []ext {
+	if f.nextRows[r] {
+		if f.logDomainSizes[r][i] < 0 {
+			return nil
+		}
+		return []ext{zeta, mulE(zeta, ext{twoAdicGenerator(f.logDomainSizes[r][i])})}
+	}
+	if r == 0 && i == 0 {
+		return []ext{zeta, addE(zeta, ext{1})}
+	}
+	return []ext{zeta}
+}
+
+func evalAt(coeffs []uint64, z ext) ext {
+	var out ext
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		out = addE(mulE(out, z), ext{coeffs[i]})
+	}
+	return out
+}
+
+// pcsProve proves the openings of matrices of the default shape.
+func pcsProve(t *testing.T) *pcsFixture {
+	return pcsProveShape(t, defaultPcsShape)
+}
+
+// pcsProveShape commits to random columns of low degree, opens them and proves the openings.
+func pcsProveShape(t *testing.T, shape pcsShape) *pcsFixture {
+	rng := rand.New(rand.NewSource(506))
+	challenger := &nativeChallenger{}
+	prover := newPcsProver(shape.config, challenger)
+	for r, logSizes := range shape.logDomainSizes {
+		coeffs := make([][][]uint64, len(logSizes))
+		for i, logSize := range logSizes {
+			if logSize < 0 {
+				continue
+			}
+			coeffs[i] = make([][]uint64, shape.widths[r][i])
+			for j := range coeffs[i] {
+				coeffs[i][j] = make([]uint64, 1<<logSize)
+				for k := range coeffs[i][j] {
+					coeffs[i][j][k] = rng.Uint64() % p
+				}
+			}
+		}
+		prover.commit(logSizes, coeffs, shape.traces)
+	}
+	return prover.open(t, challenger.sampleE())
+}
+
+// pcsProver commits to rounds of matrices, given by the coefficients of their columns, and proves
+// their openings like TwoAdicFriPcs in Plonky3.
+type pcsProver struct {
+	f          *pcsFixture
+	challenger *nativeChallenger
+	// coeffs[r][i][j] are the coefficients of column j of matrix i of round r, nil for a matrix
+	// without rows, and ldes[r][i] are the rows of its low degree extension.
+	coeffs       [][][][]uint64
+	ldes         [][][][]uint64
+	trees        []*nativeTree
+	logMaxHeight int
+}
+
+func newPcsProver(config FriConfig, challenger *nativeChallenger) *pcsProver {
+	return &pcsProver{f: &pcsFixture{config: config}, challenger: challenger}
+}
+
+// commit commits to the low degree extensions of a round of matrices over trace domains of size
+// 2^logDomainSizes[i], and observes the root. Row i of a matrix of height 2^h is its evaluations at
+// GENERATOR * g_h^reverse_bits_len(i, h). The matrices are opened at the point of their next row
+// too if nextRow is set.
+func (pr *pcsProver) commit(logDomainSizes []int, coeffs [][][]uint64, nextRow bool) [DIGEST_SIZE]uint64 {
+	var ldes [][][]uint64
+	for i, logSize := range logDomainSizes {
+		if logSize < 0 {
+			ldes = append(ldes, nil)
+			continue
+		}
+		logHeight := logSize + pr.f.config.LogBlowup
+		pr.logMaxHeight = max(pr.logMaxHeight, logHeight)
+		lde := make([][]uint64, 1<<logHeight)
+		for row := range lde {
+			x := GENERATOR * expF(twoAdicGenerator(logHeight), reverseBits(row, logHeight)) % p
+			lde[row] = []uint64{}
+			for _, column := range coeffs[i] {
+				lde[row] = append(lde[row], evalAt(column, ext{x})[0])
+			}
+		}
+		ldes = append(ldes, lde)
+	}
+	tree := commitMatrices(ldes)
+	root := tree.root()
+	pr.coeffs = append(pr.coeffs, coeffs)
+	pr.ldes = append(pr.ldes, ldes)
+	pr.trees = append(pr.trees, tree)
+	pr.f.logDomainSizes = append(pr.f.logDomainSizes, logDomainSizes)
+	pr.f.nextRows = append(pr.f.nextRows, nextRow)
+	pr.f.commits = append(pr.f.commits, root)
+	pr.challenger.observe(root[:]...)
+	return root
+}
+
+// open opens the committed matrices at zeta and proves the openings.
+func (pr *pcsProver) open(t *testing.T, zeta ext) *pcsFixture {
+	f, challenger := pr.f, pr.challenger
+	coeffs, ldes, trees, logMaxHeight := pr.coeffs, pr.ldes, pr.trees, pr.logMaxHeight
+	logBlowup := f.config.LogBlowup
+	f.values = make([][][][]ext, len(coeffs))
+	for r := range coeffs {
+		for i, columns := range coeffs[r] {
+			var values [][]ext
+			for _, z := range f.openingPoints(r, i, zeta) {
+				atZ := []ext{}
+				for _, column := range columns {
+					atZ = append(atZ, evalAt(column, z))
+				}
+				values = append(values, atZ)
+			}
+			f.values[r] = append(f.values[r], values)
+		}
+	}
+
+	// Reduce the quotients of each height with powers of alpha. The folding never reaches the
+	// matrices over a domain of size 1, so they are left out like in Plonky3.
+	alpha := challenger.sampleE()
+	reduced := make(map[int][]ext)
+	alphaPow := make(map[int]ext)
+	for r := range coeffs {
+		for i := range coeffs[r] {
+			if f.logDomainSizes[r][i] <= 0 {
+				continue
+			}
+			logHeight := f.logDomainSizes[r][i] + logBlowup
+			if _, ok := reduced[logHeight]; !ok {
+				reduced[logHeight] = make([]ext, 1<<logHeight)
+				alphaPow[logHeight] = ext{1}
+			}
+			for k, z := range f.openingPoints(r, i, zeta) {
+				pows := make([]ext, len(coeffs[r][i]))
+				for j := range pows {
+					pows[j] = alphaPow[logHeight]
+					alphaPow[logHeight] = mulE(alphaPow[logHeight], alpha)
+				}
+				for row := range reduced[logHeight] {
+					x := GENERATOR * expF(twoAdicGenerator(logHeight), reverseBits(row, logHeight)) % p
+					denominator := invE(subE(ext{x}, z))
+					for j := range pows {
+						quotient := mulE(subE(ext{ldes[r][i][row][j]}, f.values[r][i][k][j]), denominator)
+						reduced[logHeight][row] = addE(reduced[logHeight][row], mulE(pows[j], quotient))
+					}
+				}
+			}
+		}
+	}
+
+	// Commit to the pairs of each folding round, and fold them with the sampled challenge.
+	current := reduced[logMaxHeight]
+	if current == nil {
+		current = make([]ext, 1<<logMaxHeight)
+	}
+	var layers [][]ext
+	var friTrees []*nativeTree
+	for logFoldedHeight := logMaxHeight - 1; logFoldedHeight >= logBlowup; logFoldedHeight-- {
+		pairs := make([][]uint64, len(current)/2)
+		for i := range pairs {
+			pairs[i] = append(append([]uint64(nil), current[2*i][:]...), current[2*i+1][:]...)
+		}
+		tree := commitRows(pairs)
+		friTrees = append(friTrees, tree)
+		layers = append(layers, current)
+		root := tree.root()
+		f.friCommits = append(f.friCommits, root)
+		challenger.observe(root[:]...)
+		beta := challenger.sampleE()
+
+		next := make([]ext, len(pairs))
+		for i := range next {
+			start := expF(twoAdicGenerator(logFoldedHeight+1), reverseBits(i, logFoldedHeight))
+			xs := [2]uint64{start, p - start}
+			slope := mulE(subE(current[2*i+1], current[2*i]), invE(ext{(xs[1] + p - xs[0]) % p}))
+			next[i] = addE(current[2*i], mulE(subE(beta, ext{xs[0]}), slope))
+			if ro, ok := reduced[logFoldedHeight]; ok && logFoldedHeight > logBlowup {
+				next[i] = addE(next[i], ro[i])
+			}
+		}
+		current = next
+	}
+	for _, v := range current {
+		if v != current[0] {
+			t.Fatal("the folded polynomial is not constant")
+		}
+	}
+	f.finalPoly = current[0]
+	challenger.observe(f.finalPoly[:]...)
+
+	for ; ; f.powWitness++ {
+		grind := challenger.clone()
+		grind.observe(f.powWitness)
+		if grind.sampleBits(f.config.ProofOfWorkBits) == 0 {
+			break
+		}
+	}
+	challenger.observe(f.powWitness)
+	challenger.sampleBits(f.config.ProofOfWorkBits)
+
+	for q := 0; q < f.config.NumQueries; q++ {
+		index := challenger.sampleBits(logMaxHeight)
+		var siblings []ext
+		var friPaths [][][DIGEST_SIZE]uint64
+		for s, layer := range layers {
+			i := index >> s
+			siblings = append(siblings, layer[i^1])
+			friPaths = append(friPaths, friTrees[s].proof(i>>1))
+		}
+		f.siblings = append(f.siblings, siblings)
+		f.friPaths = append(f.friPaths, friPaths)
+
+		var rows [][][]uint64
+		var batchPaths [][][DIGEST_SIZE]uint64
+		for r := range ldes {
+			logBatchHeight := len(trees[r].layers) - 1
+			reducedIndex := index >> (logMaxHeight - logBatchHeight)
+			var opened [][]uint64
+			for _, lde := range ldes[r] {
+				if lde == nil {
+					opened = append(opened, []uint64{})
+				} else {
+					opened = append(opened, lde[reducedIndex>>(logBatchHeight-bits.Len(uint(len(lde)-1)))])
+				}
+			}
+			rows = append(rows, opened)
+			batchPaths = append(batchPaths, trees[r].proof(reducedIndex))
+		}
+		f.rows = append(f.rows, rows)
+		f.batchPaths = append(f.batchPaths, batchPaths)
+	}
+	return f
+}
+
+type pcsCircuit struct {
+	Commits    [][DIGEST_SIZE]frontend.Variable
+	Values     [][][][][4]frontend.Variable
+	FriCommits [][DIGEST_SIZE]frontend.Variable
+	FinalPoly  [4]frontend.Variable
+	PowWitness frontend.Variable
+	Siblings   [][][4]frontend.Variable
+	FriPaths   [][][][DIGEST_SIZE]frontend.Variable
+	Rows       [][][][]frontend.Variable
+	BatchPaths [][][][DIGEST_SIZE]frontend.Variable
+
+	config         FriConfig `gnark:"-"`
+	logDomainSizes [][]int   `gnark:"-"`
+	nextRows       []bool    `gnark:"-"`
+}
+
+// The variables of the test circuits.
+func feltVar(v frontend.Variable) babybear.Variable {
+	return babybear.Variable{Value: v, NbBits: 31}
+}
+
+func extensionVar(v [4]frontend.Variable) babybear.ExtensionVariable {
+	return babybear.Felts2Ext(feltVar(v[0]), feltVar(v[1]), feltVar(v[2]), feltVar(v[3]))
+}
+
+func extensionVars(vs [][4]frontend.Variable) []babybear.ExtensionVariable {
+	var out []babybear.ExtensionVariable
+	for _, v := range vs {
+		out = append(out, extensionVar(v))
+	}
+	return out
+}
+
+func digestVar(v [DIGEST_SIZE]frontend.Variable) [DIGEST_SIZE]babybear.Variable {
+	var out [DIGEST_SIZE]babybear.Variable
+	for i := range v {
+		out[i] = feltVar(v[i])
+	}
+	return out
+}
+
+func pathVar(siblings [][DIGEST_SIZE]frontend.Variable) [][DIGEST_SIZE]babybear.Variable {
+	out := make([][DIGEST_SIZE]babybear.Variable, len(siblings))
+	for i, sibling := range siblings {
+		out[i] = digestVar(sibling)
+	}
+	return out
+}
+
+// proof returns the TwoAdicPcsProof of the assignment.
+func (circuit *pcsCircuit) proof() TwoAdicPcsProof {
+	proof := TwoAdicPcsProof{FriProof: FriProof{FinalPoly: extensionVar(circuit.FinalPoly), PowWitness: feltVar(circuit.PowWitness)}}
+	for _, commit := range circuit.FriCommits {
+		proof.FriProof.CommitPhaseCommits = append(proof.FriProof.CommitPhaseCommits, digestVar(commit))
+	}
+	for q := range circuit.Siblings {
+		var queryProof FriQueryProof
+		for s, sibling := range circuit.Siblings[q] {
+			queryProof.CommitPhaseOpenings = append(queryProof.CommitPhaseOpenings, FriCommitPhaseStep{SiblingValue: extensionVar(sibling), OpeningProof: pathVar(circuit.FriPaths[q][s])})
+		}
+		proof.FriProof.QueryProofs = append(proof.FriProof.QueryProofs, queryProof)
+
+		var openings []BatchOpening
+		for r, rows := range circuit.Rows[q] {
+			opening := BatchOpening{OpeningProof: pathVar(circuit.BatchPaths[q][r])}
+			for _, row := range rows {
+				var opened []babybear.Variable
+				for _, v := range row {
+					opened = append(opened, feltVar(v))
+				}
+				opening.OpenedValues = append(opening.OpenedValues, opened)
+			}
+			openings = append(openings, opening)
+		}
+		proof.QueryOpenings = append(proof.QueryOpenings, openings)
+	}
+	return proof
+}
+
+// traceOpenings returns the openings of the traces of round r at their local and next rows, those
+// of absent chips at no point.
+func (circuit *pcsCircuit) traceOpenings(r int) []TraceOpenings {
+	openings := make([]TraceOpenings, len(circuit.Values[r]))
+	for i, values := range circuit.Values[r] {
+		if len(values) == 2 {
+			openings[i] = TraceOpenings{Local: extensionVars(values[0]), Next: extensionVars(values[1])}
+		}
+	}
+	return openings
+}
+
+func (circuit *pcsCircuit) Define(api frontend.API) error {
+	chip := babybear.NewChip(api)
+	hasher := poseidon2.NewBabyBearChip(api)
+
+	challenger := NewChallenger(chip, hasher)
+	rounds := make([]TwoAdicPcsRound, len(circuit.Commits))
+	for r, commit := range circuit.Commits {
+		rounds[r].BatchCommit = digestVar(commit)
+		challenger.ObserveCommitment(rounds[r].BatchCommit)
+	}
+	zeta := *challenger.SampleE()
+	for r := range rounds {
+		if circuit.nextRows[r] {
+			rounds[r] = TraceRound(chip, rounds[r].BatchCommit, circuit.logDomainSizes[r], zeta, circuit.traceOpenings(r))
+			continue
+		}
+		for i, logSize := range circuit.logDomainSizes[r] {
+			mat := TwoAdicPcsMat{LogDomainSize: logSize, Points: []babybear.ExtensionVariable{zeta}}
+			if r == 0 && i == 0 {
+				mat.Points = append(mat.Points, chip.AddE(zeta, babybear.OneE()))
+			}
+			for _, atZ := range circuit.Values[r][i] {
+				mat.Values = append(mat.Values, extensionVars(atZ))
+			}
+			rounds[r].Mats = append(rounds[r].Mats, mat)
+		}
+	}
+
+	proof := circuit.proof()
+	if len(rounds) == 1 && circuit.nextRows[0] {
+		VerifyTraceOpenings(chip, hasher, challenger, circuit.config, rounds[0].BatchCommit, circuit.logDomainSizes[0], zeta, circuit.traceOpenings(0), &proof)
+		return nil
+	}
+	VerifyTwoAdicPcs(chip, hasher, challenger, circuit.config, rounds, &proof)
+	return nil
+}
+
+func newPcsCircuit(f *pcsFixture) *pcsCircuit {
+	extension := func(v ext) [4]frontend.Variable {
+		return [4]frontend.Variable{v[0], v[1], v[2], v[3]}
+	}
+	digest := func(v [DIGEST_SIZE]uint64) [DIGEST_SIZE]frontend.Variable {
+		var out [DIGEST_SIZE]frontend.Variable
+		for i := range v {
+			out[i] = v[i]
+		}
+		return out
+	}
+	path := func(siblings [][DIGEST_SIZE]uint64) [][DIGEST_SIZE]frontend.Variable {
+		out := make([][DIGEST_SIZE]frontend.Variable, len(siblings))
+		for i, sibling := range siblings {
+			out[i] = digest(sibling)
+		}
+		return out
+	}
+
+	circuit := &pcsCircuit{
+		FinalPoly:      extension(f.finalPoly),
+		PowWitness:     f.powWitness,
+		config:         f.config,
+		logDomainSizes: f.logDomainSizes,
+		nextRows:       f.nextRows,
+	}
+	for _, commit := range f.commits {
+		circuit.Commits = append(circuit.Commits, digest(commit))
+	}
+	circuit.Values = make([][][][][4]frontend.Variable, len(f.values))
+	for r := range f.values {
+		circuit.Values[r] = make([][][][4]frontend.Variable, len(f.values[r]))
+		for i := range f.values[r] {
+			circuit.Values[r][i] = make([][][4]frontend.Variable, len(f.values[r][i]))
+			for k := range f.values[r][i] {
+				for _, v := range f.values[r][i][k] {
+					circuit.Values[r][i][k] = append(circuit.Values[r][i][k], extension(v))
+				}
+			}
+		}
+	}
+	for _, commit := range f.friCommits {
+		circuit.FriCommits = append(circuit.FriCommits, digest(commit))
+	}
+	for q := range f.siblings {
+		var siblings [][4]frontend.Variable
+		var friPaths [][][DIGEST_SIZE]frontend.Variable
+		for s, sibling := range f.siblings[q] {
+			siblings = append(siblings, extension(sibling))
+			friPaths = append(friPaths, path(f.friPaths[q][s]))
+		}
+		circuit.Siblings = append(circuit.Siblings, siblings)
+		circuit.FriPaths = append(circuit.FriPaths, friPaths)
+
+		var rounds [][][]frontend.Variable
+		var batchPaths [][][DIGEST_SIZE]frontend.Variable
+		for r, rows := range f.rows[q] {
+			var opened [][]frontend.Variable
+			for _, row := range rows {
+				var values []frontend.Variable
+				for _, v := range row {
+					values = append(values, v)
+				}
+				opened = append(opened, values)
+			}
+			rounds = append(rounds, opened)
+			batchPaths = append(batchPaths, path(f.batchPaths[q][r]))
+		}
+		circuit.Rows = append(circuit.Rows, rounds)
+		circuit.BatchPaths = append(circuit.BatchPaths, batchPaths)
+	}
+	return circuit
+}
+
+// checkPcsFixture checks that the proof returned by prove is accepted, and rejected once tampered
+// with.
+func checkPcsFixture(t *testing.T, prove func(t *testing.T) *pcsFixture) {
+	f := prove(t)
+	circuit := newPcsCircuit(f)
+	if err := test.IsSolved(circuit, newPcsCircuit(f), ecc.BN254.ScalarField()); err != nil {
+		t.Fatal(err)
+	}
+
+	increment := func(v *uint64) { *v = (*v + 1) % p }
+	for name, tamper := range map[string]func(f *pcsFixture){
+		"claimed value":   func(f *pcsFixture) { increment(&f.values[0][0][1][0][2]) },
+		"opened row":      func(f *pcsFixture) { increment(&f.rows[1][1][0][1]) },
+		"batch path":      func(f *pcsFixture) { increment(&f.batchPaths[0][0][2][5]) },
+		"sibling value":   func(f *pcsFixture) { increment(&f.siblings[1][2][0]) },
+		"folding path":    func(f *pcsFixture) { increment(&f.friPaths[0][1][0][0]) },
+		"final poly":      func(f *pcsFixture) { increment(&f.finalPoly[3]) },
+		"pow witness":     func(f *pcsFixture) { increment(&f.powWitness) },
+		"batch commit":    func(f *pcsFixture) { increment(&f.commits[1][7]) },
+		"folding commit":  func(f *pcsFixture) { increment(&f.friCommits[2][0]) },
+		"swapped queries": func(f *pcsFixture) { f.rows[0], f.rows[1] = f.rows[1], f.rows[0] },
+	} {
+		tampered := prove(t)
+		tamper(tampered)
+		if err := test.IsSolved(circuit, newPcsCircuit(tampered), ecc.BN254.ScalarField()); err == nil {
+			t.Errorf("%s: expected the tampered proof to be rejected", name)
+		}
+	}
+}
+
+func TestVerifyTwoAdicPcs(t *testing.T) {
+	checkPcsFixture(t, pcsProve)
+}
+
+// The shape of testdata/two_adic_pcs_mixed_heights_proof.json: a round of matrices of three
+// heights, the two tallest first and third, and a round of three heights.
+var mixedHeightsPcsShape = pcsShape{
+	config:         FriConfig{LogBlowup: 1, NumQueries: 2, ProofOfWorkBits: 2},
+	logDomainSizes: [][]int{{4, 2, 4, 3}, {3, 1, 2}},
+	widths:         [][]int{{3, 2, 1, 2}, {2, 1, 2}},
+}
+
+func TestVerifyTwoAdicPcsMixedHeights(t *testing.T) {
+	checkPcsFixture(t, func(t *testing.T) *pcsFixture { return pcsProveShape(t, mixedHeightsPcsShape) })
+}
+
+// twoPcsCircuit verifies two PCS proofs of different configurations in one circuit.
+type twoPcsCircuit struct {
+	First, Second pcsCircuit
+}
+
+func (circuit *twoPcsCircuit) Define(api frontend.API) error {
+	if err := circuit.First.Define(api); err != nil {
+		return err
+	}
+	return circuit.Second.Define(api)
+}
+
+func TestVerifyTwoAdicPcsConfigs(t *testing.T) {
+	// A compressed proof has a larger blowup, so its FRI domain is larger for the same traces.
+	compressed := defaultPcsShape
+	compressed.config = FriConfig{LogBlowup: 3, NumQueries: 2, ProofOfWorkBits: 1}
+	prove := func(t *testing.T) *pcsFixture { return pcsProveShape(t, compressed) }
+	checkPcsFixture(t, prove)
+
+	first, second := pcsProve(t), prove(t)
+	circuit := &twoPcsCircuit{First: *newPcsCircuit(first), Second: *newPcsCircuit(second)}
+	if err := test.IsSolved(circuit, &twoPcsCircuit{First: *newPcsCircuit(first), Second: *newPcsCircuit(second)}, ecc.BN254.ScalarField()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each proof only verifies under its own configuration.
+	swapped := *newPcsCircuit(second)
+	swapped.config = first.config
+	if err := test.IsSolved(&swapped, newPcsCircuit(second), ecc.BN254.ScalarField()); err == nil {
+		t.Error("expected the compressed proof to be rejected under the default configuration")
+	}
+}
+
+func TestFriConfigSecurity(t *testing.T) {
+	for name, config := range map[string]FriConfig{
+		"sp1":        SP1_FRI_CONFIG,
+		"compressed": COMPRESSED_FRI_CONFIG,
+		"outer":      OUTER_FRI_CONFIG,
+	} {
+		if err := config.CheckSecurity(MIN_FRI_SECURITY_BITS); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if bits := COMPRESSED_FRI_CONFIG.ConjecturedSecurityBits(); bits != 115 {
+		t.Errorf("conjectured security of %d bits, expected 115", bits)
+	}
+
+	for name, config := range map[string]FriConfig{
+		"dev mode":        {LogBlowup: 1, NumQueries: 1, ProofOfWorkBits: 16},
+		"no blowup":       {LogBlowup: 0, NumQueries: 200, ProofOfWorkBits: 16},
+		"no queries":      {LogBlowup: 200, NumQueries: 0, ProofOfWorkBits: 16},
+		"proof of work":   {LogBlowup: 1, NumQueries: 100, ProofOfWorkBits: 31},
+		"negative blowup": {LogBlowup: -1, NumQueries: -200, ProofOfWorkBits: 16},
+	} {
+		if err := config.CheckSecurity(MIN_FRI_SECURITY_BITS); err == nil {
+			t.Errorf("%s: expected an insecure or invalid configuration", name)
+		}
+	}
+}
+
+func TestVerifyTwoAdicPcsEmptyMatrices(t *testing.T) {
+	// The first round ends with a matrix without rows, and the second holds a matrix over a domain
+	// of size 1 and one without columns, injected into the tree of the matrices of height 8.
+	shape := pcsShape{
+		config:         FriConfig{LogBlowup: 1, NumQueries: 2, ProofOfWorkBits: 2},
+		logDomainSizes: [][]int{{3, 3, -1}, {2, 0, 2}},
+		widths:         [][]int{{2, 1, 0}, {2, 1, 0}},
+	}
+	prove := func(t *testing.T) *pcsFixture { return pcsProveShape(t, shape) }
+	checkPcsFixture(t, prove)
+
+	// A tampered row of the matrix without rows changes the shape of the circuit.
+	for name, tamper := range map[string]func(f *pcsFixture){
+		"row of a domain of size 1":    func(f *pcsFixture) { f.rows[0][1][1][0] = (f.rows[0][1][1][0] + 1) % p },
+		"row of a matrix without rows": func(f *pcsFixture) { f.rows[1][0][2] = []uint64{0} },
+	} {
+		tampered := prove(t)
+		tamper(tampered)
+		tamperedCircuit := newPcsCircuit(tampered)
+		if err := test.IsSolved(tamperedCircuit, tamperedCircuit, ecc.BN254.ScalarField()); err == nil {
+			t.Errorf("%s: expected the tampered proof to be rejected", name)
+		}
+	}
+}
+
+// pcsProof is a TwoAdicFriPcs proof in testdata, printed by an ignored test of the SP1 recursion
+// program that proves the openings with Plonky3, like
+//
+//	cargo test -p sp1-recursion-program --release fri::two_adic_pcs::tests::test_generate_two_adic_pcs_fixture -- --ignored --nocapture
+type pcsProof struct {
+	Config struct {
+		LogBlowup       int `json:"log_blowup"`
+		NumQueries      int `json:"num_queries"`
+		ProofOfWorkBits int `json:"proof_of_work_bits"`
+	} `json:"config"`
+	LogDomainSizes     [][]int               `json:"log_domain_sizes"`
+	Commits            [][DIGEST_SIZE]uint64 `json:"commits"`
+	Values             [][][][]ext           `json:"values"`
+	CommitPhaseCommits [][DIGEST_SIZE]uint64 `json:"commit_phase_commits"`
+	FinalPoly          ext                   `json:"final_poly"`
+	PowWitness         uint64                `json:"pow_witness"`
+	QueryProofs        []struct {
+		CommitPhaseOpenings []struct {
+			SiblingValue ext                   `json:"sibling_value"`
+			OpeningProof [][DIGEST_SIZE]uint64 `json:"opening_proof"`
+		} `json:"commit_phase_openings"`
+	} `json:"query_proofs"`
+	QueryOpenings [][]struct {
+		OpenedValues [][]uint64            `json:"opened_values"`
+		OpeningProof [][DIGEST_SIZE]uint64 `json:"opening_proof"`
+	} `json:"query_openings"`
+}
+
+// readPcsProof reads the proof at path, printed by the ignored Rust test generator, and fails the
+// test if it has not been saved.
+func readPcsProof(t *testing.T, path, generator string) *pcsFixture {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("%s is missing, save the JSON printed by: cargo test -p sp1-recursion-program --release fri::two_adic_pcs::tests::%s -- --ignored --nocapture", path, generator)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var proof pcsProof
+	if err := json.Unmarshal(data, &proof); err != nil {
+		t.Fatal(err)
+	}
+	f := &pcsFixture{
+		config:         FriConfig{LogBlowup: proof.Config.LogBlowup, NumQueries: proof.Config.NumQueries, ProofOfWorkBits: proof.Config.ProofOfWorkBits},
+		logDomainSizes: proof.LogDomainSizes,
+		nextRows:       make([]bool, len(proof.LogDomainSizes)),
+		commits:        proof.Commits,
+		values:         proof.Values,
+		friCommits:     proof.CommitPhaseCommits,
+		finalPoly:      proof.FinalPoly,
+		powWitness:     proof.PowWitness,
+	}
+	for q, query := range proof.QueryProofs {
+		var siblings []ext
+		var friPaths [][][DIGEST_SIZE]uint64
+		for _, step := range query.CommitPhaseOpenings {
+			siblings = append(siblings, step.SiblingValue)
+			friPaths = append(friPaths, step.OpeningProof)
+		}
+		f.siblings = append(f.siblings, siblings)
+		f.friPaths = append(f.friPaths, friPaths)
+
+		var rows [][][]uint64
+		var batchPaths [][][DIGEST_SIZE]uint64
+		for _, opening := range proof.QueryOpenings[q] {
+			rows = append(rows, opening.OpenedValues)
+			batchPaths = append(batchPaths, opening.OpeningProof)
+		}
+		f.rows = append(f.rows, rows)
+		f.batchPaths = append(f.batchPaths, batchPaths)
+	}
+	return f
+}
+
+func TestVerifyTwoAdicPcsProof(t *testing.T) {
+	checkPcsFixture(t, func(t *testing.T) *pcsFixture {
+		return readPcsProof(t, "testdata/two_adic_pcs_proof.json", "test_generate_two_adic_pcs_fixture")
+	})
+}
+
+func TestVerifyTwoAdicPcsMixedHeightsProof(t *testing.T) {
+	checkPcsFixture(t, func(t *testing.T) *pcsFixture {
+		return readPcsProof(t, "testdata/two_adic_pcs_mixed_heights_proof.json", "test_generate_two_adic_pcs_mixed_heights_fixture")
+	})
+}
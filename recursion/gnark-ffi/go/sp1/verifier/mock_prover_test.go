@@ -0,0 +1,234 @@
+package verifier
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/poseidon2"
+)
+
+// The log height of the trace of the Fibonacci AIR of the mock prover, like FibonacciAir of the
+// Plonky3 examples: each row holds two consecutive Fibonacci numbers, the first row holds the
+// public values a and b, and the last row ends with the public value x.
+const fibonacciLogHeight = 3
+
+// fibonacciConstraints returns the constraints of the Fibonacci AIR on the rows local and next, in
+// the order they are folded: the public values of the first row, the transitions, and the public
+// value of the last row.
+func fibonacciConstraints(local, next [2]ext, publicValues [3]uint64, selectors [4]ext) []ext {
+	isFirstRow, isLastRow, isTransition := selectors[0], selectors[1], selectors[2]
+	return []ext{
+		mulE(isFirstRow, subE(local[0], ext{publicValues[0]})),
+		mulE(isFirstRow, subE(local[1], ext{publicValues[1]})),
+		mulE(isTransition, subE(next[0], local[1])),
+		mulE(isTransition, subE(next[1], addE(local[0], local[1]))),
+		mulE(isLastRow, subE(local[1], ext{publicValues[2]})),
+	}
+}
+
+// starkFixture is the proof of the mock prover: the public values, and the openings of the trace
+// and quotient rounds.
+type starkFixture struct {
+	publicValues [3]uint64
+	pcs          *pcsFixture
+}
+
+// interpolate returns the coefficients of the polynomial of degree below len(values) whose
+// evaluation at shift * w^i is values[i], w the generator of the subgroup of that order.
+func interpolate(values []ext, shift uint64) []ext {
+	logN := 0
+	for 1<<logN < len(values) {
+		logN++
+	}
+	wInv := expF(twoAdicGenerator(logN), len(values)-1)
+	nInv := invE(ext{uint64(len(values))})[0]
+	shiftInv := invE(ext{shift})[0]
+	coeffs := make([]ext, len(values))
+	for k := range coeffs {
+		for i, v := range values {
+			coeffs[k] = addE(coeffs[k], mulE(v, ext{expF(wInv, i*k%len(values))}))
+		}
+		coeffs[k] = mulE(coeffs[k], ext{nInv * expF(shiftInv, k) % p})
+	}
+	return coeffs
+}
+
+// starkProve proves the Fibonacci AIR over 2^fibonacciLogHeight rows with the transcript of the
+// uni-stark prover of Plonky3: the prover commits to the trace, observes the public values and
+// samples alpha, commits to the quotient of the constraints folded with alpha, samples zeta and
+// opens the trace at zeta and at the next row, and the quotient at zeta. It returns an error if
+// the trace does not satisfy the constraints.
+func starkProve(t *testing.T, publicValues [3]uint64) (*starkFixture, error) {
+	n := 1 << fibonacciLogHeight
+	rows := make([][2]uint64, n)
+	rows[0] = [2]uint64{publicValues[0], publicValues[1]}
+	for i := 1; i < n; i++ {
+		rows[i] = [2]uint64{rows[i-1][1], (rows[i-1][0] + rows[i-1][1]) % p}
+	}
+	var traceCoeffs [2][]uint64
+	for j := range traceCoeffs {
+		column := make([]ext, n)
+		for i, row := range rows {
+			column[i] = ext{row[j]}
+		}
+		for _, c := range interpolate(column, 1) {
+			traceCoeffs[j] = append(traceCoeffs[j], c[0])
+		}
+	}
+
+	config := FriConfig{LogBlowup: 1, NumQueries: 2, ProofOfWorkBits: 2}
+	challenger := &nativeChallenger{}
+	prover := newPcsProver(config, challenger)
+	prover.commit([]int{fibonacciLogHeight}, [][][]uint64{traceCoeffs[:]}, true)
+	challenger.observe(publicValues[:]...)
+	alpha := challenger.sampleE()
+
+	// The folded constraints vanish on the trace domain, so their quotient by its vanishing
+	// polynomial is of degree below n. It is interpolated from its values over a coset of twice the
+	// size, and committed by the coordinates of its coefficients.
+	g := twoAdicGenerator(fibonacciLogHeight)
+	w := twoAdicGenerator(fibonacciLogHeight + 1)
+	quotient := make([]ext, 2*n)
+	for i := range quotient {
+		x := ext{GENERATOR * expF(w, i) % p}
+		xNext := mulE(x, ext{g})
+		local := [2]ext{evalAt(traceCoeffs[0], x), evalAt(traceCoeffs[1], x)}
+		next := [2]ext{evalAt(traceCoeffs[0], xNext), evalAt(traceCoeffs[1], xNext)}
+		selectors := nativeSelectors(fibonacciLogHeight, x)
+		folded := ext{}
+		for _, constraint := range fibonacciConstraints(local, next, publicValues, selectors) {
+			folded = addE(mulE(folded, alpha), constraint)
+		}
+		quotient[i] = mulE(folded, selectors[3])
+	}
+	quotientCoeffs := make([][]uint64, 4)
+	for k, c := range interpolate(quotient, GENERATOR) {
+		if k >= n && c != (ext{}) {
+			return nil, errors.New("the constraints do not vanish on the trace domain")
+		}
+		for j := range quotientCoeffs {
+			if k < n {
+				quotientCoeffs[j] = append(quotientCoeffs[j], c[j])
+			}
+		}
+	}
+	prover.commit([]int{fibonacciLogHeight}, [][][]uint64{quotientCoeffs}, false)
+
+	return &starkFixture{publicValues: publicValues, pcs: prover.open(t, challenger.sampleE())}, nil
+}
+
+// starkCircuit verifies a proof of the mock prover: it replays the transcript, checks that the
+// folded constraints at zeta are the quotient times the vanishing polynomial, and verifies the
+// openings.
+type starkCircuit struct {
+	PublicValues [3]frontend.Variable
+	PCS          pcsCircuit
+}
+
+func (circuit *starkCircuit) Define(api frontend.API) error {
+	chip := babybear.NewChip(api)
+	hasher := poseidon2.NewBabyBearChip(api)
+	challenger := NewChallenger(chip, hasher)
+
+	traceCommit, quotientCommit := digestVar(circuit.PCS.Commits[0]), digestVar(circuit.PCS.Commits[1])
+	challenger.ObserveCommitment(traceCommit)
+	var publicValues [3]babybear.ExtensionVariable
+	for i, v := range circuit.PublicValues {
+		felt := feltVar(v)
+		challenger.ObserveVariable(&felt)
+		publicValues[i] = babybear.Felt2Ext(felt)
+	}
+	alpha := *challenger.SampleE()
+	challenger.ObserveCommitment(quotientCommit)
+	zeta := *challenger.SampleE()
+
+	trace := circuit.PCS.traceOpenings(0)
+	local, next := trace[0].Local, trace[0].Next
+	quotientChunk := extensionVars(circuit.PCS.Values[1][0][0])
+	selectors := SelectorsAtPoint(chip, fibonacciLogHeight, zeta)
+	endFold := chip.TraceOperation("FoldConstraints")
+	folded := chip.Zero()
+	for _, constraint := range []babybear.ExtensionVariable{
+		chip.MulE(selectors.IsFirstRow, chip.SubE(local[0], publicValues[0])),
+		chip.MulE(selectors.IsFirstRow, chip.SubE(local[1], publicValues[1])),
+		chip.MulE(selectors.IsTransition, chip.SubE(next[0], local[1])),
+		chip.MulE(selectors.IsTransition, chip.SubE(next[1], chip.AddE(local[0], local[1]))),
+		chip.MulE(selectors.IsLastRow, chip.SubE(local[1], publicValues[2])),
+	} {
+		folded = chip.AddE(chip.MulE(folded, alpha), constraint)
+	}
+	// The quotient is the sum of its coordinate polynomials times the basis of the extension.
+	quotient := chip.Zero()
+	for j, value := range quotientChunk {
+		basis := []string{"0", "0", "0", "0"}
+		basis[j] = "1"
+		quotient = chip.AddE(quotient, chip.MulE(babybear.NewE(basis), value))
+	}
+	chip.AssertIsEqualE(chip.MulE(folded, selectors.InvZeroifier), quotient)
+	endFold()
+
+	rounds := []TwoAdicPcsRound{
+		TraceRound(chip, traceCommit, []int{fibonacciLogHeight}, zeta, trace),
+		{BatchCommit: quotientCommit, Mats: []TwoAdicPcsMat{{
+			LogDomainSize: fibonacciLogHeight,
+			Points:        []babybear.ExtensionVariable{zeta},
+			Values:        [][]babybear.ExtensionVariable{quotientChunk},
+		}}},
+	}
+	proof := circuit.PCS.proof()
+	VerifyTwoAdicPcs(chip, hasher, challenger, circuit.PCS.config, rounds, &proof)
+	return nil
+}
+
+func newStarkCircuit(f *starkFixture) *starkCircuit {
+	return &starkCircuit{
+		PublicValues: [3]frontend.Variable{f.publicValues[0], f.publicValues[1], f.publicValues[2]},
+		PCS:          *newPcsCircuit(f.pcs),
+	}
+}
+
+func TestVerifyMockStarkProof(t *testing.T) {
+	// The eighth Fibonacci number is 21.
+	publicValues := [3]uint64{0, 1, 21}
+	prove := func() *starkFixture {
+		f, err := starkProve(t, publicValues)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	f := prove()
+	circuit := newStarkCircuit(f)
+	if err := test.IsSolved(circuit, newStarkCircuit(f), ecc.BN254.ScalarField()); err != nil {
+		t.Fatal(err)
+	}
+
+	increment := func(v *uint64) { *v = (*v + 1) % p }
+	for name, tamper := range map[string]func(f *starkFixture){
+		"public value": func(f *starkFixture) { increment(&f.publicValues[2]) },
+		"local row":    func(f *starkFixture) { increment(&f.pcs.values[0][0][0][1][0]) },
+		"next row":     func(f *starkFixture) { increment(&f.pcs.values[0][0][1][0][3]) },
+		"quotient":     func(f *starkFixture) { increment(&f.pcs.values[1][0][0][2][1]) },
+		"trace commit": func(f *starkFixture) { increment(&f.pcs.commits[0][0]) },
+		"opened row":   func(f *starkFixture) { increment(&f.pcs.rows[0][1][0][3]) },
+		"swapped rows": func(f *starkFixture) {
+			f.pcs.values[0][0][0], f.pcs.values[0][0][1] = f.pcs.values[0][0][1], f.pcs.values[0][0][0]
+		},
+		"folding commit": func(f *starkFixture) { increment(&f.pcs.friCommits[1][4]) },
+	} {
+		tampered := prove()
+		tamper(tampered)
+		if err := test.IsSolved(circuit, newStarkCircuit(tampered), ecc.BN254.ScalarField()); err == nil {
+			t.Errorf("%s: expected the tampered proof to be rejected", name)
+		}
+	}
+
+	// The prover cannot prove a wrong last value, whose constraints do not vanish on the domain.
+	if _, err := starkProve(t, [3]uint64{0, 1, 22}); err == nil {
+		t.Error("expected the prover to fail on a trace that does not satisfy the constraints")
+	}
+}
@@ -0,0 +1,210 @@
+package verifier
+
+import (
+	"fmt"
+
+	"github.com/consensys/gnark/frontend"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/poseidon2"
+)
+
+// The number of words of the committed values digest, and the width of the challenger sponge.
+const PV_DIGEST_NUM_WORDS = 8
+const PERMUTATION_WIDTH = poseidon2.BABYBEAR_WIDTH
+
+// The number of felts of ChallengerPublicValues and PublicValues.
+const CHALLENGER_NUM_PV_ELTS = 3*PERMUTATION_WIDTH + 2
+const RECURSIVE_PROOF_NUM_PV_ELTS = 4*PV_DIGEST_NUM_WORDS + 6*DIGEST_SIZE + 3*CHALLENGER_NUM_PV_ELTS + 11
+
+// The number of felts of PublicValues hashed into its digest, the ones before the digest.
+const NUM_PV_ELMS_TO_HASH = RECURSIVE_PROOF_NUM_PV_ELTS - DIGEST_SIZE - 1
+
+// ChallengerPublicValues is the state of a duplex challenger, laid out like ChallengerPublicValues
+// in the SP1 recursion program. Only the first NumInputs and NumOutputs elements of the buffers
+// are part of the state.
+type ChallengerPublicValues struct {
+	SpongeState  [PERMUTATION_WIDTH]babybear.Variable
+	NumInputs    babybear.Variable
+	InputBuffer  [PERMUTATION_WIDTH]babybear.Variable
+	NumOutputs   babybear.Variable
+	OutputBuffer [PERMUTATION_WIDTH]babybear.Variable
+}
+
+// PublicValues are the public values of a recursion proof, laid out like RecursionPublicValues in
+// the SP1 recursion program.
+type PublicValues struct {
+	CommittedValueDigest           [PV_DIGEST_NUM_WORDS][4]babybear.Variable
+	DeferredProofsDigest           [DIGEST_SIZE]babybear.Variable
+	StartPc                        babybear.Variable
+	NextPc                         babybear.Variable
+	StartShard                     babybear.Variable
+	NextShard                      babybear.Variable
+	StartReconstructChallenger     ChallengerPublicValues
+	EndReconstructChallenger       ChallengerPublicValues
+	StartReconstructDeferredDigest [DIGEST_SIZE]babybear.Variable
+	EndReconstructDeferredDigest   [DIGEST_SIZE]babybear.Variable
+	Sp1VkDigest                    [DIGEST_SIZE]babybear.Variable
+	CompressVkDigest               [DIGEST_SIZE]babybear.Variable
+	LeafChallenger                 ChallengerPublicValues
+	CumulativeSum                  [4]babybear.Variable
+	IsComplete                     babybear.Variable
+	TotalCoreShards                babybear.Variable
+	Digest                         [DIGEST_SIZE]babybear.Variable
+	ExitCode                       babybear.Variable
+}
+
+// NewPublicValues lays out the RECURSIVE_PROOF_NUM_PV_ELTS public values felts of a recursion
+// proof in the order of the SP1 recursion program.
+func NewPublicValues(felts []babybear.Variable) (*PublicValues, error) {
+	if len(felts) != RECURSIVE_PROOF_NUM_PV_ELTS {
+		return nil, fmt.Errorf("expected %d public values, got %d", RECURSIVE_PROOF_NUM_PV_ELTS, len(felts))
+	}
+	next := func(out ...*babybear.Variable) {
+		for _, v := range out {
+			*v = felts[0]
+			felts = felts[1:]
+		}
+	}
+	array := func(out []babybear.Variable) {
+		for i := range out {
+			next(&out[i])
+		}
+	}
+	challenger := func(out *ChallengerPublicValues) {
+		array(out.SpongeState[:])
+		next(&out.NumInputs)
+		array(out.InputBuffer[:])
+		next(&out.NumOutputs)
+		array(out.OutputBuffer[:])
+	}
+
+	pv := &PublicValues{}
+	for i := range pv.CommittedValueDigest {
+		array(pv.CommittedValueDigest[i][:])
+	}
+	array(pv.DeferredProofsDigest[:])
+	next(&pv.StartPc, &pv.NextPc, &pv.StartShard, &pv.NextShard)
+	challenger(&pv.StartReconstructChallenger)
+	challenger(&pv.EndReconstructChallenger)
+	array(pv.StartReconstructDeferredDigest[:])
+	array(pv.EndReconstructDeferredDigest[:])
+	array(pv.Sp1VkDigest[:])
+	array(pv.CompressVkDigest[:])
+	challenger(&pv.LeafChallenger)
+	array(pv.CumulativeSum[:])
+	next(&pv.IsComplete, &pv.TotalCoreShards)
+	array(pv.Digest[:])
+	next(&pv.ExitCode)
+	return pv, nil
+}
+
+// Felts is the inverse of NewPublicValues.
+func (pv *PublicValues) Felts() []babybear.Variable {
+	felts := make([]babybear.Variable, 0, RECURSIVE_PROOF_NUM_PV_ELTS)
+	challenger := func(c *ChallengerPublicValues) {
+		felts = append(felts, c.SpongeState[:]...)
+		felts = append(felts, c.NumInputs)
+		felts = append(felts, c.InputBuffer[:]...)
+		felts = append(felts, c.NumOutputs)
+		felts = append(felts, c.OutputBuffer[:]...)
+	}
+
+	for i := range pv.CommittedValueDigest {
+		felts = append(felts, pv.CommittedValueDigest[i][:]...)
+	}
+	felts = append(felts, pv.DeferredProofsDigest[:]...)
+	felts = append(felts, pv.StartPc, pv.NextPc, pv.StartShard, pv.NextShard)
+	challenger(&pv.StartReconstructChallenger)
+	challenger(&pv.EndReconstructChallenger)
+	felts = append(felts, pv.StartReconstructDeferredDigest[:]...)
+	felts = append(felts, pv.EndReconstructDeferredDigest[:]...)
+	felts = append(felts, pv.Sp1VkDigest[:]...)
+	felts = append(felts, pv.CompressVkDigest[:]...)
+	challenger(&pv.LeafChallenger)
+	felts = append(felts, pv.CumulativeSum[:]...)
+	felts = append(felts, pv.IsComplete, pv.TotalCoreShards)
+	felts = append(felts, pv.Digest[:]...)
+	return append(felts, pv.ExitCode)
+}
+
+// AssertCompletion asserts that the is_complete flag of the public values is boolean and returns
+// it. When the flag is set, it enforces the checks the SP1 recursion program does before setting
+// it, so that the flag means the proof covers the whole execution of the program of vk:
+//   - the execution starts at the start pc of vk, in shard 1,
+//   - the program has halted, which sets the next pc to 0,
+//   - the total number of core shards is the number of shards proven,
+//   - the reconstructed challenger is the leaf challenger,
+//   - the reconstructed deferred proofs digest starts at zero and ends at the deferred proofs
+//     digest,
+//   - the cumulative sum of the lookups is zero.
+func AssertCompletion(chip *babybear.Chip, pv *PublicValues, vk *VerifyingKey) frontend.Variable {
+	isComplete := chip.AssertIsBooleanF(pv.IsComplete)
+	assertEqualIf := func(cond frontend.Variable, a, b babybear.Variable) {
+		chip.AssertIsEqualF(chip.SelectF(cond, a, b), b)
+	}
+	zero := constant(0)
+
+	assertEqualIf(isComplete, pv.StartPc, constant(uint64(vk.PcStart)))
+	assertEqualIf(isComplete, pv.StartShard, constant(1))
+	assertEqualIf(isComplete, pv.NextPc, zero)
+	assertEqualIf(isComplete, pv.TotalCoreShards, chip.SubF(pv.NextShard, constant(1)))
+
+	end, leaf := pv.EndReconstructChallenger, pv.LeafChallenger
+	for i := range end.SpongeState {
+		assertEqualIf(isComplete, end.SpongeState[i], leaf.SpongeState[i])
+	}
+	assertEqualIf(isComplete, end.NumInputs, leaf.NumInputs)
+	assertEqualIf(isComplete, end.NumOutputs, leaf.NumOutputs)
+	for i := 0; i < PERMUTATION_WIDTH; i++ {
+		// The elements of the buffers past their length are unconstrained.
+		position := constant(uint64(i))
+		isInput := chip.IsLessThanF(position, leaf.NumInputs)
+		assertEqualIf(isComplete, chip.SelectF(isInput, end.InputBuffer[i], leaf.InputBuffer[i]), leaf.InputBuffer[i])
+		isOutput := chip.IsLessThanF(position, leaf.NumOutputs)
+		assertEqualIf(isComplete, chip.SelectF(isOutput, end.OutputBuffer[i], leaf.OutputBuffer[i]), leaf.OutputBuffer[i])
+	}
+
+	for i := range pv.StartReconstructDeferredDigest {
+		assertEqualIf(isComplete, pv.StartReconstructDeferredDigest[i], zero)
+		assertEqualIf(isComplete, pv.EndReconstructDeferredDigest[i], pv.DeferredProofsDigest[i])
+	}
+	for i := range pv.CumulativeSum {
+		assertEqualIf(isComplete, pv.CumulativeSum[i], zero)
+	}
+	return isComplete
+}
+
+// AssertShardChaining asserts that the public values of a sequence of proofs chain into the
+// execution of the program of vk, from its start to its halt:
+//   - the first proof starts at the start pc of vk, in shard 1,
+//   - every proof covers at least one shard,
+//   - every proof starts at the next pc and the next shard of the previous one,
+//   - only the last proof halts, which sets its next pc to 0, so every start pc is non-zero.
+//
+// The sequence must not be empty.
+func AssertShardChaining(chip *babybear.Chip, pvs []*PublicValues, vk *VerifyingKey) {
+	if len(pvs) == 0 {
+		panic("no public values to chain")
+	}
+	one := constant(1)
+	chip.AssertIsEqualF(pvs[0].StartPc, constant(uint64(vk.PcStart)))
+	chip.AssertIsEqualF(pvs[0].StartShard, one)
+
+	for i, pv := range pvs {
+		if i > 0 {
+			chip.AssertIsEqualF(pv.StartPc, pvs[i-1].NextPc)
+			chip.AssertIsEqualF(pv.StartShard, pvs[i-1].NextShard)
+		}
+		// The counters are felts, so without the comparison a proof could wrap them around the
+		// modulus and skip shards.
+		covers := chip.IsLessThanF(pv.StartShard, pv.NextShard)
+		chip.AssertIsEqualF(babybear.Variable{Value: covers, NbBits: 31}, one)
+		// InvF asserts that its input is non-zero.
+		chip.InvF(pv.StartPc)
+	}
+	chip.AssertIsEqualF(pvs[len(pvs)-1].NextPc, constant(0))
+}
+
+func constant(v uint64) babybear.Variable {
+	return babybear.Variable{Value: v, NbBits: 31}
+}
@@ -0,0 +1,281 @@
+package verifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+)
+
+// Offsets of the fields of the public values checked for completeness and chaining.
+const (
+	startPcOffset                  = 40
+	nextPcOffset                   = 41
+	startShardOffset               = 42
+	nextShardOffset                = 43
+	endReconstructChallengerOffset = 94
+	startDeferredDigestOffset      = 144
+	endDeferredDigestOffset        = 152
+	cumulativeSumOffset            = 226
+	isCompleteOffset               = 230
+	totalCoreShardsOffset          = 231
+)
+
+type completionCircuit struct {
+	PublicValues [RECURSIVE_PROOF_NUM_PV_ELTS]frontend.Variable
+	IsComplete   frontend.Variable `gnark:",public"`
+
+	vk *VerifyingKey `gnark:"-"`
+}
+
+func (circuit *completionCircuit) Define(api frontend.API) error {
+	chip := babybear.NewChip(api)
+	felts := make([]babybear.Variable, len(circuit.PublicValues))
+	for i, v := range circuit.PublicValues {
+		felts[i] = babybear.Variable{Value: v, NbBits: 31}
+	}
+	pv, err := NewPublicValues(felts)
+	if err != nil {
+		return err
+	}
+	api.AssertIsEqual(AssertCompletion(chip, pv, circuit.vk), circuit.IsComplete)
+	return nil
+}
+
+func readTestPublicValues(t *testing.T, name string) [RECURSIVE_PROOF_NUM_PV_ELTS]uint64 {
+	data, err := os.ReadFile("testdata/" + name + "_public_values.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var felts []string
+	if err := json.Unmarshal(data, &felts); err != nil {
+		t.Fatal(err)
+	}
+	if len(felts) != RECURSIVE_PROOF_NUM_PV_ELTS {
+		t.Fatalf("%s: expected %d public values, got %d", name, RECURSIVE_PROOF_NUM_PV_ELTS, len(felts))
+	}
+	var out [RECURSIVE_PROOF_NUM_PV_ELTS]uint64
+	for i, f := range felts {
+		if _, err := fmt.Sscan(f, &out[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return out
+}
+
+func solveCompletion(pv [RECURSIVE_PROOF_NUM_PV_ELTS]uint64, isComplete uint64) error {
+	circuit := completionCircuit{vk: testVerifyingKey()}
+	assignment := completionCircuit{IsComplete: isComplete}
+	for i, v := range pv {
+		assignment.PublicValues[i] = v
+	}
+	return test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField())
+}
+
+func TestAssertCompletion(t *testing.T) {
+	if err := solveCompletion(readTestPublicValues(t, "complete"), 1); err != nil {
+		t.Errorf("complete: %v", err)
+	}
+	if err := solveCompletion(readTestPublicValues(t, "incomplete"), 0); err != nil {
+		t.Errorf("incomplete: %v", err)
+	}
+	// The flag is set but none of the conditions hold.
+	if err := solveCompletion(readTestPublicValues(t, "malicious"), 1); err == nil {
+		t.Error("malicious: expected the completeness checks to fail")
+	}
+}
+
+func TestAssertCompletionChecks(t *testing.T) {
+	p := babybear.MODULUS.Uint64()
+	challenger := endReconstructChallengerOffset
+	for name, offset := range map[string]int{
+		"start pc":                   startPcOffset,
+		"next pc":                    nextPcOffset,
+		"start shard":                startShardOffset,
+		"total core shards":          totalCoreShardsOffset,
+		"sponge state":               challenger + 15,
+		"num inputs":                 challenger + 16,
+		"input buffer":               challenger + 17 + 2,
+		"num outputs":                challenger + 33,
+		"output buffer":              challenger + 34 + 4,
+		"start deferred digest":      startDeferredDigestOffset + 7,
+		"end deferred digest":        endDeferredDigestOffset,
+		"cumulative sum":             cumulativeSumOffset + 3,
+		"is complete":                isCompleteOffset,
+		"non-canonical start pc":     -startPcOffset,
+		"non-canonical is complete":  -isCompleteOffset,
+		"non-canonical input buffer": -(challenger + 17),
+	} {
+		pv := readTestPublicValues(t, "complete")
+		if offset < 0 {
+			pv[-offset] += p
+		} else {
+			pv[offset] = (pv[offset] + 1) % p
+		}
+		if err := solveCompletion(pv, pv[isCompleteOffset]); err == nil {
+			t.Errorf("%s: expected the completeness checks to fail", name)
+		}
+	}
+
+	// The buffers of the challengers only have to match within their lengths.
+	pv := readTestPublicValues(t, "complete")
+	pv[challenger+17+3]++
+	pv[challenger+34+5]++
+	if err := solveCompletion(pv, 1); err != nil {
+		t.Errorf("expected the elements past the buffer lengths to be ignored: %v", err)
+	}
+}
+
+func TestNewPublicValues(t *testing.T) {
+	if _, err := NewPublicValues(make([]babybear.Variable, RECURSIVE_PROOF_NUM_PV_ELTS-1)); err == nil {
+		t.Fatal("expected missing public values to be rejected")
+	}
+
+	felts := make([]babybear.Variable, RECURSIVE_PROOF_NUM_PV_ELTS)
+	for i := range felts {
+		felts[i] = babybear.Variable{Value: i}
+	}
+	pv, err := NewPublicValues(felts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, field := range map[string]struct {
+		v      babybear.Variable
+		offset int
+	}{
+		"start pc":          {pv.StartPc, startPcOffset},
+		"num inputs":        {pv.EndReconstructChallenger.NumInputs, endReconstructChallengerOffset + 16},
+		"start digest":      {pv.StartReconstructDeferredDigest[0], startDeferredDigestOffset},
+		"is complete":       {pv.IsComplete, isCompleteOffset},
+		"total core shards": {pv.TotalCoreShards, totalCoreShardsOffset},
+		"exit code":         {pv.ExitCode, RECURSIVE_PROOF_NUM_PV_ELTS - 1},
+	} {
+		if field.v.Value != field.offset {
+			t.Errorf("%s is at offset %v, expected %d", name, field.v.Value, field.offset)
+		}
+	}
+
+	felts = pv.Felts()
+	if len(felts) != RECURSIVE_PROOF_NUM_PV_ELTS {
+		t.Fatalf("expected %d felts, got %d", RECURSIVE_PROOF_NUM_PV_ELTS, len(felts))
+	}
+	for i, felt := range felts {
+		if felt.Value != i {
+			t.Fatalf("felt %d is %v", i, felt.Value)
+		}
+	}
+}
+
+type shardChainingCircuit struct {
+	PublicValues [][RECURSIVE_PROOF_NUM_PV_ELTS]frontend.Variable
+
+	vk *VerifyingKey `gnark:"-"`
+}
+
+func (circuit *shardChainingCircuit) Define(api frontend.API) error {
+	chip := babybear.NewChip(api)
+	pvs := make([]*PublicValues, len(circuit.PublicValues))
+	for i, values := range circuit.PublicValues {
+		felts := make([]babybear.Variable, len(values))
+		for j, v := range values {
+			felts[j] = babybear.Variable{Value: v, NbBits: 31}
+		}
+		var err error
+		if pvs[i], err = NewPublicValues(felts); err != nil {
+			return err
+		}
+	}
+	AssertShardChaining(chip, pvs, circuit.vk)
+	return nil
+}
+
+func readTestShards(t *testing.T) [][RECURSIVE_PROOF_NUM_PV_ELTS]uint64 {
+	data, err := os.ReadFile("testdata/shards_public_values.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var shards [][RECURSIVE_PROOF_NUM_PV_ELTS]string
+	if err := json.Unmarshal(data, &shards); err != nil {
+		t.Fatal(err)
+	}
+	out := make([][RECURSIVE_PROOF_NUM_PV_ELTS]uint64, len(shards))
+	for i := range shards {
+		for j, f := range shards[i] {
+			if _, err := fmt.Sscan(f, &out[i][j]); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return out
+}
+
+func solveShardChaining(shards [][RECURSIVE_PROOF_NUM_PV_ELTS]uint64) error {
+	circuit := shardChainingCircuit{
+		PublicValues: make([][RECURSIVE_PROOF_NUM_PV_ELTS]frontend.Variable, len(shards)),
+		vk:           testVerifyingKey(),
+	}
+	assignment := shardChainingCircuit{PublicValues: make([][RECURSIVE_PROOF_NUM_PV_ELTS]frontend.Variable, len(shards))}
+	for i := range shards {
+		for j, v := range shards[i] {
+			assignment.PublicValues[i][j] = v
+		}
+	}
+	return test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField())
+}
+
+func TestAssertShardChaining(t *testing.T) {
+	if err := solveShardChaining(readTestShards(t)); err != nil {
+		t.Fatal(err)
+	}
+	// A single proof of the whole execution.
+	whole := readTestShards(t)[:1]
+	whole[0][nextPcOffset] = 0
+	whole[0][nextShardOffset] = 3
+	if err := solveShardChaining(whole); err != nil {
+		t.Fatal(err)
+	}
+
+	p := babybear.MODULUS.Uint64()
+	for name, mutate := range map[string]func(shards [][RECURSIVE_PROOF_NUM_PV_ELTS]uint64){
+		"shard gap": func(shards [][RECURSIVE_PROOF_NUM_PV_ELTS]uint64) {
+			shards[1][startShardOffset] = 3
+			shards[1][nextShardOffset] = 4
+		},
+		"pc mismatch": func(shards [][RECURSIVE_PROOF_NUM_PV_ELTS]uint64) {
+			shards[1][startPcOffset] += 4
+		},
+		"start pc": func(shards [][RECURSIVE_PROOF_NUM_PV_ELTS]uint64) {
+			shards[0][startPcOffset] += 4
+		},
+		"start shard": func(shards [][RECURSIVE_PROOF_NUM_PV_ELTS]uint64) {
+			shards[0][startShardOffset] = 0
+		},
+		"empty proof": func(shards [][RECURSIVE_PROOF_NUM_PV_ELTS]uint64) {
+			shards[0][nextShardOffset] = 1
+			shards[1][startShardOffset] = 1
+			shards[1][nextShardOffset] = 2
+		},
+		"wrapped shard": func(shards [][RECURSIVE_PROOF_NUM_PV_ELTS]uint64) {
+			shards[0][nextShardOffset] = p - 1
+			shards[1][startShardOffset] = p - 1
+		},
+		"early halt": func(shards [][RECURSIVE_PROOF_NUM_PV_ELTS]uint64) {
+			shards[0][nextPcOffset] = 0
+			shards[1][startPcOffset] = 0
+		},
+		"no halt": func(shards [][RECURSIVE_PROOF_NUM_PV_ELTS]uint64) {
+			shards[1][nextPcOffset] = 2105344
+		},
+	} {
+		shards := readTestShards(t)
+		mutate(shards)
+		if err := solveShardChaining(shards); err == nil {
+			t.Errorf("%s: expected the shard chaining checks to fail", name)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package verifier
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+)
+
+// LagrangeSelectors are the selectors of the constraints of an AIR at a point, like
+// LagrangeSelectors in Plonky3, and the inverse of the polynomial vanishing on the trace domain
+// that divides the folded constraints into the quotient.
+type LagrangeSelectors struct {
+	IsFirstRow   babybear.ExtensionVariable
+	IsLastRow    babybear.ExtensionVariable
+	IsTransition babybear.ExtensionVariable
+	InvZeroifier babybear.ExtensionVariable
+}
+
+// SelectorsAtPoint returns the selectors at zeta of the trace domain of size n = 2^logDomainSize,
+// the subgroup generated by g, like selectors_at_point of TwoAdicMultiplicativeCoset in Plonky3.
+// With Z(x) = x^n - 1, the row selectors are Z(zeta) / (zeta - 1) and Z(zeta) / (zeta - g^-1),
+// which are not normalized, and the transition selector is zeta - g^-1. zeta must not belong to
+// the domain, where Z is zero and makes the circuit unsatisfiable.
+func SelectorsAtPoint(chip *babybear.Chip, logDomainSize int, zeta babybear.ExtensionVariable) LagrangeSelectors {
+	if logDomainSize < 0 || logDomainSize > TWO_ADICITY {
+		panic(fmt.Sprintf("invalid trace domain of size 2^%d", logDomainSize))
+	}
+	g := new(big.Int).SetUint64(twoAdicGenerator(logDomainSize))
+	gInv := babybear.NewF(new(big.Int).ModInverse(g, babybear.MODULUS).String())
+
+	zeroifier := chip.SubE(chip.ExpE(zeta, new(big.Int).Lsh(big.NewInt(1), uint(logDomainSize))), chip.One())
+	isTransition := chip.SubEF(zeta, gInv)
+	return LagrangeSelectors{
+		IsFirstRow:   chip.DivE(zeroifier, chip.SubE(zeta, chip.One())),
+		IsLastRow:    chip.DivE(zeroifier, isTransition),
+		IsTransition: isTransition,
+		InvZeroifier: chip.InvE(zeroifier),
+	}
+}
@@ -0,0 +1,63 @@
+package verifier
+
+import (
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+)
+
+type selectorsCircuit struct {
+	Zeta      [4]frontend.Variable
+	Selectors [4][4]frontend.Variable
+
+	logDomainSize int `gnark:"-"`
+}
+
+func (circuit *selectorsCircuit) Define(api frontend.API) error {
+	chip := babybear.NewChip(api)
+	selectors := SelectorsAtPoint(chip, circuit.logDomainSize, extensionVar(circuit.Zeta))
+	for i, selector := range []babybear.ExtensionVariable{selectors.IsFirstRow, selectors.IsLastRow, selectors.IsTransition, selectors.InvZeroifier} {
+		chip.AssertIsEqualE(selector, extensionVar(circuit.Selectors[i]))
+	}
+	return nil
+}
+
+// nativeSelectors returns the selectors of SelectorsAtPoint: is_first_row, is_last_row,
+// is_transition and inv_zeroifier.
+func nativeSelectors(logDomainSize int, zeta ext) [4]ext {
+	zeroifier := zeta
+	for i := 0; i < logDomainSize; i++ {
+		zeroifier = mulE(zeroifier, zeroifier)
+	}
+	zeroifier = subE(zeroifier, ext{1})
+	g := twoAdicGenerator(logDomainSize)
+	isTransition := subE(zeta, ext{expF(g, 1<<logDomainSize-1)})
+	return [4]ext{
+		mulE(zeroifier, invE(subE(zeta, ext{1}))),
+		mulE(zeroifier, invE(isTransition)),
+		isTransition,
+		invE(zeroifier),
+	}
+}
+
+func TestSelectorsAtPoint(t *testing.T) {
+	zeta := ext{1234567, 89, 1 << 30, p - 1}
+	for _, logDomainSize := range []int{0, 1, 3, 16} {
+		selectors := nativeSelectors(logDomainSize, zeta)
+		circuit := selectorsCircuit{logDomainSize: logDomainSize}
+		assignment := selectorsCircuit{Zeta: [4]frontend.Variable{zeta[0], zeta[1], zeta[2], zeta[3]}}
+		for i, selector := range selectors {
+			assignment.Selectors[i] = [4]frontend.Variable{selector[0], selector[1], selector[2], selector[3]}
+		}
+		if err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()); err != nil {
+			t.Errorf("log domain size %d: %v", logDomainSize, err)
+		}
+		assignment.Selectors[0], assignment.Selectors[1] = assignment.Selectors[1], assignment.Selectors[0]
+		if err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()); err == nil && logDomainSize > 0 {
+			t.Errorf("log domain size %d: expected the swapped row selectors to be rejected", logDomainSize)
+		}
+	}
+}
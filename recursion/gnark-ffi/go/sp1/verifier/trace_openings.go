@@ -0,0 +1,71 @@
+package verifier
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/poseidon2"
+)
+
+// TraceOpenings are the values the columns of a trace take at zeta, its local row, and at the
+// point of the next row.
+type TraceOpenings struct {
+	Local []babybear.ExtensionVariable
+	Next  []babybear.ExtensionVariable
+}
+
+// NextPoint returns the point of the row after zeta in the trace domain of size 2^logDomainSize,
+// zeta * g for g the generator of the domain, like next_point of TwoAdicMultiplicativeCoset in
+// Plonky3.
+func NextPoint(chip *babybear.Chip, logDomainSize int, zeta babybear.ExtensionVariable) babybear.ExtensionVariable {
+	if logDomainSize < 0 || logDomainSize > TWO_ADICITY {
+		panic(fmt.Sprintf("invalid trace domain of size 2^%d", logDomainSize))
+	}
+	g := babybear.NewF(strconv.FormatUint(twoAdicGenerator(logDomainSize), 10))
+	return chip.MulEF(zeta, g)
+}
+
+// TraceRound returns the round of a batch commitment to traces opened at zeta and at the point of
+// their next row, the trace of openings[i] being over a domain of size 2^logDomainSizes[i]. The
+// trace of a chip absent from the shard has a negative log domain size, and neither points nor
+// values.
+func TraceRound(
+	chip *babybear.Chip,
+	batchCommit [DIGEST_SIZE]babybear.Variable,
+	logDomainSizes []int,
+	zeta babybear.ExtensionVariable,
+	openings []TraceOpenings,
+) TwoAdicPcsRound {
+	if len(openings) != len(logDomainSizes) {
+		panic(fmt.Sprintf("expected the openings of %d traces, got %d", len(logDomainSizes), len(openings)))
+	}
+	round := TwoAdicPcsRound{BatchCommit: batchCommit}
+	for i, logDomainSize := range logDomainSizes {
+		mat := TwoAdicPcsMat{LogDomainSize: logDomainSize}
+		if logDomainSize >= 0 {
+			mat.Points = []babybear.ExtensionVariable{zeta, NextPoint(chip, logDomainSize, zeta)}
+			mat.Values = [][]babybear.ExtensionVariable{openings[i].Local, openings[i].Next}
+		}
+		round.Mats = append(round.Mats, mat)
+	}
+	return round
+}
+
+// VerifyTraceOpenings verifies the openings of the traces of a single batch commitment at zeta and
+// at the points of their next rows. Proofs of several rounds verify the rounds of TraceRound
+// together with VerifyTwoAdicPcs.
+func VerifyTraceOpenings(
+	chip *babybear.Chip,
+	hasher *poseidon2.Poseidon2BabyBearChip,
+	challenger *Challenger,
+	config FriConfig,
+	batchCommit [DIGEST_SIZE]babybear.Variable,
+	logDomainSizes []int,
+	zeta babybear.ExtensionVariable,
+	openings []TraceOpenings,
+	proof *TwoAdicPcsProof,
+) {
+	round := TraceRound(chip, batchCommit, logDomainSizes, zeta, openings)
+	VerifyTwoAdicPcs(chip, hasher, challenger, config, []TwoAdicPcsRound{round}, proof)
+}
@@ -0,0 +1,77 @@
+package verifier
+
+import (
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+)
+
+type nextPointCircuit struct {
+	Zeta [4]frontend.Variable
+	Next [4]frontend.Variable
+
+	logDomainSize int `gnark:"-"`
+}
+
+func (circuit *nextPointCircuit) Define(api frontend.API) error {
+	chip := babybear.NewChip(api)
+	chip.AssertIsEqualE(NextPoint(chip, circuit.logDomainSize, extensionVar(circuit.Zeta)), extensionVar(circuit.Next))
+	return nil
+}
+
+func TestNextPoint(t *testing.T) {
+	zeta := ext{1234567, 89, 1 << 30, p - 1}
+	for _, logDomainSize := range []int{0, 1, 3, 22, TWO_ADICITY} {
+		// The generator of the domain of size 2^n is a 2^n-th root of unity, and not a smaller one.
+		g := twoAdicGenerator(logDomainSize)
+		if logDomainSize > 0 && (expF(g, 1<<logDomainSize) != 1 || expF(g, 1<<(logDomainSize-1)) == 1) {
+			t.Fatalf("%d is not a generator of the subgroup of order 2^%d", g, logDomainSize)
+		}
+		next := mulE(zeta, ext{g})
+		circuit := nextPointCircuit{logDomainSize: logDomainSize}
+		assignment := nextPointCircuit{
+			Zeta: [4]frontend.Variable{zeta[0], zeta[1], zeta[2], zeta[3]},
+			Next: [4]frontend.Variable{next[0], next[1], next[2], next[3]},
+		}
+		if err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()); err != nil {
+			t.Errorf("log domain size %d: %v", logDomainSize, err)
+		}
+		assignment.Next = assignment.Zeta
+		if err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()); err == nil && logDomainSize > 0 {
+			t.Errorf("log domain size %d: expected zeta to be rejected as its next point", logDomainSize)
+		}
+	}
+}
+
+func TestVerifyTraceOpenings(t *testing.T) {
+	// Traces of several heights, with a chip absent from the shard, like the main and permutation
+	// rounds of a shard proof.
+	shape := pcsShape{
+		config:         FriConfig{LogBlowup: 1, NumQueries: 2, ProofOfWorkBits: 2},
+		logDomainSizes: [][]int{{3, 2, -1, 3}, {3, 2, -1, 3}},
+		widths:         [][]int{{3, 1, 0, 2}, {2, 2, 0, 1}},
+		traces:         true,
+	}
+	prove := func(t *testing.T) *pcsFixture { return pcsProveShape(t, shape) }
+	checkPcsFixture(t, prove)
+
+	single := shape
+	single.logDomainSizes, single.widths = shape.logDomainSizes[:1], shape.widths[:1]
+	f := pcsProveShape(t, single)
+	circuit := newPcsCircuit(f)
+	if err := test.IsSolved(circuit, newPcsCircuit(f), ecc.BN254.ScalarField()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The local and next rows are opened at distinct points.
+	for _, i := range []int{0, 1} {
+		swapped := pcsProveShape(t, single)
+		swapped.values[0][i][0], swapped.values[0][i][1] = swapped.values[0][i][1], swapped.values[0][i][0]
+		if err := test.IsSolved(circuit, newPcsCircuit(swapped), ecc.BN254.ScalarField()); err == nil {
+			t.Errorf("trace %d: expected the swapped local and next rows to be rejected", i)
+		}
+	}
+}
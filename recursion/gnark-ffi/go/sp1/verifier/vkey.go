@@ -0,0 +1,81 @@
+package verifier
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark/frontend"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/converter"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/poseidon2"
+)
+
+const DIGEST_SIZE = poseidon2.BABYBEAR_DIGEST_SIZE
+
+// The BabyBear two-adicity, and a generator of its subgroup of order 2^TWO_ADICITY.
+const TWO_ADICITY = 27
+const TWO_ADIC_GENERATOR = 0x1a427a41
+
+// VerifyingKey holds the contents of an SP1 StarkVerifyingKey that its hash commits to. Field
+// elements are canonical BabyBear values.
+type VerifyingKey struct {
+	Commit          [DIGEST_SIZE]uint32 `json:"commit"`
+	PcStart         uint32              `json:"pc_start"`
+	ChipInformation []ChipInformation   `json:"chip_information"`
+}
+
+// ChipInformation describes the preprocessed trace domain of a chip: the coset of size 2^LogN
+// shifted by Shift.
+type ChipInformation struct {
+	Name  string `json:"name"`
+	LogN  int    `json:"log_n"`
+	Shift uint32 `json:"shift"`
+}
+
+// ComputeVKeyHash hashes the verifying key the same way as HashableKey::hash_babybear in the SP1
+// prover. It returns the 8 word digest and its packing into a BN254 scalar, which is the vkey hash
+// public input of the wrapper circuit.
+func ComputeVKeyHash(vk *VerifyingKey) ([DIGEST_SIZE]uint32, *big.Int, error) {
+	var words [DIGEST_SIZE]uint32
+
+	inputs := make([]uint64, 0, DIGEST_SIZE+1+4*len(vk.ChipInformation))
+	for _, element := range vk.Commit {
+		inputs = append(inputs, uint64(element))
+	}
+	inputs = append(inputs, uint64(vk.PcStart))
+	for _, chip := range vk.ChipInformation {
+		if chip.LogN < 0 || chip.LogN > TWO_ADICITY {
+			return words, nil, fmt.Errorf("chip %s: invalid domain size 2^%d", chip.Name, chip.LogN)
+		}
+		inputs = append(inputs, uint64(chip.LogN), uint64(1)<<chip.LogN, uint64(chip.Shift), twoAdicGenerator(chip.LogN))
+	}
+	p := babybear.MODULUS.Uint64()
+	for i, input := range inputs {
+		if input >= p {
+			return words, nil, fmt.Errorf("input %d is not a canonical BabyBear element: %d", i, input)
+		}
+	}
+
+	for i, word := range poseidon2.HashBabyBearNative(inputs) {
+		words[i] = uint32(word)
+	}
+	return words, converter.BabyBearsToBN254Native(words), nil
+}
+
+// AssertVkeyHash asserts that vkeyHash, the vkey hash public input of the wrapper circuit, is the
+// packing of the SP1 verifying key digest of pv by BabyBearsToBN254. The proof verified under pv then
+// belongs to the program of the public input, so one deployed verifier can serve the proofs of
+// every program.
+func AssertVkeyHash(api frontend.API, chip *babybear.Chip, pv *PublicValues, vkeyHash frontend.Variable) {
+	api.AssertIsEqual(converter.BabyBearsToBN254(api, chip, pv.Sp1VkDigest), vkeyHash)
+}
+
+// twoAdicGenerator returns the generator of the BabyBear subgroup of order 2^bits.
+func twoAdicGenerator(bits int) uint64 {
+	p := babybear.MODULUS.Uint64()
+	g := uint64(TWO_ADIC_GENERATOR)
+	for i := bits; i < TWO_ADICITY; i++ {
+		g = g * g % p
+	}
+	return g
+}
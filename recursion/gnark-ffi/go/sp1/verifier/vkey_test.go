@@ -0,0 +1,153 @@
+package verifier
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/poseidon2"
+)
+
+func testVerifyingKey() *VerifyingKey {
+	return &VerifyingKey{
+		Commit:  [DIGEST_SIZE]uint32{1, 2, 3, 4, 5, 6, 7, 8},
+		PcStart: 2097152,
+		ChipInformation: []ChipInformation{
+			{Name: "Program", LogN: 19, Shift: 31},
+			{Name: "MemoryProgram", LogN: 16, Shift: 31},
+		},
+	}
+}
+
+func TestTwoAdicGenerator(t *testing.T) {
+	p := babybear.MODULUS.Uint64()
+	for _, bits := range []int{1, 16, TWO_ADICITY} {
+		g := twoAdicGenerator(bits)
+		for i := 0; i < bits-1; i++ {
+			g = g * g % p
+		}
+		if g != p-1 {
+			t.Fatalf("generator of 2^%d has the wrong order", bits)
+		}
+	}
+	if twoAdicGenerator(0) != 1 {
+		t.Fatal("expected the trivial subgroup to be generated by 1")
+	}
+}
+
+func TestComputeVKeyHash(t *testing.T) {
+	vk := testVerifyingKey()
+	words, packed, err := ComputeVKeyHash(vk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The commitment and pc_start, then the log size, size, shift and generator of each domain.
+	inputs := []uint64{1, 2, 3, 4, 5, 6, 7, 8, 2097152, 19, 1 << 19, 31, twoAdicGenerator(19), 16, 1 << 16, 31, twoAdicGenerator(16)}
+	expected := new(big.Int)
+	for i, word := range poseidon2.HashBabyBearNative(inputs) {
+		if uint64(words[i]) != word {
+			t.Fatalf("word %d = %d, expected %d", i, words[i], word)
+		}
+		expected.Mul(expected, big.NewInt(1<<31)).Add(expected, new(big.Int).SetUint64(word))
+	}
+	if packed.Cmp(expected) != 0 {
+		t.Fatalf("packed hash %s, expected %s", packed, expected)
+	}
+
+	// The chip order is part of the hash.
+	vk.ChipInformation[0], vk.ChipInformation[1] = vk.ChipInformation[1], vk.ChipInformation[0]
+	if _, swapped, _ := ComputeVKeyHash(vk); swapped.Cmp(packed) == 0 {
+		t.Fatal("expected reordering the chips to change the hash")
+	}
+}
+
+func TestComputeVKeyHashInvalid(t *testing.T) {
+	vk := testVerifyingKey()
+	vk.Commit[3] = uint32(babybear.MODULUS.Uint64())
+	if _, _, err := ComputeVKeyHash(vk); err == nil || !strings.Contains(err.Error(), "input 3") {
+		t.Fatalf("expected a non-canonical commitment to be rejected, got %v", err)
+	}
+
+	vk = testVerifyingKey()
+	vk.ChipInformation[1].LogN = TWO_ADICITY + 1
+	if _, _, err := ComputeVKeyHash(vk); err == nil || !strings.Contains(err.Error(), "MemoryProgram") {
+		t.Fatalf("expected an oversized domain to be rejected, got %v", err)
+	}
+}
+
+type vkeyHashCircuit struct {
+	Sp1VkDigest [DIGEST_SIZE]frontend.Variable
+	VkeyHash    frontend.Variable `gnark:",public"`
+}
+
+func (circuit *vkeyHashCircuit) Define(api frontend.API) error {
+	chip := babybear.NewChip(api)
+	var pv PublicValues
+	for i, v := range circuit.Sp1VkDigest {
+		pv.Sp1VkDigest[i] = babybear.Variable{Value: v, NbBits: 31}
+	}
+	AssertVkeyHash(api, chip, &pv, circuit.VkeyHash)
+	return nil
+}
+
+func TestAssertVkeyHash(t *testing.T) {
+	words, packed, err := ComputeVKeyHash(testVerifyingKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	solve := func(mutate func(*vkeyHashCircuit)) error {
+		assignment := vkeyHashCircuit{VkeyHash: packed}
+		for i, word := range words {
+			assignment.Sp1VkDigest[i] = word
+		}
+		if mutate != nil {
+			mutate(&assignment)
+		}
+		return test.IsSolved(&vkeyHashCircuit{}, &assignment, ecc.BN254.ScalarField())
+	}
+	if err := solve(nil); err != nil {
+		t.Fatal(err)
+	}
+	if uint64(words[0])+babybear.MODULUS.Uint64() >= 1<<31 {
+		t.Fatal("expected the first word to have a non-canonical representative")
+	}
+
+	other, _, err := ComputeVKeyHash(&VerifyingKey{PcStart: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, mutate := range map[string]func(*vkeyHashCircuit){
+		"other program": func(c *vkeyHashCircuit) {
+			for i, word := range other {
+				c.Sp1VkDigest[i] = word
+			}
+		},
+		"vkey hash": func(c *vkeyHashCircuit) { c.VkeyHash = new(big.Int).Add(packed, big.NewInt(1)) },
+		// The first word plus p is the same BabyBear element below 2^31, with another packing.
+		"non-canonical word": func(c *vkeyHashCircuit) {
+			c.Sp1VkDigest[0] = uint64(words[0]) + babybear.MODULUS.Uint64()
+			c.VkeyHash = new(big.Int).Add(packed, new(big.Int).Lsh(babybear.MODULUS, 31*(DIGEST_SIZE-1)))
+		},
+	} {
+		if err := solve(mutate); err == nil {
+			t.Errorf("%s: expected the vkey hash to be rejected", name)
+		}
+	}
+}
+
+func BenchmarkComputeVKeyHash(b *testing.B) {
+	vk := testVerifyingKey()
+	for i := 0; i < 40; i++ {
+		vk.ChipInformation = append(vk.ChipInformation, ChipInformation{Name: "Chip", LogN: 20, Shift: 31})
+	}
+	for i := 0; i < b.N; i++ {
+		if _, _, err := ComputeVKeyHash(vk); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -1,30 +1,60 @@
 package sp1
 
 import (
-	"bytes"
-	"encoding/hex"
+	"context"
 	"os"
 
 	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark/backend/plonk"
+	plonk_bn254 "github.com/consensys/gnark/backend/plonk/bn254"
 	"github.com/consensys/gnark/frontend"
-	"github.com/succinctlabs/sp1-recursion-gnark/sp1/babybear"
+	"github.com/succinctlabs/sp1-recursion-gnark/sp1/logging"
 )
 
+// VerifyOptions configures VerifyWithOptions.
+type VerifyOptions struct {
+	// System is the proving system the data directory was built for, PLONK by default.
+	System ProvingSystem
+	// Logger receives the logs of the verification. The default logger is used if it is nil.
+	Logger logging.Logger
+}
+
 func Verify(verifyCmdDataDir string, verifyCmdProof string, verifyCmdVkeyHash string, verifyCmdCommitedValuesDigest string) error {
+	return VerifyWithOptions(verifyCmdDataDir, verifyCmdProof, verifyCmdVkeyHash, verifyCmdCommitedValuesDigest, VerifyOptions{})
+}
+
+// VerifyWithOptions is Verify, logging to the logger of opts.
+func VerifyWithOptions(verifyCmdDataDir string, verifyCmdProof string, verifyCmdVkeyHash string, verifyCmdCommitedValuesDigest string, opts VerifyOptions) error {
+	return VerifyContext(context.Background(), verifyCmdDataDir, verifyCmdProof, verifyCmdVkeyHash, verifyCmdCommitedValuesDigest, opts)
+}
+
+// VerifyContext is VerifyWithOptions, returning the error of ctx wrapped with the name of the
+// phase if ctx is done before the proof is checked.
+func VerifyContext(ctx context.Context, verifyCmdDataDir string, verifyCmdProof string, verifyCmdVkeyHash string, verifyCmdCommitedValuesDigest string, opts VerifyOptions) error {
 	// Sanity check the required arguments have been provided.
 	if verifyCmdDataDir == "" {
 		panic("--data is required")
 	}
+	if err := checkContext(ctx, "verify"); err != nil {
+		return err
+	}
+	if isGroth16, err := opts.System.isGroth16(); err != nil {
+		return err
+	} else if isGroth16 {
+		return VerifyGroth16(verifyCmdDataDir, verifyCmdProof, verifyCmdVkeyHash, verifyCmdCommitedValuesDigest, opts)
+	}
+	logger := logging.OrDefault(opts.Logger)
+	metrics := NewMetrics(logger)
+	defer metrics.Start("verify")()
+
+	if err := validateCommittedValuesDigest(verifyCmdCommitedValuesDigest); err != nil {
+		return err
+	}
 
 	// Decode the proof.
-	proofDecodedBytes, err := hex.DecodeString(verifyCmdProof)
+	proof, err := DecodeProofHex(verifyCmdProof)
 	if err != nil {
-		panic(err)
-	}
-	proof := plonk.NewProof(ecc.BN254)
-	if _, err := proof.ReadFrom(bytes.NewReader(proofDecodedBytes)); err != nil {
-		panic(err)
+		return err
 	}
 
 	// Read the verifier key.
@@ -35,15 +65,17 @@ func Verify(verifyCmdDataDir string, verifyCmdProof string, verifyCmdVkeyHash st
 	vk := plonk.NewVerifyingKey(ecc.BN254)
 	vk.ReadFrom(vkFile)
 
-	// Compute the public witness.
-	circuit := Circuit{
-		Vars:                 []frontend.Variable{},
-		Felts:                []babybear.Variable{},
-		Exts:                 []babybear.ExtensionVariable{},
+	// Compute the public witness, hashing the public values if the circuit was built with a single
+	// public input.
+	mode := publicInputsMode(int(vk.(*plonk_bn254.VerifyingKey).NbPublicVariables))
+	circuit, _, err := newModeCircuit(WitnessInput{
 		VkeyHash:             verifyCmdVkeyHash,
 		CommitedValuesDigest: verifyCmdCommitedValuesDigest,
+	}, mode)
+	if err != nil {
+		return err
 	}
-	witness, err := frontend.NewWitness(&circuit, ecc.BN254.ScalarField())
+	witness, err := frontend.NewWitness(circuit, ecc.BN254.ScalarField())
 	if err != nil {
 		panic(err)
 	}
@@ -53,6 +85,13 @@ func Verify(verifyCmdDataDir string, verifyCmdProof string, verifyCmdVkeyHash st
 	}
 
 	// Verify proof.
-	err = plonk.Verify(proof, vk, publicWitness)
-	return err
+	if err := checkContext(ctx, "verify"); err != nil {
+		return err
+	}
+	if err := plonk.Verify(proof, vk, publicWitness); err != nil {
+		logger.Warn("invalid proof", "vkey_hash", verifyCmdVkeyHash, "error", err)
+		return err
+	}
+	logger.Info("verified proof", "vkey_hash", verifyCmdVkeyHash, "mode", mode)
+	return nil
 }
@@ -39,7 +39,7 @@ fn call_docker(args: &[&str], mounts: &[(&str, &str)]) -> anyhow::Result<()> {
     Ok(())
 }
 
-pub fn prove_plonk_bn254(data_dir: &str, witness_path: &str) -> PlonkBn254Proof {
+pub fn prove_plonk_bn254(data_dir: &str, witness_path: &str) -> Result<PlonkBn254Proof, String> {
     let output_file = tempfile::NamedTempFile::new().unwrap();
     let mounts = [
         (data_dir, "/circuit"),
@@ -49,9 +49,25 @@ pub fn prove_plonk_bn254(data_dir: &str, witness_path: &str) -> PlonkBn254Proof
     assert_docker();
     call_docker(&["prove-plonk", "/circuit", "/witness", "/output"], &mounts)
         .expect("failed to prove with docker");
+    // The CLI writes the result of proving, so that the errors of the Go prover come back too.
     bincode::deserialize_from(&output_file).expect("failed to deserialize result")
 }
 
+/// The prover runs in a separate container, which renders its own progress line, so there is no
+/// progress to poll from this process.
+pub fn progress_plonk_bn254() -> Option<(String, i32, i32)> {
+    None
+}
+
+/// The prover runs in a separate container, whose logs are not routed through this process, so the
+/// level is only validated.
+pub fn set_log_level_plonk_bn254(level: &str) -> Result<(), String> {
+    match level.to_lowercase().as_str() {
+        "debug" | "info" | "warn" | "error" => Ok(()),
+        _ => Err(format!("invalid log level {:?}", level)),
+    }
+}
+
 pub fn build_plonk_bn254(data_dir: &str) {
     let circuit_dir = if data_dir.ends_with("dev") {
         "/circuit_dev"
@@ -109,6 +125,24 @@ pub fn test_plonk_bn254(witness_json: &str, constraints_json: &str) {
         .expect("failed to test with docker");
 }
 
+pub fn check_plonk_bn254(witness_json: &str, constraints_json: &str) -> Result<(), String> {
+    let output_file = tempfile::NamedTempFile::new().unwrap();
+    let mounts = [
+        (constraints_json, "/constraints"),
+        (witness_json, "/witness"),
+        (output_file.path().to_str().unwrap(), "/output"),
+    ];
+    assert_docker();
+    call_docker(&["check", "/witness", "/constraints", "/output"], &mounts)
+        .expect("failed to check with docker");
+    let result = std::fs::read_to_string(output_file.path()).unwrap();
+    if result == "OK" {
+        Ok(())
+    } else {
+        Err(result)
+    }
+}
+
 pub fn test_babybear_poseidon2() {
     unimplemented!()
 }
@@ -16,20 +16,50 @@ mod bind {
 }
 use bind::*;
 
-pub fn prove_plonk_bn254(data_dir: &str, witness_path: &str) -> PlonkBn254Proof {
+pub fn prove_plonk_bn254(data_dir: &str, witness_path: &str) -> Result<PlonkBn254Proof, String> {
     let data_dir = CString::new(data_dir).expect("CString::new failed");
     let witness_path = CString::new(witness_path).expect("CString::new failed");
 
     let proof = unsafe {
-        let proof = bind::ProvePlonkBn254(
+        bind::ProvePlonkBn254(
             data_dir.as_ptr() as *mut c_char,
             witness_path.as_ptr() as *mut c_char,
-        );
-        // Safety: The pointer is returned from the go code and is guaranteed to be valid.
-        *proof
+        )
     };
+    if proof.is_null() {
+        return Err("failed to allocate the proof".to_string());
+    }
+
+    // Safety: The pointer is returned from the go code and is guaranteed to be valid.
+    unsafe { *proof }.into_rust()
+}
+
+/// Returns the stage of the running `prove_plonk_bn254` call with the number of steps done out of
+/// its total, or None when no proof is being generated.
+pub fn progress_plonk_bn254() -> Option<(String, i32, i32)> {
+    let mut done = 0;
+    let mut total = 0;
+    let stage = unsafe { bind::ProgressPlonkBn254(&mut done, &mut total) };
+    if stage.is_null() {
+        None
+    } else {
+        // Safety: The stage is returned from the go code and is guaranteed to be valid.
+        Some((unsafe { c_char_ptr_to_string(stage) }, done, total))
+    }
+}
 
-    proof.into_rust()
+/// Sets the minimum level of the logs of the Go prover: one of "debug", "info", "warn" or "error".
+pub fn set_log_level_plonk_bn254(level: &str) -> Result<(), String> {
+    let level = CString::new(level).expect("CString::new failed");
+
+    let err_ptr = unsafe { bind::SetLogLevelPlonkBn254(level.as_ptr() as *mut c_char) };
+    if err_ptr.is_null() {
+        Ok(())
+    } else {
+        // Safety: The error message is returned from the go code and is guaranteed to be valid.
+        let err = unsafe { CString::from_raw(err_ptr) };
+        Err(err.into_string().unwrap())
+    }
 }
 
 pub fn build_plonk_bn254(data_dir: &str) {
@@ -85,6 +115,25 @@ pub fn test_plonk_bn254(witness_json: &str, constraints_json: &str) {
     }
 }
 
+pub fn check_plonk_bn254(witness_json: &str, constraints_json: &str) -> Result<(), String> {
+    let witness_json = CString::new(witness_json).expect("CString::new failed");
+    let constraints_json = CString::new(constraints_json).expect("CString::new failed");
+
+    let err_ptr = unsafe {
+        bind::CheckPlonkBn254(
+            witness_json.as_ptr() as *mut c_char,
+            constraints_json.as_ptr() as *mut c_char,
+        )
+    };
+    if err_ptr.is_null() {
+        Ok(())
+    } else {
+        // Safety: The error message is returned from the go code and is guaranteed to be valid.
+        let err = unsafe { CString::from_raw(err_ptr) };
+        Err(err.into_string().unwrap())
+    }
+}
+
 pub fn test_babybear_poseidon2() {
     unsafe {
         let err_ptr = bind::TestPoseidonBabyBear2();
@@ -110,11 +159,15 @@ unsafe fn c_char_ptr_to_string(input: *mut c_char) -> String {
 }
 
 impl C_PlonkBn254Proof {
-    /// Converts a C PlonkBn254Proof into a Rust PlonkBn254Proof, freeing the C strings.
-    fn into_rust(self) -> PlonkBn254Proof {
+    /// Converts a C PlonkBn254Proof into a Rust PlonkBn254Proof, or into the error the Go prover
+    /// returned instead, freeing the C strings.
+    fn into_rust(self) -> Result<PlonkBn254Proof, String> {
         // Safety: The raw pointers are not used anymore after converted into Rust strings.
         unsafe {
-            PlonkBn254Proof {
+            if !self.Error.is_null() {
+                return Err(c_char_ptr_to_string(self.Error));
+            }
+            Ok(PlonkBn254Proof {
                 public_inputs: [
                     c_char_ptr_to_string(self.PublicInputs[0]),
                     c_char_ptr_to_string(self.PublicInputs[1]),
@@ -122,7 +175,7 @@ impl C_PlonkBn254Proof {
                 encoded_proof: c_char_ptr_to_string(self.EncodedProof),
                 raw_proof: c_char_ptr_to_string(self.RawProof),
                 plonk_vkey_hash: [0; 32],
-            }
+            })
         }
     }
 }
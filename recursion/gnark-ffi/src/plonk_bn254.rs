@@ -4,7 +4,9 @@ use std::{
     path::{Path, PathBuf},
 };
 
-use crate::ffi::{build_plonk_bn254, prove_plonk_bn254, test_plonk_bn254, verify_plonk_bn254};
+use crate::ffi::{
+    build_plonk_bn254, check_plonk_bn254, prove_plonk_bn254, test_plonk_bn254, verify_plonk_bn254,
+};
 use crate::witness::GnarkWitness;
 
 use num_bigint::BigUint;
@@ -62,6 +64,30 @@ impl PlonkBn254Prover {
         );
     }
 
+    /// Checks that the witness satisfies the circuit definition using gnark's test engine, without
+    /// compiling the circuit or generating a proof.
+    pub fn check<C: Config>(
+        constraints: Vec<Constraint>,
+        witness: Witness<C>,
+    ) -> Result<(), String> {
+        let serialized = serde_json::to_string(&constraints).unwrap();
+
+        // Write constraints.
+        let mut constraints_file = tempfile::NamedTempFile::new().unwrap();
+        constraints_file.write_all(serialized.as_bytes()).unwrap();
+
+        // Write witness.
+        let mut witness_file = tempfile::NamedTempFile::new().unwrap();
+        let gnark_witness = GnarkWitness::new(witness);
+        let serialized = serde_json::to_string(&gnark_witness).unwrap();
+        witness_file.write_all(serialized.as_bytes()).unwrap();
+
+        check_plonk_bn254(
+            witness_file.path().to_str().unwrap(),
+            constraints_file.path().to_str().unwrap(),
+        )
+    }
+
     /// Builds the PLONK circuit locally.
     pub fn build<C: Config>(constraints: Vec<Constraint>, witness: Witness<C>, build_dir: PathBuf) {
         let serialized = serde_json::to_string(&constraints).unwrap();
@@ -120,7 +146,8 @@ impl PlonkBn254Prover {
         let mut proof = prove_plonk_bn254(
             build_dir.to_str().unwrap(),
             witness_file.path().to_str().unwrap(),
-        );
+        )
+        .expect("failed to prove");
         proof.plonk_vkey_hash = Self::get_vkey_hash(&build_dir);
         proof
     }
@@ -276,15 +276,22 @@ pub mod tests {
     use p3_challenger::FieldChallenger;
     use p3_commit::Pcs;
     use p3_commit::TwoAdicMultiplicativeCoset;
+    use p3_field::AbstractExtensionField;
     use p3_field::AbstractField;
+    use p3_field::PrimeField32;
+    use p3_fri::FriConfig;
     use p3_matrix::dense::RowMajorMatrix;
     use rand::rngs::OsRng;
+    use rand::rngs::StdRng;
+    use rand::SeedableRng;
     use sp1_core::utils::baby_bear_poseidon2::compressed_fri_config;
     use sp1_core::utils::inner_perm;
     use sp1_core::utils::InnerChallenge;
+    use sp1_core::utils::InnerChallengeMmcs;
     use sp1_core::utils::InnerChallenger;
     use sp1_core::utils::InnerCompress;
     use sp1_core::utils::InnerDft;
+    use sp1_core::utils::InnerDigestHash;
     use sp1_core::utils::InnerHash;
     use sp1_core::utils::InnerPcs;
     use sp1_core::utils::InnerPcsProof;
@@ -407,4 +414,201 @@ pub mod tests {
         let (program, witness) = build_test_fri_with_cols_and_log2_rows(10, 16);
         run_test_recursion(program, Some(witness), TestConfig::All);
     }
+
+    fn format_felts(felts: &[InnerVal]) -> String {
+        format!(
+            "[{}]",
+            felts.iter().map(|f| f.as_canonical_u32()).join(", ")
+        )
+    }
+
+    fn format_ext(x: &InnerChallenge) -> String {
+        format_felts(x.as_base_slice())
+    }
+
+    fn format_list<T>(items: &[T], format: impl Fn(&T) -> String) -> String {
+        format!("[{}]", items.iter().map(format).join(", "))
+    }
+
+    /// Prints a proof of the openings of random matrices with the given log domain sizes and
+    /// widths, in the format of the Go verifier tests. The first matrix of the first round is
+    /// opened at zeta and zeta + 1, and the others at zeta.
+    fn print_two_adic_pcs_fixture(log_domain_sizes: &[Vec<usize>], widths: &[Vec<usize>]) {
+        let mut rng = StdRng::seed_from_u64(506);
+        let perm = inner_perm();
+        let hash = InnerHash::new(perm.clone());
+        let compress = InnerCompress::new(perm.clone());
+        let fri_config = FriConfig {
+            log_blowup: 1,
+            num_queries: 2,
+            proof_of_work_bits: 2,
+            mmcs: InnerChallengeMmcs::new(InnerValMmcs::new(hash.clone(), compress.clone())),
+        };
+        let (log_blowup, num_queries, proof_of_work_bits) = (
+            fri_config.log_blowup,
+            fri_config.num_queries,
+            fri_config.proof_of_work_bits,
+        );
+        let pcs = InnerPcs::new(
+            3,
+            InnerDft {},
+            InnerValMmcs::new(hash, compress),
+            fri_config,
+        );
+
+        let mut challenger = InnerChallenger::new(perm.clone());
+        let mut commits = Vec::new();
+        let mut data = Vec::new();
+        let mut domains = Vec::new();
+        for (log_sizes, widths) in log_domain_sizes.iter().zip(widths) {
+            let round_domains = log_sizes
+                .iter()
+                .map(|&log_size| {
+                    <InnerPcs as Pcs<InnerChallenge, InnerChallenger>>::natural_domain_for_degree(
+                        &pcs,
+                        1 << log_size,
+                    )
+                })
+                .collect::<Vec<_>>();
+            let evaluations = round_domains
+                .iter()
+                .zip(log_sizes.iter().zip(widths))
+                .map(|(&domain, (&log_size, &width))| {
+                    (
+                        domain,
+                        RowMajorMatrix::<InnerVal>::rand(&mut rng, 1 << log_size, width),
+                    )
+                })
+                .collect::<Vec<_>>();
+            let (commit, round_data) =
+                <InnerPcs as Pcs<InnerChallenge, InnerChallenger>>::commit(&pcs, evaluations);
+            challenger.observe(commit);
+            commits.push(commit);
+            data.push(round_data);
+            domains.push(round_domains);
+        }
+        let zeta = challenger.sample_ext_element::<InnerChallenge>();
+        let points = domains
+            .iter()
+            .enumerate()
+            .map(|(r, round_domains)| {
+                (0..round_domains.len())
+                    .map(|i| match (r, i) {
+                        (0, 0) => vec![zeta, zeta + InnerChallenge::one()],
+                        _ => vec![zeta],
+                    })
+                    .collect::<Vec<_>>()
+            })
+            .collect::<Vec<_>>();
+        let (values, proof) = pcs.open(data.iter().zip(points.clone()).collect(), &mut challenger);
+
+        // Check the proof before printing it.
+        let mut challenger = InnerChallenger::new(perm);
+        let mut claims = Vec::new();
+        for (r, &commit) in commits.iter().enumerate() {
+            challenger.observe(commit);
+            let mats: Vec<(
+                TwoAdicMultiplicativeCoset<InnerVal>,
+                Vec<(InnerChallenge, Vec<InnerChallenge>)>,
+            )> = domains[r]
+                .iter()
+                .zip(&points[r])
+                .zip(&values[r])
+                .map(|((&domain, points), values)| {
+                    (domain, points.iter().copied().zip(values.clone()).collect())
+                })
+                .collect();
+            claims.push((commit, mats));
+        }
+        challenger.sample_ext_element::<InnerChallenge>();
+        pcs.verify(claims, &proof, &mut challenger).unwrap();
+
+        let digest =
+            |commit: &InnerDigestHash| format_felts(&<[InnerVal; DIGEST_SIZE]>::from(*commit));
+        let path =
+            |siblings: &Vec<[InnerVal; DIGEST_SIZE]>| format_list(siblings, |s| format_felts(s));
+        let fri_proof = &proof.fri_proof;
+        let query_proofs = format_list(&fri_proof.query_proofs, |query| {
+            format!(
+                "{{\"commit_phase_openings\": {}}}",
+                format_list(&query.commit_phase_openings, |step| format!(
+                    "{{\"sibling_value\": {}, \"opening_proof\": {}}}",
+                    format_ext(&step.sibling_value),
+                    path(&step.opening_proof),
+                )),
+            )
+        });
+        let query_openings = format_list(&proof.query_openings, |openings| {
+            format_list(openings, |opening| {
+                format!(
+                    "{{\"opened_values\": {}, \"opening_proof\": {}}}",
+                    format_list(&opening.opened_values, |row| format_felts(row)),
+                    path(&opening.opening_proof),
+                )
+            })
+        });
+        let fields = [
+            (
+                "config",
+                format!(
+                    "{{\"log_blowup\": {}, \"num_queries\": {}, \"proof_of_work_bits\": {}}}",
+                    log_blowup, num_queries, proof_of_work_bits
+                ),
+            ),
+            (
+                "log_domain_sizes",
+                format_list(log_domain_sizes, |sizes| {
+                    format!("[{}]", sizes.iter().join(", "))
+                }),
+            ),
+            ("commits", format_list(&commits, digest)),
+            (
+                "values",
+                format_list(&values, |round| {
+                    format_list(round, |mat| {
+                        format_list(mat, |point| format_list(point, format_ext))
+                    })
+                }),
+            ),
+            (
+                "commit_phase_commits",
+                format_list(&fri_proof.commit_phase_commits, digest),
+            ),
+            ("final_poly", format_ext(&fri_proof.final_poly)),
+            (
+                "pow_witness",
+                fri_proof.pow_witness.as_canonical_u32().to_string(),
+            ),
+            ("query_proofs", query_proofs),
+            ("query_openings", query_openings),
+        ];
+        println!(
+            "{{\n{}\n}}",
+            fields
+                .iter()
+                .map(|(key, value)| format!("  \"{}\": {}", key, value))
+                .join(",\n")
+        );
+    }
+
+    /// Prints the proof of sp1-recursion-gnark/sp1/verifier/testdata/two_adic_pcs_proof.json: a
+    /// round of two matrices over a domain of size 8, and a round of one matrix over a domain of
+    /// size 4.
+    #[test]
+    #[ignore]
+    fn test_generate_two_adic_pcs_fixture() {
+        print_two_adic_pcs_fixture(&[vec![3, 3], vec![2]], &[vec![2, 1], vec![2]]);
+    }
+
+    /// Prints the proof of
+    /// sp1-recursion-gnark/sp1/verifier/testdata/two_adic_pcs_mixed_heights_proof.json: a round of
+    /// matrices of three heights, two of them of the largest, and a round of three heights.
+    #[test]
+    #[ignore]
+    fn test_generate_two_adic_pcs_mixed_heights_fixture() {
+        print_two_adic_pcs_fixture(
+            &[vec![4, 2, 4, 3], vec![3, 1, 2]],
+            &[vec![3, 2, 1, 2], vec![2, 1, 2]],
+        );
+    }
 }